@@ -0,0 +1,163 @@
+package ufs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+)
+
+/*
+Archive-errors.go contains the classification of extraction failures, so callers can react to them:
+prompt for a password, offer to download the archive again, or suggest another tool.
+
+Extraction functions return an *ArchiveError when an archive can't be read, with its ArchiveErrorKind:
+- ArchivePasswordRequired (ErrArchivePasswordRequired): entries are encrypted and no password was given.
+  archive/zip can't decrypt, extract the archive with ExtractEncrypted.
+- ArchiveWrongPassword (ErrArchiveWrongPassword): the password given to ExtractEncrypted is wrong.
+- ArchiveCorrupt (ErrArchiveCorrupt): truncated archive, checksum mismatch, invalid headers or data.
+- ArchiveUnsupported (ErrArchiveUnsupported): compression or encryption method the reader doesn't know.
+
+The errors of archive/zip, archive/tar and the decompressors are classified, as well as the messages of
+the external tools (tar, 7-Zip, unrar, unar, bsdtar). Other failures (disk full, permission denied...)
+are returned as before.
+
+	err := ufs.ExtractArchive(archive, dest)
+	for errors.Is(err, ufs.ErrArchivePasswordRequired) || errors.Is(err, ufs.ErrArchiveWrongPassword) {
+	    err = ufs.ExtractEncrypted(archive, dest, askPassword())
+	}
+	if errors.Is(err, ufs.ErrArchiveCorrupt) {
+	    fmt.Println("The archive is damaged, download it again")
+	}
+
+Functions:
+- ExtractEncrypted: Extracts an encrypted archive with a password, see Archive-tools.go.
+*/
+
+// ArchiveErrorKind is the reason an archive couldn't be extracted, see ArchiveError.
+type ArchiveErrorKind int
+
+const (
+	// ArchivePasswordRequired means the entries are encrypted and no password was given
+	ArchivePasswordRequired ArchiveErrorKind = iota
+	// ArchiveWrongPassword means the password given doesn't decrypt the entries
+	ArchiveWrongPassword
+	// ArchiveCorrupt means the archive is truncated or its data or headers are invalid
+	ArchiveCorrupt
+	// ArchiveUnsupported means the archive uses a compression or encryption method the reader doesn't know
+	ArchiveUnsupported
+)
+
+func (kind ArchiveErrorKind) String() string {
+	switch kind {
+	case ArchivePasswordRequired:
+		return "password required"
+	case ArchiveWrongPassword:
+		return "wrong password"
+	case ArchiveCorrupt:
+		return "corrupted data"
+	case ArchiveUnsupported:
+		return "unsupported method"
+	}
+	return "unknown archive error"
+}
+
+// sentinel returns the error matched by errors.Is for the kind
+func (kind ArchiveErrorKind) sentinel() error {
+	switch kind {
+	case ArchivePasswordRequired:
+		return ErrArchivePasswordRequired
+	case ArchiveWrongPassword:
+		return ErrArchiveWrongPassword
+	case ArchiveCorrupt:
+		return ErrArchiveCorrupt
+	}
+	return ErrArchiveUnsupported
+}
+
+// zipFlagEncrypted is the general purpose flag of the encrypted zip entries (ZipCrypto and AES)
+const zipFlagEncrypted = 0x1
+
+// checkZipEncryption returns an *ArchiveError if an entry of the archive is encrypted,
+// archive/zip would read it as garbage or fail with an unsupported method
+func checkZipEncryption(archive string, files []*zip.File) error {
+	for _, file := range files {
+		if file.Flags&zipFlagEncrypted != 0 {
+			return &ArchiveError{Archive: archive, Entry: file.Name, Kind: ArchivePasswordRequired,
+				Err: errors.New("encrypted entries can't be extracted without a password, use ExtractEncrypted")}
+		}
+	}
+	return nil
+}
+
+// classifyArchiveError returns an *ArchiveError for the errors of the archive readers and decompressors,
+// and err itself for the others. entry is the entry being read, "" for the archive itself.
+func classifyArchiveError(archive, entry string, err error) error {
+	var archiveErr *ArchiveError
+	if err == nil || errors.As(err, &archiveErr) {
+		return err
+	}
+
+	var corruptInput flate.CorruptInputError
+	var structural bzip2.StructuralError
+	switch {
+	case errors.Is(err, zip.ErrAlgorithm):
+		return &ArchiveError{Archive: archive, Entry: entry, Kind: ArchiveUnsupported, Err: err}
+	case errors.Is(err, zip.ErrFormat), errors.Is(err, zip.ErrChecksum),
+		errors.Is(err, tar.ErrHeader), errors.Is(err, gzip.ErrHeader), errors.Is(err, gzip.ErrChecksum),
+		errors.Is(err, io.ErrUnexpectedEOF), errors.As(err, &corruptInput), errors.As(err, &structural):
+		return &ArchiveError{Archive: archive, Entry: entry, Kind: ArchiveCorrupt, Err: err}
+	}
+	return err
+}
+
+// toolPasswordMessages are the messages of the tools failing on encrypted entries, in lower case
+var toolPasswordMessages = []string{
+	"wrong password", "incorrect password", "password is incorrect", "incorrect passphrase",
+	"enter password", "password required", "requires a password", "passphrase required",
+	"encrypted file", "encrypted archive",
+}
+
+// toolCorruptMessages are the messages of the tools failing on damaged archives, in lower case
+var toolCorruptMessages = []string{
+	"crc failed", "checksum error", "data error", "headers error", "unexpected end", "truncated",
+	"corrupt", "damaged", "is not archive", "can not open the file as archive", "not in gzip format",
+	"unrecognized archive format", "invalid header", "bad header", "premature end",
+}
+
+// toolUnsupportedMessages are the messages of the tools failing on unknown methods, in lower case
+var toolUnsupportedMessages = []string{
+	"unsupported method", "unsupported compression", "unknown method", "unsupported encryption",
+	"unsupported feature",
+}
+
+// classifyToolFailure returns an *ArchiveError for the failure of an external tool whose output
+// explains it, and err itself otherwise. password is the password given to the tool, if any.
+func classifyToolFailure(archive string, output []byte, password string, err error) error {
+	message := strings.ToLower(string(output))
+	contains := func(messages []string) bool {
+		for _, m := range messages {
+			if strings.Contains(message, m) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Checked first, the tools report bad passwords as data errors too ("Data Error in encrypted file")
+	switch {
+	case contains(toolPasswordMessages) && password != "":
+		return &ArchiveError{Archive: archive, Kind: ArchiveWrongPassword, Err: err}
+	case contains(toolPasswordMessages):
+		return &ArchiveError{Archive: archive, Kind: ArchivePasswordRequired, Err: err}
+	case contains(toolUnsupportedMessages):
+		return &ArchiveError{Archive: archive, Kind: ArchiveUnsupported, Err: err}
+	case contains(toolCorruptMessages):
+		return &ArchiveError{Archive: archive, Kind: ArchiveCorrupt, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,322 @@
+package ufs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+Archive-fs.go contains functions to browse archives like directories, without extracting them.
+
+OpenArchiveFS mounts a ZIP or TAR archive as a read-only fs.FS, so the standard library helpers
+(fs.WalkDir, fs.ReadDir, fs.ReadFile, fs.Glob, http.FS...) and the ufs functions taking an fs.FS
+(ListDirectoryFS, RenderTreeFS) work the same on archives and on real directories. The other ufs
+functions take paths of the disk and can't read inside archives. Paths inside the archive are slash separated and relative,
+so archives holding paths longer than the Windows MAX_PATH limit can still be listed and read.
+
+Directories missing from the archive but implied by the paths of its files are listed too.
+Reading a file of a compressed TAR archive decompresses the archive up to that file,
+ZIP archives are read with random access.
+
+Functions:
+- OpenArchiveFS: Opens an archive as a read-only file system.
+- ReadArchiveTree: Reads the directory structure of an archive, like ReadDirectoryTree.
+*/
+
+// ArchiveFS is a read-only file system over the content of an archive. It must be closed after use.
+type ArchiveFS struct {
+	fs.FS
+	closer io.Closer
+}
+
+// Close releases the archive file
+func (afs *ArchiveFS) Close() error {
+	if afs.closer == nil {
+		return nil
+	}
+	return afs.closer.Close()
+}
+
+// OpenArchiveFS opens a ZIP or TAR archive as a read-only file system.
+// The format is detected from the file extension (.zip, .tar, .tar.gz, .tgz, .tar.bz2, .tbz2).
+//
+// Parameters:
+//   - path: The absolute or relative path to the archive
+//
+// Returns:
+//   - *ArchiveFS: The content of the archive as an fs.FS, to close after use
+//   - error: An error if the archive couldn't be read or its format is not supported
+//
+// Example:
+//
+//	archive, err := ufs.OpenArchiveFS("backup.zip")
+//	if err != nil {
+//	    fmt.Printf("Error opening archive: %v\n", err)
+//	    return
+//	}
+//	defer archive.Close()
+//	fs.WalkDir(archive, ".", func(path string, d fs.DirEntry, err error) error {
+//	    fmt.Println(path)
+//	    return err
+//	})
+func (ufs *UFS) OpenArchiveFS(path string) (_ *ArchiveFS, err error) {
+	defer ufs.recoverPanic("OpenArchiveFS", &err)
+
+	if !ufs.IsFile(path) {
+		return nil, ufs.misuseError("OpenArchiveFS", "path is not a file", path)
+	}
+
+	switch format := detectArchiveFormat(path); format {
+	case archiveFormatZip:
+		reader, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, ufs.wrapError(err, "OpenArchiveFS")
+		}
+		return &ArchiveFS{FS: reader, closer: reader}, nil
+
+	case archiveFormatTar, archiveFormatTarGz, archiveFormatTarBz2:
+		tfs, err := openTarFS(path, format)
+		if err != nil {
+			return nil, ufs.wrapError(err, "OpenArchiveFS")
+		}
+		return &ArchiveFS{FS: tfs}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported archive format: %s", path)
+	}
+}
+
+// ReadArchiveTree reads the directory structure of an archive, in the format returned by ReadDirectoryTree
+// and accepted by CreateDirectoryTree. Directories become nested maps (nil when empty). When includeFiles
+// is true, files are included as string leaves holding an empty string; otherwise they are left out.
+//
+// Parameters:
+//   - archivePath: The absolute or relative path to the archive
+//   - includeFiles: If true, files are included as string leaves
+//
+// Returns:
+//   - map[string]interface{}: The directory structure, or nil if the archive couldn't be read
+//
+// Example:
+//
+//	structure := ufs.ReadArchiveTree("template.zip", false)
+//	ok := ufs.CreateDirectoryTree("/path/to/new_project", structure)
+//	if !ok {
+//	    fmt.Printf("Error recreating directory tree\n")
+//	}
+func (ufs *UFS) ReadArchiveTree(archivePath string, includeFiles bool) map[string]interface{} {
+	defer ufs.recoverPanicReport("ReadArchiveTree")
+
+	archive, err := ufs.OpenArchiveFS(archivePath)
+	if err != nil {
+		ufs.handleError(err, "ReadArchiveTree")
+		return nil
+	}
+	defer archive.Close()
+
+	structure := map[string]interface{}{}
+	dirs := map[string]map[string]interface{}{".": structure}
+	err = fs.WalkDir(archive, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == "." {
+			return nil
+		}
+
+		parent := dirs[path.Dir(name)]
+		if d.IsDir() {
+			subStructure := map[string]interface{}{}
+			dirs[name] = subStructure
+			parent[d.Name()] = subStructure
+		} else if includeFiles {
+			parent[d.Name()] = ""
+		}
+		return nil
+	})
+	if err != nil {
+		ufs.handleError(err, "ReadArchiveTree")
+		return nil
+	}
+
+	emptyDirectoriesToNil(structure)
+	return structure
+}
+
+// tarFS is an fs.FS over a tar archive. The headers are indexed when it is opened,
+// the content of a file is read by scanning the archive again up to the file.
+type tarFS struct {
+	path   string
+	format int
+	nodes  map[string]*tarNode // By clean slash separated name, "." is the root
+}
+
+// tarNode is an entry of a tar archive, or a directory implied by the names of the entries
+type tarNode struct {
+	info     fs.FileInfo
+	index    int      // Position of the entry in the archive, -1 for implied directories
+	children []string // Names of the entries of a directory
+}
+
+// openTarFS indexes the entries of a tar archive
+func openTarFS(archivePath string, format int) (*tarFS, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stream, err := openTarStream(file, format)
+	if err != nil {
+		return nil, err
+	}
+
+	tfs := &tarFS{path: archivePath, format: format, nodes: map[string]*tarNode{
+		".": {info: impliedDirInfo("."), index: -1},
+	}}
+	reader := tar.NewReader(stream)
+	for index := 0; ; index++ {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "/"))
+		if name == "." || !fs.ValidPath(name) {
+			continue // Unsafe or root entry, not addressable through fs.FS
+		}
+		tfs.add(name, &tarNode{info: header.FileInfo(), index: index})
+	}
+
+	for _, node := range tfs.nodes {
+		sort.Strings(node.children)
+	}
+	return tfs, nil
+}
+
+// add adds a node and the directories implied by its name. A later entry with the same name replaces
+// the earlier one, like extracting the archive would.
+func (tfs *tarFS) add(name string, node *tarNode) {
+	if existing, ok := tfs.nodes[name]; ok {
+		node.children = existing.children
+		tfs.nodes[name] = node
+		return
+	}
+	tfs.nodes[name] = node
+
+	dir := path.Dir(name)
+	parent, ok := tfs.nodes[dir]
+	if !ok {
+		parent = &tarNode{info: impliedDirInfo(path.Base(dir)), index: -1}
+		tfs.add(dir, parent)
+	}
+	parent.children = append(parent.children, path.Base(name))
+}
+
+func (tfs *tarFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	node, ok := tfs.nodes[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if node.info.IsDir() {
+		return &tarDir{tfs: tfs, name: name, node: node}, nil
+	}
+	if !node.info.Mode().IsRegular() {
+		// Links and special files have no content
+		return &tarFile{info: node.info, reader: strings.NewReader("")}, nil
+	}
+
+	file, err := os.Open(tfs.path)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	stream, err := openTarStream(file, tfs.format)
+	if err != nil {
+		file.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	reader := tar.NewReader(stream)
+	for i := 0; i <= node.index; i++ {
+		if _, err := reader.Next(); err != nil {
+			file.Close()
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+	return &tarFile{info: node.info, reader: reader, closer: file}, nil
+}
+
+// tarFile is an open file of a tarFS
+type tarFile struct {
+	info   fs.FileInfo
+	reader io.Reader
+	closer io.Closer
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *tarFile) Read(p []byte) (int, error) { return f.reader.Read(p) }
+
+func (f *tarFile) Close() error {
+	if f.closer == nil {
+		return nil
+	}
+	return f.closer.Close()
+}
+
+// tarDir is an open directory of a tarFS
+type tarDir struct {
+	tfs    *tarFS
+	name   string
+	node   *tarNode
+	offset int // Entries already returned by ReadDir
+}
+
+func (d *tarDir) Stat() (fs.FileInfo, error) { return d.node.info, nil }
+
+func (d *tarDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fmt.Errorf("is a directory")}
+}
+
+func (d *tarDir) Close() error { return nil }
+
+func (d *tarDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.node.children[d.offset:]
+	if n > 0 && len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(remaining) {
+		remaining = remaining[:n]
+	}
+
+	entries := make([]fs.DirEntry, 0, len(remaining))
+	for _, child := range remaining {
+		entries = append(entries, fs.FileInfoToDirEntry(d.tfs.nodes[path.Join(d.name, child)].info))
+	}
+	d.offset += len(remaining)
+	return entries, nil
+}
+
+// impliedDirInfo describes a directory implied by the names of the entries of an archive
+type impliedDirInfo string
+
+func (name impliedDirInfo) Name() string  { return string(name) }
+func (impliedDirInfo) Size() int64        { return 0 }
+func (impliedDirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0755 }
+func (impliedDirInfo) ModTime() time.Time { return time.Time{} }
+func (impliedDirInfo) IsDir() bool        { return true }
+func (impliedDirInfo) Sys() interface{}   { return nil }
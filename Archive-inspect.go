@@ -0,0 +1,506 @@
+package ufs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+Archive-inspect.go contains functions to look inside archives without extracting them.
+
+These functions are useful to show previews of an archive or to validate its contents
+before calling ExtractArchive.
+
+Supported formats:
+- ZIP (.zip)
+- TAR (.tar)
+- Gzip compressed TAR (.tar.gz, .tgz)
+- Bzip2 compressed TAR (.tar.bz2, .tbz2)
+
+Functions:
+- ListArchiveContents: Lists all entries of an archive with their size, compressed size, mode and modification time.
+- CompareDirectoryWithArchive: Reports files added, removed or modified in a directory since a ZIP archive of it was made.
+- VerifyArchive: Decompresses every entry of an archive and reports the corrupted ones.
+- TestArchive: VerifyArchive that also reports the entries with unsafe paths, like "unzip -t".
+*/
+
+// ArchiveEntry describes a single entry (file or directory) stored inside an archive.
+type ArchiveEntry struct {
+	Name           string      // Path of the entry inside the archive (always uses forward slashes)
+	Size           int64       // Uncompressed size in bytes
+	CompressedSize int64       // Compressed size in bytes (equals Size for tar entries, which are compressed as a whole stream)
+	Mode           os.FileMode // Permission and mode bits of the entry
+	ModTime        time.Time   // Last modification time stored in the archive
+	IsDir          bool        // True if the entry is a directory
+}
+
+// archive format identifiers used internally to pick the right reader
+const (
+	archiveFormatUnknown = iota
+	archiveFormatZip
+	archiveFormatTar
+	archiveFormatTarGz
+	archiveFormatTarBz2
+)
+
+// ListArchiveContents lists all entries of a ZIP or TAR archive without extracting it.
+// The format is detected from the file extension (.zip, .tar, .tar.gz, .tgz, .tar.bz2, .tbz2).
+//
+// Parameters:
+//   - path: The absolute or relative path to the archive
+//
+// Returns:
+//   - []ArchiveEntry: The entries of the archive in the order they are stored
+//   - error: An error if the archive couldn't be read or its format is not supported
+//
+// Example:
+//
+//	entries, err := ufs.ListArchiveContents("/path/to/archive.zip")
+//	if err != nil {
+//	    fmt.Printf("Error listing archive: %v\n", err)
+//	    return
+//	}
+//	for _, entry := range entries {
+//	    fmt.Printf("%s (%d bytes)\n", entry.Name, entry.Size)
+//	}
+func (ufs *UFS) ListArchiveContents(path string) (_ []ArchiveEntry, err error) {
+	defer ufs.recoverPanic("ListArchiveContents", &err)
+
+	if err := ufs.requireOS("ListArchiveContents"); err != nil {
+		return nil, err
+	}
+
+	// Verify source is a file
+	if !ufs.IsFile(path) {
+		return nil, ufs.misuseError("ListArchiveContents", "source path is not a file", path)
+	}
+
+	switch detectArchiveFormat(path) {
+	case archiveFormatZip:
+		return ufs.listZipContents(path)
+	case archiveFormatTar, archiveFormatTarGz, archiveFormatTarBz2:
+		return ufs.listTarContents(path)
+	default:
+		return nil, fmt.Errorf("unsupported archive format: %s", path)
+	}
+}
+
+// CompareDirectoryWithArchive compares a directory with an existing ZIP archive of it,
+// for example to know whether a new backup is needed.
+// Nothing is extracted: modified files are detected using the sizes and CRC32 checksums
+// stored in the archive, local files are only read when their size matches.
+//
+// Parameters:
+//   - dir: The absolute or relative path to the directory
+//   - zipPath: The absolute or relative path to the ZIP archive made from the directory
+//
+// Returns:
+//   - *DirectoryDiff: Added (only in dir), Removed (only in the archive), Modified and Unchanged files,
+//     as paths relative to dir
+//   - error: An error if the directory or the archive couldn't be read
+//
+// Example:
+//
+//	diff, err := ufs.CompareDirectoryWithArchive("/path/to/project", "/backups/project.zip")
+//	if err != nil {
+//	    fmt.Printf("Error comparing with archive: %v\n", err)
+//	    return
+//	}
+//	if diff.HasChanges() {
+//	    fmt.Println("Project changed since the last backup")
+//	}
+func (ufs *UFS) CompareDirectoryWithArchive(dir, zipPath string) (_ *DirectoryDiff, err error) {
+	defer ufs.recoverPanic("CompareDirectoryWithArchive", &err)
+	defer ufs.applyIOPriority()()
+
+	if !ufs.IsDirectory(dir) {
+		return nil, ufs.misuseError("CompareDirectoryWithArchive", "source path is not a directory", dir)
+	}
+	if !ufs.IsFile(zipPath) {
+		return nil, ufs.misuseError("CompareDirectoryWithArchive", "archive path is not a file", zipPath)
+	}
+
+	localFiles, err := ufs.collectFiles(dir, "CompareDirectoryWithArchive")
+	if err != nil {
+		return nil, ufs.wrapError(err, "CompareDirectoryWithArchive")
+	}
+
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, ufs.wrapError(err, "CompareDirectoryWithArchive")
+	}
+	defer reader.Close()
+
+	diff := &DirectoryDiff{}
+	archived := map[string]bool{}
+
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		rel := filepath.FromSlash(strings.TrimPrefix(file.Name, "./"))
+		archived[rel] = true
+
+		info, exists := localFiles[rel]
+		if !exists {
+			diff.Removed = append(diff.Removed, rel)
+			continue
+		}
+
+		// Different sizes don't need the checksum
+		if info.Size() != int64(file.UncompressedSize64) {
+			diff.Modified = append(diff.Modified, rel)
+			continue
+		}
+
+		checksum, err := ufs.fileCRC32(filepath.Join(dir, rel))
+		if err != nil {
+			return nil, ufs.wrapError(err, "CompareDirectoryWithArchive")
+		}
+		if checksum != file.CRC32 {
+			diff.Modified = append(diff.Modified, rel)
+		} else {
+			diff.Unchanged = append(diff.Unchanged, rel)
+		}
+	}
+
+	// The archive itself may live in the compared directory, it is not a change
+	absZip, _ := filepath.Abs(zipPath)
+	for rel := range localFiles {
+		if archived[rel] {
+			continue
+		}
+		if absLocal, _ := filepath.Abs(filepath.Join(dir, rel)); absLocal == absZip {
+			continue
+		}
+		diff.Added = append(diff.Added, rel)
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Modified)
+	sort.Strings(diff.Unchanged)
+
+	return diff, nil
+}
+
+// ArchiveVerifyReport describes the result of VerifyArchive.
+type ArchiveVerifyReport struct {
+	Entries int            // Number of entries checked
+	Bytes   int64          // Uncompressed bytes read
+	Corrupt []CorruptEntry // Entries that couldn't be read back, empty when the archive is valid
+	Unsafe  []CorruptEntry // Entries with absolute or escaping paths, only checked by TestArchive
+}
+
+// CorruptEntry is an archive entry that failed verification.
+type CorruptEntry struct {
+	Name string // Path of the entry inside the archive
+	Err  error  // Why the entry is corrupted, e.g. zip.ErrChecksum
+}
+
+// OK reports whether every entry of the archive was read back successfully and has a safe path
+func (report *ArchiveVerifyReport) OK() bool {
+	return len(report.Corrupt) == 0 && len(report.Unsafe) == 0
+}
+
+// VerifyArchive checks the integrity of an archive without extracting it: every entry is
+// fully decompressed and discarded, checking the CRC32 of ZIP entries and the checksums of
+// tar headers and gzip streams. It is meant to validate backup archives on a schedule.
+// A corrupted ZIP entry doesn't stop the verification; in a tar stream nothing can be read
+// after a corruption, so the verification stops at the first corrupted entry.
+//
+// Parameters:
+//   - path: The absolute or relative path to the archive (.zip, .tar, .tar.gz, .tgz, .tar.bz2, .tbz2)
+//
+// Returns:
+//   - *ArchiveVerifyReport: The number of entries checked and the corrupted ones
+//   - error: An error if the archive couldn't be opened at all (missing file, unreadable ZIP directory,
+//     unsupported format), nil otherwise even when entries are corrupted
+//
+// Example:
+//
+//	report, err := ufs.VerifyArchive("/backups/project.zip")
+//	if err != nil {
+//	    fmt.Printf("Error verifying archive: %v\n", err)
+//	    return
+//	}
+//	for _, entry := range report.Corrupt {
+//	    fmt.Printf("%s is corrupted: %v\n", entry.Name, entry.Err)
+//	}
+func (ufs *UFS) VerifyArchive(path string) (_ *ArchiveVerifyReport, err error) {
+	defer ufs.recoverPanic("VerifyArchive", &err)
+
+	if err := ufs.requireOS("VerifyArchive"); err != nil {
+		return nil, err
+	}
+	defer ufs.applyIOPriority()()
+
+	// Verify source is a file
+	if !ufs.IsFile(path) {
+		return nil, ufs.misuseError("VerifyArchive", "source path is not a file", path)
+	}
+
+	return ufs.verifyArchive(path, false, "VerifyArchive")
+}
+
+// TestArchive tests an archive like "unzip -t" or "tar -t" would, without writing anything: it checks its
+// integrity like VerifyArchive, and also reports in Unsafe the entries that ExtractArchive would refuse
+// or that could be written outside of the destination by other tools: absolute paths ("/etc/passwd",
+// "C:\Windows") and paths escaping the destination ("../../.bashrc").
+//
+// Parameters:
+//   - path: The absolute or relative path to the archive (.zip, .tar, .tar.gz, .tgz, .tar.bz2, .tbz2)
+//
+// Returns:
+//   - *ArchiveVerifyReport: The number of entries checked, the corrupted ones and the unsafe ones,
+//     whose errors match ErrUnsafeArchivePath
+//   - error: An error if the archive couldn't be opened at all, nil otherwise even when entries are corrupted or unsafe
+//
+// Example:
+//
+//	report, err := ufs.TestArchive("/backups/nightly.tar.gz")
+//	if err != nil {
+//	    fmt.Printf("Error testing archive: %v\n", err)
+//	    return
+//	}
+//	if !report.OK() {
+//	    fmt.Printf("Backup is broken: %d corrupted and %d unsafe entries\n", len(report.Corrupt), len(report.Unsafe))
+//	}
+func (ufs *UFS) TestArchive(path string) (_ *ArchiveVerifyReport, err error) {
+	defer ufs.recoverPanic("TestArchive", &err)
+
+	if err := ufs.requireOS("TestArchive"); err != nil {
+		return nil, err
+	}
+	defer ufs.applyIOPriority()()
+
+	if !ufs.IsFile(path) {
+		return nil, ufs.misuseError("TestArchive", "source path is not a file", path)
+	}
+
+	return ufs.verifyArchive(path, true, "TestArchive")
+}
+
+// verifyArchive reads every entry of an archive, checking the entry paths when checkPaths is set
+func (ufs *UFS) verifyArchive(path string, checkPaths bool, operation string) (*ArchiveVerifyReport, error) {
+	switch detectArchiveFormat(path) {
+	case archiveFormatZip:
+		return ufs.verifyZip(path, checkPaths, operation)
+	case archiveFormatTar, archiveFormatTarGz, archiveFormatTarBz2:
+		return ufs.verifyTar(path, checkPaths, operation)
+	default:
+		return nil, fmt.Errorf("unsupported archive format: %s", path)
+	}
+}
+
+// checkEntryPath records an entry whose path is absolute or escapes the destination
+func (report *ArchiveVerifyReport) checkEntryPath(name string) {
+	slashed := strings.ReplaceAll(name, "\\", "/")
+	switch {
+	case strings.HasPrefix(slashed, "/") || (len(slashed) >= 2 && slashed[1] == ':'):
+		report.Unsafe = append(report.Unsafe, CorruptEntry{Name: name, Err: fmt.Errorf("%w: absolute path", ErrUnsafeArchivePath)})
+	case path.Clean(slashed) == ".." || strings.HasPrefix(path.Clean(slashed), "../"):
+		report.Unsafe = append(report.Unsafe, CorruptEntry{Name: name, Err: fmt.Errorf("%w: path escapes the destination", ErrUnsafeArchivePath)})
+	case strings.ContainsRune(name, 0):
+		report.Unsafe = append(report.Unsafe, CorruptEntry{Name: name, Err: fmt.Errorf("%w: NUL byte in path", ErrUnsafeArchivePath)})
+	}
+}
+
+// verifyZip reads every entry of a ZIP archive, archive/zip checks the CRC32 at the end of each entry
+func (ufs *UFS) verifyZip(path string, checkPaths bool, operation string) (*ArchiveVerifyReport, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, ufs.wrapError(err, operation)
+	}
+	defer reader.Close()
+
+	report := &ArchiveVerifyReport{}
+	for _, file := range reader.File {
+		report.Entries++
+		if checkPaths {
+			report.checkEntryPath(file.Name)
+		}
+
+		entry, err := file.Open()
+		if err != nil {
+			report.Corrupt = append(report.Corrupt, CorruptEntry{Name: file.Name, Err: err})
+			continue
+		}
+
+		n, err := io.Copy(io.Discard, entry)
+		entry.Close()
+		report.Bytes += n
+		if err != nil {
+			report.Corrupt = append(report.Corrupt, CorruptEntry{Name: file.Name, Err: err})
+		}
+	}
+
+	return report, nil
+}
+
+// verifyTar reads a (possibly compressed) tar archive to the end, tar.Reader checks the header
+// checksums and the gzip reader checks the CRC32 of the stream
+func (ufs *UFS) verifyTar(path string, checkPaths bool, operation string) (*ArchiveVerifyReport, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, ufs.wrapError(err, operation)
+	}
+	defer file.Close()
+
+	stream, err := openTarStream(file, detectArchiveFormat(path))
+	if err != nil {
+		return nil, ufs.wrapError(err, operation)
+	}
+
+	report := &ArchiveVerifyReport{}
+	tarReader := tar.NewReader(stream)
+	name := ""
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			// Read the compressed stream to its end, the gzip checksum is only checked there
+			if _, err := io.Copy(io.Discard, stream); err != nil {
+				report.Corrupt = append(report.Corrupt, CorruptEntry{Name: "(after " + name + ")", Err: err})
+			}
+			break
+		}
+		if err != nil {
+			// The stream is unusable from here, report the entry following the last good one
+			report.Corrupt = append(report.Corrupt, CorruptEntry{Name: "(after " + name + ")", Err: err})
+			break
+		}
+
+		name = header.Name
+		report.Entries++
+		if checkPaths {
+			report.checkEntryPath(name)
+		}
+
+		n, err := io.Copy(io.Discard, tarReader)
+		report.Bytes += n
+		if err != nil {
+			report.Corrupt = append(report.Corrupt, CorruptEntry{Name: name, Err: err})
+			break
+		}
+	}
+
+	return report, nil
+}
+
+// fileCRC32 computes the IEEE CRC32 checksum of a file, as stored in ZIP archives
+func (ufs *UFS) fileCRC32(path string) (uint32, error) {
+	file, err := ufs.openSequential(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	hasher := crc32.NewIEEE()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return 0, err
+	}
+	return hasher.Sum32(), nil
+}
+
+// listZipContents is a helper function to list the entries of a zip archive
+func (ufs *UFS) listZipContents(path string) ([]ArchiveEntry, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, ufs.wrapError(err, "ListArchiveContents")
+	}
+	defer reader.Close()
+
+	entries := make([]ArchiveEntry, 0, len(reader.File))
+	for _, file := range reader.File {
+		info := file.FileInfo()
+		entries = append(entries, ArchiveEntry{
+			Name:           file.Name,
+			Size:           int64(file.UncompressedSize64),
+			CompressedSize: int64(file.CompressedSize64),
+			Mode:           file.Mode(),
+			ModTime:        file.Modified,
+			IsDir:          info.IsDir(),
+		})
+	}
+
+	return entries, nil
+}
+
+// listTarContents is a helper function to list the entries of a (possibly compressed) tar archive
+func (ufs *UFS) listTarContents(path string) ([]ArchiveEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, ufs.wrapError(err, "ListArchiveContents")
+	}
+	defer file.Close()
+
+	stream, err := openTarStream(file, detectArchiveFormat(path))
+	if err != nil {
+		return nil, ufs.wrapError(err, "ListArchiveContents")
+	}
+
+	var entries []ArchiveEntry
+	tarReader := tar.NewReader(stream)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return entries, ufs.wrapError(err, "ListArchiveContents")
+		}
+
+		info := header.FileInfo()
+		entries = append(entries, ArchiveEntry{
+			Name:           header.Name,
+			Size:           header.Size,
+			CompressedSize: header.Size,
+			Mode:           info.Mode(),
+			ModTime:        header.ModTime,
+			IsDir:          info.IsDir(),
+		})
+	}
+
+	return entries, nil
+}
+
+// openTarStream wraps the given reader with the decompressor matching the archive format
+func openTarStream(r io.Reader, format int) (io.Reader, error) {
+	switch format {
+	case archiveFormatTarGz:
+		return gzip.NewReader(r)
+	case archiveFormatTarBz2:
+		return bzip2.NewReader(r), nil
+	default:
+		return r, nil
+	}
+}
+
+// detectArchiveFormat guesses the archive format from the file extension
+func detectArchiveFormat(path string) int {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return archiveFormatZip
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return archiveFormatTarGz
+	case strings.HasSuffix(lower, ".tar.bz2"), strings.HasSuffix(lower, ".tbz2"):
+		return archiveFormatTarBz2
+	case strings.HasSuffix(lower, ".tar"):
+		return archiveFormatTar
+	default:
+		return archiveFormatUnknown
+	}
+}
@@ -0,0 +1,292 @@
+package ufs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+/*
+Archive-tools.go contains functions to extract formats Go can't read, using the programs installed on the system.
+
+7z and RAR archives are extracted by the first tool found among the ones able to read the format.
+The tools have different command lines, which are normalized here:
+- 7z, 7zz, 7za (7-Zip / p7zip): 7z x -y -o<dest> <archive>
+- unrar (RARLAB): unrar x -o+ -y <archive> <dest>/
+- unar (The Unarchiver, macOS): unar -f -o <dest> <archive>
+- bsdtar (libarchive, tar.exe on Windows): bsdtar -xf <archive> -C <dest>
+
+The system tar is used as bsdtar where it is one (Windows, macOS, FreeBSD), and on Windows the default
+installation folders of 7-Zip and WinRAR are searched as well, as they are usually not in the PATH.
+
+Passwords are never put on the command line, where other users of the machine can read them: the tools
+run without a terminal and read the password from their standard input when they ask for it. unar only
+takes the password on its command line, so it isn't used for encrypted archives.
+When no tool is installed, a *ToolNotFoundError (matching ErrToolNotFound) is returned.
+Failures the tools explain (wrong password, damaged archive...) are returned as an *ArchiveError,
+see Archive-errors.go.
+
+Functions:
+- Extract7z: Extracts a 7z archive.
+- ExtractRar: Extracts a RAR archive.
+- ExtractEncrypted: Extracts a password protected ZIP, 7z or RAR archive.
+*/
+
+// archiveTool is an external program able to extract some archive formats
+type archiveTool struct {
+	name          string                              // Program name, looked up in the PATH
+	args          func(archive, dest string) []string // Command line extracting archive into dest
+	readsPassword bool                                // Asks for the password of encrypted archives on its standard input
+}
+
+var (
+	sevenZipArgs = func(archive, dest string) []string {
+		return []string{"x", "-y", "-o" + dest, archive}
+	}
+	unrarArgs = func(archive, dest string) []string {
+		// unrar only treats the last argument as a directory when it ends with a separator
+		return []string{"x", "-o+", "-y", archive, dest + string(os.PathSeparator)}
+	}
+	unarArgs = func(archive, dest string) []string {
+		return []string{"-f", "-o", dest, archive}
+	}
+	bsdtarArgs = func(archive, dest string) []string {
+		return []string{"-xf", archive, "-C", dest}
+	}
+)
+
+// toolPasswordPrompts are the prompts of the tools asking for a password, removed from their output
+var toolPasswordPrompts = []string{"Enter password (will not be echoed)", "Enter passphrase:", "Enter password:"}
+
+// sevenZipTools are the tools able to extract 7z archives, in order of preference
+var sevenZipTools = []archiveTool{
+	{"7z", sevenZipArgs, true},
+	{"7zz", sevenZipArgs, true},
+	{"7za", sevenZipArgs, true},
+	{"7zr", sevenZipArgs, true},
+	{"bsdtar", bsdtarArgs, true},
+}
+
+// rarTools are the tools able to extract RAR archives, in order of preference
+var rarTools = []archiveTool{
+	{"unrar", unrarArgs, true},
+	{"7z", sevenZipArgs, true},
+	{"7zz", sevenZipArgs, true},
+	{"unar", unarArgs, false},
+	{"bsdtar", bsdtarArgs, true},
+}
+
+// Extract7z extracts a 7z archive using 7-Zip (7z, 7zz, 7za, 7zr) or bsdtar, whichever is installed.
+//
+// Parameters:
+//   - sourcePath: The absolute or relative path to the 7z archive
+//   - destPath: The absolute or relative path where the contents will be extracted
+//
+// Returns:
+//   - error: A *ToolNotFoundError if no tool is installed, an error if the extraction failed, nil otherwise
+//
+// Example:
+//
+//	err := ufs.Extract7z("/downloads/dataset.7z", "/data/dataset")
+//	if errors.Is(err, ufs.ErrToolNotFound) {
+//	    fmt.Println("Please install 7-Zip")
+//	} else if err != nil {
+//	    fmt.Printf("Error extracting archive: %v\n", err)
+//	}
+func (ufs *UFS) Extract7z(sourcePath, destPath string) (err error) {
+	defer ufs.recoverPanic("Extract7z", &err)
+
+	if err := ufs.requireOS("Extract7z"); err != nil {
+		return err
+	}
+	return ufs.extractWithTools(sourcePath, destPath, "", "7z", sevenZipTools, "Extract7z")
+}
+
+// ExtractRar extracts a RAR archive using unrar, 7-Zip, unar or bsdtar, whichever is installed.
+//
+// Parameters:
+//   - sourcePath: The absolute or relative path to the RAR archive
+//   - destPath: The absolute or relative path where the contents will be extracted
+//
+// Returns:
+//   - error: A *ToolNotFoundError if no tool is installed, an error if the extraction failed, nil otherwise
+//
+// Example:
+//
+//	err := ufs.ExtractRar("/downloads/photos.rar", "/pictures/photos")
+//	if errors.Is(err, ufs.ErrToolNotFound) {
+//	    fmt.Println("Please install unrar or 7-Zip")
+//	} else if err != nil {
+//	    fmt.Printf("Error extracting archive: %v\n", err)
+//	}
+func (ufs *UFS) ExtractRar(sourcePath, destPath string) (err error) {
+	defer ufs.recoverPanic("ExtractRar", &err)
+
+	if err := ufs.requireOS("ExtractRar"); err != nil {
+		return err
+	}
+	return ufs.extractWithTools(sourcePath, destPath, "", "RAR", rarTools, "ExtractRar")
+}
+
+// zipTools are the tools able to extract encrypted ZIP archives, in order of preference
+var zipTools = []archiveTool{
+	{"7z", sevenZipArgs, true},
+	{"7zz", sevenZipArgs, true},
+	{"7za", sevenZipArgs, true},
+	{"unar", unarArgs, false},
+	{"bsdtar", bsdtarArgs, true},
+}
+
+// ExtractEncrypted extracts a password protected archive using the tools of Extract7z for 7z archives,
+// of ExtractRar for RAR archives and 7-Zip or bsdtar for ZIP archives (ZipCrypto and AES),
+// which archive/zip can't decrypt. The format is chosen by the extension of the archive.
+// The password is written to the standard input of the tool, it never appears on its command line.
+//
+// Parameters:
+//   - sourcePath: The absolute or relative path to the archive
+//   - destPath: The absolute or relative path where the contents will be extracted
+//   - password: The password of the archive
+//
+// Returns:
+//   - error: An *ArchiveError matching ErrArchiveWrongPassword if the password is wrong, a *ToolNotFoundError
+//     if no tool is installed, another error if the extraction failed, nil otherwise
+//
+// Example:
+//
+//	err := ufs.ExtractArchive("/downloads/invoices.zip", "/data/invoices")
+//	for errors.Is(err, ufs.ErrArchivePasswordRequired) || errors.Is(err, ufs.ErrArchiveWrongPassword) {
+//	    err = ufs.ExtractEncrypted("/downloads/invoices.zip", "/data/invoices", askPassword())
+//	}
+func (ufs *UFS) ExtractEncrypted(sourcePath, destPath, password string) (err error) {
+	defer ufs.recoverPanic("ExtractEncrypted", &err)
+
+	if err := ufs.requireOS("ExtractEncrypted"); err != nil {
+		return err
+	}
+
+	if password == "" {
+		return fmt.Errorf("ExtractEncrypted: a password is required")
+	}
+	switch strings.ToLower(filepath.Ext(sourcePath)) {
+	case ".7z":
+		return ufs.extractWithTools(sourcePath, destPath, password, "7z", sevenZipTools, "ExtractEncrypted")
+	case ".rar":
+		return ufs.extractWithTools(sourcePath, destPath, password, "RAR", rarTools, "ExtractEncrypted")
+	}
+	return ufs.extractWithTools(sourcePath, destPath, password, "ZIP", zipTools, "ExtractEncrypted")
+}
+
+// extractWithTools extracts an archive with the first installed tool of the list,
+// giving it the password unless it is empty
+func (ufs *UFS) extractWithTools(sourcePath, destPath, password, format string, tools []archiveTool, operation string) error {
+	// Verify source is a file
+	if !ufs.IsFile(sourcePath) {
+		return ufs.misuseError(operation, "source path is not a file", sourcePath)
+	}
+
+	if err := ufs.requireCapability(CapabilityProcesses, operation, sourcePath); err != nil {
+		return err
+	}
+
+	program, tool, err := findArchiveTool(format, tools, password != "")
+	if err != nil {
+		return ufs.wrapError(err, operation)
+	}
+
+	// Get absolute paths to ensure consistent behavior
+	sourcePath, err = filepath.Abs(sourcePath)
+	if err != nil {
+		return ufs.wrapError(err, operation)
+	}
+
+	destPath, err = filepath.Abs(destPath)
+	if err != nil {
+		return ufs.wrapError(err, operation)
+	}
+
+	// Ensure destination directory exists
+	if !ufs.IsDirectory(destPath) {
+		err = os.MkdirAll(destPath, 0755)
+		if err != nil {
+			return ufs.wrapError(err, operation)
+		}
+	}
+
+	// Stdin only holds the password, so tools asking for another one fail instead of waiting forever.
+	// Without a terminal, the tools read it from stdin.
+	cmd := exec.Command(program, tool.args(sourcePath, destPath)...)
+	if password != "" {
+		cmd.Stdin = strings.NewReader(password + "\n")
+		detachTerminal(cmd)
+	}
+	output, err := cmd.CombinedOutput()
+	if password != "" {
+		for _, prompt := range toolPasswordPrompts {
+			output = bytes.ReplaceAll(output, []byte(prompt), nil)
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("extraction with %s failed: %v, output: %s", tool.name, err, output)
+		return ufs.wrapError(classifyToolFailure(sourcePath, output, password, err), operation)
+	}
+
+	return nil
+}
+
+// findArchiveTool returns the path of the first installed tool of the list, reading passwords if needed
+func findArchiveTool(format string, tools []archiveTool, password bool) (string, archiveTool, error) {
+	names := make([]string, 0, len(tools))
+
+	for _, tool := range tools {
+		if password && !tool.readsPassword {
+			continue
+		}
+		names = append(names, tool.name)
+
+		if path, err := exec.LookPath(tool.name); err == nil {
+			return path, tool, nil
+		}
+		if path, ok := findDefaultArchiveTool(tool.name); ok {
+			return path, tool, nil
+		}
+	}
+
+	return "", archiveTool{}, &ToolNotFoundError{Format: format, Tools: names}
+}
+
+// findDefaultArchiveTool looks for a tool where it is installed by default but not under its name in the PATH:
+// bsdtar is the system tar on Windows (tar.exe), macOS and FreeBSD, and 7-Zip and WinRAR
+// are not added to the PATH on Windows.
+func findDefaultArchiveTool(name string) (string, bool) {
+	var candidates []string
+
+	switch {
+	case name == "bsdtar" && runtime.GOOS == "windows":
+		candidates = append(candidates, "tar.exe")
+	case name == "bsdtar" && (runtime.GOOS == "darwin" || runtime.GOOS == "freebsd"):
+		candidates = append(candidates, "tar")
+	case runtime.GOOS == "windows":
+		for _, programFiles := range []string{os.Getenv("ProgramFiles"), os.Getenv("ProgramFiles(x86)")} {
+			if programFiles == "" {
+				continue
+			}
+			switch name {
+			case "7z":
+				candidates = append(candidates, filepath.Join(programFiles, "7-Zip", "7z.exe"))
+			case "unrar":
+				candidates = append(candidates, filepath.Join(programFiles, "WinRAR", "UnRAR.exe"))
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		if path, err := exec.LookPath(candidate); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd && !dragonfly
+
+package ufs
+
+import "os/exec"
+
+// detachTerminal does nothing, the tools read the password from their standard input when it is redirected
+func detachTerminal(cmd *exec.Cmd) {}
@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package ufs
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachTerminal runs a tool in a new session, without a controlling terminal: the tools asking for
+// a password then read it from their standard input instead of the terminal of the program
+func detachTerminal(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
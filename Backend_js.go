@@ -0,0 +1,6 @@
+//go:build js
+
+package ufs
+
+// defaultBackend keeps the files in memory, browsers have no file system
+var defaultBackend Backend = dufs.NewMemoryBackend()
@@ -0,0 +1,6 @@
+//go:build !js
+
+package ufs
+
+// defaultBackend is the operating system
+var defaultBackend Backend = OSBackend
@@ -0,0 +1,403 @@
+package ufs
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+Backup-incremental.go contains functions to make incremental ZIP backups of a directory.
+
+A full backup archives every file. Each following backup only archives the files that changed
+since a previous one, which keeps daily backups of large directories small:
+
+	backup-monday.zip    (full)
+	backup-tuesday.zip   (files changed since monday)
+	backup-wednesday.zip (files changed since tuesday)
+
+Every archive embeds a manifest (also written next to it as <archive>.backup.json) listing the
+size, modification time and SHA-256 of every file at backup time, the files stored in the archive
+and the files deleted since the previous backup. A file is considered changed when its size or
+modification time differs and its content hash differs too, so touched but identical files are not archived again.
+
+RestoreIncremental extracts the full backup and then applies each increment in order,
+including deletions, to rebuild the directory as it was at the last backup.
+
+Functions:
+- CompressDirectoryIncremental: Archives the files changed since a previous backup manifest.
+- RestoreIncremental: Restores a directory from a full backup and its increments.
+*/
+
+// backupManifestVersion is the version of the manifest format written by CompressDirectoryIncremental
+const backupManifestVersion = 1
+
+// backupManifestEntry is the name of the manifest stored inside backup archives
+const backupManifestEntry = ".ufs-backup.json"
+
+// backupManifestSuffix is appended to the archive path to get the manifest written next to it
+const backupManifestSuffix = ".backup.json"
+
+// BackupManifest describes the state of a directory at the time of a backup.
+type BackupManifest struct {
+	Version int       `json:"version"`
+	ID      string    `json:"id"`               // Unique identifier of this backup
+	Parent  string    `json:"parent,omitempty"` // ID of the backup this one is based on, empty for a full backup
+	Archive string    `json:"archive"`          // File name of the archive
+	Created time.Time `json:"created"`
+
+	Files   map[string]BackupFileState `json:"files"`   // Every file of the directory, keyed by slash separated relative path
+	Changed []string                   `json:"changed"` // Files stored in this archive
+	Deleted []string                   `json:"deleted"` // Files removed since the parent backup
+}
+
+// BackupFileState is the state of a single file recorded in a BackupManifest.
+type BackupFileState struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	SHA256  string    `json:"sha256"`
+}
+
+// CompressDirectoryIncremental archives the files of a directory that changed since a previous backup.
+// With an empty sinceManifest every file is archived (full backup).
+// The manifest of the new backup is stored in the archive and written to <destPath>.backup.json,
+// it is the sinceManifest of the next backup.
+//
+// Parameters:
+//   - sourcePath: The absolute or relative path to the directory to back up
+//   - destPath: The absolute or relative path where the ZIP archive will be created
+//   - sinceManifest: The manifest of the previous backup (a .backup.json file or the previous archive), "" for a full backup
+//
+// Returns:
+//   - *BackupManifest: The manifest of the new backup
+//   - error: An error if the backup failed, nil otherwise
+//
+// Example:
+//
+//	// Full backup, then an increment
+//	ufs.CompressDirectoryIncremental("/data", "/backups/data-1.zip", "")
+//	manifest, err := ufs.CompressDirectoryIncremental("/data", "/backups/data-2.zip", "/backups/data-1.zip.backup.json")
+//	if err != nil {
+//	    fmt.Printf("Error backing up: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("%d files changed, %d deleted\n", len(manifest.Changed), len(manifest.Deleted))
+func (ufs *UFS) CompressDirectoryIncremental(sourcePath, destPath, sinceManifest string) (_ *BackupManifest, err error) {
+	defer ufs.recoverPanic("CompressDirectoryIncremental", &err)
+
+	if err := ufs.requireOS("CompressDirectoryIncremental"); err != nil {
+		return nil, err
+	}
+	defer ufs.applyIOPriority()()
+
+	// Verify source is a directory
+	if !ufs.IsDirectory(sourcePath) {
+		return nil, ufs.misuseError("CompressDirectoryIncremental", "source path is not a directory", sourcePath)
+	}
+
+	sourcePath, err = filepath.Abs(sourcePath)
+	if err != nil {
+		return nil, ufs.wrapError(err, "CompressDirectoryIncremental")
+	}
+	destPath, err = ufs.expandOutputPath(destPath)
+	if err != nil {
+		return nil, ufs.wrapError(err, "CompressDirectoryIncremental")
+	}
+	destPath, err = filepath.Abs(destPath)
+	if err != nil {
+		return nil, ufs.wrapError(err, "CompressDirectoryIncremental")
+	}
+	manifestPath := destPath + backupManifestSuffix
+
+	var previous *BackupManifest
+	if sinceManifest != "" {
+		previous, err = readBackupManifest(sinceManifest)
+		if err != nil {
+			return nil, ufs.wrapError(err, "CompressDirectoryIncremental")
+		}
+	}
+
+	files, err := ufs.collectFiles(sourcePath, "CompressDirectoryIncremental")
+	if err != nil {
+		return nil, ufs.wrapError(err, "CompressDirectoryIncremental")
+	}
+
+	id, err := newBackupID()
+	if err != nil {
+		return nil, ufs.wrapError(err, "CompressDirectoryIncremental")
+	}
+
+	manifest := &BackupManifest{
+		Version: backupManifestVersion,
+		ID:      id,
+		Archive: filepath.Base(destPath),
+		Created: time.Now().UTC(),
+		Files:   map[string]BackupFileState{},
+		Changed: []string{},
+		Deleted: []string{},
+	}
+	if previous != nil {
+		manifest.Parent = previous.ID
+	}
+
+	// Files left out of the archive: unchanged ones and the backup itself
+	excluded := map[string]bool{destPath: true, manifestPath: true}
+
+	for rel, info := range files {
+		path := filepath.Join(sourcePath, rel)
+		if excluded[path] {
+			continue
+		}
+
+		key := filepath.ToSlash(rel)
+		state := BackupFileState{Size: info.Size(), ModTime: info.ModTime().UTC()}
+		old, existed := previous.file(key)
+
+		// Same size and modification time: unchanged, reuse the recorded hash
+		if existed && old.Size == state.Size && old.ModTime.Equal(state.ModTime) {
+			state.SHA256 = old.SHA256
+			manifest.Files[key] = state
+			excluded[path] = true
+			continue
+		}
+
+		digest, err := ufs.fileDigest(path, info.Size(), DetectFullHash)
+		if err != nil {
+			return nil, ufs.wrapError(err, "CompressDirectoryIncremental")
+		}
+		state.SHA256 = hex.EncodeToString(digest)
+		manifest.Files[key] = state
+
+		// Touched but identical content
+		if existed && old.SHA256 == state.SHA256 {
+			excluded[path] = true
+			continue
+		}
+
+		manifest.Changed = append(manifest.Changed, key)
+	}
+
+	if previous != nil {
+		for key := range previous.Files {
+			if _, exists := manifest.Files[key]; !exists {
+				manifest.Deleted = append(manifest.Deleted, key)
+			}
+		}
+	}
+
+	sort.Strings(manifest.Changed)
+	sort.Strings(manifest.Deleted)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, ufs.wrapError(err, "CompressDirectoryIncremental")
+	}
+
+	if ufs.dryRun("CompressDirectoryIncremental", DryRunWrite, destPath, sourcePath) {
+		ufs.dryRun("CompressDirectoryIncremental", DryRunWrite, manifestPath, "")
+		return manifest, nil
+	}
+
+	// Ensure destination directory exists
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return nil, ufs.wrapError(err, "CompressDirectoryIncremental")
+	}
+
+	zipFile, err := os.Create(destPath)
+	if err != nil {
+		return nil, ufs.wrapError(err, "CompressDirectoryIncremental")
+	}
+
+	// Don't leave a broken backup behind
+	defer func() {
+		if err != nil {
+			os.Remove(destPath)
+			os.Remove(manifestPath)
+		}
+	}()
+
+	zipWriter := zip.NewWriter(zipFile)
+	err = ufs.writeDirectoryZip(context.Background(), zipWriter, sourcePath, func(path string) bool { return excluded[path] }, nil, "CompressDirectoryIncremental")
+	if err == nil {
+		err = writeZipEntry(zipWriter, backupManifestEntry, data)
+	}
+	if closeErr := zipWriter.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := zipFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, ufs.wrapError(err, "CompressDirectoryIncremental")
+	}
+
+	if err = os.WriteFile(manifestPath, data, 0644); err != nil {
+		return nil, ufs.wrapError(err, "CompressDirectoryIncremental")
+	}
+
+	return manifest, nil
+}
+
+// RestoreIncremental restores a directory from a full backup followed by its increments.
+// The archives must be given in order, each one based on the previous one; the chain is checked
+// before anything is extracted. Files deleted between backups are removed from the destination.
+//
+// Parameters:
+//   - archives: The full backup first, then the increments in the order they were made
+//   - destPath: The absolute or relative path of the directory to restore to
+//
+// Returns:
+//   - error: An error if the chain is broken or the extraction failed, nil otherwise
+//
+// Example:
+//
+//	err := ufs.RestoreIncremental([]string{
+//	    "/backups/data-1.zip",
+//	    "/backups/data-2.zip",
+//	    "/backups/data-3.zip",
+//	}, "/restore/data")
+//	if err != nil {
+//	    fmt.Printf("Error restoring backup: %v\n", err)
+//	}
+func (ufs *UFS) RestoreIncremental(archives []string, destPath string) (err error) {
+	defer ufs.recoverPanic("RestoreIncremental", &err)
+
+	if err := ufs.requireOS("RestoreIncremental"); err != nil {
+		return err
+	}
+	defer ufs.applyIOPriority()()
+
+	if len(archives) == 0 {
+		return fmt.Errorf("RestoreIncremental: no archive given")
+	}
+
+	// Check the whole chain first
+	manifests := make([]*BackupManifest, len(archives))
+	for i, archive := range archives {
+		manifest, err := readBackupManifest(archive)
+		if err != nil {
+			return ufs.wrapError(err, "RestoreIncremental")
+		}
+
+		if i == 0 && manifest.Parent != "" {
+			return fmt.Errorf("RestoreIncremental: %s is not a full backup", archive)
+		}
+		if i > 0 && manifest.Parent != manifests[i-1].ID {
+			return fmt.Errorf("RestoreIncremental: %s is not based on %s", archive, archives[i-1])
+		}
+		manifests[i] = manifest
+	}
+
+	// The embedded manifest is not part of the restored directory
+	skipManifest := &ExtractOptions{
+		Transform: func(name string, info os.FileInfo) (string, bool) {
+			return name, name == backupManifestEntry
+		},
+	}
+
+	for i, archive := range archives {
+		if _, err := ufs.extractArchive(context.Background(), archive, destPath, skipManifest, "RestoreIncremental"); err != nil {
+			return err
+		}
+
+		for _, key := range manifests[i].Deleted {
+			if !filepath.IsLocal(filepath.FromSlash(key)) {
+				return fmt.Errorf("RestoreIncremental: illegal deleted path in %s: %s", archive, key)
+			}
+			err := os.Remove(filepath.Join(destPath, filepath.FromSlash(key)))
+			if err != nil && !os.IsNotExist(err) {
+				return ufs.wrapError(err, "RestoreIncremental")
+			}
+		}
+	}
+
+	return nil
+}
+
+// file returns the recorded state of a file, a nil manifest records nothing
+func (manifest *BackupManifest) file(key string) (BackupFileState, bool) {
+	if manifest == nil {
+		return BackupFileState{}, false
+	}
+	state, exists := manifest.Files[key]
+	return state, exists
+}
+
+// readBackupManifest reads a backup manifest from a .backup.json file or from the backup archive itself
+func readBackupManifest(path string) (*BackupManifest, error) {
+	var data []byte
+	var err error
+
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		data, err = readZipEntry(path, backupManifestEntry)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest BackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid backup manifest %s: %w", path, err)
+	}
+	if manifest.Version != backupManifestVersion {
+		return nil, fmt.Errorf("unsupported backup manifest version %d: %s", manifest.Version, path)
+	}
+
+	return &manifest, nil
+}
+
+// newBackupID returns a random identifier for a backup
+func newBackupID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// writeZipEntry adds a file with the given content to a zip archive
+func writeZipEntry(zipWriter *zip.Writer, name string, data []byte) error {
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()}
+	header.SetMode(0644)
+
+	writer, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
+
+// readZipEntry returns the content of a single entry of a zip archive
+func readZipEntry(zipPath, name string) ([]byte, error) {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		if file.Name != name {
+			continue
+		}
+		entry, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer entry.Close()
+		return io.ReadAll(entry)
+	}
+
+	return nil, fmt.Errorf("%s not found in %s", name, zipPath)
+}
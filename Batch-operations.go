@@ -0,0 +1,179 @@
+package ufs
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+/*
+Batch-operations.go contains plural variants of MoveFile, CopyFile and DeleteFile for bulk scripts.
+
+Each function runs the operations with a bounded number of workers and returns one BatchResult per
+item, in the order of the input, instead of stopping or returning a single bool:
+
+	results := ufs.MoveFiles(ufs.FilePairsFromMap(renames), 4)
+	for _, result := range results {
+	    if result.Err != nil {
+	        fmt.Println(result.Err)
+	    }
+	}
+
+The items are independent: a failed item doesn't stop the others. Items sharing a destination
+should not be in the same batch, their order is not guaranteed. Every item behaves like the single
+file function, Options.Overwrite and Options.DryRun included. BatchResult.Err wraps the reason an item
+failed, e.g. a *MisuseError when the source isn't a file, or the error of the OS (errors.Is works with
+os.ErrNotExist, os.ErrExist, ...); the reasons are also passed to Options.ErrorSink or Options.WarningsSink,
+and logged as usual.
+
+Functions:
+- MoveFiles: Moves files concurrently
+- CopyFiles: Copies files concurrently
+- DeleteFiles: Deletes files concurrently
+- FilePairsFromMap: Converts a source -> destination map to pairs sorted by source
+*/
+
+// FilePair is the source and destination of a file in MoveFiles and CopyFiles.
+type FilePair struct {
+	Src string
+	Dst string
+}
+
+// BatchResult is the outcome of a single item of a batch operation.
+type BatchResult struct {
+	Src string
+	Dst string // "" for DeleteFiles
+	Err error  // nil when the item succeeded
+}
+
+// MoveFiles moves files like MoveFile, workers at a time.
+//
+// Parameters:
+//   - pairs: The files to move with their destinations
+//   - workers: The number of files moved at the same time, 0 uses runtime.NumCPU()
+//
+// Returns:
+//   - []BatchResult: The result of every pair, in the order of pairs
+//
+// Example:
+//
+//	results := ufs.MoveFiles([]ufs.FilePair{
+//	    {Src: "inbox/a.pdf", Dst: "archive/a.pdf"},
+//	    {Src: "inbox/b.pdf", Dst: "archive/b.pdf"},
+//	}, 0)
+//	for _, result := range results {
+//	    if result.Err != nil {
+//	        fmt.Printf("Error moving %s: %v\n", result.Src, result.Err)
+//	    }
+//	}
+func (ufs *UFS) MoveFiles(pairs []FilePair, workers int) []BatchResult {
+	defer ufs.recoverPanicReport("MoveFiles")
+
+	return ufs.runPairs("MoveFiles", pairs, workers, func(pair FilePair) error {
+		err := ufs.tryMoveFile(pair.Src, pair.Dst)
+		if !ufs.reportFailure(err, "MoveFile") {
+			return fmt.Errorf("MoveFiles: could not move %s to %s: %w", pair.Src, pair.Dst, err)
+		}
+		return nil
+	})
+}
+
+// CopyFiles copies files like CopyFile, workers at a time.
+//
+// Parameters:
+//   - pairs: The files to copy with their destinations
+//   - workers: The number of files copied at the same time, 0 uses runtime.NumCPU()
+//
+// Returns:
+//   - []BatchResult: The result of every pair, in the order of pairs
+//
+// Example:
+//
+//	results := ufs.CopyFiles(ufs.FilePairsFromMap(map[string]string{
+//	    "templates/invoice.docx": "clients/acme/invoice.docx",
+//	    "templates/quote.docx":   "clients/acme/quote.docx",
+//	}), 2)
+func (ufs *UFS) CopyFiles(pairs []FilePair, workers int) []BatchResult {
+	defer ufs.recoverPanicReport("CopyFiles")
+
+	return ufs.runPairs("CopyFiles", pairs, workers, func(pair FilePair) error {
+		return ufs.CopyFile(pair.Src, pair.Dst)
+	})
+}
+
+// DeleteFiles deletes files like DeleteFile, workers at a time.
+//
+// Parameters:
+//   - paths: The files to delete
+//   - workers: The number of files deleted at the same time, 0 uses runtime.NumCPU()
+//
+// Returns:
+//   - []BatchResult: The result of every path, in the order of paths, with an empty Dst
+//
+// Example:
+//
+//	results := ufs.DeleteFiles([]string{"tmp/a.log", "tmp/b.log"}, 0)
+func (ufs *UFS) DeleteFiles(paths []string, workers int) []BatchResult {
+	defer ufs.recoverPanicReport("DeleteFiles")
+
+	pairs := make([]FilePair, len(paths))
+	for i, path := range paths {
+		pairs[i] = FilePair{Src: path}
+	}
+	return ufs.runPairs("DeleteFiles", pairs, workers, func(pair FilePair) error {
+		err := ufs.tryRemoveFile(pair.Src)
+		if !ufs.reportFailure(err, "RemoveFile") {
+			return fmt.Errorf("DeleteFiles: could not delete %s: %w", pair.Src, err)
+		}
+		return nil
+	})
+}
+
+// FilePairsFromMap converts a map from sources to destinations to pairs, sorted by source so the
+// batch runs in a predictable order.
+//
+// Parameters:
+//   - files: The destinations by source
+//
+// Returns:
+//   - []FilePair: The pairs, sorted by source
+//
+// Example:
+//
+//	pairs := ufs.FilePairsFromMap(map[string]string{"a.txt": "out/a.txt"})
+func FilePairsFromMap(files map[string]string) []FilePair {
+	pairs := make([]FilePair, 0, len(files))
+	for src, dst := range files {
+		pairs = append(pairs, FilePair{Src: src, Dst: dst})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Src < pairs[j].Src })
+	return pairs
+}
+
+// runPairs runs op on every pair with a pool of workers and collects the results in order
+func (ufs *UFS) runPairs(operation string, pairs []FilePair, workers int, op func(pair FilePair) error) []BatchResult {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([]BatchResult, len(pairs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(pairs)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = BatchResult{Src: pairs[i].Src, Dst: pairs[i].Dst, Err: ufs.recoverWorker(operation, func() error { return op(pairs[i]) })}
+			}
+		}()
+	}
+	for i := range pairs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,308 @@
+package ufs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Cache-dir.go contains CacheDir, a ready-made disk cache with a size limit.
+
+Entries are stored as files named after the SHA-256 of their key. Every Put, Get and Touch
+updates the modification time of the entry, which is used as its last use time: when the
+total size goes over the limit, the least recently used entries are deleted first.
+Modification times are used rather than access times, which most systems don't update (noatime, relatime).
+
+A CacheDir is safe for concurrent use by multiple goroutines. Several processes sharing the same
+directory work, but each keeps its own view of the total size until it is opened again.
+
+Functions:
+- OpenCacheDir: Opens (or creates) a cache directory with a size limit.
+- CacheDir.Put: Stores an entry, evicting old entries if needed.
+- CacheDir.Get: Reads an entry and marks it as recently used.
+- CacheDir.Touch: Marks an entry as recently used.
+- CacheDir.Remove: Deletes an entry.
+- CacheDir.Size: Returns the total size of the entries.
+*/
+
+// cacheTempPrefix prefixes the files being written, they are not entries
+const cacheTempPrefix = ".tmp-"
+
+// CacheDir is a directory of cached entries with a total size limit and LRU eviction.
+type CacheDir struct {
+	ufs      *UFS
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	size    int64
+	entries map[string]*cacheEntry // file name -> entry
+}
+
+// cacheEntry is the size and last use time of a cached file
+type cacheEntry struct {
+	size    int64
+	lastUse time.Time
+}
+
+// OpenCacheDir opens a cache directory, creating it if needed. Entries already in the directory are kept,
+// and evicted right away if they exceed maxBytes.
+//
+// Parameters:
+//   - dir: The absolute or relative path to the cache directory
+//   - maxBytes: The maximum total size of the entries, in bytes
+//
+// Returns:
+//   - *CacheDir: The opened cache
+//   - error: An error if the directory couldn't be created or read, nil otherwise
+//
+// Example:
+//
+//	cache, err := ufs.OpenCacheDir(filepath.Join(os.TempDir(), "myapp-thumbnails"), 500<<20)
+//	if err != nil {
+//	    fmt.Printf("Error opening cache: %v\n", err)
+//	    return
+//	}
+//	thumbnail, ok := cache.Get(imagePath)
+//	if !ok {
+//	    thumbnail = render(imagePath)
+//	    cache.Put(imagePath, thumbnail)
+//	}
+func (ufs *UFS) OpenCacheDir(dir string, maxBytes int64) (_ *CacheDir, err error) {
+	defer ufs.recoverPanic("OpenCacheDir", &err)
+
+	if err := ufs.requireOS("OpenCacheDir"); err != nil {
+		return nil, err
+	}
+
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("OpenCacheDir: invalid maximum size %d, expected more than 0", maxBytes)
+	}
+
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, ufs.wrapError(err, "OpenCacheDir")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, ufs.wrapError(err, "OpenCacheDir")
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, ufs.wrapError(err, "OpenCacheDir")
+	}
+
+	cache := &CacheDir{ufs: ufs, dir: dir, maxBytes: maxBytes, entries: map[string]*cacheEntry{}}
+	for _, file := range files {
+		// Leftovers of interrupted writes
+		if strings.HasPrefix(file.Name(), cacheTempPrefix) {
+			os.Remove(filepath.Join(dir, file.Name()))
+			continue
+		}
+		if !file.Type().IsRegular() {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			continue // Removed in the meantime
+		}
+		cache.entries[file.Name()] = &cacheEntry{size: info.Size(), lastUse: info.ModTime()}
+		cache.size += info.Size()
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if err := cache.evict(); err != nil {
+		return nil, ufs.wrapError(err, "OpenCacheDir")
+	}
+	return cache, nil
+}
+
+// Put stores an entry, replacing any previous value of the key.
+// The least recently used entries are deleted when the cache goes over its size limit.
+//
+// Parameters:
+//   - key: The key of the entry, any string
+//   - data: The content of the entry
+//
+// Returns:
+//   - error: An error if the entry couldn't be written or is larger than the cache, nil otherwise
+func (c *CacheDir) Put(key string, data []byte) (err error) {
+	defer c.ufs.recoverPanic("CacheDir.Put", &err)
+
+	if int64(len(data)) > c.maxBytes {
+		return fmt.Errorf("CacheDir.Put: entry of %d bytes is larger than the cache (%d bytes)", len(data), c.maxBytes)
+	}
+
+	name := cacheFileName(key)
+
+	// Write aside and rename, so readers never see a partial entry
+	temp, err := os.CreateTemp(c.dir, cacheTempPrefix)
+	if err != nil {
+		return c.ufs.wrapError(err, "CacheDir.Put")
+	}
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), filepath.Join(c.dir, name))
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return c.ufs.wrapError(err, "CacheDir.Put")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if old, ok := c.entries[name]; ok {
+		c.size -= old.size
+	}
+	c.entries[name] = &cacheEntry{size: int64(len(data)), lastUse: time.Now()}
+	c.size += int64(len(data))
+
+	if err := c.evict(); err != nil {
+		return c.ufs.wrapError(err, "CacheDir.Put")
+	}
+	return nil
+}
+
+// Get reads an entry and marks it as recently used.
+//
+// Parameters:
+//   - key: The key of the entry
+//
+// Returns:
+//   - []byte: The content of the entry, nil when missing
+//   - bool: true if the entry was found, false otherwise
+func (c *CacheDir) Get(key string) (_ []byte, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+			c.ufs.handleError(&PanicError{Op: "CacheDir.Get", Value: r}, "CacheDir.Get")
+		}
+	}()
+
+	name := cacheFileName(key)
+	data, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			c.ufs.handleError(err, "CacheDir.Get")
+		}
+		return nil, false
+	}
+
+	c.touch(name)
+	return data, true
+}
+
+// Touch marks an entry as recently used, so it is evicted last.
+//
+// Parameters:
+//   - key: The key of the entry
+//
+// Returns:
+//   - bool: true if the entry exists, false otherwise
+func (c *CacheDir) Touch(key string) (ok bool) {
+	defer c.ufs.recoverPanicBool("CacheDir.Touch", &ok)
+
+	return c.touch(cacheFileName(key))
+}
+
+// Remove deletes an entry. Removing a missing entry is not an error.
+//
+// Parameters:
+//   - key: The key of the entry
+//
+// Returns:
+//   - error: An error if the entry couldn't be deleted, nil otherwise
+func (c *CacheDir) Remove(key string) (err error) {
+	defer c.ufs.recoverPanic("CacheDir.Remove", &err)
+
+	name := cacheFileName(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.Remove(filepath.Join(c.dir, name)); err != nil && !os.IsNotExist(err) {
+		return c.ufs.wrapError(err, "CacheDir.Remove")
+	}
+	if entry, ok := c.entries[name]; ok {
+		c.size -= entry.size
+		delete(c.entries, name)
+	}
+	return nil
+}
+
+// Size returns the total size of the entries, in bytes
+func (c *CacheDir) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// touch updates the last use time of an entry, on disk and in memory
+func (c *CacheDir) touch(name string) bool {
+	now := time.Now()
+	if err := os.Chtimes(filepath.Join(c.dir, name), now, now); err != nil {
+		if !os.IsNotExist(err) {
+			c.ufs.handleError(err, "CacheDir.Touch")
+		}
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[name]; ok {
+		entry.lastUse = now
+	} else if info, err := os.Stat(filepath.Join(c.dir, name)); err == nil {
+		// Added by another process sharing the directory
+		c.entries[name] = &cacheEntry{size: info.Size(), lastUse: now}
+		c.size += info.Size()
+	}
+	return true
+}
+
+// evict deletes the least recently used entries until the cache fits its size limit.
+// c.mu must be held.
+func (c *CacheDir) evict() error {
+	if c.size <= c.maxBytes {
+		return nil
+	}
+
+	names := make([]string, 0, len(c.entries))
+	for name := range c.entries {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return c.entries[names[i]].lastUse.Before(c.entries[names[j]].lastUse)
+	})
+
+	for _, name := range names {
+		if c.size <= c.maxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		c.size -= c.entries[name].size
+		delete(c.entries, name)
+	}
+	return nil
+}
+
+// cacheFileName returns the file name of the entry of a key
+func cacheFileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,735 @@
+package ufs
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+Checksums.go contains functions to publish and verify file checksums.
+
+Checksum sidecars are the usual way to distribute artifacts: next to release.tar.gz sits
+release.tar.gz.sha256, holding the checksum in the format of the sha256sum tool:
+
+	9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08  release.tar.gz
+
+which can also be verified with "sha256sum -c release.tar.gz.sha256".
+
+For backups, HashDirectory writes a single manifest for a whole tree in the same format, with
+paths relative to the tree, and VerifyChecksumManifest checks the tree against it later.
+HashDirectoryParallel hashes large datasets with several workers, biggest files first, and also returns
+a single digest of the whole tree, which changes when any file is added, removed, renamed or modified.
+
+CRC-32 and xxHash (XXH64) are much faster than the cryptographic hashes and are enough to detect
+accidental corruption, but not tampering.
+
+Functions:
+- HashFile: Returns the checksum of a file.
+- HashDirectory: Checksums every file of a tree and writes a manifest file.
+- HashDirectoryParallel: Checksums every file of a tree with several workers, and combines the checksums into one.
+- VerifyChecksumManifest: Verifies the files of a tree against a manifest file.
+- WriteChecksumSidecars: Writes a checksum sidecar file next to every file of a tree.
+- VerifyChecksumSidecars: Verifies the files of a tree against their checksum sidecar files.
+*/
+
+// HashAlgorithm is a checksum algorithm. Its value is also the extension of its sidecar files.
+type HashAlgorithm string
+
+const (
+	// HashSHA256 is SHA-256, the recommended algorithm
+	HashSHA256 HashAlgorithm = "sha256"
+	// HashSHA512 is SHA-512
+	HashSHA512 HashAlgorithm = "sha512"
+	// HashSHA1 is SHA-1, only for compatibility with existing checksums
+	HashSHA1 HashAlgorithm = "sha1"
+	// HashMD5 is MD5, only for compatibility with existing checksums
+	HashMD5 HashAlgorithm = "md5"
+	// HashCRC32 is CRC-32 (IEEE), fast but only detects accidental corruption
+	HashCRC32 HashAlgorithm = "crc32"
+	// HashXXH64 is the 64-bit xxHash, very fast but only detects accidental corruption
+	HashXXH64 HashAlgorithm = "xxh64"
+)
+
+// hashAlgorithms lists the supported algorithms
+var hashAlgorithms = []HashAlgorithm{HashSHA256, HashSHA512, HashSHA1, HashMD5, HashCRC32, HashXXH64}
+
+// newHash returns a new hash of the algorithm
+func (algo HashAlgorithm) newHash() (hash.Hash, error) {
+	switch algo {
+	case HashSHA256:
+		return sha256.New(), nil
+	case HashSHA512:
+		return sha512.New(), nil
+	case HashSHA1:
+		return sha1.New(), nil
+	case HashMD5:
+		return md5.New(), nil
+	case HashCRC32:
+		return crc32.NewIEEE(), nil
+	case HashXXH64:
+		return newXXH64(), nil
+	}
+	return nil, fmt.Errorf("unsupported hash algorithm %q", string(algo))
+}
+
+// sidecarAlgorithm returns the algorithm of a sidecar file, given its path
+func sidecarAlgorithm(path string) (HashAlgorithm, bool) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	for _, algo := range hashAlgorithms {
+		if ext == string(algo) {
+			return algo, true
+		}
+	}
+	return "", false
+}
+
+// algorithmForSum guesses the algorithm of a hex encoded checksum from its length
+func algorithmForSum(sum string) (HashAlgorithm, bool) {
+	switch len(sum) {
+	case 8:
+		return HashCRC32, true
+	case 16:
+		return HashXXH64, true
+	case 32:
+		return HashMD5, true
+	case 40:
+		return HashSHA1, true
+	case 64:
+		return HashSHA256, true
+	case 128:
+		return HashSHA512, true
+	}
+	return "", false
+}
+
+// ChecksumMismatch is a file that failed the verification of its checksum.
+type ChecksumMismatch struct {
+	Path     string // Path of the verified file
+	Sidecar  string // Path of the sidecar or manifest file holding the expected checksum
+	Expected string // Expected checksum, hex encoded
+	Actual   string // Actual checksum, hex encoded, "" when the file couldn't be read
+	Err      error  // Why the file couldn't be verified (missing file, ...), nil for a checksum mismatch
+}
+
+// HashFile returns the checksum of a file, hex encoded as printed by the sha256sum or md5sum tools.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file
+//   - algo: The checksum algorithm (HashSHA256, HashSHA512, HashSHA1, HashMD5, HashCRC32 or HashXXH64)
+//
+// Returns:
+//   - string: The hex encoded checksum
+//   - error: An error if the algorithm is unsupported or the file couldn't be read, nil otherwise
+//
+// Example:
+//
+//	sum, err := ufs.HashFile("backup.tar.gz", ufs.HashSHA256)
+//	if err != nil {
+//	    fmt.Printf("Error hashing file: %v\n", err)
+//	    return
+//	}
+//	fmt.Println(sum)
+func (ufs *UFS) HashFile(path string, algo HashAlgorithm) (_ string, err error) {
+	defer ufs.recoverPanic("HashFile", &err)
+	defer ufs.applyIOPriority()()
+
+	if !ufs.IsFile(path) {
+		return "", ufs.misuseError("HashFile", "path is not a file", path)
+	}
+
+	sum, err := ufs.fileChecksum(path, algo)
+	if err != nil {
+		return "", ufs.wrapError(err, "HashFile")
+	}
+	return sum, nil
+}
+
+// HashDirectory checksums every file of a tree and writes a manifest file listing them, in the format
+// of the sha256sum tool, with slash separated paths relative to dir sorted by path.
+// The manifest can be verified later with VerifyChecksumManifest, or with "sha256sum -c" run from dir.
+// The manifest file itself is not listed when it is inside dir. Symbolic links are not followed.
+//
+// Parameters:
+//   - dir: The absolute or relative path to the directory to checksum
+//   - algo: The checksum algorithm
+//   - manifestPath: The absolute or relative path to the manifest file to write
+//
+// Returns:
+//   - map[string]string: The hex encoded checksums, by slash separated path relative to dir
+//   - error: An error if a file couldn't be read or the manifest couldn't be written, nil otherwise
+//
+// Example:
+//
+//	sums, err := ufs.HashDirectory("/backups/2024-05-01", ufs.HashSHA256, "/backups/2024-05-01.sha256")
+//	if err != nil {
+//	    fmt.Printf("Error hashing backup: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("%d files checksummed\n", len(sums))
+func (ufs *UFS) HashDirectory(dir string, algo HashAlgorithm, manifestPath string) (_ map[string]string, err error) {
+	defer ufs.recoverPanic("HashDirectory", &err)
+	defer ufs.applyIOPriority()()
+
+	if _, err := algo.newHash(); err != nil {
+		return nil, ufs.wrapError(err, "HashDirectory")
+	}
+	if !ufs.IsDirectory(dir) {
+		return nil, ufs.misuseError("HashDirectory", "path is not a directory", dir)
+	}
+	manifestAbs, err := filepath.Abs(manifestPath)
+	if err != nil {
+		return nil, ufs.wrapError(err, "HashDirectory")
+	}
+
+	sums := map[string]string{}
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkAbort, "HashDirectory")
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if abs, err := filepath.Abs(path); err == nil && abs == manifestAbs {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		sum, err := ufs.fileChecksum(path, algo)
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkAbort, "HashDirectory")
+		}
+		sums[filepath.ToSlash(rel)] = sum
+		return nil
+	})
+	if err != nil {
+		return nil, ufs.wrapError(err, "HashDirectory")
+	}
+
+	if err := ufs.WriteFileAtomic(manifestPath, []byte(checksumManifest(sums))); err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
+
+// VerifyChecksumManifest verifies the files of a tree against a manifest file in the format of the
+// sha256sum tool, such as written by HashDirectory. The algorithm is found from the extension of the
+// manifest (.sha256, .md5, ...) or else from the length of the checksums.
+// Files of the tree missing from the manifest are not reported.
+//
+// Parameters:
+//   - dir: The absolute or relative path to the directory the manifest paths are relative to
+//   - manifestPath: The absolute or relative path to the manifest file
+//
+// Returns:
+//   - []ChecksumMismatch: The files whose checksum doesn't match or that couldn't be verified, empty if all match
+//   - error: An error if the manifest couldn't be read or its algorithm is unknown, nil otherwise
+//
+// Example:
+//
+//	mismatches, err := ufs.VerifyChecksumManifest("/backups/2024-05-01", "/backups/2024-05-01.sha256")
+//	if err != nil {
+//	    fmt.Printf("Error verifying backup: %v\n", err)
+//	    return
+//	}
+//	if len(mismatches) > 0 {
+//	    fmt.Printf("%d files are corrupt or missing\n", len(mismatches))
+//	}
+func (ufs *UFS) VerifyChecksumManifest(dir, manifestPath string) (_ []ChecksumMismatch, err error) {
+	defer ufs.recoverPanic("VerifyChecksumManifest", &err)
+	defer ufs.applyIOPriority()()
+
+	if !ufs.IsDirectory(dir) {
+		return nil, ufs.misuseError("VerifyChecksumManifest", "path is not a directory", dir)
+	}
+
+	entries, err := ufs.readChecksumList(manifestPath)
+	if err != nil {
+		return nil, ufs.wrapError(err, "VerifyChecksumManifest")
+	}
+
+	algo, ok := sidecarAlgorithm(manifestPath)
+	if !ok && len(entries) > 0 {
+		algo, ok = algorithmForSum(entries[0].sum)
+	}
+	if !ok && len(entries) > 0 {
+		return nil, fmt.Errorf("VerifyChecksumManifest: unknown checksum algorithm in %s", manifestPath)
+	}
+
+	return ufs.verifyChecksumEntries(dir, manifestPath, algo, entries), nil
+}
+
+// HashProgress reports the progress of HashDirectoryParallel.
+type HashProgress struct {
+	Path       string // Path of the file just hashed
+	FilesDone  int    // Files hashed so far
+	FilesTotal int    // Files to hash
+	BytesDone  int64  // Bytes hashed so far
+	BytesTotal int64  // Bytes to hash
+}
+
+// DirectoryHash is the result of HashDirectoryParallel.
+type DirectoryHash struct {
+	// Digest is the hex encoded checksum of the manifest of the tree (the content HashDirectory would
+	// write), so it can be reproduced with e.g. "sha256sum" on that manifest
+	Digest string
+
+	// Files holds the hex encoded checksums, by slash separated path relative to the root
+	Files map[string]string
+}
+
+// HashDirectoryParallel checksums every file of a tree with several workers, for datasets too large to
+// hash file by file. Files are hashed biggest first so the workers finish together.
+// The combined digest doesn't depend on the number of workers or the order files were hashed in.
+// Symbolic links are not followed.
+//
+// Parameters:
+//   - root: The absolute or relative path to the directory to checksum
+//   - algo: The checksum algorithm
+//   - workers: The number of files hashed at the same time, 0 uses runtime.NumCPU()
+//   - progress: Called after every file, one call at a time, nil disables progress reports
+//
+// Returns:
+//   - *DirectoryHash: The combined digest of the tree and the checksum of every file
+//   - error: An error if a file couldn't be read, nil otherwise
+//
+// Example:
+//
+//	result, err := ufs.HashDirectoryParallel("/datasets/imagenet", ufs.HashXXH64, 16, func(p ufs.HashProgress) {
+//	    fmt.Printf("\r%d/%d files, %d%%", p.FilesDone, p.FilesTotal, p.BytesDone*100/max(p.BytesTotal, 1))
+//	})
+//	if err != nil {
+//	    fmt.Printf("Error hashing dataset: %v\n", err)
+//	    return
+//	}
+//	fmt.Println("\ndataset digest:", result.Digest)
+func (ufs *UFS) HashDirectoryParallel(root string, algo HashAlgorithm, workers int, progress func(HashProgress)) (_ *DirectoryHash, err error) {
+	defer ufs.recoverPanic("HashDirectoryParallel", &err)
+
+	if _, err := algo.newHash(); err != nil {
+		return nil, ufs.wrapError(err, "HashDirectoryParallel")
+	}
+	if !ufs.IsDirectory(root) {
+		return nil, ufs.misuseError("HashDirectoryParallel", "path is not a directory", root)
+	}
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	type hashJob struct {
+		path, rel string
+		size      int64
+	}
+	var jobs []hashJob
+	var total HashProgress
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkAbort, "HashDirectoryParallel")
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, hashJob{path: path, rel: filepath.ToSlash(rel), size: info.Size()})
+		total.BytesTotal += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, ufs.wrapError(err, "HashDirectoryParallel")
+	}
+	total.FilesTotal = len(jobs)
+	sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].size > jobs[j].size })
+
+	result := &DirectoryHash{Files: make(map[string]string, len(jobs))}
+	queue := make(chan hashJob)
+	var mu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer ufs.applyIOPriority()()
+
+			for job := range queue {
+				var sum string
+				err := ufs.recoverWorker("HashDirectoryParallel", func() (err error) {
+					sum, err = ufs.fileChecksum(job.path, algo)
+					return err
+				})
+
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = ufs.decideWalkError(job.path, err, WalkAbort, "HashDirectoryParallel")
+				}
+				if err == nil {
+					result.Files[job.rel] = sum
+				}
+				total.FilesDone++
+				total.BytesDone += job.size
+				if progress != nil {
+					report := total
+					report.Path = job.path
+					progress(report)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, ufs.wrapError(firstErr, "HashDirectoryParallel")
+	}
+
+	hasher, _ := algo.newHash()
+	io.WriteString(hasher, checksumManifest(result.Files))
+	result.Digest = hex.EncodeToString(hasher.Sum(nil))
+	return result, nil
+}
+
+// WriteChecksumSidecars writes a sidecar file holding the checksum of every file of a tree, next to it:
+// file.zip gets file.zip.sha256 with HashSHA256. Existing sidecar files are not checksummed themselves,
+// and sidecars whose content didn't change are not rewritten. root can also be a single file.
+//
+// Parameters:
+//   - root: The absolute or relative path to the directory (or file) to checksum
+//   - algo: The checksum algorithm, which is also the extension of the sidecar files
+//
+// Returns:
+//   - int: The number of files checksummed
+//   - error: An error if a file couldn't be read or a sidecar couldn't be written, nil otherwise
+//
+// Example:
+//
+//	count, err := ufs.WriteChecksumSidecars("./dist", ufs.HashSHA256)
+//	if err != nil {
+//	    fmt.Printf("Error writing checksums: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("%d artifacts checksummed\n", count)
+func (ufs *UFS) WriteChecksumSidecars(root string, algo HashAlgorithm) (_ int, err error) {
+	defer ufs.recoverPanic("WriteChecksumSidecars", &err)
+	defer ufs.applyIOPriority()()
+
+	if _, err := algo.newHash(); err != nil {
+		return 0, ufs.wrapError(err, "WriteChecksumSidecars")
+	}
+	if !ufs.PathExists(root) {
+		return 0, fmt.Errorf("path does not exist: %s", root)
+	}
+
+	count := 0
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkAbort, "WriteChecksumSidecars")
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if _, ok := sidecarAlgorithm(path); ok {
+			return nil
+		}
+
+		sum, err := ufs.fileChecksum(path, algo)
+		if err != nil {
+			return err
+		}
+		line := sum + "  " + filepath.Base(path) + "\n"
+		if _, err := ufs.WriteFileIfChanged(path+"."+string(algo), []byte(line)); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, ufs.wrapError(err, "WriteChecksumSidecars")
+	}
+	return count, nil
+}
+
+// VerifyChecksumSidecars verifies the files of a tree against the checksum sidecar files found in it
+// (.sha256, .sha512, .sha1 and .md5 files in the format of the sha256sum tool).
+// A sidecar may list several files, relative to its directory.
+//
+// Parameters:
+//   - root: The absolute or relative path to the directory to verify
+//
+// Returns:
+//   - []ChecksumMismatch: The files whose checksum doesn't match or that couldn't be verified, empty if all match
+//   - error: An error if the tree or a sidecar couldn't be read, nil otherwise
+//
+// Example:
+//
+//	mismatches, err := ufs.VerifyChecksumSidecars("/downloads/release-1.2")
+//	if err != nil {
+//	    fmt.Printf("Error verifying checksums: %v\n", err)
+//	    return
+//	}
+//	for _, m := range mismatches {
+//	    if m.Err != nil {
+//	        fmt.Printf("%s: %v\n", m.Path, m.Err)
+//	    } else {
+//	        fmt.Printf("%s: checksum mismatch\n", m.Path)
+//	    }
+//	}
+func (ufs *UFS) VerifyChecksumSidecars(root string) (_ []ChecksumMismatch, err error) {
+	defer ufs.recoverPanic("VerifyChecksumSidecars", &err)
+	defer ufs.applyIOPriority()()
+
+	if !ufs.IsDirectory(root) {
+		return nil, ufs.misuseError("VerifyChecksumSidecars", "path is not a directory", root)
+	}
+
+	mismatches := []ChecksumMismatch{}
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkAbort, "VerifyChecksumSidecars")
+		}
+		algo, ok := sidecarAlgorithm(path)
+		if !ok || !info.Mode().IsRegular() {
+			return nil
+		}
+
+		entries, err := ufs.readChecksumList(path)
+		if err != nil {
+			return err
+		}
+		mismatches = append(mismatches, ufs.verifyChecksumEntries(filepath.Dir(path), path, algo, entries)...)
+		return nil
+	})
+	if err != nil {
+		return mismatches, ufs.wrapError(err, "VerifyChecksumSidecars")
+	}
+	return mismatches, nil
+}
+
+// verifyChecksumEntries checksums the files of a checksum list, relative to dir, and returns the mismatches
+func (ufs *UFS) verifyChecksumEntries(dir, list string, algo HashAlgorithm, entries []checksumEntry) []ChecksumMismatch {
+	mismatches := []ChecksumMismatch{}
+	for _, entry := range entries {
+		target := filepath.Join(dir, filepath.FromSlash(entry.name))
+		mismatch := ChecksumMismatch{Path: target, Sidecar: list, Expected: entry.sum}
+
+		mismatch.Actual, mismatch.Err = ufs.fileChecksum(target, algo)
+		if mismatch.Err != nil || !strings.EqualFold(mismatch.Actual, entry.sum) {
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+	return mismatches
+}
+
+// checksumManifest returns a checksum list in the format of the sha256sum tool, sorted by name
+func checksumManifest(sums map[string]string) string {
+	var manifest strings.Builder
+	for _, name := range sortedKeys(sums) {
+		manifest.WriteString(sums[name] + "  " + name + "\n")
+	}
+	return manifest.String()
+}
+
+// checksumEntry is a line of a checksum list: a hex encoded checksum and a slash separated file name
+type checksumEntry struct {
+	sum  string
+	name string
+}
+
+// readChecksumList reads a checksum list in the format of the sha256sum tool:
+// "<checksum>  <name>" lines, or "<checksum> *<name>" for files checksummed in binary mode
+func (ufs *UFS) readChecksumList(path string) ([]checksumEntry, error) {
+	var entries []checksumEntry
+	err := ufs.IterateLines(path, func(lineNo int, line string) (bool, error) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			return false, nil
+		}
+		sum, name, ok := strings.Cut(line, " ")
+		name = strings.TrimPrefix(strings.TrimPrefix(name, " "), "*")
+		if _, err := hex.DecodeString(sum); !ok || err != nil || name == "" {
+			return true, fmt.Errorf("invalid checksum line %d in %s", lineNo, path)
+		}
+		entries = append(entries, checksumEntry{sum: sum, name: name})
+		return false, nil
+	})
+	return entries, err
+}
+
+// fileChecksum returns the hex encoded checksum of a file
+func (ufs *UFS) fileChecksum(path string, algo HashAlgorithm) (string, error) {
+	sum, err := ufs.fileHash(path, algo)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// fileHash returns the checksum of a file
+func (ufs *UFS) fileHash(path string, algo HashAlgorithm) ([]byte, error) {
+	hasher, err := algo.newHash()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := ufs.openSequential(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(hasher, file); err != nil {
+		return nil, err
+	}
+	return hasher.Sum(nil), nil
+}
+
+// XXH64 primes
+const (
+	xxhPrime1 uint64 = 11400714785074694791
+	xxhPrime2 uint64 = 14029467366897019727
+	xxhPrime3 uint64 = 1609587929392839161
+	xxhPrime4 uint64 = 9650029242287828579
+	xxhPrime5 uint64 = 2870177450012600261
+)
+
+// xxh64 is a streaming implementation of the 64-bit xxHash with seed 0, as a hash.Hash64.
+// Sum appends the digest in big-endian order, as printed by the xxhsum tool.
+type xxh64 struct {
+	v1, v2, v3, v4 uint64
+	total          uint64
+	buf            [32]byte
+	n              int // Bytes in buf
+}
+
+// newXXH64 returns a new XXH64 hash
+func newXXH64() *xxh64 {
+	h := &xxh64{}
+	h.Reset()
+	return h
+}
+
+func (h *xxh64) Reset() {
+	// The sums wrap around, which constant expressions don't allow
+	prime1 := xxhPrime1
+	h.v1 = prime1 + xxhPrime2
+	h.v2 = xxhPrime2
+	h.v3 = 0
+	h.v4 = -prime1
+	h.total, h.n = 0, 0
+}
+
+func (h *xxh64) Size() int { return 8 }
+
+func (h *xxh64) BlockSize() int { return 32 }
+
+func (h *xxh64) Write(p []byte) (int, error) {
+	written := len(p)
+	h.total += uint64(written)
+
+	// Complete the buffered stripe first
+	if h.n > 0 {
+		copied := copy(h.buf[h.n:], p)
+		h.n += copied
+		p = p[copied:]
+		if h.n < len(h.buf) {
+			return written, nil
+		}
+		h.stripe(h.buf[:])
+		h.n = 0
+	}
+	for ; len(p) >= 32; p = p[32:] {
+		h.stripe(p)
+	}
+	h.n = copy(h.buf[:], p)
+	return written, nil
+}
+
+// stripe consumes 32 bytes
+func (h *xxh64) stripe(p []byte) {
+	h.v1 = xxhRound(h.v1, binary.LittleEndian.Uint64(p[0:8]))
+	h.v2 = xxhRound(h.v2, binary.LittleEndian.Uint64(p[8:16]))
+	h.v3 = xxhRound(h.v3, binary.LittleEndian.Uint64(p[16:24]))
+	h.v4 = xxhRound(h.v4, binary.LittleEndian.Uint64(p[24:32]))
+}
+
+func (h *xxh64) Sum64() uint64 {
+	var sum uint64
+	if h.total >= 32 {
+		sum = bits.RotateLeft64(h.v1, 1) + bits.RotateLeft64(h.v2, 7) +
+			bits.RotateLeft64(h.v3, 12) + bits.RotateLeft64(h.v4, 18)
+		sum = xxhMergeRound(sum, h.v1)
+		sum = xxhMergeRound(sum, h.v2)
+		sum = xxhMergeRound(sum, h.v3)
+		sum = xxhMergeRound(sum, h.v4)
+	} else {
+		sum = xxhPrime5
+	}
+	sum += h.total
+
+	p := h.buf[:h.n]
+	for ; len(p) >= 8; p = p[8:] {
+		sum ^= xxhRound(0, binary.LittleEndian.Uint64(p))
+		sum = bits.RotateLeft64(sum, 27)*xxhPrime1 + xxhPrime4
+	}
+	if len(p) >= 4 {
+		sum ^= uint64(binary.LittleEndian.Uint32(p)) * xxhPrime1
+		sum = bits.RotateLeft64(sum, 23)*xxhPrime2 + xxhPrime3
+		p = p[4:]
+	}
+	for _, b := range p {
+		sum ^= uint64(b) * xxhPrime5
+		sum = bits.RotateLeft64(sum, 11) * xxhPrime1
+	}
+
+	sum ^= sum >> 33
+	sum *= xxhPrime2
+	sum ^= sum >> 29
+	sum *= xxhPrime3
+	sum ^= sum >> 32
+	return sum
+}
+
+func (h *xxh64) Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint64(b, h.Sum64())
+}
+
+func xxhRound(acc, input uint64) uint64 {
+	acc += input * xxhPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxhPrime1
+}
+
+func xxhMergeRound(acc, val uint64) uint64 {
+	acc ^= xxhRound(0, val)
+	return acc*xxhPrime1 + xxhPrime4
+}
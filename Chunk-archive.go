@@ -0,0 +1,438 @@
+package ufs
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+/*
+Chunk-archive.go contains a deduplicating, upload friendly backup format.
+
+ChunkArchive cuts every file of a directory into chunks using content-defined chunking:
+chunk boundaries depend on the content itself (a rolling "gear" hash), not on fixed offsets,
+so inserting or removing bytes in a file only changes the chunks around the edit.
+Each chunk is stored once, deflated, under the SHA-256 of its content:
+
+	dstDir/
+	    manifest.json
+	    chunks/3f/3fa9c1...   (deflated chunk)
+	    chunks/b2/b20e47...
+
+Running ChunkArchive again into the same directory only writes the chunks that don't exist yet,
+and reports them, so a changed tree only needs its new chunks (and the manifest) uploaded to remote storage.
+Chunks no longer referenced are kept, they may still be used by older manifests.
+
+Functions:
+- ChunkArchive: Chunks a directory into a chunk store with a manifest.
+- RestoreChunkArchive: Rebuilds a directory from a chunk store, verifying every chunk.
+*/
+
+// chunkManifestVersion is the version of the manifest format written by ChunkArchive
+const chunkManifestVersion = 1
+
+// chunkManifestName is the file name of the manifest inside a chunk store
+const chunkManifestName = "manifest.json"
+
+// ChunkManifest describes a directory stored in a chunk store.
+type ChunkManifest struct {
+	Version      int           `json:"version"`
+	Created      time.Time     `json:"created"`
+	AvgChunkSize int           `json:"avgChunkSize"`
+	Files        []ChunkedFile `json:"files"` // Directories and files, parents before children
+}
+
+// ChunkedFile describes a single file or directory of a ChunkManifest.
+type ChunkedFile struct {
+	Path    string      `json:"path"` // Slash separated path relative to the source directory
+	IsDir   bool        `json:"isDir,omitempty"`
+	Mode    os.FileMode `json:"mode"`
+	ModTime time.Time   `json:"modTime"`
+	Size    int64       `json:"size"`
+	Chunks  []string    `json:"chunks,omitempty"` // SHA-256 of the chunks, in order
+}
+
+// ChunkArchive stores a directory in a content-defined chunk store.
+// Chunks already present in dstDir are reused, only new ones are written.
+//
+// Parameters:
+//   - sourcePath: The absolute or relative path to the directory to store
+//   - dstDir: The chunk store directory, created if needed
+//   - avgChunkSize: The average chunk size in bytes (chunks are between a quarter and 4 times this size), at least 1024
+//
+// Returns:
+//   - *ChunkManifest: The manifest written to dstDir/manifest.json
+//   - []string: Paths (relative to dstDir, slash separated) of the chunks written by this run, the ones to upload
+//   - error: An error if the directory couldn't be stored, nil otherwise
+//
+// Example:
+//
+//	_, newChunks, err := ufs.ChunkArchive("/data/project", "/backups/project-chunks", 1<<20)
+//	if err != nil {
+//	    fmt.Printf("Error chunking directory: %v\n", err)
+//	    return
+//	}
+//	for _, chunk := range append(newChunks, "manifest.json") {
+//	    upload(filepath.Join("/backups/project-chunks", chunk))
+//	}
+func (ufs *UFS) ChunkArchive(sourcePath, dstDir string, avgChunkSize int) (_ *ChunkManifest, newChunks []string, err error) {
+	defer ufs.recoverPanic("ChunkArchive", &err)
+
+	if err := ufs.requireOS("ChunkArchive"); err != nil {
+		return nil, nil, err
+	}
+	defer ufs.applyIOPriority()()
+
+	// Verify source is a directory
+	if !ufs.IsDirectory(sourcePath) {
+		return nil, nil, ufs.misuseError("ChunkArchive", "source path is not a directory", sourcePath)
+	}
+	if avgChunkSize < 1024 {
+		return nil, nil, fmt.Errorf("ChunkArchive: average chunk size must be at least 1024 bytes, got %d", avgChunkSize)
+	}
+
+	sourcePath, err = filepath.Abs(sourcePath)
+	if err != nil {
+		return nil, nil, ufs.wrapError(err, "ChunkArchive")
+	}
+	dstDir, err = filepath.Abs(dstDir)
+	if err != nil {
+		return nil, nil, ufs.wrapError(err, "ChunkArchive")
+	}
+
+	if err := os.MkdirAll(filepath.Join(dstDir, "chunks"), 0755); err != nil {
+		return nil, nil, ufs.wrapError(err, "ChunkArchive")
+	}
+
+	manifest := &ChunkManifest{
+		Version:      chunkManifestVersion,
+		Created:      time.Now().UTC(),
+		AvgChunkSize: avgChunkSize,
+		Files:        []ChunkedFile{},
+	}
+	newChunks = []string{}
+
+	err = filepath.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkAbort, "ChunkArchive")
+		}
+
+		// Skip the root and the chunk store when it is inside the source
+		if path == sourcePath {
+			return nil
+		}
+		if path == dstDir {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(sourcePath, path)
+		if err != nil {
+			return err
+		}
+
+		entry := ChunkedFile{
+			Path:    filepath.ToSlash(rel),
+			IsDir:   info.IsDir(),
+			Mode:    info.Mode(),
+			ModTime: info.ModTime().UTC(),
+		}
+		if !info.Mode().IsRegular() {
+			if info.IsDir() {
+				manifest.Files = append(manifest.Files, entry)
+			}
+			return nil
+		}
+
+		entry.Size = info.Size()
+		entry.Chunks, err = ufs.chunkFile(path, dstDir, avgChunkSize, &newChunks)
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, ufs.wrapError(err, "ChunkArchive")
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, nil, ufs.wrapError(err, "ChunkArchive")
+	}
+	if err := writeFileReplacing(filepath.Join(dstDir, chunkManifestName), data); err != nil {
+		return nil, nil, ufs.wrapError(err, "ChunkArchive")
+	}
+
+	sort.Strings(newChunks)
+	return manifest, newChunks, nil
+}
+
+// RestoreChunkArchive rebuilds a directory from a chunk store written by ChunkArchive.
+// Every chunk is verified against its SHA-256 before being written.
+//
+// Parameters:
+//   - storeDir: The chunk store directory containing manifest.json
+//   - destPath: The absolute or relative path of the directory to restore to
+//
+// Returns:
+//   - error: An error if a chunk is missing or corrupted, or a file couldn't be written, nil otherwise
+//
+// Example:
+//
+//	err := ufs.RestoreChunkArchive("/backups/project-chunks", "/restore/project")
+//	if err != nil {
+//	    fmt.Printf("Error restoring directory: %v\n", err)
+//	}
+func (ufs *UFS) RestoreChunkArchive(storeDir, destPath string) (err error) {
+	defer ufs.recoverPanic("RestoreChunkArchive", &err)
+
+	if err := ufs.requireOS("RestoreChunkArchive"); err != nil {
+		return err
+	}
+	defer ufs.applyIOPriority()()
+
+	data, err := os.ReadFile(filepath.Join(storeDir, chunkManifestName))
+	if err != nil {
+		return ufs.wrapError(err, "RestoreChunkArchive")
+	}
+
+	var manifest ChunkManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("RestoreChunkArchive: invalid manifest: %w", err)
+	}
+	if manifest.Version != chunkManifestVersion {
+		return fmt.Errorf("RestoreChunkArchive: unsupported manifest version %d", manifest.Version)
+	}
+
+	if err := os.MkdirAll(destPath, 0755); err != nil {
+		return ufs.wrapError(err, "RestoreChunkArchive")
+	}
+
+	for _, entry := range manifest.Files {
+		if !filepath.IsLocal(filepath.FromSlash(entry.Path)) {
+			return fmt.Errorf("RestoreChunkArchive: illegal file path: %s", entry.Path)
+		}
+		target := filepath.Join(destPath, filepath.FromSlash(entry.Path))
+
+		if entry.IsDir {
+			if err := os.MkdirAll(target, entry.Mode.Perm()|0700); err != nil {
+				return ufs.wrapError(err, "RestoreChunkArchive")
+			}
+			continue
+		}
+
+		if err := restoreChunkedFile(storeDir, target, entry); err != nil {
+			return ufs.wrapError(err, "RestoreChunkArchive")
+		}
+	}
+
+	return nil
+}
+
+// chunkFile cuts a file into chunks, stores the missing ones and returns the chunk hashes.
+// Paths of newly written chunks are appended to newChunks.
+func (ufs *UFS) chunkFile(path, dstDir string, avgChunkSize int, newChunks *[]string) ([]string, error) {
+	file, err := ufs.openSequential(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	chunker := newChunker(bufio.NewReaderSize(file, 256*1024), avgChunkSize)
+	hashes := []string{}
+
+	for {
+		chunk, err := chunker.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		sum := sha256.Sum256(chunk)
+		hash := hex.EncodeToString(sum[:])
+		hashes = append(hashes, hash)
+
+		rel := chunkRelPath(hash)
+		chunkPath := filepath.Join(dstDir, filepath.FromSlash(rel))
+		if _, err := os.Stat(chunkPath); err == nil {
+			continue // Already stored
+		}
+
+		if err := writeChunk(chunkPath, chunk); err != nil {
+			return nil, err
+		}
+		*newChunks = append(*newChunks, rel)
+	}
+
+	return hashes, nil
+}
+
+// chunkRelPath returns the slash separated path of a chunk inside the store
+func chunkRelPath(hash string) string {
+	return "chunks/" + hash[:2] + "/" + hash
+}
+
+// writeChunk stores a deflated chunk
+func writeChunk(path string, chunk []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	var buffer bytes.Buffer
+	compressor, err := flate.NewWriter(&buffer, flate.DefaultCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := compressor.Write(chunk); err != nil {
+		return err
+	}
+	if err := compressor.Close(); err != nil {
+		return err
+	}
+
+	return writeFileReplacing(path, buffer.Bytes())
+}
+
+// restoreChunkedFile writes a file from its verified chunks
+func restoreChunkedFile(storeDir, target string, entry ChunkedFile) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, entry.Mode.Perm())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var written int64
+	for _, hash := range entry.Chunks {
+		if len(hash) != sha256.Size*2 {
+			return fmt.Errorf("invalid chunk hash in manifest: %s", hash)
+		}
+
+		compressed, err := os.ReadFile(filepath.Join(storeDir, filepath.FromSlash(chunkRelPath(hash))))
+		if err != nil {
+			return fmt.Errorf("chunk %s of %s is missing: %w", hash, entry.Path, err)
+		}
+
+		chunk, err := io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+		if err != nil {
+			return fmt.Errorf("chunk %s of %s is corrupted: %w", hash, entry.Path, err)
+		}
+		if sum := sha256.Sum256(chunk); hex.EncodeToString(sum[:]) != hash {
+			return fmt.Errorf("chunk %s of %s is corrupted (checksum mismatch)", hash, entry.Path)
+		}
+
+		n, err := file.Write(chunk)
+		written += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+
+	if written != entry.Size {
+		return fmt.Errorf("%s restored with %d bytes, expected %d", entry.Path, written, entry.Size)
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(target, entry.ModTime, entry.ModTime)
+}
+
+// writeFileReplacing writes a file through a temporary file and a rename,
+// so readers never see a partially written file
+func writeFileReplacing(path string, data []byte) error {
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, data, 0644); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	if err := os.Rename(temp, path); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return nil
+}
+
+// gearTable holds the random values of the gear rolling hash used for content-defined chunking.
+// It is generated from a fixed seed: changing it would change every chunk boundary.
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+	state := uint64(0x5546534368756e6b) // "UFSChunk"
+	for i := range table {
+		// splitmix64
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// chunker cuts a stream into content-defined chunks
+type chunker struct {
+	reader  *bufio.Reader
+	minSize int
+	maxSize int
+	mask    uint64
+	buffer  []byte
+}
+
+func newChunker(reader *bufio.Reader, avgSize int) *chunker {
+	// The mask has log2(avgSize) bits, a boundary is found on average every avgSize bytes
+	bits := 0
+	for (1 << (bits + 1)) <= avgSize {
+		bits++
+	}
+
+	return &chunker{
+		reader:  reader,
+		minSize: avgSize / 4,
+		maxSize: avgSize * 4,
+		mask:    (uint64(1)<<bits - 1) << (64 - bits),
+		buffer:  make([]byte, 0, avgSize*4),
+	}
+}
+
+// next returns the next chunk, or io.EOF at the end of the stream.
+// The returned slice is only valid until the next call.
+func (c *chunker) next() ([]byte, error) {
+	c.buffer = c.buffer[:0]
+	var hash uint64
+
+	for len(c.buffer) < c.maxSize {
+		b, err := c.reader.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		c.buffer = append(c.buffer, b)
+		hash = (hash << 1) + gearTable[b]
+
+		if len(c.buffer) >= c.minSize && hash&c.mask == 0 {
+			break
+		}
+	}
+
+	if len(c.buffer) == 0 {
+		return nil, io.EOF
+	}
+	return c.buffer, nil
+}
@@ -0,0 +1,215 @@
+package ufs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+/*
+Collision-policy.go contains what happens when MoveFile or CopyFile find a file at their destination,
+with the policies of OverwritePolicy:
+- OverwriteExisting: the file is replaced (default)
+- SkipExisting: the file is kept, the source isn't moved or copied and the call succeeds
+- RenameWithSuffix: the source is moved or copied next to the file as "name (1).ext", "name (2).ext"...
+  like file managers do, compressed TAR archives keeping their whole extension ("name (1).tar.gz")
+- FailOnExisting: the call fails with an error wrapping os.ErrExist
+
+Options.Overwrite sets the policy of an instance, the *WithPolicy functions the one of a call and
+return the path the file was written to:
+
+	safe := ufs.NewUfs(&ufs.Options{Overwrite: ufs.FailOnExisting})
+	if err := safe.CopyFile("report.pdf", "/shared/report.pdf"); errors.Is(err, os.ErrExist) {
+	    fmt.Println("A report is already shared")
+	}
+
+	ok, dest := ufs.MoveFileUnique("scan.jpg", "/photos/scan.jpg") // "/photos/scan (1).jpg" if taken
+
+The policies other than OverwriteExisting are applied again when the file is written, so a destination
+created by another program after the check is never replaced: copies create the destination exclusively
+(O_EXCL), moves hard link the source to the destination, which fails if it exists, before removing the
+source. File systems without hard links (FAT, exFAT) fall back to checking the destination right before
+the rename.
+
+MoveDirectory merging into an existing directory and the backups of MoveWithBackup always overwrite,
+MoveDirectoryWithMerge has its own policies (see Directory-merge.go).
+
+Functions:
+- MoveFileWithPolicy: Moves a file, applying a policy when the destination exists
+- MoveFileUnique: Moves a file to a free "name (n).ext" path when the destination exists
+- CopyFileWithPolicy: Copies a file, applying a policy when the destination exists
+*/
+
+// MoveFileWithPolicy moves a file like MoveFile, with policy instead of Options.Overwrite deciding
+// what happens when the destination exists.
+//
+// Parameters:
+//   - srcPath: The absolute or relative path to the source file
+//   - destPath: The absolute or relative path where the file should be moved to
+//   - policy: What to do when destPath exists
+//
+// Returns:
+//   - bool: true if the file was moved or skipped, false otherwise
+//   - string: The path the file was moved to, "" if it was skipped or the move failed
+//
+// Example:
+//
+//	ok, dest := ufs.MoveFileWithPolicy("invoice.pdf", "/archive/invoice.pdf", ufs.SkipExisting)
+//	if ok && dest == "" {
+//	    fmt.Println("Already archived")
+//	}
+func (ufs *UFS) MoveFileWithPolicy(srcPath, destPath string, policy OverwritePolicy) (bool, string) {
+	defer ufs.recoverPanicReport("MoveFileWithPolicy")
+
+	// Verify source is a file
+	if !ufs.IsFile(srcPath) {
+		ufs.reportMisuse("MoveFileWithPolicy", "Source is not a file", srcPath)
+		return false, ""
+	}
+
+	destPath, skip, err := ufs.placeFile(destPath, policy, func(dest string, noReplace bool) error {
+		return ufs.moveFileTo(srcPath, dest, noReplace)
+	})
+	if err != nil {
+		ufs.handleError(err, "MoveFileWithPolicy")
+		return false, ""
+	}
+	if skip {
+		return true, ""
+	}
+	return true, destPath
+}
+
+// MoveFileUnique moves a file, to a free "name (1).ext", "name (2).ext"... path next to the
+// destination when it exists, like file managers do.
+//
+// Parameters:
+//   - srcPath: The absolute or relative path to the source file
+//   - destPath: The absolute or relative path where the file should be moved to
+//
+// Returns:
+//   - bool: true if the file was moved successfully, false otherwise
+//   - string: The path the file was moved to, "" if the move failed
+//
+// Example:
+//
+//	ok, dest := ufs.MoveFileUnique("/downloads/report.pdf", "/documents/report.pdf")
+//	if ok {
+//	    fmt.Println("Moved to", dest) // "/documents/report (1).pdf" if report.pdf was there
+//	}
+func (ufs *UFS) MoveFileUnique(srcPath, destPath string) (bool, string) {
+	defer ufs.recoverPanicReport("MoveFileUnique")
+
+	return ufs.MoveFileWithPolicy(srcPath, destPath, RenameWithSuffix)
+}
+
+// CopyFileWithPolicy copies a file like CopyFile, with policy instead of Options.Overwrite deciding
+// what happens when the destination exists.
+//
+// Parameters:
+//   - src: The absolute or relative path to the source file
+//   - dst: The absolute or relative path to the destination file
+//   - policy: What to do when dst exists
+//
+// Returns:
+//   - string: The path of the copy, "" if it was skipped
+//   - error: An error if the file couldn't be copied, wrapping os.ErrExist with FailOnExisting
+//
+// Example:
+//
+//	dest, err := ufs.CopyFileWithPolicy("template.docx", "/letters/letter.docx", ufs.RenameWithSuffix)
+//	if err != nil {
+//	    fmt.Printf("Error copying template: %v\n", err)
+//	    return
+//	}
+//	fmt.Println("New letter:", dest)
+func (ufs *UFS) CopyFileWithPolicy(src, dst string, policy OverwritePolicy) (_ string, err error) {
+	defer ufs.recoverPanic("CopyFileWithPolicy", &err)
+
+	// Verify source is a file
+	if !ufs.IsFile(src) {
+		return "", ufs.misuseError("CopyFileWithPolicy", "source is not a file", src)
+	}
+
+	dst, _, err = ufs.placeFile(dst, policy, func(dest string, noReplace bool) error {
+		return ufs.copyFileTo(src, dest, noReplace)
+	})
+	return dst, err
+}
+
+// placeFile writes a file to path with policy, write being called with the destination chosen. Unless
+// policy is OverwriteExisting, write is asked not to replace an existing file and must fail with an error
+// wrapping os.ErrExist instead: a destination created since the check is then skipped, refused, or
+// replaced by the next free name. It returns the path written, or skip = true when the existing file is kept.
+func (ufs *UFS) placeFile(path string, policy OverwritePolicy, write func(dest string, noReplace bool) error) (string, bool, error) {
+	for {
+		dest, skip, err := ufs.resolveCollision(path, policy)
+		if err != nil || skip {
+			return "", skip, err
+		}
+
+		err = write(dest, policy != OverwriteExisting)
+		switch {
+		case err == nil:
+			return dest, false, nil
+		case policy == OverwriteExisting || !errors.Is(err, os.ErrExist):
+			return "", false, err
+		case policy == SkipExisting:
+			return "", true, nil
+		case policy == FailOnExisting:
+			return "", false, err
+		}
+		// RenameWithSuffix: the name was taken since the check, the next free one is tried
+	}
+}
+
+// renameNoReplace renames a file without replacing newpath, failing with an error wrapping os.ErrExist
+// if it exists: oldpath is hard linked to newpath, which fails if it exists, then removed. A rename to
+// another file system fails with a cross-device error, like os.Rename.
+func renameNoReplace(oldpath, newpath string) error {
+	err := os.Link(oldpath, newpath)
+	if err == nil {
+		if err := os.Remove(oldpath); err != nil {
+			os.Remove(newpath)
+			return err
+		}
+		return nil
+	}
+	if errors.Is(err, os.ErrExist) || errorCodeOf(err) == CodeCrossDevice {
+		return err
+	}
+
+	// File systems without hard links: the destination is checked right before the rename
+	if _, statErr := os.Lstat(newpath); !os.IsNotExist(statErr) {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrExist}
+	}
+	return os.Rename(oldpath, newpath)
+}
+
+// resolveCollision applies a policy to a destination about to be written. It returns the path to
+// write to, or skip = true when the existing file is kept.
+func (ufs *UFS) resolveCollision(path string, policy OverwritePolicy) (string, bool, error) {
+	if policy < OverwriteExisting || policy > FailOnExisting {
+		return "", false, fmt.Errorf("invalid OverwritePolicy %d", policy)
+	}
+
+	exists := func(candidate string) bool {
+		if ufs.onOS() {
+			_, err := os.Lstat(candidate)
+			return !os.IsNotExist(err)
+		}
+		_, err := ufs.backend().Stat(candidate)
+		return !os.IsNotExist(err)
+	}
+	if policy == OverwriteExisting || !exists(path) {
+		return path, false, nil
+	}
+
+	switch policy {
+	case SkipExisting:
+		return "", true, nil
+	case RenameWithSuffix:
+		return uniquePathWith(path, exists), false, nil
+	}
+	return "", false, fmt.Errorf("%w: %s", os.ErrExist, path)
+}
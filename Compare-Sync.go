@@ -0,0 +1,878 @@
+package ufs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+Compare-Sync.go contains functions to compare directory trees, detect changed files and mirror a tree.
+
+The way a change is detected can be chosen per call, trading speed for accuracy:
+- DetectSize: compares sizes only (fastest, misses same-size edits)
+- DetectSizeModTime: compares size and modification time only (fast, default)
+- DetectQuickHash: compares size and a hash of the first and last blocks of the file
+- DetectFullHash: compares a hash of the whole file content (slow, most accurate)
+- DetectContent: compares the bytes of both files (as accurate as DetectFullHash, stops at the first difference)
+
+For multi-terabyte trees DetectSizeModTime or DetectQuickHash are usually good enough,
+DetectFullHash should be used when modification times can't be trusted.
+
+Hash based strategies can persist the computed hashes in a state file (CompareOptions.StateFile).
+On the next run files whose size and modification time didn't change reuse the stored hash
+instead of being read again, which makes repeated (e.g. nightly) runs over large trees feasible.
+
+Functions:
+- CompareDirectories: Compares two directory trees and reports added, removed, modified and unchanged files.
+- DiffDirectories: Compares two directory trees, CompareDirectories with the sides named a and b.
+- SyncDirectories: Makes a directory tree a mirror of another one, copying only what changed.
+- FileChanged: Compares two files using the selected change detection strategy.
+- FilesEqual: Reports whether two files have the same content.
+*/
+
+// ChangeDetection selects how two files are compared to decide whether a file has changed.
+type ChangeDetection int
+
+const (
+	// DetectSizeModTime treats files as changed when their size or modification time differ
+	DetectSizeModTime ChangeDetection = iota
+	// DetectQuickHash treats files as changed when their size or the hash of their first and last blocks differ
+	DetectQuickHash
+	// DetectFullHash treats files as changed when the hash of their whole content differs
+	DetectFullHash
+	// DetectSize treats files as changed when their size differs
+	DetectSize
+	// DetectContent treats files as changed when their bytes differ, read side by side
+	DetectContent
+)
+
+// quickHashBlockSize is the size of the head and tail blocks hashed by DetectQuickHash
+const quickHashBlockSize = 64 * 1024
+
+// String returns the name of the change detection strategy
+func (d ChangeDetection) String() string {
+	switch d {
+	case DetectSizeModTime:
+		return "size+mtime"
+	case DetectQuickHash:
+		return "quick-hash"
+	case DetectFullHash:
+		return "full-hash"
+	case DetectSize:
+		return "size"
+	case DetectContent:
+		return "content"
+	default:
+		return fmt.Sprintf("ChangeDetection(%d)", int(d))
+	}
+}
+
+// CompareOptions controls how CompareDirectories compares two trees.
+type CompareOptions struct {
+	Detection ChangeDetection // Strategy used to decide if a file present on both sides has changed
+	StateFile string          // Optional path of a state file caching hashes between runs (hash strategies only)
+}
+
+// DirectoryDiff is the result of comparing a source tree with a destination tree.
+// All paths are relative to the compared roots.
+type DirectoryDiff struct {
+	Added     []string // Files present in the source but not in the destination
+	Removed   []string // Files present in the destination but not in the source
+	Modified  []string // Files present on both sides whose content changed
+	Unchanged []string // Files present on both sides that are considered identical
+}
+
+// HasChanges reports whether the diff contains any added, removed or modified file
+func (d *DirectoryDiff) HasChanges() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Modified) > 0
+}
+
+// CompareDirectories compares the files of two directory trees.
+// Files are matched by their path relative to each root, directories themselves are not reported.
+//
+// Parameters:
+//   - srcDir: The absolute or relative path to the source (reference) directory
+//   - dstDir: The absolute or relative path to the destination directory
+//   - opts: Comparison options, nil uses DetectSizeModTime
+//
+// Returns:
+//   - *DirectoryDiff: The added, removed, modified and unchanged files
+//   - error: An error if either tree couldn't be read
+//
+// Example:
+//
+//	diff, err := ufs.CompareDirectories("/data/photos", "/backup/photos", &ufs.CompareOptions{
+//	    Detection: ufs.DetectQuickHash,
+//	})
+//	if err != nil {
+//	    fmt.Printf("Error comparing directories: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("%d new, %d changed, %d deleted\n", len(diff.Added), len(diff.Modified), len(diff.Removed))
+func (ufs *UFS) CompareDirectories(srcDir, dstDir string, opts *CompareOptions) (_ *DirectoryDiff, err error) {
+	defer ufs.recoverPanic("CompareDirectories", &err)
+	defer ufs.applyIOPriority()()
+
+	if opts == nil {
+		opts = &CompareOptions{}
+	}
+
+	if !ufs.IsDirectory(srcDir) {
+		return nil, ufs.misuseError("CompareDirectories", "source path is not a directory", srcDir)
+	}
+
+	srcFiles, err := ufs.collectFiles(srcDir, "CompareDirectories")
+	if err != nil {
+		return nil, ufs.wrapError(err, "CompareDirectories")
+	}
+
+	// A missing destination simply means every source file is new
+	dstFiles := map[string]os.FileInfo{}
+	if ufs.PathExists(dstDir) {
+		dstFiles, err = ufs.collectFiles(dstDir, "CompareDirectories")
+		if err != nil {
+			return nil, ufs.wrapError(err, "CompareDirectories")
+		}
+	}
+
+	state, err := loadSyncState(opts.StateFile)
+	if err != nil {
+		return nil, ufs.wrapError(err, "CompareDirectories")
+	}
+
+	diff := &DirectoryDiff{}
+	for rel, srcInfo := range srcFiles {
+		dstInfo, exists := dstFiles[rel]
+		if !exists {
+			diff.Added = append(diff.Added, rel)
+			continue
+		}
+
+		changed, err := ufs.fileInfoChanged(state, filepath.Join(srcDir, rel), srcInfo, filepath.Join(dstDir, rel), dstInfo, opts.Detection)
+		if err != nil {
+			return nil, ufs.wrapError(err, "CompareDirectories")
+		}
+		if changed {
+			diff.Modified = append(diff.Modified, rel)
+		} else {
+			diff.Unchanged = append(diff.Unchanged, rel)
+		}
+	}
+
+	for rel := range dstFiles {
+		if _, exists := srcFiles[rel]; !exists {
+			diff.Removed = append(diff.Removed, rel)
+		}
+	}
+
+	if err := state.save(); err != nil {
+		return nil, ufs.wrapError(err, "CompareDirectories")
+	}
+
+	// Map iteration order is random, keep the report stable
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Modified)
+	sort.Strings(diff.Unchanged)
+
+	return diff, nil
+}
+
+// DiffDirectories compares the files of two directory trees, and is CompareDirectories with the sides
+// named a and b: Added lists the files only in a, Removed the files only in b, and Modified the files
+// of both whose content differs according to opts.Detection.
+//
+// Parameters:
+//   - a: The absolute or relative path to the first directory
+//   - b: The absolute or relative path to the second directory
+//   - opts: Comparison options, nil uses DetectSizeModTime
+//
+// Returns:
+//   - *DirectoryDiff: The added, removed, modified and unchanged files, relative to the roots
+//   - error: An error if either tree couldn't be read
+//
+// Example:
+//
+//	diff, err := ufs.DiffDirectories("release-1.2", "release-1.3", &ufs.CompareOptions{
+//	    Detection: ufs.DetectContent,
+//	})
+//	if err != nil {
+//	    fmt.Printf("Error comparing releases: %v\n", err)
+//	    return
+//	}
+//	for _, rel := range diff.Modified {
+//	    fmt.Println("changed:", rel)
+//	}
+func (ufs *UFS) DiffDirectories(a, b string, opts *CompareOptions) (_ *DirectoryDiff, err error) {
+	defer ufs.recoverPanic("DiffDirectories", &err)
+
+	return ufs.CompareDirectories(a, b, opts)
+}
+
+// SyncOptions controls how SyncDirectories mirrors a tree. The zero value compares files by size and
+// modification time and keeps the extraneous files of the destination.
+type SyncOptions struct {
+	Detection ChangeDetection // Strategy used to decide if a file present on both sides has changed
+	StateFile string          // Optional path of a state file caching hashes between runs (hash strategies only)
+
+	// DeleteExtraneous removes the files and directories of the destination that are not in the source,
+	// and replaces the destination entries whose type differs (a directory where the source has a file).
+	DeleteExtraneous bool
+}
+
+// SyncReport is the result of SyncDirectories. Paths are relative to the roots.
+type SyncReport struct {
+	Copied  []string      // Files and symbolic links copied, because they were new or changed
+	Deleted []string      // Files and directories removed from the destination
+	Skipped []string      // Files and symbolic links left as they were, because they didn't change
+	Errors  []SyncFailure // Entries that couldn't be synchronized
+}
+
+// SyncFailure is an entry SyncDirectories couldn't synchronize.
+type SyncFailure struct {
+	Path string // Path of the entry, relative to the roots
+	Err  error  // Why the entry couldn't be synchronized
+}
+
+// OK reports whether every entry was synchronized
+func (report *SyncReport) OK() bool {
+	return len(report.Errors) == 0
+}
+
+// SyncDirectories makes dst a mirror of src (one-way): new and changed files are copied, with their
+// permissions and modification time so the next run sees them unchanged, unchanged files are skipped,
+// directories are created, symbolic links are recreated with the same target, and with
+// opts.DeleteExtraneous the entries of dst missing from src are removed.
+// Entries that fail are listed in the report and the synchronization goes on.
+// Hidden entries are left alone on both sides when Options.ExcludeHidden is set.
+//
+// Parameters:
+//   - src: The absolute or relative path to the source directory
+//   - dst: The absolute or relative path to the destination directory, created if needed
+//   - opts: Synchronization options, nil uses DetectSizeModTime and keeps extraneous files
+//
+// Returns:
+//   - *SyncReport: The copied, deleted and skipped entries, and the ones that failed
+//   - error: An error if src is not a directory, dst is inside src, or either tree couldn't be read
+//
+// Example:
+//
+//	report, err := ufs.SyncDirectories("/srv/www", "/mnt/mirror/www", &ufs.SyncOptions{
+//	    Detection:        ufs.DetectQuickHash,
+//	    DeleteExtraneous: true,
+//	})
+//	if err != nil {
+//	    fmt.Printf("Error synchronizing: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("%d copied, %d deleted, %d unchanged, %d errors\n",
+//	    len(report.Copied), len(report.Deleted), len(report.Skipped), len(report.Errors))
+func (ufs *UFS) SyncDirectories(src, dst string, opts *SyncOptions) (_ *SyncReport, err error) {
+	defer ufs.recoverPanic("SyncDirectories", &err)
+
+	if err := ufs.requireOS("SyncDirectories"); err != nil {
+		return nil, err
+	}
+	defer ufs.applyIOPriority()()
+
+	if opts == nil {
+		opts = &SyncOptions{}
+	}
+
+	src, err = filepath.Abs(src)
+	if err != nil {
+		return nil, ufs.wrapError(err, "SyncDirectories")
+	}
+	dst, err = filepath.Abs(dst)
+	if err != nil {
+		return nil, ufs.wrapError(err, "SyncDirectories")
+	}
+	if !ufs.IsDirectory(src) {
+		return nil, ufs.misuseError("SyncDirectories", "source path is not a directory", src)
+	}
+	// A destination inside the source would be copied into itself, and a source inside the destination
+	// would be deleted as extraneous: compare the real paths, symbolic links resolved
+	realSrc, realDst := resolveExistingPath(src), resolveExistingPath(dst)
+	if pathWithin(realDst, realSrc) {
+		return nil, fmt.Errorf("SyncDirectories: can't synchronize %s into itself", src)
+	}
+	if pathWithin(realSrc, realDst) {
+		return nil, fmt.Errorf("SyncDirectories: can't synchronize %s into %s, which contains it", src, dst)
+	}
+
+	srcEntries, err := ufs.collectEntries(src, "SyncDirectories")
+	if err != nil {
+		return nil, ufs.wrapError(err, "SyncDirectories")
+	}
+	dstEntries := map[string]os.FileInfo{}
+	if ufs.PathExists(dst) {
+		if dstEntries, err = ufs.collectEntries(dst, "SyncDirectories"); err != nil {
+			return nil, ufs.wrapError(err, "SyncDirectories")
+		}
+	}
+	if !ufs.PathExists(dst) && !ufs.dryRun("SyncDirectories", DryRunMkdir, dst, "") {
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return nil, ufs.wrapError(err, "SyncDirectories")
+		}
+	}
+
+	state, err := loadSyncState(opts.StateFile)
+	if err != nil {
+		return nil, ufs.wrapError(err, "SyncDirectories")
+	}
+
+	report := &SyncReport{}
+	fail := func(rel string, err error) {
+		report.Errors = append(report.Errors, SyncFailure{Path: rel, Err: err})
+	}
+
+	// Sorted, so directories are created before their entries
+	rels := make([]string, 0, len(srcEntries))
+	for rel := range srcEntries {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+
+	for _, rel := range rels {
+		srcInfo, srcPath, dstPath := srcEntries[rel], filepath.Join(src, rel), filepath.Join(dst, rel)
+		dstInfo, exists := dstEntries[rel]
+
+		// An entry of another type is in the way
+		if exists && dstInfo.Mode().Type() != srcInfo.Mode().Type() {
+			if !opts.DeleteExtraneous {
+				fail(rel, fmt.Errorf("destination is a %s, the source a %s", entryKind(dstInfo), entryKind(srcInfo)))
+				continue
+			}
+			if !ufs.dryRun("SyncDirectories", DryRunRemoveAll, dstPath, "") {
+				if err := os.RemoveAll(dstPath); err != nil {
+					fail(rel, err)
+					continue
+				}
+				ufs.recordQuota(dstPath)
+			}
+			exists = false
+		}
+
+		switch {
+		case srcInfo.IsDir():
+			if !exists && !ufs.dryRun("SyncDirectories", DryRunMkdir, dstPath, "") {
+				if err := os.Mkdir(dstPath, srcInfo.Mode().Perm()|0700); err != nil {
+					fail(rel, err)
+				}
+			}
+
+		case srcInfo.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(srcPath)
+			if err != nil {
+				fail(rel, err)
+				continue
+			}
+			if exists {
+				if current, err := os.Readlink(dstPath); err == nil && current == link {
+					report.Skipped = append(report.Skipped, rel)
+					continue
+				}
+			}
+			if err := ufs.requireCapability(CapabilitySymlinks, "SyncDirectories", srcPath); err != nil {
+				fail(rel, err)
+				continue
+			}
+			if ufs.dryRun("SyncDirectories", DryRunSymlink, dstPath, link) {
+				report.Copied = append(report.Copied, rel)
+				continue
+			}
+			if exists {
+				if err := os.Remove(dstPath); err != nil {
+					fail(rel, err)
+					continue
+				}
+			}
+			if err := os.Symlink(link, dstPath); err != nil {
+				fail(rel, err)
+				continue
+			}
+			report.Copied = append(report.Copied, rel)
+
+		case srcInfo.Mode().IsRegular():
+			if exists {
+				changed, err := ufs.fileInfoChanged(state, srcPath, srcInfo, dstPath, dstInfo, opts.Detection)
+				if err != nil {
+					fail(rel, err)
+					continue
+				}
+				if !changed {
+					report.Skipped = append(report.Skipped, rel)
+					continue
+				}
+			}
+			if !ufs.dryRun("SyncDirectories", DryRunCopy, dstPath, srcPath) {
+				if err := ufs.syncFile(srcPath, dstPath, srcInfo); err != nil {
+					fail(rel, err)
+					continue
+				}
+			}
+			report.Copied = append(report.Copied, rel)
+
+		default:
+			fail(rel, fmt.Errorf("special files can't be synchronized"))
+		}
+	}
+
+	if opts.DeleteExtraneous {
+		extraneous := []string{}
+		for rel := range dstEntries {
+			if _, ok := srcEntries[rel]; !ok {
+				extraneous = append(extraneous, rel)
+			}
+		}
+		// Reversed, so the entries of a directory are removed before it
+		sort.Sort(sort.Reverse(sort.StringSlice(extraneous)))
+		for _, rel := range extraneous {
+			dstPath := filepath.Join(dst, rel)
+			if !ufs.dryRun("SyncDirectories", DryRunRemoveAll, dstPath, "") {
+				if err := os.RemoveAll(dstPath); err != nil {
+					fail(rel, err)
+					continue
+				}
+				ufs.recordQuota(dstPath)
+			}
+			report.Deleted = append(report.Deleted, rel)
+		}
+		sort.Strings(report.Deleted)
+	}
+
+	if ufs.IsDryRun() {
+		return report, nil
+	}
+	if err := state.save(); err != nil {
+		return report, ufs.wrapError(err, "SyncDirectories")
+	}
+	return report, nil
+}
+
+// FileChanged compares two files using the given change detection strategy.
+//
+// Parameters:
+//   - a: The absolute or relative path to the first file
+//   - b: The absolute or relative path to the second file
+//   - detection: The strategy used to compare the files
+//
+// Returns:
+//   - bool: true if the files are considered different
+//   - error: An error if either file couldn't be read
+//
+// Example:
+//
+//	changed, err := ufs.FileChanged("/data/db.sqlite", "/backup/db.sqlite", ufs.DetectFullHash)
+//	if err == nil && changed {
+//	    fmt.Println("Backup is outdated")
+//	}
+func (ufs *UFS) FileChanged(a, b string, detection ChangeDetection) (_ bool, err error) {
+	defer ufs.recoverPanic("FileChanged", &err)
+
+	infoA, err := ufs.backend().Stat(a)
+	if err != nil {
+		return false, ufs.wrapError(err, "FileChanged")
+	}
+	infoB, err := ufs.backend().Stat(b)
+	if err != nil {
+		return false, ufs.wrapError(err, "FileChanged")
+	}
+	if infoA.IsDir() || infoB.IsDir() {
+		return false, fmt.Errorf("FileChanged: both paths must be files: %s, %s", a, b)
+	}
+
+	// A nil state simply hashes without caching
+	return ufs.fileInfoChanged(nil, a, infoA, b, infoB, detection)
+}
+
+// FilesEqual reports whether two files have the same content. Files of different sizes are
+// never read, otherwise both files are read side by side until the first difference.
+//
+// Parameters:
+//   - a: The absolute or relative path to the first file
+//   - b: The absolute or relative path to the second file
+//
+// Returns:
+//   - bool: true if both files have the same bytes
+//   - error: An error if either path is not a file or couldn't be read
+//
+// Example:
+//
+//	equal, err := ufs.FilesEqual("config.json", "config.json.orig")
+//	if err == nil && !equal {
+//	    fmt.Println("config.json was edited")
+//	}
+func (ufs *UFS) FilesEqual(a, b string) (_ bool, err error) {
+	defer ufs.recoverPanic("FilesEqual", &err)
+
+	if !ufs.IsFile(a) {
+		return false, ufs.misuseError("FilesEqual", "path is not a file", a)
+	}
+	if !ufs.IsFile(b) {
+		return false, ufs.misuseError("FilesEqual", "path is not a file", b)
+	}
+
+	equal, err := filesEqual(ufs.backend(), a, b)
+	if err != nil {
+		return false, ufs.wrapError(err, "FilesEqual")
+	}
+	return equal, nil
+}
+
+// filesEqual compares the sizes then the bytes of two files of backend
+func filesEqual(backend Backend, a, b string) (bool, error) {
+	infoA, err := backend.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	infoB, err := backend.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	if infoA.Size() != infoB.Size() {
+		return false, nil
+	}
+	if os.SameFile(infoA, infoB) {
+		return true, nil
+	}
+
+	fileA, err := backend.OpenFile(a, os.O_RDONLY, 0)
+	if err != nil {
+		return false, err
+	}
+	defer fileA.Close()
+	fileB, err := backend.OpenFile(b, os.O_RDONLY, 0)
+	if err != nil {
+		return false, err
+	}
+	defer fileB.Close()
+
+	bufA := make([]byte, streamBufferSize)
+	bufB := make([]byte, streamBufferSize)
+	for {
+		n, errA := io.ReadFull(fileA, bufA)
+		m, errB := io.ReadFull(fileB, bufB)
+		if !bytes.Equal(bufA[:n], bufB[:m]) {
+			return false, nil
+		}
+		endA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		endB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		if errA != nil && !endA {
+			return false, errA
+		}
+		if errB != nil && !endB {
+			return false, errB
+		}
+		if endA || endB {
+			// Unequal ends mean a file changed size since the Stat
+			return endA && endB, nil
+		}
+	}
+}
+
+// fileInfoChanged is a helper function comparing two already stat-ed files.
+// Hashes are taken from (and stored into) the state when it is not nil.
+func (ufs *UFS) fileInfoChanged(state *syncState, pathA string, infoA os.FileInfo, pathB string, infoB os.FileInfo, detection ChangeDetection) (bool, error) {
+	// A different size always means a change, whatever the strategy
+	if infoA.Size() != infoB.Size() {
+		return true, nil
+	}
+
+	switch detection {
+	case DetectQuickHash, DetectFullHash:
+		digestA, err := state.digest(pathA, infoA, detection, ufs.fileDigest)
+		if err != nil {
+			return false, err
+		}
+		digestB, err := state.digest(pathB, infoB, detection, ufs.fileDigest)
+		if err != nil {
+			return false, err
+		}
+		return !bytes.Equal(digestA, digestB), nil
+	case DetectSize:
+		return false, nil
+	case DetectContent:
+		equal, err := filesEqual(ufs.backend(), pathA, pathB)
+		return !equal, err
+	default:
+		return !infoA.ModTime().Equal(infoB.ModTime()), nil
+	}
+}
+
+// fileDigest computes the sha256 digest used by the hash based change detection strategies
+func (ufs *UFS) fileDigest(path string, size int64, detection ChangeDetection) ([]byte, error) {
+	hasher := sha256.New()
+
+	// Small files are hashed completely, quick hashing wouldn't save anything. The files of other
+	// backends than the operating system can't seek, they are hashed completely too.
+	if detection == DetectFullHash || size <= 2*quickHashBlockSize || !ufs.onOS() {
+		reader, err := ufs.openSequential(path)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+
+		if _, err := io.Copy(hasher, reader); err != nil {
+			return nil, err
+		}
+		return hasher.Sum(nil), nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Hash the first and the last block only
+	if _, err := io.CopyN(hasher, file, quickHashBlockSize); err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(-quickHashBlockSize, io.SeekEnd); err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(hasher, file, quickHashBlockSize); err != nil {
+		return nil, err
+	}
+
+	return hasher.Sum(nil), nil
+}
+
+// collectFiles is a helper function returning all files of a tree keyed by their path relative to root.
+// Unreadable paths abort the walk unless Options.OnWalkError decides to skip them.
+func (ufs *UFS) collectFiles(root string, operation string) (map[string]os.FileInfo, error) {
+	files := map[string]os.FileInfo{}
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkAbort, operation)
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkAbort, operation)
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files[rel] = info
+		return nil
+	})
+	return files, err
+}
+
+// collectEntries is a helper function returning the files, directories and symbolic links of a tree,
+// keyed by their path relative to root, root excluded. Hidden entries are left out when
+// Options.ExcludeHidden is set. Unreadable paths abort the walk unless Options.OnWalkError decides to skip them.
+func (ufs *UFS) collectEntries(root string, operation string) (map[string]os.FileInfo, error) {
+	entries := map[string]os.FileInfo{}
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkAbort, operation)
+		}
+		if path == root {
+			return nil
+		}
+		if ufs.skipHidden(d) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkAbort, operation)
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		entries[rel] = info
+		return nil
+	})
+	return entries, err
+}
+
+// syncFile copies a file for SyncDirectories, with its permissions and modification time
+func (ufs *UFS) syncFile(src, dst string, info os.FileInfo) error {
+	if err := ufs.CopyFileWithPermissions(src, dst); err != nil {
+		return err
+	}
+	// The mode given at creation doesn't apply to files that already existed
+	if !ufs.IsScopedStorage() {
+		if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return os.Chtimes(dst, time.Time{}, info.ModTime())
+}
+
+// resolveExistingPath returns the absolute path with the symbolic links of its longest existing part
+// resolved, so paths not created yet can be compared with existing ones
+func resolveExistingPath(path string) string {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	missing := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(resolved, missing)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, missing)
+		}
+		missing = filepath.Join(filepath.Base(path), missing)
+		path = parent
+	}
+}
+
+// pathWithin reports whether path is dir or inside it, both being clean absolute paths
+func pathWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// entryKind names the type of an entry for error messages
+func entryKind(info os.FileInfo) string {
+	switch {
+	case info.IsDir():
+		return "directory"
+	case info.Mode()&os.ModeSymlink != 0:
+		return "symbolic link"
+	case info.Mode().IsRegular():
+		return "file"
+	}
+	return "special file"
+}
+
+// syncStateVersion is the version of the state file format
+const syncStateVersion = 1
+
+// syncStateEntry is what is remembered about a single file between two runs
+type syncStateEntry struct {
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"modTime"`
+	QuickHash string    `json:"quickHash,omitempty"`
+	FullHash  string    `json:"fullHash,omitempty"`
+}
+
+// syncState caches file hashes between runs, keyed by absolute file path.
+// A nil *syncState is valid and simply doesn't cache anything.
+type syncState struct {
+	path    string
+	Version int                       `json:"version"`
+	Entries map[string]syncStateEntry `json:"entries"`
+	dirty   bool
+}
+
+// loadSyncState reads the state file at path, a missing file gives an empty state.
+// An empty path disables the cache and returns a nil state.
+func loadSyncState(path string) (*syncState, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	state := &syncState{path: path, Version: syncStateVersion, Entries: map[string]syncStateEntry{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid sync state file %s: %w", path, err)
+	}
+
+	// A state written by another format version can't be trusted, start over
+	if state.Version != syncStateVersion || state.Entries == nil {
+		state.Version = syncStateVersion
+		state.Entries = map[string]syncStateEntry{}
+		state.dirty = true
+	}
+
+	return state, nil
+}
+
+// digest returns the hash of a file computed by fileDigest,
+// reusing the cached one if size and modification time didn't change
+func (state *syncState) digest(path string, info os.FileInfo, detection ChangeDetection, fileDigest func(string, int64, ChangeDetection) ([]byte, error)) ([]byte, error) {
+	if state == nil {
+		return fileDigest(path, info.Size(), detection)
+	}
+
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, cached := state.Entries[key]
+	if !cached || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		// The file changed since the last run, forget every hash stored for it
+		entry = syncStateEntry{Size: info.Size(), ModTime: info.ModTime()}
+	}
+
+	stored := &entry.QuickHash
+	if detection == DetectFullHash {
+		stored = &entry.FullHash
+	}
+	if *stored != "" {
+		if digest, err := hex.DecodeString(*stored); err == nil {
+			return digest, nil
+		}
+	}
+
+	digest, err := fileDigest(path, info.Size(), detection)
+	if err != nil {
+		return nil, err
+	}
+
+	*stored = hex.EncodeToString(digest)
+	state.Entries[key] = entry
+	state.dirty = true
+
+	return digest, nil
+}
+
+// save writes the state back to its file if anything changed, atomically so an interrupted save
+// leaves the previous state
+func (state *syncState) save() error {
+	if state == nil || !state.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(state.path), 0755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(state.path, data, 0644); err != nil {
+		return err
+	}
+
+	state.dirty = false
+	return nil
+}
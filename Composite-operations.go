@@ -0,0 +1,330 @@
+package ufs
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Composite-operations.go contains operations chaining several subsystems of the library, with the
+temporary files, verification and cleanup they need handled in one call:
+- CopyThenCompress archives a snapshot of a file or directory, so files changing during the
+  compression (logs, databases) don't end up half written in the archive
+- DownloadAndExtract downloads an archive, checks its checksum, then extracts it
+- CompressAndUpload compresses a file or directory and writes the archive to another Backend
+
+	err := ufs.DownloadAndExtract(ctx, "https://example.com/tool-1.2.tar.gz", "/opt/tool",
+	    "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08")
+
+Temporary files are removed whether the operation succeeds or not, and the destination is only
+touched once the steps before it succeeded: a failed download or checksum leaves /opt/tool as it was.
+
+Functions:
+- CopyThenCompress: Copies a file or directory to a work directory, compresses the copy and verifies the archive
+- DownloadAndExtract: Downloads an archive, verifies its checksum and extracts it
+- CompressAndUpload: Compresses a file or directory and writes the archive to a Backend
+*/
+
+// CopyThenCompress copies a file or directory into a temporary directory created in workDir, compresses
+// the copy to a ZIP archive, verifies the archive and removes the copy. Copies are cloned on file systems
+// supporting it (see File-clone.go), which makes the snapshot almost free.
+//
+// Parameters:
+//   - src: The absolute or relative path to the file or directory to archive
+//   - workDir: The directory receiving the temporary copy, "" uses the temporary directory of the OS
+//   - archivePath: The absolute or relative path of the ZIP archive to create
+//
+// Returns:
+//   - error: An error if a step failed or the archive doesn't read back, nil otherwise
+//
+// Example:
+//
+//	err := ufs.CopyThenCompress("/var/lib/app", "/var/tmp", "/backups/app.zip")
+//	if err != nil {
+//	    fmt.Printf("Error archiving the application data: %v\n", err)
+//	}
+func (ufs *UFS) CopyThenCompress(src, workDir, archivePath string) (err error) {
+	defer ufs.recoverPanic("CopyThenCompress", &err)
+
+	if err := ufs.requireOS("CopyThenCompress"); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return ufs.wrapError(err, "CopyThenCompress")
+	}
+	if ufs.dryRun("CopyThenCompress", DryRunWrite, archivePath, src) {
+		return nil
+	}
+
+	staging, err := os.MkdirTemp(workDir, ".ufs-snapshot-")
+	if err != nil {
+		return ufs.wrapError(err, "CopyThenCompress")
+	}
+	defer os.RemoveAll(staging)
+
+	snapshot := filepath.Join(staging, filepath.Base(src))
+	if info.IsDir() {
+		if _, err := ufs.CopyDirectory(src, snapshot, &CopyDirectoryOptions{StopOnError: true}); err != nil {
+			return ufs.wrapError(err, "CopyThenCompress")
+		}
+		err = ufs.CompressDirectory(snapshot, archivePath)
+	} else {
+		if err := ufs.CopyFile(src, snapshot); err != nil {
+			return ufs.wrapError(err, "CopyThenCompress")
+		}
+		err = ufs.CompressFile(snapshot, archivePath)
+	}
+	if err != nil {
+		return ufs.wrapError(err, "CopyThenCompress")
+	}
+
+	return ufs.verifyCompositeArchive(archivePath, "CopyThenCompress")
+}
+
+// DownloadAndExtract downloads an archive over HTTP(S), checks its checksum and extracts it to dst.
+// The format is taken from the name of the downloaded file (.zip, .tar, .tar.gz, .tgz, .tar.bz2,
+// .tbz2). The archive is extracted within Options.ExtractLimits whatever its format.
+//
+// Parameters:
+//   - ctx: The context cancelling the download and the extraction
+//   - rawURL: The URL of the archive
+//   - dst: The absolute or relative path where the archive is extracted, created if needed
+//   - checksum: The expected checksum, "algorithm:hex" (e.g. "sha256:9f86...") or a hexadecimal SHA-256,
+//     SHA-512, SHA-1 or MD5 recognized by its length; "" skips the verification
+//
+// Returns:
+//   - error: An error if the download failed, the checksum doesn't match or the extraction failed,
+//     an *ExtractionLimitError when the archive exceeds the limits
+//
+// Example:
+//
+//	err := ufs.DownloadAndExtract(context.Background(), "https://example.com/assets.zip", "public/assets",
+//	    "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae")
+//	if err != nil {
+//	    fmt.Printf("Error installing assets: %v\n", err)
+//	}
+func (ufs *UFS) DownloadAndExtract(ctx context.Context, rawURL, dst, checksum string) (err error) {
+	defer ufs.recoverPanic("DownloadAndExtract", &err)
+
+	if err := ufs.requireOS("DownloadAndExtract"); err != nil {
+		return err
+	}
+
+	ctx, done, err := ufs.trackOperation(ctx)
+	if err != nil {
+		return ufs.wrapError(err, "DownloadAndExtract")
+	}
+	defer done()
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ufs.wrapError(err, "DownloadAndExtract")
+	}
+	name := path.Base(parsed.Path)
+	format := detectArchiveFormat(name)
+	if format == archiveFormatUnknown {
+		return fmt.Errorf("DownloadAndExtract: unsupported archive format: %s", rawURL)
+	}
+	algo, expected, err := parseExpectedChecksum(checksum)
+	if err != nil {
+		return ufs.wrapError(err, "DownloadAndExtract")
+	}
+	if ufs.dryRun("DownloadAndExtract", DryRunExtract, dst, rawURL) {
+		return nil
+	}
+
+	// The archive keeps its name so its format is recognized by the extraction
+	staging, err := os.MkdirTemp("", "ufs-download-")
+	if err != nil {
+		return ufs.wrapError(err, "DownloadAndExtract")
+	}
+	defer os.RemoveAll(staging)
+	archivePath := filepath.Join(staging, name)
+
+	if err := downloadFile(ctx, rawURL, archivePath, algo, expected); err != nil {
+		return ufs.wrapError(err, "DownloadAndExtract")
+	}
+
+	_, err = ufs.extractArchiveFormat(ctx, archivePath, dst, nil, "DownloadAndExtract")
+	return err
+}
+
+// CompressAndUpload compresses a file or directory to a temporary ZIP archive, verifies it and writes it
+// to targetPath on the target backend, e.g. a network share or cloud storage implementing Backend.
+// The archive is written to targetPath + ".partial" then renamed, so targetPath is never a truncated archive.
+//
+// Parameters:
+//   - src: The absolute or relative path to the file or directory to compress
+//   - target: The backend receiving the archive, nil uses the backend of the instance
+//   - targetPath: The path of the archive on the backend, its directory must exist
+//
+// Returns:
+//   - error: An error if the compression, the verification or the upload failed, nil otherwise
+//
+// Example:
+//
+//	err := ufs.CompressAndUpload("/srv/site", backupShare, "backups/site.zip")
+//	if err != nil {
+//	    fmt.Printf("Error uploading the backup: %v\n", err)
+//	}
+func (ufs *UFS) CompressAndUpload(src string, target Backend, targetPath string) (err error) {
+	defer ufs.recoverPanic("CompressAndUpload", &err)
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return ufs.wrapError(err, "CompressAndUpload")
+	}
+	if target == nil {
+		target = ufs.backend()
+	}
+	if ufs.dryRun("CompressAndUpload", DryRunWrite, targetPath, src) {
+		return nil
+	}
+
+	staging, err := os.MkdirTemp("", "ufs-upload-")
+	if err != nil {
+		return ufs.wrapError(err, "CompressAndUpload")
+	}
+	defer os.RemoveAll(staging)
+	archivePath := filepath.Join(staging, filepath.Base(src)+".zip")
+
+	if info.IsDir() {
+		err = ufs.CompressDirectory(src, archivePath)
+	} else {
+		err = ufs.CompressFile(src, archivePath)
+	}
+	if err != nil {
+		return ufs.wrapError(err, "CompressAndUpload")
+	}
+	if err := ufs.verifyCompositeArchive(archivePath, "CompressAndUpload"); err != nil {
+		return err
+	}
+
+	return ufs.wrapError(uploadFile(archivePath, target, targetPath), "CompressAndUpload")
+}
+
+// verifyCompositeArchive reads back an archive just created, removing it when it is corrupted
+func (ufs *UFS) verifyCompositeArchive(archivePath, operation string) error {
+	report, err := ufs.VerifyArchive(archivePath)
+	if err == nil && len(report.Corrupt) > 0 {
+		err = fmt.Errorf("archive %s is corrupted: %s: %w", archivePath, report.Corrupt[0].Name, report.Corrupt[0].Err)
+	}
+	if err != nil {
+		os.Remove(archivePath)
+		return ufs.wrapError(err, operation)
+	}
+	return nil
+}
+
+// parseExpectedChecksum splits a checksum given as "algorithm:hex" or as a bare hexadecimal digest,
+// whose algorithm is recognized by its length. An empty checksum returns an empty algorithm.
+func parseExpectedChecksum(checksum string) (HashAlgorithm, string, error) {
+	if checksum == "" {
+		return "", "", nil
+	}
+	algo, digest, found := strings.Cut(strings.TrimSpace(checksum), ":")
+	if !found {
+		digest = algo
+		switch len(digest) {
+		case 32:
+			algo = string(HashMD5)
+		case 40:
+			algo = string(HashSHA1)
+		case 64:
+			algo = string(HashSHA256)
+		case 128:
+			algo = string(HashSHA512)
+		default:
+			return "", "", fmt.Errorf("unrecognized checksum %q", checksum)
+		}
+	}
+	if _, err := HashAlgorithm(strings.ToLower(algo)).newHash(); err != nil {
+		return "", "", err
+	}
+	if _, err := hex.DecodeString(digest); err != nil {
+		return "", "", fmt.Errorf("invalid checksum %q: %w", checksum, err)
+	}
+	return HashAlgorithm(strings.ToLower(algo)), strings.ToLower(digest), nil
+}
+
+// downloadFile downloads rawURL to path, checking the checksum of the content when algo is set
+func downloadFile(ctx context.Context, rawURL, path string, algo HashAlgorithm, expected string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("downloading %s: %s", rawURL, response.Status)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var writer io.Writer = file
+	hasher, _ := algo.newHash()
+	if algo != "" {
+		writer = io.MultiWriter(file, hasher)
+	}
+	if _, err := io.Copy(writer, response.Body); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if algo != "" {
+		if actual := hex.EncodeToString(hasher.Sum(nil)); actual != expected {
+			return fmt.Errorf("checksum mismatch for %s: expected %s %s, got %s", rawURL, algo, expected, actual)
+		}
+	}
+	return nil
+}
+
+// uploadFile copies a local file to targetPath on a backend, through a ".partial" file renamed at the end
+func uploadFile(localPath string, target Backend, targetPath string) error {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	partial := targetPath + ".partial"
+	dst, err := target.OpenFile(partial, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	written, err := io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// Check the backend kept everything before replacing the previous archive
+		var info os.FileInfo
+		if info, err = target.Stat(partial); err == nil && info.Size() != written {
+			err = fmt.Errorf("uploaded %d bytes to %s, the backend has %d", written, partial, info.Size())
+		}
+	}
+	if err != nil {
+		target.Remove(partial)
+		return err
+	}
+	return target.Rename(partial, targetPath)
+}
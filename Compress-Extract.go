@@ -1,11 +1,16 @@
 package ufs
 
 import (
+	"archive/tar"
 	"archive/zip"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -31,6 +36,11 @@ Basic Functions:
 - CompressDirectory: Compresses a directory into a ZIP file.
 - ExtractArchive: Extracts the contents of a ZIP file to a specified directory.
 - CompressFile: Compresses a single file into a ZIP file.
+- ExtractFiles: Extracts only the entries of a ZIP or TAR archive matching glob patterns.
+- ExtractMatching: ExtractFiles with extraction settings (limits, overwrite policy, renaming), returning a report.
+- CompressDirectoryContext / ExtractArchiveContext: Cancellable variants that remove their partial output when aborted.
+- ExtractArchiveWithOptions: Extracts with an entry transform hook to rename or skip entries (see Extract-options.go).
+- CompressDirectoryWithOptions / CompressFileWithOptions: Compress with a custom level, method and stored extensions (see Compress-options.go).
 
 Some utilities uses basic functions internally:
 - CompressHere: Compresses the  directory into a ZIP file and outputs in cwd.
@@ -46,6 +56,8 @@ Other utilities (Just for demonstration, not recommended for production use) all
 
 // CompressDirectory compresses a directory into a ZIP file.
 // This function will create a ZIP archive containing all files and subdirectories.
+// When Options.CompressionWorkers is greater than 1, files are deflated concurrently
+// by that many workers and written to the archive in the usual order.
 //
 // Parameters:
 //   - sourcePath: The absolute or relative path to the directory to compress
@@ -62,21 +74,79 @@ Other utilities (Just for demonstration, not recommended for production use) all
 //	    return
 //	}
 //	fmt.Println("Directory compressed successfully")
-func (ufs *UFS) CompressDirectory(sourcePath, destPath string) error {
+func (ufs *UFS) CompressDirectory(sourcePath, destPath string) (err error) {
+	defer ufs.recoverPanic("CompressDirectory", &err)
+
+	if err := ufs.requireOS("CompressDirectory"); err != nil {
+		return err
+	}
+
+	return ufs.compressDirectory(context.Background(), sourcePath, destPath, nil, "CompressDirectory")
+}
+
+// CompressDirectoryContext compresses a directory into a ZIP file like CompressDirectory,
+// but stops as soon as the context is cancelled or its deadline is exceeded.
+// The context is checked between files and while copying file contents; when the
+// operation is aborted the partially written ZIP file is removed.
+//
+// Parameters:
+//   - ctx: The context controlling cancellation and deadlines
+//   - sourcePath: The absolute or relative path to the directory to compress
+//   - destPath: The absolute or relative path where the ZIP file will be created
+//
+// Returns:
+//   - error: An error if the compression failed or was cancelled (wrapping ctx.Err()), nil otherwise
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+//	defer cancel()
+//	err := ufs.CompressDirectoryContext(ctx, "/path/to/source_dir", "/path/to/archive.zip")
+//	if errors.Is(err, context.DeadlineExceeded) {
+//	    fmt.Println("Compression took too long and was aborted")
+//	}
+func (ufs *UFS) CompressDirectoryContext(ctx context.Context, sourcePath, destPath string) (err error) {
+	defer ufs.recoverPanic("CompressDirectoryContext", &err)
+
+	if err := ufs.requireOS("CompressDirectoryContext"); err != nil {
+		return err
+	}
+
+	return ufs.compressDirectory(ctx, sourcePath, destPath, nil, "CompressDirectoryContext")
+}
+
+// compressDirectory is the implementation shared by CompressDirectory, CompressDirectoryContext
+// and CompressDirectoryWithOptions. A nil opts uses the default compression settings.
+// The partially written archive is removed when compression fails.
+func (ufs *UFS) compressDirectory(ctx context.Context, sourcePath, destPath string, opts *CompressOptions, operation string) (err error) {
+	defer ufs.applyIOPriority()()
+
 	// Verify source is a directory
 	if !ufs.IsDirectory(sourcePath) {
-		return fmt.Errorf("source path is not a directory: %s", sourcePath)
+		return ufs.misuseError(operation, "source path is not a directory", sourcePath)
 	}
 
 	// Get absolute paths to ensure consistent behavior
-	sourcePath, err := filepath.Abs(sourcePath)
+	sourcePath, err = filepath.Abs(sourcePath)
 	if err != nil {
-		return ufs.wrapError(err, "CompressDirectory")
+		return ufs.wrapError(err, operation)
 	}
 
+	destPath, err = ufs.expandOutputPath(destPath)
+	if err != nil {
+		return ufs.wrapError(err, operation)
+	}
 	destPath, err = filepath.Abs(destPath)
 	if err != nil {
-		return ufs.wrapError(err, "CompressDirectory")
+		return ufs.wrapError(err, operation)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return ufs.wrapError(err, operation)
+	}
+
+	if ufs.dryRun(operation, DryRunWrite, destPath, sourcePath) {
+		return nil
 	}
 
 	// Ensure destination directory exists
@@ -84,33 +154,90 @@ func (ufs *UFS) CompressDirectory(sourcePath, destPath string) error {
 	if !ufs.IsDirectory(destDir) {
 		err = os.MkdirAll(destDir, 0755)
 		if err != nil {
-			return ufs.wrapError(err, "CompressDirectory")
+			return ufs.wrapError(err, operation)
 		}
 	}
 
 	// Create zip file
 	zipFile, err := os.Create(destPath)
 	if err != nil {
-		return ufs.wrapError(err, "CompressDirectory")
+		return ufs.wrapError(err, operation)
 	}
-	defer zipFile.Close()
+
+	// Don't leave a broken archive behind
+	defer func() {
+		if err != nil {
+			os.Remove(destPath)
+		}
+	}()
+
+	// Prevent compressing the destination zip itself
+	excludeDest := func(path string) bool { return path == destPath }
 
 	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
+	err = ufs.writeDirectoryZip(ctx, zipWriter, sourcePath, excludeDest, opts, operation)
+
+	// Close the writer first, it writes the central directory
+	if closeErr := zipWriter.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := zipFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return ufs.wrapError(err, operation)
+	}
+
+	return nil
+}
+
+// writeDirectoryZip adds the contents of the absolute sourcePath to a zip writer, which the caller closes.
+// Paths for which exclude returns true are left out (used to skip the archive being written).
+// The returned error is not wrapped.
+func (ufs *UFS) writeDirectoryZip(ctx context.Context, zipWriter *zip.Writer, sourcePath string, exclude func(path string) bool, opts *CompressOptions, operation string) (err error) {
+	// Cancelled by the shutdown manager of the instance, if any
+	ctx, done, err := ufs.trackOperation(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	opts.registerCompressor(zipWriter)
+
+	// In parallel mode the walk only collects the entries, they are written afterwards
+	workers := ufs.compressionWorkers()
+	var entries []compressEntry
+
+	// AppleDouble entries of the files with macOS metadata, written after the files
+	var macEntries []*zipMetadataEntry
 
 	// Walk the directory and add files to the zip
 	err = filepath.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
+			return ufs.decideWalkError(path, err, WalkAbort, operation)
+		}
+
+		// Stop between files when the context is done
+		if err := ctx.Err(); err != nil {
 			return err
 		}
 
-		// Skip the root directory itself
-		if path == sourcePath {
+		// Skip the root directory itself, unless entries are stored under its name
+		if path == sourcePath && !opts.includeRootFolder() {
 			return nil
 		}
 
-		// Prevent compressing the destination zip itself
-		if path == destPath {
+		// Skip excluded paths, like the archive being written
+		if exclude(path) {
+			return nil
+		}
+
+		// Skip hidden entries when Options.ExcludeHidden is set
+		if path != sourcePath && ufs.skipHidden(fs.FileInfoToDirEntry(info)) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
@@ -126,9 +253,27 @@ func (ufs *UFS) CompressDirectory(sourcePath, destPath string) error {
 			return err
 		}
 		header.Name = relPath
+		if opts.includeRootFolder() {
+			header.Name = filepath.Join(filepath.Base(sourcePath), relPath)
+		}
 
 		// Set compression method
-		header.Method = zip.Deflate
+		header.Method = opts.methodFor(path)
+
+		if !info.IsDir() {
+			macEntry, err := ufs.macMetadataEntry(path, filepath.ToSlash(header.Name))
+			if err != nil {
+				return err
+			}
+			if macEntry != nil {
+				macEntries = append(macEntries, macEntry)
+			}
+		}
+
+		if workers > 1 {
+			entries = append(entries, compressEntry{path: path, header: header, isDir: info.IsDir()})
+			return nil
+		}
 
 		// Create writer for the file header
 		writer, err := zipWriter.CreateHeader(header)
@@ -149,15 +294,18 @@ func (ufs *UFS) CompressDirectory(sourcePath, destPath string) error {
 		defer file.Close()
 
 		// Copy file contents to the zip
-		_, err = io.Copy(writer, file)
+		_, err = io.Copy(writer, &contextReader{ctx: ctx, reader: file})
 		return err
 	})
 
-	if err != nil {
-		return ufs.wrapError(err, "CompressDirectory")
+	if err == nil && workers > 1 {
+		err = ufs.writeEntriesParallel(ctx, zipWriter, entries, workers, opts.level(), operation)
+	}
+	if err == nil {
+		err = writeMacMetadataEntries(zipWriter, macEntries)
 	}
 
-	return nil
+	return err
 }
 
 // ExtractArchive extracts the contents of a ZIP file to a specified directory.
@@ -178,53 +326,249 @@ func (ufs *UFS) CompressDirectory(sourcePath, destPath string) error {
 //	    return
 //	}
 //	fmt.Println("Archive extracted successfully")
-func (ufs *UFS) ExtractArchive(sourcePath, destPath string) error {
+func (ufs *UFS) ExtractArchive(sourcePath, destPath string) (err error) {
+	defer ufs.recoverPanic("ExtractArchive", &err)
+
+	if err := ufs.requireOS("ExtractArchive"); err != nil {
+		return err
+	}
+
+	_, err = ufs.extractArchive(context.Background(), sourcePath, destPath, nil, "ExtractArchive")
+	return err
+}
+
+// ExtractArchiveContext extracts the contents of a ZIP file like ExtractArchive,
+// but stops as soon as the context is cancelled or its deadline is exceeded.
+// The context is checked between files and while copying file contents; when the
+// operation is aborted every file and directory created by the extraction is removed.
+// Files that already existed and were overwritten before the cancellation are not restored.
+//
+// Parameters:
+//   - ctx: The context controlling cancellation and deadlines
+//   - sourcePath: The absolute or relative path to the ZIP file
+//   - destPath: The absolute or relative path where the contents will be extracted
+//
+// Returns:
+//   - error: An error if the extraction failed or was cancelled (wrapping ctx.Err()), nil otherwise
+//
+// Example:
+//
+//	ctx, cancel := context.WithCancel(context.Background())
+//	go func() { <-stopButton; cancel() }()
+//	err := ufs.ExtractArchiveContext(ctx, "/path/to/archive.zip", "/path/to/extract_dir")
+//	if errors.Is(err, context.Canceled) {
+//	    fmt.Println("Extraction cancelled, partial output removed")
+//	}
+func (ufs *UFS) ExtractArchiveContext(ctx context.Context, sourcePath, destPath string) (err error) {
+	defer ufs.recoverPanic("ExtractArchiveContext", &err)
+
+	if err := ufs.requireOS("ExtractArchiveContext"); err != nil {
+		return err
+	}
+
+	_, err = ufs.extractArchive(ctx, sourcePath, destPath, nil, "ExtractArchiveContext")
+	return err
+}
+
+// extractArchive is the implementation shared by ExtractArchive, ExtractArchiveContext
+// and ExtractArchiveWithOptions. A nil opts extracts every entry as is.
+func (ufs *UFS) extractArchive(ctx context.Context, sourcePath, destPath string, opts *ExtractOptions, operation string) (_ *ExtractReport, err error) {
+	defer ufs.applyIOPriority()()
+
 	// Verify source is a file
 	if !ufs.IsFile(sourcePath) {
-		return fmt.Errorf("source path is not a file: %s", sourcePath)
+		return nil, ufs.misuseError(operation, "source path is not a file", sourcePath)
 	}
 
 	// Get absolute paths to ensure consistent behavior
-	sourcePath, err := filepath.Abs(sourcePath)
+	sourcePath, err = filepath.Abs(sourcePath)
 	if err != nil {
-		return ufs.wrapError(err, "ExtractArchive")
+		return nil, ufs.wrapError(err, operation)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, ufs.wrapError(err, operation)
 	}
 
+	// Open the zip file
+	reader, err := zip.OpenReader(sourcePath)
+	if err != nil {
+		return nil, ufs.wrapError(classifyArchiveError(sourcePath, "", err), operation)
+	}
+	defer reader.Close()
+
+	return ufs.extractZipReader(ctx, &reader.Reader, sourcePath, destPath, opts, operation)
+}
+
+// extractZipReader extracts every entry of an opened zip archive to destPath.
+// archive names the archive in the errors, "" for streams. A nil opts extracts every entry as is.
+func (ufs *UFS) extractZipReader(ctx context.Context, reader *zip.Reader, archive, destPath string, opts *ExtractOptions, operation string) (_ *ExtractReport, err error) {
+	// Cancelled by the shutdown manager of the instance, if any
+	ctx, done, err := ufs.trackOperation(ctx)
+	if err != nil {
+		return nil, ufs.wrapError(err, operation)
+	}
+	defer done()
+
 	destPath, err = filepath.Abs(destPath)
 	if err != nil {
-		return ufs.wrapError(err, "ExtractArchive")
+		return nil, ufs.wrapError(err, operation)
 	}
 
+	// Remember what existed before, so a cancelled extraction can be undone
+	cleanup := &extractionCleanup{destPath: destPath, seen: map[string]bool{}}
+
 	// Ensure destination directory exists
 	if !ufs.IsDirectory(destPath) {
 		err = os.MkdirAll(destPath, 0755)
 		if err != nil {
-			return ufs.wrapError(err, "ExtractArchive")
+			return nil, ufs.wrapError(err, operation)
 		}
+		cleanup.createdDest = true
 	}
 
-	// Open the zip file
-	reader, err := zip.OpenReader(sourcePath)
-	if err != nil {
-		return ufs.wrapError(err, "ExtractArchive")
+	report := &ExtractReport{}
+
+	// Refuse archives exceeding the limits before writing anything
+	if err := ufs.extractLimits(opts).check(reader, opts); err != nil {
+		cleanup.undo()
+		return report, ufs.wrapError(err, operation)
 	}
-	defer reader.Close()
+	var selected []*zip.File
+	for _, file := range reader.File {
+		if _, skip := opts.transform(file.Name, file.FileInfo()); !skip {
+			selected = append(selected, file)
+		}
+	}
+	if err := checkZipEncryption(archive, selected); err != nil {
+		cleanup.undo()
+		return report, ufs.wrapError(err, operation)
+	}
+
+	// Look for existing files first, so nothing is written when one would be overwritten
+	if opts.overwritePolicy() == FailOnExisting {
+		for _, file := range reader.File {
+			name, skip := opts.transform(file.Name, file.FileInfo())
+			if skip || file.FileInfo().IsDir() || ufs.isMacMetadataEntry(file.Name) {
+				continue
+			}
+			if _, _, err := opts.resolveExisting(destPath, name, report); err != nil {
+				return report, ufs.wrapError(err, operation)
+			}
+		}
+	}
+
+	// With Options.PreserveMacMetadata the AppleDouble entries are applied to the extracted files
+	// afterwards, extracted maps the archive names to the names written
+	var macEntries []*zip.File
+	extracted := map[string]string{}
 
 	// Extract each file
 	for _, file := range reader.File {
-		err := ufs.extractZipFile(file, destPath)
+		if err := ctx.Err(); err != nil {
+			cleanup.undo()
+			return report, ufs.wrapError(err, operation)
+		}
+
+		if ufs.isMacMetadataEntry(file.Name) {
+			macEntries = append(macEntries, file)
+			continue
+		}
+
+		name, skip := opts.transform(file.Name, file.FileInfo())
+		if skip {
+			report.Skipped = append(report.Skipped, file.Name)
+			continue
+		}
+
+		if !file.FileInfo().IsDir() {
+			name, skip, err = opts.resolveExisting(destPath, name, report)
+			if err != nil {
+				return report, ufs.wrapError(err, operation)
+			}
+			if skip {
+				continue
+			}
+		}
+
+		cleanup.track(name)
+		err := ufs.extractZipFileContext(ctx, file, name, destPath)
 		if err != nil {
-			return ufs.wrapError(err, "ExtractArchive")
+			if ctx.Err() != nil {
+				cleanup.undo()
+			}
+			return report, ufs.wrapError(classifyArchiveError(archive, file.Name, err), operation)
 		}
+		report.Extracted = append(report.Extracted, name)
+		extracted[strings.TrimSuffix(file.Name, "/")] = name
 	}
 
-	return nil
+	if err := ufs.applyMacMetadataEntries(macEntries, extracted, destPath, operation); err != nil {
+		return report, ufs.wrapError(err, operation)
+	}
+
+	return report, nil
+}
+
+// extractionCleanup remembers which top level paths an extraction created,
+// so they can be removed again when the extraction is cancelled
+type extractionCleanup struct {
+	destPath    string
+	createdDest bool
+	created     []string
+	seen        map[string]bool
+}
+
+// track records the top level path of an entry if it didn't exist before the extraction
+func (c *extractionCleanup) track(name string) {
+	name = strings.TrimPrefix(filepath.ToSlash(name), "./")
+	top := strings.SplitN(name, "/", 2)[0]
+	if top == "" || c.seen[top] {
+		return
+	}
+	c.seen[top] = true
+
+	topPath := filepath.Join(c.destPath, top)
+	if _, err := os.Lstat(topPath); os.IsNotExist(err) {
+		c.created = append(c.created, topPath)
+	}
+}
+
+// undo removes everything the extraction created
+func (c *extractionCleanup) undo() {
+	if c.createdDest {
+		os.RemoveAll(c.destPath)
+		return
+	}
+	for _, path := range c.created {
+		os.RemoveAll(path)
+	}
+}
+
+// contextReader is an io.Reader that fails with the context error once the context is done,
+// so long copies can be interrupted
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
 }
 
 // extractZipFile is a helper function to extract a single file from a zip archive
 func (ufs *UFS) extractZipFile(file *zip.File, destPath string) error {
+	return ufs.extractZipFileContext(context.Background(), file, file.Name, destPath)
+}
+
+// extractZipFileContext extracts a single file from a zip archive under the given name,
+// aborting the copy when ctx is done
+func (ufs *UFS) extractZipFileContext(ctx context.Context, file *zip.File, name string, destPath string) error {
 	// Form the full path to the file
-	filePath := filepath.Join(destPath, file.Name)
+	filePath := filepath.Join(destPath, name)
 
 	// Check for zip slip vulnerability
 	if !strings.HasPrefix(filePath, filepath.Clean(destPath)+string(os.PathSeparator)) {
@@ -261,10 +605,333 @@ func (ufs *UFS) extractZipFile(file *zip.File, destPath string) error {
 	defer zipFile.Close()
 
 	// Copy the contents
-	_, err = io.Copy(destFile, zipFile)
+	_, err = io.Copy(destFile, &contextReader{ctx: ctx, reader: zipFile})
+	return err
+}
+
+// ExtractFiles extracts only the archive entries matching at least one of the given patterns.
+// Patterns without a slash (e.g. "*.json") are matched against the base name of every entry,
+// patterns with a slash are matched against the full entry path where "**" matches any number
+// of directories (e.g. "configs/**" or "src/**/*.go"), braces are expanded ("*.{yml,yaml}").
+// ZIP and TAR (.tar, .tar.gz, .tgz, .tar.bz2, .tbz2) archives are supported.
+// The matching entries are extracted within Options.ExtractLimits, existing files are replaced.
+//
+// Parameters:
+//   - archivePath: The absolute or relative path to the archive
+//   - destPath: The absolute or relative path where the matching entries will be extracted
+//   - patterns: The patterns selecting the entries to extract
+//
+// Returns:
+//   - []string: The names of the extracted entries
+//   - error: An error if the extraction failed, nil otherwise. An archive exceeding the limits fails
+//     with an *ExtractionLimitError and nothing extracted.
+//
+// Example:
+//
+//	extracted, err := ufs.ExtractFiles("/path/to/archive.zip", "/path/to/extract_dir", []string{"*.json", "configs/**"})
+//	if err != nil {
+//	    fmt.Printf("Error extracting files: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("Extracted %d files\n", len(extracted))
+func (ufs *UFS) ExtractFiles(archivePath, destPath string, patterns []string) (_ []string, err error) {
+	defer ufs.recoverPanic("ExtractFiles", &err)
+
+	if err := ufs.requireOS("ExtractFiles"); err != nil {
+		return nil, err
+	}
+
+	// Verify source is a file
+	if !ufs.IsFile(archivePath) {
+		return nil, ufs.misuseError("ExtractFiles", "source path is not a file", archivePath)
+	}
+
+	// Validate the patterns once instead of failing in the middle of the extraction
+	globs, err := compileGlobs(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &ExtractOptions{match: func(name string) bool { return matchArchiveGlob(globs, name) }}
+	report, err := ufs.extractArchiveFormat(context.Background(), archivePath, destPath, opts, "ExtractFiles")
+	if report == nil {
+		return nil, err
+	}
+	return report.Extracted, err
+}
+
+// extractArchiveFormat extracts a ZIP or TAR archive, chosen from its extension, with the extraction
+// settings, limits and overwrite policy included. A nil opts extracts every entry as is.
+func (ufs *UFS) extractArchiveFormat(ctx context.Context, archivePath, destPath string, opts *ExtractOptions, operation string) (*ExtractReport, error) {
+	switch format := detectArchiveFormat(archivePath); format {
+	case archiveFormatZip:
+		return ufs.extractArchive(ctx, archivePath, destPath, opts, operation)
+	case archiveFormatTar, archiveFormatTarGz, archiveFormatTarBz2:
+		return ufs.extractTarArchive(ctx, archivePath, destPath, format, opts, operation)
+	default:
+		return nil, fmt.Errorf("unsupported archive format: %s", archivePath)
+	}
+}
+
+// extractTarArchive extracts a TAR archive, compressed with gzip or bzip2 depending on format, like
+// extractZipReader does a ZIP archive. A stream can't be inspected before it is extracted, so the
+// limits are checked while reading it: the entries written are removed when it exceeds them.
+// With FailOnExisting the archive is read a first time to look for existing files.
+func (ufs *UFS) extractTarArchive(ctx context.Context, archivePath, destPath string, format int, opts *ExtractOptions, operation string) (_ *ExtractReport, err error) {
+	defer ufs.applyIOPriority()()
+
+	// Cancelled by the shutdown manager of the instance, if any
+	ctx, done, err := ufs.trackOperation(ctx)
+	if err != nil {
+		return nil, ufs.wrapError(err, operation)
+	}
+	defer done()
+
+	destPath, err = filepath.Abs(destPath)
+	if err != nil {
+		return nil, ufs.wrapError(err, operation)
+	}
+
+	report := &ExtractReport{}
+
+	// Look for existing files first, so nothing is written when one would be overwritten
+	if opts.overwritePolicy() == FailOnExisting {
+		err := readTarArchive(archivePath, format, func(header *tar.Header, _ io.Reader, _ *countingReader) error {
+			name, skip := opts.transform(header.Name, header.FileInfo())
+			if skip || header.Typeflag != tar.TypeReg {
+				return nil
+			}
+			_, _, err := opts.resolveExisting(destPath, name, report)
+			return err
+		})
+		if err != nil {
+			return report, ufs.wrapError(classifyArchiveError(archivePath, "", err), operation)
+		}
+	}
+
+	// Remember what existed before, so a cancelled or refused extraction can be undone
+	cleanup := &extractionCleanup{destPath: destPath, seen: map[string]bool{}}
+	if !ufs.IsDirectory(destPath) {
+		if err := os.MkdirAll(destPath, 0755); err != nil {
+			return nil, ufs.wrapError(err, operation)
+		}
+		cleanup.createdDest = true
+	}
+
+	budget := &extractionBudget{limits: ufs.extractLimits(opts)}
+	var entry string
+	err = readTarArchive(archivePath, format, func(header *tar.Header, reader io.Reader, compressed *countingReader) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entry = header.Name
+		budget.compressed = compressed
+
+		name, skip := opts.transform(header.Name, header.FileInfo())
+		if skip {
+			report.Skipped = append(report.Skipped, header.Name)
+			return nil
+		}
+		if err := budget.admit(header.Name, name, uint64(max(header.Size, 0))); err != nil {
+			return err
+		}
+
+		if header.Typeflag == tar.TypeReg {
+			name, skip, err = opts.resolveExisting(destPath, name, report)
+			if err != nil || skip {
+				return err
+			}
+		}
+
+		cleanup.track(name)
+		if err := ufs.extractTarEntry(header, name, budget.reader(header.Name, reader), destPath); err != nil {
+			return err
+		}
+		report.Extracted = append(report.Extracted, name)
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil || errors.Is(err, ErrExtractionLimitExceeded) {
+			cleanup.undo()
+			report.Extracted = nil
+		}
+		return report, ufs.wrapError(classifyArchiveError(archivePath, entry, err), operation)
+	}
+	return report, nil
+}
+
+// readTarArchive calls fn with every entry of a TAR archive, its content and the archive bytes read so far
+func readTarArchive(archivePath string, format int, fn func(header *tar.Header, reader io.Reader, compressed *countingReader) error) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	compressed := &countingReader{reader: file}
+	stream, err := openTarStream(compressed, format)
+	if err != nil {
+		return err
+	}
+
+	tarReader := tar.NewReader(stream)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(header, tarReader, compressed); err != nil {
+			return err
+		}
+	}
+}
+
+// ExtractMatching extracts only the archive entries matching at least one of the globs, with the
+// extraction settings of ExtractArchiveWithOptions: the limits, checked on the matching entries only,
+// the overwrite policy, StripComponents and Transform, which receive the entries left by the globs.
+// The globs are matched against the names stored in the archive, like the patterns of ExtractFiles.
+// The entries of a ZIP archive that don't match are not even decompressed, so picking a few files
+// out of a large archive is fast; TAR archives have to be read up to their last entry.
+//
+// Parameters:
+//   - archivePath: The absolute or relative path to the ZIP or TAR archive
+//   - destPath: The absolute or relative path where the matching entries will be extracted
+//   - opts: The extraction settings, nil extracts the matching entries as is within Options.ExtractLimits
+//   - globs: The patterns selecting the entries, at least one
+//
+// Returns:
+//   - *ExtractReport: The extracted and skipped entries, also filled up to the failing entry on error
+//   - error: An error if no glob is given or the extraction failed, nil otherwise. An archive whose
+//     matching entries exceed the limits fails with an *ExtractionLimitError and nothing extracted,
+//     FailOnExisting fails with an error wrapping os.ErrExist.
+//
+// Example:
+//
+//	report, err := ufs.ExtractMatching("release.zip", "/etc/app", &ufs.ExtractOptions{
+//	    StripComponents: 2, // "app-1.4.0/config/app.yaml" is extracted as "app.yaml"
+//	    Overwrite:       ufs.SkipExisting,
+//	    Limits:          &ufs.ExtractLimits{MaxTotalBytes: 10 << 20},
+//	}, "*/config/*.yaml", "*/config/*.yml")
+//	if err != nil {
+//	    fmt.Printf("Error extracting the configuration: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("%d files extracted, %d kept\n", len(report.Extracted), len(report.SkippedExisting))
+func (ufs *UFS) ExtractMatching(archivePath, destPath string, opts *ExtractOptions, globs ...string) (_ *ExtractReport, err error) {
+	defer ufs.recoverPanic("ExtractMatching", &err)
+
+	if err := ufs.requireOS("ExtractMatching"); err != nil {
+		return nil, err
+	}
+
+	if len(globs) == 0 {
+		return nil, fmt.Errorf("ExtractMatching: no pattern given for %s", archivePath)
+	}
+	if !ufs.IsFile(archivePath) {
+		return nil, ufs.misuseError("ExtractMatching", "source path is not a file", archivePath)
+	}
+	if err := opts.validate(); err != nil {
+		return nil, ufs.wrapError(err, "ExtractMatching")
+	}
+	compiled, err := compileGlobs(globs)
+	if err != nil {
+		return nil, ufs.wrapError(err, "ExtractMatching")
+	}
+
+	// The caller's settings are left untouched
+	matching := ExtractOptions{}
+	if opts != nil {
+		matching = *opts
+	}
+	matching.match = func(name string) bool { return matchArchiveGlob(compiled, name) }
+	return ufs.extractArchiveFormat(context.Background(), archivePath, destPath, &matching, "ExtractMatching")
+}
+
+// extractTarEntry is a helper function to extract a single entry from a tar archive under the given name
+func (ufs *UFS) extractTarEntry(header *tar.Header, name string, reader io.Reader, destPath string) error {
+	// Form the full path to the file
+	filePath := filepath.Join(destPath, name)
+
+	// Check for zip slip vulnerability (same attack applies to tar archives)
+	if !strings.HasPrefix(filePath, filepath.Clean(destPath)+string(os.PathSeparator)) {
+		return fmt.Errorf("illegal file path: %s", filePath)
+	}
+
+	mode := header.FileInfo().Mode()
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(filePath, mode.Perm()|0700)
+	case tar.TypeReg:
+		// handled below
+	default:
+		// Links, devices and other special entries are not extracted
+		return nil
+	}
+
+	// Ensure the parent directory exists
+	err := os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err != nil {
+		return err
+	}
+
+	// Create the file
+	destFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	defer destFile.Close()
+
+	// Copy the contents
+	_, err = io.Copy(destFile, reader)
 	return err
 }
 
+// matchArchivePattern reports whether an archive entry name matches a pattern.
+// Patterns without a slash are matched against the base name, others against the
+// full name where a "**" segment matches zero or more directories, see Glob.go.
+func matchArchivePattern(pattern, name string) bool {
+	glob, err := compileGlob(pattern)
+	return err == nil && matchArchiveGlob(glob, name)
+}
+
+// matchArchiveGlob is matchArchivePattern with a compiled pattern
+func matchArchiveGlob(glob *globPattern, name string) bool {
+	// Tar archives created with "tar -C dir ." prefix their entries with "./"
+	return glob.matchName(strings.TrimPrefix(strings.TrimSuffix(filepath.ToSlash(name), "/"), "./"))
+}
+
+// matchSegments matches path segments one by one, expanding "**" to any number of segments
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Try to match the rest of the pattern at every possible depth
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		matched, err := path.Match(pattern[0], name[0])
+		if err != nil || !matched {
+			return false
+		}
+
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+
+	return len(name) == 0
+}
+
 // CompressFile compresses a single file into a ZIP file.
 // This function will create a ZIP archive containing just the specified file.
 //
@@ -283,21 +950,43 @@ func (ufs *UFS) extractZipFile(file *zip.File, destPath string) error {
 //	    return
 //	}
 //	fmt.Println("File compressed successfully")
-func (ufs *UFS) CompressFile(sourcePath, destPath string) error {
+func (ufs *UFS) CompressFile(sourcePath, destPath string) (err error) {
+	defer ufs.recoverPanic("CompressFile", &err)
+
+	if err := ufs.requireOS("CompressFile"); err != nil {
+		return err
+	}
+
+	return ufs.compressFile(sourcePath, destPath, nil, "CompressFile")
+}
+
+// compressFile is the implementation shared by CompressFile and CompressFileWithOptions.
+// A nil opts uses the default compression settings.
+func (ufs *UFS) compressFile(sourcePath, destPath string, opts *CompressOptions, operation string) (err error) {
+	defer ufs.applyIOPriority()()
+
 	// Verify source is a file
 	if !ufs.IsFile(sourcePath) {
-		return fmt.Errorf("source path is not a file: %s", sourcePath)
+		return ufs.misuseError(operation, "source path is not a file", sourcePath)
 	}
 
 	// Get absolute paths to ensure consistent behavior
-	sourcePath, err := filepath.Abs(sourcePath)
+	sourcePath, err = filepath.Abs(sourcePath)
 	if err != nil {
-		return ufs.wrapError(err, "CompressFile")
+		return ufs.wrapError(err, operation)
 	}
 
+	destPath, err = ufs.expandOutputPath(destPath)
+	if err != nil {
+		return ufs.wrapError(err, operation)
+	}
 	destPath, err = filepath.Abs(destPath)
 	if err != nil {
-		return ufs.wrapError(err, "CompressFile")
+		return ufs.wrapError(err, operation)
+	}
+
+	if ufs.dryRun(operation, DryRunWrite, destPath, sourcePath) {
+		return nil
 	}
 
 	// Ensure destination directory exists
@@ -305,53 +994,63 @@ func (ufs *UFS) CompressFile(sourcePath, destPath string) error {
 	if !ufs.IsDirectory(destDir) {
 		err = os.MkdirAll(destDir, 0755)
 		if err != nil {
-			return ufs.wrapError(err, "CompressFile")
+			return ufs.wrapError(err, operation)
 		}
 	}
 
 	// Create zip file
 	zipFile, err := os.Create(destPath)
 	if err != nil {
-		return ufs.wrapError(err, "CompressFile")
+		return ufs.wrapError(err, operation)
 	}
 	defer zipFile.Close()
 
 	zipWriter := zip.NewWriter(zipFile)
 	defer zipWriter.Close()
+	opts.registerCompressor(zipWriter)
 
 	// Get file info
 	info, err := os.Stat(sourcePath)
 	if err != nil {
-		return ufs.wrapError(err, "CompressFile")
+		return ufs.wrapError(err, operation)
 	}
 
 	// Create a zip header
 	header, err := zip.FileInfoHeader(info)
 	if err != nil {
-		return ufs.wrapError(err, "CompressFile")
+		return ufs.wrapError(err, operation)
 	}
 
 	// Use the base file name as the name in the archive
 	header.Name = filepath.Base(sourcePath)
-	header.Method = zip.Deflate
+	header.Method = opts.methodFor(sourcePath)
 
 	// Create writer for the file header
 	writer, err := zipWriter.CreateHeader(header)
 	if err != nil {
-		return ufs.wrapError(err, "CompressFile")
+		return ufs.wrapError(err, operation)
 	}
 
 	// Open the file for reading
 	file, err := os.Open(sourcePath)
 	if err != nil {
-		return ufs.wrapError(err, "CompressFile")
+		return ufs.wrapError(err, operation)
 	}
 	defer file.Close()
 
 	// Copy file contents to the zip
 	_, err = io.Copy(writer, file)
 	if err != nil {
-		return ufs.wrapError(err, "CompressFile")
+		return ufs.wrapError(err, operation)
+	}
+
+	// Store the resource fork and Finder info when Options.PreserveMacMetadata is set
+	macEntry, err := ufs.macMetadataEntry(sourcePath, header.Name)
+	if err == nil && macEntry != nil {
+		err = writeMacMetadataEntries(zipWriter, []*zipMetadataEntry{macEntry})
+	}
+	if err != nil {
+		return ufs.wrapError(err, operation)
 	}
 
 	return nil
@@ -375,10 +1074,16 @@ func (ufs *UFS) CompressFile(sourcePath, destPath string) error {
 //	    return
 //	}
 //	fmt.Printf("Directory compressed to: %s\n", zipPath)
-func (ufs *UFS) CompressHere(sourcePath string) (string, error) {
+func (ufs *UFS) CompressHere(sourcePath string) (_ string, err error) {
+	defer ufs.recoverPanic("CompressHere", &err)
+
+	if err := ufs.requireOS("CompressHere"); err != nil {
+		return "", err
+	}
+
 	// Verify source is a directory
 	if !ufs.IsDirectory(sourcePath) {
-		return "", fmt.Errorf("source path is not a directory: %s", sourcePath)
+		return "", ufs.misuseError("CompressHere", "source path is not a directory", sourcePath)
 	}
 
 	// Get current working directory
@@ -418,10 +1123,16 @@ func (ufs *UFS) CompressHere(sourcePath string) (string, error) {
 //	    return
 //	}
 //	fmt.Printf("Archive extracted to: %s\n", extractPath)
-func (ufs *UFS) ExtractHere(sourcePath string) (string, error) {
+func (ufs *UFS) ExtractHere(sourcePath string) (_ string, err error) {
+	defer ufs.recoverPanic("ExtractHere", &err)
+
+	if err := ufs.requireOS("ExtractHere"); err != nil {
+		return "", err
+	}
+
 	// Verify source is a file
 	if !ufs.IsFile(sourcePath) {
-		return "", fmt.Errorf("source path is not a file: %s", sourcePath)
+		return "", ufs.misuseError("ExtractHere", "source path is not a file", sourcePath)
 	}
 
 	// Get current working directory
@@ -462,10 +1173,16 @@ func (ufs *UFS) ExtractHere(sourcePath string) (string, error) {
 //	    return
 //	}
 //	fmt.Printf("File compressed to: %s\n", zipPath)
-func (ufs *UFS) CompressFileHere(sourcePath string) (string, error) {
+func (ufs *UFS) CompressFileHere(sourcePath string) (_ string, err error) {
+	defer ufs.recoverPanic("CompressFileHere", &err)
+
+	if err := ufs.requireOS("CompressFileHere"); err != nil {
+		return "", err
+	}
+
 	// Verify source is a file
 	if !ufs.IsFile(sourcePath) {
-		return "", fmt.Errorf("source path is not a file: %s", sourcePath)
+		return "", ufs.misuseError("CompressFileHere", "source path is not a file", sourcePath)
 	}
 
 	// Get current working directory
@@ -506,14 +1223,23 @@ func (ufs *UFS) CompressFileHere(sourcePath string) (string, error) {
 //	    return
 //	}
 //	fmt.Println("Directory compressed and removed successfully")
-func (ufs *UFS) CompressAndRemove(sourcePath, destPath string) error {
+func (ufs *UFS) CompressAndRemove(sourcePath, destPath string) (err error) {
+	defer ufs.recoverPanic("CompressAndRemove", &err)
+
+	if err := ufs.requireOS("CompressAndRemove"); err != nil {
+		return err
+	}
+
 	// First compress the directory
-	err := ufs.CompressDirectory(sourcePath, destPath)
+	err = ufs.CompressDirectory(sourcePath, destPath)
 	if err != nil {
 		return err
 	}
 
 	// Then remove the directory
+	if ufs.dryRun("CompressAndRemove", DryRunRemoveAll, sourcePath, "") {
+		return nil
+	}
 	err = os.RemoveAll(sourcePath)
 	if err != nil {
 		return ufs.wrapError(err, "CompressAndRemove")
@@ -541,9 +1267,15 @@ func (ufs *UFS) CompressAndRemove(sourcePath, destPath string) error {
 //	    return
 //	}
 //	fmt.Println("Archive extracted and removed successfully")
-func (ufs *UFS) ExtractAndRemove(sourcePath, destPath string) error {
+func (ufs *UFS) ExtractAndRemove(sourcePath, destPath string) (err error) {
+	defer ufs.recoverPanic("ExtractAndRemove", &err)
+
+	if err := ufs.requireOS("ExtractAndRemove"); err != nil {
+		return err
+	}
+
 	// First extract the archive
-	err := ufs.ExtractArchive(sourcePath, destPath)
+	err = ufs.ExtractArchive(sourcePath, destPath)
 	if err != nil {
 		return err
 	}
@@ -577,14 +1309,24 @@ func (ufs *UFS) ExtractAndRemove(sourcePath, destPath string) error {
 //	    return
 //	}
 //	fmt.Println("Directory compressed and extracted successfully")
-func (ufs *UFS) CompressAndExtract(sourcePath, tempPath, finalPath string) error {
+func (ufs *UFS) CompressAndExtract(sourcePath, tempPath, finalPath string) (err error) {
+	defer ufs.recoverPanic("CompressAndExtract", &err)
+
+	if err := ufs.requireOS("CompressAndExtract"); err != nil {
+		return err
+	}
+
 	// First compress the directory
-	err := ufs.CompressDirectory(sourcePath, tempPath)
+	err = ufs.CompressDirectory(sourcePath, tempPath)
 	if err != nil {
 		return err
 	}
 
-	// Then extract it to the final path
+	// Then extract it to the final path, the archive doesn't exist in dry-run mode
+	if ufs.dryRun("CompressAndExtract", DryRunExtract, finalPath, tempPath) {
+		ufs.dryRun("CompressAndExtract", DryRunRemove, tempPath, "")
+		return nil
+	}
 	err = ufs.ExtractArchive(tempPath, finalPath)
 	if err != nil {
 		return err
@@ -619,9 +1361,15 @@ func (ufs *UFS) CompressAndExtract(sourcePath, tempPath, finalPath string) error
 //	    return
 //	}
 //	fmt.Println("Archive extracted and compressed successfully")
-func (ufs *UFS) ExtractAndCompress(sourcePath, tempPath, finalPath string) error {
+func (ufs *UFS) ExtractAndCompress(sourcePath, tempPath, finalPath string) (err error) {
+	defer ufs.recoverPanic("ExtractAndCompress", &err)
+
+	if err := ufs.requireOS("ExtractAndCompress"); err != nil {
+		return err
+	}
+
 	// First extract the archive
-	err := ufs.ExtractArchive(sourcePath, tempPath)
+	err = ufs.ExtractArchive(sourcePath, tempPath)
 	if err != nil {
 		return err
 	}
@@ -661,30 +1409,35 @@ func (ufs *UFS) ExtractAndCompress(sourcePath, tempPath, finalPath string) error
 //	    return
 //	}
 //	fmt.Println("Directory compressed successfully using system command")
-func (ufs *UFS) CompressWithSystemCommand(sourcePath, destPath, format string) error {
+func (ufs *UFS) CompressWithSystemCommand(sourcePath, destPath, format string) (err error) {
+	defer ufs.recoverPanic("CompressWithSystemCommand", &err)
+
+	if err := ufs.requireOS("CompressWithSystemCommand"); err != nil {
+		return err
+	}
+
+	if err := ufs.requireCapability(CapabilityProcesses, "CompressWithSystemCommand", sourcePath); err != nil {
+		return err
+	}
+
 	// Verify source is a directory
 	if !ufs.IsDirectory(sourcePath) {
-		return fmt.Errorf("source path is not a directory: %s", sourcePath)
+		return ufs.misuseError("CompressWithSystemCommand", "source path is not a directory", sourcePath)
 	}
 
 	// Get absolute paths to ensure consistent behavior
-	sourcePath, err := filepath.Abs(sourcePath)
+	sourcePath, err = filepath.Abs(sourcePath)
 	if err != nil {
 		return ufs.wrapError(err, "CompressWithSystemCommand")
 	}
 
-	destPath, err = filepath.Abs(destPath)
+	destPath, err = ufs.expandOutputPath(destPath)
 	if err != nil {
 		return ufs.wrapError(err, "CompressWithSystemCommand")
 	}
-
-	// Ensure destination directory exists
-	destDir := filepath.Dir(destPath)
-	if !ufs.IsDirectory(destDir) {
-		err = os.MkdirAll(destDir, 0755)
-		if err != nil {
-			return ufs.wrapError(err, "CompressWithSystemCommand")
-		}
+	destPath, err = filepath.Abs(destPath)
+	if err != nil {
+		return ufs.wrapError(err, "CompressWithSystemCommand")
 	}
 
 	// Set compression flag based on format
@@ -709,6 +1462,19 @@ func (ufs *UFS) CompressWithSystemCommand(sourcePath, destPath, format string) e
 		return fmt.Errorf("unsupported compression format: %s", format)
 	}
 
+	if ufs.dryRun("CompressWithSystemCommand", DryRunWrite, destPath, sourcePath) {
+		return nil
+	}
+
+	// Ensure destination directory exists
+	destDir := filepath.Dir(destPath)
+	if !ufs.IsDirectory(destDir) {
+		err = os.MkdirAll(destDir, 0755)
+		if err != nil {
+			return ufs.wrapError(err, "CompressWithSystemCommand")
+		}
+	}
+
 	var cmd *exec.Cmd
 	sourceDir := filepath.Base(sourcePath)
 	parentDir := filepath.Dir(sourcePath)
@@ -752,14 +1518,24 @@ func (ufs *UFS) CompressWithSystemCommand(sourcePath, destPath, format string) e
 //	    return
 //	}
 //	fmt.Println("Archive extracted successfully using system command")
-func (ufs *UFS) ExtractWithSystemCommand(sourcePath, destPath string) error {
+func (ufs *UFS) ExtractWithSystemCommand(sourcePath, destPath string) (err error) {
+	defer ufs.recoverPanic("ExtractWithSystemCommand", &err)
+
+	if err := ufs.requireOS("ExtractWithSystemCommand"); err != nil {
+		return err
+	}
+
+	if err := ufs.requireCapability(CapabilityProcesses, "ExtractWithSystemCommand", sourcePath); err != nil {
+		return err
+	}
+
 	// Verify source is a file
 	if !ufs.IsFile(sourcePath) {
-		return fmt.Errorf("source path is not a file: %s", sourcePath)
+		return ufs.misuseError("ExtractWithSystemCommand", "source path is not a file", sourcePath)
 	}
 
 	// Get absolute paths to ensure consistent behavior
-	sourcePath, err := filepath.Abs(sourcePath)
+	sourcePath, err = filepath.Abs(sourcePath)
 	if err != nil {
 		return ufs.wrapError(err, "ExtractWithSystemCommand")
 	}
@@ -793,7 +1569,8 @@ func (ufs *UFS) ExtractWithSystemCommand(sourcePath, destPath string) error {
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("extraction failed: %v, output: %s", err, output)
+		err = fmt.Errorf("extraction failed: %v, output: %s", err, output)
+		return classifyToolFailure(sourcePath, output, "", err)
 	}
 
 	return nil
@@ -0,0 +1,422 @@
+package ufs
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*
+Compress-checkpoint.go contains a resumable variant of CompressDirectory for long archive jobs.
+
+CompressDirectoryCheckpointed writes the archive like CompressDirectory and regularly records in a
+JSON checkpoint file the entries completely written so far and the size of the archive after them.
+When the compression is interrupted (error, cancellation by the shutdown manager, killed process),
+the partial archive and the checkpoint are kept, and calling CompressDirectoryCheckpointed again
+with the same paths resumes after the last recorded entry instead of starting over:
+
+	nightly.zip             (partial archive)
+	nightly.zip.checkpoint  (entries written, archive size)
+
+On resume the archive is truncated to the recorded size, the recorded entries are replayed
+without being read or compressed again (only their headers are needed to write the central
+directory), and the remaining files are compressed. The compression starts over when the
+checkpoint doesn't match anymore: different source or archive, files recorded as written that
+changed or disappeared since, or an archive shorter than recorded.
+
+The checkpoint is removed once the archive is complete.
+
+Functions:
+- CompressDirectoryCheckpointed: Compresses a directory into a ZIP file, resuming an interrupted run.
+*/
+
+// compressCheckpointVersion is the version of the checkpoint format written by CompressDirectoryCheckpointed
+const compressCheckpointVersion = 1
+
+// zipLocalHeaderLen is the size of the fixed part of a zip local file header, before the name and extra field
+const zipLocalHeaderLen = 30
+
+// compressCheckpointInterval is the longest time between two checkpoints, each one syncs the archive to disk
+const compressCheckpointInterval = 10 * time.Second
+
+// compressCheckpoint is the content of a checkpoint file
+type compressCheckpoint struct {
+	Version int               `json:"version"`
+	Source  string            `json:"source"`
+	Archive string            `json:"archive"`
+	Offset  int64             `json:"offset"`  // Size of the archive after the recorded entries
+	Entries []checkpointEntry `json:"entries"` // Entries completely written, in archive order
+}
+
+// checkpointEntry is an entry written to the archive, with the state of its file at that time
+type checkpointEntry struct {
+	Header  zip.FileHeader `json:"header"`
+	Size    int64          `json:"size"`
+	ModTime time.Time      `json:"modTime"`
+}
+
+// checkpointFile is a file or directory of the source, in the order it is archived
+type checkpointFile struct {
+	path string
+	info os.FileInfo
+	name string // Slash separated name in the archive, "/" terminated for directories
+}
+
+// CompressDirectoryCheckpointed compresses a directory into a ZIP file like CompressDirectory, recording
+// its progress in a checkpoint file so an interrupted compression resumes where it stopped.
+// The partial archive is kept when the compression fails, the checkpoint is removed when it succeeds.
+// Files are compressed sequentially, Options.CompressionWorkers is ignored.
+//
+// Parameters:
+//   - sourcePath: The absolute or relative path to the directory to compress
+//   - destPath: The absolute or relative path where the ZIP file will be created
+//   - checkpointPath: The path of the checkpoint file, e.g. destPath + ".checkpoint"
+//
+// Returns:
+//   - error: An error if the compression failed, nil otherwise
+//
+// Example:
+//
+//	err := ufs.CompressDirectoryCheckpointed("/srv/data", "/backups/nightly.zip", "/backups/nightly.zip.checkpoint")
+//	if err != nil {
+//	    fmt.Printf("Compression interrupted, run again to resume: %v\n", err)
+//	    return
+//	}
+func (ufs *UFS) CompressDirectoryCheckpointed(sourcePath, destPath, checkpointPath string) (err error) {
+	defer ufs.recoverPanic("CompressDirectoryCheckpointed", &err)
+
+	if err := ufs.requireOS("CompressDirectoryCheckpointed"); err != nil {
+		return err
+	}
+	defer ufs.applyIOPriority()()
+
+	// Verify source is a directory
+	if !ufs.IsDirectory(sourcePath) {
+		return ufs.misuseError("CompressDirectoryCheckpointed", "source path is not a directory", sourcePath)
+	}
+
+	sourcePath, err = filepath.Abs(sourcePath)
+	if err != nil {
+		return ufs.wrapError(err, "CompressDirectoryCheckpointed")
+	}
+	destPath, err = ufs.expandOutputPath(destPath)
+	if err != nil {
+		return ufs.wrapError(err, "CompressDirectoryCheckpointed")
+	}
+	destPath, err = filepath.Abs(destPath)
+	if err != nil {
+		return ufs.wrapError(err, "CompressDirectoryCheckpointed")
+	}
+	checkpointPath, err = filepath.Abs(checkpointPath)
+	if err != nil {
+		return ufs.wrapError(err, "CompressDirectoryCheckpointed")
+	}
+
+	if ufs.dryRun("CompressDirectoryCheckpointed", DryRunWrite, destPath, sourcePath) {
+		return nil
+	}
+
+	// Cancelled by the shutdown manager of the instance, if any
+	ctx, done, err := ufs.trackOperation(context.Background())
+	if err != nil {
+		return ufs.wrapError(err, "CompressDirectoryCheckpointed")
+	}
+	defer done()
+
+	files, err := ufs.checkpointFiles(ctx, sourcePath, destPath, checkpointPath)
+	if err != nil {
+		return ufs.wrapError(err, "CompressDirectoryCheckpointed")
+	}
+
+	// Ensure destination directories exist
+	for _, dir := range []string{filepath.Dir(destPath), filepath.Dir(checkpointPath)} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return ufs.wrapError(err, "CompressDirectoryCheckpointed")
+		}
+	}
+
+	checkpoint := loadCompressCheckpoint(checkpointPath, sourcePath, destPath, files)
+	err = ufs.writeCheckpointedZip(ctx, files, destPath, checkpointPath, checkpoint)
+	if err == errCheckpointMismatch {
+		// The archive doesn't end like the checkpoint says, start over
+		err = ufs.writeCheckpointedZip(ctx, files, destPath, checkpointPath, newCompressCheckpoint(sourcePath, destPath))
+	}
+	if err != nil {
+		return ufs.wrapError(err, "CompressDirectoryCheckpointed")
+	}
+
+	if err := os.Remove(checkpointPath); err != nil && !os.IsNotExist(err) {
+		return ufs.wrapError(err, "CompressDirectoryCheckpointed")
+	}
+	return nil
+}
+
+// errCheckpointMismatch is returned when the replayed entries don't end at the recorded archive size
+var errCheckpointMismatch = errors.New("archive doesn't match its checkpoint")
+
+// checkpointFiles lists the files and directories of sourcePath in archive order,
+// leaving out the archive, the checkpoint and the hidden entries when Options.ExcludeHidden is set
+func (ufs *UFS) checkpointFiles(ctx context.Context, sourcePath, destPath, checkpointPath string) ([]checkpointFile, error) {
+	var files []checkpointFile
+	err := filepath.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkAbort, "CompressDirectoryCheckpointed")
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if path == sourcePath || path == destPath || path == checkpointPath {
+			return nil
+		}
+		if ufs.skipHidden(fs.FileInfoToDirEntry(info)) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		relPath, err := filepath.Rel(sourcePath, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(relPath)
+		if info.IsDir() {
+			name += "/"
+		}
+		files = append(files, checkpointFile{path: path, info: info, name: name})
+		return nil
+	})
+	return files, err
+}
+
+// newCompressCheckpoint returns the checkpoint of an archive with no entry written yet
+func newCompressCheckpoint(sourcePath, destPath string) *compressCheckpoint {
+	return &compressCheckpoint{Version: compressCheckpointVersion, Source: sourcePath, Archive: destPath}
+}
+
+// loadCompressCheckpoint reads the checkpoint at path. A new checkpoint is returned when there is none,
+// or when it doesn't describe the start of the current compression of files.
+func loadCompressCheckpoint(path, sourcePath, destPath string, files []checkpointFile) *compressCheckpoint {
+	fresh := newCompressCheckpoint(sourcePath, destPath)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fresh
+	}
+	var checkpoint compressCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return fresh
+	}
+	if checkpoint.Version != compressCheckpointVersion || checkpoint.Source != sourcePath ||
+		checkpoint.Archive != destPath || len(checkpoint.Entries) > len(files) {
+		return fresh
+	}
+
+	// The archive must still hold the recorded entries
+	info, err := os.Stat(destPath)
+	if err != nil || info.Size() < checkpoint.Offset {
+		return fresh
+	}
+
+	// The recorded entries must be the first files, unchanged since they were written
+	for i, entry := range checkpoint.Entries {
+		file := files[i]
+		if entry.Header.Name != file.name {
+			return fresh
+		}
+		if !file.info.IsDir() && (entry.Size != file.info.Size() || !entry.ModTime.Equal(file.info.ModTime())) {
+			return fresh
+		}
+	}
+	return &checkpoint
+}
+
+// save writes the checkpoint to path, replacing the previous one atomically
+func (checkpoint *compressCheckpoint) save(path string) error {
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+// writeCheckpointedZip writes the files after the entries of the checkpoint to the archive,
+// saving the checkpoint as it goes. errCheckpointMismatch is returned, before anything is written,
+// when the recorded entries can't be replayed.
+func (ufs *UFS) writeCheckpointedZip(ctx context.Context, files []checkpointFile, destPath, checkpointPath string, checkpoint *compressCheckpoint) (err error) {
+	archive, err := os.OpenFile(destPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	// Drop whatever was written after the recorded entries, the central directory of a finished run included
+	if err := archive.Truncate(checkpoint.Offset); err != nil {
+		return err
+	}
+	if _, err := archive.Seek(checkpoint.Offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	writer := &checkpointWriter{file: archive, skip: checkpoint.Offset}
+	zipWriter := zip.NewWriter(writer)
+
+	// Replay the recorded entries, their bytes are already in the archive and are discarded
+	for i := range checkpoint.Entries {
+		header := checkpoint.Entries[i].Header
+		entryWriter, err := zipWriter.CreateRaw(&header)
+		if err != nil {
+			return err
+		}
+		if err := writeZeros(entryWriter, int64(header.CompressedSize64)); err != nil {
+			return err
+		}
+	}
+	if err := zipWriter.Flush(); err != nil {
+		return err
+	}
+	// The data descriptor of the last replayed entry is only written when the next entry is created,
+	// the recorded size includes it
+	replayed := writer.count
+	if n := len(checkpoint.Entries); n > 0 {
+		replayed += zipDataDescriptorLen(&checkpoint.Entries[n-1].Header)
+	}
+	if replayed != checkpoint.Offset {
+		return errCheckpointMismatch
+	}
+
+	// Keep the last checkpoint when the compression stops, the next run resumes from it
+	lastSave := time.Now()
+	var pending []checkpointEntry
+	var pendingOffset int64 // Size of the archive after the pending entries
+	saveCheckpoint := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		if err := archive.Sync(); err != nil {
+			return err
+		}
+		checkpoint.Entries = append(checkpoint.Entries, pending...)
+		checkpoint.Offset = pendingOffset
+		pending = nil
+		lastSave = time.Now()
+		return checkpoint.save(checkpointPath)
+	}
+	defer func() {
+		if err != nil {
+			saveCheckpoint()
+		}
+	}()
+
+	var previous *checkpointFile
+	var previousHeader *zip.FileHeader
+	for i := len(checkpoint.Entries); i < len(files); i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		file := files[i]
+
+		header, err := zip.FileInfoHeader(file.info)
+		if err != nil {
+			return err
+		}
+		header.Name = file.name
+		header.Method = zip.Deflate
+
+		// Creating the entry completes the previous one, which can then be recorded
+		entryWriter, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if err := zipWriter.Flush(); err != nil {
+			return err
+		}
+		if previous != nil {
+			pending = append(pending, checkpointEntry{Header: *previousHeader, Size: previous.info.Size(), ModTime: previous.info.ModTime()})
+			pendingOffset = writer.count - int64(zipLocalHeaderLen+len(header.Name)+len(header.Extra))
+			if time.Since(lastSave) >= compressCheckpointInterval {
+				if err := saveCheckpoint(); err != nil {
+					return err
+				}
+			}
+		}
+		previous, previousHeader = &files[i], header
+
+		if file.info.IsDir() {
+			continue
+		}
+		if err := copyFileToZip(ctx, entryWriter, file.path); err != nil {
+			return err
+		}
+	}
+
+	return zipWriter.Close()
+}
+
+// copyFileToZip copies the content of a file to an archive entry, aborting when ctx is done
+func copyFileToZip(ctx context.Context, entryWriter io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(entryWriter, &contextReader{ctx: ctx, reader: file})
+	return err
+}
+
+// zipDataDescriptorLen returns the size of the data descriptor archive/zip writes after the data of an entry,
+// 0 for directories and entries without one
+func zipDataDescriptorLen(header *zip.FileHeader) int64 {
+	if strings.HasSuffix(header.Name, "/") || header.Flags&0x8 == 0 {
+		return 0
+	}
+	if header.CompressedSize64 > math.MaxUint32 || header.UncompressedSize64 > math.MaxUint32 {
+		return 24
+	}
+	return 16
+}
+
+// writeZeros writes n zero bytes to w
+func writeZeros(w io.Writer, n int64) error {
+	zeros := make([]byte, 32*1024)
+	for n > 0 {
+		chunk := min(n, int64(len(zeros)))
+		if _, err := w.Write(zeros[:chunk]); err != nil {
+			return err
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+// checkpointWriter writes an archive resumed after skip bytes: the first skip bytes, replayed from the
+// checkpoint, are already in the file and are discarded. count is the size of the archive written so far.
+type checkpointWriter struct {
+	file  *os.File
+	skip  int64
+	count int64
+}
+
+func (w *checkpointWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	if discard := w.skip - w.count; discard > 0 {
+		discard = min(discard, int64(len(p)))
+		w.count += discard
+		p = p[discard:]
+	}
+	n, err := w.file.Write(p)
+	w.count += int64(n)
+	if err != nil {
+		return written - len(p) + n, err
+	}
+	return written, nil
+}
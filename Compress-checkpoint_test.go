@@ -0,0 +1,111 @@
+package ufs
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompressDirectoryCheckpointedResumes(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source")
+	archivePath := filepath.Join(dir, "out.zip")
+	checkpointPath := archivePath + ".checkpoint"
+
+	contents := map[string]string{}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt"} {
+		content := strings.Repeat(name+" some content to compress\n", 200)
+		contents[name] = content
+		if err := os.MkdirAll(source, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(source, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ufs := NewUfs(NewOptions())
+	files, err := ufs.checkpointFiles(context.Background(), source, archivePath, checkpointPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Interrupt the first run on the fourth file, removed since the listing: the first three entries are recorded
+	missing := filepath.Join(source, "d.txt")
+	if err := os.Remove(missing); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint := newCompressCheckpoint(source, archivePath)
+	if err := ufs.writeCheckpointedZip(context.Background(), files, archivePath, checkpointPath, checkpoint); err == nil {
+		t.Fatal("interrupted run succeeded")
+	}
+	saved := loadCompressCheckpoint(checkpointPath, source, archivePath, files)
+	if len(saved.Entries) != 3 {
+		t.Fatalf("checkpoint records %d entries, want 3", len(saved.Entries))
+	}
+	if err := os.WriteFile(missing, []byte(contents["d.txt"]), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the data of the first recorded entry: a resumed run keeps it, a restarted one rewrites it
+	first := saved.Entries[0].Header
+	dataOffset := int64(zipLocalHeaderLen + len(first.Name) + len(first.Extra))
+	archive, err := os.OpenFile(archivePath, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupted := []byte{0}
+	if _, err := archive.ReadAt(corrupted, dataOffset); err != nil {
+		t.Fatal(err)
+	}
+	corrupted[0] ^= 0xff
+	if _, err := archive.WriteAt(corrupted, dataOffset); err != nil {
+		t.Fatal(err)
+	}
+	archive.Close()
+
+	if err := ufs.CompressDirectoryCheckpointed(source, archivePath, checkpointPath); err != nil {
+		t.Fatalf("resumed run: %v", err)
+	}
+	if _, err := os.Stat(checkpointPath); !os.IsNotExist(err) {
+		t.Errorf("checkpoint not removed after the resumed run: %v", err)
+	}
+
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[dataOffset] != corrupted[0] {
+		t.Fatalf("the recorded entry was written again, the compression restarted instead of resuming")
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reader.File) != len(contents) {
+		t.Fatalf("archive has %d entries, want %d", len(reader.File), len(contents))
+	}
+	for _, file := range reader.File {
+		if file.Name == first.Name {
+			continue
+		}
+		entry, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(entry)
+		entry.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", file.Name, err)
+		}
+		if string(content) != contents[file.Name] {
+			t.Errorf("%s: unexpected content", file.Name)
+		}
+	}
+}
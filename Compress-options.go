@@ -0,0 +1,194 @@
+package ufs
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Compress-options.go contains the settings used to control how files are compressed into ZIP archives.
+
+By default every file is compressed with Deflate at level 5 (the archive/zip default).
+CompressOptions allows to change the level, to store files without compression,
+or to store only the files that are already compressed (images, videos, archives, ...),
+which saves a lot of CPU time for almost no size difference.
+
+Directory entries are stored bare by default (relative to the source directory);
+IncludeRootFolder stores them under the source directory's name instead.
+
+Functions:
+- CompressDirectoryWithOptions: CompressDirectory with custom compression settings.
+- CompressFileWithOptions: CompressFile with custom compression settings.
+*/
+
+// CompressionMethod is the method used to write files into a ZIP archive.
+type CompressionMethod int
+
+const (
+	// CompressDeflate compresses files with Deflate (default)
+	CompressDeflate CompressionMethod = iota
+	// CompressStore writes files without compression
+	CompressStore
+)
+
+// defaultCompressionLevel is the Deflate level used by archive/zip
+const defaultCompressionLevel = 5
+
+// DefaultStoreExtensions lists extensions of formats that are already compressed.
+// It can be used as CompressOptions.StoreExtensions.
+var DefaultStoreExtensions = []string{
+	".zip", ".gz", ".tgz", ".bz2", ".xz", ".zst", ".7z", ".rar",
+	".jpg", ".jpeg", ".png", ".gif", ".webp", ".heic",
+	".mp3", ".aac", ".ogg", ".flac",
+	".mp4", ".mkv", ".avi", ".mov", ".webm",
+	".docx", ".xlsx", ".pptx", ".jar", ".apk",
+}
+
+// CompressOptions controls how files are written into ZIP archives.
+// The zero value compresses every file with Deflate at the default level.
+type CompressOptions struct {
+	// Level is the Deflate level, from 1 (fastest) to 9 (smallest).
+	// 0 uses the default level (5); use Method CompressStore to disable compression.
+	Level int
+
+	// Method is the compression method used for all files
+	Method CompressionMethod
+
+	// StoreExtensions lists file extensions (like ".jpg") that are stored without compression
+	// even when Method is CompressDeflate. The comparison is case-insensitive.
+	StoreExtensions []string
+
+	// IncludeRootFolder stores the entries under the source directory's name ("project/src/main.go")
+	// instead of bare ("src/main.go"), so extracting the archive creates a single folder.
+	// Only used when compressing a directory.
+	IncludeRootFolder bool
+}
+
+// CompressDirectoryWithOptions compresses a directory into a ZIP file like CompressDirectory,
+// using the given compression settings.
+//
+// Parameters:
+//   - sourcePath: The absolute or relative path to the directory to compress
+//   - destPath: The absolute or relative path where the ZIP file will be created
+//   - opts: The compression settings, nil uses the defaults
+//
+// Returns:
+//   - error: An error if the compression failed or the level is invalid, nil otherwise
+//
+// Example:
+//
+//	err := ufs.CompressDirectoryWithOptions("/path/to/photos", "/path/to/photos.zip", &ufs.CompressOptions{
+//	    Level:             9,
+//	    StoreExtensions:   ufs.DefaultStoreExtensions,
+//	    IncludeRootFolder: true, // entries are stored as "photos/..."
+//	})
+//	if err != nil {
+//	    fmt.Printf("Error compressing directory: %v\n", err)
+//	}
+func (ufs *UFS) CompressDirectoryWithOptions(sourcePath, destPath string, opts *CompressOptions) (err error) {
+	defer ufs.recoverPanic("CompressDirectoryWithOptions", &err)
+
+	if err := ufs.requireOS("CompressDirectoryWithOptions"); err != nil {
+		return err
+	}
+
+	if err := opts.validate(); err != nil {
+		return ufs.wrapError(err, "CompressDirectoryWithOptions")
+	}
+
+	return ufs.compressDirectory(context.Background(), sourcePath, destPath, opts, "CompressDirectoryWithOptions")
+}
+
+// CompressFileWithOptions compresses a single file into a ZIP file like CompressFile,
+// using the given compression settings.
+//
+// Parameters:
+//   - sourcePath: The absolute or relative path to the file to compress
+//   - destPath: The absolute or relative path where the ZIP file will be created
+//   - opts: The compression settings, nil uses the defaults
+//
+// Returns:
+//   - error: An error if the compression failed or the level is invalid, nil otherwise
+//
+// Example:
+//
+//	err := ufs.CompressFileWithOptions("/path/to/video.mp4", "/path/to/video.zip", &ufs.CompressOptions{
+//	    Method: ufs.CompressStore,
+//	})
+//	if err != nil {
+//	    fmt.Printf("Error compressing file: %v\n", err)
+//	}
+func (ufs *UFS) CompressFileWithOptions(sourcePath, destPath string, opts *CompressOptions) (err error) {
+	defer ufs.recoverPanic("CompressFileWithOptions", &err)
+
+	if err := ufs.requireOS("CompressFileWithOptions"); err != nil {
+		return err
+	}
+
+	if err := opts.validate(); err != nil {
+		return ufs.wrapError(err, "CompressFileWithOptions")
+	}
+
+	return ufs.compressFile(sourcePath, destPath, opts, "CompressFileWithOptions")
+}
+
+// validate checks the compression level
+func (opts *CompressOptions) validate() error {
+	if opts == nil {
+		return nil
+	}
+	if opts.Level < 0 || opts.Level > 9 {
+		return fmt.Errorf("invalid compression level %d, expected 0 to 9", opts.Level)
+	}
+	if opts.Method != CompressDeflate && opts.Method != CompressStore {
+		return fmt.Errorf("invalid compression method %d", opts.Method)
+	}
+	return nil
+}
+
+// level returns the Deflate level to use
+func (opts *CompressOptions) level() int {
+	if opts == nil || opts.Level == 0 {
+		return defaultCompressionLevel
+	}
+	return opts.Level
+}
+
+// methodFor returns the zip method to use for the file at path
+func (opts *CompressOptions) methodFor(path string) uint16 {
+	if opts == nil {
+		return zip.Deflate
+	}
+	if opts.Method == CompressStore {
+		return zip.Store
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, stored := range opts.StoreExtensions {
+		if ext != "" && strings.ToLower(stored) == ext {
+			return zip.Store
+		}
+	}
+	return zip.Deflate
+}
+
+// includeRootFolder reports whether entries are stored under the source directory's name
+func (opts *CompressOptions) includeRootFolder() bool {
+	return opts != nil && opts.IncludeRootFolder
+}
+
+// registerCompressor makes the zip writer use the configured Deflate level
+func (opts *CompressOptions) registerCompressor(zipWriter *zip.Writer) {
+	level := opts.level()
+	if level == defaultCompressionLevel {
+		return
+	}
+	zipWriter.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, level)
+	})
+}
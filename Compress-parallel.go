@@ -0,0 +1,296 @@
+package ufs
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"unicode/utf8"
+)
+
+/*
+Compress-parallel.go contains the parallel mode used by CompressDirectory.
+
+Deflate is CPU-bound, so when Options.CompressionWorkers is greater than 1 the file
+payloads are compressed concurrently by a pool of workers into memory buffers.
+The compressed entries are then written to the archive in the same order as the
+sequential mode, so both modes produce equivalent archives.
+
+At most 2 payloads per worker wait to be written at any time. A payload growing past
+parallelSpillSize continues in a temporary file, so the memory held stays under
+2 * workers * parallelSpillSize whatever the size of the files.
+*/
+
+// parallelSpillSize is the compressed size from which a payload is moved from memory to a temporary file
+const parallelSpillSize = 8 << 20
+
+// compressEntry is a file or directory waiting to be written to a zip archive
+type compressEntry struct {
+	path   string
+	header *zip.FileHeader
+	isDir  bool
+}
+
+// compressedPayload is the compressed (or stored) content of a file, ready to be written raw to a zip archive
+type compressedPayload struct {
+	data *spillBuffer
+	crc  uint32
+	size uint64
+	err  error
+}
+
+// compressionWorkers returns the number of workers to use, 1 means sequential compression
+func (ufs *UFS) compressionWorkers() int {
+	if ufs.opts.CompressionWorkers < 1 {
+		return 1
+	}
+	return ufs.opts.CompressionWorkers
+}
+
+// writeEntriesParallel compresses the entries with a pool of workers and writes them in order, reporting
+// the panics of the workers as those of operation
+func (ufs *UFS) writeEntriesParallel(ctx context.Context, zipWriter *zip.Writer, entries []compressEntry, workers int, level int, operation string) error {
+	ctx, cancel := context.WithCancel(ctx)
+
+	results := make([]chan compressedPayload, len(entries))
+	for i := range results {
+		results[i] = make(chan compressedPayload, 1)
+	}
+
+	// The window bounds how many compressed payloads wait in memory
+	window := make(chan struct{}, workers*2)
+	jobs := make(chan int)
+
+	go func() {
+		defer close(jobs)
+		for i := range entries {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer ufs.applyIOPriority()()
+
+			for i := range jobs {
+				var payload compressedPayload
+				err := ufs.recoverWorker(operation, func() error {
+					payload = deflateEntry(ctx, entries[i], level)
+					return nil
+				})
+				if err != nil {
+					payload = compressedPayload{err: err}
+				}
+				results[i] <- payload
+			}
+		}()
+	}
+
+	// Stop the workers before returning, whatever happened, and drop the payloads not written
+	defer func() {
+		cancel()
+		wg.Wait()
+		for _, result := range results {
+			select {
+			case payload := <-result:
+				payload.data.discard()
+			default:
+			}
+		}
+	}()
+
+	for i, entry := range entries {
+		var payload compressedPayload
+		select {
+		case payload = <-results[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		<-window
+
+		if payload.err != nil {
+			return payload.err
+		}
+
+		if entry.isDir {
+			if _, err := zipWriter.CreateHeader(entry.header); err != nil {
+				return err
+			}
+			continue
+		}
+
+		header := entry.header
+		header.CRC32 = payload.crc
+		header.UncompressedSize64 = payload.size
+		header.CompressedSize64 = uint64(payload.data.size)
+		prepareRawHeader(header)
+
+		writer, err := zipWriter.CreateRaw(header)
+		if err == nil {
+			err = payload.data.writeTo(writer)
+		}
+		payload.data.discard()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deflateEntry reads a single file into memory, or a temporary file past parallelSpillSize,
+// deflated at the given level unless the entry uses the Store method
+func deflateEntry(ctx context.Context, entry compressEntry, level int) (payload compressedPayload) {
+	if entry.isDir {
+		return compressedPayload{}
+	}
+
+	file, err := os.Open(entry.path)
+	if err != nil {
+		return compressedPayload{err: err}
+	}
+	defer file.Close()
+
+	buffer := &spillBuffer{}
+	defer func() {
+		if payload.err != nil {
+			buffer.discard()
+		}
+	}()
+
+	var compressor io.WriteCloser = nopWriteCloser{buffer}
+	if entry.header.Method == zip.Deflate {
+		compressor, err = flate.NewWriter(buffer, level)
+		if err != nil {
+			return compressedPayload{err: err}
+		}
+	}
+
+	hasher := crc32.NewIEEE()
+	size, err := io.Copy(io.MultiWriter(compressor, hasher), &contextReader{ctx: ctx, reader: file})
+	if err != nil {
+		return compressedPayload{err: err}
+	}
+	if err := compressor.Close(); err != nil {
+		return compressedPayload{err: err}
+	}
+
+	return compressedPayload{data: buffer, crc: hasher.Sum32(), size: uint64(size)}
+}
+
+// spillBuffer holds a compressed payload in memory, moving it to a temporary file once it grows
+// past parallelSpillSize
+type spillBuffer struct {
+	memory bytes.Buffer
+	file   *os.File
+	size   int64
+}
+
+func (b *spillBuffer) Write(p []byte) (int, error) {
+	if b.file == nil && b.memory.Len()+len(p) > parallelSpillSize {
+		file, err := os.CreateTemp("", "ufs-deflate-*")
+		if err != nil {
+			return 0, err
+		}
+		b.file = file
+		if _, err := b.memory.WriteTo(file); err != nil {
+			return 0, err
+		}
+		b.memory = bytes.Buffer{}
+	}
+
+	var n int
+	var err error
+	if b.file != nil {
+		n, err = b.file.Write(p)
+	} else {
+		n, err = b.memory.Write(p)
+	}
+	b.size += int64(n)
+	return n, err
+}
+
+// writeTo copies the payload to w
+func (b *spillBuffer) writeTo(w io.Writer) error {
+	if b.file == nil {
+		_, err := w.Write(b.memory.Bytes())
+		return err
+	}
+	if _, err := b.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.Copy(w, b.file)
+	return err
+}
+
+// discard releases the payload, removing its temporary file. A nil buffer is ignored.
+func (b *spillBuffer) discard() {
+	if b == nil {
+		return
+	}
+	if b.file != nil {
+		b.file.Close()
+		os.Remove(b.file.Name())
+		b.file = nil
+	}
+	b.memory = bytes.Buffer{}
+}
+
+// nopWriteCloser is used for stored entries, which are copied as is
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// prepareRawHeader fills the header fields that zip.Writer.CreateHeader sets but CreateRaw doesn't,
+// so entries written raw look the same as entries written by the sequential mode
+func prepareRawHeader(header *zip.FileHeader) {
+	if !isASCII(header.Name) && utf8.ValidString(header.Name) {
+		header.Flags |= 0x800
+	}
+
+	header.CreatorVersion = header.CreatorVersion&0xff00 | 20
+	header.ReaderVersion = 20
+
+	if !header.Modified.IsZero() {
+		modified := header.Modified
+		header.ModifiedDate = uint16(modified.Day() + int(modified.Month())<<5 + (modified.Year()-1980)<<9)
+		header.ModifiedTime = uint16(modified.Second()/2 + modified.Minute()<<5 + modified.Hour()<<11)
+
+		// Extended timestamp extra field, as written by CreateHeader
+		extra := make([]byte, 9)
+		binary.LittleEndian.PutUint16(extra[0:], 0x5455)
+		binary.LittleEndian.PutUint16(extra[2:], 5)
+		extra[4] = 1
+		binary.LittleEndian.PutUint32(extra[5:], uint32(modified.Unix()))
+		header.Extra = append(header.Extra, extra...)
+	}
+}
+
+// isASCII reports whether s only contains ASCII characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,477 @@
+package ufs
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+Compress-split.go contains functions to create ZIP archives split into several part files.
+
+Many storage and upload services limit the size of a single file, so a backup of a large
+directory has to be cut in parts. CompressDirectorySplit writes the archive directly into
+parts of a fixed size, along with a JSON manifest describing them:
+
+	backup.zip.001
+	backup.zip.002
+	backup.zip.003
+	backup.zip.manifest.json
+
+The manifest records the order, size and SHA-256 checksum of every part and of the complete archive,
+so ExtractSplitArchive can detect missing or corrupted parts before extracting anything.
+
+Functions:
+- CompressDirectorySplit: Compresses a directory into a split ZIP archive with a manifest.
+- ExtractSplitArchive: Verifies the parts listed in a manifest and extracts the archive.
+*/
+
+// splitManifestVersion is the version of the manifest format written by CompressDirectorySplit
+const splitManifestVersion = 1
+
+// splitManifestSuffix is appended to the archive name to get the manifest file name
+const splitManifestSuffix = ".manifest.json"
+
+// SplitManifest describes an archive split into several part files.
+// It is also written by SplitFile and the other split functions, see Split-manifest.go.
+type SplitManifest struct {
+	Version   int         `json:"version"`
+	Archive   string      `json:"archive"`   // File name of the complete archive (or split file), e.g. "backup.zip"
+	TotalSize int64       `json:"totalSize"` // Size of the complete archive in bytes
+	ChunkSize int64       `json:"chunkSize"` // Maximum size of a part in bytes, 0 for SplitFileByLines
+	SHA256    string      `json:"sha256"`    // Checksum of the complete archive
+	Parts     []SplitPart `json:"parts"`     // Parts in order
+}
+
+// SplitPart describes a single part of a split archive.
+type SplitPart struct {
+	Index  int    `json:"index"`  // Position of the part, starting at 1
+	Name   string `json:"name"`   // File name of the part, in the same directory as the manifest
+	Size   int64  `json:"size"`   // Size of the part in bytes
+	SHA256 string `json:"sha256"` // Checksum of the part
+}
+
+// CompressDirectorySplit compresses a directory into a ZIP archive split into parts of at most chunkSize bytes.
+// The parts are named <destPrefix>.zip.001, <destPrefix>.zip.002, ... and a manifest
+// <destPrefix>.zip.manifest.json lists them with their checksums.
+// Compression settings (Options.CompressionWorkers, ...) are the same as CompressDirectory.
+//
+// Parameters:
+//   - sourcePath: The absolute or relative path to the directory to compress
+//   - destPrefix: The path of the archive without extension, e.g. "/backups/project"
+//   - chunkSize: The maximum size in bytes of each part
+//
+// Returns:
+//   - *SplitManifest: The manifest written next to the parts
+//   - error: An error if the compression failed, nil otherwise. No part is left behind on error.
+//
+// Example:
+//
+//	manifest, err := ufs.CompressDirectorySplit("/path/to/project", "/backups/project", 100*1024*1024)
+//	if err != nil {
+//	    fmt.Printf("Error compressing directory: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("Archive of %d bytes split into %d parts\n", manifest.TotalSize, len(manifest.Parts))
+func (ufs *UFS) CompressDirectorySplit(sourcePath, destPrefix string, chunkSize int64) (_ *SplitManifest, err error) {
+	defer ufs.recoverPanic("CompressDirectorySplit", &err)
+
+	if err := ufs.requireOS("CompressDirectorySplit"); err != nil {
+		return nil, err
+	}
+	defer ufs.applyIOPriority()()
+
+	// Verify source is a directory
+	if !ufs.IsDirectory(sourcePath) {
+		return nil, ufs.misuseError("CompressDirectorySplit", "source path is not a directory", sourcePath)
+	}
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("CompressDirectorySplit: chunk size must be positive, got %d", chunkSize)
+	}
+
+	sourcePath, err = filepath.Abs(sourcePath)
+	if err != nil {
+		return nil, ufs.wrapError(err, "CompressDirectorySplit")
+	}
+	destPrefix, err = ufs.expandOutputPath(destPrefix)
+	if err != nil {
+		return nil, ufs.wrapError(err, "CompressDirectorySplit")
+	}
+	archivePath, err := filepath.Abs(destPrefix + ".zip")
+	if err != nil {
+		return nil, ufs.wrapError(err, "CompressDirectorySplit")
+	}
+
+	// The parts depend on the compressed size, only the archive and its manifest are recorded
+	if ufs.dryRun("CompressDirectorySplit", DryRunWrite, archivePath, sourcePath) {
+		ufs.dryRun("CompressDirectorySplit", DryRunWrite, archivePath+splitManifestSuffix, "")
+		return &SplitManifest{Version: splitManifestVersion, Archive: filepath.Base(archivePath), ChunkSize: chunkSize}, nil
+	}
+
+	// Ensure destination directory exists
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+		return nil, ufs.wrapError(err, "CompressDirectorySplit")
+	}
+
+	writer := newSplitWriter(archivePath, chunkSize)
+
+	// Don't leave an incomplete set of parts behind
+	defer func() {
+		if err != nil {
+			writer.removeParts()
+		}
+	}()
+
+	// Skip the parts and the manifest when they are written inside the source
+	exclude := func(path string) bool { return strings.HasPrefix(path, archivePath+".") }
+
+	zipWriter := zip.NewWriter(writer)
+	err = ufs.writeDirectoryZip(context.Background(), zipWriter, sourcePath, exclude, nil, "CompressDirectorySplit")
+	if closeErr := zipWriter.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, ufs.wrapError(err, "CompressDirectorySplit")
+	}
+
+	manifest := &SplitManifest{
+		Version:   splitManifestVersion,
+		Archive:   filepath.Base(archivePath),
+		TotalSize: writer.totalSize,
+		ChunkSize: chunkSize,
+		SHA256:    hex.EncodeToString(writer.totalHash.Sum(nil)),
+		Parts:     writer.parts,
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, ufs.wrapError(err, "CompressDirectorySplit")
+	}
+	if err = os.WriteFile(archivePath+splitManifestSuffix, data, 0644); err != nil {
+		os.Remove(archivePath + splitManifestSuffix)
+		return nil, ufs.wrapError(err, "CompressDirectorySplit")
+	}
+
+	return manifest, nil
+}
+
+// ExtractSplitArchive verifies the parts listed in a manifest written by CompressDirectorySplit
+// and extracts the archive they form to a directory.
+// Every part is checked (presence, size and checksum) before anything is extracted,
+// and the parts are read in place: the complete archive is never written to disk.
+//
+// Parameters:
+//   - manifestPath: The absolute or relative path to the manifest (<prefix>.zip.manifest.json)
+//   - destPath: The absolute or relative path where the contents will be extracted
+//
+// Returns:
+//   - error: An error if a part is missing or corrupted, or if the extraction failed, nil otherwise
+//
+// Example:
+//
+//	err := ufs.ExtractSplitArchive("/downloads/project.zip.manifest.json", "/restore/project")
+//	if err != nil {
+//	    fmt.Printf("Error extracting split archive: %v\n", err)
+//	    return
+//	}
+func (ufs *UFS) ExtractSplitArchive(manifestPath, destPath string) (err error) {
+	defer ufs.recoverPanic("ExtractSplitArchive", &err)
+
+	if err := ufs.requireOS("ExtractSplitArchive"); err != nil {
+		return err
+	}
+	defer ufs.applyIOPriority()()
+
+	manifest, err := readSplitManifest(manifestPath)
+	if err != nil {
+		return ufs.wrapError(err, "ExtractSplitArchive")
+	}
+
+	partPaths, err := manifest.verify(filepath.Dir(manifestPath))
+	if err != nil {
+		return ufs.wrapError(err, "ExtractSplitArchive")
+	}
+
+	parts, err := openParts(partPaths)
+	if err != nil {
+		return ufs.wrapError(err, "ExtractSplitArchive")
+	}
+	defer parts.Close()
+
+	reader, err := zip.NewReader(parts, parts.size)
+	if err != nil {
+		return ufs.wrapError(classifyArchiveError(manifestPath, "", err), "ExtractSplitArchive")
+	}
+
+	_, err = ufs.extractZipReader(context.Background(), reader, manifestPath, destPath, nil, "ExtractSplitArchive")
+	return err
+}
+
+// readSplitManifest reads and decodes a split archive manifest
+func readSplitManifest(path string) (*SplitManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest SplitManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid split manifest %s: %w", path, err)
+	}
+	if manifest.Version != splitManifestVersion {
+		return nil, fmt.Errorf("unsupported split manifest version %d: %s", manifest.Version, path)
+	}
+
+	return &manifest, nil
+}
+
+// verify checks that every part exists in dir with the expected size and checksum,
+// and that together they form the expected archive. It returns the part paths in order.
+func (manifest *SplitManifest) verify(dir string) ([]string, error) {
+	parts, err := manifest.orderedParts()
+	if err != nil {
+		return nil, err
+	}
+
+	var totalSize int64
+	totalHash := sha256.New()
+	paths := make([]string, len(parts))
+
+	for i, part := range parts {
+		paths[i] = filepath.Join(dir, part.Name)
+
+		info, err := os.Stat(paths[i])
+		if err != nil {
+			return nil, fmt.Errorf("part %d is missing: %w", part.Index, err)
+		}
+		if info.Size() != part.Size {
+			return nil, fmt.Errorf("part %d has size %d, expected %d: %s", part.Index, info.Size(), part.Size, paths[i])
+		}
+
+		partHash := sha256.New()
+		file, err := os.Open(paths[i])
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(io.MultiWriter(partHash, totalHash), file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		if hex.EncodeToString(partHash.Sum(nil)) != part.SHA256 {
+			return nil, fmt.Errorf("part %d is corrupted (checksum mismatch): %s", part.Index, paths[i])
+		}
+
+		totalSize += part.Size
+	}
+
+	if totalSize != manifest.TotalSize {
+		return nil, fmt.Errorf("parts add up to %d bytes, expected %d", totalSize, manifest.TotalSize)
+	}
+	if hex.EncodeToString(totalHash.Sum(nil)) != manifest.SHA256 {
+		return nil, fmt.Errorf("archive checksum mismatch")
+	}
+
+	return paths, nil
+}
+
+// orderedParts returns the parts sorted by index, checking that none is missing
+// and that their names stay in the manifest directory
+func (manifest *SplitManifest) orderedParts() ([]SplitPart, error) {
+	parts := append([]SplitPart(nil), manifest.Parts...)
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Index < parts[j].Index })
+
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("split manifest lists no parts")
+	}
+	for i, part := range parts {
+		if part.Index != i+1 {
+			return nil, fmt.Errorf("split manifest is missing part %d", i+1)
+		}
+		if part.Name != filepath.Base(part.Name) {
+			return nil, fmt.Errorf("invalid part name in split manifest: %s", part.Name)
+		}
+	}
+	return parts, nil
+}
+
+// splitWriter is an io.Writer cutting its output into part files of chunkSize bytes
+type splitWriter struct {
+	base      string // path of the complete archive, parts add .001, .002, ...
+	chunkSize int64
+
+	current     *os.File
+	currentSize int64
+	currentHash hash.Hash
+
+	parts     []SplitPart
+	created   []string
+	totalSize int64
+	totalHash hash.Hash
+}
+
+func newSplitWriter(base string, chunkSize int64) *splitWriter {
+	return &splitWriter{base: base, chunkSize: chunkSize, totalHash: sha256.New()}
+}
+
+func (w *splitWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if w.current == nil || w.currentSize == w.chunkSize {
+			if err := w.nextPart(); err != nil {
+				return written, err
+			}
+		}
+
+		n := int64(len(p))
+		if remaining := w.chunkSize - w.currentSize; n > remaining {
+			n = remaining
+		}
+
+		wn, err := w.current.Write(p[:n])
+		w.currentHash.Write(p[:wn])
+		w.totalHash.Write(p[:wn])
+		w.currentSize += int64(wn)
+		w.totalSize += int64(wn)
+		written += wn
+		if err != nil {
+			return written, err
+		}
+
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// nextPart closes the current part and starts the next one
+func (w *splitWriter) nextPart() error {
+	if err := w.closePart(); err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf("%s.%03d", w.base, len(w.parts)+1)
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w.created = append(w.created, path)
+	w.current = file
+	w.currentSize = 0
+	w.currentHash = sha256.New()
+	return nil
+}
+
+// closePart closes the current part and records it
+func (w *splitWriter) closePart() error {
+	if w.current == nil {
+		return nil
+	}
+
+	err := w.current.Close()
+	w.parts = append(w.parts, SplitPart{
+		Index:  len(w.parts) + 1,
+		Name:   filepath.Base(w.current.Name()),
+		Size:   w.currentSize,
+		SHA256: hex.EncodeToString(w.currentHash.Sum(nil)),
+	})
+	w.current = nil
+	return err
+}
+
+// Close closes the last part
+func (w *splitWriter) Close() error {
+	return w.closePart()
+}
+
+// removeParts removes every part created so far
+func (w *splitWriter) removeParts() {
+	if w.current != nil {
+		w.current.Close()
+		w.current = nil
+	}
+	for _, path := range w.created {
+		os.Remove(path)
+	}
+}
+
+// multiPartReader is an io.ReaderAt over several files read one after the other
+type multiPartReader struct {
+	files   []*os.File
+	offsets []int64 // offset of each file in the whole stream
+	sizes   []int64
+	size    int64
+}
+
+// openParts opens the given files as a single io.ReaderAt
+func openParts(paths []string) (*multiPartReader, error) {
+	reader := &multiPartReader{}
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			reader.Close()
+			return nil, err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			reader.Close()
+			return nil, err
+		}
+
+		reader.files = append(reader.files, file)
+		reader.offsets = append(reader.offsets, reader.size)
+		reader.sizes = append(reader.sizes, info.Size())
+		reader.size += info.Size()
+	}
+	return reader, nil
+}
+
+func (r *multiPartReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+
+	// Find the part containing off
+	i := sort.Search(len(r.offsets), func(i int) bool { return r.offsets[i]+r.sizes[i] > off })
+
+	read := 0
+	for read < len(p) && i < len(r.files) {
+		n, err := r.files[i].ReadAt(p[read:min(len(p), read+int(r.offsets[i]+r.sizes[i]-off))], off-r.offsets[i])
+		read += n
+		off += int64(n)
+		if err != nil && err != io.EOF {
+			return read, err
+		}
+		if n == 0 {
+			break // The part is shorter than when it was opened
+		}
+		if off >= r.offsets[i]+r.sizes[i] {
+			i++
+		}
+	}
+
+	if read < len(p) {
+		return read, io.EOF
+	}
+	return read, nil
+}
+
+func (r *multiPartReader) Close() error {
+	for _, file := range r.files {
+		file.Close()
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package ufs
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"path/filepath"
+)
+
+/*
+Compress-stream.go contains functions to create and extract ZIP archives without going through a file.
+
+They allow archives to be streamed directly to HTTP responses, S3 uploads or pipes,
+and extracted from any io.ReaderAt (a downloaded buffer, an open file, a section of a bigger file)
+without temporary files.
+
+Functions:
+- CompressDirectoryTo: Writes a ZIP archive of a directory to an io.Writer.
+- ExtractArchiveFrom: Extracts a ZIP archive read from an io.ReaderAt.
+*/
+
+// CompressDirectoryTo writes a ZIP archive of a directory to w, like CompressDirectory does to a file.
+// w is not closed. Compression settings (Options.CompressionWorkers, ...) are the same as CompressDirectory.
+//
+// Parameters:
+//   - sourcePath: The absolute or relative path to the directory to compress
+//   - w: The writer receiving the archive, e.g. an http.ResponseWriter
+//
+// Returns:
+//   - error: An error if the compression or a write failed, nil otherwise
+//
+// Example:
+//
+//	http.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+//	    w.Header().Set("Content-Type", "application/zip")
+//	    w.Header().Set("Content-Disposition", `attachment; filename="reports.zip"`)
+//	    if err := ufs.CompressDirectoryTo("/data/reports", w); err != nil {
+//	        log.Printf("Error streaming archive: %v", err)
+//	    }
+//	})
+func (ufs *UFS) CompressDirectoryTo(sourcePath string, w io.Writer) (err error) {
+	defer ufs.recoverPanic("CompressDirectoryTo", &err)
+
+	if err := ufs.requireOS("CompressDirectoryTo"); err != nil {
+		return err
+	}
+	defer ufs.applyIOPriority()()
+
+	// Verify source is a directory
+	if !ufs.IsDirectory(sourcePath) {
+		return ufs.misuseError("CompressDirectoryTo", "source path is not a directory", sourcePath)
+	}
+
+	sourcePath, err = filepath.Abs(sourcePath)
+	if err != nil {
+		return ufs.wrapError(err, "CompressDirectoryTo")
+	}
+
+	zipWriter := zip.NewWriter(w)
+	excludeNothing := func(path string) bool { return false }
+
+	err = ufs.writeDirectoryZip(context.Background(), zipWriter, sourcePath, excludeNothing, nil, "CompressDirectoryTo")
+
+	// Close the writer, it writes the central directory
+	if closeErr := zipWriter.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return ufs.wrapError(err, "CompressDirectoryTo")
+	}
+
+	return nil
+}
+
+// ExtractArchiveFrom extracts a ZIP archive read from r to a directory, like ExtractArchive does from a file.
+// ZIP archives keep their index at the end, so random access (io.ReaderAt) and the total size are needed.
+//
+// Parameters:
+//   - r: The reader giving access to the archive, e.g. a bytes.Reader or an *os.File
+//   - size: The size of the archive in bytes
+//   - destPath: The absolute or relative path where the contents will be extracted
+//
+// Returns:
+//   - error: An error if the archive is invalid or the extraction failed, nil otherwise
+//
+// Example:
+//
+//	resp, err := http.Get("https://example.com/release.zip")
+//	if err != nil {
+//	    return err
+//	}
+//	defer resp.Body.Close()
+//	data, err := io.ReadAll(resp.Body)
+//	if err != nil {
+//	    return err
+//	}
+//	err = ufs.ExtractArchiveFrom(bytes.NewReader(data), int64(len(data)), "/opt/release")
+func (ufs *UFS) ExtractArchiveFrom(r io.ReaderAt, size int64, destPath string) (err error) {
+	defer ufs.recoverPanic("ExtractArchiveFrom", &err)
+
+	if err := ufs.requireOS("ExtractArchiveFrom"); err != nil {
+		return err
+	}
+	defer ufs.applyIOPriority()()
+
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return ufs.wrapError(classifyArchiveError("", "", err), "ExtractArchiveFrom")
+	}
+
+	_, err = ufs.extractZipReader(context.Background(), reader, "", destPath, nil, "ExtractArchiveFrom")
+	return err
+}
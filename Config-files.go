@@ -0,0 +1,490 @@
+package ufs
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+/*
+Config-files.go contains functions to read and write .env and INI files.
+
+The write functions update an existing file rather than regenerating it: comments, blank lines,
+the order of the keys and the formatting of unchanged values are kept. Only the lines of changed
+keys are rewritten, keys missing from the new values are removed, and new keys are added at the
+end of their section (new sections at the end of the file, in alphabetical order). INI sections
+missing from the new values are removed with their comments.
+
+.env files follow the dotenv conventions: KEY=value lines, an optional "export " prefix,
+"double quoted" values with \n, \t, \" and \\ escapes, 'single quoted' literal values, and
+# comments, including after unquoted values. Multi-line values are written escaped on a single line.
+
+INI files have key = value (or key: value) lines grouped in [section]s, and ; or # comments.
+Keys before the first section belong to the section "".
+
+Functions:
+- ReadEnvFile: Reads the variables of a .env file.
+- WriteEnvFile: Writes the variables of a .env file, keeping its comments and order.
+- ReadINI: Reads the sections and keys of an INI file.
+- WriteINI: Writes the sections and keys of an INI file, keeping its comments and order.
+*/
+
+// configSyntax describes the syntax of a key/value config file format
+type configSyntax struct {
+	name       string                             // Format name used in errors
+	sections   bool                               // [section] headers are allowed
+	separators string                             // Characters separating keys from values
+	comments   string                             // Characters starting comment lines
+	decode     func(raw string) string            // Decodes the raw value of a line
+	encode     func(value string) (string, error) // Encodes a value for a line
+	newLine    func(key, value string) string     // Formats the line of a new key
+}
+
+// configLine is a line of a config file
+type configLine struct {
+	text    string
+	section string // Section the line belongs to, "" before the first section
+	header  bool   // The line is a [section] header
+	key     string // Key of key/value lines, "" for other lines
+	raw     string // Raw value of key/value lines, after the separator and its spaces
+	prefix  string // Start of key/value lines, up to the raw value
+}
+
+var envSyntax = configSyntax{
+	name:       ".env",
+	separators: "=",
+	comments:   "#",
+	decode:     decodeEnvValue,
+	encode:     encodeEnvValue,
+	newLine:    func(key, value string) string { return key + "=" + value },
+}
+
+var iniSyntax = configSyntax{
+	name:       "INI",
+	sections:   true,
+	separators: "=:",
+	comments:   ";#",
+	decode:     decodeINIValue,
+	encode:     encodeINIValue,
+	newLine:    func(key, value string) string { return key + " = " + value },
+}
+
+// ReadEnvFile reads the variables of a .env file. When a key is defined several times, the last value wins.
+//
+// Parameters:
+//   - path: The absolute or relative path to the .env file
+//
+// Returns:
+//   - map[string]string: The variables of the file, with quotes and escapes decoded
+//   - error: An error if the file couldn't be read, nil otherwise
+//
+// Example:
+//
+//	env, err := ufs.ReadEnvFile(".env")
+//	if err != nil {
+//	    fmt.Printf("Error reading .env: %v\n", err)
+//	    return
+//	}
+//	fmt.Println("Database:", env["DATABASE_URL"])
+func (ufs *UFS) ReadEnvFile(path string) (_ map[string]string, err error) {
+	defer ufs.recoverPanic("ReadEnvFile", &err)
+
+	sections, err := ufs.readConfigFile(path, envSyntax, "ReadEnvFile")
+	if err != nil {
+		return nil, err
+	}
+	return sections[""], nil
+}
+
+// WriteEnvFile writes the variables of a .env file, creating it if it doesn't exist.
+// An existing file is updated in place: comments, order and unchanged lines are kept,
+// variables missing from values are removed and new variables are appended in alphabetical order.
+// This function will create any parent directories if they don't exist.
+//
+// Parameters:
+//   - path: The absolute or relative path to the .env file
+//   - values: All the variables the file must contain
+//
+// Returns:
+//   - error: An error if a key is invalid or the file couldn't be written, nil otherwise
+//
+// Example:
+//
+//	env, _ := ufs.ReadEnvFile(".env")
+//	env["API_TOKEN"] = newToken
+//	if err := ufs.WriteEnvFile(".env", env); err != nil {
+//	    fmt.Printf("Error writing .env: %v\n", err)
+//	}
+func (ufs *UFS) WriteEnvFile(path string, values map[string]string) (err error) {
+	defer ufs.recoverPanic("WriteEnvFile", &err)
+
+	return ufs.writeConfigFile(path, map[string]map[string]string{"": values}, envSyntax, "WriteEnvFile")
+}
+
+// ReadINI reads the sections and keys of an INI file. Keys before the first section are in the section "".
+// When a key is defined several times in a section, the last value wins.
+//
+// Parameters:
+//   - path: The absolute or relative path to the INI file
+//
+// Returns:
+//   - map[string]map[string]string: The keys of every section, by section name
+//   - error: An error if the file couldn't be read, nil otherwise
+//
+// Example:
+//
+//	config, err := ufs.ReadINI("/etc/myapp/config.ini")
+//	if err != nil {
+//	    fmt.Printf("Error reading config: %v\n", err)
+//	    return
+//	}
+//	fmt.Println("Port:", config["server"]["port"])
+func (ufs *UFS) ReadINI(path string) (_ map[string]map[string]string, err error) {
+	defer ufs.recoverPanic("ReadINI", &err)
+
+	return ufs.readConfigFile(path, iniSyntax, "ReadINI")
+}
+
+// WriteINI writes the sections and keys of an INI file, creating it if it doesn't exist.
+// An existing file is updated in place: comments, order and unchanged lines are kept,
+// keys missing from data are removed, new keys are appended to their section and new sections
+// to the file, in alphabetical order. Sections missing from data are removed with their header and
+// comments, including the comment lines right above the header.
+// This function will create any parent directories if they don't exist.
+//
+// Parameters:
+//   - path: The absolute or relative path to the INI file
+//   - data: All the keys the file must contain, by section name ("" for keys before the first section)
+//
+// Returns:
+//   - error: An error if a section, key or value is invalid or the file couldn't be written, nil otherwise
+//
+// Example:
+//
+//	config, _ := ufs.ReadINI("config.ini")
+//	config["server"]["port"] = "9090"
+//	if err := ufs.WriteINI("config.ini", config); err != nil {
+//	    fmt.Printf("Error writing config: %v\n", err)
+//	}
+func (ufs *UFS) WriteINI(path string, data map[string]map[string]string) (err error) {
+	defer ufs.recoverPanic("WriteINI", &err)
+
+	return ufs.writeConfigFile(path, data, iniSyntax, "WriteINI")
+}
+
+// readConfigFile reads the keys of a config file by section
+func (ufs *UFS) readConfigFile(path string, syntax configSyntax, op string) (map[string]map[string]string, error) {
+	if !ufs.IsFile(path) {
+		return nil, ufs.misuseError(op, "path is not a file", path)
+	}
+
+	data, err := readBackendFile(ufs.backend(), path)
+	if err != nil {
+		return nil, ufs.wrapError(err, op)
+	}
+
+	sections := map[string]map[string]string{"": {}}
+	for _, line := range parseConfigLines(splitFileLines(string(data)), syntax) {
+		if sections[line.section] == nil {
+			sections[line.section] = map[string]string{}
+		}
+		if line.key != "" {
+			sections[line.section][line.key] = syntax.decode(line.raw)
+		}
+	}
+	return sections, nil
+}
+
+// writeConfigFile updates the keys of a config file, keeping its other lines
+func (ufs *UFS) writeConfigFile(path string, data map[string]map[string]string, syntax configSyntax, op string) error {
+	for section, values := range data {
+		if err := validateConfigSection(section, values, syntax); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	content, err := readBackendFile(ufs.backend(), path)
+	if err != nil && !os.IsNotExist(err) {
+		return ufs.wrapError(err, op)
+	}
+	lineBreak := "\n"
+	if strings.Contains(string(content), "\r\n") {
+		lineBreak = "\r\n"
+	}
+	lines := parseConfigLines(splitFileLines(string(content)), syntax)
+
+	var output []string
+	seen := map[string]map[string]bool{}
+
+	// addNewKeys adds the keys of a section missing from the file after its last key,
+	// or after its last non-blank line when it has no keys
+	addNewKeys := func(section string, start, lastKey int) error {
+		var added []string
+		for _, key := range sortedKeys(data[section]) {
+			if seen[section][key] {
+				continue
+			}
+			value, err := syntax.encode(data[section][key])
+			if err != nil {
+				return fmt.Errorf("%s: key %q: %w", op, key, err)
+			}
+			added = append(added, syntax.newLine(key, value))
+			if seen[section] == nil {
+				seen[section] = map[string]bool{}
+			}
+			seen[section][key] = true
+		}
+		if len(added) == 0 {
+			return nil
+		}
+
+		end := lastKey
+		if end < 0 {
+			end = len(output)
+		}
+		for lastKey < 0 && end > start && strings.TrimSpace(output[end-1]) == "" {
+			end--
+		}
+		output = append(output[:end], append(added, output[end:]...)...)
+		return nil
+	}
+
+	isComment := func(text string) bool {
+		trimmed := strings.TrimSpace(text)
+		return trimmed != "" && strings.ContainsRune(syntax.comments, rune(trimmed[0]))
+	}
+
+	sectionStart, lastKey := 0, -1
+	current := ""
+	removed := false      // The current section is missing from data
+	var comments []string // The last comment lines of a removed section
+	for _, line := range lines {
+		if line.header {
+			// The comments right above a header describe its section: they are removed with it,
+			// and kept with it when they are the last lines of a removed section
+			_, ok := data[line.section]
+			if !ok {
+				for len(output) > sectionStart && isComment(output[len(output)-1]) {
+					output = output[:len(output)-1]
+				}
+			}
+			if err := addNewKeys(current, sectionStart, lastKey); err != nil {
+				return err
+			}
+			if removed && ok {
+				output = append(output, comments...)
+			}
+			comments, removed = nil, !ok
+			current, sectionStart, lastKey = line.section, len(output)+1, -1
+		}
+		if removed {
+			if isComment(line.text) {
+				comments = append(comments, line.text)
+			} else {
+				comments = nil
+			}
+			continue
+		}
+		if seen[line.section] == nil {
+			seen[line.section] = map[string]bool{}
+		}
+
+		if line.key == "" {
+			output = append(output, line.text)
+			continue
+		}
+
+		value, ok := data[line.section][line.key]
+		if !ok {
+			continue // Removed
+		}
+		seen[line.section][line.key] = true
+		if syntax.decode(line.raw) == value {
+			output = append(output, line.text) // Unchanged, keep its quoting and comment
+		} else {
+			encoded, err := syntax.encode(value)
+			if err != nil {
+				return fmt.Errorf("%s: key %q: %w", op, line.key, err)
+			}
+			output = append(output, line.prefix+encoded)
+		}
+		lastKey = len(output)
+	}
+	if err := addNewKeys(current, sectionStart, lastKey); err != nil {
+		return err
+	}
+	// Don't leave the blank lines that separated the last section, removed, from the previous one
+	for removed && len(output) > 0 && strings.TrimSpace(output[len(output)-1]) == "" {
+		output = output[:len(output)-1]
+	}
+
+	// New sections
+	for _, section := range sortedKeys(data) {
+		if _, ok := seen[section]; ok || section == "" || len(data[section]) == 0 {
+			continue
+		}
+		if len(output) > 0 {
+			output = append(output, "")
+		}
+		output = append(output, "["+section+"]")
+		if err := addNewKeys(section, len(output), -1); err != nil {
+			return err
+		}
+	}
+
+	result := strings.Join(output, lineBreak)
+	if len(output) > 0 {
+		result += lineBreak
+	}
+	if err := ufs.WriteFile(path, []byte(result)); err != nil {
+		return ufs.wrapError(err, op)
+	}
+	return nil
+}
+
+// parseConfigLines finds the section, key and raw value of every line
+func parseConfigLines(lines []string, syntax configSyntax) []configLine {
+	parsed := make([]configLine, len(lines))
+	section := ""
+
+	for i, text := range lines {
+		parsed[i] = configLine{text: text, section: section}
+		trimmed := strings.TrimSpace(text)
+
+		switch {
+		case trimmed == "" || strings.ContainsRune(syntax.comments, rune(trimmed[0])):
+			// Comment or blank line
+		case syntax.sections && strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"):
+			section = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			parsed[i].section, parsed[i].header = section, true
+		default:
+			sep := strings.IndexAny(text, syntax.separators)
+			if sep < 0 {
+				continue
+			}
+			key := strings.TrimSpace(text[:sep])
+			if syntax.name == envSyntax.name {
+				key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+			}
+			if key == "" {
+				continue
+			}
+
+			rest := text[sep+1:]
+			raw := strings.TrimLeft(rest, " \t")
+			parsed[i].key = key
+			parsed[i].raw = raw
+			parsed[i].prefix = text[:sep+1] + rest[:len(rest)-len(raw)]
+		}
+	}
+	return parsed
+}
+
+// validateConfigSection checks that a section and its keys and values can be written
+func validateConfigSection(section string, values map[string]string, syntax configSyntax) error {
+	if section != "" && (!syntax.sections || strings.ContainsAny(section, "[]\r\n")) {
+		return fmt.Errorf("invalid %s section %q", syntax.name, section)
+	}
+	for key := range values {
+		if key == "" || key != strings.TrimSpace(key) || strings.ContainsAny(key, syntax.separators+"\r\n") ||
+			strings.ContainsRune(syntax.comments+"[", rune(key[0])) {
+			return fmt.Errorf("invalid %s key %q", syntax.name, key)
+		}
+		if syntax.name == envSyntax.name && strings.ContainsAny(key, " \t") {
+			return fmt.Errorf("invalid %s key %q", syntax.name, key)
+		}
+	}
+	return nil
+}
+
+// decodeEnvValue decodes the quotes, escapes and comment of a .env value
+func decodeEnvValue(raw string) string {
+	raw = strings.TrimSpace(raw)
+
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		var value strings.Builder
+		for i := 1; i < len(raw); i++ {
+			switch c := raw[i]; {
+			case c == '"':
+				return value.String()
+			case c == '\\' && i+1 < len(raw):
+				i++
+				switch raw[i] {
+				case 'n':
+					value.WriteByte('\n')
+				case 'r':
+					value.WriteByte('\r')
+				case 't':
+					value.WriteByte('\t')
+				case '"', '\\':
+					value.WriteByte(raw[i])
+				default:
+					value.WriteByte('\\')
+					value.WriteByte(raw[i])
+				}
+			default:
+				value.WriteByte(c)
+			}
+		}
+		return value.String() // Unterminated
+	case strings.HasPrefix(raw, "'"):
+		value := raw[1:]
+		if end := strings.IndexByte(value, '\''); end >= 0 {
+			value = value[:end]
+		}
+		return value
+	default:
+		if comment := strings.Index(raw, " #"); comment >= 0 {
+			raw = raw[:comment]
+		}
+		return strings.TrimSpace(raw)
+	}
+}
+
+// encodeEnvValue quotes a .env value when it contains spaces, quotes, comments or line breaks
+func encodeEnvValue(value string) (string, error) {
+	if !strings.ContainsAny(value, " \t\r\n#\"'\\$=`") {
+		return value, nil
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + replacer.Replace(value) + `"`, nil
+}
+
+// decodeINIValue removes the quotes or the inline comment of an INI value
+func decodeINIValue(raw string) string {
+	raw = strings.TrimSpace(raw)
+
+	if len(raw) >= 2 && (raw[0] == '"' || raw[0] == '\'') && raw[len(raw)-1] == raw[0] {
+		return raw[1 : len(raw)-1]
+	}
+	for _, marker := range []string{" ;", " #", "\t;", "\t#"} {
+		if comment := strings.Index(raw, marker); comment >= 0 {
+			raw = raw[:comment]
+		}
+	}
+	return strings.TrimSpace(raw)
+}
+
+// encodeINIValue quotes an INI value when its spaces or comment characters would be lost
+func encodeINIValue(value string) (string, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return "", fmt.Errorf("INI values can't contain line breaks")
+	}
+	if value != strings.TrimSpace(value) || strings.ContainsAny(value, ";#") ||
+		(value != "" && (value[0] == '"' || value[0] == '\'')) {
+		return `"` + value + `"`, nil
+	}
+	return value, nil
+}
+
+// sortedKeys returns the keys of a map in alphabetical order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
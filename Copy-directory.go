@@ -0,0 +1,274 @@
+package ufs
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*
+Copy-directory.go contains CopyDirectory, a faithful copy of a directory tree.
+
+Unlike a plain recursive copy, the permissions and modification times of files and directories are kept,
+symbolic links are copied as links (or replaced by what they point to with FollowSymlinks), and every entry
+that couldn't be copied is listed in the report instead of failing the whole copy.
+
+Functions:
+- CopyDirectory: Copies a directory tree, keeping modes, times and symbolic links.
+*/
+
+// CopyDirectoryOptions controls CopyDirectory. The zero value copies everything, symbolic links as links,
+// and goes on after failures.
+type CopyDirectoryOptions struct {
+	// FollowSymlinks copies the files and directories symbolic links point to, instead of the links.
+	// Links to one of their parent directories are reported as failures instead of copied forever.
+	FollowSymlinks bool
+
+	// ExcludeHidden leaves hidden files and directories out of the copy, like Options.ExcludeHidden
+	// (which applies too).
+	ExcludeHidden bool
+
+	// StopOnError stops the copy at the first entry that can't be copied and returns its error.
+	// By default the failure is recorded in the report and the copy goes on.
+	StopOnError bool
+}
+
+// CopyReport is the result of CopyDirectory. Paths are relative to the source directory.
+type CopyReport struct {
+	Files       int           // Regular files copied
+	Directories int           // Directories copied, including the source directory itself
+	Symlinks    int           // Symbolic links copied as links
+	Bytes       int64         // Total size of the files copied
+	Skipped     []string      // Hidden entries left out, and special files (devices, pipes, sockets)
+	Failed      []CopyFailure // Entries that couldn't be copied
+}
+
+// CopyFailure is an entry CopyDirectory couldn't copy.
+type CopyFailure struct {
+	Path string // Path of the entry, relative to the source directory
+	Err  error  // Why the entry couldn't be copied
+}
+
+// OK reports whether every entry of the tree was copied
+func (report *CopyReport) OK() bool {
+	return len(report.Failed) == 0
+}
+
+// CopyDirectory copies a directory and all its contents to a new location. Files keep their permissions
+// and modification time, directories too; symbolic links are recreated with the same target, which is
+// not rewritten, unless opts.FollowSymlinks is set. The modification time of symbolic links themselves
+// is not kept. Existing files of the destination are overwritten, other files of the destination are kept.
+// Files are cloned on file systems supporting it, like with CopyFile. In the grouped API it is
+// DirFunctions.CopyDirectoryWithReport, DirFunctions.CopyDirectory keeping its former bool result.
+//
+// Parameters:
+//   - src: The absolute or relative path to the source directory
+//   - dst: The absolute or relative path to the destination directory, created if needed
+//   - opts: The copy settings, nil uses the defaults
+//
+// Returns:
+//   - *CopyReport: What was copied, skipped, and every entry that couldn't be copied
+//   - error: An error if src is not a directory, dst couldn't be created, or with opts.StopOnError the first
+//     failure; entries failing otherwise are only listed in CopyReport.Failed
+//
+// Example:
+//
+//	report, err := ufs.CopyDirectory("/srv/site", "/backup/site", nil)
+//	if err != nil {
+//	    fmt.Printf("Error copying directory: %v\n", err)
+//	    return
+//	}
+//	for _, failure := range report.Failed {
+//	    fmt.Printf("Not copied: %s: %v\n", failure.Path, failure.Err)
+//	}
+//	fmt.Printf("%d files copied (%d bytes)\n", report.Files, report.Bytes)
+func (ufs *UFS) CopyDirectory(src, dst string, opts *CopyDirectoryOptions) (_ *CopyReport, err error) {
+	defer ufs.recoverPanic("CopyDirectory", &err)
+
+	if err := ufs.requireOS("CopyDirectory"); err != nil {
+		return nil, err
+	}
+	defer ufs.applyIOPriority()()
+
+	if opts == nil {
+		opts = &CopyDirectoryOptions{}
+	}
+
+	src, err = filepath.Abs(src)
+	if err != nil {
+		return nil, ufs.wrapError(err, "CopyDirectory")
+	}
+	dst, err = filepath.Abs(dst)
+	if err != nil {
+		return nil, ufs.wrapError(err, "CopyDirectory")
+	}
+	info, err := os.Stat(src)
+	if err != nil || !info.IsDir() {
+		return nil, ufs.misuseError("CopyDirectory", "source path is not a directory", src)
+	}
+
+	copier := &directoryCopier{ufs: ufs, opts: opts, src: src, dst: dst, report: &CopyReport{}}
+	if err := copier.copyDirectory(src, dst, info, nil); err != nil {
+		return copier.report, ufs.wrapError(err, "CopyDirectory")
+	}
+	return copier.report, nil
+}
+
+// directoryCopier holds the state of a CopyDirectory call
+type directoryCopier struct {
+	ufs    *UFS
+	opts   *CopyDirectoryOptions
+	src    string
+	dst    string
+	report *CopyReport
+
+	includeHidden bool          // Copy hidden entries whatever the options, for moves
+	transfer      *transfer     // Reports and paces the copy of files, for MoveDirectoryWithProgress
+	verify        HashAlgorithm // Checks the copy of files with this algorithm, for CopyDirectoryVerified
+}
+
+// fail records a failed entry, returning the error when the copy must stop
+func (c *directoryCopier) fail(path string, err error) error {
+	rel, _ := filepath.Rel(c.src, path)
+	c.report.Failed = append(c.report.Failed, CopyFailure{Path: rel, Err: err})
+	if c.opts.StopOnError {
+		return fmt.Errorf("%s: %w", rel, err)
+	}
+	return nil
+}
+
+// copyDirectory copies the directory path to target. parents are the directories being copied above it,
+// to find symbolic links pointing back to them.
+func (c *directoryCopier) copyDirectory(path, target string, info os.FileInfo, parents []os.FileInfo) error {
+	if err := os.MkdirAll(target, info.Mode().Perm()|0700); err != nil {
+		if path == c.src {
+			return err
+		}
+		return c.fail(path, err)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return c.fail(path, err)
+	}
+
+	parents = append(parents, info)
+	for _, entry := range entries {
+		entryPath := filepath.Join(path, entry.Name())
+
+		// Don't copy the destination into itself when it is inside the source
+		if entryPath == c.dst {
+			continue
+		}
+		if err := c.copyEntry(entryPath, filepath.Join(target, entry.Name()), parents); err != nil {
+			return err
+		}
+	}
+
+	// Restore the mode and time last: adding the entries changed the time, and the mode may forbid writing
+	if err := c.chmod(target, info); err != nil {
+		return c.fail(path, err)
+	}
+	if err := os.Chtimes(target, time.Time{}, info.ModTime()); err != nil {
+		return c.fail(path, err)
+	}
+	c.report.Directories++
+	return nil
+}
+
+// copyEntry copies a single entry of a directory, whatever its type
+func (c *directoryCopier) copyEntry(path, target string, parents []os.FileInfo) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return c.fail(path, err)
+	}
+
+	rel, _ := filepath.Rel(c.src, path)
+	if !c.includeHidden && (c.opts.ExcludeHidden || c.ufs.opts.ExcludeHidden) && isHiddenEntry(fs.FileInfoToDirEntry(info)) {
+		c.report.Skipped = append(c.report.Skipped, rel)
+		return nil
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		if !c.opts.FollowSymlinks {
+			return c.copySymlink(path, target)
+		}
+		if info, err = os.Stat(path); err != nil {
+			return c.fail(path, err)
+		}
+		if info.IsDir() {
+			for _, parent := range parents {
+				if os.SameFile(parent, info) {
+					return c.fail(path, fmt.Errorf("symbolic link to a parent directory"))
+				}
+			}
+		}
+	}
+
+	switch {
+	case info.IsDir():
+		return c.copyDirectory(path, target, info, parents)
+	case info.Mode().IsRegular():
+		return c.copyFile(path, target, info)
+	}
+	c.report.Skipped = append(c.report.Skipped, rel)
+	return nil
+}
+
+// copyFile copies a regular file with its mode and modification time
+func (c *directoryCopier) copyFile(path, target string, info os.FileInfo) error {
+	copyFile := c.ufs.CopyFileWithPermissions
+	if c.transfer != nil {
+		copyFile = func(src, dst string) error { return c.ufs.copyFileTransfer(src, dst, info, c.transfer) }
+	}
+	if c.verify != "" {
+		copyFile = func(src, dst string) error {
+			_, err := c.ufs.copyFileVerified(src, dst, info, c.verify)
+			return err
+		}
+	}
+	if err := copyFile(path, target); err != nil {
+		return c.fail(path, err)
+	}
+	// The mode given at creation doesn't apply to files that already existed
+	if err := c.chmod(target, info); err != nil {
+		return c.fail(path, err)
+	}
+	if err := os.Chtimes(target, time.Time{}, info.ModTime()); err != nil {
+		return c.fail(path, err)
+	}
+	c.report.Files++
+	c.report.Bytes += info.Size()
+	return nil
+}
+
+// copySymlink recreates a symbolic link with the same target, replacing an existing file or link
+func (c *directoryCopier) copySymlink(path, target string) error {
+	if err := c.ufs.requireCapability(CapabilitySymlinks, "CopyDirectory", path); err != nil {
+		return c.fail(path, err)
+	}
+	link, err := os.Readlink(path)
+	if err != nil {
+		return c.fail(path, err)
+	}
+	if existing, err := os.Lstat(target); err == nil && !existing.IsDir() {
+		if err := os.Remove(target); err != nil {
+			return c.fail(path, err)
+		}
+	}
+	if err := os.Symlink(link, target); err != nil {
+		return c.fail(path, err)
+	}
+	c.report.Symlinks++
+	return nil
+}
+
+// chmod gives target the mode of info, except in scoped storage mode where permissions are not changed
+func (c *directoryCopier) chmod(target string, info os.FileInfo) error {
+	if c.ufs.IsScopedStorage() {
+		return nil
+	}
+	return os.Chmod(target, info.Mode().Perm())
+}
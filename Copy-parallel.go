@@ -0,0 +1,268 @@
+package ufs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+/*
+Copy-parallel.go contains functions to copy directory trees with several workers.
+
+Copying many files one by one wastes most of the time waiting for the disk,
+so CopyDirectoryParallel copies several files at the same time.
+
+On trees mixing multi-GB files with thousands of small files, copying in walk order
+can leave every worker streaming a large file while the small files wait.
+The ScheduleInterleave schedule keeps at least one worker for small files and hands
+out batches of small files between large ones, so both make progress.
+
+Functions:
+- CopyDirectoryParallel: Copies a directory tree using a pool of workers.
+*/
+
+// CopySchedule decides in which order CopyDirectoryParallel hands files to its workers.
+type CopySchedule int
+
+const (
+	// ScheduleWalkOrder copies files in the order they are found (default)
+	ScheduleWalkOrder CopySchedule = iota
+	// ScheduleInterleave interleaves large files with batches of small files
+	ScheduleInterleave
+)
+
+// Default values used by CopyParallelOptions
+const (
+	defaultLargeFileThreshold = 64 << 20 // 64 MiB
+	defaultSmallFileBatch     = 32
+)
+
+// CopyParallelOptions controls CopyDirectoryParallel.
+// The zero value uses runtime.NumCPU() workers and copies files in walk order.
+type CopyParallelOptions struct {
+	// Workers is the number of files copied at the same time, 0 uses runtime.NumCPU()
+	Workers int
+
+	// Schedule is the order in which files are handed to the workers
+	Schedule CopySchedule
+
+	// LargeFileThreshold is the size from which a file is considered large by ScheduleInterleave,
+	// 0 uses 64 MiB
+	LargeFileThreshold int64
+
+	// SmallFileBatch is the number of small files handed out between two large files by ScheduleInterleave,
+	// 0 uses 32
+	SmallFileBatch int
+}
+
+// copyJob is a single file to copy
+type copyJob struct {
+	src   string
+	dst   string
+	size  int64
+	large bool
+}
+
+// CopyDirectoryParallel copies a directory and all its contents to a new location
+// using several workers. File permissions are preserved and existing files are overwritten.
+// The copy stops at the first error.
+//
+// Parameters:
+//   - src: The absolute or relative path to the source directory
+//   - dst: The absolute or relative path to the destination directory
+//   - opts: The workers and scheduling settings, nil uses the defaults
+//
+// Returns:
+//   - error: An error if a file or directory couldn't be copied, nil otherwise
+//
+// Example:
+//
+//	err := ufs.CopyDirectoryParallel("/data/media", "/backup/media", &ufs.CopyParallelOptions{
+//	    Workers:  8,
+//	    Schedule: ufs.ScheduleInterleave,
+//	})
+//	if err != nil {
+//	    fmt.Printf("Error copying directory: %v\n", err)
+//	}
+func (ufs *UFS) CopyDirectoryParallel(src, dst string, opts *CopyParallelOptions) (err error) {
+	defer ufs.recoverPanic("CopyDirectoryParallel", &err)
+
+	if err := ufs.requireOS("CopyDirectoryParallel"); err != nil {
+		return err
+	}
+	defer ufs.applyIOPriority()()
+
+	if !ufs.IsDirectory(src) {
+		return ufs.misuseError("CopyDirectoryParallel", "source path is not a directory", src)
+	}
+
+	if opts == nil {
+		opts = &CopyParallelOptions{}
+	}
+	workers := opts.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	threshold := opts.LargeFileThreshold
+	if threshold <= 0 {
+		threshold = defaultLargeFileThreshold
+	}
+	batch := opts.SmallFileBatch
+	if batch <= 0 {
+		batch = defaultSmallFileBatch
+	}
+
+	src, err = filepath.Abs(src)
+	if err != nil {
+		return ufs.wrapError(err, "CopyDirectoryParallel")
+	}
+	dst, err = filepath.Abs(dst)
+	if err != nil {
+		return ufs.wrapError(err, "CopyDirectoryParallel")
+	}
+
+	// Create the directories first, so workers only have to copy files
+	scheduler := &copyScheduler{batch: batch, maxLarge: workers}
+	err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkAbort, "CopyDirectoryParallel")
+		}
+
+		// Don't copy the destination into itself when it is inside the source
+		if path == dst {
+			return filepath.SkipDir
+		}
+
+		if path != src && ufs.skipHidden(fs.FileInfoToDirEntry(info)) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, relPath)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+
+		job := copyJob{src: path, dst: target, size: info.Size()}
+		if opts.Schedule == ScheduleInterleave && info.Size() >= threshold {
+			job.large = true
+			scheduler.large = append(scheduler.large, job)
+		} else {
+			scheduler.small = append(scheduler.small, job)
+		}
+		return nil
+	})
+	if err != nil {
+		return ufs.wrapError(err, "CopyDirectoryParallel")
+	}
+
+	if opts.Schedule == ScheduleInterleave {
+		scheduler.prepareInterleave(workers)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer ufs.applyIOPriority()()
+
+			for {
+				job, ok := scheduler.next()
+				if !ok {
+					return
+				}
+				scheduler.done(job, ufs.CopyFileWithPermissions(job.src, job.dst))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if scheduler.err != nil {
+		return ufs.wrapError(scheduler.err, "CopyDirectoryParallel")
+	}
+	return nil
+}
+
+// copyScheduler hands copy jobs to the workers of CopyDirectoryParallel
+type copyScheduler struct {
+	mu sync.Mutex
+
+	large []copyJob
+	small []copyJob
+
+	batch           int // small files handed out between two large files
+	smallSinceLarge int
+	largeInFlight   int
+	maxLarge        int // workers allowed to copy large files at the same time
+
+	err error // first error, stops the scheduling
+}
+
+// prepareInterleave orders large files biggest first and keeps a worker free for small files
+func (s *copyScheduler) prepareInterleave(workers int) {
+	sort.SliceStable(s.large, func(i, j int) bool {
+		return s.large[i].size > s.large[j].size
+	})
+	if workers > 1 {
+		s.maxLarge = workers - 1
+	}
+}
+
+// next returns the next job to copy, or false when there is nothing left to do
+func (s *copyScheduler) next() (copyJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return copyJob{}, false
+	}
+
+	takeLarge := len(s.large) > 0 && s.largeInFlight < s.maxLarge &&
+		(s.smallSinceLarge >= s.batch || len(s.small) == 0)
+
+	// Once small files are all handed out, large files don't need a limit
+	if !takeLarge && len(s.small) == 0 && len(s.large) > 0 {
+		takeLarge = true
+	}
+
+	if takeLarge {
+		job := s.large[0]
+		s.large = s.large[1:]
+		s.largeInFlight++
+		s.smallSinceLarge = 0
+		return job, true
+	}
+
+	if len(s.small) > 0 {
+		job := s.small[0]
+		s.small = s.small[1:]
+		s.smallSinceLarge++
+		return job, true
+	}
+
+	return copyJob{}, false
+}
+
+// done records the end of a job
+func (s *copyScheduler) done(job copyJob, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job.large {
+		s.largeInFlight--
+	}
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+}
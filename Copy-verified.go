@@ -0,0 +1,194 @@
+package ufs
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+/*
+Copy-verified.go contains copy functions checking the integrity of what they wrote, for backups.
+
+The checksum of the data is computed while it is written, then the destination file is synced and read
+back, and its checksum compared. A difference (bad disk, faulty network share, cable...) fails the copy with
+a *CopyVerificationError, matching ErrCopyVerificationFailed, and the corrupted destination is removed.
+Files are always copied, never cloned (see File-clone.go), so the check covers the written data.
+With Options.DirectIO, the destination is read back from the disk rather than from the page cache.
+
+Functions:
+- CopyFileVerified: Copies a file and verifies the copy.
+- CopyDirectoryVerified: Copies a directory tree like CopyDirectory and verifies every file.
+*/
+
+// CopyFileVerified copies a file like CopyFileWithPermissions, then reads the copy back and compares
+// its checksum with the one of the data written.
+// If the destination file already exists, it will be overwritten.
+// This function will create any parent directories for the destination if they don't exist.
+//
+// Parameters:
+//   - src: The absolute or relative path to the source file
+//   - dst: The absolute or relative path to the destination file
+//   - algo: The checksum algorithm, "" uses HashSHA256; HashXXH64 is much faster for large backups
+//
+// Returns:
+//   - string: The hex encoded checksum of the file, e.g. to record in a manifest
+//   - error: A *CopyVerificationError if the copy doesn't match, another error if the file couldn't be
+//     copied, nil otherwise
+//
+// Example:
+//
+//	sum, err := ufs.CopyFileVerified("/data/db.sqlite", "/mnt/backup/db.sqlite", ufs.HashSHA256)
+//	if errors.Is(err, ufs.ErrCopyVerificationFailed) {
+//	    fmt.Println("The backup disk returned corrupted data")
+//	} else if err != nil {
+//	    fmt.Printf("Error copying file: %v\n", err)
+//	} else {
+//	    fmt.Printf("Copied, sha256 %s\n", sum)
+//	}
+func (ufs *UFS) CopyFileVerified(src, dst string, algo HashAlgorithm) (_ string, err error) {
+	defer ufs.recoverPanic("CopyFileVerified", &err)
+
+	if err := ufs.requireOS("CopyFileVerified"); err != nil {
+		return "", err
+	}
+	defer ufs.applyIOPriority()()
+
+	info, err := os.Stat(src)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", ufs.misuseError("CopyFileVerified", "source is not a file", src)
+	}
+
+	sum, err := ufs.copyFileVerified(src, dst, info, algo)
+	if err != nil {
+		return "", ufs.wrapError(err, "CopyFileVerified")
+	}
+	return sum, nil
+}
+
+// CopyDirectoryVerified copies a directory tree like CopyDirectory, verifying every file copied like
+// CopyFileVerified. Files failing the verification are removed from the destination and listed in
+// CopyReport.Failed with a *CopyVerificationError.
+//
+// Parameters:
+//   - src: The absolute or relative path to the source directory
+//   - dst: The absolute or relative path to the destination directory, created if needed
+//   - algo: The checksum algorithm, "" uses HashSHA256
+//   - opts: The copy settings, nil uses the defaults of CopyDirectory
+//
+// Returns:
+//   - *CopyReport: What was copied, skipped, and every entry that couldn't be copied or verified
+//   - error: An error matching ErrCopyVerificationFailed if any file failed the verification, else the
+//     errors of CopyDirectory
+//
+// Example:
+//
+//	report, err := ufs.CopyDirectoryVerified("/srv/photos", "/mnt/backup/photos", ufs.HashXXH64, nil)
+//	if errors.Is(err, ufs.ErrCopyVerificationFailed) {
+//	    for _, failure := range report.Failed {
+//	        fmt.Printf("%s: %v\n", failure.Path, failure.Err)
+//	    }
+//	}
+func (ufs *UFS) CopyDirectoryVerified(src, dst string, algo HashAlgorithm, opts *CopyDirectoryOptions) (_ *CopyReport, err error) {
+	defer ufs.recoverPanic("CopyDirectoryVerified", &err)
+
+	if err := ufs.requireOS("CopyDirectoryVerified"); err != nil {
+		return nil, err
+	}
+	defer ufs.applyIOPriority()()
+
+	if opts == nil {
+		opts = &CopyDirectoryOptions{}
+	}
+	if algo == "" {
+		algo = HashSHA256
+	}
+	if _, err := algo.newHash(); err != nil {
+		return nil, ufs.wrapError(err, "CopyDirectoryVerified")
+	}
+
+	src, err = filepath.Abs(src)
+	if err != nil {
+		return nil, ufs.wrapError(err, "CopyDirectoryVerified")
+	}
+	dst, err = filepath.Abs(dst)
+	if err != nil {
+		return nil, ufs.wrapError(err, "CopyDirectoryVerified")
+	}
+	info, err := os.Stat(src)
+	if err != nil || !info.IsDir() {
+		return nil, ufs.misuseError("CopyDirectoryVerified", "source path is not a directory", src)
+	}
+
+	copier := &directoryCopier{ufs: ufs, opts: opts, src: src, dst: dst, report: &CopyReport{}, verify: algo}
+	if err := copier.copyDirectory(src, dst, info, nil); err != nil {
+		return copier.report, ufs.wrapError(err, "CopyDirectoryVerified")
+	}
+
+	var failed []error
+	for _, failure := range copier.report.Failed {
+		if errors.Is(failure.Err, ErrCopyVerificationFailed) {
+			failed = append(failed, failure.Err)
+		}
+	}
+	if len(failed) > 0 {
+		return copier.report, fmt.Errorf("CopyDirectoryVerified: %d of %d files failed the verification, first: %w",
+			len(failed), copier.report.Files+len(failed), failed[0])
+	}
+	return copier.report, nil
+}
+
+// copyFileVerified copies the file src, described by info, to dst with its permissions, then checks
+// the copy, returning its checksum
+func (ufs *UFS) copyFileVerified(src, dst string, info os.FileInfo, algo HashAlgorithm) (string, error) {
+	if algo == "" {
+		algo = HashSHA256
+	}
+	hasher, err := algo.newHash()
+	if err != nil {
+		return "", err
+	}
+	if err := ufs.checkQuota(dst, info.Size()); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", err
+	}
+
+	srcFile, err := ufs.openSequential(src)
+	if err != nil {
+		return "", err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return "", err
+	}
+	defer ufs.recordQuota(dst)
+
+	_, err = io.Copy(io.MultiWriter(dstFile, hasher), srcFile)
+	if err == nil {
+		// Flushed to the disk, so the data read back is the one stored
+		err = dstFile.Sync()
+	}
+	if closeErr := dstFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	expected := hex.EncodeToString(hasher.Sum(nil))
+	actual, err := ufs.fileChecksum(dst, algo)
+	if err != nil {
+		return "", err
+	}
+	if actual != expected {
+		os.Remove(dst)
+		return "", &CopyVerificationError{Source: src, Destination: dst, Algorithm: algo, Expected: expected, Actual: actual}
+	}
+	return expected, nil
+}
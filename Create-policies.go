@@ -0,0 +1,71 @@
+package ufs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Create-policies.go contains the per-extension defaults applied to new files.
+
+Scaffolding tools usually chmod every script they create and prepend the same shebang or license header.
+Options.CreatePolicies does it once: CreateFile and CreateFileWithContent look for the first policy
+whose pattern matches the file name and apply its mode and header.
+
+	u := ufs.NewUfs(&ufs.Options{CreatePolicies: []ufs.CreatePolicy{
+	    {Pattern: "*.sh", Mode: 0755, Header: "#!/usr/bin/env bash\n"},
+	    {Pattern: "*.go", Header: "// Code owned by the platform team.\n\n"},
+	}})
+	u.CreateFileWithContent("deploy.sh", "echo deploying\n") // executable, starts with the shebang
+*/
+
+// CreatePolicy gives default permissions and content to the files created by CreateFile and CreateFileWithContent.
+type CreatePolicy struct {
+	// Pattern is matched against the base name of the file with filepath.Match, e.g. "*.sh" or "Makefile"
+	Pattern string
+
+	// Mode is set on the file after creation, regardless of the umask. 0 keeps the default permissions
+	Mode fs.FileMode
+
+	// Header is written at the top of the file. Content already starting with the header is not changed
+	Header string
+}
+
+// createPolicy returns the first policy matching the file name, nil if none does.
+// Invalid patterns never match.
+func (ufs *UFS) createPolicy(path string) *CreatePolicy {
+	name := filepath.Base(path)
+	for i := range ufs.opts.CreatePolicies {
+		policy := &ufs.opts.CreatePolicies[i]
+		if matched, err := filepath.Match(policy.Pattern, name); err == nil && matched {
+			return policy
+		}
+	}
+	return nil
+}
+
+// create creates or truncates a file of backend like os.Create, then applies the policy mode
+func (policy *CreatePolicy) create(backend Backend, path string) (BackendFile, error) {
+	file, err := backend.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	if policy != nil && policy.Mode != 0 {
+		if err := backend.Chmod(path, policy.Mode); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+// content returns the content to write, prefixed with the policy header
+func (policy *CreatePolicy) content(content string) string {
+	if policy == nil || policy.Header == "" || strings.HasPrefix(content, policy.Header) {
+		return content
+	}
+	return policy.Header + content
+}
@@ -1,6 +1,7 @@
 package ufs
 
 import (
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -17,10 +18,12 @@ Some especial methods includes:
 create file with content, create directory with permissions,
 create symbolic link, and create a directory tree with specified permissions.
 also provides option to symlink whole directory tree.
+and to read an existing directory back into the structure accepted by CreateDirectoryTree.
 */
 
 // CreateFile creates a new empty file at the specified path.
 // If the file already exists, it will be truncated to zero length.
+// When one of Options.CreatePolicies matches the file name, its mode is set and its header written.
 //
 // Parameters:
 //   - path: The absolute or relative path to the file to create
@@ -34,18 +37,30 @@ also provides option to symlink whole directory tree.
 //	if !ok {
 //	    fmt.Printf("Error creating file\n")
 //	}
-func (ufs *UFS) CreateFile(path string) bool {
-	file, err := os.Create(path)
+func (ufs *UFS) CreateFile(path string) (ok bool) {
+	defer ufs.recoverPanicBool("CreateFile", &ok)
+
+	policy := ufs.createPolicy(path)
+	file, err := policy.create(ufs.backend(), path)
 	if err != nil {
 		ufs.handleError(err, "CreateFile")
 		return false
 	}
 	defer file.Close()
+
+	if header := policy.content(""); header != "" {
+		if _, err := io.WriteString(file, header); err != nil {
+			ufs.handleError(err, "CreateFile")
+			return false
+		}
+	}
 	return true
 }
 
 // CreateFileWithContent creates a new file at the specified path with the given content.
 // If the file already exists, it will be overwritten.
+// When one of Options.CreatePolicies matches the file name, its mode is set and its header
+// is written before the content, unless the content already starts with it.
 //
 // Parameters:
 //   - path: The absolute or relative path to the file to create
@@ -60,15 +75,18 @@ func (ufs *UFS) CreateFile(path string) bool {
 //	if !ok {
 //	    fmt.Printf("Error creating file with content\n")
 //	}
-func (ufs *UFS) CreateFileWithContent(path string, content string) bool {
-	file, err := os.Create(path)
+func (ufs *UFS) CreateFileWithContent(path string, content string) (ok bool) {
+	defer ufs.recoverPanicBool("CreateFileWithContent", &ok)
+
+	policy := ufs.createPolicy(path)
+	file, err := policy.create(ufs.backend(), path)
 	if err != nil {
 		ufs.handleError(err, "CreateFileWithContent")
 		return false
 	}
 	defer file.Close()
 
-	_, err = file.WriteString(content)
+	_, err = io.WriteString(file, policy.content(content))
 	if err != nil {
 		ufs.handleError(err, "CreateFileWithContent")
 		return false
@@ -94,15 +112,17 @@ func (ufs *UFS) CreateFileWithContent(path string, content string) bool {
 //	if !ok {
 //	    fmt.Printf("Error creating file with content and permissions\n")
 //	}
-func (ufs *UFS) CreateFileWithContentAndPermissions(path string, content string, perm fs.FileMode) bool {
-	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+func (ufs *UFS) CreateFileWithContentAndPermissions(path string, content string, perm fs.FileMode) (ok bool) {
+	defer ufs.recoverPanicBool("CreateFileWithContentAndPermissions", &ok)
+
+	file, err := ufs.backend().OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
 	if err != nil {
 		ufs.handleError(err, "CreateFileWithContentAndPermissions")
 		return false
 	}
 	defer file.Close()
 
-	_, err = file.WriteString(content)
+	_, err = io.WriteString(file, content)
 	if err != nil {
 		ufs.handleError(err, "CreateFileWithContentAndPermissions")
 		return false
@@ -127,8 +147,10 @@ func (ufs *UFS) CreateFileWithContentAndPermissions(path string, content string,
 //	if !ok {
 //	    fmt.Printf("Error creating file with permissions\n")
 //	}
-func (ufs *UFS) CreateFileWithPermissions(path string, perm fs.FileMode) bool {
-	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+func (ufs *UFS) CreateFileWithPermissions(path string, perm fs.FileMode) (ok bool) {
+	defer ufs.recoverPanicBool("CreateFileWithPermissions", &ok)
+
+	file, err := ufs.backend().OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
 	if err != nil {
 		ufs.handleError(err, "CreateFileWithPermissions")
 		return false
@@ -152,8 +174,10 @@ func (ufs *UFS) CreateFileWithPermissions(path string, perm fs.FileMode) bool {
 //	if !ok {
 //	    fmt.Printf("Error creating directory\n")
 //	}
-func (ufs *UFS) CreateDirectory(path string) bool {
-	err := os.MkdirAll(path, 0755) // Default permissions: rwxr-xr-x
+func (ufs *UFS) CreateDirectory(path string) (ok bool) {
+	defer ufs.recoverPanicBool("CreateDirectory", &ok)
+
+	err := mkdirAllBackend(ufs.backend(), path, 0755) // Default permissions: rwxr-xr-x
 	if err != nil {
 		ufs.handleError(err, "CreateDirectory")
 		return false
@@ -177,8 +201,10 @@ func (ufs *UFS) CreateDirectory(path string) bool {
 //	if err != nil {
 //	    fmt.Printf("Error creating directory with permissions: %v\n", err)
 //	}
-func (ufs *UFS) CreateDirectoryWithPermissions(path string, perm fs.FileMode) bool {
-	err := os.MkdirAll(path, perm)
+func (ufs *UFS) CreateDirectoryWithPermissions(path string, perm fs.FileMode) (ok bool) {
+	defer ufs.recoverPanicBool("CreateDirectoryWithPermissions", &ok)
+
+	err := mkdirAllBackend(ufs.backend(), path, perm)
 	if err != nil {
 		ufs.handleError(err, "CreateDirectoryWithPermissions")
 		return false
@@ -201,7 +227,18 @@ func (ufs *UFS) CreateDirectoryWithPermissions(path string, perm fs.FileMode) bo
 //	if !ok {
 //	    fmt.Printf("Error creating symlink\n")
 //	}
-func (ufs *UFS) CreateSymlink(target string, symlink string) bool {
+func (ufs *UFS) CreateSymlink(target string, symlink string) (ok bool) {
+	defer ufs.recoverPanicBool("CreateSymlink", &ok)
+
+	if !ufs.requireOSBool("CreateSymlink") {
+		return false
+	}
+
+	if err := ufs.requireCapability(CapabilitySymlinks, "CreateSymlink", symlink); err != nil {
+		ufs.handleError(err, "CreateSymlink")
+		return false
+	}
+
 	err := os.Symlink(target, symlink)
 	if err != nil {
 		ufs.handleError(err, "CreateSymlink")
@@ -226,7 +263,18 @@ func (ufs *UFS) CreateSymlink(target string, symlink string) bool {
 //	if !ok {
 //	    fmt.Printf("Error creating hard link\n")
 //	}
-func (ufs *UFS) CreateHardLink(target string, link string) bool {
+func (ufs *UFS) CreateHardLink(target string, link string) (ok bool) {
+	defer ufs.recoverPanicBool("CreateHardLink", &ok)
+
+	if !ufs.requireOSBool("CreateHardLink") {
+		return false
+	}
+
+	if err := ufs.requireCapability(CapabilityHardLinks, "CreateHardLink", link); err != nil {
+		ufs.handleError(err, "CreateHardLink")
+		return false
+	}
+
 	err := os.Link(target, link)
 	if err != nil {
 		ufs.handleError(err, "CreateHardLink")
@@ -238,6 +286,8 @@ func (ufs *UFS) CreateHardLink(target string, link string) bool {
 // CreateDirectoryTree creates a directory tree based on the provided structure.
 // The structure is a map where keys are directory names and values are either
 // nil (for empty directories) or nested maps (for subdirectories).
+// A string value creates a file with that string as content instead of a directory,
+// which allows the structures produced by ReadDirectoryTree to be recreated.
 //
 // Parameters:
 //   - basePath: The base directory path where the tree will be created
@@ -255,13 +305,16 @@ func (ufs *UFS) CreateHardLink(target string, link string) bool {
 //	        "subdir2": map[string]interface{}{
 //	            "subsubdir": nil,
 //	        },
+//	        "README.md": "# Hello",
 //	    },
 //	}
 //	err := ufs.CreateDirectoryTree("/path/to/base", structure)
 //	if err != nil {
 //	    fmt.Printf("Error creating directory tree: %v\n", err)
 //	}
-func (ufs *UFS) CreateDirectoryTree(basePath string, structure map[string]interface{}) bool {
+func (ufs *UFS) CreateDirectoryTree(basePath string, structure map[string]interface{}) (ok bool) {
+	defer ufs.recoverPanicBool("CreateDirectoryTree", &ok)
+
 	// Create the base directory if it doesn't exist
 	if !ufs.CreateDirectory(basePath) {
 		return false
@@ -270,8 +323,16 @@ func (ufs *UFS) CreateDirectoryTree(basePath string, structure map[string]interf
 	// Iterate through the structure and create subdirectories
 	for dirName, subStructure := range structure {
 		dirPath := filepath.Join(basePath, dirName)
-		err := ufs.CreateDirectory(dirPath)
-		if err {
+
+		// String leaves are files
+		if content, ok := subStructure.(string); ok {
+			if !ufs.CreateFileWithContent(dirPath, content) {
+				return false
+			}
+			continue
+		}
+
+		if !ufs.CreateDirectory(dirPath) {
 			return false
 		}
 
@@ -291,6 +352,7 @@ func (ufs *UFS) CreateDirectoryTree(basePath string, structure map[string]interf
 // CreateDirectoryTreeWithPermissions creates a directory tree with the specified permissions.
 // The structure is a map where keys are directory names and values are either
 // nil (for empty directories) or nested maps (for subdirectories).
+// A string value creates a file with that string as content, files keep the default file permissions.
 //
 // Parameters:
 //   - basePath: The base directory path where the tree will be created
@@ -312,9 +374,11 @@ func (ufs *UFS) CreateDirectoryTree(basePath string, structure map[string]interf
 //	if err != nil {
 //	    fmt.Printf("Error creating directory tree with permissions: %v\n", err)
 //	}
-func (ufs *UFS) CreateDirectoryTreeWithPermissions(basePath string, structure map[string]interface{}, perm fs.FileMode) bool {
+func (ufs *UFS) CreateDirectoryTreeWithPermissions(basePath string, structure map[string]interface{}, perm fs.FileMode) (ok bool) {
+	defer ufs.recoverPanicBool("CreateDirectoryTreeWithPermissions", &ok)
+
 	// Create the base directory if it doesn't exist
-	ok := ufs.CreateDirectoryWithPermissions(basePath, perm)
+	ok = ufs.CreateDirectoryWithPermissions(basePath, perm)
 	if !ok {
 		return false
 	}
@@ -322,6 +386,15 @@ func (ufs *UFS) CreateDirectoryTreeWithPermissions(basePath string, structure ma
 	// Iterate through the structure and create subdirectories
 	for dirName, subStructure := range structure {
 		dirPath := filepath.Join(basePath, dirName)
+
+		// String leaves are files
+		if content, ok := subStructure.(string); ok {
+			if !ufs.CreateFileWithContent(dirPath, content) {
+				return false
+			}
+			continue
+		}
+
 		ok := ufs.CreateDirectoryWithPermissions(dirPath, perm)
 		if !ok {
 			return false
@@ -340,6 +413,87 @@ func (ufs *UFS) CreateDirectoryTreeWithPermissions(basePath string, structure ma
 	return true
 }
 
+// ReadDirectoryTree reads an existing directory and returns its structure in the same
+// format accepted by CreateDirectoryTree, so structures can be round-tripped between machines and tests.
+// Directories become nested maps (nil when empty). When includeFiles is true, files are
+// included as string leaves holding an empty string; otherwise they are left out.
+//
+// Parameters:
+//   - basePath: The absolute or relative path of the directory to read
+//   - includeFiles: If true, files are included as string leaves
+//
+// Returns:
+//   - map[string]interface{}: The directory structure, or nil if the directory couldn't be read
+//
+// Example:
+//
+//	structure := ufs.ReadDirectoryTree("/path/to/template", true)
+//	ok := ufs.CreateDirectoryTree("/path/to/new_project", structure)
+//	if !ok {
+//	    fmt.Printf("Error recreating directory tree\n")
+//	}
+func (ufs *UFS) ReadDirectoryTree(basePath string, includeFiles bool) map[string]interface{} {
+	defer ufs.recoverPanicReport("ReadDirectoryTree")
+
+	if !ufs.IsDirectory(basePath) {
+		return nil
+	}
+
+	basePath = filepath.Clean(basePath)
+	structure := map[string]interface{}{}
+	dirs := map[string]map[string]interface{}{basePath: structure}
+
+	err := filepath.WalkDir(basePath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if path == basePath {
+				return err
+			}
+			if err := ufs.decideWalkError(path, err, WalkAbort, "ReadDirectoryTree"); err != nil {
+				return err
+			}
+			// Leave the unreadable entry out of the structure
+			delete(dirs[filepath.Dir(path)], filepath.Base(path))
+			return filepath.SkipDir
+		}
+		if path == basePath {
+			return nil
+		}
+
+		parent := dirs[filepath.Dir(path)]
+		if d.IsDir() {
+			subStructure := map[string]interface{}{}
+			dirs[path] = subStructure
+			parent[d.Name()] = subStructure
+		} else if includeFiles {
+			parent[d.Name()] = ""
+		}
+		return nil
+	})
+	if err != nil {
+		ufs.handleError(err, "ReadDirectoryTree")
+		return nil
+	}
+
+	emptyDirectoriesToNil(structure)
+	return structure
+}
+
+// emptyDirectoriesToNil replaces empty nested maps with nil, which is how
+// CreateDirectoryTree represents empty directories
+func emptyDirectoriesToNil(structure map[string]interface{}) {
+	for name, value := range structure {
+		subStructure, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if len(subStructure) == 0 {
+			structure[name] = nil
+		} else {
+			emptyDirectoriesToNil(subStructure)
+		}
+	}
+}
+
 // SymlinkDirectoryTree creates symbolic links for an entire directory tree.
 // This function walks through the source directory tree and creates corresponding
 // symbolic links in the destination directory.
@@ -359,14 +513,25 @@ func (ufs *UFS) CreateDirectoryTreeWithPermissions(basePath string, structure ma
 //	if err != nil {
 //	    fmt.Printf("Error symlinking directory tree: %v\n", err)
 //	}
-func (ufs *UFS) SymlinkDirectoryTree(sourceDir string, destDir string, recursive bool) bool {
+func (ufs *UFS) SymlinkDirectoryTree(sourceDir string, destDir string, recursive bool) (ok bool) {
+	defer ufs.recoverPanicBool("SymlinkDirectoryTree", &ok)
+
+	if !ufs.requireOSBool("SymlinkDirectoryTree") {
+		return false
+	}
+
+	if err := ufs.requireCapability(CapabilitySymlinks, "SymlinkDirectoryTree", destDir); err != nil {
+		ufs.handleError(err, "SymlinkDirectoryTree")
+		return false
+	}
+
 	// Ensure the source directory exists
 	if !ufs.IsDirectory(sourceDir) {
 		return false
 	}
 
 	// Create the destination directory if it doesn't exist
-	ok := ufs.CreateDirectory(destDir)
+	ok = ufs.CreateDirectory(destDir)
 	if !ok {
 		return false
 	}
@@ -0,0 +1,107 @@
+package ufs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+/*
+Cross-device-move.go contains the fallback of MoveFile and MoveFileWithPermissions when the source and
+the destination are on different file systems (EXDEV on Unix, ERROR_NOT_SAME_DEVICE on Windows),
+where a rename is impossible.
+
+The file is then copied as fast as the OS allows: cloned when both paths are on the same file system
+mounted twice (Btrfs subvolumes, bind mounts), else copied by the kernel (copy_file_range or sendfile
+on Linux) without going through the buffers of the program. The copy is written to a temporary file
+next to the destination with the permissions and modification time of the source, synced to disk,
+then renamed into place, and only then is the source removed: a crash during the move leaves the
+source intact and at most a temporary file, never a truncated destination nor a lost file.
+
+Other rename failures (permission denied, file in use, ...) are reported as is, they are not
+retried with a copy.
+*/
+
+// moveAcrossDevices moves a file to another file system, see Cross-device-move.go.
+// dest is replaced if it exists, unless noReplace is set: an error wrapping os.ErrExist is then returned.
+func (ufs *UFS) moveAcrossDevices(src, dest string, noReplace bool) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	temp := filepath.Join(filepath.Dir(dest), fmt.Sprintf(".%s.move-%d", filepath.Base(dest), os.Getpid()))
+	if err := ufs.copyForMove(src, temp, info); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	rename := os.Rename
+	if noReplace {
+		rename = renameNoReplace
+	}
+	if err := rename(temp, dest); err != nil {
+		os.Remove(temp)
+		return err
+	}
+
+	// The rename must be on disk before the source disappears
+	if err := syncDirectory(filepath.Dir(dest)); err != nil {
+		return err
+	}
+	if err := ufs.copyMacMetadata(src, dest); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// copyForMove copies src to a new file at temp with the permissions and modification time of
+// the source, synced to disk
+func (ufs *UFS) copyForMove(src, temp string, info os.FileInfo) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	tempFile, err := os.OpenFile(temp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer tempFile.Close()
+
+	// Reading *os.File lets the runtime copy in the kernel when it can't be cloned
+	if ufs.opts.DisableReflink || cloneFile(srcFile, tempFile) != nil {
+		if _, err := tempFile.ReadFrom(srcFile); err != nil {
+			return err
+		}
+	}
+
+	// The mode given to OpenFile is reduced by the umask
+	if err := tempFile.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := tempFile.Sync(); err != nil {
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	// A zero access time is left unchanged
+	return os.Chtimes(temp, time.Time{}, info.ModTime())
+}
+
+// syncDirectory flushes the entries of a directory to disk, so a rename in it survives a crash.
+// Windows can't open directories for syncing, its renames are journaled by NTFS.
+func syncDirectory(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
@@ -0,0 +1,210 @@
+package ufs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+/*
+Csv-files.go contains functions to read and write CSV files.
+
+ReadCSVFile and WriteCSVFile handle small files in memory. IterateCSVRows streams a file row by row,
+with a configurable delimiter (e.g. ';' or '\t') and an optional header row, whose column names can
+then be used to read the fields of every row.
+
+The UTF-8 byte order mark written by spreadsheet programs at the start of files is ignored.
+
+Functions:
+- ReadCSVFile: Reads all the rows of a CSV file.
+- WriteCSVFile: Atomically writes rows to a CSV file.
+- IterateCSVRows: Calls a function for every row of a CSV file, one row in memory at a time.
+*/
+
+// utf8BOM is the byte order mark some programs write at the start of UTF-8 files
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// CSVOptions controls IterateCSVRows. The zero value reads comma separated rows without header.
+type CSVOptions struct {
+	// Delimiter separates the fields, 0 uses ','
+	Delimiter rune
+
+	// Comment starts comment lines, which are skipped, 0 disables comments
+	Comment rune
+
+	// HasHeader reads the first row as the column names instead of passing it to the callback
+	HasHeader bool
+
+	// LazyQuotes accepts quotes in unquoted fields and unescaped quotes in quoted fields
+	LazyQuotes bool
+
+	// TrimLeadingSpace ignores the spaces at the start of the fields
+	TrimLeadingSpace bool
+}
+
+// CSVRow is a row read by IterateCSVRows.
+type CSVRow struct {
+	Number int      // Row number, starting at 1 with the first row after the header
+	Line   int      // Line number of the start of the row in the file, starting at 1
+	Fields []string // Fields of the row
+	Header []string // Column names read from the header row, nil without CSVOptions.HasHeader
+}
+
+// Get returns the field of the row in a column of the header, "" if the column doesn't exist
+func (row CSVRow) Get(column string) string {
+	for i, name := range row.Header {
+		if name == column && i < len(row.Fields) {
+			return row.Fields[i]
+		}
+	}
+	return ""
+}
+
+// ReadCSVFile reads all the rows of a comma separated file.
+// The whole file is held in memory, use IterateCSVRows for large files.
+//
+// Parameters:
+//   - path: The absolute or relative path to the CSV file
+//
+// Returns:
+//   - [][]string: The rows of the file, header included
+//   - error: An error if the file couldn't be read or isn't valid CSV, nil otherwise
+//
+// Example:
+//
+//	rows, err := ufs.ReadCSVFile("users.csv")
+//	if err != nil {
+//	    fmt.Printf("Error reading CSV: %v\n", err)
+//	    return
+//	}
+//	for _, row := range rows[1:] {
+//	    fmt.Println(row[0])
+//	}
+func (ufs *UFS) ReadCSVFile(path string) (_ [][]string, err error) {
+	defer ufs.recoverPanic("ReadCSVFile", &err)
+
+	data, err := ufs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, utf8BOM))).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("ReadCSVFile: invalid CSV in %s: %w", path, err)
+	}
+	return rows, nil
+}
+
+// WriteCSVFile writes rows to a comma separated file, atomically, creating it if it doesn't exist
+// or replacing it if it does. Fields are quoted when needed.
+// This function will create any parent directories if they don't exist.
+//
+// Parameters:
+//   - path: The absolute or relative path to the CSV file
+//   - rows: The rows to write, header included
+//
+// Returns:
+//   - error: An error if the file couldn't be written, nil otherwise
+//
+// Example:
+//
+//	rows := [][]string{{"name", "email"}, {"Ada", "ada@example.com"}}
+//	if err := ufs.WriteCSVFile("users.csv", rows); err != nil {
+//	    fmt.Printf("Error writing CSV: %v\n", err)
+//	}
+func (ufs *UFS) WriteCSVFile(path string, rows [][]string) (err error) {
+	defer ufs.recoverPanic("WriteCSVFile", &err)
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.WriteAll(rows); err != nil {
+		return ufs.wrapError(err, "WriteCSVFile")
+	}
+
+	return ufs.WriteFileAtomic(path, buf.Bytes())
+}
+
+// IterateCSVRows calls fn for every row of a CSV file, holding a single row in memory,
+// so files of any size can be processed.
+//
+// Parameters:
+//   - path: The absolute or relative path to the CSV file
+//   - opts: The delimiter and header settings, nil reads comma separated rows without header
+//   - fn: The function called for every row. Returning stop = true ends the iteration without error,
+//     returning an error ends it with that error
+//
+// Returns:
+//   - error: The error returned by fn, or an error if the file couldn't be read or isn't valid CSV, nil otherwise
+//
+// Example:
+//
+//	total := 0.0
+//	err := ufs.IterateCSVRows("orders.csv", &ufs.CSVOptions{Delimiter: ';', HasHeader: true},
+//	    func(row ufs.CSVRow) (bool, error) {
+//	        amount, err := strconv.ParseFloat(row.Get("amount"), 64)
+//	        if err != nil {
+//	            return true, fmt.Errorf("line %d: %w", row.Line, err)
+//	        }
+//	        total += amount
+//	        return false, nil
+//	    })
+//	if err != nil {
+//	    fmt.Printf("Error reading orders: %v\n", err)
+//	}
+func (ufs *UFS) IterateCSVRows(path string, opts *CSVOptions, fn func(row CSVRow) (stop bool, err error)) (err error) {
+	defer ufs.recoverPanic("IterateCSVRows", &err)
+
+	if opts == nil {
+		opts = &CSVOptions{}
+	}
+	if !ufs.IsFile(path) {
+		return ufs.misuseError("IterateCSVRows", "path is not a file", path)
+	}
+
+	file, err := ufs.openSequential(path)
+	if err != nil {
+		return ufs.wrapError(err, "IterateCSVRows")
+	}
+	defer file.Close()
+
+	buffered := bufio.NewReaderSize(file, streamBufferSize)
+	if bom, err := buffered.Peek(len(utf8BOM)); err == nil && bytes.Equal(bom, utf8BOM) {
+		buffered.Discard(len(utf8BOM))
+	}
+
+	reader := csv.NewReader(buffered)
+	if opts.Delimiter != 0 {
+		reader.Comma = opts.Delimiter
+	}
+	reader.Comment = opts.Comment
+	reader.LazyQuotes = opts.LazyQuotes
+	reader.TrimLeadingSpace = opts.TrimLeadingSpace
+
+	var header []string
+	for number := 1; ; {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("IterateCSVRows: invalid CSV in %s: %w", path, err)
+		}
+
+		if opts.HasHeader && header == nil {
+			header = fields
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		stop, err := fn(CSVRow{Number: number, Line: line, Fields: fields, Header: header})
+		if err != nil {
+			return ufs.wrapError(err, "IterateCSVRows")
+		}
+		if stop {
+			return nil
+		}
+		number++
+	}
+}
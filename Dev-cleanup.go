@@ -0,0 +1,211 @@
+package ufs
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+/*
+Dev-cleanup.go contains functions to reclaim the disk space used by build artifacts on development machines.
+
+Dependency folders and build outputs (node_modules, target/, __pycache__, ...) can be recreated at any time
+but quickly use gigabytes across old projects. CleanDevArtifacts finds them with profiles, reports how much
+space they use, and only deletes them when the report is confirmed:
+
+	report, _ := ufs.CleanDevArtifacts("/home/me/projects")
+	fmt.Printf("%d bytes reclaimable\n", report.TotalSize)
+	if askUser() {
+	    report.Delete()
+	}
+
+A profile matches directories by name, optionally only next to a marker file (target/ is only a
+Rust artifact next to a Cargo.toml), so unrelated folders with common names are left alone.
+
+Functions:
+- CleanDevArtifacts: Finds the build artifacts of a tree and reports their size.
+- DevArtifactReport.Delete: Deletes the artifacts of a report.
+*/
+
+// DevArtifactProfile describes a kind of build artifact directory.
+type DevArtifactProfile struct {
+	Name     string   // Name shown in reports
+	DirNames []string // Names of the artifact directories
+	Markers  []string // Files of which at least one must exist next to the directory, empty matches everywhere
+}
+
+// Built-in profiles used by CleanDevArtifacts
+var (
+	DevProfileNode = DevArtifactProfile{
+		Name:     "node",
+		DirNames: []string{"node_modules"},
+		Markers:  []string{"package.json"},
+	}
+	DevProfileRust = DevArtifactProfile{
+		Name:     "rust",
+		DirNames: []string{"target"},
+		Markers:  []string{"Cargo.toml"},
+	}
+	DevProfileBuild = DevArtifactProfile{
+		Name:     "build",
+		DirNames: []string{"build"},
+		Markers:  []string{"CMakeLists.txt", "build.gradle", "build.gradle.kts", "package.json", "pyproject.toml", "setup.py"},
+	}
+	DevProfilePython = DevArtifactProfile{
+		Name:     "python",
+		DirNames: []string{"__pycache__", ".venv", ".pytest_cache", ".mypy_cache"},
+	}
+)
+
+// DefaultDevProfiles are the profiles used when CleanDevArtifacts is called without any
+var DefaultDevProfiles = []DevArtifactProfile{DevProfileNode, DevProfileRust, DevProfileBuild, DevProfilePython}
+
+// DevArtifact is a build artifact directory found by CleanDevArtifacts.
+type DevArtifact struct {
+	Path    string // Absolute path of the directory
+	Profile string // Name of the profile that matched it
+	Size    int64  // Total size of its files, in bytes
+}
+
+// DevArtifactReport lists the artifacts found by CleanDevArtifacts. Nothing is deleted until Delete is called.
+type DevArtifactReport struct {
+	Root      string        // Absolute path of the scanned tree
+	Artifacts []DevArtifact // Artifacts found, in walk order
+	TotalSize int64         // Space reclaimable by deleting all the artifacts, in bytes
+
+	ufs *UFS
+}
+
+// CleanDevArtifacts finds the build artifact directories of a tree and reports the space they use.
+// Nothing is deleted: call Delete on the report once the user confirmed.
+// Artifact directories are not searched for nested artifacts, and symbolic links are not followed.
+//
+// Parameters:
+//   - root: The absolute or relative path to the tree to scan, e.g. a projects folder
+//   - profiles: The kinds of artifacts to look for, none uses DefaultDevProfiles
+//
+// Returns:
+//   - *DevArtifactReport: The artifacts found and the reclaimable space
+//   - error: An error if the tree couldn't be scanned, nil otherwise
+//
+// Example:
+//
+//	report, err := ufs.CleanDevArtifacts("/home/me/projects", ufs.DevProfileNode, ufs.DevProfileRust)
+//	if err != nil {
+//	    fmt.Printf("Error scanning projects: %v\n", err)
+//	    return
+//	}
+//	for _, artifact := range report.Artifacts {
+//	    fmt.Printf("%-8s %10d  %s\n", artifact.Profile, artifact.Size, artifact.Path)
+//	}
+//	fmt.Printf("Delete %d bytes? ", report.TotalSize)
+//	if confirmed() {
+//	    freed, err := report.Delete()
+//	    fmt.Println(freed, err)
+//	}
+func (ufs *UFS) CleanDevArtifacts(root string, profiles ...DevArtifactProfile) (_ *DevArtifactReport, err error) {
+	defer ufs.recoverPanic("CleanDevArtifacts", &err)
+
+	// Verify root is a directory
+	if !ufs.IsDirectory(root) {
+		return nil, ufs.misuseError("CleanDevArtifacts", "root path is not a directory", root)
+	}
+	if len(profiles) == 0 {
+		profiles = DefaultDevProfiles
+	}
+
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return nil, ufs.wrapError(err, "CleanDevArtifacts")
+	}
+
+	report := &DevArtifactReport{Root: root, ufs: ufs}
+
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkSkip, "CleanDevArtifacts")
+		}
+		if !info.IsDir() || path == root {
+			return nil
+		}
+
+		// Version control data is never an artifact and can be large
+		if info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		profile, ok := matchDevProfile(path, info.Name(), profiles)
+		if !ok {
+			return nil
+		}
+
+		size := ufs.GetFolderSize(path)
+		report.Artifacts = append(report.Artifacts, DevArtifact{Path: path, Profile: profile, Size: size})
+		report.TotalSize += size
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, ufs.wrapError(err, "CleanDevArtifacts")
+	}
+
+	return report, nil
+}
+
+// Delete deletes the artifacts of the report. Artifacts that were replaced by a symbolic link
+// or a file since the scan, or that are no longer inside the scanned tree, are left alone.
+// Deleting continues after an error, the first error is returned.
+//
+// Returns:
+//   - int64: The space freed, as measured by the scan, in bytes (that would be freed in dry-run mode)
+//   - error: The first error met while deleting, nil otherwise
+func (report *DevArtifactReport) Delete() (freed int64, err error) {
+	defer report.ufs.recoverPanic("DevArtifactReport.Delete", &err)
+
+	var firstErr error
+	for _, artifact := range report.Artifacts {
+		rel, relErr := filepath.Rel(report.Root, artifact.Path)
+		if relErr != nil || rel == "." || !filepath.IsLocal(rel) {
+			continue
+		}
+
+		// The tree may have changed since the scan
+		info, statErr := os.Lstat(artifact.Path)
+		if statErr != nil || !info.IsDir() {
+			continue
+		}
+
+		if report.ufs.dryRun("DevArtifactReport.Delete", DryRunRemoveAll, artifact.Path, "") {
+			freed += artifact.Size
+			continue
+		}
+		if err := os.RemoveAll(artifact.Path); err != nil {
+			if firstErr == nil {
+				firstErr = report.ufs.wrapError(err, "DevArtifactReport.Delete")
+			}
+			continue
+		}
+		freed += artifact.Size
+	}
+
+	return freed, firstErr
+}
+
+// matchDevProfile returns the name of the first profile matching a directory
+func matchDevProfile(path, name string, profiles []DevArtifactProfile) (string, bool) {
+	parent := filepath.Dir(path)
+
+	for _, profile := range profiles {
+		if !slices.Contains(profile.DirNames, name) {
+			continue
+		}
+		if len(profile.Markers) == 0 {
+			return profile.Name, true
+		}
+		for _, marker := range profile.Markers {
+			if _, err := os.Stat(filepath.Join(parent, marker)); err == nil {
+				return profile.Name, true
+			}
+		}
+	}
+	return "", false
+}
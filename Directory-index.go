@@ -0,0 +1,229 @@
+package ufs
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+Directory-index.go contains functions to generate directory listings for static file shares.
+
+GenerateIndex writes an index.html (or INDEX.md) in every directory of a tree, listing its
+subdirectories and files, with the size and modification date of the files, and linking to them.
+The tree can then be published by any static web server, or browsed on a git forge.
+
+Hidden entries (names starting with a dot) and the index files themselves are not listed.
+Index files whose content didn't change are not rewritten.
+
+Functions:
+- GenerateIndex: Writes a listing file in every directory of a tree.
+*/
+
+// IndexFormat is the format of the listing files written by GenerateIndex.
+type IndexFormat int
+
+const (
+	// IndexHTML writes an index.html page per directory
+	IndexHTML IndexFormat = iota
+	// IndexMarkdown writes an INDEX.md file per directory
+	IndexMarkdown
+)
+
+// fileName returns the name of the listing file of the format
+func (format IndexFormat) fileName() string {
+	if format == IndexMarkdown {
+		return "INDEX.md"
+	}
+	return "index.html"
+}
+
+// indexEntry is a line of a directory listing
+type indexEntry struct {
+	Name    string
+	Link    string
+	IsDir   bool
+	Size    string
+	ModTime string
+}
+
+// indexPage is the content of a directory listing
+type indexPage struct {
+	Title   string
+	Parent  bool // The directory is not the root, a link to the parent is shown
+	Entries []indexEntry
+}
+
+var indexHTMLTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Index of {{.Title}}</title>
+<style>body{font-family:sans-serif}td{padding:2px 12px}td.size{text-align:right}</style>
+</head>
+<body>
+<h1>Index of {{.Title}}</h1>
+<table>
+<tr><th>Name</th><th>Size</th><th>Modified</th></tr>
+{{- if .Parent}}
+<tr><td><a href="../index.html">../</a></td><td></td><td></td></tr>
+{{- end}}
+{{- range .Entries}}
+<tr><td><a href="{{.Link}}">{{.Name}}{{if .IsDir}}/{{end}}</a></td><td class="size">{{.Size}}</td><td>{{.ModTime}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// GenerateIndex writes a listing file in every directory of a tree: index.html with IndexHTML,
+// INDEX.md with IndexMarkdown. Every listing shows the subdirectories then the files of its directory,
+// sorted by name, with the size and modification date of the files, and links to them and to the listings
+// of the subdirectories. Hidden directories are not indexed.
+//
+// Parameters:
+//   - root: The absolute or relative path to the root of the tree
+//   - format: IndexHTML or IndexMarkdown
+//
+// Returns:
+//   - int: The number of listing files written (unchanged listings are not counted)
+//   - error: An error if the tree couldn't be read or a listing couldn't be written, nil otherwise
+//
+// Example:
+//
+//	written, err := ufs.GenerateIndex("/srv/downloads", ufs.IndexHTML)
+//	if err != nil {
+//	    fmt.Printf("Error generating listings: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("%d listings updated\n", written)
+func (ufs *UFS) GenerateIndex(root string, format IndexFormat) (_ int, err error) {
+	defer ufs.recoverPanic("GenerateIndex", &err)
+
+	if format != IndexHTML && format != IndexMarkdown {
+		return 0, fmt.Errorf("GenerateIndex: unsupported index format %d", format)
+	}
+	if !ufs.IsDirectory(root) {
+		return 0, ufs.misuseError("GenerateIndex", "path is not a directory", root)
+	}
+
+	written := 0
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkSkip, "GenerateIndex")
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		content, err := ufs.directoryIndex(root, path, format)
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkSkip, "GenerateIndex")
+		}
+		changed, err := ufs.WriteFileIfChanged(filepath.Join(path, format.fileName()), content)
+		if err != nil {
+			return err
+		}
+		if changed {
+			written++
+		}
+		return nil
+	})
+	if err != nil {
+		return written, ufs.wrapError(err, "GenerateIndex")
+	}
+	return written, nil
+}
+
+// directoryIndex returns the listing of a directory
+func (ufs *UFS) directoryIndex(root, dir string, format IndexFormat) ([]byte, error) {
+	files, err := ufs.backend().ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	title := "/"
+	if rel, err := filepath.Rel(root, dir); err == nil && rel != "." {
+		title = "/" + filepath.ToSlash(rel) + "/"
+	}
+	page := indexPage{Title: title, Parent: dir != root}
+
+	for _, file := range files {
+		name := file.Name()
+		if strings.HasPrefix(name, ".") || name == format.fileName() {
+			continue
+		}
+		info, err := ufs.backend().Stat(filepath.Join(dir, name)) // Follows symbolic links
+		if err != nil {
+			continue // Broken link, or removed in the meantime
+		}
+
+		// The size and date of directories are not shown: writing their listing changes their date
+		entry := indexEntry{Name: name, Link: url.PathEscape(name), IsDir: info.IsDir(), Size: "-", ModTime: "-"}
+		if info.IsDir() {
+			entry.Link += "/" + format.fileName()
+		} else {
+			entry.Size = formatSize(info.Size())
+			entry.ModTime = info.ModTime().Format(time.DateTime)
+		}
+		page.Entries = append(page.Entries, entry)
+	}
+
+	// Directories first, then files, by name
+	sort.SliceStable(page.Entries, func(i, j int) bool {
+		if page.Entries[i].IsDir != page.Entries[j].IsDir {
+			return page.Entries[i].IsDir
+		}
+		return page.Entries[i].Name < page.Entries[j].Name
+	})
+
+	var buf bytes.Buffer
+	if format == IndexHTML {
+		if err := indexHTMLTemplate.Execute(&buf, page); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	fmt.Fprintf(&buf, "# Index of %s\n\n", markdownEscape(page.Title))
+	buf.WriteString("| Name | Size | Modified |\n|---|---:|---|\n")
+	if page.Parent {
+		buf.WriteString("| [../](../INDEX.md) | | |\n")
+	}
+	for _, entry := range page.Entries {
+		name := markdownEscape(entry.Name)
+		if entry.IsDir {
+			name += "/"
+		}
+		fmt.Fprintf(&buf, "| [%s](%s) | %s | %s |\n", name, entry.Link, entry.Size, entry.ModTime)
+	}
+	return buf.Bytes(), nil
+}
+
+// markdownEscape escapes the characters of a name that Markdown tables and links would interpret
+var markdownEscape = strings.NewReplacer(
+	`\`, `\\`, "|", `\|`, "[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`, "`", "\\`", "<", "&lt;",
+).Replace
+
+// formatSize formats a size in bytes with a binary unit, e.g. "1.5 MiB"
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,216 @@
+package ufs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/*
+Directory-lock.go contains functions to make sure a single instance of a batch job processes
+a directory at a time.
+
+LockDirectory creates a lock file (.ufs.lock) in the directory, holding the PID and host name of the
+owner. A second instance fails with a *DirectoryLockedError until the first one calls Unlock:
+
+	lock, err := ufs.LockDirectory("/srv/inbox")
+	if errors.Is(err, ufs.ErrDirectoryLocked) {
+	    return // Another instance is already at work
+	}
+	defer lock.Unlock()
+
+A lock left behind by a crashed process is stale: when its owner ran on the same machine and is no
+longer running, LockDirectory takes the lock over. The stale file is renamed before it is removed, so
+of several processes taking over the same lock only one succeeds. Locks of other machines (e.g. on a network share)
+can't be checked and are only released by their owner, or by removing the lock file by hand.
+On platforms where processes can't be checked, locks are never considered stale.
+
+Functions:
+- LockDirectory: Takes the lock of a directory, returning the handle to release it.
+*/
+
+// directoryLockName is the name of the lock file created in locked directories
+const directoryLockName = ".ufs.lock"
+
+// corruptLockGrace is how long an unreadable lock file is assumed to be being written by its owner
+const corruptLockGrace = 10 * time.Second
+
+// directoryLockOwner is the content of a lock file
+type directoryLockOwner struct {
+	PID      int       `json:"pid"`
+	Hostname string    `json:"hostname"`
+	Since    time.Time `json:"since"`
+	Token    string    `json:"token"` // Identifies the lock, so Unlock never removes a lock taken over by another process
+}
+
+// DirectoryLock is a lock held on a directory, released by Unlock.
+type DirectoryLock struct {
+	path  string // Path of the lock file
+	owner directoryLockOwner
+	once  sync.Once
+	err   error
+}
+
+// Unlock releases the lock by removing the lock file. Calling it more than once is harmless.
+// A lock file that was replaced by another process (after being removed by hand) is left alone.
+//
+// Returns:
+//   - error: An error if the lock file couldn't be removed, nil otherwise
+func (lock *DirectoryLock) Unlock() error {
+	lock.once.Do(func() {
+		current, err := readDirectoryLock(lock.path)
+		if os.IsNotExist(err) {
+			return
+		}
+		if err == nil && current.Token != lock.owner.Token {
+			return
+		}
+		if err := os.Remove(lock.path); err != nil && !os.IsNotExist(err) {
+			lock.err = err
+		}
+	})
+	return lock.err
+}
+
+// LockDirectory takes the lock of a directory, so that two instances of a job never process it
+// concurrently. The lock is a file named .ufs.lock in the directory, the jobs should not process it.
+// A stale lock, whose owner ran on this machine and is no longer running, is taken over.
+//
+// Parameters:
+//   - dir: The absolute or relative path to the directory to lock
+//
+// Returns:
+//   - *DirectoryLock: The lock, to release with Unlock
+//   - error: A *DirectoryLockedError matching ErrDirectoryLocked if another process holds the lock,
+//     or an error if the lock file couldn't be created, nil otherwise
+//
+// Example:
+//
+//	lock, err := ufs.LockDirectory("/srv/inbox")
+//	if err != nil {
+//	    fmt.Printf("Can't process the inbox: %v\n", err)
+//	    return
+//	}
+//	defer lock.Unlock()
+func (ufs *UFS) LockDirectory(dir string) (_ *DirectoryLock, err error) {
+	defer ufs.recoverPanic("LockDirectory", &err)
+
+	if err := ufs.requireOS("LockDirectory"); err != nil {
+		return nil, err
+	}
+
+	if !ufs.IsDirectory(dir) {
+		return nil, ufs.misuseError("LockDirectory", "path is not a directory", dir)
+	}
+
+	hostname, _ := os.Hostname()
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		return nil, ufs.wrapError(err, "LockDirectory")
+	}
+	lock := &DirectoryLock{
+		path: filepath.Join(dir, directoryLockName),
+		owner: directoryLockOwner{
+			PID:      os.Getpid(),
+			Hostname: hostname,
+			Since:    time.Now().UTC(),
+			Token:    hex.EncodeToString(token),
+		},
+	}
+	content, err := json.Marshal(lock.owner)
+	if err != nil {
+		return nil, ufs.wrapError(err, "LockDirectory")
+	}
+
+	// A stale lock is removed then created again once
+	for attempt := 0; ; attempt++ {
+		err := createLockFile(lock.path, append(content, '\n'))
+		if err == nil {
+			return lock, nil
+		}
+		if !os.IsExist(err) {
+			return nil, ufs.wrapError(err, "LockDirectory")
+		}
+
+		owner, readErr := readDirectoryLock(lock.path)
+		if os.IsNotExist(readErr) {
+			continue // Released in the meantime
+		}
+		if attempt == 0 && isStaleLock(lock.path, owner, readErr, hostname) {
+			if err := takeOverStaleLock(lock.path, owner, readErr, lock.owner.Token); err != nil {
+				return nil, ufs.wrapError(err, "LockDirectory")
+			}
+			continue
+		}
+		return nil, &DirectoryLockedError{Dir: dir, PID: owner.PID, Hostname: owner.Hostname, Since: owner.Since}
+	}
+}
+
+// createLockFile creates the lock file, failing with an os.ErrExist error if it exists
+func createLockFile(path string, content []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+	return file.Close()
+}
+
+// takeOverStaleLock removes the stale lock file of owner (readErr when it couldn't be read). The file is
+// renamed to a name of its own before being checked again, so two processes taking over the same lock
+// never remove a lock that isn't stale: a lock replaced since it was found stale is put back.
+func takeOverStaleLock(path string, owner directoryLockOwner, readErr error, token string) error {
+	moved := path + ".stale-" + token
+	if err := os.Rename(path, moved); err != nil {
+		if os.IsNotExist(err) {
+			return nil // Taken over or released in the meantime
+		}
+		return err
+	}
+
+	current, err := readDirectoryLock(moved)
+	stale := (readErr != nil && err != nil) || (readErr == nil && err == nil && current.Token == owner.Token)
+	if !stale {
+		// Another process took the lock over first, give its lock back unless a new one was created since
+		if err := os.Link(moved, path); err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
+	return os.Remove(moved)
+}
+
+// readDirectoryLock reads the owner of a lock file
+func readDirectoryLock(path string) (directoryLockOwner, error) {
+	var owner directoryLockOwner
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return owner, err
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(data), &owner); err != nil {
+		return owner, fmt.Errorf("invalid lock file %s: %w", path, err)
+	}
+	return owner, nil
+}
+
+// isStaleLock reports whether a lock file was left behind by a process that is no longer running.
+// Unreadable lock files are stale once they are old enough not to be being written.
+func isStaleLock(path string, owner directoryLockOwner, readErr error, hostname string) bool {
+	if readErr != nil {
+		info, err := os.Stat(path)
+		return err == nil && time.Since(info.ModTime()) > corruptLockGrace
+	}
+	if owner.Hostname != hostname || owner.PID <= 0 {
+		return false
+	}
+	return owner.PID != os.Getpid() && !processAlive(owner.PID)
+}
@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package ufs
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// processAlive reports whether a process is running, by sending it the null signal
+func processAlive(pid int) bool {
+	err := unix.Kill(pid, 0)
+	// EPERM means the process exists but belongs to another user
+	return err == nil || err == unix.EPERM
+}
@@ -0,0 +1,26 @@
+//go:build windows
+
+package ufs
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// stillActive is the exit code reported by GetExitCodeProcess for running processes
+const stillActive = 259
+
+// processAlive reports whether a process is running
+func processAlive(pid int) bool {
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// Access denied means the process exists but belongs to another user
+		return err == windows.ERROR_ACCESS_DENIED
+	}
+	defer windows.CloseHandle(handle)
+
+	var code uint32
+	if err := windows.GetExitCodeProcess(handle, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
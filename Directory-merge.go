@@ -0,0 +1,274 @@
+package ufs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+/*
+Directory-merge.go contains MoveDirectoryWithMerge, moving a directory into an existing one with
+control over the files present on both sides. MoveDirectory replaces them.
+
+A file of the source whose path already exists in the destination is a conflict, resolved by a MergePolicy:
+- MergeOverwrite: the source file replaces the existing one, like MoveDirectory
+- MergeKeepNewest: the file modified last is kept
+- MergeKeepLargest: the largest file is kept
+- MergeSkip: the existing file is kept
+- MergeRename: the source file is moved next to the existing one as "name (1).ext", "name (2).ext"...
+- MergeCallback: MergeOptions.Resolve decides for each conflict
+
+Source files losing a conflict are left in the source directory, which is then only removed
+when empty, so nothing is deleted by a merge. A file and a directory with the same name are a
+conflict only MergeRename (or MergeKeepBoth from the callback) resolves, otherwise both are kept.
+
+	report, err := ufs.MoveDirectoryWithMerge("/mnt/old-laptop/Photos", "/home/me/Photos",
+	    &ufs.MergeOptions{Policy: ufs.MergeKeepNewest})
+	for _, path := range report.Conflicts {
+	    fmt.Println("Conflict:", path)
+	}
+
+Functions:
+- MoveDirectoryWithMerge: Moves a directory, merging it into the destination with a conflict policy
+*/
+
+// MergePolicy decides which file MoveDirectoryWithMerge keeps when a file exists on both sides.
+type MergePolicy int
+
+const (
+	// MergeOverwrite replaces the existing file (default)
+	MergeOverwrite MergePolicy = iota
+	// MergeKeepNewest keeps the file with the latest modification time, the existing one on a tie
+	MergeKeepNewest
+	// MergeKeepLargest keeps the largest file, the existing one on a tie
+	MergeKeepLargest
+	// MergeSkip keeps the existing file
+	MergeSkip
+	// MergeRename keeps both, the source file gets a free "name (n).ext" name
+	MergeRename
+	// MergeCallback asks MergeOptions.Resolve
+	MergeCallback
+)
+
+// MergeDecision is the resolution of a single conflict, returned by MergeOptions.Resolve.
+type MergeDecision int
+
+const (
+	// MergeReplace moves the source file over the existing one
+	MergeReplace MergeDecision = iota
+	// MergeKeepExisting keeps the existing file, the source file stays in the source directory
+	MergeKeepExisting
+	// MergeKeepBoth moves the source file under a free "name (n).ext" name
+	MergeKeepBoth
+)
+
+// MergeConflict describes a path existing in both directories.
+type MergeConflict struct {
+	Path       string      // Path relative to the destination
+	Source     string      // Full path of the source entry
+	Dest       string      // Full path of the existing entry
+	SourceInfo os.FileInfo // Information about the source entry
+	DestInfo   os.FileInfo // Information about the existing entry
+}
+
+// MergeOptions configures MoveDirectoryWithMerge.
+type MergeOptions struct {
+	// Policy resolves the conflicts, MergeOverwrite by default
+	Policy MergePolicy
+
+	// Resolve decides each conflict with MergeCallback, it is required with that policy
+	Resolve func(conflict MergeConflict) MergeDecision
+}
+
+// MergeReport lists what MoveDirectoryWithMerge did. Paths are relative to the destination.
+type MergeReport struct {
+	Moved     []string          // Entries moved without conflict, a directory moved whole is listed once
+	Conflicts []string          // Every path existing on both sides, sorted
+	Replaced  []string          // Conflicts where the source file replaced the existing one
+	Kept      []string          // Conflicts where the existing entry was kept, the source one is left in place
+	Renamed   map[string]string // Conflicts where the source was moved under a new name, path -> new path
+}
+
+// MoveDirectoryWithMerge moves a directory like MoveDirectory, resolving the files existing in both
+// directories with a policy instead of overwriting them, see Directory-merge.go.
+// The source directory is removed when everything was moved out of it.
+//
+// Parameters:
+//   - srcPath: The absolute or relative path to the source directory
+//   - destPath: The absolute or relative path where the directory should be moved to
+//   - opts: The merge settings, nil overwrites like MoveDirectory
+//
+// Returns:
+//   - *MergeReport: The entries moved and the conflicts with how they were resolved
+//   - error: An error if an entry couldn't be moved, the report lists what was done before
+//
+// Example:
+//
+//	report, err := ufs.MoveDirectoryWithMerge("incoming/assets", "site/assets", &ufs.MergeOptions{Policy: ufs.MergeRename})
+//	if err != nil {
+//	    fmt.Printf("Error merging assets: %v\n", err)
+//	    return
+//	}
+//	for path, renamed := range report.Renamed {
+//	    fmt.Printf("%s already existed, moved to %s\n", path, renamed)
+//	}
+func (ufs *UFS) MoveDirectoryWithMerge(srcPath, destPath string, opts *MergeOptions) (_ *MergeReport, err error) {
+	defer ufs.recoverPanic("MoveDirectoryWithMerge", &err)
+
+	if err := ufs.requireOS("MoveDirectoryWithMerge"); err != nil {
+		return nil, err
+	}
+
+	if opts == nil {
+		opts = &MergeOptions{}
+	}
+	if opts.Policy < MergeOverwrite || opts.Policy > MergeCallback {
+		return nil, fmt.Errorf("MoveDirectoryWithMerge: invalid MergePolicy %d", opts.Policy)
+	}
+	if opts.Policy == MergeCallback && opts.Resolve == nil {
+		return nil, fmt.Errorf("MoveDirectoryWithMerge: MergeCallback requires MergeOptions.Resolve")
+	}
+
+	// Verify source is a directory
+	if !ufs.IsDirectory(srcPath) {
+		return nil, ufs.misuseError("MoveDirectoryWithMerge", "source path is not a directory", srcPath)
+	}
+	if ufs.IsFile(destPath) {
+		return nil, fmt.Errorf("destination exists and is a file: %s", destPath)
+	}
+
+	report := &MergeReport{Renamed: map[string]string{}}
+	if ufs.dryRun("MoveDirectoryWithMerge", DryRunMove, destPath, srcPath) {
+		return report, nil
+	}
+
+	// Without destination there is nothing to merge
+	if !ufs.PathExists(destPath) {
+		if !ufs.MoveDirectory(srcPath, destPath) {
+			return report, fmt.Errorf("MoveDirectoryWithMerge: could not move %s to %s", srcPath, destPath)
+		}
+		report.Moved = append(report.Moved, ".")
+		return report, nil
+	}
+
+	err = ufs.mergeDirectoryInto(srcPath, destPath, "", opts, report)
+	sort.Strings(report.Conflicts)
+	if err != nil {
+		return report, ufs.wrapError(err, "MoveDirectoryWithMerge")
+	}
+	return report, nil
+}
+
+// mergeDirectoryInto moves the entries of srcDir into the existing destDir, rel is the path of
+// destDir relative to the destination. srcDir is removed when it ends up empty.
+func (ufs *UFS) mergeDirectoryInto(srcDir, destDir, rel string, opts *MergeOptions, report *MergeReport) error {
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return err
+	}
+
+	emptied := true
+	for _, entry := range entries {
+		src := filepath.Join(srcDir, entry.Name())
+		dest := filepath.Join(destDir, entry.Name())
+		path := filepath.Join(rel, entry.Name())
+
+		srcInfo, err := os.Lstat(src)
+		if err != nil {
+			return err
+		}
+		destInfo, err := os.Lstat(dest)
+		if os.IsNotExist(err) {
+			if err := ufs.moveMergedEntry(src, dest, srcInfo); err != nil {
+				return err
+			}
+			report.Moved = append(report.Moved, path)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		// Directories on both sides are merged in turn
+		if srcInfo.IsDir() && destInfo.IsDir() {
+			if err := ufs.mergeDirectoryInto(src, dest, path, opts, report); err != nil {
+				return err
+			}
+			if ufs.PathExists(src) {
+				emptied = false
+			}
+			continue
+		}
+
+		report.Conflicts = append(report.Conflicts, path)
+		conflict := MergeConflict{Path: path, Source: src, Dest: dest, SourceInfo: srcInfo, DestInfo: destInfo}
+		decision := opts.decide(conflict)
+		if decision == MergeReplace && srcInfo.IsDir() != destInfo.IsDir() {
+			// A directory never replaces a file, nor a file a directory
+			decision = MergeKeepExisting
+		}
+
+		switch decision {
+		case MergeReplace:
+			if !ufs.moveFile(src, dest) {
+				return fmt.Errorf("could not move %s to %s", src, dest)
+			}
+			report.Replaced = append(report.Replaced, path)
+		case MergeKeepBoth:
+			renamed := uniquePathWith(dest, func(candidate string) bool {
+				_, err := os.Lstat(candidate)
+				return !os.IsNotExist(err)
+			})
+			if err := ufs.moveMergedEntry(src, renamed, srcInfo); err != nil {
+				return err
+			}
+			report.Renamed[path] = filepath.Join(rel, filepath.Base(renamed))
+		default:
+			report.Kept = append(report.Kept, path)
+			emptied = false
+		}
+	}
+
+	if emptied {
+		return os.Remove(srcDir)
+	}
+	return nil
+}
+
+// moveMergedEntry moves a file or a whole directory to a free destination
+func (ufs *UFS) moveMergedEntry(src, dest string, info os.FileInfo) error {
+	var moved bool
+	if info.IsDir() {
+		moved = ufs.MoveDirectory(src, dest)
+	} else {
+		moved = ufs.moveFile(src, dest)
+	}
+	if !moved {
+		return fmt.Errorf("could not move %s to %s", src, dest)
+	}
+	return nil
+}
+
+// decide resolves a conflict with the policy
+func (opts *MergeOptions) decide(conflict MergeConflict) MergeDecision {
+	switch opts.Policy {
+	case MergeKeepNewest:
+		if conflict.SourceInfo.ModTime().After(conflict.DestInfo.ModTime()) {
+			return MergeReplace
+		}
+		return MergeKeepExisting
+	case MergeKeepLargest:
+		if conflict.SourceInfo.Size() > conflict.DestInfo.Size() {
+			return MergeReplace
+		}
+		return MergeKeepExisting
+	case MergeSkip:
+		return MergeKeepExisting
+	case MergeRename:
+		return MergeKeepBoth
+	case MergeCallback:
+		return opts.Resolve(conflict)
+	}
+	return MergeReplace
+}
@@ -0,0 +1,571 @@
+package ufs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+Directory-quota.go contains functions to limit the total size of directories, e.g. the upload folders
+of the users of an application.
+
+A quota is a soft limit enforced by the library, not by the file system: writes through ufs (WriteFile,
+WriteFileAtomic, AppendToFile, WriteFileFromReader, CopyFile, CopyFileWithPermissions, MoveFile,
+MoveFileWithPermissions and the functions built on them, like WriteStringToFile or WriteJSONFile) that would
+take the directory over its quota fail with a *QuotaExceededError, or first call the eviction callback of
+the quota to make room. Files removed with RemoveFile and DeleteFile are deducted from the manifest.
+Files written by other means are not checked, and are only counted once the directory is scanned again.
+
+The size of every file of the directory is tracked in a manifest, .ufs-quota.json at the root of the
+directory, so writes don't have to walk the directory. The directory is scanned again whenever the
+manifest says a write doesn't fit, as files may have been removed without ufs since.
+Quotas apply to the UFS instance they were set on; the manifest lets a restarted program set them
+again without scanning large directories.
+
+Concurrent writes are checked one by one, so the quota can be exceeded by writes racing each other.
+
+Functions:
+- SetDirectoryQuota: Limits the total size of the files of a directory.
+- SetDirectoryQuotaWithOptions: Limits the total size of a directory, with an eviction callback.
+- GetDirectoryQuota: Returns the limit and the space used in a directory with a quota.
+- RefreshDirectoryQuota: Scans a directory with a quota again, to count files written without ufs.
+- RemoveDirectoryQuota: Stops enforcing the quota of a directory.
+- EvictOldestFiles: Deletes the least recently modified files of a directory, to use as eviction callback.
+*/
+
+// directoryQuotaName is the name of the manifest of sizes written at the root of directories with a quota
+const directoryQuotaName = ".ufs-quota.json"
+
+// QuotaOptions configures the quota of a directory.
+type QuotaOptions struct {
+	// OnExceeded is called when a write through ufs would take the directory over its quota, with the
+	// number of bytes to free. It can delete files of the directory (with ufs or not) to make room, e.g.
+	// EvictOldestFiles. The write goes on if the directory is under quota afterwards, and fails with a
+	// *QuotaExceededError otherwise, or with the error returned by OnExceeded.
+	// When nil, writes over the quota fail right away.
+	OnExceeded func(dir string, needBytes int64) error
+}
+
+// QuotaUsage is the limit and the space used in a directory with a quota.
+type QuotaUsage struct {
+	Dir       string // Absolute path to the directory
+	MaxBytes  int64  // Quota of the directory
+	UsedBytes int64  // Total size of the files of the directory, as tracked in the manifest
+	Files     int    // Number of files of the directory
+}
+
+// quotaManifest is the content of the manifest of sizes of a directory with a quota
+type quotaManifest struct {
+	MaxBytes int64            `json:"max_bytes"`
+	Files    map[string]int64 `json:"files"` // Slash separated path relative to the directory -> size
+}
+
+// directoryQuota is the quota of a directory and the sizes of its files
+type directoryQuota struct {
+	dir        string
+	maxBytes   int64
+	onExceeded func(dir string, needBytes int64) error
+
+	mu      sync.Mutex
+	files   map[string]int64
+	used    int64
+	writing map[string]int // Files whose write is waiting for the eviction callback, never evicted
+}
+
+// SetDirectoryQuota limits the total size of the files of a directory, creating the directory if needed.
+// Writes through ufs that would take the directory over maxBytes then fail with a *QuotaExceededError.
+// The manifest of a previous quota of the directory is reused, else the directory is scanned.
+// Setting the quota of a directory again replaces its limit.
+//
+// Parameters:
+//   - dir: The absolute or relative path to the directory
+//   - maxBytes: The maximum total size of the files of the directory, in bytes
+//
+// Returns:
+//   - error: An error if the directory couldn't be created or scanned, or the manifest written, nil otherwise
+//
+// Example:
+//
+//	err := ufs.SetDirectoryQuota("/srv/uploads/alice", 100<<20)
+//	if err != nil {
+//	    fmt.Printf("Error setting quota: %v\n", err)
+//	    return
+//	}
+//	err = ufs.WriteFile("/srv/uploads/alice/photo.jpg", photo)
+//	if errors.Is(err, ufs.ErrQuotaExceeded) {
+//	    fmt.Println("Upload folder is full")
+//	}
+func (ufs *UFS) SetDirectoryQuota(dir string, maxBytes int64) (err error) {
+	defer ufs.recoverPanic("SetDirectoryQuota", &err)
+
+	if err := ufs.requireOS("SetDirectoryQuota"); err != nil {
+		return err
+	}
+
+	return ufs.setDirectoryQuota(dir, maxBytes, nil, "SetDirectoryQuota")
+}
+
+// SetDirectoryQuotaWithOptions limits the total size of the files of a directory, like SetDirectoryQuota,
+// calling opts.OnExceeded to make room when a write doesn't fit.
+//
+// Parameters:
+//   - dir: The absolute or relative path to the directory
+//   - maxBytes: The maximum total size of the files of the directory, in bytes
+//   - opts: The eviction callback, nil behaves like SetDirectoryQuota
+//
+// Returns:
+//   - error: An error if the directory couldn't be created or scanned, or the manifest written, nil otherwise
+//
+// Example:
+//
+//	// Keep the last 2 GiB of recordings, deleting the oldest ones
+//	err := ufs.SetDirectoryQuotaWithOptions("/var/lib/cam/recordings", 2<<30, &ufs.QuotaOptions{
+//	    OnExceeded: ufs.EvictOldestFiles,
+//	})
+func (ufs *UFS) SetDirectoryQuotaWithOptions(dir string, maxBytes int64, opts *QuotaOptions) (err error) {
+	defer ufs.recoverPanic("SetDirectoryQuotaWithOptions", &err)
+
+	if err := ufs.requireOS("SetDirectoryQuotaWithOptions"); err != nil {
+		return err
+	}
+
+	return ufs.setDirectoryQuota(dir, maxBytes, opts, "SetDirectoryQuotaWithOptions")
+}
+
+func (ufs *UFS) setDirectoryQuota(dir string, maxBytes int64, opts *QuotaOptions, operation string) error {
+	if maxBytes <= 0 {
+		return fmt.Errorf("%s: invalid quota %d, expected more than 0", operation, maxBytes)
+	}
+	if opts == nil {
+		opts = &QuotaOptions{}
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ufs.wrapError(err, operation)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return ufs.wrapError(err, operation)
+	}
+
+	quota := &directoryQuota{dir: dir, maxBytes: maxBytes, onExceeded: opts.OnExceeded, writing: map[string]int{}}
+
+	quota.mu.Lock()
+	defer quota.mu.Unlock()
+
+	// Reuse the sizes of a previous run, a stale manifest is corrected by the scans of checkQuota
+	var manifest quotaManifest
+	if data, err := os.ReadFile(filepath.Join(dir, directoryQuotaName)); err == nil && json.Unmarshal(data, &manifest) == nil && manifest.Files != nil {
+		quota.files = manifest.Files
+		for _, size := range manifest.Files {
+			quota.used += size
+		}
+	} else if _, err := ufs.scanQuota(quota); err != nil {
+		return ufs.wrapError(err, operation)
+	}
+	if err := quota.save(); err != nil {
+		return ufs.wrapError(err, operation)
+	}
+
+	ufs.quotaMu.Lock()
+	defer ufs.quotaMu.Unlock()
+	if ufs.quotas == nil {
+		ufs.quotas = map[string]*directoryQuota{}
+	}
+	ufs.quotas[dir] = quota
+	return nil
+}
+
+// GetDirectoryQuota returns the limit and the space used in a directory with a quota.
+//
+// Parameters:
+//   - dir: The absolute or relative path to the directory
+//
+// Returns:
+//   - QuotaUsage: The limit and the space used
+//   - error: An error if the directory has no quota, nil otherwise
+//
+// Example:
+//
+//	usage, err := ufs.GetDirectoryQuota("/srv/uploads/alice")
+//	if err == nil {
+//	    fmt.Printf("%d of %d bytes used\n", usage.UsedBytes, usage.MaxBytes)
+//	}
+func (ufs *UFS) GetDirectoryQuota(dir string) (_ QuotaUsage, err error) {
+	defer ufs.recoverPanic("GetDirectoryQuota", &err)
+
+	quota, err := ufs.directoryQuota(dir)
+	if err != nil {
+		return QuotaUsage{}, ufs.wrapError(err, "GetDirectoryQuota")
+	}
+
+	quota.mu.Lock()
+	defer quota.mu.Unlock()
+	return quota.usage(), nil
+}
+
+// RefreshDirectoryQuota scans a directory with a quota again, to count the files written or removed
+// without ufs. The directory is over its quota afterwards if such files take more space than allowed;
+// nothing is evicted until the next write through ufs.
+//
+// Parameters:
+//   - dir: The absolute or relative path to the directory
+//
+// Returns:
+//   - QuotaUsage: The limit and the space used after the scan
+//   - error: An error if the directory has no quota or couldn't be scanned, nil otherwise
+//
+// Example:
+//
+//	// Files are also uploaded by an FTP server
+//	usage, err := ufs.RefreshDirectoryQuota("/srv/uploads/alice")
+//	if err == nil && usage.UsedBytes > usage.MaxBytes {
+//	    fmt.Println("Upload folder is over quota")
+//	}
+func (ufs *UFS) RefreshDirectoryQuota(dir string) (_ QuotaUsage, err error) {
+	defer ufs.recoverPanic("RefreshDirectoryQuota", &err)
+
+	quota, err := ufs.directoryQuota(dir)
+	if err != nil {
+		return QuotaUsage{}, ufs.wrapError(err, "RefreshDirectoryQuota")
+	}
+
+	quota.mu.Lock()
+	defer quota.mu.Unlock()
+	changed, err := ufs.scanQuota(quota)
+	if err != nil {
+		return QuotaUsage{}, ufs.wrapError(err, "RefreshDirectoryQuota")
+	}
+	if changed {
+		if err := quota.save(); err != nil {
+			return QuotaUsage{}, ufs.wrapError(err, "RefreshDirectoryQuota")
+		}
+	}
+	return quota.usage(), nil
+}
+
+// RemoveDirectoryQuota stops enforcing the quota of a directory and deletes its manifest.
+// The files of the directory are left as is.
+//
+// Parameters:
+//   - dir: The absolute or relative path to the directory
+//
+// Returns:
+//   - error: An error if the directory has no quota or the manifest couldn't be deleted, nil otherwise
+func (ufs *UFS) RemoveDirectoryQuota(dir string) (err error) {
+	defer ufs.recoverPanic("RemoveDirectoryQuota", &err)
+
+	if err := ufs.requireOS("RemoveDirectoryQuota"); err != nil {
+		return err
+	}
+
+	quota, err := ufs.directoryQuota(dir)
+	if err != nil {
+		return ufs.wrapError(err, "RemoveDirectoryQuota")
+	}
+
+	ufs.quotaMu.Lock()
+	delete(ufs.quotas, quota.dir)
+	ufs.quotaMu.Unlock()
+
+	quota.mu.Lock()
+	defer quota.mu.Unlock()
+	if err := os.Remove(filepath.Join(quota.dir, directoryQuotaName)); err != nil && !os.IsNotExist(err) {
+		return ufs.wrapError(err, "RemoveDirectoryQuota")
+	}
+	return nil
+}
+
+// EvictOldestFiles deletes the least recently modified files of a directory (and its subdirectories)
+// until needBytes are freed, or the directory is empty. Its signature matches QuotaOptions.OnExceeded.
+// The files whose write called the eviction callback are never deleted.
+//
+// Parameters:
+//   - dir: The absolute or relative path to the directory
+//   - needBytes: The number of bytes to free
+//
+// Returns:
+//   - error: An error if the directory couldn't be read or a file couldn't be deleted, nil otherwise
+//
+// Example:
+//
+//	err := ufs.EvictOldestFiles("/var/cache/myapp", 50<<20)
+func (ufs *UFS) EvictOldestFiles(dir string, needBytes int64) (err error) {
+	defer ufs.recoverPanic("EvictOldestFiles", &err)
+
+	if err := ufs.requireOS("EvictOldestFiles"); err != nil {
+		return err
+	}
+
+	if !ufs.IsDirectory(dir) {
+		return ufs.misuseError("EvictOldestFiles", "path is not a directory", dir)
+	}
+
+	var files []fileAge
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkSkip, "EvictOldestFiles")
+		}
+		if !entry.Type().IsRegular() || entry.Name() == directoryQuotaName || ufs.quotaWriting(path) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkSkip, "EvictOldestFiles")
+		}
+		files = append(files, fileAge{path: path, size: info.Size(), modTime: info.ModTime().UnixNano()})
+		return nil
+	})
+	if err != nil {
+		return ufs.wrapError(err, "EvictOldestFiles")
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime < files[j].modTime })
+
+	// The manifests are written once, after the files are removed
+	var freed int64
+	var removed []string
+	defer func() { ufs.recordQuota(removed...) }()
+	for _, file := range files {
+		if freed >= needBytes {
+			break
+		}
+		if err := os.Remove(file.path); err != nil && !os.IsNotExist(err) {
+			return ufs.wrapError(err, "EvictOldestFiles")
+		}
+		removed = append(removed, file.path)
+		freed += file.size
+	}
+	return nil
+}
+
+// quotaWriting reports whether path is being written to a directory with a quota, waiting for its eviction callback
+func (ufs *UFS) quotaWriting(path string) bool {
+	quotas, rels := ufs.quotasOf(path)
+	for i, quota := range quotas {
+		quota.mu.Lock()
+		writing := quota.writing[rels[i]] > 0
+		quota.mu.Unlock()
+		if writing {
+			return true
+		}
+	}
+	return false
+}
+
+// fileAge is a file candidate for eviction
+type fileAge struct {
+	path    string
+	size    int64
+	modTime int64
+}
+
+// directoryQuota returns the quota set on dir
+func (ufs *UFS) directoryQuota(dir string) (*directoryQuota, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ufs.quotaMu.Lock()
+	defer ufs.quotaMu.Unlock()
+	quota, ok := ufs.quotas[dir]
+	if !ok {
+		return nil, fmt.Errorf("directory has no quota: %s", dir)
+	}
+	return quota, nil
+}
+
+// quotasOf returns the quotas of the directories containing path, with the path relative to each of them.
+// Paths that can't be made absolute are not checked.
+func (ufs *UFS) quotasOf(path string) (quotas []*directoryQuota, rels []string) {
+	ufs.quotaMu.Lock()
+	defer ufs.quotaMu.Unlock()
+	if len(ufs.quotas) == 0 || !ufs.onOS() {
+		return nil, nil
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil
+	}
+	for dir, quota := range ufs.quotas {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == directoryQuotaName {
+			continue
+		}
+		quotas = append(quotas, quota)
+		rels = append(rels, rel)
+	}
+	return quotas, rels
+}
+
+// checkQuota checks that path can be written with size bytes without taking a directory over its quota.
+// When it doesn't fit, the directory is scanned again, then the eviction callback of the quota is called.
+// It returns a *QuotaExceededError if there is still no room.
+func (ufs *UFS) checkQuota(path string, size int64) error {
+	quotas, rels := ufs.quotasOf(path)
+	for i, quota := range quotas {
+		if err := ufs.reserveQuota(quota, rels[i], size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reserveQuota is checkQuota for one of the quotas containing the file
+func (ufs *UFS) reserveQuota(quota *directoryQuota, rel string, size int64) error {
+	// overBy returns the number of bytes the write would take the directory over its quota
+	overBy := func(rescan bool) (int64, error) {
+		quota.mu.Lock()
+		defer quota.mu.Unlock()
+		if rescan {
+			changed, err := ufs.scanQuota(quota)
+			if err != nil {
+				return 0, err
+			}
+			if changed {
+				if err := quota.save(); err != nil {
+					return 0, err
+				}
+			}
+		}
+		return quota.used - quota.files[rel] + size - quota.maxBytes, nil
+	}
+	exceeded := func() error {
+		quota.mu.Lock()
+		defer quota.mu.Unlock()
+		return &QuotaExceededError{Dir: quota.dir, Path: rel, MaxBytes: quota.maxBytes, UsedBytes: quota.used - quota.files[rel], WriteBytes: size}
+	}
+
+	if need, _ := overBy(false); need <= 0 {
+		return nil
+	}
+	if size > quota.maxBytes {
+		return exceeded()
+	}
+
+	// Files may have been removed without ufs
+	need, err := overBy(true)
+	if err != nil || need <= 0 {
+		return err
+	}
+	if quota.onExceeded == nil {
+		return exceeded()
+	}
+
+	// Called unlocked, the callback may delete files with ufs, except the one being written
+	quota.mu.Lock()
+	quota.writing[rel]++
+	quota.mu.Unlock()
+	err = quota.onExceeded(quota.dir, need)
+	quota.mu.Lock()
+	if quota.writing[rel]--; quota.writing[rel] == 0 {
+		delete(quota.writing, rel)
+	}
+	quota.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if need, err = overBy(true); err != nil || need <= 0 {
+		return err
+	}
+	return exceeded()
+}
+
+// recordQuota updates the size of the paths in the quotas containing them, after they were written or
+// removed. The manifest of a quota is written once, when a size changed.
+func (ufs *UFS) recordQuota(paths ...string) {
+	changed := map[*directoryQuota]bool{}
+	for _, path := range paths {
+		quotas, rels := ufs.quotasOf(path)
+		if len(quotas) == 0 {
+			continue
+		}
+
+		var size int64 = -1
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			size = info.Size()
+		}
+		for i, quota := range quotas {
+			quota.mu.Lock()
+			old, tracked := quota.files[rels[i]]
+			switch {
+			case size < 0 && tracked:
+				delete(quota.files, rels[i])
+				quota.used -= old
+				changed[quota] = true
+			case size >= 0 && (!tracked || old != size):
+				quota.files[rels[i]] = size
+				quota.used += size - old
+				changed[quota] = true
+			}
+			quota.mu.Unlock()
+		}
+	}
+
+	for quota := range changed {
+		quota.mu.Lock()
+		if err := quota.save(); err != nil {
+			ufs.handleError(err, "recordQuota")
+		}
+		quota.mu.Unlock()
+	}
+}
+
+// scanQuota counts the files of the directory of a quota again, reporting whether the sizes changed.
+// The caller holds quota.mu.
+func (ufs *UFS) scanQuota(quota *directoryQuota) (bool, error) {
+	files := map[string]int64{}
+	var used int64
+	err := filepath.WalkDir(quota.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkSkip, "scanQuota")
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(quota.dir, path)
+		if err != nil || rel == directoryQuotaName {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkSkip, "scanQuota")
+		}
+		files[filepath.ToSlash(rel)] = info.Size()
+		used += info.Size()
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	changed := !maps.Equal(files, quota.files)
+	quota.files, quota.used = files, used
+	return changed, nil
+}
+
+// save writes the manifest of sizes of the quota. The caller holds quota.mu.
+func (quota *directoryQuota) save() error {
+	data, err := json.Marshal(quotaManifest{MaxBytes: quota.maxBytes, Files: quota.files})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(quota.dir, directoryQuotaName), data, 0644)
+}
+
+// usage returns the limit and the space used. The caller holds quota.mu.
+func (quota *directoryQuota) usage() QuotaUsage {
+	return QuotaUsage{Dir: quota.dir, MaxBytes: quota.maxBytes, UsedBytes: quota.used, Files: len(quota.files)}
+}
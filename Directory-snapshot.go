@@ -0,0 +1,435 @@
+package ufs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Directory-snapshot.go contains functions to record the state of a directory tree and compare two
+records of it. A snapshot holds every file, directory and symbolic link of the tree with its size,
+mode, modification time and the checksum of its content, as a tree serializable to JSON:
+
+	before, _ := ufs.SnapshotDirectory("/srv/www", nil)
+	_ = ufs.SaveSnapshot(before, "www.snapshot.json")
+
+	// Later, maybe in another process
+	before, _ = ufs.LoadSnapshot("www.snapshot.json")
+	after, _ := ufs.SnapshotDirectory("/srv/www", nil)
+	for _, change := range ufs.CompareSnapshots(before, after).Changes {
+	    fmt.Println(change.Kind, change.Path)
+	}
+
+Unlike CompareDirectories, which reads both trees, a snapshot is taken once and can be kept for
+auditing (what changed on a server since it was deployed), caching (rebuilding only what changed)
+or incremental backups.
+
+Files are hashed with SHA-256 unless SnapshotOptions says otherwise, several at a time. Without
+hashes, files are compared by size and modification time. Symbolic links are recorded with their
+target, they are not followed. Hidden entries are skipped with Options.ExcludeHidden, and entries
+that can't be read are handled like in the other recursive functions (see Options.OnWalkError).
+
+Functions:
+- SnapshotDirectory: Records the entries of a directory tree with their sizes, times and checksums
+- SaveSnapshot: Writes a snapshot to a JSON file
+- LoadSnapshot: Reads a snapshot written by SaveSnapshot
+- CompareSnapshots: Returns the changes between two snapshots of a tree
+*/
+
+// snapshotVersion is the version of the snapshot format, increased on incompatible changes
+const snapshotVersion = 1
+
+// SnapshotOptions are the settings of SnapshotDirectory.
+type SnapshotOptions struct {
+	// Algorithm is the checksum of the files, HashSHA256 by default
+	Algorithm HashAlgorithm
+
+	// NoHash records sizes and modification times only, without reading the files
+	NoHash bool
+
+	// Skip lists patterns of entries not recorded, directories matching them are skipped with their
+	// contents, see WalkOptions.Skip
+	Skip []string
+}
+
+// DirectorySnapshot is the state of a directory tree at a point in time, see Directory-snapshot.go.
+type DirectorySnapshot struct {
+	Version   int            `json:"version"`
+	Root      string         `json:"root"`                // Absolute path of the tree when the snapshot was taken
+	Created   time.Time      `json:"created"`             // Time the snapshot was taken
+	Algorithm HashAlgorithm  `json:"algorithm,omitempty"` // Checksum of the files, empty without hashes
+	Tree      *SnapshotEntry `json:"tree"`                // The root directory, its entries as children
+}
+
+// SnapshotEntry is a file, directory or symbolic link of a DirectorySnapshot.
+type SnapshotEntry struct {
+	Name     string           `json:"name"`
+	IsDir    bool             `json:"isDir,omitempty"`
+	Mode     os.FileMode      `json:"mode"`
+	Size     int64            `json:"size,omitempty"`
+	ModTime  time.Time        `json:"modTime"`
+	Hash     string           `json:"hash,omitempty"`     // Hex checksum of the content of files
+	Target   string           `json:"target,omitempty"`   // Target of symbolic links
+	Children []*SnapshotEntry `json:"children,omitempty"` // Entries of directories, sorted by name
+}
+
+// SnapshotChangeKind is the kind of a change found by CompareSnapshots.
+type SnapshotChangeKind string
+
+const (
+	// SnapshotAdded is an entry only in the new snapshot
+	SnapshotAdded SnapshotChangeKind = "added"
+	// SnapshotRemoved is an entry only in the old snapshot
+	SnapshotRemoved SnapshotChangeKind = "removed"
+	// SnapshotModified is a file whose content changed, or a symbolic link whose target changed
+	SnapshotModified SnapshotChangeKind = "modified"
+	// SnapshotModeChanged is an entry whose permissions changed, its content being the same
+	SnapshotModeChanged SnapshotChangeKind = "mode"
+	// SnapshotTypeChanged is an entry replaced by one of another type, e.g. a file by a directory
+	SnapshotTypeChanged SnapshotChangeKind = "type"
+)
+
+// SnapshotChange is a difference between two snapshots.
+type SnapshotChange struct {
+	Kind SnapshotChangeKind `json:"kind"`
+	Path string             `json:"path"`          // Slash separated path relative to the root
+	Old  *SnapshotEntry     `json:"old,omitempty"` // Entry in the old snapshot, nil when added
+	New  *SnapshotEntry     `json:"new,omitempty"` // Entry in the new snapshot, nil when removed
+}
+
+// SnapshotDiff is the result of CompareSnapshots.
+type SnapshotDiff struct {
+	Changes []SnapshotChange `json:"changes"` // Changes sorted by path
+}
+
+// HasChanges reports whether the snapshots differ
+func (d *SnapshotDiff) HasChanges() bool {
+	return len(d.Changes) > 0
+}
+
+// Paths returns the paths of the changes of the given kind
+func (d *SnapshotDiff) Paths(kind SnapshotChangeKind) []string {
+	var paths []string
+	for _, change := range d.Changes {
+		if change.Kind == kind {
+			paths = append(paths, change.Path)
+		}
+	}
+	return paths
+}
+
+// Entries returns the entries of the snapshot by their slash separated path relative to the root,
+// the root itself excluded
+func (s *DirectorySnapshot) Entries() map[string]*SnapshotEntry {
+	entries := map[string]*SnapshotEntry{}
+	if s == nil || s.Tree == nil {
+		return entries
+	}
+	var add func(prefix string, entry *SnapshotEntry)
+	add = func(prefix string, entry *SnapshotEntry) {
+		for _, child := range entry.Children {
+			rel := path.Join(prefix, child.Name)
+			entries[rel] = child
+			add(rel, child)
+		}
+	}
+	add("", s.Tree)
+	return entries
+}
+
+// JSON returns the snapshot as indented JSON
+func (s *DirectorySnapshot) JSON() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// SnapshotDirectory records every entry of a directory tree with its size, mode, modification time and,
+// for files, the checksum of its content, see Directory-snapshot.go.
+//
+// Parameters:
+//   - root: The absolute or relative path to the directory to record
+//   - opts: The settings of the snapshot, nil hashes the files with SHA-256
+//
+// Returns:
+//   - *DirectorySnapshot: The state of the tree
+//   - error: An error if root is not a directory, or an entry can't be read and Options.OnWalkError aborts
+//
+// Example:
+//
+//	snapshot, err := ufs.SnapshotDirectory("/etc", &ufs.SnapshotOptions{Algorithm: ufs.HashXXH64})
+//	if err != nil {
+//	    fmt.Printf("Error taking snapshot: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("%d entries recorded\n", len(snapshot.Entries()))
+func (ufs *UFS) SnapshotDirectory(root string, opts *SnapshotOptions) (_ *DirectorySnapshot, err error) {
+	defer ufs.recoverPanic("SnapshotDirectory", &err)
+	defer ufs.applyIOPriority()()
+
+	if opts == nil {
+		opts = &SnapshotOptions{}
+	}
+	algo := opts.Algorithm
+	if opts.NoHash {
+		algo = ""
+	} else if algo == "" {
+		algo = HashSHA256
+	} else if _, err := algo.newHash(); err != nil {
+		return nil, ufs.wrapError(err, "SnapshotDirectory")
+	}
+
+	if !ufs.IsDirectory(root) {
+		return nil, fmt.Errorf("SnapshotDirectory: root is not a directory: %s", root)
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, ufs.wrapError(err, "SnapshotDirectory")
+	}
+
+	snapshot := &DirectorySnapshot{
+		Version:   snapshotVersion,
+		Root:      absRoot,
+		Created:   time.Now().UTC(),
+		Algorithm: algo,
+	}
+	directories := map[string]*SnapshotEntry{}
+	var files []string
+	var fileEntries []*SnapshotEntry
+
+	err = ufs.walk("SnapshotDirectory", absRoot, WalkOptions{Skip: opts.Skip}, func(path string, d fs.DirEntry) error {
+		info, err := d.Info()
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkSkip, "SnapshotDirectory")
+		}
+		entry := &SnapshotEntry{
+			Name:    info.Name(),
+			IsDir:   info.IsDir(),
+			Mode:    info.Mode(),
+			ModTime: info.ModTime().UTC(),
+		}
+		if path == absRoot {
+			snapshot.Tree = entry
+			directories[path] = entry
+			return nil
+		}
+
+		switch {
+		case info.IsDir():
+			directories[path] = entry
+		case info.Mode()&os.ModeSymlink != 0:
+			if entry.Target, err = os.Readlink(path); err != nil {
+				return ufs.decideWalkError(path, err, WalkSkip, "SnapshotDirectory")
+			}
+		default:
+			entry.Size = info.Size()
+			if algo != "" && info.Mode().IsRegular() {
+				files = append(files, path)
+				fileEntries = append(fileEntries, entry)
+			}
+		}
+
+		// Entries are visited after their directory, in lexical order
+		if parent := directories[filepath.Dir(path)]; parent != nil {
+			parent.Children = append(parent.Children, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ufs.hashSnapshotFiles(files, fileEntries, algo); err != nil {
+		return nil, ufs.wrapError(err, "SnapshotDirectory")
+	}
+	return snapshot, nil
+}
+
+// hashSnapshotFiles sets the checksums of the entries of files, hashing several files at a time.
+// Files that can't be read keep an empty hash when OnWalkError skips them.
+func (ufs *UFS) hashSnapshotFiles(files []string, entries []*SnapshotEntry, algo HashAlgorithm) error {
+	workers := min(runtime.NumCPU(), len(files))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	done := make(chan struct{})
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				var sum string
+				err := ufs.recoverWorker("SnapshotDirectory", func() (err error) {
+					sum, err = ufs.fileChecksum(files[index], algo)
+					return err
+				})
+				if err != nil {
+					if err := ufs.decideWalkError(files[index], err, WalkSkip, "SnapshotDirectory"); err != nil {
+						errOnce.Do(func() {
+							firstErr = err
+							close(done)
+						})
+					}
+					continue
+				}
+				entries[index].Hash = sum
+			}
+		}()
+	}
+
+feed:
+	for index := range files {
+		select {
+		case jobs <- index:
+		case <-done:
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
+
+// SaveSnapshot writes a snapshot to a JSON file, replacing it atomically.
+//
+// Parameters:
+//   - snapshot: The snapshot returned by SnapshotDirectory
+//   - path: The absolute or relative path to the file to write
+//
+// Returns:
+//   - error: An error if the file can't be written, nil otherwise
+//
+// Example:
+//
+//	err := ufs.SaveSnapshot(snapshot, "/var/lib/audit/etc.snapshot.json")
+func (ufs *UFS) SaveSnapshot(snapshot *DirectorySnapshot, path string) (err error) {
+	defer ufs.recoverPanic("SaveSnapshot", &err)
+
+	if snapshot == nil {
+		return fmt.Errorf("SaveSnapshot: snapshot is nil")
+	}
+	if ufs.dryRun("SaveSnapshot", DryRunWrite, path, "") {
+		return nil
+	}
+	data, err := snapshot.JSON()
+	if err != nil {
+		return ufs.wrapError(err, "SaveSnapshot")
+	}
+	return ufs.wrapError(ufs.replaceFile(path, data, 0644), "SaveSnapshot")
+}
+
+// LoadSnapshot reads a snapshot written by SaveSnapshot.
+//
+// Parameters:
+//   - path: The absolute or relative path to the snapshot file
+//
+// Returns:
+//   - *DirectorySnapshot: The snapshot
+//   - error: An error if the file can't be read or is not a snapshot, nil otherwise
+//
+// Example:
+//
+//	baseline, err := ufs.LoadSnapshot("/var/lib/audit/etc.snapshot.json")
+//	if err != nil {
+//	    fmt.Printf("Error loading baseline: %v\n", err)
+//	    return
+//	}
+func (ufs *UFS) LoadSnapshot(path string) (_ *DirectorySnapshot, err error) {
+	defer ufs.recoverPanic("LoadSnapshot", &err)
+
+	data, err := readBackendFile(ufs.backend(), path)
+	if err != nil {
+		return nil, ufs.wrapError(err, "LoadSnapshot")
+	}
+	var snapshot DirectorySnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("LoadSnapshot: invalid snapshot %s: %w", path, err)
+	}
+	if snapshot.Version != snapshotVersion || snapshot.Tree == nil {
+		return nil, fmt.Errorf("LoadSnapshot: unsupported snapshot version %d: %s", snapshot.Version, path)
+	}
+	return &snapshot, nil
+}
+
+// CompareSnapshots returns the changes between two snapshots of a tree, matching the entries by their
+// path relative to the root, so the snapshots may be of trees in different places.
+// Files are compared by checksum when both snapshots have checksums of the same algorithm, by size
+// and modification time otherwise. Directories are reported when added, removed, or when their
+// permissions change, not when their modification time does.
+//
+// Parameters:
+//   - previous: The earlier snapshot
+//   - current: The later snapshot
+//
+// Returns:
+//   - *SnapshotDiff: The changes, sorted by path
+//
+// Example:
+//
+//	diff := ufs.CompareSnapshots(baseline, current)
+//	for _, path := range diff.Paths(ufs.SnapshotModified) {
+//	    fmt.Println("Modified:", path)
+//	}
+func CompareSnapshots(previous, current *DirectorySnapshot) *SnapshotDiff {
+	oldEntries, newEntries := previous.Entries(), current.Entries()
+	byHash := previous != nil && current != nil && previous.Algorithm != "" && previous.Algorithm == current.Algorithm
+
+	diff := &SnapshotDiff{Changes: []SnapshotChange{}}
+	for rel, before := range oldEntries {
+		after, exists := newEntries[rel]
+		if !exists {
+			diff.Changes = append(diff.Changes, SnapshotChange{Kind: SnapshotRemoved, Path: rel, Old: before})
+			continue
+		}
+		if kind, changed := compareSnapshotEntries(before, after, byHash); changed {
+			diff.Changes = append(diff.Changes, SnapshotChange{Kind: kind, Path: rel, Old: before, New: after})
+		}
+	}
+	for rel, after := range newEntries {
+		if _, exists := oldEntries[rel]; !exists {
+			diff.Changes = append(diff.Changes, SnapshotChange{Kind: SnapshotAdded, Path: rel, New: after})
+		}
+	}
+
+	sort.Slice(diff.Changes, func(i, j int) bool {
+		return diff.Changes[i].Path < diff.Changes[j].Path
+	})
+	return diff
+}
+
+// compareSnapshotEntries returns how an entry present in both snapshots changed
+func compareSnapshotEntries(before, after *SnapshotEntry, byHash bool) (SnapshotChangeKind, bool) {
+	if before.Mode.Type() != after.Mode.Type() {
+		return SnapshotTypeChanged, true
+	}
+
+	switch {
+	case before.IsDir:
+	case before.Mode&os.ModeSymlink != 0:
+		if before.Target != after.Target {
+			return SnapshotModified, true
+		}
+	case byHash && before.Hash != "" && after.Hash != "":
+		if before.Size != after.Size || !strings.EqualFold(before.Hash, after.Hash) {
+			return SnapshotModified, true
+		}
+	default:
+		if before.Size != after.Size || !before.ModTime.Equal(after.ModTime) {
+			return SnapshotModified, true
+		}
+	}
+
+	if before.Mode.Perm() != after.Mode.Perm() {
+		return SnapshotModeChanged, true
+	}
+	return "", false
+}
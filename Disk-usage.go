@@ -0,0 +1,272 @@
+package ufs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+Disk-usage.go contains functions to query and watch the free space of a filesystem.
+
+Agents managing cache or log directories need to react before the disk fills up.
+WatchFreeSpace polls the filesystem holding a path and calls back when the available
+space drops below a threshold. It can also free space by itself, deleting the oldest files
+of cleanup directories until the threshold is reached again.
+
+Functions:
+- GetDiskUsage: Returns the total, free and available space of the filesystem holding a path.
+- WatchFreeSpace: Calls back when the available space drops below a threshold.
+- WatchFreeSpaceWithOptions: WatchFreeSpace with a polling interval and a cleanup policy.
+*/
+
+// defaultFreeSpaceInterval is the polling interval used by WatchFreeSpace
+const defaultFreeSpaceInterval = 30 * time.Second
+
+// DiskUsage describes the capacity of a filesystem, in bytes.
+type DiskUsage struct {
+	Total     uint64 // Size of the filesystem
+	Free      uint64 // Free space, including the space reserved for the administrator
+	Available uint64 // Free space usable by the current user
+}
+
+// WatchFreeSpaceOptions controls WatchFreeSpaceWithOptions.
+type WatchFreeSpaceOptions struct {
+	// Interval is the time between two checks, 0 uses 30 seconds
+	Interval time.Duration
+
+	// CleanupDirs are directories whose oldest files (by modification time) are deleted,
+	// one by one, while the available space is below the threshold. Empty disables the cleanup.
+	CleanupDirs []string
+}
+
+// GetDiskUsage returns the capacity of the filesystem holding a path.
+//
+// Parameters:
+//   - path: The absolute or relative path to any file or directory of the filesystem
+//
+// Returns:
+//   - DiskUsage: The total, free and available space in bytes
+//   - error: An error if the path doesn't exist or the platform is not supported, nil otherwise
+//
+// Example:
+//
+//	usage, err := ufs.GetDiskUsage("/var/log")
+//	if err != nil {
+//	    fmt.Printf("Error reading disk usage: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("%d of %d bytes available\n", usage.Available, usage.Total)
+func (ufs *UFS) GetDiskUsage(path string) (_ DiskUsage, err error) {
+	defer ufs.recoverPanic("GetDiskUsage", &err)
+
+	usage, err := diskUsage(path)
+	if err != nil {
+		return DiskUsage{}, ufs.wrapError(err, "GetDiskUsage")
+	}
+	return usage, nil
+}
+
+// WatchFreeSpace checks the filesystem holding a path every 30 seconds, and calls callback
+// when the available space drops below threshold. The callback is called once per drop:
+// it is called again only after the available space went back above the threshold.
+// The first check is done immediately.
+//
+// Parameters:
+//   - path: The absolute or relative path to any file or directory of the filesystem to watch
+//   - threshold: The available space, in bytes, under which the callback is called
+//   - callback: The function called with the current usage
+//
+// Returns:
+//   - func(): A function stopping the watch, waiting for a running callback to return
+//   - error: An error if the disk usage of path can't be read, nil otherwise
+//
+// Example:
+//
+//	stop, err := ufs.WatchFreeSpace("/var/cache/myapp", 5<<30, func(usage ufs.DiskUsage) {
+//	    log.Printf("Only %d bytes left on the cache disk", usage.Available)
+//	})
+//	if err != nil {
+//	    fmt.Printf("Error watching free space: %v\n", err)
+//	    return
+//	}
+//	defer stop()
+func (ufs *UFS) WatchFreeSpace(path string, threshold uint64, callback func(usage DiskUsage)) (_ func(), err error) {
+	defer ufs.recoverPanic("WatchFreeSpace", &err)
+
+	if err := ufs.requireOS("WatchFreeSpace"); err != nil {
+		return nil, err
+	}
+
+	return ufs.watchFreeSpace(path, threshold, callback, nil, "WatchFreeSpace")
+}
+
+// WatchFreeSpaceWithOptions watches the free space like WatchFreeSpace, with a custom polling
+// interval and an optional cleanup policy. When CleanupDirs are set, the oldest files of these
+// directories are deleted on every check while the available space is below the threshold,
+// after the callback was called.
+// Errors while checking or cleaning are reported through the error handling options.
+//
+// Parameters:
+//   - path: The absolute or relative path to any file or directory of the filesystem to watch
+//   - threshold: The available space, in bytes, under which the callback is called and the cleanup runs
+//   - callback: The function called with the current usage, can be nil when only the cleanup is wanted
+//   - opts: The polling and cleanup settings, nil behaves like WatchFreeSpace
+//
+// Returns:
+//   - func(): A function stopping the watch, waiting for a running check to return
+//   - error: An error if the disk usage of path can't be read, nil otherwise
+//
+// Example:
+//
+//	stop, err := ufs.WatchFreeSpaceWithOptions("/var/log/myapp", 1<<30, nil, &ufs.WatchFreeSpaceOptions{
+//	    Interval:    time.Minute,
+//	    CleanupDirs: []string{"/var/log/myapp/archive"},
+//	})
+//	if err != nil {
+//	    fmt.Printf("Error watching free space: %v\n", err)
+//	    return
+//	}
+//	defer stop()
+func (ufs *UFS) WatchFreeSpaceWithOptions(path string, threshold uint64, callback func(usage DiskUsage), opts *WatchFreeSpaceOptions) (_ func(), err error) {
+	defer ufs.recoverPanic("WatchFreeSpaceWithOptions", &err)
+
+	if err := ufs.requireOS("WatchFreeSpaceWithOptions"); err != nil {
+		return nil, err
+	}
+
+	return ufs.watchFreeSpace(path, threshold, callback, opts, "WatchFreeSpaceWithOptions")
+}
+
+func (ufs *UFS) watchFreeSpace(path string, threshold uint64, callback func(usage DiskUsage), opts *WatchFreeSpaceOptions, operation string) (_ func(), err error) {
+	defer ufs.recoverPanic(operation, &err)
+
+	if opts == nil {
+		opts = &WatchFreeSpaceOptions{}
+	}
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultFreeSpaceInterval
+	}
+
+	// Fail early when the path can't be watched at all
+	if _, err := diskUsage(path); err != nil {
+		return nil, ufs.wrapError(err, operation)
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		low := false
+		for {
+			low = ufs.checkFreeSpace(path, threshold, callback, opts.CleanupDirs, low, operation)
+
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}, nil
+}
+
+// checkFreeSpace runs a single check of watchFreeSpace and returns whether the space is below the threshold.
+// wasLow tells whether the previous check was already below it, so the callback is only called once per drop.
+func (ufs *UFS) checkFreeSpace(path string, threshold uint64, callback func(usage DiskUsage), cleanupDirs []string, wasLow bool, operation string) (low bool) {
+	// A panicking callback must not kill the watch
+	defer func() {
+		if r := recover(); r != nil {
+			ufs.handleError(&PanicError{Op: operation, Value: r}, operation)
+			low = true
+		}
+	}()
+
+	usage, err := diskUsage(path)
+	if err != nil {
+		ufs.handleError(err, operation)
+		return wasLow
+	}
+	if usage.Available >= threshold {
+		return false
+	}
+
+	if !wasLow && callback != nil {
+		callback(usage)
+	}
+	// Nothing is deleted in dry-run mode, so the same files would be recorded on every check:
+	// they are only recorded once per drop
+	if len(cleanupDirs) > 0 && !(wasLow && ufs.opts.DryRun) {
+		if err := ufs.freeSpaceCleanup(path, threshold, cleanupDirs, operation); err != nil {
+			ufs.handleError(err, operation)
+		}
+	}
+	return true
+}
+
+// freeSpaceCleanup deletes the oldest files of the cleanup directories until the available space
+// of the filesystem holding path reaches the threshold or there is nothing left to delete.
+// In dry-run mode, the deletions are recorded until the available space plus the size of the recorded
+// files reaches the threshold.
+func (ufs *UFS) freeSpaceCleanup(path string, threshold uint64, cleanupDirs []string, operation string) error {
+	type candidate struct {
+		path    string
+		size    uint64
+		modTime time.Time
+	}
+	var candidates []candidate
+
+	for _, dir := range cleanupDirs {
+		err := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return ufs.decideWalkError(filePath, err, WalkSkip, operation)
+			}
+			if info.Mode().IsRegular() {
+				candidates = append(candidates, candidate{path: filePath, size: uint64(info.Size()), modTime: info.ModTime()})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].modTime.Before(candidates[j].modTime)
+	})
+
+	var previewed uint64 // Size of the files recorded in dry-run mode
+	for _, file := range candidates {
+		if ufs.dryRun(operation, DryRunRemove, file.path, "") {
+			previewed += file.size
+		} else if err := os.Remove(file.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("couldn't free space: %w", err)
+		}
+
+		usage, err := diskUsage(path)
+		if err != nil {
+			return err
+		}
+		if usage.Available+previewed >= threshold {
+			return nil
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !dragonfly && !windows
+
+package ufs
+
+import (
+	"errors"
+	"runtime"
+)
+
+// diskUsage is not supported on this platform
+func diskUsage(path string) (DiskUsage, error) {
+	return DiskUsage{}, errors.New("disk usage is not supported on " + runtime.GOOS)
+}
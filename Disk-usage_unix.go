@@ -0,0 +1,25 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package ufs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// diskUsage returns the capacity of the filesystem holding path using statfs
+func diskUsage(path string) (DiskUsage, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return DiskUsage{}, &os.PathError{Op: "statfs", Path: path, Err: err}
+	}
+
+	// Field types differ between systems, and Bavail can be negative on BSDs when the reserve is used
+	blockSize := uint64(stat.Bsize)
+	return DiskUsage{
+		Total:     uint64(stat.Blocks) * blockSize,
+		Free:      uint64(stat.Bfree) * blockSize,
+		Available: uint64(max(int64(stat.Bavail), 0)) * blockSize,
+	}, nil
+}
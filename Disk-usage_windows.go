@@ -0,0 +1,23 @@
+//go:build windows
+
+package ufs
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// diskUsage returns the capacity of the volume holding path using GetDiskFreeSpaceEx
+func diskUsage(path string) (DiskUsage, error) {
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return DiskUsage{}, err
+	}
+
+	var usage DiskUsage
+	if err := windows.GetDiskFreeSpaceEx(pathPtr, &usage.Available, &usage.Total, &usage.Free); err != nil {
+		return DiskUsage{}, &os.PathError{Op: "GetDiskFreeSpaceEx", Path: path, Err: err}
+	}
+	return usage, nil
+}
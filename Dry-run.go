@@ -0,0 +1,165 @@
+package ufs
+
+import "fmt"
+
+/*
+Dry-run.go contains the dry-run mode, previewing the destructive and bulk operations without touching the disk.
+
+With Options.DryRun, the move, delete, remove, sync and compress functions check their arguments and
+read the disk as usual, but record the changes they would make instead of making them, and report
+success. Scripts can be previewed safely:
+
+	preview := ufs.NewUfs(&ufs.Options{DryRun: true})
+	preview.RemoveByPattern("/var/cache/app", "*.tmp")
+	preview.SyncDirectories("/srv/site", "/mnt/backup/site", &ufs.SyncOptions{DeleteExtraneous: true})
+	for _, action := range preview.DryRunActions() {
+	    fmt.Println(action) // e.g. "SyncDirectories: copy /srv/site/index.html -> /mnt/backup/site/index.html"
+	}
+
+Preview runs a function with a dry-run copy of an instance, to preview a single call:
+
+	actions := ufs.Preview(func(dry *ufs.UFS) {
+	    dry.MoveDirectory("/data/old", "/archive/old")
+	})
+
+Each change is recorded by the function making it: RemoveByPattern records a removal per file
+through RemoveFile, MoveDirectory records a single move. As nothing is written, the later steps of
+a previewed script see the disk unchanged: removing a file twice is recorded twice, and a step
+depending on the result of a previous one (extracting an archive just created...) fails.
+The state files of the synchronizations aren't saved, OperationQueue.Run leaves its queue unchanged,
+and the manifest returned by CompressDirectorySplit lists no parts, as they depend on the compressed size.
+
+Functions:
+- DryRunActions: Returns the changes recorded by the dry-run mode
+- ResetDryRun: Clears the recorded changes
+- IsDryRun: Reports whether the dry-run mode is enabled
+- Preview: Runs a function with a dry-run copy of the instance and returns its changes
+*/
+
+// Actions recorded by the dry-run mode, see DryRunAction
+const (
+	// DryRunRemove removes a file, a symbolic link or an empty directory
+	DryRunRemove = "remove"
+	// DryRunRemoveAll removes a directory with its contents
+	DryRunRemoveAll = "remove-all"
+	// DryRunMove moves Source to Path
+	DryRunMove = "move"
+	// DryRunCopy copies the file or directory Source to Path
+	DryRunCopy = "copy"
+	// DryRunWrite creates or overwrites the file Path, an archive of Source for the compress functions
+	DryRunWrite = "write"
+	// DryRunMkdir creates the directory Path
+	DryRunMkdir = "mkdir"
+	// DryRunSymlink creates the symbolic link Path pointing to Source
+	DryRunSymlink = "symlink"
+	// DryRunExtract extracts the archive Source to Path
+	DryRunExtract = "extract"
+)
+
+// DryRunAction is a change recorded instead of being made in dry-run mode.
+type DryRunAction struct {
+	// Operation is the function making the change, e.g. "RemoveFile"
+	Operation string
+	// Action is the change, one of the DryRun* constants
+	Action string
+	// Path is the path created, written or removed
+	Path string
+	// Source is where the content of Path comes from for moves, copies, links and archives, "" otherwise
+	Source string
+}
+
+// String describes the action, e.g. "MoveFile: move /a.txt -> /b.txt"
+func (action DryRunAction) String() string {
+	if action.Source == "" {
+		return fmt.Sprintf("%s: %s %s", action.Operation, action.Action, action.Path)
+	}
+	return fmt.Sprintf("%s: %s %s -> %s", action.Operation, action.Action, action.Source, action.Path)
+}
+
+// dryRun records a change when the dry-run mode is enabled and returns true, the caller must then
+// skip it. It returns false, recording nothing, otherwise.
+func (ufs *UFS) dryRun(operation, action, path, source string) bool {
+	if !ufs.opts.DryRun {
+		return false
+	}
+	recorded := DryRunAction{Operation: operation, Action: action, Path: path, Source: source}
+	ufs.dryRunMu.Lock()
+	ufs.dryRunActions = append(ufs.dryRunActions, recorded)
+	ufs.dryRunMu.Unlock()
+	if ufs.opts.OnDryRun != nil {
+		ufs.opts.OnDryRun(recorded)
+	}
+	return true
+}
+
+// IsDryRun reports whether the dry-run mode is enabled, see Options.DryRun.
+//
+// Returns:
+//   - bool: true if the destructive and bulk operations only record their changes
+//
+// Example:
+//
+//	if ufs.IsDryRun() {
+//	    fmt.Println("Preview, nothing will be changed")
+//	}
+func (ufs *UFS) IsDryRun() bool {
+	return ufs.opts.DryRun
+}
+
+// DryRunActions returns the changes recorded in dry-run mode since the instance was created
+// or ResetDryRun was called, in the order they would have been made.
+//
+// Returns:
+//   - []DryRunAction: The recorded changes, empty outside of the dry-run mode
+//
+// Example:
+//
+//	preview := ufs.NewUfs(&ufs.Options{DryRun: true})
+//	preview.RemoveDirectoryContents("/tmp/build")
+//	for _, action := range preview.DryRunActions() {
+//	    fmt.Println(action)
+//	}
+func (ufs *UFS) DryRunActions() []DryRunAction {
+	ufs.dryRunMu.Lock()
+	defer ufs.dryRunMu.Unlock()
+	return append([]DryRunAction{}, ufs.dryRunActions...)
+}
+
+// ResetDryRun clears the changes recorded in dry-run mode.
+//
+// Example:
+//
+//	preview.ResetDryRun()
+//	preview.CompressDirectory("/srv/site", "/backup/site.zip")
+//	fmt.Println(preview.DryRunActions())
+func (ufs *UFS) ResetDryRun() {
+	ufs.dryRunMu.Lock()
+	ufs.dryRunActions = nil
+	ufs.dryRunMu.Unlock()
+}
+
+// Preview runs fn with a copy of the instance in dry-run mode and returns the changes it recorded.
+// The copy has the options of the instance, the instance itself is left unchanged.
+//
+// Parameters:
+//   - fn: The function to preview, calling the functions of dry
+//
+// Returns:
+//   - []DryRunAction: The changes fn would make, in order
+//
+// Example:
+//
+//	actions := ufs.Preview(func(dry *ufs.UFS) {
+//	    dry.CompressAndRemove("/data/logs/2023", "/archive/logs-2023.zip")
+//	})
+//	for _, action := range actions {
+//	    fmt.Println(action)
+//	}
+func (ufs *UFS) Preview(fn func(dry *UFS)) []DryRunAction {
+	opts := ufs.opts
+	opts.DryRun = true
+	dry := &UFS{opts: opts}
+	dry.shutdown.Store(ufs.shutdown.Load())
+	fn(dry)
+	return dry.DryRunActions()
+}
@@ -0,0 +1,280 @@
+package ufs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+/*
+Error-codes.go contains functions to find out why a file operation failed, the same way on every OS.
+
+The errors returned by the OS differ between platforms (errno on Unix, Windows error codes) and their
+messages are translated in the language of the system ("Access is denied.", "Accès refusé.").
+ErrorCodeOf maps them to a small set of ErrorCode values, so the callers can react to a failure
+without comparing messages or importing syscall:
+
+	if err := ufs.CopyFile(src, dst); ufs.IsPermissionDenied(err) {
+	    fmt.Println("Run the program as administrator")
+	}
+
+Errors returned by the UFS functions keep the error of the OS in their chain, so they can be passed
+as is, wrapped or not. NormalizeError replaces the message of the OS by a message in English.
+
+Functions:
+- ErrorCodeOf: Returns the ErrorCode of an error.
+- NormalizeError: Converts an error to a *FileSystemError with a portable code and message.
+- IsPermissionDenied, IsNotFound, IsAlreadyExists, IsDirectoryNotEmpty, IsDiskFull, IsCrossDevice, IsFileInUse:
+  Report whether an error has the corresponding code.
+*/
+
+// ErrorCode is the portable reason of a failed file operation, see ErrorCodeOf.
+type ErrorCode int
+
+const (
+	// CodeUnknown is returned for nil errors and errors that are not recognized
+	CodeUnknown ErrorCode = iota
+	// CodeNotFound means the file or directory doesn't exist
+	CodeNotFound
+	// CodeAlreadyExists means the file or directory already exists
+	CodeAlreadyExists
+	// CodePermissionDenied means the user isn't allowed to access the file or do the operation
+	CodePermissionDenied
+	// CodeDirectoryNotEmpty means a directory couldn't be removed or replaced because it has entries
+	CodeDirectoryNotEmpty
+	// CodeNotDirectory means a directory was expected, but the path is a file
+	CodeNotDirectory
+	// CodeIsDirectory means a file was expected, but the path is a directory
+	CodeIsDirectory
+	// CodeDiskFull means the disk is full, or the disk quota of the user or the quota of a directory (SetDirectoryQuota) is exceeded
+	CodeDiskFull
+	// CodeReadOnly means the file system or the media is read-only
+	CodeReadOnly
+	// CodeCrossDevice means a file can't be renamed or linked to another file system
+	CodeCrossDevice
+	// CodeFileInUse means the file is locked or used by another process
+	CodeFileInUse
+	// CodeTooManyOpenFiles means the process or the system can't open more files
+	CodeTooManyOpenFiles
+	// CodeNameTooLong means the file name or the path is too long
+	CodeNameTooLong
+	// CodeInvalidName means the file name has characters not allowed by the file system
+	CodeInvalidName
+	// CodeNotSupported means the operation is not supported by the OS or the file system
+	CodeNotSupported
+	// CodeTimeout means the operation timed out, e.g. on a network share
+	CodeTimeout
+)
+
+// errorCodeMessages are the portable messages of the codes
+var errorCodeMessages = map[ErrorCode]string{
+	CodeUnknown:           "unknown error",
+	CodeNotFound:          "no such file or directory",
+	CodeAlreadyExists:     "file already exists",
+	CodePermissionDenied:  "permission denied",
+	CodeDirectoryNotEmpty: "directory not empty",
+	CodeNotDirectory:      "not a directory",
+	CodeIsDirectory:       "is a directory",
+	CodeDiskFull:          "no space left on device",
+	CodeReadOnly:          "read-only file system",
+	CodeCrossDevice:       "cross-device link",
+	CodeFileInUse:         "file is in use by another process",
+	CodeTooManyOpenFiles:  "too many open files",
+	CodeNameTooLong:       "file name too long",
+	CodeInvalidName:       "invalid file name",
+	CodeNotSupported:      "operation not supported",
+	CodeTimeout:           "operation timed out",
+}
+
+// String returns the portable message of the code, like "permission denied"
+func (code ErrorCode) String() string {
+	if message, ok := errorCodeMessages[code]; ok {
+		return message
+	}
+	return fmt.Sprintf("ErrorCode(%d)", int(code))
+}
+
+// FileSystemError is an error of the OS with its portable code, returned by NormalizeError.
+type FileSystemError struct {
+	Code ErrorCode // Portable reason of the failure
+	Op   string    // Failed operation, like "open" or "rename", empty if unknown
+	Path string    // Path the operation failed on, empty if unknown
+	Err  error     // Original error
+}
+
+func (e *FileSystemError) Error() string {
+	switch {
+	case e.Op != "" && e.Path != "":
+		return fmt.Sprintf("%s %s: %s", e.Op, e.Path, e.Code)
+	case e.Path != "":
+		return fmt.Sprintf("%s: %s", e.Path, e.Code)
+	default:
+		return e.Code.String()
+	}
+}
+
+// Unwrap returns the original error
+func (e *FileSystemError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is the io/fs error of the code (fs.ErrNotExist, fs.ErrExist or fs.ErrPermission),
+// so errors.Is gives the same answer on every OS
+func (e *FileSystemError) Is(target error) bool {
+	switch e.Code {
+	case CodeNotFound:
+		return target == fs.ErrNotExist
+	case CodeAlreadyExists:
+		return target == fs.ErrExist
+	case CodePermissionDenied:
+		return target == fs.ErrPermission
+	}
+	return false
+}
+
+// ErrorCodeOf returns the portable reason of a failed file operation, looking through wrapped errors
+// for the error code of the OS.
+//
+// Parameters:
+//   - err: The error to inspect, usually returned by a UFS or os function
+//
+// Returns:
+//   - ErrorCode: The code of the error, CodeUnknown if err is nil or not recognized
+//
+// Example:
+//
+//	switch ufs.ErrorCodeOf(err) {
+//	case ufs.CodeDiskFull:
+//	    fmt.Println("Free some space and try again")
+//	case ufs.CodeFileInUse:
+//	    fmt.Println("Close the file in the other program and try again")
+//	}
+func (ufs *UFS) ErrorCodeOf(err error) ErrorCode {
+	return errorCodeOf(err)
+}
+
+// NormalizeError converts an error of the OS to a *FileSystemError, whose message is the same
+// on every OS and in every language, like "open /etc/shadow: permission denied".
+// The original error stays in the chain, for errors.Is and errors.As.
+//
+// Parameters:
+//   - err: The error to convert
+//
+// Returns:
+//   - error: A *FileSystemError, err itself if it isn't recognized or already normalized, nil if err is nil
+//
+// Example:
+//
+//	if err := ufs.RemoveFile(path); err != nil {
+//	    log.Printf("cleanup failed: %v", ufs.NormalizeError(err))
+//	}
+func (ufs *UFS) NormalizeError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var normalized *FileSystemError
+	if errors.As(err, &normalized) {
+		return err
+	}
+
+	code := errorCodeOf(err)
+	if code == CodeUnknown {
+		return err
+	}
+	normalized = &FileSystemError{Code: code, Err: err}
+
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	var syscallErr *os.SyscallError
+	switch {
+	case errors.As(err, &pathErr):
+		normalized.Op, normalized.Path = pathErr.Op, pathErr.Path
+	case errors.As(err, &linkErr):
+		normalized.Op, normalized.Path = linkErr.Op, linkErr.Old+" "+linkErr.New
+	case errors.As(err, &syscallErr):
+		normalized.Op = syscallErr.Syscall
+	}
+	return normalized
+}
+
+// IsPermissionDenied reports whether an error means the user isn't allowed to access a file,
+// e.g. EACCES or EPERM on Unix, ERROR_ACCESS_DENIED or ERROR_PRIVILEGE_NOT_HELD on Windows.
+//
+// Parameters:
+//   - err: The error to inspect
+//
+// Returns:
+//   - bool: true if the code of the error is CodePermissionDenied
+//
+// Example:
+//
+//	if _, err := ufs.ReadFile("/root/secret"); ufs.IsPermissionDenied(err) {
+//	    fmt.Println("Access denied")
+//	}
+func (ufs *UFS) IsPermissionDenied(err error) bool {
+	return errorCodeOf(err) == CodePermissionDenied
+}
+
+// IsNotFound reports whether an error means a file or directory doesn't exist (CodeNotFound).
+func (ufs *UFS) IsNotFound(err error) bool {
+	return errorCodeOf(err) == CodeNotFound
+}
+
+// IsAlreadyExists reports whether an error means a file or directory already exists (CodeAlreadyExists).
+func (ufs *UFS) IsAlreadyExists(err error) bool {
+	return errorCodeOf(err) == CodeAlreadyExists
+}
+
+// IsDirectoryNotEmpty reports whether an error means a directory has entries (CodeDirectoryNotEmpty).
+func (ufs *UFS) IsDirectoryNotEmpty(err error) bool {
+	return errorCodeOf(err) == CodeDirectoryNotEmpty
+}
+
+// IsDiskFull reports whether an error means the disk is full or the quota exceeded (CodeDiskFull).
+func (ufs *UFS) IsDiskFull(err error) bool {
+	return errorCodeOf(err) == CodeDiskFull
+}
+
+// IsCrossDevice reports whether an error means a file can't be renamed to another file system (CodeCrossDevice).
+func (ufs *UFS) IsCrossDevice(err error) bool {
+	return errorCodeOf(err) == CodeCrossDevice
+}
+
+// IsFileInUse reports whether an error means a file is locked or used by another process (CodeFileInUse).
+func (ufs *UFS) IsFileInUse(err error) bool {
+	return errorCodeOf(err) == CodeFileInUse
+}
+
+// errorCodeOf returns the code of an error: a *FileSystemError, then the error code of the OS,
+// then the generic errors of the io/fs and os packages
+func errorCodeOf(err error) ErrorCode {
+	if err == nil {
+		return CodeUnknown
+	}
+
+	var normalized *FileSystemError
+	if errors.As(err, &normalized) {
+		return normalized.Code
+	}
+
+	if code := osErrorCode(err); code != CodeUnknown {
+		return code
+	}
+
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return CodeNotFound
+	case errors.Is(err, fs.ErrExist):
+		return CodeAlreadyExists
+	case errors.Is(err, fs.ErrPermission):
+		return CodePermissionDenied
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return CodeTimeout
+	case errors.Is(err, errors.ErrUnsupported):
+		return CodeNotSupported
+	case errors.Is(err, ErrQuotaExceeded):
+		return CodeDiskFull
+	}
+	return CodeUnknown
+}
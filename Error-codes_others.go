@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd && !dragonfly && !windows
+
+package ufs
+
+// osErrorCode doesn't know the error codes of this platform, ErrorCodeOf falls back to the io/fs errors
+func osErrorCode(err error) ErrorCode {
+	return CodeUnknown
+}
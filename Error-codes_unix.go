@@ -0,0 +1,53 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package ufs
+
+import (
+	"errors"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// osErrorCode returns the code of the errno in the chain of err
+func osErrorCode(err error) ErrorCode {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return CodeUnknown
+	}
+
+	switch errno {
+	case unix.ENOENT:
+		return CodeNotFound
+	case unix.EEXIST:
+		return CodeAlreadyExists
+	case unix.EACCES, unix.EPERM:
+		return CodePermissionDenied
+	case unix.ENOTEMPTY:
+		return CodeDirectoryNotEmpty
+	case unix.ENOTDIR:
+		return CodeNotDirectory
+	case unix.EISDIR:
+		return CodeIsDirectory
+	case unix.ENOSPC, unix.EDQUOT:
+		return CodeDiskFull
+	case unix.EROFS:
+		return CodeReadOnly
+	case unix.EXDEV:
+		return CodeCrossDevice
+	case unix.EBUSY, unix.ETXTBSY:
+		return CodeFileInUse
+	case unix.EMFILE, unix.ENFILE:
+		return CodeTooManyOpenFiles
+	case unix.ENAMETOOLONG:
+		return CodeNameTooLong
+	case unix.ETIMEDOUT:
+		return CodeTimeout
+	}
+
+	// ENOTSUP and EOPNOTSUPP are the same value on Linux, but not on the BSDs
+	if errno == unix.ENOTSUP || errno == unix.EOPNOTSUPP {
+		return CodeNotSupported
+	}
+	return CodeUnknown
+}
@@ -0,0 +1,56 @@
+//go:build windows
+
+package ufs
+
+import (
+	"errors"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// osErrorCode returns the code of the Windows error code in the chain of err.
+// The Unix-like errno values that the Go runtime returns on Windows (syscall.EISDIR, ...) are mapped too,
+// except syscall.ENOENT and syscall.ENOTDIR which are ERROR_FILE_NOT_FOUND and ERROR_PATH_NOT_FOUND.
+func osErrorCode(err error) ErrorCode {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return CodeUnknown
+	}
+
+	switch errno {
+	case windows.ERROR_FILE_NOT_FOUND, windows.ERROR_PATH_NOT_FOUND, windows.ERROR_INVALID_DRIVE,
+		windows.ERROR_BAD_NETPATH, windows.ERROR_BAD_NET_NAME:
+		return CodeNotFound
+	case windows.ERROR_FILE_EXISTS, windows.ERROR_ALREADY_EXISTS, syscall.EEXIST:
+		return CodeAlreadyExists
+	case windows.ERROR_ACCESS_DENIED, windows.ERROR_PRIVILEGE_NOT_HELD, windows.ERROR_CANT_ACCESS_FILE,
+		windows.ERROR_NETWORK_ACCESS_DENIED, windows.ERROR_ELEVATION_REQUIRED, syscall.EACCES, syscall.EPERM:
+		return CodePermissionDenied
+	case windows.ERROR_DIR_NOT_EMPTY, syscall.ENOTEMPTY:
+		return CodeDirectoryNotEmpty
+	case windows.ERROR_DIRECTORY:
+		return CodeNotDirectory
+	case syscall.EISDIR:
+		return CodeIsDirectory
+	case windows.ERROR_DISK_FULL, windows.ERROR_HANDLE_DISK_FULL, windows.ERROR_DISK_QUOTA_EXCEEDED, syscall.ENOSPC:
+		return CodeDiskFull
+	case windows.ERROR_WRITE_PROTECT, syscall.EROFS:
+		return CodeReadOnly
+	case windows.ERROR_NOT_SAME_DEVICE, syscall.EXDEV:
+		return CodeCrossDevice
+	case windows.ERROR_SHARING_VIOLATION, windows.ERROR_LOCK_VIOLATION, windows.ERROR_USER_MAPPED_FILE:
+		return CodeFileInUse
+	case windows.ERROR_TOO_MANY_OPEN_FILES, syscall.EMFILE:
+		return CodeTooManyOpenFiles
+	case windows.ERROR_FILENAME_EXCED_RANGE, syscall.ENAMETOOLONG:
+		return CodeNameTooLong
+	case windows.ERROR_INVALID_NAME, windows.ERROR_BAD_PATHNAME:
+		return CodeInvalidName
+	case windows.ERROR_NOT_SUPPORTED, windows.ERROR_INVALID_FUNCTION, syscall.EWINDOWS:
+		return CodeNotSupported
+	case windows.ERROR_SEM_TIMEOUT, syscall.ETIMEDOUT:
+		return CodeTimeout
+	}
+	return CodeUnknown
+}
@@ -0,0 +1,389 @@
+package ufs
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+/*
+Errors.go contains the typed errors returned by the UFS package.
+
+Functions returning an error can be inspected with errors.Is / errors.As
+to find out what went wrong without parsing error messages.
+
+Every function reading or writing files recovers from the panics raised while it runs, in its worker
+goroutines and in the callbacks it calls too: functions returning an error return a *PanicError, the
+others report it like their other errors (Options.ErrorSink, Options.ShowError) and return false or
+empty results. Constructors, the accessors of the options and the dry run, and the classification
+functions of Error-codes.go, which touch no file, don't.
+*/
+
+// ErrPanicRecovered is matched (via errors.Is) by every error produced from a recovered panic.
+var ErrPanicRecovered = errors.New("ufs: recovered from panic")
+
+// PanicError is returned when an unexpected panic happened inside a UFS operation.
+// Instead of crashing the caller, the panic is recovered and converted to this error.
+type PanicError struct {
+	Op    string      // Name of the operation that panicked
+	Value interface{} // Value passed to panic
+	Stack []byte      // Stack trace captured at the time of the panic
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s: unexpected panic: %v", e.Op, e.Value)
+}
+
+// Is reports whether the target is ErrPanicRecovered
+func (e *PanicError) Is(target error) bool {
+	return target == ErrPanicRecovered
+}
+
+// Unwrap returns the panic value if it was an error
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// recoverPanic converts a panic into a *PanicError stored in errp.
+// It must be deferred directly by the function it protects:
+//
+//	defer ufs.recoverPanic("ReadFile", &err)
+func (ufs *UFS) recoverPanic(operation string, errp *error) {
+	if r := recover(); r != nil {
+		*errp = &PanicError{Op: operation, Value: r, Stack: debug.Stack()}
+	}
+}
+
+// recoverPanicBool converts a panic into a false result for functions returning a bool.
+// The panic is reported through handleError like any other error.
+// It must be deferred directly by the function it protects:
+//
+//	defer ufs.recoverPanicBool("IsFileHidden", &ok)
+func (ufs *UFS) recoverPanicBool(operation string, okp *bool) {
+	if r := recover(); r != nil {
+		*okp = false
+		ufs.handleError(&PanicError{Op: operation, Value: r, Stack: debug.Stack()}, operation)
+	}
+}
+
+// recoverPanicReport reports a panic through handleError for functions returning neither an error nor
+// a bool, which then return the zero values of their results (nil, 0, false). It must be deferred
+// directly by the function it protects:
+//
+//	defer ufs.recoverPanicReport("GetFileList")
+func (ufs *UFS) recoverPanicReport(operation string) {
+	if r := recover(); r != nil {
+		ufs.handleError(&PanicError{Op: operation, Value: r, Stack: debug.Stack()}, operation)
+	}
+}
+
+// recoverWorker calls fn, converting a panic into a *PanicError of operation. The worker goroutines of
+// an operation run their jobs through it: the recoverPanic deferred by the operation doesn't catch the
+// panics of the goroutines it starts.
+func (ufs *UFS) recoverWorker(operation string, fn func() error) (err error) {
+	defer ufs.recoverPanic(operation, &err)
+	return fn()
+}
+
+// ErrExtractionLimitExceeded is matched (via errors.Is) by every error produced when an archive
+// exceeds one of the ExtractLimits.
+var ErrExtractionLimitExceeded = errors.New("ufs: extraction limit exceeded")
+
+// ExtractionLimitError is returned when an archive exceeds one of the ExtractLimits.
+// Nothing is extracted when an archive is refused.
+type ExtractionLimitError struct {
+	Limit  string // Name of the exceeded limit, e.g. "MaxTotalBytes"
+	Entry  string // Archive entry that exceeded it
+	Actual string // Value reached by the archive
+	Max    string // Configured limit
+}
+
+func (e *ExtractionLimitError) Error() string {
+	return fmt.Sprintf("extraction limit %s exceeded at %s: %s > %s", e.Limit, e.Entry, e.Actual, e.Max)
+}
+
+// Is reports whether the target is ErrExtractionLimitExceeded
+func (e *ExtractionLimitError) Is(target error) bool {
+	return target == ErrExtractionLimitExceeded
+}
+
+// ErrToolNotFound is matched (via errors.Is) by every error produced when an external program
+// needed by an operation is not installed.
+var ErrToolNotFound = errors.New("ufs: required tool not found")
+
+// ToolNotFoundError is returned when none of the external programs able to handle a format is installed.
+type ToolNotFoundError struct {
+	Format string   // Format that couldn't be handled, e.g. "7z"
+	Tools  []string // Programs that were looked for, in order of preference
+}
+
+func (e *ToolNotFoundError) Error() string {
+	return fmt.Sprintf("no tool found to handle %s archives, install one of: %s", e.Format, strings.Join(e.Tools, ", "))
+}
+
+// Is reports whether the target is ErrToolNotFound
+func (e *ToolNotFoundError) Is(target error) bool {
+	return target == ErrToolNotFound
+}
+
+// ErrGeneratedFileEdited is matched (via errors.Is) by the error returned by WriteGeneratedFile
+// when the file to regenerate was edited by hand, or was never generated.
+var ErrGeneratedFileEdited = errors.New("ufs: generated file was edited by hand")
+
+// ErrInvalidSignature is matched (via errors.Is) by the error returned by VerifyFileSignature
+// when the signature doesn't match the file, e.g. the file was modified or signed with another key.
+var ErrInvalidSignature = errors.New("ufs: invalid signature")
+
+// ErrUnsafeArchivePath is matched (via errors.Is) by the errors TestArchive reports for entries whose path
+// is absolute or escapes the extraction directory (zip slip).
+var ErrUnsafeArchivePath = errors.New("ufs: unsafe archive entry path")
+
+// ErrCloneNotSupported is returned by CopyFileCloned when the file system (or the platform)
+// can't clone files, or when the source and destination are on different file systems.
+var ErrCloneNotSupported = errors.New("ufs: file cloning not supported")
+
+// ErrDirectoryLocked is matched (via errors.Is) by the error returned by LockDirectory
+// when another live process holds the lock.
+var ErrDirectoryLocked = errors.New("ufs: directory is locked")
+
+// DirectoryLockedError is returned by LockDirectory when the directory is locked by another process.
+type DirectoryLockedError struct {
+	Dir      string    // Locked directory
+	PID      int       // Process ID of the lock owner
+	Hostname string    // Host name of the machine the owner runs on
+	Since    time.Time // Time the lock was taken
+}
+
+func (e *DirectoryLockedError) Error() string {
+	return fmt.Sprintf("directory %s is locked by process %d on %s since %s", e.Dir, e.PID, e.Hostname, e.Since.Format(time.DateTime))
+}
+
+// Is reports whether the target is ErrDirectoryLocked
+func (e *DirectoryLockedError) Is(target error) bool {
+	return target == ErrDirectoryLocked
+}
+
+// ErrChunkCorrupt is matched (via errors.Is) by the error returned by AssembleFromManifest
+// when a part doesn't match its size or checksum in the manifest.
+var ErrChunkCorrupt = errors.New("ufs: chunk doesn't match its manifest")
+
+// ChunkCorruptError is returned by AssembleFromManifest when a part is missing data or was altered.
+type ChunkCorruptError struct {
+	Path         string // Path of the part
+	Index        int    // Index of the part in the manifest, starting at 1
+	ExpectedSize int64  // Size recorded in the manifest
+	ActualSize   int64  // Size of the part file
+	Expected     string // SHA-256 recorded in the manifest, hex encoded
+	Actual       string // SHA-256 of the part file, "" when its size already differs
+}
+
+func (e *ChunkCorruptError) Error() string {
+	if e.ExpectedSize != e.ActualSize {
+		return fmt.Sprintf("part %d (%s) is %d bytes, the manifest expects %d", e.Index, e.Path, e.ActualSize, e.ExpectedSize)
+	}
+	return fmt.Sprintf("part %d (%s) has sha256 %s, the manifest expects %s", e.Index, e.Path, e.Actual, e.Expected)
+}
+
+// Is reports whether the target is ErrChunkCorrupt
+func (e *ChunkCorruptError) Is(target error) bool {
+	return target == ErrChunkCorrupt
+}
+
+// ErrQuotaExceeded is matched (via errors.Is) by the error returned by writes through ufs that would take
+// a directory over the quota set with SetDirectoryQuota.
+var ErrQuotaExceeded = errors.New("ufs: directory quota exceeded")
+
+// QuotaExceededError is returned by the writes that don't fit in the quota of a directory.
+// Nothing is written when a write is refused, except by WriteFileFromReader which removes the file.
+type QuotaExceededError struct {
+	Dir        string // Directory with the quota
+	Path       string // File written, relative to Dir
+	MaxBytes   int64  // Quota of the directory
+	UsedBytes  int64  // Space used in the directory by the other files
+	WriteBytes int64  // Size of the file after the write
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("writing %d bytes to %s exceeds the quota of %s: %d of %d bytes used by other files", e.WriteBytes, e.Path, e.Dir, e.UsedBytes, e.MaxBytes)
+}
+
+// Is reports whether the target is ErrQuotaExceeded
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// ErrShuttingDown is returned by the cancellable operations started after the shutdown of their
+// instance started, see ShutdownManager.
+var ErrShuttingDown = errors.New("ufs: shutting down")
+
+// ErrCapabilityUnavailable is matched (via errors.Is) by the error returned by operations the
+// scoped storage mode forbids, see HasCapability.
+var ErrCapabilityUnavailable = errors.New("ufs: operation not available in scoped storage")
+
+// CapabilityError is returned by an operation forbidden in scoped storage mode, e.g. creating a symbolic
+// link on Android. It matches ErrCapabilityUnavailable and errors.ErrUnsupported.
+type CapabilityError struct {
+	Operation  string     // Operation refused, e.g. "CreateSymlink"
+	Path       string     // Path the operation was given
+	Capability Capability // What the operation needs
+	Platform   string     // runtime.GOOS
+}
+
+func (e *CapabilityError) Error() string {
+	return fmt.Sprintf("%s %s: %s not available in scoped storage on %s", e.Operation, e.Path, e.Capability, e.Platform)
+}
+
+// Is reports whether the target is ErrCapabilityUnavailable or errors.ErrUnsupported
+func (e *CapabilityError) Is(target error) bool {
+	return target == ErrCapabilityUnavailable || target == errors.ErrUnsupported
+}
+
+// ErrBackendUnsupported is matched (via errors.Is) by the error returned by operations that need the
+// operating system when the instance uses another Backend, see Backend.go.
+var ErrBackendUnsupported = errors.New("ufs: operation not supported by the backend")
+
+// BackendUnsupportedError is returned by an operation relying on the operating system (archives, links,
+// locks, system commands...) called on an instance whose Options.Backend is not the operating system.
+// It matches ErrBackendUnsupported and errors.ErrUnsupported.
+type BackendUnsupportedError struct {
+	Operation string // Operation refused, e.g. "CompressDirectory"
+	Backend   string // Backend of the instance, "memory" or the type of a custom one
+}
+
+func (e *BackendUnsupportedError) Error() string {
+	return fmt.Sprintf("%s: not supported by the %s backend, it needs the operating system", e.Operation, e.Backend)
+}
+
+// Is reports whether the target is ErrBackendUnsupported or errors.ErrUnsupported
+func (e *BackendUnsupportedError) Is(target error) bool {
+	return target == ErrBackendUnsupported || target == errors.ErrUnsupported
+}
+
+// ErrCopyVerificationFailed is matched (via errors.Is) by the error returned by CopyFileVerified and
+// CopyDirectoryVerified when a copy doesn't match its source.
+var ErrCopyVerificationFailed = errors.New("ufs: copy verification failed")
+
+// CopyVerificationError is returned when the checksum of a copy, read back after the copy, differs from
+// the checksum of the data written. The corrupted copy is removed.
+type CopyVerificationError struct {
+	Source      string        // Path of the source file
+	Destination string        // Path of the copy
+	Algorithm   HashAlgorithm // Checksum algorithm
+	Expected    string        // Checksum of the data written, hex encoded
+	Actual      string        // Checksum of the copy read back, hex encoded
+}
+
+func (e *CopyVerificationError) Error() string {
+	return fmt.Sprintf("copy of %s to %s is corrupted: %s %s read back, %s written", e.Source, e.Destination, e.Algorithm, e.Actual, e.Expected)
+}
+
+// Is reports whether the target is ErrCopyVerificationFailed
+func (e *CopyVerificationError) Is(target error) bool {
+	return target == ErrCopyVerificationFailed
+}
+
+// ErrArchivePasswordRequired is matched (via errors.Is) by the extraction errors of archives with
+// encrypted entries extracted without a password.
+var ErrArchivePasswordRequired = errors.New("ufs: archive is encrypted, a password is required")
+
+// ErrArchiveWrongPassword is matched (via errors.Is) by the extraction errors of archives whose
+// password is wrong.
+var ErrArchiveWrongPassword = errors.New("ufs: wrong archive password")
+
+// ErrArchiveCorrupt is matched (via errors.Is) by the extraction errors of truncated or damaged archives.
+var ErrArchiveCorrupt = errors.New("ufs: archive is corrupted")
+
+// ErrArchiveUnsupported is matched (via errors.Is) by the extraction errors of archives using a
+// compression or encryption method the reader doesn't support.
+var ErrArchiveUnsupported = errors.New("ufs: archive uses an unsupported method")
+
+// ArchiveError is returned when an archive can't be extracted because of its content: it is encrypted,
+// the password is wrong, it is damaged or uses an unsupported method. See Archive-errors.go.
+type ArchiveError struct {
+	Archive string           // Path of the archive, "" for archives read from a stream
+	Entry   string           // Entry that failed, "" when the archive itself can't be read
+	Kind    ArchiveErrorKind // Reason of the failure
+	Err     error            // Error of the reader, or of the external tool with its output
+}
+
+func (e *ArchiveError) Error() string {
+	name := e.Archive
+	if e.Entry != "" {
+		name = strings.TrimPrefix(name+": "+e.Entry, ": ")
+	}
+	if name == "" {
+		return fmt.Sprintf("archive %s: %v", e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", name, e.Kind, e.Err)
+}
+
+// Is reports whether the target is the sentinel error of the kind, e.g. ErrArchiveCorrupt
+func (e *ArchiveError) Is(target error) bool {
+	return target == e.Kind.sentinel()
+}
+
+// Unwrap returns the error of the reader or the tool
+func (e *ArchiveError) Unwrap() error {
+	return e.Err
+}
+
+// ErrRollbackIncomplete is matched (via errors.Is) by the errors of transactions whose rollback
+// failed, some of the executed steps weren't undone. See TransactionError.RollbackErrors.
+var ErrRollbackIncomplete = errors.New("ufs: transaction rollback incomplete")
+
+// TransactionError is returned by Transaction.Commit when a step failed. See Transaction.go.
+type TransactionError struct {
+	Step           int     // Index of the failed step, in the order they were queued
+	Operation      string  // Step that failed, e.g. "CopyFile"
+	Path           string  // Path the step created, wrote or deleted
+	Err            error   // Error of the step
+	RollbackErrors []error // Errors met undoing the executed steps, empty if the rollback completed
+}
+
+func (e *TransactionError) Error() string {
+	message := fmt.Sprintf("transaction step %d (%s %s) failed: %v", e.Step, e.Operation, e.Path, e.Err)
+	if len(e.RollbackErrors) > 0 {
+		return fmt.Sprintf("%s, rollback incomplete: %v", message, errors.Join(e.RollbackErrors...))
+	}
+	return message + ", rolled back"
+}
+
+// Is reports whether the target is ErrRollbackIncomplete and the rollback failed
+func (e *TransactionError) Is(target error) bool {
+	return target == ErrRollbackIncomplete && len(e.RollbackErrors) > 0
+}
+
+// Unwrap returns the error of the step
+func (e *TransactionError) Unwrap() error {
+	return e.Err
+}
+
+// ErrSymlinkLoop is reported by Walk when a followed symbolic link points to one of the directories
+// containing it, which would be walked forever.
+var ErrSymlinkLoop = errors.New("ufs: symbolic link loop")
+
+// ErrMisuse is matched (via errors.Is) by every MisuseError.
+var ErrMisuse = errors.New("ufs: invalid call")
+
+// MisuseError is reported in strict mode (Options.StrictMode) when a function is called on a path of
+// the wrong kind or with an invalid argument, e.g. RemoveDirectory on a directory that isn't empty or
+// CopyFile on a directory. The boolean functions pass it to Options.ErrorSink, the others return it.
+type MisuseError struct {
+	Op     string // Name of the operation, e.g. "RenameFile"
+	Reason string // What is wrong, e.g. "New name should not be a path"
+	Path   string // Path or argument at fault
+}
+
+func (e *MisuseError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.Op, e.Reason, e.Path)
+}
+
+// Is reports whether the target is ErrMisuse
+func (e *MisuseError) Is(target error) bool {
+	return target == ErrMisuse
+}
@@ -1,5 +1,13 @@
 package ufs
 
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
 /*
 Export exports the UFS functions for external use.
 
@@ -82,6 +90,30 @@ func (metadata) GetFolderSize(path string) int64 {
 	return GetFolderSize(path)
 }
 
+func (metadata) ListFilesRecursive(path string) ([]string, error) {
+	return ListFilesRecursive(path)
+}
+
+func (metadata) Features() FeatureSet {
+	return Features()
+}
+
+func (metadata) Version() string {
+	return Version()
+}
+
+func (metadata) GetExtendedMetadata(path string) (*FileMetadata, error) {
+	return GetExtendedMetadata(path)
+}
+
+func (metadata) GetFileOwner(path string) (FileOwner, error) {
+	return GetFileOwner(path)
+}
+
+func (metadata) GetCreationTime(path string) (time.Time, error) {
+	return GetCreationTime(path)
+}
+
 // Exported archive methods
 func (archive) CompressDirectory(sourcePath, destPath string) error {
 	return CompressDirectory(sourcePath, destPath)
@@ -91,6 +123,86 @@ func (archive) ExtractArchive(sourcePath, destPath string) error {
 	return ExtractArchive(sourcePath, destPath)
 }
 
+func (archive) CompressDirectoryContext(ctx context.Context, sourcePath, destPath string) error {
+	return CompressDirectoryContext(ctx, sourcePath, destPath)
+}
+
+func (archive) ExtractArchiveContext(ctx context.Context, sourcePath, destPath string) error {
+	return ExtractArchiveContext(ctx, sourcePath, destPath)
+}
+
+func (archive) CompressDirectoryWithOptions(sourcePath, destPath string, opts *CompressOptions) error {
+	return CompressDirectoryWithOptions(sourcePath, destPath, opts)
+}
+
+func (archive) CompressFileWithOptions(sourcePath, destPath string, opts *CompressOptions) error {
+	return CompressFileWithOptions(sourcePath, destPath, opts)
+}
+
+func (archive) CompressDirectorySplit(sourcePath, destPrefix string, chunkSize int64) (*SplitManifest, error) {
+	return CompressDirectorySplit(sourcePath, destPrefix, chunkSize)
+}
+
+func (archive) ExtractSplitArchive(manifestPath, destPath string) error {
+	return ExtractSplitArchive(manifestPath, destPath)
+}
+
+func (archive) CompressDirectoryCheckpointed(sourcePath, destPath, checkpointPath string) error {
+	return CompressDirectoryCheckpointed(sourcePath, destPath, checkpointPath)
+}
+
+func (archive) ExtractArchiveWithOptions(sourcePath, destPath string, opts *ExtractOptions) (*ExtractReport, error) {
+	return ExtractArchiveWithOptions(sourcePath, destPath, opts)
+}
+
+func (archive) CompressDirectoryIncremental(sourcePath, destPath, sinceManifest string) (*BackupManifest, error) {
+	return CompressDirectoryIncremental(sourcePath, destPath, sinceManifest)
+}
+
+func (archive) RestoreIncremental(archives []string, destPath string) error {
+	return RestoreIncremental(archives, destPath)
+}
+
+func (archive) ChunkArchive(sourcePath, dstDir string, avgChunkSize int) (*ChunkManifest, []string, error) {
+	return ChunkArchive(sourcePath, dstDir, avgChunkSize)
+}
+
+func (archive) RestoreChunkArchive(storeDir, destPath string) error {
+	return RestoreChunkArchive(storeDir, destPath)
+}
+
+func (archive) CompressDirectoryTo(sourcePath string, w io.Writer) error {
+	return CompressDirectoryTo(sourcePath, w)
+}
+
+func (archive) ExtractArchiveFrom(r io.ReaderAt, size int64, destPath string) error {
+	return ExtractArchiveFrom(r, size, destPath)
+}
+
+func (archive) ExtractWithSystemCommandOptions(sourcePath, destPath string, opts *ExtractOptions) error {
+	return ExtractWithSystemCommandOptions(sourcePath, destPath, opts)
+}
+
+func (archive) ExtractFiles(archivePath, destPath string, patterns []string) ([]string, error) {
+	return ExtractFiles(archivePath, destPath, patterns)
+}
+
+func (archive) ExtractMatching(archivePath, destPath string, opts *ExtractOptions, globs ...string) (*ExtractReport, error) {
+	return ExtractMatching(archivePath, destPath, opts, globs...)
+}
+
+func (archive) CopyThenCompress(src, workDir, archivePath string) error {
+	return CopyThenCompress(src, workDir, archivePath)
+}
+
+func (archive) DownloadAndExtract(ctx context.Context, rawURL, dst, checksum string) error {
+	return DownloadAndExtract(ctx, rawURL, dst, checksum)
+}
+
+func (archive) CompressAndUpload(src string, target Backend, targetPath string) error {
+	return CompressAndUpload(src, target, targetPath)
+}
+
 func (archive) CompressFile(sourcePath, destPath string) error {
 	return CompressFile(sourcePath, destPath)
 }
@@ -132,6 +244,38 @@ func (archive) ExtractWithSystemCommand(sourcePath, destPath string) error {
 
 }
 
+func (archive) ListArchiveContents(path string) ([]ArchiveEntry, error) {
+	return ListArchiveContents(path)
+}
+
+func (archive) OpenArchiveFS(path string) (*ArchiveFS, error) {
+	return OpenArchiveFS(path)
+}
+
+func (archive) ReadArchiveTree(path string, includeFiles bool) map[string]interface{} {
+	return ReadArchiveTree(path, includeFiles)
+}
+
+func (archive) VerifyArchive(path string) (*ArchiveVerifyReport, error) {
+	return VerifyArchive(path)
+}
+
+func (archive) TestArchive(path string) (*ArchiveVerifyReport, error) {
+	return TestArchive(path)
+}
+
+func (archive) Extract7z(sourcePath, destPath string) error {
+	return Extract7z(sourcePath, destPath)
+}
+
+func (archive) ExtractRar(sourcePath, destPath string) error {
+	return ExtractRar(sourcePath, destPath)
+}
+
+func (archive) ExtractEncrypted(sourcePath, destPath, password string) error {
+	return ExtractEncrypted(sourcePath, destPath, password)
+}
+
 // Exported file functions methods
 func (fileFunctions) ReadFile(path string) ([]byte, error) {
 	return ReadFile(path)
@@ -145,6 +289,14 @@ func (fileFunctions) WriteFile(path string, data []byte) error {
 	return WriteFile(path, data)
 }
 
+func (fileFunctions) WriteFileIfChanged(path string, data []byte) (bool, error) {
+	return WriteFileIfChanged(path, data)
+}
+
+func (fileFunctions) WriteFileAtomic(path string, data []byte) error {
+	return WriteFileAtomic(path, data)
+}
+
 func (fileFunctions) WriteStringToFile(path string, content string) error {
 	return WriteStringToFile(path, content)
 }
@@ -165,6 +317,18 @@ func (fileFunctions) MoveFile(src, dst string) bool {
 	return dufs.MoveFile(src, dst)
 }
 
+func (fileFunctions) MoveFileWithPolicy(src, dst string, policy OverwritePolicy) (bool, string) {
+	return MoveFileWithPolicy(src, dst, policy)
+}
+
+func (fileFunctions) MoveFileUnique(src, dst string) (bool, string) {
+	return MoveFileUnique(src, dst)
+}
+
+func (fileFunctions) CopyFileWithPolicy(src, dst string, policy OverwritePolicy) (string, error) {
+	return CopyFileWithPolicy(src, dst, policy)
+}
+
 func (fileFunctions) DeleteFile(path string) bool {
 	return DeleteFile(path)
 }
@@ -173,6 +337,18 @@ func (fileFunctions) CopyFileWithPermissions(src, dst string) error {
 	return CopyFileWithPermissions(src, dst)
 }
 
+func (fileFunctions) CopyFileCloned(src, dst string) error {
+	return CopyFileCloned(src, dst)
+}
+
+func (fileFunctions) CopyFileWithProgress(src, dst string, progress ProgressFunc, opts *TransferOptions) error {
+	return CopyFileWithProgress(src, dst, progress, opts)
+}
+
+func (fileFunctions) CopyFileVerified(src, dst string, algo HashAlgorithm) (string, error) {
+	return CopyFileVerified(src, dst, algo)
+}
+
 func (fileFunctions) MoveFileWithPermissions(src, dst string) error {
 	return MoveFileWithPermissions(src, dst)
 }
@@ -185,6 +361,18 @@ func (fileFunctions) SplitFile(src string, chunkSize int64) ([]string, error) {
 	return SplitFile(src, chunkSize)
 }
 
+func (fileFunctions) SplitFileWithOptions(src string, chunkSize int64, opts *SplitOptions) ([]string, error) {
+	return SplitFileWithOptions(src, chunkSize, opts)
+}
+
+func (fileFunctions) SplitFileByLines(src string, linesPerChunk int, opts *SplitOptions) ([]string, error) {
+	return SplitFileByLines(src, linesPerChunk, opts)
+}
+
+func (fileFunctions) AssembleFromManifest(manifestPath, dst string) error {
+	return AssembleFromManifest(manifestPath, dst)
+}
+
 func (fileFunctions) CleanUpFiles(files []string) ([]string, error) {
 	return CleanUpFiles(files)
 }
@@ -201,6 +389,206 @@ func (fileFunctions) AppendToFirstLine(path string, content string) error {
 	return AppendToFirstLine(path, content)
 }
 
+func (fileFunctions) WriteFileFromReader(path string, r io.Reader) (int64, error) {
+	return WriteFileFromReader(path, r)
+}
+
+func (fileFunctions) ReadFileToWriter(path string, w io.Writer) (int64, error) {
+	return ReadFileToWriter(path, w)
+}
+
+func (fileFunctions) OpenBufferedReader(path string) (*BufferedReader, error) {
+	return OpenBufferedReader(path)
+}
+
+func (fileFunctions) OpenBufferedWriter(path string) (*BufferedWriter, error) {
+	return OpenBufferedWriter(path)
+}
+
+func (fileFunctions) IterateLines(path string, fn func(lineNo int, line string) (stop bool, err error)) error {
+	return IterateLines(path, fn)
+}
+
+func (fileFunctions) ReadFirstNLines(path string, n int) ([]string, error) {
+	return ReadFirstNLines(path, n)
+}
+
+func (fileFunctions) ReadLastNLines(path string, n int) ([]string, error) {
+	return ReadLastNLines(path, n)
+}
+
+func (fileFunctions) ReadLinesRange(path string, from, to int) ([]string, error) {
+	return ReadLinesRange(path, from, to)
+}
+
+func (fileFunctions) InsertLineAt(path string, n int, content string) error {
+	return InsertLineAt(path, n, content)
+}
+
+func (fileFunctions) ReplaceLine(path string, n int, content string) error {
+	return ReplaceLine(path, n, content)
+}
+
+func (fileFunctions) DeleteLine(path string, n int) error {
+	return DeleteLine(path, n)
+}
+
+func (fileFunctions) DeleteLinesMatching(path string, pattern string) (int, error) {
+	return DeleteLinesMatching(path, pattern)
+}
+
+func (fileFunctions) WriteGeneratedFile(path, content, marker string) error {
+	return WriteGeneratedFile(path, content, marker)
+}
+
+func (fileFunctions) ReadJSONFile(path string, v any) error {
+	return ReadJSONFile(path, v)
+}
+
+func (fileFunctions) WriteJSONFile(path string, v any, indent string) error {
+	return WriteJSONFile(path, v, indent)
+}
+
+func (fileFunctions) ReadYAMLFile(path string, v any) error {
+	return ReadYAMLFile(path, v)
+}
+
+func (fileFunctions) WriteYAMLFile(path string, v any) error {
+	return WriteYAMLFile(path, v)
+}
+
+func (fileFunctions) ReadTOMLFile(path string, v any) error {
+	return ReadTOMLFile(path, v)
+}
+
+func (fileFunctions) WriteTOMLFile(path string, v any) error {
+	return WriteTOMLFile(path, v)
+}
+
+func (fileFunctions) ReadCSVFile(path string) ([][]string, error) {
+	return ReadCSVFile(path)
+}
+
+func (fileFunctions) WriteCSVFile(path string, rows [][]string) error {
+	return WriteCSVFile(path, rows)
+}
+
+func (fileFunctions) IterateCSVRows(path string, opts *CSVOptions, fn func(row CSVRow) (stop bool, err error)) error {
+	return IterateCSVRows(path, opts, fn)
+}
+
+func (fileFunctions) ReadEnvFile(path string) (map[string]string, error) {
+	return ReadEnvFile(path)
+}
+
+func (fileFunctions) WriteEnvFile(path string, values map[string]string) error {
+	return WriteEnvFile(path, values)
+}
+
+func (fileFunctions) ReadINI(path string) (map[string]map[string]string, error) {
+	return ReadINI(path)
+}
+
+func (fileFunctions) WriteINI(path string, data map[string]map[string]string) error {
+	return WriteINI(path, data)
+}
+
+func (fileFunctions) SearchInFile(path string, pattern string) ([]SearchMatch, error) {
+	return SearchInFile(path, pattern)
+}
+
+func (fileFunctions) ReplaceInFile(path, pattern, replacement string, opts *ReplaceOptions) (*ReplaceResult, error) {
+	return ReplaceInFile(path, pattern, replacement, opts)
+}
+
+func (fileFunctions) HashFile(path string, algo HashAlgorithm) (string, error) {
+	return HashFile(path, algo)
+}
+
+func (fileFunctions) TruncateFile(path string, size int64) error {
+	return TruncateFile(path, size)
+}
+
+func (fileFunctions) TouchFile(path string) error {
+	return TouchFile(path)
+}
+
+func (fileFunctions) SetFileTimes(path string, atime, mtime time.Time) error {
+	return SetFileTimes(path, atime, mtime)
+}
+
+func (fileFunctions) PreallocateFile(path string, size int64) error {
+	return PreallocateFile(path, size)
+}
+
+func (fileFunctions) IsBinaryFile(path string) (bool, error) {
+	return IsBinaryFile(path)
+}
+
+func (fileFunctions) DetectMIMEType(path string) (string, error) {
+	return DetectMIMEType(path)
+}
+
+func (fileFunctions) GetFileKind(path string) (FileKind, error) {
+	return GetFileKind(path)
+}
+
+func (fileFunctions) IngestFile(src, libraryRoot string, layout IngestLayout) (*IngestResult, error) {
+	return IngestFile(src, libraryRoot, layout)
+}
+
+func (fileFunctions) SignFile(path string, signer FileSigner) (string, error) {
+	return SignFile(path, signer)
+}
+
+func (fileFunctions) VerifyFileSignature(path string, verifier FileVerifier) error {
+	return VerifyFileSignature(path, verifier)
+}
+
+func (fileFunctions) MoveFiles(pairs []FilePair, workers int) []BatchResult {
+	return MoveFiles(pairs, workers)
+}
+
+func (fileFunctions) CopyFiles(pairs []FilePair, workers int) []BatchResult {
+	return CopyFiles(pairs, workers)
+}
+
+func (fileFunctions) DeleteFiles(paths []string, workers int) []BatchResult {
+	return DeleteFiles(paths, workers)
+}
+
+func (fileFunctions) PlanOperations(ops []PlannedOperation) (*Plan, error) {
+	return PlanOperations(ops)
+}
+
+func (fileFunctions) Glob(pattern string, excludes ...string) ([]string, error) {
+	return Glob(pattern, excludes...)
+}
+
+func (fileFunctions) GlobIn(root, pattern string, excludes ...string) ([]string, error) {
+	return GlobIn(root, pattern, excludes...)
+}
+
+func (fileFunctions) Find(root string, opts FindOptions) ([]string, error) {
+	return Find(root, opts)
+}
+
+func (fileFunctions) FindFunc(root string, opts FindOptions, fn func(path string, info os.FileInfo) error) error {
+	return FindFunc(root, opts, fn)
+}
+
+func (fileFunctions) ReadMacMetadata(path string) (*MacMetadata, error) {
+	return ReadMacMetadata(path)
+}
+
+func (fileFunctions) WriteMacMetadata(path string, meta *MacMetadata) error {
+	return WriteMacMetadata(path, meta)
+}
+
+func (fileFunctions) MergeFileLines(base, incoming, dst string, strategy MergeStrategy) (int, error) {
+	return MergeFileLines(base, incoming, dst, strategy)
+}
+
 // Exported directory functions methods
 func (dirFunctions) CreateFile(path string) bool {
 	return CreateFile(path)
@@ -219,7 +607,191 @@ func (dirFunctions) RenameDirectory(oldPath, newPath string) bool {
 }
 
 func (dirFunctions) CopyDirectory(src, dst string) bool {
-	return dufs.copyDirectoryRecursive(src, dst)
+	return dufs.copyDirectoryRecursive(src, dst, false)
+}
+
+func (dirFunctions) CopyDirectoryWithReport(src, dst string, opts *CopyDirectoryOptions) (*CopyReport, error) {
+	return CopyDirectory(src, dst, opts)
+}
+
+func (dirFunctions) CopyDirectoryVerified(src, dst string, algo HashAlgorithm, opts *CopyDirectoryOptions) (*CopyReport, error) {
+	return CopyDirectoryVerified(src, dst, algo, opts)
+}
+
+func (dirFunctions) SyncDirectories(src, dst string, opts *SyncOptions) (*SyncReport, error) {
+	return SyncDirectories(src, dst, opts)
+}
+
+func (dirFunctions) BidirectionalSync(a, b string, opts *BidirectionalSyncOptions) (*BidirectionalSyncReport, error) {
+	return BidirectionalSync(a, b, opts)
+}
+
+func (dirFunctions) MoveDirectoryWithProgress(src, dst string, progress ProgressFunc, opts *TransferOptions) error {
+	return MoveDirectoryWithProgress(src, dst, progress, opts)
+}
+
+func (dirFunctions) MoveDirectoryWithMerge(src, dst string, opts *MergeOptions) (*MergeReport, error) {
+	return MoveDirectoryWithMerge(src, dst, opts)
+}
+
+func (dirFunctions) Walk(root string, opts WalkOptions, fn func(path string, d fs.DirEntry) error) error {
+	return Walk(root, opts, fn)
+}
+
+func (dirFunctions) WalkParallel(root string, workers int, fn func(path string, d fs.DirEntry) error) error {
+	return WalkParallel(root, workers, fn)
+}
+
+func (dirFunctions) ListDirectory(path string, opts ListOptions) ([]FileMetadata, error) {
+	return ListDirectory(path, opts)
+}
+
+func (dirFunctions) ListDirectoryFS(fsys fs.FS, root string, opts ListOptions) ([]FileMetadata, error) {
+	return ListDirectoryFS(fsys, root, opts)
+}
+
+func (dirFunctions) SnapshotDirectory(root string, opts *SnapshotOptions) (*DirectorySnapshot, error) {
+	return SnapshotDirectory(root, opts)
+}
+
+func (dirFunctions) SaveSnapshot(snapshot *DirectorySnapshot, path string) error {
+	return SaveSnapshot(snapshot, path)
+}
+
+func (dirFunctions) LoadSnapshot(path string) (*DirectorySnapshot, error) {
+	return LoadSnapshot(path)
+}
+
+func (dirFunctions) CompareSnapshots(previous, current *DirectorySnapshot) *SnapshotDiff {
+	return CompareSnapshots(previous, current)
+}
+
+func (dirFunctions) RenderTree(root string, opts *TreeOptions) (string, error) {
+	return RenderTree(root, opts)
+}
+
+func (dirFunctions) RenderTreeFS(fsys fs.FS, root string, opts *TreeOptions) (string, error) {
+	return RenderTreeFS(fsys, root, opts)
+}
+
+func (dirFunctions) PrintTree(root string, opts *TreeOptions) error {
+	return PrintTree(root, opts)
+}
+
+func (dirFunctions) CopyDirectoryParallel(src, dst string, opts *CopyParallelOptions) error {
+	return CopyDirectoryParallel(src, dst, opts)
+}
+
+func (dirFunctions) SeedIfMissing(seedDir, targetDir string) (*SeedReport, error) {
+	return SeedIfMissing(seedDir, targetDir)
+}
+
+func (dirFunctions) GetDiskUsage(path string) (DiskUsage, error) {
+	return GetDiskUsage(path)
+}
+
+func (dirFunctions) WatchFreeSpace(path string, threshold uint64, callback func(usage DiskUsage)) (func(), error) {
+	return WatchFreeSpace(path, threshold, callback)
+}
+
+func (dirFunctions) WatchFreeSpaceWithOptions(path string, threshold uint64, callback func(usage DiskUsage), opts *WatchFreeSpaceOptions) (func(), error) {
+	return WatchFreeSpaceWithOptions(path, threshold, callback, opts)
+}
+
+func (dirFunctions) OpenCacheDir(dir string, maxBytes int64) (*CacheDir, error) {
+	return OpenCacheDir(dir, maxBytes)
+}
+
+func (dirFunctions) UpdateCurrentSymlink(linkPath, newTarget string) error {
+	return UpdateCurrentSymlink(linkPath, newTarget)
+}
+
+func (dirFunctions) ListReleases(releasesDir string) ([]Release, error) {
+	return ListReleases(releasesDir)
+}
+
+func (dirFunctions) PruneOldReleases(releasesDir, currentLink string, keep int) ([]Release, error) {
+	return PruneOldReleases(releasesDir, currentLink, keep)
+}
+
+func (dirFunctions) GenerateIndex(root string, format IndexFormat) (int, error) {
+	return GenerateIndex(root, format)
+}
+
+func (dirFunctions) SearchInDirectory(dir string, pattern string, opts *SearchOptions) ([]SearchMatch, error) {
+	return SearchInDirectory(dir, pattern, opts)
+}
+
+func (dirFunctions) BuildNameIndex(root, indexPath string) (*NameIndex, error) {
+	return BuildNameIndex(root, indexPath)
+}
+
+func (dirFunctions) ReplaceInDirectory(dir, pattern, replacement string, opts *ReplaceOptions) ([]ReplaceResult, error) {
+	return ReplaceInDirectory(dir, pattern, replacement, opts)
+}
+
+func (dirFunctions) ComparePermissions(a, b string) ([]PermissionDifference, error) {
+	return ComparePermissions(a, b)
+}
+
+func (dirFunctions) LockDirectory(dir string) (*DirectoryLock, error) {
+	return LockDirectory(dir)
+}
+
+func (dirFunctions) SetDirectoryQuota(dir string, maxBytes int64) error {
+	return SetDirectoryQuota(dir, maxBytes)
+}
+
+func (dirFunctions) SetDirectoryQuotaWithOptions(dir string, maxBytes int64, opts *QuotaOptions) error {
+	return SetDirectoryQuotaWithOptions(dir, maxBytes, opts)
+}
+
+func (dirFunctions) GetDirectoryQuota(dir string) (QuotaUsage, error) {
+	return GetDirectoryQuota(dir)
+}
+
+func (dirFunctions) RefreshDirectoryQuota(dir string) (QuotaUsage, error) {
+	return RefreshDirectoryQuota(dir)
+}
+
+func (dirFunctions) RemoveDirectoryQuota(dir string) error {
+	return RemoveDirectoryQuota(dir)
+}
+
+func (dirFunctions) EvictOldestFiles(dir string, needBytes int64) error {
+	return EvictOldestFiles(dir, needBytes)
+}
+
+func (dirFunctions) RouteFiles(srcDir string, rules []RouteRule, opts *RouteOptions) (*RouteReport, error) {
+	return RouteFiles(srcDir, rules, opts)
+}
+
+func (dirFunctions) HashDirectory(dir string, algo HashAlgorithm, manifestPath string) (map[string]string, error) {
+	return HashDirectory(dir, algo, manifestPath)
+}
+
+func (dirFunctions) HashDirectoryParallel(root string, algo HashAlgorithm, workers int, progress func(HashProgress)) (*DirectoryHash, error) {
+	return HashDirectoryParallel(root, algo, workers, progress)
+}
+
+func (dirFunctions) VerifyChecksumManifest(dir, manifestPath string) ([]ChecksumMismatch, error) {
+	return VerifyChecksumManifest(dir, manifestPath)
+}
+
+func (dirFunctions) WriteChecksumSidecars(root string, algo HashAlgorithm) (int, error) {
+	return WriteChecksumSidecars(root, algo)
+}
+
+func (dirFunctions) VerifyChecksumSidecars(root string) ([]ChecksumMismatch, error) {
+	return VerifyChecksumSidecars(root)
+}
+
+func (dirFunctions) CleanDevArtifacts(root string, profiles ...DevArtifactProfile) (*DevArtifactReport, error) {
+	return CleanDevArtifacts(root, profiles...)
+}
+
+func (dirFunctions) PruneVendorTree(root string, rules *VendorPruneRules) (*VendorPruneReport, error) {
+	return PruneVendorTree(root, rules)
 }
 
 func (dirFunctions) MoveDirectory(src, dst string) bool {
@@ -0,0 +1,187 @@
+package ufs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*
+Extended-metadata.go contains the metadata GetFileMetadata leaves out because every OS stores it
+differently: the owner and group of a file, its creation (birth) time, its inode and its number of
+hard links.
+
+	meta, err := ufs.GetExtendedMetadata("report.pdf")
+	if err == nil {
+	    fmt.Printf("%s, owned by %s, created %s, %d links\n", meta.Name, meta.Owner, meta.CreatedAt, meta.Links)
+	}
+
+What is available depends on the platform, fields that aren't are left empty:
+- Linux: everything, the creation time through statx on kernels from 4.11 and file systems recording it
+  (ext4, Btrfs, XFS; not tmpfs before 6.x nor most network file systems)
+- macOS, FreeBSD, NetBSD, OpenBSD: everything
+- DragonFly, Solaris, illumos, AIX: everything except the creation time, which they don't record
+- Windows: everything, owners being accounts ("DOMAIN\user") identified by SIDs, the inode being the file index
+- Other systems (Plan 9, js, WASI): the fields of os.FileInfo only
+
+Functions:
+- GetExtendedMetadata: Returns the metadata of a file or directory with owner, inode, links and creation time
+- GetFileOwner: Returns the owner and group of a file or directory
+- GetCreationTime: Returns the creation time of a file or directory
+*/
+
+// FileMetadata is the metadata of a file or directory returned by GetExtendedMetadata.
+type FileMetadata struct {
+	Path    string      `json:"path"`
+	Name    string      `json:"name"`
+	Size    int64       `json:"size"`
+	Mode    os.FileMode `json:"mode"`
+	ModTime time.Time   `json:"modTime"`
+	IsDir   bool        `json:"isDir"`
+
+	Owner FileOwner `json:"owner"`
+
+	CreatedAt time.Time `json:"createdAt,omitempty"` // Zero when the platform or file system doesn't record it
+	Inode     uint64    `json:"inode,omitempty"`     // Inode number, or file index on Windows
+	Device    uint64    `json:"device,omitempty"`    // Device holding the file, or volume serial number on Windows
+	Links     uint64    `json:"links,omitempty"`     // Number of hard links to the file
+}
+
+// FileOwner is the owner and group of a file. Names are empty when the IDs have no account, e.g. files
+// extracted from an archive made on another machine.
+type FileOwner struct {
+	User  string `json:"user,omitempty"`  // Account name, "DOMAIN\user" on Windows
+	Group string `json:"group,omitempty"` // Group name
+	UID   string `json:"uid,omitempty"`   // Numeric user ID, or SID on Windows
+	GID   string `json:"gid,omitempty"`   // Numeric group ID, or SID on Windows
+}
+
+// errCreationTimeUnavailable is returned by GetCreationTime when the creation time isn't recorded
+var errCreationTimeUnavailable = fmt.Errorf("creation time not available: %w", errors.ErrUnsupported)
+
+// GetExtendedMetadata returns the metadata of a file or directory, with its owner, inode, number of links
+// and creation time when the platform provides them, see Extended-metadata.go.
+// Symbolic links are followed.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file or directory
+//
+// Returns:
+//   - *FileMetadata: The metadata of the file
+//   - error: An error if the file doesn't exist or can't be read, nil otherwise
+//
+// Example:
+//
+//	meta, err := ufs.GetExtendedMetadata("/srv/data/db.sqlite")
+//	if err != nil {
+//	    fmt.Printf("Error reading metadata: %v\n", err)
+//	    return
+//	}
+//	if meta.Links > 1 {
+//	    fmt.Printf("%s has %d hard links\n", meta.Path, meta.Links)
+//	}
+func (ufs *UFS) GetExtendedMetadata(path string) (_ *FileMetadata, err error) {
+	defer ufs.recoverPanic("GetExtendedMetadata", &err)
+
+	if err := ufs.requireOS("GetExtendedMetadata"); err != nil {
+		return nil, err
+	}
+
+	meta, err := extendedMetadata(path, true)
+	if err != nil {
+		return nil, ufs.wrapError(err, "GetExtendedMetadata")
+	}
+	return meta, nil
+}
+
+// extendedMetadata returns the extended metadata of path, of the target of a symbolic link with follow
+// and of the link itself otherwise, every field describing the same file
+func extendedMetadata(path string, follow bool) (*FileMetadata, error) {
+	stat := os.Stat
+	if !follow {
+		stat = os.Lstat
+	}
+	info, err := stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &FileMetadata{
+		Path:    path,
+		Name:    filepath.Base(path),
+		Size:    info.Size(),
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+		IsDir:   info.IsDir(),
+	}
+	if err := platformMetadata(path, info, meta, follow); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// GetFileOwner returns the owner and group of a file or directory. Symbolic links are followed.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file or directory
+//
+// Returns:
+//   - FileOwner: The user and group owning the file, empty on platforms without owners
+//   - error: An error if the file doesn't exist or can't be read, nil otherwise
+//
+// Example:
+//
+//	owner, err := ufs.GetFileOwner("/etc/passwd")
+//	if err == nil {
+//	    fmt.Printf("Owned by %s (%s), group %s\n", owner.User, owner.UID, owner.Group)
+//	}
+func (ufs *UFS) GetFileOwner(path string) (_ FileOwner, err error) {
+	defer ufs.recoverPanic("GetFileOwner", &err)
+
+	if err := ufs.requireOS("GetFileOwner"); err != nil {
+		return FileOwner{}, err
+	}
+
+	meta, err := ufs.GetExtendedMetadata(path)
+	if err != nil {
+		return FileOwner{}, err
+	}
+	return meta.Owner, nil
+}
+
+// GetCreationTime returns the creation (birth) time of a file or directory. Symbolic links are followed.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file or directory
+//
+// Returns:
+//   - time.Time: The time the file was created
+//   - error: An error if the file can't be read, or matching errors.ErrUnsupported when the platform or
+//     the file system doesn't record creation times
+//
+// Example:
+//
+//	created, err := ufs.GetCreationTime("photo.jpg")
+//	if errors.Is(err, errors.ErrUnsupported) {
+//	    fmt.Println("Creation time unknown")
+//	} else if err == nil {
+//	    fmt.Println("Created", created.Format(time.RFC1123))
+//	}
+func (ufs *UFS) GetCreationTime(path string) (_ time.Time, err error) {
+	defer ufs.recoverPanic("GetCreationTime", &err)
+
+	if err := ufs.requireOS("GetCreationTime"); err != nil {
+		return time.Time{}, err
+	}
+
+	meta, err := ufs.GetExtendedMetadata(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if meta.CreatedAt.IsZero() {
+		return time.Time{}, fmt.Errorf("GetCreationTime: %s: %w", path, errCreationTimeUnavailable)
+	}
+	return meta.CreatedAt, nil
+}
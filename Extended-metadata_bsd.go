@@ -0,0 +1,17 @@
+//go:build darwin || freebsd || netbsd
+
+package ufs
+
+import (
+	"syscall"
+	"time"
+)
+
+// birthTime returns the creation time recorded in the stat of a file
+func birthTime(path string, stat *syscall.Stat_t, follow bool) time.Time {
+	ts := stat.Birthtimespec
+	if ts.Sec == 0 && ts.Nsec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(ts.Unix())
+}
@@ -0,0 +1,28 @@
+//go:build linux
+
+package ufs
+
+import (
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// birthTime returns the creation time of a file with statx, the zero time when the kernel or the file
+// system doesn't provide it. Without follow, a symbolic link gives its own creation time, like the stat
+// of the link.
+func birthTime(path string, stat *syscall.Stat_t, follow bool) time.Time {
+	flags := unix.AT_STATX_SYNC_AS_STAT
+	if !follow {
+		flags |= unix.AT_SYMLINK_NOFOLLOW
+	}
+	var statx unix.Statx_t
+	if err := unix.Statx(unix.AT_FDCWD, path, flags, unix.STATX_BTIME, &statx); err != nil {
+		return time.Time{}
+	}
+	if statx.Mask&unix.STATX_BTIME == 0 {
+		return time.Time{}
+	}
+	return time.Unix(statx.Btime.Sec, int64(statx.Btime.Nsec))
+}
@@ -0,0 +1,13 @@
+//go:build dragonfly || solaris || aix
+
+package ufs
+
+import (
+	"syscall"
+	"time"
+)
+
+// birthTime returns the zero time, these systems don't record creation times in the stat of a file
+func birthTime(path string, stat *syscall.Stat_t, follow bool) time.Time {
+	return time.Time{}
+}
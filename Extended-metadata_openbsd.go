@@ -0,0 +1,17 @@
+//go:build openbsd
+
+package ufs
+
+import (
+	"syscall"
+	"time"
+)
+
+// birthTime returns the creation time recorded in the stat of a file, zero on file systems not recording it
+func birthTime(path string, stat *syscall.Stat_t, follow bool) time.Time {
+	ts := stat.X__st_birthtim
+	if ts.Sec == 0 && ts.Nsec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(ts.Unix())
+}
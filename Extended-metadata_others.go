@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !freebsd && !dragonfly && !netbsd && !openbsd && !solaris && !aix && !windows
+
+package ufs
+
+import "os"
+
+// platformMetadata leaves the extended fields empty, they are not available on this platform
+func platformMetadata(path string, info os.FileInfo, meta *FileMetadata, follow bool) error {
+	return nil
+}
+
+// statOwner returns false, owners are not available on this platform
+func statOwner(info os.FileInfo) (FileOwner, bool) {
+	return FileOwner{}, false
+}
@@ -0,0 +1,45 @@
+//go:build linux || darwin || freebsd || dragonfly || netbsd || openbsd || solaris || aix
+
+package ufs
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// platformMetadata fills the owner, inode, links and creation time of meta from the stat of the file
+func platformMetadata(path string, info os.FileInfo, meta *FileMetadata, follow bool) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	meta.Inode = uint64(stat.Ino)
+	meta.Device = uint64(stat.Dev)
+	meta.Links = uint64(stat.Nlink)
+	meta.Owner, _ = statOwner(info)
+	// Accounts missing from the system leave the names empty
+	if account, err := user.LookupId(meta.Owner.UID); err == nil {
+		meta.Owner.User = account.Username
+	}
+	if group, err := user.LookupGroupId(meta.Owner.GID); err == nil {
+		meta.Owner.Group = group.Name
+	}
+
+	meta.CreatedAt = birthTime(path, stat, follow)
+	return nil
+}
+
+// statOwner returns the numeric user and group IDs of a file, false when info doesn't come from a stat
+func statOwner(info os.FileInfo) (FileOwner, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return FileOwner{}, false
+	}
+	return FileOwner{
+		UID: strconv.FormatUint(uint64(stat.Uid), 10),
+		GID: strconv.FormatUint(uint64(stat.Gid), 10),
+	}, true
+}
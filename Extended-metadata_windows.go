@@ -0,0 +1,72 @@
+package ufs
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// platformMetadata fills the owner, file index, links and creation time of meta, of the link itself
+// without follow
+func platformMetadata(path string, info os.FileInfo, meta *FileMetadata, follow bool) error {
+	pathp, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+
+	// FILE_FLAG_BACKUP_SEMANTICS is needed to open directories
+	flags := uint32(windows.FILE_FLAG_BACKUP_SEMANTICS)
+	if !follow {
+		flags |= windows.FILE_FLAG_OPEN_REPARSE_POINT
+	}
+	share := uint32(windows.FILE_SHARE_READ | windows.FILE_SHARE_WRITE | windows.FILE_SHARE_DELETE)
+	// Reading the owner needs READ_CONTROL, which may be refused: the owner is then left empty
+	readOwner := true
+	handle, err := windows.CreateFile(pathp, windows.READ_CONTROL, share, nil, windows.OPEN_EXISTING, flags, 0)
+	if err != nil {
+		readOwner = false
+		handle, err = windows.CreateFile(pathp, 0, share, nil, windows.OPEN_EXISTING, flags, 0)
+	}
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(handle)
+
+	var data windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(handle, &data); err != nil {
+		return err
+	}
+	meta.Inode = uint64(data.FileIndexHigh)<<32 | uint64(data.FileIndexLow)
+	meta.Device = uint64(data.VolumeSerialNumber)
+	meta.Links = uint64(data.NumberOfLinks)
+	meta.CreatedAt = time.Unix(0, data.CreationTime.Nanoseconds())
+
+	if !readOwner {
+		return nil
+	}
+	descriptor, err := windows.GetSecurityInfo(handle, windows.SE_FILE_OBJECT,
+		windows.OWNER_SECURITY_INFORMATION|windows.GROUP_SECURITY_INFORMATION)
+	if err != nil {
+		return nil
+	}
+	if owner, _, err := descriptor.Owner(); err == nil && owner != nil {
+		meta.Owner.UID = owner.String()
+		if account, domain, _, err := owner.LookupAccount(""); err == nil {
+			meta.Owner.User = domain + `\` + account
+		}
+	}
+	if group, _, err := descriptor.Group(); err == nil && group != nil {
+		meta.Owner.GID = group.String()
+		if account, domain, _, err := group.LookupAccount(""); err == nil {
+			meta.Owner.Group = domain + `\` + account
+		}
+	}
+	return nil
+}
+
+// statOwner returns false, the owners of Windows files are in their security descriptor and not in
+// os.FileInfo, see platformMetadata
+func statOwner(info os.FileInfo) (FileOwner, bool) {
+	return FileOwner{}, false
+}
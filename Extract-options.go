@@ -0,0 +1,522 @@
+package ufs
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+/*
+Extract-options.go contains the settings used to control how archives are extracted.
+
+ExtractArchive writes every entry of an archive as is. ExtractOptions lets callers change that
+while extracting, instead of post-processing the extracted tree:
+- StripComponents: remove leading path components, like tar --strip-components.
+- Transform: rename or skip entries (strip a top-level folder, remap paths, drop unwanted files).
+- Overwrite: what to do with files that already exist in the destination.
+- Limits: refuse archives that would extract too much (decompression bombs), see ExtractLimits.
+
+Functions:
+- ExtractArchiveWithOptions: ExtractArchive with extraction settings, returning a report.
+- ExtractWithSystemCommandOptions: ExtractWithSystemCommand with StripComponents support.
+*/
+
+// EntryTransform is called for every archive entry before it is extracted.
+// name is the path stored in the archive (forward slashes) and info describes the entry.
+// It returns the path to extract the entry to (relative to the destination, either separator),
+// or skip = true to leave the entry out. Returning an empty name also skips the entry.
+type EntryTransform func(name string, info os.FileInfo) (newName string, skip bool)
+
+// OverwritePolicy decides what happens when a file extracted, routed, moved or copied already exists
+// in the destination (see ExtractOptions, RouteOptions and Options.Overwrite).
+// Existing directories are always merged with the extracted ones.
+type OverwritePolicy int
+
+const (
+	// OverwriteExisting replaces existing files (default, like ExtractArchive)
+	OverwriteExisting OverwritePolicy = iota
+	// SkipExisting keeps existing files and doesn't extract the entry
+	SkipExisting
+	// RenameWithSuffix extracts the entry next to the existing file as "name (1).ext", "name (2).ext", ...
+	RenameWithSuffix
+	// FailOnExisting fails before anything is written if any file would be overwritten
+	FailOnExisting
+)
+
+// ExtractOptions controls how archives are extracted.
+// The zero value extracts every entry as is, like ExtractArchive.
+type ExtractOptions struct {
+	// StripComponents removes this many leading directories from every entry name,
+	// like tar --strip-components. Entries with no component left (the stripped directories) are skipped.
+	StripComponents int
+
+	// Transform renames or skips entries, nil keeps every entry with its name.
+	// It receives the name left after StripComponents.
+	Transform EntryTransform
+
+	// Overwrite decides what happens to files that already exist in the destination
+	Overwrite OverwritePolicy
+
+	// Limits overrides Options.ExtractLimits for this extraction, nil uses Options.ExtractLimits
+	Limits *ExtractLimits
+
+	// match selects the files extracted by ExtractFiles and ExtractMatching by their archive name, nil
+	// extracts every entry
+	match func(name string) bool
+}
+
+// ratioCheckMinSize is the size from which entries are checked against MaxCompressionRatio.
+// Small files (e.g. text full of spaces) often compress far better than real data.
+const ratioCheckMinSize = 1 << 20
+
+// ExtractLimits bounds what an extraction may write. Zero fields don't limit anything.
+// An archive exceeding a limit is refused as a whole with an *ExtractionLimitError (matching
+// ErrExtractionLimitExceeded). ZIP archives are checked before anything is written, using the sizes
+// declared by the archive, which archive/zip enforces while reading, so an entry can't extract more
+// than it declares. TAR archives are streams checked while they are extracted, the entries already
+// written being removed when one exceeds a limit; the compression ratio of a compressed TAR is the
+// one of the whole stream read so far.
+type ExtractLimits struct {
+	// MaxTotalBytes is the maximum uncompressed size of all the extracted files
+	MaxTotalBytes int64
+
+	// MaxFiles is the maximum number of extracted entries (files and directories)
+	MaxFiles int
+
+	// MaxCompressionRatio is the maximum uncompressed/compressed size ratio of a single entry.
+	// Entries smaller than 1 MiB are not checked.
+	MaxCompressionRatio float64
+
+	// MaxPathDepth is the maximum number of path components of an extracted entry ("a/b/c.txt" is 3)
+	MaxPathDepth int
+}
+
+// ExtractReport describes what an extraction did.
+type ExtractReport struct {
+	Extracted []string // Paths written, relative to the destination (after Transform)
+	Skipped   []string // Archive names of the entries left out
+
+	Overwritten     []string          // Existing files replaced, relative to the destination
+	SkippedExisting []string          // Existing files kept by SkipExisting, relative to the destination
+	Renamed         map[string]string // Entries written under a new name by RenameWithSuffix, name -> new name
+}
+
+// ExtractArchiveWithOptions extracts the contents of a ZIP or TAR archive like ExtractArchive,
+// using the given extraction settings. The format is detected from the file extension
+// (.zip, .tar, .tar.gz, .tgz, .tar.bz2, .tbz2), every setting applying to both formats.
+// Renamed entries are still checked to stay inside the destination directory.
+//
+// Parameters:
+//   - sourcePath: The absolute or relative path to the ZIP or TAR archive
+//   - destPath: The absolute or relative path where the contents will be extracted
+//   - opts: The extraction settings, nil extracts every entry as is
+//
+// Returns:
+//   - *ExtractReport: The extracted and skipped entries, also filled up to the failing entry on error
+//   - error: An error if the extraction failed, nil otherwise.
+//     With FailOnExisting the error wraps os.ErrExist when a file already exists,
+//     an archive exceeding the limits fails with an *ExtractionLimitError, an encrypted or damaged
+//     archive with an *ArchiveError
+//
+// Example:
+//
+//	// Drop the "project-1.2.0/" folder GitHub adds and skip the tests
+//	report, err := ufs.ExtractArchiveWithOptions("/downloads/project.zip", "/src/project", &ufs.ExtractOptions{
+//	    StripComponents: 1,
+//	    Transform: func(name string, info os.FileInfo) (string, bool) {
+//	        return name, strings.HasPrefix(name, "tests/")
+//	    },
+//	})
+//	if err != nil {
+//	    fmt.Printf("Error extracting archive: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("%d entries extracted, %d skipped\n", len(report.Extracted), len(report.Skipped))
+func (ufs *UFS) ExtractArchiveWithOptions(sourcePath, destPath string, opts *ExtractOptions) (_ *ExtractReport, err error) {
+	defer ufs.recoverPanic("ExtractArchiveWithOptions", &err)
+
+	if err := ufs.requireOS("ExtractArchiveWithOptions"); err != nil {
+		return nil, err
+	}
+
+	if err := opts.validate(); err != nil {
+		return nil, ufs.wrapError(err, "ExtractArchiveWithOptions")
+	}
+
+	return ufs.extractArchiveFormat(context.Background(), sourcePath, destPath, opts, "ExtractArchiveWithOptions")
+}
+
+// validate checks the extraction settings
+func (opts *ExtractOptions) validate() error {
+	if opts == nil {
+		return nil
+	}
+	if opts.StripComponents < 0 {
+		return fmt.Errorf("invalid StripComponents %d, expected 0 or more", opts.StripComponents)
+	}
+	if opts.Overwrite < OverwriteExisting || opts.Overwrite > FailOnExisting {
+		return fmt.Errorf("invalid OverwritePolicy %d", opts.Overwrite)
+	}
+	if opts.Limits != nil {
+		return opts.Limits.validate()
+	}
+	return nil
+}
+
+// validate checks the limits
+func (limits *ExtractLimits) validate() error {
+	if limits.MaxTotalBytes < 0 || limits.MaxFiles < 0 || limits.MaxCompressionRatio < 0 || limits.MaxPathDepth < 0 {
+		return fmt.Errorf("invalid ExtractLimits, expected 0 (unlimited) or more")
+	}
+	return nil
+}
+
+// extractLimits returns the limits that apply to an extraction
+func (ufs *UFS) extractLimits(opts *ExtractOptions) ExtractLimits {
+	if opts != nil && opts.Limits != nil {
+		return *opts.Limits
+	}
+	return ufs.opts.ExtractLimits
+}
+
+// check refuses an archive exceeding the limits, looking at the entries that would be extracted
+func (limits ExtractLimits) check(reader *zip.Reader, opts *ExtractOptions) error {
+	if limits == (ExtractLimits{}) {
+		return nil
+	}
+
+	budget := &extractionBudget{limits: limits}
+	for _, file := range reader.File {
+		name, skip := opts.transform(file.Name, file.FileInfo())
+		if skip {
+			continue
+		}
+		if err := budget.admit(file.Name, name, file.UncompressedSize64); err != nil {
+			return err
+		}
+		if file.UncompressedSize64 >= ratioCheckMinSize {
+			if err := budget.checkRatio(file.Name, file.UncompressedSize64, file.CompressedSize64); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// extractionBudget counts what an extraction writes against its limits. ZIP archives are checked
+// entry by entry before anything is written (see check), TAR streams while they are extracted.
+type extractionBudget struct {
+	limits     ExtractLimits
+	files      int
+	totalBytes uint64
+
+	// For streams, the archive bytes read so far and the bytes extracted from them,
+	// the compression ratio being checked on the whole stream
+	compressed *countingReader
+	written    uint64
+}
+
+// admit counts an entry extracted as name, declaring size bytes, refusing it when it exceeds
+// MaxFiles, MaxPathDepth or MaxTotalBytes
+func (b *extractionBudget) admit(entry, name string, size uint64) error {
+	limits := b.limits
+
+	b.files++
+	if limits.MaxFiles > 0 && b.files > limits.MaxFiles {
+		return &ExtractionLimitError{Limit: "MaxFiles", Entry: entry,
+			Actual: strconv.Itoa(b.files), Max: strconv.Itoa(limits.MaxFiles)}
+	}
+
+	if limits.MaxPathDepth > 0 {
+		depth := len(strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }))
+		if depth > limits.MaxPathDepth {
+			return &ExtractionLimitError{Limit: "MaxPathDepth", Entry: entry,
+				Actual: strconv.Itoa(depth), Max: strconv.Itoa(limits.MaxPathDepth)}
+		}
+	}
+
+	b.totalBytes += size
+	if limits.MaxTotalBytes > 0 && b.totalBytes > uint64(limits.MaxTotalBytes) {
+		return &ExtractionLimitError{Limit: "MaxTotalBytes", Entry: entry,
+			Actual: strconv.FormatUint(b.totalBytes, 10), Max: strconv.FormatInt(limits.MaxTotalBytes, 10)}
+	}
+	return nil
+}
+
+// checkRatio refuses size bytes extracted from compressed bytes when their ratio exceeds MaxCompressionRatio
+func (b *extractionBudget) checkRatio(entry string, size, compressed uint64) error {
+	if b.limits.MaxCompressionRatio <= 0 {
+		return nil
+	}
+	ratio := float64(size) / float64(max(compressed, 1))
+	if ratio > b.limits.MaxCompressionRatio {
+		return &ExtractionLimitError{Limit: "MaxCompressionRatio", Entry: entry,
+			Actual: strconv.FormatFloat(ratio, 'f', 1, 64), Max: strconv.FormatFloat(b.limits.MaxCompressionRatio, 'f', 1, 64)}
+	}
+	return nil
+}
+
+// reader returns r counting the bytes extracted from the stream for entry, failing once the
+// stream exceeds MaxCompressionRatio
+func (b *extractionBudget) reader(entry string, r io.Reader) io.Reader {
+	if b.compressed == nil || b.limits.MaxCompressionRatio <= 0 {
+		return r
+	}
+	return &budgetReader{budget: b, entry: entry, reader: r}
+}
+
+// budgetReader is the reader of an entry of a stream extracted within an extractionBudget
+type budgetReader struct {
+	budget *extractionBudget
+	entry  string
+	reader io.Reader
+}
+
+func (r *budgetReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	b := r.budget
+	b.written += uint64(n)
+	if b.written >= ratioCheckMinSize {
+		if ratioErr := b.checkRatio(r.entry, b.written, uint64(b.compressed.count)); ratioErr != nil {
+			return n, ratioErr
+		}
+	}
+	return n, err
+}
+
+// countingReader counts the bytes read from reader
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// overwritePolicy returns the overwrite policy, OverwriteExisting when opts is nil
+func (opts *ExtractOptions) overwritePolicy() OverwritePolicy {
+	if opts == nil {
+		return OverwriteExisting
+	}
+	return opts.Overwrite
+}
+
+// resolveExisting applies the overwrite policy to a file entry about to be extracted to destPath/name.
+// It returns the name to extract the entry to, or skip = true when the existing file is kept,
+// and records what happened in the report.
+func (opts *ExtractOptions) resolveExisting(destPath, name string, report *ExtractReport) (string, bool, error) {
+	target := filepath.Join(destPath, name)
+	info, err := os.Lstat(target)
+	if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		// Nothing to overwrite, extracting a file over a directory fails as before
+		return name, false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	switch opts.overwritePolicy() {
+	case SkipExisting:
+		report.SkippedExisting = append(report.SkippedExisting, name)
+		return "", true, nil
+
+	case RenameWithSuffix:
+		newName, err := filepath.Rel(destPath, uniquePath(target))
+		if err != nil {
+			return "", false, err
+		}
+		if report.Renamed == nil {
+			report.Renamed = map[string]string{}
+		}
+		report.Renamed[name] = newName
+		return newName, false, nil
+
+	case FailOnExisting:
+		return "", false, fmt.Errorf("%w: %s", os.ErrExist, target)
+
+	default:
+		report.Overwritten = append(report.Overwritten, name)
+		return name, false, nil
+	}
+}
+
+// uniquePath returns path if nothing exists there, otherwise the first free
+// "name (1).ext", "name (2).ext", ... next to it. Compressed TAR archives keep their
+// whole extension: "name (1).tar.gz"
+func uniquePath(path string) string {
+	return uniquePathWith(path, func(candidate string) bool {
+		_, err := os.Lstat(candidate)
+		return !os.IsNotExist(err)
+	})
+}
+
+// uniquePathWith is uniquePath checking the existence of the paths with exists
+func uniquePathWith(path string, exists func(path string) bool) string {
+	if !exists(path) {
+		return path
+	}
+
+	dir, base := filepath.Split(path)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	if inner := filepath.Ext(stem); strings.EqualFold(inner, ".tar") && inner != stem {
+		stem, ext = strings.TrimSuffix(stem, inner), inner+ext
+	}
+	if stem == "" {
+		// Dot files like ".env" have no stem, keep the whole name
+		stem, ext = base, ""
+	}
+
+	for i := 1; ; i++ {
+		candidate := filepath.Join(dir, fmt.Sprintf("%s (%d)%s", stem, i, ext))
+		if !exists(candidate) {
+			return candidate
+		}
+	}
+}
+
+// transform applies StripComponents and the Transform hook to an entry
+func (opts *ExtractOptions) transform(name string, info os.FileInfo) (string, bool) {
+	if opts == nil {
+		return name, false
+	}
+
+	if opts.match != nil && (info.IsDir() || !opts.match(name)) {
+		return "", true
+	}
+
+	if opts.StripComponents > 0 {
+		var ok bool
+		if name, ok = stripComponents(name, opts.StripComponents); !ok {
+			return "", true
+		}
+	}
+
+	if opts.Transform == nil {
+		return name, false
+	}
+
+	newName, skip := opts.Transform(name, info)
+	if skip || newName == "" {
+		return "", true
+	}
+	return newName, false
+}
+
+// stripComponents removes the first n directories of an entry name.
+// It returns false when nothing is left.
+func stripComponents(name string, n int) (string, bool) {
+	parts := strings.Split(strings.TrimPrefix(name, "./"), "/")
+
+	// Directory entries end with a slash, which leaves an empty last part
+	if len(parts) > 0 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) <= n {
+		return "", false
+	}
+	return strings.Join(parts[n:], "/"), true
+}
+
+// ExtractWithSystemCommandOptions extracts an archive with the system's tar tool like ExtractWithSystemCommand,
+// passing StripComponents as --strip-components. Transform, Limits and overwrite policies other than
+// OverwriteExisting are not supported by tar and are rejected.
+//
+// Parameters:
+//   - sourcePath: The absolute or relative path to the archive to extract
+//   - destPath: The absolute or relative path where the contents will be extracted
+//   - opts: The extraction settings, nil behaves like ExtractWithSystemCommand
+//
+// Returns:
+//   - error: An error if the extraction failed or opts uses Transform, Overwrite or Limits, nil otherwise
+//
+// Example:
+//
+//	// Unpack a release without its top-level "tool-v2.1.0/" folder
+//	err := ufs.ExtractWithSystemCommandOptions("/downloads/tool-v2.1.0.tar.gz", "/opt/tool", &ufs.ExtractOptions{
+//	    StripComponents: 1,
+//	})
+//	if err != nil {
+//	    fmt.Printf("Error extracting archive: %v\n", err)
+//	}
+func (ufs *UFS) ExtractWithSystemCommandOptions(sourcePath, destPath string, opts *ExtractOptions) (err error) {
+	defer ufs.recoverPanic("ExtractWithSystemCommandOptions", &err)
+
+	if err := ufs.requireOS("ExtractWithSystemCommandOptions"); err != nil {
+		return err
+	}
+
+	if err := ufs.requireCapability(CapabilityProcesses, "ExtractWithSystemCommandOptions", sourcePath); err != nil {
+		return err
+	}
+
+	if err := opts.validate(); err != nil {
+		return ufs.wrapError(err, "ExtractWithSystemCommandOptions")
+	}
+	if opts != nil && opts.Transform != nil {
+		return fmt.Errorf("ExtractWithSystemCommandOptions: Transform is not supported by the system tar")
+	}
+	if opts.overwritePolicy() != OverwriteExisting {
+		return fmt.Errorf("ExtractWithSystemCommandOptions: Overwrite is not supported by the system tar")
+	}
+	if opts != nil && opts.Limits != nil {
+		return fmt.Errorf("ExtractWithSystemCommandOptions: Limits are not supported by the system tar")
+	}
+
+	// Verify source is a file
+	if !ufs.IsFile(sourcePath) {
+		return ufs.misuseError("ExtractWithSystemCommandOptions", "source path is not a file", sourcePath)
+	}
+
+	// Get absolute paths to ensure consistent behavior
+	sourcePath, err = filepath.Abs(sourcePath)
+	if err != nil {
+		return ufs.wrapError(err, "ExtractWithSystemCommandOptions")
+	}
+
+	destPath, err = filepath.Abs(destPath)
+	if err != nil {
+		return ufs.wrapError(err, "ExtractWithSystemCommandOptions")
+	}
+
+	// Ensure destination directory exists
+	if !ufs.IsDirectory(destPath) {
+		err = os.MkdirAll(destPath, 0755)
+		if err != nil {
+			return ufs.wrapError(err, "ExtractWithSystemCommandOptions")
+		}
+	}
+
+	tarCommand := "tar"
+	if runtime.GOOS == "windows" {
+		tarCommand = "tar.exe"
+	}
+	if _, err := exec.LookPath(tarCommand); err != nil {
+		return fmt.Errorf("%s not found, extraction not supported on this system", tarCommand)
+	}
+
+	args := []string{"-xf", sourcePath, "-C", destPath}
+	if opts != nil && opts.StripComponents > 0 {
+		// Both GNU tar and bsdtar (tar.exe on Windows) support this flag
+		args = append(args, "--strip-components="+strconv.Itoa(opts.StripComponents))
+	}
+
+	output, err := exec.Command(tarCommand, args...).CombinedOutput()
+	if err != nil {
+		err = fmt.Errorf("extraction failed: %v, output: %s", err, output)
+		return classifyToolFailure(sourcePath, output, "", err)
+	}
+
+	return nil
+}
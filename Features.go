@@ -0,0 +1,115 @@
+package ufs
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+/*
+Features.go describes the build of the library a program embeds, for tools adapting to what it supports
+and for diagnostics ("ufs v1.4.0 on linux/arm64, reflink: yes"):
+
+	features := ufs.Features()
+	if !features.Reflink {
+	    fmt.Println("Copies are full copies on this platform")
+	}
+	log.Printf("ufs %s: %+v", ufs.Version(), features)
+
+What is available depends on the platform the program was compiled for, and for a few fields on the
+settings of the instance (Backend, ScopedStorage).
+
+Functions:
+- Features: Returns the capabilities of the build and of the instance
+- Version: Returns the version of the library
+*/
+
+// modulePath is the import path of the library, used to find its version in the build information
+const modulePath = "github.com/utsav-56/ufs"
+
+// FeatureSet lists what the library can do in the running program.
+type FeatureSet struct {
+	Version   string // Version of the library, see Version
+	GoVersion string // Go version the program was compiled with
+	Platform  string // GOOS/GOARCH of the program
+
+	ArchiveFormats  []string // Formats the extraction and inspection functions read
+	CompressFormats []string // Formats the compression functions write, without external tools
+	Zstd            bool     // Zstandard compressed archives are supported
+
+	Reflink     bool // CopyFile clones files on file systems supporting it, see File-clone.go
+	DirectIO    bool // Options.DirectIO bypasses the page cache
+	IOPriority  bool // Options.IOPriority lowers the disk priority of heavy operations
+	MacMetadata bool // Options.PreserveMacMetadata keeps resource forks and Finder info
+	Xattr       bool // Extended attributes are copied; only the macOS ones of MacMetadata for now
+
+	Backends      []string // Backends built in, see Backend.go; other storages implement Backend
+	CloudBackends []string // Cloud storages built in, none: they are provided by implementing Backend
+	Watcher       string   // How changes are watched: "polling" (WatchFreeSpace), no file system events
+
+	Backend       string // Backend used by the instance, "os", "memory" or the type of a custom one
+	ScopedStorage bool   // The instance is restricted to the operations of mobile sandboxes
+}
+
+// Features returns the capabilities of the library in this build, and the backend settings of the instance.
+//
+// Returns:
+//   - FeatureSet: What the library supports
+//
+// Example:
+//
+//	features := ufs.Features()
+//	fmt.Printf("ufs %s on %s, cloning: %v, direct I/O: %v\n",
+//	    features.Version, features.Platform, features.Reflink, features.DirectIO)
+func (ufs *UFS) Features() FeatureSet {
+	return FeatureSet{
+		Version:   Version(),
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+
+		ArchiveFormats:  []string{"zip", "tar", "tar.gz", "tar.bz2"},
+		CompressFormats: []string{"zip"},
+		Zstd:            false,
+
+		Reflink:     cloneSupported,
+		DirectIO:    directIOSupported,
+		IOPriority:  ioPrioritySupported,
+		MacMetadata: macMetadataSupported,
+		Xattr:       macMetadataSupported,
+
+		Backends:      []string{"os", "memory"},
+		CloudBackends: []string{},
+		Watcher:       "polling",
+
+		Backend:       ufs.backendName(),
+		ScopedStorage: ufs.IsScopedStorage(),
+	}
+}
+
+// Version returns the semantic version of the library the program was built with, e.g. "v1.4.0",
+// read from the build information of the program. It is "(devel)" when the library is built from
+// its own repository, and "unknown" when the program has no build information.
+//
+// Returns:
+//   - string: The version of the library
+//
+// Example:
+//
+//	fmt.Println("ufs version", ufs.Version())
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Path == modulePath && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil && dep.Replace.Version != "" {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "(devel)"
+}
@@ -0,0 +1,112 @@
+package ufs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+/*
+File-clone.go contains functions to copy files by cloning them (reflink, copy-on-write).
+
+On file systems supporting it, a clone shares the data blocks of the source until one of the files
+is modified, so copying a file of any size takes about the same time as creating an empty file,
+and uses no extra disk space. Cloning is supported by:
+- Linux: Btrfs, XFS (created with reflink=1), bcachefs and OCFS2, with the FICLONE ioctl
+- macOS: APFS, with clonefile
+- Windows: ReFS and Dev Drives, with block cloning (FSCTL_DUPLICATE_EXTENTS_TO_FILE)
+
+CopyFile and CopyFileWithPermissions try to clone first and fall back to copying the content,
+unless Options.DisableReflink is set (e.g. to really duplicate the data on disk for a backup).
+CopyFileCloned only clones, and fails with ErrCloneNotSupported instead of copying.
+
+Functions:
+- CopyFileCloned: Clones a file, failing when the file system can't clone.
+*/
+
+// CopyFileCloned copies a file by cloning it: the copy shares the data blocks of the source until one
+// of them is modified, which is near-instant whatever the size of the file. It never falls back to
+// copying the content. Options.DisableReflink doesn't apply to this function.
+// If the destination file already exists, it will be replaced, or left unchanged if the clone fails.
+// This function will create any parent directories for the destination if they don't exist.
+//
+// Parameters:
+//   - src: The absolute or relative path to the source file
+//   - dst: The absolute or relative path to the destination file, on the same file system
+//
+// Returns:
+//   - error: An error matching ErrCloneNotSupported if the file system can't clone files or src and dst
+//     are on different file systems, or an error if the file couldn't be cloned, nil otherwise
+//
+// Example:
+//
+//	err := ufs.CopyFileCloned("vm/base.qcow2", "vm/test.qcow2")
+//	if errors.Is(err, ufs.ErrCloneNotSupported) {
+//	    fmt.Println("The disk doesn't support clones, making a full copy")
+//	    err = ufs.CopyFile("vm/base.qcow2", "vm/test.qcow2")
+//	}
+func (ufs *UFS) CopyFileCloned(src, dst string) (err error) {
+	defer ufs.recoverPanic("CopyFileCloned", &err)
+
+	if err := ufs.requireOS("CopyFileCloned"); err != nil {
+		return err
+	}
+
+	// Verify source is a file
+	if !ufs.IsFile(src) {
+		return ufs.misuseError("CopyFileCloned", "source is not a file", src)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return ufs.wrapError(err, "CopyFileCloned")
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return ufs.wrapError(err, "CopyFileCloned")
+	}
+	defer srcFile.Close()
+
+	// Clone next to dst then rename, so dst is left as is when the file system can't clone
+	temp := filepath.Join(filepath.Dir(dst), fmt.Sprintf(".%s.clone-%d", filepath.Base(dst), os.Getpid()))
+	os.Remove(temp)
+	tempFile, err := os.OpenFile(temp, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return ufs.wrapError(err, "CopyFileCloned")
+	}
+	err = cloneFile(srcFile, tempFile)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp, dst)
+	}
+	if err != nil {
+		os.Remove(temp)
+		return ufs.wrapError(err, "CopyFileCloned")
+	}
+	return nil
+}
+
+// cloneOrCopy fills dstFile, just created and empty, with the content of src: by cloning it
+// unless Options.DisableReflink is set, else (or if the file system can't clone) by copying it
+func (ufs *UFS) cloneOrCopy(src io.Reader, dstFile *os.File) error {
+	if !ufs.opts.DisableReflink {
+		if srcFile := openedFile(src); srcFile != nil && cloneFile(srcFile, dstFile) == nil {
+			return nil
+		}
+	}
+	_, err := io.Copy(dstFile, src)
+	return err
+}
+
+// openedFile returns the file read by a reader from os.Open or openSequential, nil for other readers
+func openedFile(reader io.Reader) *os.File {
+	switch r := reader.(type) {
+	case *os.File:
+		return r
+	case *sequentialReader:
+		return r.file
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+//go:build darwin
+
+package ufs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneSupported reports whether cloneFile can clone files on this platform
+const cloneSupported = true
+
+// cloneFile makes dst a clone of src with clonefile. clonefile only creates new files, so the clone
+// is made next to dst then renamed over it; the dst handle keeps pointing to the replaced empty file,
+// which the caller only closes. dst is left unchanged on failure.
+func cloneFile(src, dst *os.File) error {
+	info, err := dst.Stat()
+	if err != nil {
+		return err
+	}
+
+	temp := filepath.Join(filepath.Dir(dst.Name()), fmt.Sprintf(".%s.clone-%d", filepath.Base(dst.Name()), os.Getpid()))
+	os.Remove(temp)
+	if err := unix.Clonefile(src.Name(), temp, unix.CLONE_NOFOLLOW); err != nil {
+		if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EXDEV) {
+			return fmt.Errorf("%w: %w", ErrCloneNotSupported, err)
+		}
+		return err
+	}
+
+	// clonefile copies the mode of the source, keep the one dst was created with
+	if err := os.Chmod(temp, info.Mode().Perm()); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	if err := os.Rename(temp, dst.Name()); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build linux
+
+package ufs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneSupported reports whether cloneFile can clone files on this platform
+const cloneSupported = true
+
+// cloneFile makes dst a clone of src with the FICLONE ioctl. dst is left unchanged on failure.
+func cloneFile(src, dst *os.File) error {
+	err := unix.IoctlFileClone(int(dst.Fd()), int(src.Fd()))
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, unix.EOPNOTSUPP), errors.Is(err, unix.ENOTTY), errors.Is(err, unix.EXDEV),
+		errors.Is(err, unix.EINVAL), errors.Is(err, unix.ENOSYS):
+		return fmt.Errorf("%w: %w", ErrCloneNotSupported, err)
+	}
+	return err
+}
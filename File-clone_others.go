@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package ufs
+
+import "os"
+
+// cloneSupported reports whether cloneFile can clone files on this platform
+const cloneSupported = false
+
+// cloneFile can't clone files on this platform
+func cloneFile(src, dst *os.File) error {
+	return ErrCloneNotSupported
+}
@@ -0,0 +1,109 @@
+//go:build windows
+
+package ufs
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// cloneSupported reports whether cloneFile can clone files on this platform
+const cloneSupported = true
+
+// fileSupportsBlockRefcounting is the volume flag of file systems supporting block cloning (ReFS)
+const fileSupportsBlockRefcounting = 0x08000000
+
+// cloneChunkSize is the largest range cloned by one FSCTL_DUPLICATE_EXTENTS_TO_FILE call (under 4 GiB)
+const cloneChunkSize = 1 << 30
+
+// duplicateExtentsData is DUPLICATE_EXTENTS_DATA. The handle is stored in 64 bits, so the layout
+// matches the C struct (handle padded to 8 bytes) on 32-bit Windows too.
+type duplicateExtentsData struct {
+	FileHandle       uint64
+	SourceFileOffset int64
+	TargetFileOffset int64
+	ByteCount        int64
+}
+
+// integrityInformation is FSCTL_GET_INTEGRITY_INFORMATION_BUFFER
+type integrityInformation struct {
+	ChecksumAlgorithm        uint16
+	Reserved                 uint16
+	Flags                    uint32
+	ChecksumChunkSizeInBytes uint32
+	ClusterSizeInBytes       uint32
+}
+
+// cloneFile makes dst a clone of src with block cloning, range by range. dst is emptied on failure.
+func cloneFile(src, dst *os.File) error {
+	var flags uint32
+	if err := windows.GetVolumeInformationByHandle(windows.Handle(dst.Fd()), nil, 0, nil, nil, &flags, nil, 0); err != nil {
+		return err
+	}
+	if flags&fileSupportsBlockRefcounting == 0 {
+		return fmt.Errorf("%w: the volume doesn't support block cloning", ErrCloneNotSupported)
+	}
+
+	info, err := src.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+
+	// Ranges must be aligned on clusters
+	var integrity integrityInformation
+	var returned uint32
+	err = windows.DeviceIoControl(windows.Handle(src.Fd()), windows.FSCTL_GET_INTEGRITY_INFORMATION, nil, 0,
+		(*byte)(unsafe.Pointer(&integrity)), uint32(unsafe.Sizeof(integrity)), &returned, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCloneNotSupported, err)
+	}
+	cluster := int64(integrity.ClusterSizeInBytes)
+	if cluster <= 0 {
+		return fmt.Errorf("%w: unknown cluster size", ErrCloneNotSupported)
+	}
+
+	if err := duplicateExtents(src, dst, info.Size(), cluster); err != nil {
+		dst.Truncate(0)
+		return err
+	}
+	return nil
+}
+
+// duplicateExtents sizes dst like src then clones src into it, cluster-aligned range by range
+func duplicateExtents(src, dst *os.File, size, cluster int64) error {
+	srcHandle, dstHandle := windows.Handle(src.Fd()), windows.Handle(dst.Fd())
+
+	var byHandle windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(srcHandle, &byHandle); err != nil {
+		return err
+	}
+	var returned uint32
+	if byHandle.FileAttributes&windows.FILE_ATTRIBUTE_SPARSE_FILE != 0 {
+		if err := windows.DeviceIoControl(dstHandle, windows.FSCTL_SET_SPARSE, nil, 0, nil, 0, &returned, nil); err != nil {
+			return err
+		}
+	}
+	if err := dst.Truncate(size); err != nil {
+		return err
+	}
+
+	chunk := cloneChunkSize / cluster * cluster
+	for offset := int64(0); offset < size; offset += chunk {
+		count := min(chunk, (size-offset+cluster-1)/cluster*cluster)
+		data := duplicateExtentsData{
+			FileHandle:       uint64(srcHandle),
+			SourceFileOffset: offset,
+			TargetFileOffset: offset,
+			ByteCount:        count,
+		}
+		err := windows.DeviceIoControl(dstHandle, windows.FSCTL_DUPLICATE_EXTENTS_TO_FILE,
+			(*byte)(unsafe.Pointer(&data)), uint32(unsafe.Sizeof(data)), nil, 0, &returned, nil)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrCloneNotSupported, err)
+		}
+	}
+	return nil
+}
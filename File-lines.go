@@ -0,0 +1,502 @@
+package ufs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+/*
+File-lines.go contains functions to read part of the lines of a file, like head and tail,
+without reading the entire file, and to edit single lines of a file.
+
+ReadFirstNLines and ReadLinesRange stop reading once the last wanted line is reached.
+ReadLastNLines reads the file backwards from its end, block by block, so the tail of a
+multi-GB log costs the same as the tail of a small one.
+
+Line endings (\n or \r\n) are removed from the returned lines, as with ReadFileWithLines.
+
+The editing functions stream the file into a temporary file next to it, which then replaces it:
+large files are edited with constant memory, and the file is never left half written.
+The line ending of the file (\n or \r\n) and its permissions are kept.
+
+Functions:
+- ReadFirstNLines: Reads the first n lines of a file.
+- ReadLastNLines: Reads the last n lines of a file, seeking backwards from its end.
+- ReadLinesRange: Reads the lines of a file between two line numbers.
+- InsertLineAt: Inserts a line at a line number.
+- ReplaceLine: Replaces the line at a line number.
+- DeleteLine: Deletes the line at a line number.
+- DeleteLinesMatching: Deletes the lines matching a regular expression.
+*/
+
+// ReadFirstNLines reads the first n lines of a file, like the head command.
+// Only the returned lines are read.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file to read
+//   - n: The number of lines to read
+//
+// Returns:
+//   - []string: The first n lines, fewer if the file is shorter
+//   - error: An error if the file couldn't be read or n is negative, nil otherwise
+//
+// Example:
+//
+//	header, err := ufs.ReadFirstNLines("/data/export.csv", 1)
+//	if err != nil {
+//	    fmt.Printf("Error reading file: %v\n", err)
+//	    return
+//	}
+//	fmt.Println("Columns:", header)
+func (ufs *UFS) ReadFirstNLines(path string, n int) (_ []string, err error) {
+	defer ufs.recoverPanic("ReadFirstNLines", &err)
+
+	if n < 0 {
+		return nil, fmt.Errorf("ReadFirstNLines: invalid number of lines %d", n)
+	}
+	if n == 0 {
+		return []string{}, nil
+	}
+
+	return ufs.readLinesRange(path, 1, n, "ReadFirstNLines")
+}
+
+// ReadLastNLines reads the last n lines of a file, like the tail command.
+// The file is read backwards from its end, so only the returned lines are read whatever the size of the file.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file to read
+//   - n: The number of lines to read
+//
+// Returns:
+//   - []string: The last n lines in file order, fewer if the file is shorter
+//   - error: An error if the file couldn't be read or n is negative, nil otherwise
+//
+// Example:
+//
+//	lines, err := ufs.ReadLastNLines("/var/log/app.log", 50)
+//	if err != nil {
+//	    fmt.Printf("Error reading log: %v\n", err)
+//	    return
+//	}
+//	for _, line := range lines {
+//	    fmt.Println(line)
+//	}
+func (ufs *UFS) ReadLastNLines(path string, n int) (_ []string, err error) {
+	defer ufs.recoverPanic("ReadLastNLines", &err)
+
+	if err := ufs.requireOS("ReadLastNLines"); err != nil {
+		return nil, err
+	}
+
+	if n < 0 {
+		return nil, fmt.Errorf("ReadLastNLines: invalid number of lines %d", n)
+	}
+	if !ufs.IsFile(path) {
+		return nil, ufs.misuseError("ReadLastNLines", "path is not a file", path)
+	}
+	if n == 0 {
+		return []string{}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, ufs.wrapError(err, "ReadLastNLines")
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, ufs.wrapError(err, "ReadLastNLines")
+	}
+
+	// Read blocks from the end until they hold n line breaks before the last line,
+	// the line break ending the file doesn't start a new line
+	var blocks [][]byte
+	newlines, total := 0, 0
+	trailing := true
+	for offset := info.Size(); offset > 0 && newlines <= n; {
+		size := int64(streamBufferSize)
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+
+		block := make([]byte, size)
+		if _, err := file.ReadAt(block, offset); err != nil && err != io.EOF {
+			return nil, ufs.wrapError(err, "ReadLastNLines")
+		}
+		blocks = append(blocks, block)
+		total += len(block)
+
+		newlines += bytes.Count(block, []byte{'\n'})
+		if trailing && block[len(block)-1] == '\n' {
+			newlines--
+		}
+		trailing = false
+	}
+
+	// Blocks were read from the end
+	data := make([]byte, 0, total)
+	for i := len(blocks) - 1; i >= 0; i-- {
+		data = append(data, blocks[i]...)
+	}
+
+	lines := splitFileLines(string(data))
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
+
+// ReadLinesRange reads the lines of a file from line number from to line number to, both included.
+// Line numbers start at 1. Reading stops at line to.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file to read
+//   - from: The number of the first line to read, starting at 1
+//   - to: The number of the last line to read, greater than or equal to from
+//
+// Returns:
+//   - []string: The lines of the range, fewer if the file is shorter
+//   - error: An error if the file couldn't be read or the range is invalid, nil otherwise
+//
+// Example:
+//
+//	// Show the lines around a compilation error at line 120
+//	lines, err := ufs.ReadLinesRange("main.go", 115, 125)
+//	if err != nil {
+//	    fmt.Printf("Error reading file: %v\n", err)
+//	    return
+//	}
+//	for i, line := range lines {
+//	    fmt.Printf("%4d  %s\n", 115+i, line)
+//	}
+func (ufs *UFS) ReadLinesRange(path string, from, to int) (_ []string, err error) {
+	defer ufs.recoverPanic("ReadLinesRange", &err)
+
+	if from < 1 || to < from {
+		return nil, fmt.Errorf("ReadLinesRange: invalid line range %d-%d", from, to)
+	}
+
+	return ufs.readLinesRange(path, from, to, "ReadLinesRange")
+}
+
+// InsertLineAt inserts a line so that it becomes line number n, shifting the following lines down.
+// n can be the number of lines + 1 to append the line at the end of the file.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file to edit
+//   - n: The line number of the inserted line, starting at 1
+//   - content: The line to insert, without line break
+//
+// Returns:
+//   - error: An error if n is out of range or the file couldn't be edited, nil otherwise
+//
+// Example:
+//
+//	// Add an import after the package clause
+//	err := ufs.InsertLineAt("main.go", 2, `import "fmt"`)
+//	if err != nil {
+//	    fmt.Printf("Error editing file: %v\n", err)
+//	}
+func (ufs *UFS) InsertLineAt(path string, n int, content string) (err error) {
+	defer ufs.recoverPanic("InsertLineAt", &err)
+
+	if err := ufs.requireOS("InsertLineAt"); err != nil {
+		return err
+	}
+
+	if n < 1 {
+		return fmt.Errorf("InsertLineAt: invalid line number %d", n)
+	}
+
+	_, err = ufs.rewriteLines(path, "InsertLineAt", n-1, func(lineNo int, line string) []string {
+		if lineNo == n {
+			return []string{content, line}
+		}
+		return []string{line}
+	}, func(lineNo int) []string {
+		if lineNo == n {
+			return []string{content}
+		}
+		return nil
+	})
+	return err
+}
+
+// ReplaceLine replaces the line at line number n.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file to edit
+//   - n: The line number of the line to replace, starting at 1
+//   - content: The new line, without line break
+//
+// Returns:
+//   - error: An error if n is out of range or the file couldn't be edited, nil otherwise
+//
+// Example:
+//
+//	err := ufs.ReplaceLine("/etc/myapp/motd", 1, "Welcome to the staging server")
+//	if err != nil {
+//	    fmt.Printf("Error editing file: %v\n", err)
+//	}
+func (ufs *UFS) ReplaceLine(path string, n int, content string) (err error) {
+	defer ufs.recoverPanic("ReplaceLine", &err)
+
+	if err := ufs.requireOS("ReplaceLine"); err != nil {
+		return err
+	}
+
+	if n < 1 {
+		return fmt.Errorf("ReplaceLine: invalid line number %d", n)
+	}
+
+	_, err = ufs.rewriteLines(path, "ReplaceLine", n, func(lineNo int, line string) []string {
+		if lineNo == n {
+			return []string{content}
+		}
+		return []string{line}
+	}, nil)
+	return err
+}
+
+// DeleteLine deletes the line at line number n, shifting the following lines up.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file to edit
+//   - n: The line number of the line to delete, starting at 1
+//
+// Returns:
+//   - error: An error if n is out of range or the file couldn't be edited, nil otherwise
+//
+// Example:
+//
+//	// Drop the header row of a CSV export
+//	err := ufs.DeleteLine("/data/export.csv", 1)
+//	if err != nil {
+//	    fmt.Printf("Error editing file: %v\n", err)
+//	}
+func (ufs *UFS) DeleteLine(path string, n int) (err error) {
+	defer ufs.recoverPanic("DeleteLine", &err)
+
+	if err := ufs.requireOS("DeleteLine"); err != nil {
+		return err
+	}
+
+	if n < 1 {
+		return fmt.Errorf("DeleteLine: invalid line number %d", n)
+	}
+
+	_, err = ufs.rewriteLines(path, "DeleteLine", n, func(lineNo int, line string) []string {
+		if lineNo == n {
+			return nil
+		}
+		return []string{line}
+	}, nil)
+	return err
+}
+
+// DeleteLinesMatching deletes every line matching a regular expression.
+// The file is left untouched when no line matches.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file to edit
+//   - pattern: The regular expression (RE2 syntax) matched against every line, without its line break
+//
+// Returns:
+//   - int: The number of lines deleted
+//   - error: An error if the pattern is invalid or the file couldn't be edited, nil otherwise
+//
+// Example:
+//
+//	// Remove the debug lines from a log
+//	deleted, err := ufs.DeleteLinesMatching("/var/log/app.log", `^\S+ DEBUG `)
+//	if err != nil {
+//	    fmt.Printf("Error editing file: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("%d lines deleted\n", deleted)
+func (ufs *UFS) DeleteLinesMatching(path string, pattern string) (_ int, err error) {
+	defer ufs.recoverPanic("DeleteLinesMatching", &err)
+
+	if err := ufs.requireOS("DeleteLinesMatching"); err != nil {
+		return 0, err
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return 0, ufs.wrapError(err, "DeleteLinesMatching")
+	}
+
+	deleted := 0
+	_, err = ufs.rewriteLines(path, "DeleteLinesMatching", 0, func(lineNo int, line string) []string {
+		if re.MatchString(line) {
+			deleted++
+			return nil
+		}
+		return []string{line}
+	}, nil)
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// rewriteLines streams the lines of a file through edit into a temporary file, which replaces the file
+// unless it has fewer than minLines lines. edit returns the lines written for every line, and end
+// (if not nil) the lines written after the last one, given the number of lines + 1.
+// The file is not replaced when nothing changed. It returns the number of lines of the original file.
+func (ufs *UFS) rewriteLines(path, op string, minLines int, edit func(lineNo int, line string) []string, end func(lineNo int) []string) (_ int, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, ufs.wrapError(err, op)
+	}
+	if !info.Mode().IsRegular() {
+		return 0, ufs.misuseError(op, "path is not a file", path)
+	}
+
+	file, err := ufs.openSequential(path)
+	if err != nil {
+		return 0, ufs.wrapError(err, op)
+	}
+	defer file.Close()
+
+	temp, err := os.CreateTemp(filepath.Dir(path), ".ufs-edit-*")
+	if err != nil {
+		return 0, ufs.wrapError(err, op)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			temp.Close()
+			os.Remove(temp.Name())
+		}
+	}()
+
+	reader := bufio.NewReaderSize(file, streamBufferSize)
+	writer := bufio.NewWriterSize(temp, streamBufferSize)
+
+	// Lines are written with the line ending of the first line, the file ends with one if it did
+	lineBreak, endsWithBreak := "\n", true
+	needBreak, changed := false, false
+	write := func(lines []string) {
+		for _, line := range lines {
+			if needBreak {
+				writer.WriteString(lineBreak)
+			}
+			writer.WriteString(line)
+			needBreak = true
+		}
+	}
+
+	count := 0
+	for {
+		text, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return 0, ufs.wrapError(readErr, op)
+		}
+		if text == "" {
+			break
+		}
+
+		count++
+		line := strings.TrimSuffix(text, "\n")
+		endsWithBreak = len(line) != len(text)
+		if count == 1 && strings.HasSuffix(line, "\r") {
+			lineBreak = "\r\n"
+		}
+		line = strings.TrimSuffix(line, "\r")
+
+		edited := edit(count, line)
+		if len(edited) != 1 || edited[0] != line {
+			changed = true
+		}
+		write(edited)
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	if count < minLines {
+		return count, fmt.Errorf("%s: line number out of range, %s has %d lines", op, path, count)
+	}
+	if end != nil {
+		if lines := end(count + 1); len(lines) > 0 {
+			changed = true
+			write(lines)
+		}
+	}
+	if !changed {
+		return count, nil
+	}
+	if needBreak && endsWithBreak {
+		writer.WriteString(lineBreak)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return count, ufs.wrapError(err, op)
+	}
+	if mode := ufs.fileMode(info.Mode().Perm()); mode != 0 {
+		if err := temp.Chmod(mode); err != nil {
+			return count, ufs.wrapError(err, op)
+		}
+	}
+	if err := temp.Close(); err != nil {
+		return count, ufs.wrapError(err, op)
+	}
+	file.Close()
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return count, ufs.wrapError(err, op)
+	}
+	committed = true
+	return count, nil
+}
+
+// readLinesRange reads the lines from line number from to line number to, stopping at line to
+func (ufs *UFS) readLinesRange(path string, from, to int, op string) ([]string, error) {
+	if !ufs.IsFile(path) {
+		return nil, ufs.misuseError(op, "path is not a file", path)
+	}
+
+	file, err := ufs.openSequential(path)
+	if err != nil {
+		return nil, ufs.wrapError(err, op)
+	}
+	defer file.Close()
+
+	lines := []string{}
+	scanner := ufs.newLineScanner(file)
+	for lineNo := 1; lineNo <= to && scanner.Scan(); lineNo++ {
+		if lineNo >= from {
+			lines = append(lines, scanner.Text())
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, ufs.wrapError(err, op)
+	}
+	return lines, nil
+}
+
+// splitFileLines splits content into lines the way bufio.ScanLines does:
+// no empty line after the final line break, and \r removed before line breaks
+func splitFileLines(content string) []string {
+	content = strings.TrimSuffix(content, "\n")
+	if content == "" {
+		return []string{}
+	}
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
@@ -0,0 +1,195 @@
+package ufs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*
+File-primitives.go contains the basic operations on the size and times of files, like the truncate,
+touch and fallocate commands.
+
+PreallocateFile reserves the disk space of a file before it is written, so downloads and recordings
+fail early when the disk is full, and large files are less fragmented. Space is really allocated on
+Linux (fallocate) and Windows (file allocation size); on other systems the file is only extended,
+which may create a sparse file.
+
+Functions:
+- TruncateFile: Changes the size of a file, cutting or zero-extending it.
+- TouchFile: Creates a file, or updates its access and modification times to now.
+- SetFileTimes: Sets the access and modification times of a file.
+- PreallocateFile: Reserves disk space for a file.
+*/
+
+// TruncateFile changes the size of an existing file, like the truncate command: a longer file is cut,
+// a shorter file is extended with zero bytes.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file
+//   - size: The new size of the file in bytes
+//
+// Returns:
+//   - error: An error if the path is not a file, size is negative or the file couldn't be resized, nil otherwise
+//
+// Example:
+//
+//	// Empty a log file without changing its inode, so the writing process keeps logging to it
+//	if err := ufs.TruncateFile("/var/log/app.log", 0); err != nil {
+//	    fmt.Printf("Error truncating log: %v\n", err)
+//	}
+func (ufs *UFS) TruncateFile(path string, size int64) (err error) {
+	defer ufs.recoverPanic("TruncateFile", &err)
+
+	if err := ufs.requireOS("TruncateFile"); err != nil {
+		return err
+	}
+
+	if size < 0 {
+		return fmt.Errorf("TruncateFile: negative size %d", size)
+	}
+	if !ufs.IsFile(path) {
+		return ufs.misuseError("TruncateFile", "path is not a file", path)
+	}
+
+	if err := os.Truncate(path, size); err != nil {
+		return ufs.wrapError(err, "TruncateFile")
+	}
+	return nil
+}
+
+// TouchFile creates an empty file if it doesn't exist, otherwise sets its access and modification
+// times to now without changing its content, like the touch command.
+// This function will create any parent directories if they don't exist.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file
+//
+// Returns:
+//   - error: An error if the path is a directory or the file couldn't be created or updated, nil otherwise
+//
+// Example:
+//
+//	// Mark the last successful run, for monitoring
+//	if err := ufs.TouchFile("/var/run/backup.done"); err != nil {
+//	    fmt.Printf("Error touching marker: %v\n", err)
+//	}
+func (ufs *UFS) TouchFile(path string) (err error) {
+	defer ufs.recoverPanic("TouchFile", &err)
+
+	if err := ufs.requireOS("TouchFile"); err != nil {
+		return err
+	}
+
+	if ufs.IsDirectory(path) {
+		return ufs.misuseError("TouchFile", "path is not a file", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return ufs.wrapError(err, "TouchFile")
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return ufs.wrapError(err, "TouchFile")
+	}
+	if err := file.Close(); err != nil {
+		return ufs.wrapError(err, "TouchFile")
+	}
+
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		return ufs.wrapError(err, "TouchFile")
+	}
+	return nil
+}
+
+// SetFileTimes sets the access and modification times of a file or directory.
+// A zero time leaves the corresponding time unchanged.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file or directory
+//   - atime: The new access time, or time.Time{} to keep it
+//   - mtime: The new modification time, or time.Time{} to keep it
+//
+// Returns:
+//   - error: An error if the path doesn't exist or its times couldn't be set, nil otherwise
+//
+// Example:
+//
+//	// Give a restored file back its original date
+//	if err := ufs.SetFileTimes("report.pdf", time.Time{}, originalModTime); err != nil {
+//	    fmt.Printf("Error setting file times: %v\n", err)
+//	}
+func (ufs *UFS) SetFileTimes(path string, atime, mtime time.Time) (err error) {
+	defer ufs.recoverPanic("SetFileTimes", &err)
+
+	if err := ufs.requireOS("SetFileTimes"); err != nil {
+		return err
+	}
+
+	if !ufs.PathExists(path) {
+		return fmt.Errorf("path does not exist: %s", path)
+	}
+
+	if err := os.Chtimes(path, atime, mtime); err != nil {
+		return ufs.wrapError(err, "SetFileTimes")
+	}
+	return nil
+}
+
+// PreallocateFile reserves disk space for a file, creating it if it doesn't exist. The file grows to
+// size bytes (zeros) if it is smaller, its content is kept; a larger file is left as is.
+// This function will create any parent directories if they don't exist.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file
+//   - size: The number of bytes to reserve
+//
+// Returns:
+//   - error: An error if size is negative, the path is a directory or the space couldn't be reserved
+//     (e.g. the disk is full), nil otherwise
+//
+// Example:
+//
+//	if err := ufs.PreallocateFile("download.iso.part", expectedSize); err != nil {
+//	    fmt.Printf("Not enough space for the download: %v\n", err)
+//	    return
+//	}
+func (ufs *UFS) PreallocateFile(path string, size int64) (err error) {
+	defer ufs.recoverPanic("PreallocateFile", &err)
+
+	if err := ufs.requireOS("PreallocateFile"); err != nil {
+		return err
+	}
+
+	if size < 0 {
+		return fmt.Errorf("PreallocateFile: negative size %d", size)
+	}
+	if ufs.IsDirectory(path) {
+		return ufs.misuseError("PreallocateFile", "path is not a file", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return ufs.wrapError(err, "PreallocateFile")
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return ufs.wrapError(err, "PreallocateFile")
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return ufs.wrapError(err, "PreallocateFile")
+	}
+	if err := preallocate(file, size); err != nil {
+		return ufs.wrapError(err, "PreallocateFile")
+	}
+	if info.Size() < size {
+		if err := file.Truncate(size); err != nil {
+			return ufs.wrapError(err, "PreallocateFile")
+		}
+	}
+	return file.Close()
+}
@@ -0,0 +1,23 @@
+//go:build linux
+
+package ufs
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// preallocate allocates the disk blocks of the first size bytes of a file with fallocate,
+// extending the file. File systems without fallocate support are left to the caller's extension.
+func preallocate(file *os.File, size int64) error {
+	if size == 0 {
+		return nil
+	}
+	err := unix.Fallocate(int(file.Fd()), 0, 0, size)
+	if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOSYS) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !windows
+
+package ufs
+
+import "os"
+
+// preallocate does nothing on this platform, the caller extends the file
+func preallocate(file *os.File, size int64) error {
+	return nil
+}
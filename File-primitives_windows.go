@@ -0,0 +1,22 @@
+//go:build windows
+
+package ufs
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// preallocate sets the allocation size of a file, which reserves its clusters without changing its size
+func preallocate(file *os.File, size int64) error {
+	info, err := file.Stat()
+	if err != nil || info.Size() >= size {
+		return err
+	}
+
+	allocation := struct{ AllocationSize int64 }{size}
+	return windows.SetFileInformationByHandle(windows.Handle(file.Fd()), windows.FileAllocationInfo,
+		(*byte)(unsafe.Pointer(&allocation)), uint32(unsafe.Sizeof(allocation)))
+}
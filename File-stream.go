@@ -0,0 +1,306 @@
+package ufs
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+/*
+File-stream.go contains the streaming counterparts of the file functions of file-Reader_writer.go.
+
+ReadFile and WriteFile hold the whole content in memory. The functions below move data through
+io.Reader and io.Writer instead, so files of any size are handled with constant memory:
+downloads written straight to disk, files served over HTTP, large logs processed line by line.
+
+Functions:
+- WriteFileFromReader: Writes everything read from an io.Reader to a file.
+- ReadFileToWriter: Copies the content of a file to an io.Writer.
+- OpenBufferedReader: Opens a file for buffered reading.
+- OpenBufferedWriter: Creates a file for buffered writing.
+- IterateLines: Calls a function for every line of a file, one line in memory at a time.
+*/
+
+// streamBufferSize is the buffer size used by the buffered readers and writers
+const streamBufferSize = 64 * 1024
+
+// BufferedReader is a file opened for buffered reading. Close closes the file.
+type BufferedReader struct {
+	*bufio.Reader
+	file io.Closer
+}
+
+// Close closes the underlying file
+func (r *BufferedReader) Close() error {
+	return r.file.Close()
+}
+
+// BufferedWriter is a file opened for buffered writing. Close flushes the buffer and closes the file.
+type BufferedWriter struct {
+	*bufio.Writer
+	file *os.File
+}
+
+// Close flushes the buffered data and closes the underlying file.
+// The file is closed even when the flush fails.
+func (w *BufferedWriter) Close() error {
+	flushErr := w.Flush()
+	closeErr := w.file.Close()
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
+
+// WriteFileFromReader writes everything read from r to a file, creating it if it doesn't exist
+// or overwriting it if it does. Only a small buffer is held in memory, whatever the size of the data.
+// This function will create any parent directories if they don't exist.
+// The partially written file is removed when reading or writing fails.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file to write
+//   - r: The reader providing the content, read until io.EOF
+//
+// Returns:
+//   - int64: The number of bytes written
+//   - error: An error if the file couldn't be written or r failed
+//
+// Example:
+//
+//	resp, err := http.Get("https://example.com/dataset.csv")
+//	if err != nil {
+//	    return
+//	}
+//	defer resp.Body.Close()
+//	n, err := ufs.WriteFileFromReader("/data/dataset.csv", resp.Body)
+//	if err != nil {
+//	    fmt.Printf("Error writing file: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("%d bytes downloaded\n", n)
+func (ufs *UFS) WriteFileFromReader(path string, r io.Reader) (_ int64, err error) {
+	defer ufs.recoverPanic("WriteFileFromReader", &err)
+
+	if err := ufs.requireOS("WriteFileFromReader"); err != nil {
+		return 0, err
+	}
+
+	// Ensure the directory exists
+	dir := filepath.Dir(path)
+	if !ufs.IsDirectory(dir) {
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			return 0, ufs.wrapError(err, "WriteFileFromReader")
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, ufs.wrapError(err, "WriteFileFromReader")
+	}
+
+	n, err := io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// The size is only known once written
+		err = ufs.checkQuota(path, n)
+	}
+	if err != nil {
+		os.Remove(path)
+		ufs.recordQuota(path)
+		return n, ufs.wrapError(err, "WriteFileFromReader")
+	}
+	ufs.recordQuota(path)
+	return n, nil
+}
+
+// ReadFileToWriter copies the content of a file to w. Only a small buffer is held in memory,
+// whatever the size of the file. The read-ahead and direct I/O options apply.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file to read
+//   - w: The writer receiving the content
+//
+// Returns:
+//   - int64: The number of bytes copied
+//   - error: An error if the file couldn't be read or w failed
+//
+// Example:
+//
+//	func serveReport(w http.ResponseWriter, r *http.Request) {
+//	    w.Header().Set("Content-Type", "text/csv")
+//	    if _, err := ufs.ReadFileToWriter("/reports/latest.csv", w); err != nil {
+//	        log.Printf("Error sending report: %v", err)
+//	    }
+//	}
+func (ufs *UFS) ReadFileToWriter(path string, w io.Writer) (_ int64, err error) {
+	defer ufs.recoverPanic("ReadFileToWriter", &err)
+
+	if !ufs.IsFile(path) {
+		return 0, ufs.misuseError("ReadFileToWriter", "path is not a file", path)
+	}
+
+	file, err := ufs.openSequential(path)
+	if err != nil {
+		return 0, ufs.wrapError(err, "ReadFileToWriter")
+	}
+	defer file.Close()
+
+	n, err := io.Copy(w, file)
+	if err != nil {
+		return n, ufs.wrapError(err, "ReadFileToWriter")
+	}
+	return n, nil
+}
+
+// OpenBufferedReader opens a file for buffered reading, e.g. to process a large file line by line
+// with ReadString or ReadLine. The caller must Close it.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file to read
+//
+// Returns:
+//   - *BufferedReader: The buffered reader, also an io.ReadCloser
+//   - error: An error if the file couldn't be opened
+//
+// Example:
+//
+//	reader, err := ufs.OpenBufferedReader("/var/log/huge.log")
+//	if err != nil {
+//	    fmt.Printf("Error opening file: %v\n", err)
+//	    return
+//	}
+//	defer reader.Close()
+//	for {
+//	    line, err := reader.ReadString('\n')
+//	    process(line)
+//	    if err != nil {
+//	        break
+//	    }
+//	}
+func (ufs *UFS) OpenBufferedReader(path string) (_ *BufferedReader, err error) {
+	defer ufs.recoverPanic("OpenBufferedReader", &err)
+
+	if !ufs.IsFile(path) {
+		return nil, ufs.misuseError("OpenBufferedReader", "path is not a file", path)
+	}
+
+	file, err := ufs.openSequential(path)
+	if err != nil {
+		return nil, ufs.wrapError(err, "OpenBufferedReader")
+	}
+	return &BufferedReader{Reader: bufio.NewReaderSize(file, streamBufferSize), file: file}, nil
+}
+
+// OpenBufferedWriter creates a file for buffered writing, creating it if it doesn't exist
+// or truncating it if it does. This function will create any parent directories if they don't exist.
+// The caller must Close it, which flushes the buffered data.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file to write
+//
+// Returns:
+//   - *BufferedWriter: The buffered writer, also an io.WriteCloser
+//   - error: An error if the file couldn't be created
+//
+// Example:
+//
+//	writer, err := ufs.OpenBufferedWriter("/exports/rows.csv")
+//	if err != nil {
+//	    fmt.Printf("Error creating file: %v\n", err)
+//	    return
+//	}
+//	for _, row := range rows {
+//	    writer.WriteString(row + "\n")
+//	}
+//	if err := writer.Close(); err != nil {
+//	    fmt.Printf("Error writing file: %v\n", err)
+//	}
+func (ufs *UFS) OpenBufferedWriter(path string) (_ *BufferedWriter, err error) {
+	defer ufs.recoverPanic("OpenBufferedWriter", &err)
+
+	if err := ufs.requireOS("OpenBufferedWriter"); err != nil {
+		return nil, err
+	}
+
+	// Ensure the directory exists
+	dir := filepath.Dir(path)
+	if !ufs.IsDirectory(dir) {
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			return nil, ufs.wrapError(err, "OpenBufferedWriter")
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, ufs.wrapError(err, "OpenBufferedWriter")
+	}
+	return &BufferedWriter{Writer: bufio.NewWriterSize(file, streamBufferSize), file: file}, nil
+}
+
+// IterateLines calls fn for every line of a file, holding a single line in memory,
+// so multi-GB files can be scanned. Line endings (\n or \r\n) are removed.
+// Lines longer than Options.MaxLineSize (64 KiB by default) fail with bufio.ErrTooLong.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file to read
+//   - fn: The function called for every line, with the line number starting at 1.
+//     Returning stop = true ends the iteration without error, returning an error ends it with that error
+//
+// Returns:
+//   - error: The error returned by fn, or an error if the file couldn't be read, nil otherwise
+//
+// Example:
+//
+//	errors := 0
+//	err := ufs.IterateLines("/var/log/app.log", func(lineNo int, line string) (bool, error) {
+//	    if strings.Contains(line, "ERROR") {
+//	        errors++
+//	    }
+//	    return errors >= 100, nil
+//	})
+//	if err != nil {
+//	    fmt.Printf("Error reading log: %v\n", err)
+//	}
+func (ufs *UFS) IterateLines(path string, fn func(lineNo int, line string) (stop bool, err error)) (err error) {
+	defer ufs.recoverPanic("IterateLines", &err)
+
+	if !ufs.IsFile(path) {
+		return ufs.misuseError("IterateLines", "path is not a file", path)
+	}
+
+	file, err := ufs.openSequential(path)
+	if err != nil {
+		return ufs.wrapError(err, "IterateLines")
+	}
+	defer file.Close()
+
+	scanner := ufs.newLineScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		stop, err := fn(lineNo, scanner.Text())
+		if err != nil {
+			return ufs.wrapError(err, "IterateLines")
+		}
+		if stop {
+			return nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return ufs.wrapError(err, "IterateLines")
+	}
+	return nil
+}
+
+// newLineScanner returns a line scanner accepting lines up to Options.MaxLineSize
+func (ufs *UFS) newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	if ufs.opts.MaxLineSize > 0 {
+		scanner.Buffer(make([]byte, 0, min(ufs.opts.MaxLineSize, streamBufferSize)), ufs.opts.MaxLineSize)
+	}
+	return scanner
+}
@@ -0,0 +1,287 @@
+package ufs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+File-type.go contains functions to find out what a file holds, from its first bytes rather than its name.
+
+DetectMIMEType recognizes the formats of http.DetectContentType (images, audio, video, PDF, ZIP, gzip,
+HTML, ...) plus executables (ELF, PE, Mach-O) and the 7z, xz, bzip2, zstd and tar archives,
+and falls back to the type registered for the file extension when the content is not recognized.
+
+Functions:
+- IsBinaryFile: Reports whether a file holds binary data rather than text.
+- DetectMIMEType: Returns the MIME type of a file, sniffed from its content.
+- GetFileKind: Returns the coarse category of a file (image, video, archive, text, executable...).
+*/
+
+// FileKind is a coarse category of files, returned by GetFileKind.
+type FileKind string
+
+const (
+	FileKindImage      FileKind = "image"
+	FileKindVideo      FileKind = "video"
+	FileKindAudio      FileKind = "audio"
+	FileKindArchive    FileKind = "archive"    // Archives and compressed files
+	FileKindDocument   FileKind = "document"   // PDF and office documents
+	FileKindText       FileKind = "text"       // Plain text, source code, JSON, XML, HTML...
+	FileKindExecutable FileKind = "executable" // Native programs and libraries, WebAssembly modules
+	FileKindBinary     FileKind = "binary"     // Binary data of an unknown format
+)
+
+// sniffSize is the number of bytes read to detect the content type of a file
+const sniffSize = 512
+
+// mimeOctetStream and mimeTextPlain are the types returned when the content of a file is not recognized
+const (
+	mimeOctetStream = "application/octet-stream"
+	mimeTextPlain   = "text/plain"
+)
+
+// magicSignature is a MIME type recognized by bytes at a fixed offset
+type magicSignature struct {
+	offset   int
+	magic    []byte
+	mimeType string
+}
+
+// magicSignatures complete http.DetectContentType, which doesn't know executables and most archives
+var magicSignatures = []magicSignature{
+	{0, []byte("\x7fELF"), "application/x-elf"},
+	{0, []byte{0xFE, 0xED, 0xFA, 0xCE}, "application/x-mach-binary"},
+	{0, []byte{0xFE, 0xED, 0xFA, 0xCF}, "application/x-mach-binary"},
+	{0, []byte{0xCE, 0xFA, 0xED, 0xFE}, "application/x-mach-binary"},
+	{0, []byte{0xCF, 0xFA, 0xED, 0xFE}, "application/x-mach-binary"},
+	{0, []byte{0xCA, 0xFE, 0xBA, 0xBE}, "application/x-mach-binary"}, // Universal binary (or a Java class)
+	{0, []byte("7z\xBC\xAF\x27\x1C"), "application/x-7z-compressed"},
+	{0, []byte("\xFD7zXZ\x00"), "application/x-xz"},
+	{0, []byte{0x28, 0xB5, 0x2F, 0xFD}, "application/zstd"},
+	{257, []byte("ustar"), "application/x-tar"},
+}
+
+// mimeKinds maps MIME types that are not named after their kind
+var mimeKinds = map[string]FileKind{
+	"application/pdf":                                FileKindDocument,
+	"application/msword":                             FileKindDocument,
+	"application/rtf":                                FileKindDocument,
+	"application/vnd.oasis.opendocument.text":        FileKindDocument,
+	"application/vnd.oasis.opendocument.spreadsheet": FileKindDocument,
+	"application/postscript":                         FileKindDocument,
+	"application/zip":                                FileKindArchive,
+	"application/x-gzip":                             FileKindArchive,
+	"application/gzip":                               FileKindArchive,
+	"application/x-rar-compressed":                   FileKindArchive,
+	"application/vnd.rar":                            FileKindArchive,
+	"application/x-7z-compressed":                    FileKindArchive,
+	"application/x-xz":                               FileKindArchive,
+	"application/x-bzip2":                            FileKindArchive,
+	"application/zstd":                               FileKindArchive,
+	"application/x-tar":                              FileKindArchive,
+	"application/x-elf":                              FileKindExecutable,
+	"application/x-mach-binary":                      FileKindExecutable,
+	"application/vnd.microsoft.portable-executable":  FileKindExecutable,
+	"application/x-msdownload":                       FileKindExecutable,
+	"application/wasm":                               FileKindExecutable,
+	"application/json":                               FileKindText,
+	"application/xml":                                FileKindText,
+	"application/javascript":                         FileKindText,
+}
+
+// IsBinaryFile reports whether a file holds binary data rather than text, by looking for a NUL byte
+// in its first 8 KiB, like git and grep do. Empty files are text.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file
+//
+// Returns:
+//   - bool: true if the file holds binary data
+//   - error: An error if the path is not a file or couldn't be read, nil otherwise
+//
+// Example:
+//
+//	binary, err := ufs.IsBinaryFile("upload.dat")
+//	if err == nil && binary {
+//	    fmt.Println("Not a text file, skipping")
+//	}
+func (ufs *UFS) IsBinaryFile(path string) (_ bool, err error) {
+	defer ufs.recoverPanic("IsBinaryFile", &err)
+
+	if !ufs.IsFile(path) {
+		return false, ufs.misuseError("IsBinaryFile", "path is not a file", path)
+	}
+	head, err := readFileHead(path, binarySniffSize)
+	if err != nil {
+		return false, ufs.wrapError(err, "IsBinaryFile")
+	}
+	return bytes.IndexByte(head, 0) >= 0, nil
+}
+
+// DetectMIMEType returns the MIME type of a file, without parameters (e.g. "image/png"), sniffed from
+// its first bytes. When the content is not recognized, the type registered for the extension of the file
+// is returned, or else "text/plain" for text and "application/octet-stream" for binary data.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file
+//
+// Returns:
+//   - string: The MIME type of the file
+//   - error: An error if the path is not a file or couldn't be read, nil otherwise
+//
+// Example:
+//
+//	mimeType, err := ufs.DetectMIMEType("upload.bin")
+//	if err != nil {
+//	    fmt.Printf("Error reading upload: %v\n", err)
+//	    return
+//	}
+//	if !strings.HasPrefix(mimeType, "image/") {
+//	    fmt.Printf("Refusing %s upload\n", mimeType)
+//	}
+func (ufs *UFS) DetectMIMEType(path string) (_ string, err error) {
+	defer ufs.recoverPanic("DetectMIMEType", &err)
+
+	if !ufs.IsFile(path) {
+		return "", ufs.misuseError("DetectMIMEType", "path is not a file", path)
+	}
+	mimeType, err := detectMIMEType(path)
+	if err != nil {
+		return "", ufs.wrapError(err, "DetectMIMEType")
+	}
+	return mimeType, nil
+}
+
+// GetFileKind returns the coarse category of a file, from its MIME type: images, videos, audio,
+// archives, documents (PDF, office files), text (including source code, JSON, XML), executables,
+// or FileKindBinary for other binary data.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file
+//
+// Returns:
+//   - FileKind: The category of the file
+//   - error: An error if the path is not a file or couldn't be read, nil otherwise
+//
+// Example:
+//
+//	kind, err := ufs.GetFileKind("download")
+//	if err == nil && kind == ufs.FileKindExecutable {
+//	    fmt.Println("Warning: the download is a program")
+//	}
+func (ufs *UFS) GetFileKind(path string) (_ FileKind, err error) {
+	defer ufs.recoverPanic("GetFileKind", &err)
+
+	if !ufs.IsFile(path) {
+		return "", ufs.misuseError("GetFileKind", "path is not a file", path)
+	}
+	mimeType, err := detectMIMEType(path)
+	if err != nil {
+		return "", ufs.wrapError(err, "GetFileKind")
+	}
+	return mimeKind(mimeType), nil
+}
+
+// mimeKind returns the category of a MIME type
+func mimeKind(mimeType string) FileKind {
+	if kind, ok := mimeKinds[mimeType]; ok {
+		return kind
+	}
+	family, subtype, _ := strings.Cut(mimeType, "/")
+	switch {
+	case family == "image":
+		return FileKindImage
+	case family == "video":
+		return FileKindVideo
+	case family == "audio":
+		return FileKindAudio
+	case family == "text":
+		return FileKindText
+	case strings.HasSuffix(subtype, "+xml") || strings.HasSuffix(subtype, "+json"):
+		return FileKindText
+	case strings.HasPrefix(subtype, "vnd.openxmlformats-officedocument."):
+		return FileKindDocument
+	default:
+		return FileKindBinary
+	}
+}
+
+// detectMIMEType sniffs the MIME type of a file, falling back to its extension
+func detectMIMEType(path string) (string, error) {
+	head, err := readFileHead(path, sniffSize)
+	if err != nil {
+		return "", err
+	}
+
+	mimeType := sniffMIMEType(head)
+	if mimeType != mimeOctetStream && mimeType != mimeTextPlain {
+		return mimeType, nil
+	}
+	if byExtension := mimeTypeByExtension(path); byExtension != "" {
+		return byExtension, nil
+	}
+	return mimeType, nil
+}
+
+// sniffMIMEType returns the MIME type of content, without parameters
+func sniffMIMEType(head []byte) string {
+	for _, signature := range magicSignatures {
+		end := signature.offset + len(signature.magic)
+		if len(head) >= end && bytes.Equal(head[signature.offset:end], signature.magic) {
+			return signature.mimeType
+		}
+	}
+
+	// Short signatures that text files could start with are checked further
+	if isPortableExecutable(head) {
+		return "application/vnd.microsoft.portable-executable"
+	}
+	if len(head) >= 4 && bytes.HasPrefix(head, []byte("BZh")) && head[3] >= '1' && head[3] <= '9' {
+		return "application/x-bzip2"
+	}
+
+	mimeType, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	return mimeType
+}
+
+// isPortableExecutable reports whether content starts with a DOS header pointing to a PE header
+func isPortableExecutable(head []byte) bool {
+	if len(head) < 64 || !bytes.HasPrefix(head, []byte("MZ")) {
+		return false
+	}
+	offset := int(binary.LittleEndian.Uint32(head[60:64]))
+	return offset >= 64 && offset+4 <= len(head) && bytes.Equal(head[offset:offset+4], []byte("PE\x00\x00"))
+}
+
+// mimeTypeByExtension returns the MIME type registered for the extension of a file, without parameters,
+// "" if there is none
+func mimeTypeByExtension(path string) string {
+	mimeType := mime.TypeByExtension(filepath.Ext(path))
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		return mediaType
+	}
+	return mimeType
+}
+
+// readFileHead returns up to size bytes from the start of a file
+func readFileHead(path string, size int) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	head := make([]byte, size)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return head[:n], nil
+}
@@ -0,0 +1,216 @@
+package ufs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+/*
+Find.go contains Find and FindFunc, a programmatic `find`: they walk a directory tree and select the
+entries with predicates instead of hand-written filepath.Walk filters.
+
+	logs, err := ufs.Find("/var/log", ufs.FindOptions{
+	    Name:          "*.log",
+	    MinSize:       10 << 20,
+	    ModifiedAfter: time.Now().AddDate(0, 0, -7),
+	    Type:          ufs.FindFiles,
+	})
+
+Every predicate set in FindOptions must match, the zero value of a field doesn't filter anything.
+Hidden entries (see IsFileHidden) are skipped with their contents unless FindOptions.Hidden is set,
+like fd and ripgrep do. Symbolic links are reported as links, they are not followed.
+
+Functions:
+- Find: Returns the paths of the entries matching the options
+- FindFunc: Calls a function for every entry matching the options, as they are found
+*/
+
+// FindType selects the kind of entries returned by Find.
+type FindType int
+
+const (
+	// FindAll returns files, directories and symbolic links
+	FindAll FindType = iota
+	// FindFiles returns regular files only
+	FindFiles
+	// FindDirectories returns directories only
+	FindDirectories
+	// FindSymlinks returns symbolic links only
+	FindSymlinks
+)
+
+// FindOptions are the predicates of Find and FindFunc, all of them must match.
+type FindOptions struct {
+	// Name is a pattern matched against the name of the entries, or against their slash separated path
+	// relative to the root when it contains a "/", see Glob.go
+	Name string
+
+	// Regex is a regular expression matched against the slash separated path relative to the root
+	Regex string
+
+	// Exclude skips the entries matching any of these patterns, and the contents of the directories
+	// matching them, matched like Name (e.g. ".git", "node_modules", "build/**")
+	Exclude []string
+
+	// MinSize and MaxSize bound the size of the files in bytes, 0 doesn't bound it.
+	// When one of them is set, directories and symbolic links don't match.
+	MinSize int64
+	MaxSize int64
+
+	// ModifiedAfter and ModifiedBefore bound the modification time, the zero time doesn't bound it
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+
+	// Type selects files, directories or symbolic links, FindAll returns all of them
+	Type FindType
+
+	// MaxDepth is the deepest level searched, 1 for the entries directly in the root, 0 is unlimited
+	MaxDepth int
+
+	// Hidden includes hidden entries, which are skipped with their contents by default
+	Hidden bool
+}
+
+// Find returns the entries of a directory tree matching the options, see Find.go.
+//
+// Parameters:
+//   - root: The absolute or relative path to the directory to search
+//   - opts: The predicates the entries must match
+//
+// Returns:
+//   - []string: The paths of the matching entries, prefixed with root, in lexical order
+//   - error: An error if root is not a directory, an option is invalid or the walk was aborted
+//
+// Example:
+//
+//	bigVideos, err := ufs.Find("/home/me", ufs.FindOptions{
+//	    Name:    "*.{mp4,mkv}",
+//	    MinSize: 1 << 30,
+//	    Exclude: []string{"node_modules"},
+//	})
+//	if err != nil {
+//	    fmt.Printf("Error searching videos: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("%d videos over 1 GiB\n", len(bigVideos))
+func (ufs *UFS) Find(root string, opts FindOptions) (_ []string, err error) {
+	defer ufs.recoverPanic("Find", &err)
+
+	var found []string
+	err = ufs.findEntries("Find", root, opts, func(path string, info os.FileInfo) error {
+		found = append(found, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// FindFunc calls fn for every entry of a directory tree matching the options, as soon as it is found,
+// so large trees can be processed without collecting all the paths. Returning filepath.SkipAll from fn
+// stops the search without error, any other error stops it and is returned.
+//
+// Parameters:
+//   - root: The absolute or relative path to the directory to search
+//   - opts: The predicates the entries must match
+//   - fn: The function called with the path and the information of every matching entry
+//
+// Returns:
+//   - error: An error if root is not a directory, an option is invalid, the walk was aborted or fn failed
+//
+// Example:
+//
+//	err := ufs.FindFunc("/srv/uploads", ufs.FindOptions{
+//	    Type:           ufs.FindFiles,
+//	    ModifiedBefore: time.Now().AddDate(0, -6, 0),
+//	}, func(path string, info os.FileInfo) error {
+//	    fmt.Printf("%s (%d bytes) is old\n", path, info.Size())
+//	    return nil
+//	})
+func (ufs *UFS) FindFunc(root string, opts FindOptions, fn func(path string, info os.FileInfo) error) (err error) {
+	defer ufs.recoverPanic("FindFunc", &err)
+	return ufs.findEntries("FindFunc", root, opts, fn)
+}
+
+// findEntries walks root and calls fn for the entries matching opts
+func (ufs *UFS) findEntries(operation, root string, opts FindOptions, fn func(path string, info os.FileInfo) error) error {
+	if !ufs.IsDirectory(root) {
+		return ufs.misuseError(operation, "root is not a directory", root)
+	}
+
+	var name *globPattern
+	if opts.Name != "" {
+		glob, err := compileGlob(opts.Name)
+		if err != nil {
+			return ufs.wrapError(err, operation)
+		}
+		name = glob
+	}
+	var regex *regexp.Regexp
+	if opts.Regex != "" {
+		compiled, err := regexp.Compile(opts.Regex)
+		if err != nil {
+			return ufs.wrapError(err, operation)
+		}
+		regex = compiled
+	}
+	walkOptions := WalkOptions{MaxDepth: opts.MaxDepth, SkipHidden: !opts.Hidden, Skip: opts.Exclude}
+	return ufs.walk(operation, root, walkOptions, func(path string, d fs.DirEntry) error {
+		if path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if !opts.matchType(d.Type()) || (name != nil && !name.matchName(rel)) || (regex != nil && !regex.MatchString(rel)) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkSkip, operation)
+		}
+		if !opts.matchInfo(info) {
+			return nil
+		}
+		return fn(path, info)
+	})
+}
+
+// matchType reports whether an entry of the given type matches opts.Type
+func (opts *FindOptions) matchType(mode fs.FileMode) bool {
+	switch opts.Type {
+	case FindFiles:
+		return mode.IsRegular()
+	case FindDirectories:
+		return mode.IsDir()
+	case FindSymlinks:
+		return mode&fs.ModeSymlink != 0
+	}
+	return true
+}
+
+// matchInfo reports whether the size and modification time of an entry match opts
+func (opts *FindOptions) matchInfo(info os.FileInfo) bool {
+	if opts.MinSize > 0 || opts.MaxSize > 0 {
+		if !info.Mode().IsRegular() {
+			return false
+		}
+		if info.Size() < opts.MinSize || (opts.MaxSize > 0 && info.Size() > opts.MaxSize) {
+			return false
+		}
+	}
+	if !opts.ModifiedAfter.IsZero() && !info.ModTime().After(opts.ModifiedAfter) {
+		return false
+	}
+	if !opts.ModifiedBefore.IsZero() && !info.ModTime().Before(opts.ModifiedBefore) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,206 @@
+package ufs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Generated-files.go contains functions for code generators writing files that users may also edit.
+
+WriteGeneratedFile adds the conventional header to the file:
+
+	// Code generated by mytool. DO NOT EDIT.
+
+and records the SHA-256 of what it wrote in a sidecar manifest (.ufs-generated.json, one per directory).
+When the file is generated again, the manifest tells whether it was edited by hand since:
+an edited file is never overwritten, an ErrGeneratedFileEdited error is returned instead.
+
+The comment syntax of the header follows the file extension. Formats without comments (e.g. .json)
+get no header and rely on the manifest only.
+
+Functions:
+- WriteGeneratedFile: Writes a generated file, refusing to overwrite manual edits.
+*/
+
+// generatedManifestName is the file name of the sidecar manifest, next to the generated files
+const generatedManifestName = ".ufs-generated.json"
+
+// generatedManifest records the hash of the generated files of a directory
+type generatedManifest struct {
+	Version int               `json:"version"`
+	Files   map[string]string `json:"files"` // File name -> SHA-256 of the generated content
+}
+
+// generatedCommentStyles maps file extensions to the start and end of a line comment
+var generatedCommentStyles = map[string][2]string{
+	".go": {"// ", ""}, ".js": {"// ", ""}, ".ts": {"// ", ""}, ".jsx": {"// ", ""}, ".tsx": {"// ", ""},
+	".c": {"// ", ""}, ".h": {"// ", ""}, ".cpp": {"// ", ""}, ".hpp": {"// ", ""}, ".cs": {"// ", ""},
+	".java": {"// ", ""}, ".kt": {"// ", ""}, ".rs": {"// ", ""}, ".swift": {"// ", ""}, ".dart": {"// ", ""},
+	".proto": {"// ", ""}, ".scss": {"// ", ""},
+	".py": {"# ", ""}, ".sh": {"# ", ""}, ".rb": {"# ", ""}, ".pl": {"# ", ""}, ".yaml": {"# ", ""},
+	".yml": {"# ", ""}, ".toml": {"# ", ""}, ".ini": {"# ", ""}, ".env": {"# ", ""}, ".mk": {"# ", ""},
+	".ps1": {"# ", ""}, ".r": {"# ", ""}, ".tf": {"# ", ""},
+	".sql": {"-- ", ""}, ".lua": {"-- ", ""}, ".hs": {"-- ", ""},
+	".html": {"<!-- ", " -->"}, ".xml": {"<!-- ", " -->"}, ".md": {"<!-- ", " -->"}, ".vue": {"<!-- ", " -->"},
+	".css": {"/* ", " */"},
+}
+
+// WriteGeneratedFile writes the output of a code generator with a "Code generated by <marker>. DO NOT EDIT." header.
+// If the file exists and was edited by hand since it was last generated, or was not generated by
+// WriteGeneratedFile with this marker, nothing is written and an error matching ErrGeneratedFileEdited is returned.
+// The file is left untouched when its content doesn't change.
+// This function will create any parent directories if they don't exist.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file to generate
+//   - content: The generated content, without header
+//   - marker: The name of the generator, used in the header
+//
+// Returns:
+//   - error: An error matching ErrGeneratedFileEdited if the file was edited by hand,
+//     an error if the file couldn't be written, nil otherwise
+//
+// Example:
+//
+//	err := ufs.WriteGeneratedFile("api/client.go", source, "apigen")
+//	if errors.Is(err, ufs.ErrGeneratedFileEdited) {
+//	    fmt.Println("api/client.go was edited by hand, delete it to regenerate it")
+//	} else if err != nil {
+//	    fmt.Printf("Error writing generated file: %v\n", err)
+//	}
+func (ufs *UFS) WriteGeneratedFile(path, content, marker string) (err error) {
+	defer ufs.recoverPanic("WriteGeneratedFile", &err)
+
+	if err := ufs.requireOS("WriteGeneratedFile"); err != nil {
+		return err
+	}
+
+	if marker == "" {
+		return fmt.Errorf("WriteGeneratedFile: marker can't be empty")
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return ufs.wrapError(err, "WriteGeneratedFile")
+	}
+
+	manifestPath := filepath.Join(dir, generatedManifestName)
+	manifest, err := readGeneratedManifest(manifestPath)
+	if err != nil {
+		return ufs.wrapError(err, "WriteGeneratedFile")
+	}
+
+	header := generatedHeader(path, marker)
+	data := []byte(withGeneratedHeader(content, header))
+	name := filepath.Base(path)
+
+	existing, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		// First generation
+	case err != nil:
+		return ufs.wrapError(err, "WriteGeneratedFile")
+	default:
+		recorded, ok := manifest.Files[name]
+		switch {
+		case ok && recorded != generatedHash(existing):
+			return fmt.Errorf("WriteGeneratedFile: %w: %s", ErrGeneratedFileEdited, path)
+		case !ok && (header == "" || !hasGeneratedHeader(string(existing), header)):
+			// Not generated by us, or by us with a lost manifest and no header to recognise it
+			return fmt.Errorf("WriteGeneratedFile: %w (not generated by %s): %s", ErrGeneratedFileEdited, marker, path)
+		case ok && string(existing) == string(data):
+			return nil // Unchanged
+		}
+	}
+
+	// The file and the manifest keep their permissions, e.g. an executable generated script
+	if err := writeFileAtomic(path, data, ufs.fileMode(existingFileMode(path))); err != nil {
+		return ufs.wrapError(err, "WriteGeneratedFile")
+	}
+
+	manifest.Files[name] = generatedHash(data)
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return ufs.wrapError(err, "WriteGeneratedFile")
+	}
+	if err := writeFileAtomic(manifestPath, manifestData, ufs.fileMode(existingFileMode(manifestPath))); err != nil {
+		return ufs.wrapError(err, "WriteGeneratedFile")
+	}
+	return nil
+}
+
+// generatedHeader returns the header line for a file, empty when its format has no comments
+func generatedHeader(path, marker string) string {
+	style, ok := generatedCommentStyles[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		switch filepath.Base(path) {
+		case "Makefile", "Dockerfile", ".gitignore", ".env":
+			style, ok = [2]string{"# ", ""}, true
+		}
+	}
+	if !ok {
+		return ""
+	}
+	return style[0] + "Code generated by " + marker + ". DO NOT EDIT." + style[1] + "\n\n"
+}
+
+// withGeneratedHeader adds the header at the top of content, after the shebang line of scripts
+func withGeneratedHeader(content, header string) string {
+	if strings.HasPrefix(content, "#!") {
+		if end := strings.IndexByte(content, '\n'); end >= 0 {
+			return content[:end+1] + header + content[end+1:]
+		}
+	}
+	return header + content
+}
+
+// hasGeneratedHeader reports whether content starts with the header, after the shebang line of scripts
+func hasGeneratedHeader(content, header string) bool {
+	if strings.HasPrefix(content, "#!") {
+		if end := strings.IndexByte(content, '\n'); end >= 0 {
+			content = content[end+1:]
+		}
+	}
+	return strings.HasPrefix(content, header)
+}
+
+// existingFileMode returns the permissions of the file at path, 0644 when it doesn't exist yet
+func existingFileMode(path string) os.FileMode {
+	if info, err := os.Stat(path); err == nil {
+		return info.Mode().Perm()
+	}
+	return 0644
+}
+
+// generatedHash returns the hex encoded SHA-256 of generated content
+func generatedHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// readGeneratedManifest reads the manifest of a directory, an empty one when it doesn't exist yet
+func readGeneratedManifest(path string) (*generatedManifest, error) {
+	manifest := &generatedManifest{Version: 1, Files: map[string]string{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("invalid generated files manifest %s: %w", path, err)
+	}
+	if manifest.Files == nil {
+		manifest.Files = map[string]string{}
+	}
+	return manifest, nil
+}
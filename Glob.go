@@ -0,0 +1,309 @@
+package ufs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Glob.go contains the pattern matching shared by the functions selecting files by name: Glob, GlobIn,
+RemoveByPattern, ExtractFiles, PlanOperations and the Include/Exclude filters of SearchInDirectory
+and ReplaceInDirectory.
+
+Patterns use "/" as separator on every OS and support, on top of the filepath.Match syntax:
+- "**" as a whole segment, matching zero or more directories: "docs/**" or "src/**" followed by "/*.go"
+- brace expansion, nested or not: "*.{jpg,png}", "{cmd,internal/{api,db}}/*.go"
+
+	images, err := ufs.GlobIn("assets", "{icons,photos/**}/*.{png,svg}", "node_modules", "*.min.svg")
+
+Exclusion patterns remove matches and stop the walk in excluded directories. Like the filters of
+.gitignore, an exclusion without "/" matches the name of a file or directory at any depth ("vendor",
+"*.bak"), the others the path relative to the root of the walk ("build/tmp/**").
+
+Matches are returned in lexical order with their root prepended, directories included. Entries are
+not followed through symbolic links, and hidden ones are skipped when Options.ExcludeHidden is set.
+
+Functions:
+- Glob: Returns the paths matching a pattern
+- GlobIn: Returns the paths under a directory matching a pattern
+*/
+
+// Glob returns the files and directories matching a pattern, see Glob.go for the syntax.
+// The directories before the first wildcard of the pattern are the root of the walk, exclusions
+// with a "/" are relative to it.
+//
+// Parameters:
+//   - pattern: The absolute or relative pattern, e.g. "src/**/*.go"
+//   - excludes: Patterns of the paths to leave out
+//
+// Returns:
+//   - []string: The matching paths in lexical order, empty if nothing matches
+//   - error: An error if a pattern is malformed or the walk was aborted
+//
+// Example:
+//
+//	sources, err := ufs.Glob("src/**/*.{go,mod}", "testdata", "*_test.go")
+//	if err != nil {
+//	    fmt.Printf("Error listing sources: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("%d source files\n", len(sources))
+func (ufs *UFS) Glob(pattern string, excludes ...string) (_ []string, err error) {
+	defer ufs.recoverPanic("Glob", &err)
+
+	// Split the pattern at the first segment with a wildcard
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	static := 0
+	for static < len(segments) && !strings.ContainsAny(segments[static], `*?[{\`) {
+		static++
+	}
+	root := strings.Join(segments[:static], "/")
+	if root == "" && static > 0 {
+		root = "/"
+	}
+
+	if static == len(segments) {
+		// Nothing to expand: the pattern is a path
+		if _, err := ufs.lstat(pattern); err != nil {
+			return nil, nil
+		}
+		if excluded, err := compileGlobs(excludes); err != nil {
+			return nil, ufs.wrapError(err, "Glob")
+		} else if excluded.matchName(filepath.Base(pattern)) {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+
+	if root == "" {
+		root = "."
+	}
+	if info, err := ufs.backend().Stat(filepath.FromSlash(root)); err != nil || !info.IsDir() {
+		return nil, nil
+	}
+	return ufs.globIn("Glob", filepath.FromSlash(root), strings.Join(segments[static:], "/"), excludes)
+}
+
+// GlobIn returns the files and directories under root whose path relative to root matches a
+// pattern, see Glob.go for the syntax.
+//
+// Parameters:
+//   - root: The absolute or relative path to the directory to search
+//   - pattern: The pattern relative to root, e.g. "**/*.log"
+//   - excludes: Patterns of the paths to leave out
+//
+// Returns:
+//   - []string: The matching paths, prefixed with root, in lexical order
+//   - error: An error if root is not a directory, a pattern is malformed or the walk was aborted
+//
+// Example:
+//
+//	logs, err := ufs.GlobIn("/var/log/app", "**/*.{log,log.gz}", "archive/**")
+//	if err != nil {
+//	    fmt.Printf("Error listing logs: %v\n", err)
+//	    return
+//	}
+//	for _, log := range logs {
+//	    fmt.Println(log)
+//	}
+func (ufs *UFS) GlobIn(root, pattern string, excludes ...string) (_ []string, err error) {
+	defer ufs.recoverPanic("GlobIn", &err)
+
+	if !ufs.IsDirectory(root) {
+		return nil, ufs.misuseError("GlobIn", "root is not a directory", root)
+	}
+	return ufs.globIn("GlobIn", root, pattern, excludes)
+}
+
+// globIn walks root and collects the paths matching pattern, skipping the directories that can't
+// contain a match. Errors are reported as those of operation.
+func (ufs *UFS) globIn(operation, root, pattern string, excludes []string) ([]string, error) {
+	glob, err := compileGlob(pattern)
+	if err != nil {
+		return nil, ufs.wrapError(err, operation)
+	}
+	var matches []string
+	err = ufs.walk(operation, root, WalkOptions{Skip: excludes}, func(p string, d os.DirEntry) error {
+		if p == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if glob.match(rel) {
+			matches = append(matches, p)
+		}
+		if d.IsDir() && !glob.mayMatchUnder(rel) {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// globPattern is a compiled pattern: the alternatives of its braces, split in segments
+type globPattern struct {
+	alternatives [][]string
+}
+
+// compileGlob expands the braces of a pattern and checks the syntax of its segments
+func compileGlob(pattern string) (*globPattern, error) {
+	expanded, err := expandBraces(filepath.ToSlash(pattern))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+
+	glob := &globPattern{}
+	for _, alternative := range expanded {
+		var segments []string
+		for _, segment := range strings.Split(strings.TrimPrefix(alternative, "./"), "/") {
+			// "**/**" is the same as "**"
+			if segment == "**" && len(segments) > 0 && segments[len(segments)-1] == "**" {
+				continue
+			}
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+			segments = append(segments, segment)
+		}
+		glob.alternatives = append(glob.alternatives, segments)
+	}
+	return glob, nil
+}
+
+// compileGlobs compiles a list of patterns into one pattern matching any of them
+func compileGlobs(patterns []string) (*globPattern, error) {
+	globs := &globPattern{}
+	for _, pattern := range patterns {
+		glob, err := compileGlob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		globs.alternatives = append(globs.alternatives, glob.alternatives...)
+	}
+	return globs, nil
+}
+
+// match reports whether a slash separated path matches the pattern
+func (glob *globPattern) match(name string) bool {
+	names := strings.Split(name, "/")
+	for _, segments := range glob.alternatives {
+		if matchSegments(segments, names) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchName is match where the alternatives without "/" are matched against the base name of the
+// path, like the filters of .gitignore
+func (glob *globPattern) matchName(name string) bool {
+	names := strings.Split(name, "/")
+	for _, segments := range glob.alternatives {
+		if len(segments) == 1 {
+			if matched, _ := path.Match(segments[0], names[len(names)-1]); matched {
+				return true
+			}
+		} else if matchSegments(segments, names) {
+			return true
+		}
+	}
+	return false
+}
+
+// mayMatchUnder reports whether a path inside the directory dir could match the pattern
+func (glob *globPattern) mayMatchUnder(dir string) bool {
+	names := strings.Split(dir, "/")
+	for _, segments := range glob.alternatives {
+		if matchSegmentsPrefix(segments, names) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegmentsPrefix reports whether the segments of a directory match the beginning of the
+// pattern, leaving segments to match its content
+func matchSegmentsPrefix(pattern, name []string) bool {
+	for ; len(name) > 0; name = name[1:] {
+		if len(pattern) == 0 {
+			return false
+		}
+		if pattern[0] == "**" {
+			return true
+		}
+		if matched, _ := path.Match(pattern[0], name[0]); !matched {
+			return false
+		}
+		pattern = pattern[1:]
+	}
+	return len(pattern) > 0
+}
+
+// expandBraces returns the patterns described by the braces of a pattern, "{a,b}c" gives "ac" and
+// "bc". Braces without a comma are kept as is, escaped braces and commas are literal.
+func expandBraces(pattern string) ([]string, error) {
+	// Find the first group of braces
+	open, depth := -1, 0
+	var commas []int
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				open, commas = i, nil
+			}
+			depth++
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		case '}':
+			if depth == 0 {
+				return nil, fmt.Errorf("unmatched '}'")
+			}
+			depth--
+			if depth > 0 {
+				continue
+			}
+			if len(commas) == 0 {
+				// "{a}" is literal, expand the rest of the pattern
+				rest, err := expandBraces(pattern[i+1:])
+				if err != nil {
+					return nil, err
+				}
+				expanded := make([]string, len(rest))
+				for j, suffix := range rest {
+					expanded[j] = pattern[:i+1] + suffix
+				}
+				return expanded, nil
+			}
+
+			var expanded []string
+			start := open + 1
+			for _, end := range append(commas, i) {
+				alternatives, err := expandBraces(pattern[:open] + pattern[start:end] + pattern[i+1:])
+				if err != nil {
+					return nil, err
+				}
+				expanded = append(expanded, alternatives...)
+				start = end + 1
+			}
+			return expanded, nil
+		}
+	}
+	if depth > 0 {
+		return nil, fmt.Errorf("unmatched '{'")
+	}
+	return []string{pattern}, nil
+}
@@ -0,0 +1,165 @@
+package ufs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Ingest.go contains functions to import files into a content-addressed library, for media and document
+library builders.
+
+IngestFile hashes the incoming file and looks the hash up in the index of the library: a file whose
+content is already in the library is not copied again, whatever its name. New files are copied under
+a layout derived from their hash or their date, and recorded in the index.
+
+The index is a JSON file at the root of the library (.ufs-library.json), mapping the SHA-256 of every
+file to its path relative to the root:
+
+	{
+	  "files": {
+	    "9f86d081884c7d65...": {"path": "2024/05/IMG_0042.jpg", "size": 2481152, "added": "2024-05-01T10:12:00Z"}
+	  }
+	}
+
+Functions:
+- IngestFile: Copies a file into a library unless the library already holds the same content.
+*/
+
+// libraryIndexName is the name of the index file at the root of a library
+const libraryIndexName = ".ufs-library.json"
+
+// ingestMu serializes the updates of library indexes within the process
+var ingestMu sync.Mutex
+
+// IngestLayout decides where IngestFile places new files in the library.
+type IngestLayout int
+
+const (
+	// LayoutByHash places files under their hash: "9f/86/9f86d081...e9.jpg"
+	LayoutByHash IngestLayout = iota
+	// LayoutByDate places files under the year and month of their modification time, keeping their name:
+	// "2024/05/IMG_0042.jpg". Different files with the same name get a " (1)" suffix
+	LayoutByDate
+)
+
+// IngestResult describes what IngestFile did with a file.
+type IngestResult struct {
+	Path      string // Path of the file in the library, the existing copy for duplicates
+	Hash      string // Hex encoded SHA-256 of the file
+	Duplicate bool   // The library already held the same content, nothing was copied
+}
+
+// libraryIndex is the content of the index file of a library
+type libraryIndex struct {
+	Files map[string]libraryEntry `json:"files"`
+}
+
+// libraryEntry is a file of a library index
+type libraryEntry struct {
+	Path  string    `json:"path"` // Slash separated path relative to the library root
+	Size  int64     `json:"size"`
+	Added time.Time `json:"added"`
+}
+
+// IngestFile copies a file into a library, unless the library already holds a file with the same content.
+// The source file is left untouched. Index entries whose file was removed from the library are ignored,
+// so a removed file can be ingested again. The index updates are serialized within the process,
+// but the library must not be ingested into by several processes at once.
+//
+// Parameters:
+//   - src: The absolute or relative path to the file to import
+//   - libraryRoot: The absolute or relative path to the root of the library, created if needed
+//   - layout: Where new files are placed, LayoutByHash or LayoutByDate
+//
+// Returns:
+//   - *IngestResult: The path of the file in the library, its hash, and whether it was a duplicate
+//   - error: An error if the file couldn't be read or copied or the index couldn't be updated, nil otherwise
+//
+// Example:
+//
+//	for _, photo := range photos {
+//	    result, err := ufs.IngestFile(photo, "/srv/photos", ufs.LayoutByDate)
+//	    if err != nil {
+//	        fmt.Printf("Error importing %s: %v\n", photo, err)
+//	        continue
+//	    }
+//	    if result.Duplicate {
+//	        fmt.Printf("%s already imported as %s\n", photo, result.Path)
+//	    }
+//	}
+func (ufs *UFS) IngestFile(src, libraryRoot string, layout IngestLayout) (_ *IngestResult, err error) {
+	defer ufs.recoverPanic("IngestFile", &err)
+
+	if err := ufs.requireOS("IngestFile"); err != nil {
+		return nil, err
+	}
+	defer ufs.applyIOPriority()()
+
+	if layout != LayoutByHash && layout != LayoutByDate {
+		return nil, fmt.Errorf("IngestFile: unsupported layout %d", layout)
+	}
+	if !ufs.IsFile(src) {
+		return nil, ufs.misuseError("IngestFile", "path is not a file", src)
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, ufs.wrapError(err, "IngestFile")
+	}
+
+	sum, err := ufs.fileChecksum(src, HashSHA256)
+	if err != nil {
+		return nil, ufs.wrapError(err, "IngestFile")
+	}
+
+	ingestMu.Lock()
+	defer ingestMu.Unlock()
+
+	indexPath := filepath.Join(libraryRoot, libraryIndexName)
+	index := libraryIndex{}
+	if ufs.IsFile(indexPath) {
+		if err := ufs.ReadJSONFile(indexPath, &index); err != nil {
+			return nil, err
+		}
+	}
+	if index.Files == nil {
+		index.Files = map[string]libraryEntry{}
+	}
+
+	if entry, ok := index.Files[sum]; ok {
+		existing := filepath.Join(libraryRoot, filepath.FromSlash(entry.Path))
+		if ufs.IsFile(existing) {
+			return &IngestResult{Path: existing, Hash: sum, Duplicate: true}, nil
+		}
+	}
+
+	var target string
+	switch layout {
+	case LayoutByHash:
+		target = filepath.Join(libraryRoot, sum[:2], sum[2:4], sum+strings.ToLower(filepath.Ext(src)))
+	case LayoutByDate:
+		modTime := info.ModTime()
+		target = uniquePath(filepath.Join(libraryRoot, modTime.Format("2006"), modTime.Format("01"), filepath.Base(src)))
+	}
+
+	if err := ufs.CopyFile(src, target); err != nil {
+		return nil, err
+	}
+
+	rel, err := filepath.Rel(libraryRoot, target)
+	if err != nil {
+		return nil, ufs.wrapError(err, "IngestFile")
+	}
+	index.Files[sum] = libraryEntry{Path: filepath.ToSlash(rel), Size: info.Size(), Added: time.Now().UTC()}
+	if err := ufs.WriteJSONFile(indexPath, index, "  "); err != nil {
+		// An unindexed copy would be ingested again as a duplicate, remove it
+		os.Remove(target)
+		return nil, err
+	}
+
+	return &IngestResult{Path: target, Hash: sum}, nil
+}
@@ -0,0 +1,59 @@
+package ufs
+
+import "runtime"
+
+/*
+Io-priority.go contains the priority control used by heavy operations.
+
+Background jobs built with ufs (sync, backups, archiving) can set Options.IOPriority
+so their disk usage doesn't slow down interactive programs:
+- Linux: the I/O scheduling class of the threads doing the work (like ionice).
+- Windows: the thread background processing mode, lowering both I/O and CPU priority.
+- Other platforms: the setting is ignored.
+
+The priority is set on the OS threads running the operation only, and restored when it ends,
+so the rest of the program is not affected.
+
+It is applied by CompressDirectory, CompressFile, ExtractArchive, ExtractFiles,
+CopyDirectoryParallel, CompareDirectories and CompareDirectoryWithArchive
+(and their variants).
+*/
+
+// IOPriority is the priority given to the disk accesses of heavy operations.
+type IOPriority int
+
+const (
+	// IOPriorityNormal leaves the priority unchanged (default)
+	IOPriorityNormal IOPriority = iota
+	// IOPriorityLow uses the lowest best-effort priority (ionice -c2 -n7 on Linux)
+	IOPriorityLow
+	// IOPriorityIdle only uses the disk when nobody else does (ionice -c3 on Linux)
+	IOPriorityIdle
+)
+
+// applyIOPriority lowers the priority of the calling goroutine's thread for the duration of an operation.
+// The returned function restores it and must be deferred by the caller:
+//
+//	defer ufs.applyIOPriority()()
+//
+// Goroutines started by the operation must call it too, the priority is per thread.
+func (ufs *UFS) applyIOPriority() (restore func()) {
+	if ufs.opts.IOPriority == IOPriorityNormal {
+		return func() {}
+	}
+
+	// The priority belongs to the OS thread, keep the goroutine on it
+	runtime.LockOSThread()
+
+	restoreThread, err := setThreadIOPriority(ufs.opts.IOPriority)
+	if err != nil {
+		runtime.UnlockOSThread()
+		ufs.handleError(err, "IOPriority")
+		return func() {}
+	}
+
+	return func() {
+		restoreThread()
+		runtime.UnlockOSThread()
+	}
+}
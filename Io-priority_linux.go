@@ -0,0 +1,39 @@
+//go:build linux
+
+package ufs
+
+import "golang.org/x/sys/unix"
+
+// ioPrioritySupported reports whether setThreadIOPriority changes priorities on this platform
+const ioPrioritySupported = true
+
+// ioprio_set constants, see ioprio_set(2)
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+	ioprioClassBE    = 2
+	ioprioClassIdle  = 3
+)
+
+// setThreadIOPriority sets the I/O scheduling class of the current thread
+// and returns a function restoring the previous one
+func setThreadIOPriority(priority IOPriority) (func(), error) {
+	// who = 0 targets the calling thread
+	previous, _, errno := unix.Syscall(unix.SYS_IOPRIO_GET, ioprioWhoProcess, 0, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	value := uintptr(ioprioClassBE<<ioprioClassShift | 7)
+	if priority == IOPriorityIdle {
+		value = ioprioClassIdle << ioprioClassShift
+	}
+
+	if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, 0, value); errno != 0 {
+		return nil, errno
+	}
+
+	return func() {
+		unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, 0, previous)
+	}, nil
+}
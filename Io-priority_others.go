@@ -0,0 +1,11 @@
+//go:build !linux && !windows
+
+package ufs
+
+// ioPrioritySupported reports whether setThreadIOPriority changes priorities on this platform
+const ioPrioritySupported = false
+
+// setThreadIOPriority does nothing, I/O priorities are not supported on this platform
+func setThreadIOPriority(priority IOPriority) (func(), error) {
+	return func() {}, nil
+}
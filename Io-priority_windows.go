@@ -0,0 +1,36 @@
+//go:build windows
+
+package ufs
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// ioPrioritySupported reports whether setThreadIOPriority changes priorities on this platform
+const ioPrioritySupported = true
+
+// SetThreadPriority values enabling the background processing mode
+const (
+	threadModeBackgroundBegin = 0x00010000
+	threadModeBackgroundEnd   = 0x00020000
+)
+
+var procSetThreadPriority = windows.NewLazySystemDLL("kernel32.dll").NewProc("SetThreadPriority")
+
+// setThreadIOPriority puts the current thread in background processing mode,
+// which lowers its I/O and memory priority, and returns a function leaving it.
+// Windows has a single background mode, used for both IOPriorityLow and IOPriorityIdle.
+func setThreadIOPriority(priority IOPriority) (func(), error) {
+	thread, err := windows.GetCurrentThread()
+	if err != nil {
+		return nil, err
+	}
+
+	if ok, _, err := procSetThreadPriority.Call(uintptr(thread), threadModeBackgroundBegin); ok == 0 {
+		return nil, err
+	}
+
+	return func() {
+		procSetThreadPriority.Call(uintptr(thread), threadModeBackgroundEnd)
+	}, nil
+}
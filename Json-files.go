@@ -0,0 +1,152 @@
+package ufs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+/*
+Json-files.go contains functions to read and write JSON files into Go values, for typed configuration
+and state files.
+
+WriteJSONFile writes through WriteFileAtomic, so a crash while saving never leaves a truncated file
+behind, which would otherwise fail to parse on the next start.
+
+The YAML and TOML equivalents are in Yaml-files.go and Toml-files.go. They convert the documents
+through encoding/json, so the three formats map the same Go values with the same json struct tags.
+
+Functions:
+- ReadJSONFile: Reads a JSON file into a Go value.
+- WriteJSONFile: Atomically writes a Go value to a JSON file.
+*/
+
+// ReadJSONFile reads a JSON file and decodes it into v, like json.Unmarshal.
+//
+// Parameters:
+//   - path: The absolute or relative path to the JSON file
+//   - v: A pointer to the value to fill, e.g. a pointer to a struct or a map
+//
+// Returns:
+//   - error: An error if the file couldn't be read or isn't valid JSON for v, nil otherwise
+//
+// Example:
+//
+//	type Config struct {
+//	    Port  int      `json:"port"`
+//	    Hosts []string `json:"hosts"`
+//	}
+//	var config Config
+//	if err := ufs.ReadJSONFile("config.json", &config); err != nil {
+//	    fmt.Printf("Error reading config: %v\n", err)
+//	    return
+//	}
+func (ufs *UFS) ReadJSONFile(path string, v any) (err error) {
+	defer ufs.recoverPanic("ReadJSONFile", &err)
+
+	data, err := ufs.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("ReadJSONFile: invalid JSON in %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteJSONFile encodes v as JSON and writes it atomically to a file, creating it if it doesn't exist
+// or replacing it if it does. The file ends with a line break.
+// This function will create any parent directories if they don't exist.
+//
+// Parameters:
+//   - path: The absolute or relative path to the JSON file
+//   - v: The value to encode, like json.Marshal
+//   - indent: The indentation of nested values, e.g. "  " or "\t", "" writes compact JSON
+//
+// Returns:
+//   - error: An error if v can't be encoded or the file couldn't be written, nil otherwise
+//
+// Example:
+//
+//	config.Port = 9090
+//	if err := ufs.WriteJSONFile("config.json", config, "  "); err != nil {
+//	    fmt.Printf("Error saving config: %v\n", err)
+//	}
+func (ufs *UFS) WriteJSONFile(path string, v any, indent string) (err error) {
+	defer ufs.recoverPanic("WriteJSONFile", &err)
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", indent)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return ufs.wrapError(err, "WriteJSONFile")
+	}
+
+	return ufs.WriteFileAtomic(path, buf.Bytes())
+}
+
+// jsonField is a member of a JSON object decoded by decodeOrderedJSON
+type jsonField struct {
+	key   string
+	value any
+}
+
+// decodeOrderedJSON decodes a JSON document keeping the order of the members of the objects, for the
+// writers of other formats: objects are decoded as []jsonField, arrays as []any, numbers as json.Number
+func decodeOrderedJSON(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decodeOrderedJSONValue(decoder)
+}
+
+// decodeOrderedJSONValue decodes the next value of decoder, see decodeOrderedJSON
+func decodeOrderedJSONValue(decoder *json.Decoder) (any, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return token, nil
+	}
+
+	if delim == '[' {
+		items := []any{}
+		for decoder.More() {
+			item, err := decodeOrderedJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		_, err := decoder.Token()
+		return items, err
+	}
+
+	fields := []jsonField{}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		value, err := decodeOrderedJSONValue(decoder)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, jsonField{key: key.(string), value: value})
+	}
+	_, err = decoder.Token()
+	return fields, err
+}
+
+// decodeThroughJSON stores a document decoded by another format (maps, slices, strings, json.Number,
+// bools and nil) into v, like json.Unmarshal does
+func decodeThroughJSON(document any, v any) error {
+	data, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
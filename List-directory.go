@@ -0,0 +1,268 @@
+package ufs
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+)
+
+/*
+List-directory.go contains ListDirectory, a directory listing for user interfaces: where GetFileList
+and GetFolderList return bare names, it returns the metadata of the entries, filtered, sorted and
+cut into pages in a single call.
+
+	page, err := ufs.ListDirectory("/srv/uploads", ufs.ListOptions{
+	    SortBy:    ufs.ListByModTime,
+	    Desc:      true,
+	    FilesOnly: true,
+	    Pattern:   "*.{jpg,png}",
+	    Offset:    50,
+	    Limit:     50,
+	})
+
+The entries are sorted before Offset and Limit apply, so every page of a listing comes from the same
+order; the whole directory is read for every page. Hidden entries are left out with
+Options.ExcludeHidden. ListDirectoryFS lists the directories of an fs.FS the same way, e.g. those of an
+archive opened with OpenArchiveFS.
+
+Functions:
+- ListDirectory: Returns the metadata of the entries of a directory, filtered, sorted and paginated
+- ListDirectoryFS: ListDirectory for a directory of an fs.FS
+*/
+
+// ListOptions are the settings of ListDirectory and ListDirectoryFS.
+type ListOptions struct {
+	// SortBy is the order of the entries, ListByName by default, see Listing-order.go.
+	// Recursive listings sort by name on the path relative to the listed directory.
+	SortBy ListingOrder
+
+	// Desc reverses the order
+	Desc bool
+
+	// FilesOnly and DirsOnly list only files, or only directories
+	FilesOnly bool
+	DirsOnly  bool
+
+	// Pattern keeps the entries whose name matches it, or whose slash separated path relative to the
+	// listed directory matches it when it contains a "/", see Glob.go. Empty keeps every entry.
+	Pattern string
+
+	// Recursive lists the contents of the subdirectories too
+	Recursive bool
+
+	// Offset skips that many entries of the sorted listing, and Limit returns at most that many
+	// entries after them, 0 returning all of them
+	Offset int
+	Limit  int
+
+	// Extended fills the owner, creation time, inode and links of the entries, see GetExtendedMetadata.
+	// Unlike GetExtendedMetadata, symbolic links are not followed: every field of an entry describes the
+	// link itself, like the rest of the listing. It costs a lookup per entry of the page, and is ignored
+	// with a custom Options.Backend.
+	Extended bool
+}
+
+// ListDirectory returns the metadata of the entries of a directory, filtered, sorted and paginated
+// with the options, see List-directory.go.
+//
+// Parameters:
+//   - path: The absolute or relative path to the directory to list
+//   - opts: The filters, order and page of the listing
+//
+// Returns:
+//   - []FileMetadata: The entries of the page, their Path prefixed with path. Owner, CreatedAt, Inode,
+//     Device and Links are only set with ListOptions.Extended.
+//   - error: An error if path can't be read, or the options are invalid
+//
+// Example:
+//
+//	entries, err := ufs.ListDirectory("/home/me/Downloads", ufs.ListOptions{SortBy: ufs.ListBySize, Desc: true, Limit: 10})
+//	if err != nil {
+//	    fmt.Printf("Error listing downloads: %v\n", err)
+//	    return
+//	}
+//	for _, entry := range entries {
+//	    fmt.Printf("%-40s %d bytes\n", entry.Name, entry.Size)
+//	}
+func (ufs *UFS) ListDirectory(path string, opts ListOptions) (_ []FileMetadata, err error) {
+	defer ufs.recoverPanic("ListDirectory", &err)
+
+	// Both modes read the directory through the same walk, a listing being a walk of depth 1
+	walkOpts := WalkOptions{MaxDepth: 1}
+	if opts.Recursive {
+		walkOpts.MaxDepth = 0
+	}
+	walk := func(fn func(entryPath string, d fs.DirEntry) error) error {
+		return ufs.walk("ListDirectory", path, walkOpts, fn)
+	}
+	list, err := ufs.listDirectory("ListDirectory", path, opts, walk)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Extended && ufs.onOS() {
+		for i := range list {
+			// Like the rest of the listing, symbolic links describe themselves. Entries removed since the
+			// listing keep their basic metadata.
+			if meta, err := extendedMetadata(list[i].Path, false); err == nil {
+				list[i] = *meta
+			}
+		}
+	}
+	return list, nil
+}
+
+// ListDirectoryFS returns the metadata of the entries of a directory of an fs.FS, filtered, sorted and
+// paginated like ListDirectory does, see List-directory.go. ListOptions.Extended is ignored.
+//
+// Parameters:
+//   - fsys: The file system holding the directory, e.g. an *ArchiveFS or an embed.FS
+//   - root: The slash separated path to the directory to list in fsys, "." for its root
+//   - opts: The filters, order and page of the listing
+//
+// Returns:
+//   - []FileMetadata: The entries of the page, their Path being their slash separated path in fsys
+//   - error: An error if root can't be read, or the options are invalid
+//
+// Example:
+//
+//	archive, err := ufs.OpenArchiveFS("photos.zip")
+//	if err != nil {
+//	    fmt.Printf("Error opening archive: %v\n", err)
+//	    return
+//	}
+//	defer archive.Close()
+//	entries, err := ufs.ListDirectoryFS(archive, ".", ufs.ListOptions{Recursive: true, Pattern: "*.jpg", SortBy: ufs.ListBySize})
+//	for _, entry := range entries {
+//	    fmt.Printf("%-40s %d bytes\n", entry.Path, entry.Size)
+//	}
+func (ufs *UFS) ListDirectoryFS(fsys fs.FS, root string, opts ListOptions) (_ []FileMetadata, err error) {
+	defer ufs.recoverPanic("ListDirectoryFS", &err)
+
+	walk := func(fn func(entryPath string, d fs.DirEntry) error) error {
+		err := fs.WalkDir(fsys, root, func(entryPath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if entryPath == root {
+					return err
+				}
+				return ufs.decideWalkError(entryPath, err, WalkSkip, "ListDirectoryFS")
+			}
+			if entryPath != root && ufs.skipHidden(d) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if err := fn(entryPath, d); err != nil {
+				return err
+			}
+			if d.IsDir() && entryPath != root && !opts.Recursive {
+				return fs.SkipDir
+			}
+			return nil
+		})
+		return ufs.wrapError(err, "ListDirectoryFS")
+	}
+	return ufs.listDirectory("ListDirectoryFS", root, opts, walk)
+}
+
+// listDirectory lists root with the options, walk calling fn with root first, then with the entries of
+// root, and of its subdirectories when the listing is recursive, see ListDirectory
+func (ufs *UFS) listDirectory(operation, root string, opts ListOptions, walk func(fn func(entryPath string, d fs.DirEntry) error) error) ([]FileMetadata, error) {
+	if opts.FilesOnly && opts.DirsOnly {
+		return nil, fmt.Errorf("%s: FilesOnly and DirsOnly are exclusive", operation)
+	}
+	if opts.Offset < 0 || opts.Limit < 0 {
+		return nil, fmt.Errorf("%s: negative offset or limit", operation)
+	}
+	var pattern *globPattern
+	if opts.Pattern != "" {
+		var err error
+		if pattern, err = compileGlob(opts.Pattern); err != nil {
+			return nil, ufs.wrapError(err, operation)
+		}
+	}
+
+	var list []FileMetadata
+	err := walk(func(entryPath string, d fs.DirEntry) error {
+		if entryPath == root {
+			if !d.IsDir() {
+				return ufs.misuseError(operation, "not a directory", root)
+			}
+			return nil
+		}
+		if (opts.FilesOnly && d.IsDir()) || (opts.DirsOnly && !d.IsDir()) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, entryPath)
+		if err != nil {
+			return err
+		}
+		if pattern != nil && !pattern.matchName(filepath.ToSlash(rel)) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			// Removed since the listing
+			return ufs.decideWalkError(entryPath, err, WalkSkip, operation)
+		}
+		list = append(list, FileMetadata{
+			Path:    entryPath,
+			Name:    info.Name(),
+			Size:    info.Size(),
+			Mode:    info.Mode(),
+			ModTime: info.ModTime(),
+			IsDir:   info.IsDir(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortMetadata(list, root, opts.SortBy, opts.Desc)
+
+	if opts.Offset >= len(list) {
+		return []FileMetadata{}, nil
+	}
+	list = list[opts.Offset:]
+	if opts.Limit > 0 && opts.Limit < len(list) {
+		list = list[:opts.Limit]
+	}
+	return list, nil
+}
+
+// sortMetadata sorts a listing of root in the given order, the names being compared on their path
+// relative to root. Entries comparing equal are ordered by name, like in readDirOrdered.
+func sortMetadata(list []FileMetadata, root string, order ListingOrder, desc bool) {
+	if order == ListUnsorted {
+		return
+	}
+	key := func(i int) string {
+		if rel, err := filepath.Rel(root, list[i].Path); err == nil {
+			return filepath.ToSlash(rel)
+		}
+		return list[i].Name
+	}
+	less := func(i, j int) bool {
+		switch order {
+		case ListNatural:
+			return naturalLess(key(i), key(j))
+		case ListBySize:
+			if list[i].Size != list[j].Size {
+				return list[i].Size < list[j].Size
+			}
+		case ListByModTime:
+			if !list[i].ModTime.Equal(list[j].ModTime) {
+				return list[i].ModTime.Before(list[j].ModTime)
+			}
+		}
+		return key(i) < key(j)
+	}
+	if desc {
+		sort.SliceStable(list, func(i, j int) bool { return less(j, i) })
+	} else {
+		sort.SliceStable(list, less)
+	}
+}
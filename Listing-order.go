@@ -0,0 +1,166 @@
+package ufs
+
+import (
+	"io/fs"
+	"os"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+Listing-order.go contains the order of the names returned by GetFileList and GetFolderList, set with
+Options.ListingOrder:
+- ListByName: byte order of the names, "B.txt" before "a.txt" and "file10" before "file2" (default)
+- ListNatural: the order of file managers, ignoring case and comparing numbers by value, so
+  "file2" comes before "file10"
+- ListByModTime: oldest first
+- ListBySize: smallest first
+- ListUnsorted: the order of the directory on disk, which differs between file systems, without the
+  cost of sorting, for large directories whose order doesn't matter
+
+Options.ListingDescending reverses the order. Entries comparing equal (same size, same time, natural
+names differing by case only) are ordered by name, so a listing is the same on every platform:
+
+	files := ufs.NewUfs(&ufs.Options{ListingOrder: ufs.ListByModTime, ListingDescending: true}).GetFileList("inbox")
+*/
+
+// ListingOrder is the order of the names returned by GetFileList and GetFolderList, see Listing-order.go.
+type ListingOrder int
+
+const (
+	// ListByName sorts the names in byte order, the default
+	ListByName ListingOrder = iota
+	// ListNatural sorts the names ignoring case, with numbers compared by value
+	ListNatural
+	// ListByModTime sorts the entries by modification time, oldest first
+	ListByModTime
+	// ListBySize sorts the entries by size, smallest first
+	ListBySize
+	// ListUnsorted keeps the order of the directory on disk
+	ListUnsorted
+)
+
+// readDirOrdered lists a directory in the order of Options.ListingOrder
+func (ufs *UFS) readDirOrdered(path string) ([]fs.DirEntry, error) {
+	order := ufs.opts.ListingOrder
+	if order == ListUnsorted && ufs.onOS() {
+		dir, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer dir.Close()
+		return dir.ReadDir(-1)
+	}
+
+	// Sorted by name
+	entries, err := ufs.backend().ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	ufs.orderEntries(entries)
+	return entries, nil
+}
+
+// orderEntries sorts entries listed by name in the order of Options.ListingOrder
+func (ufs *UFS) orderEntries(entries []fs.DirEntry) {
+	order := ufs.opts.ListingOrder
+	switch order {
+	case ListNatural:
+		sort.SliceStable(entries, func(i, j int) bool {
+			return naturalLess(entries[i].Name(), entries[j].Name())
+		})
+	case ListByModTime, ListBySize:
+		infos := make([]fs.FileInfo, len(entries))
+		for i, entry := range entries {
+			// Entries removed since the listing sort first
+			infos[i], _ = entry.Info()
+		}
+		sort.Stable(&entriesByInfo{entries: entries, infos: infos, bySize: order == ListBySize})
+	}
+
+	if ufs.opts.ListingDescending && order != ListUnsorted {
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+	}
+}
+
+// entriesByInfo sorts entries by the size or modification time of their infos, kept side by side
+type entriesByInfo struct {
+	entries []fs.DirEntry
+	infos   []fs.FileInfo
+	bySize  bool
+}
+
+func (s *entriesByInfo) Len() int {
+	return len(s.entries)
+}
+
+func (s *entriesByInfo) Less(i, j int) bool {
+	a, b := s.infos[i], s.infos[j]
+	switch {
+	case a == nil || b == nil:
+		return a == nil && b != nil
+	case s.bySize:
+		return a.Size() < b.Size()
+	default:
+		return a.ModTime().Before(b.ModTime())
+	}
+}
+
+func (s *entriesByInfo) Swap(i, j int) {
+	s.entries[i], s.entries[j] = s.entries[j], s.entries[i]
+	s.infos[i], s.infos[j] = s.infos[j], s.infos[i]
+}
+
+// naturalLess reports whether a sorts before b in natural order: case is ignored and runs of digits
+// are compared by value. Names equal in natural order are compared byte by byte.
+func naturalLess(a, b string) bool {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			// Compare the numbers without their leading zeros: longer is bigger, then digit by digit
+			startA, startB := i, j
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			numA, numB := trimZeros(a[startA:i]), trimZeros(b[startB:j])
+			if len(numA) != len(numB) {
+				return len(numA) < len(numB)
+			}
+			if numA != numB {
+				return numA < numB
+			}
+			continue
+		}
+
+		runeA, sizeA := utf8.DecodeRuneInString(a[i:])
+		runeB, sizeB := utf8.DecodeRuneInString(b[j:])
+		if foldA, foldB := unicode.ToLower(runeA), unicode.ToLower(runeB); foldA != foldB {
+			return foldA < foldB
+		}
+		i += sizeA
+		j += sizeB
+	}
+	if remainingA, remainingB := len(a)-i, len(b)-j; remainingA != remainingB {
+		return remainingA < remainingB
+	}
+	return a < b
+}
+
+// isDigit reports whether c is an ASCII digit
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// trimZeros removes the leading zeros of a number, keeping one digit
+func trimZeros(digits string) string {
+	for len(digits) > 1 && digits[0] == '0' {
+		digits = digits[1:]
+	}
+	return digits
+}
@@ -0,0 +1,315 @@
+package ufs
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Mac-metadata.go contains the macOS metadata kept outside the content of files: the resource fork
+(custom icons, fonts, documents of older applications) and the Finder info (type and creator codes,
+color label and other Finder flags). They are lost by plain copies and zip archives.
+
+With Options.PreserveMacMetadata, on macOS:
+- CopyFile, CopyFileWithPermissions and the moves across file systems copy them.
+- CompressDirectory and CompressFile store them as AppleDouble entries, "__MACOSX/<dir>/._<name>",
+  like the Finder does, so archives made by either can be exchanged.
+- ExtractArchive applies the AppleDouble entries to the extracted files instead of writing them.
+
+On other systems the option changes nothing: the AppleDouble entries are extracted as files, as before,
+and ReadMacMetadata and WriteMacMetadata fail with an error wrapping errors.ErrUnsupported.
+
+	designer := ufs.NewUfs(&ufs.Options{PreserveMacMetadata: true})
+	designer.CompressDirectory("/Users/me/Fonts", "/Volumes/Share/fonts.zip")
+
+Functions:
+- ReadMacMetadata: Reads the resource fork and Finder info of a file
+- WriteMacMetadata: Sets the resource fork and Finder info of a file
+*/
+
+// MacMetadata is the macOS metadata of a file kept outside its content, see Mac-metadata.go.
+type MacMetadata struct {
+	// FinderInfo holds the type and creator codes and the Finder flags, 32 bytes, nil when not set
+	FinderInfo []byte
+	// ResourceFork is the content of the resource fork, nil when empty
+	ResourceFork []byte
+}
+
+// finderInfoSize is the size of the Finder info
+const finderInfoSize = 32
+
+// empty reports whether there is no metadata to keep, an all zero Finder info counts as none
+func (meta *MacMetadata) empty() bool {
+	if meta == nil {
+		return true
+	}
+	if len(meta.ResourceFork) > 0 {
+		return false
+	}
+	for _, b := range meta.FinderInfo {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadMacMetadata reads the resource fork and Finder info of a file. Only supported on macOS.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file
+//
+// Returns:
+//   - *MacMetadata: The metadata, with nil fields for what the file doesn't have
+//   - error: An error if the file couldn't be read, wrapping errors.ErrUnsupported on other systems
+//
+// Example:
+//
+//	meta, err := ufs.ReadMacMetadata("/Users/me/Fonts/Chicago")
+//	if err == nil && len(meta.ResourceFork) > 0 {
+//	    fmt.Printf("Resource fork of %d bytes\n", len(meta.ResourceFork))
+//	}
+func (ufs *UFS) ReadMacMetadata(path string) (_ *MacMetadata, err error) {
+	defer ufs.recoverPanic("ReadMacMetadata", &err)
+
+	meta, err := readMacMetadata(path)
+	if err != nil {
+		return nil, ufs.wrapError(err, "ReadMacMetadata")
+	}
+	return meta, nil
+}
+
+// WriteMacMetadata sets the resource fork and Finder info of a file. nil fields are left unchanged.
+// Only supported on macOS.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file
+//   - meta: The metadata to set, FinderInfo must be 32 bytes long when set
+//
+// Returns:
+//   - error: An error if the metadata couldn't be set, wrapping errors.ErrUnsupported on other systems
+//
+// Example:
+//
+//	meta, _ := ufs.ReadMacMetadata("original.psd")
+//	err := ufs.WriteMacMetadata("restored.psd", meta)
+func (ufs *UFS) WriteMacMetadata(path string, meta *MacMetadata) (err error) {
+	defer ufs.recoverPanic("WriteMacMetadata", &err)
+
+	if meta == nil {
+		return nil
+	}
+	if meta.FinderInfo != nil && len(meta.FinderInfo) != finderInfoSize {
+		return fmt.Errorf("WriteMacMetadata: Finder info must be %d bytes, got %d", finderInfoSize, len(meta.FinderInfo))
+	}
+	if err := writeMacMetadata(path, meta); err != nil {
+		return ufs.wrapError(err, "WriteMacMetadata")
+	}
+	return nil
+}
+
+// preservesMacMetadata reports whether the copies and archives of the instance keep the macOS metadata
+func (ufs *UFS) preservesMacMetadata() bool {
+	return ufs.opts.PreserveMacMetadata && macMetadataSupported && ufs.onOS()
+}
+
+// copyMacMetadata copies the macOS metadata of src to dst when the instance preserves it
+func (ufs *UFS) copyMacMetadata(src, dst string) error {
+	if !ufs.preservesMacMetadata() {
+		return nil
+	}
+	meta, err := readMacMetadata(src)
+	if err != nil || meta.empty() {
+		return err
+	}
+	return writeMacMetadata(dst, meta)
+}
+
+// macMetadataEntry returns the AppleDouble entry holding the macOS metadata of a file about to be
+// archived under name, nil when the instance doesn't preserve it or the file has none
+func (ufs *UFS) macMetadataEntry(filePath, name string) (*zipMetadataEntry, error) {
+	if !ufs.preservesMacMetadata() {
+		return nil, nil
+	}
+	meta, err := readMacMetadata(filePath)
+	if err != nil || meta.empty() {
+		return nil, err
+	}
+	return &zipMetadataEntry{name: appleDoubleName(name), data: encodeAppleDouble(meta)}, nil
+}
+
+// writeMacMetadataEntries writes the AppleDouble entries after the files of an archive
+func writeMacMetadataEntries(zipWriter *zip.Writer, entries []*zipMetadataEntry) error {
+	for _, entry := range entries {
+		writer, err := zipWriter.CreateHeader(&zip.FileHeader{Name: entry.name, Method: zip.Deflate})
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(entry.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isMacMetadataEntry reports whether an archive entry is an AppleDouble entry the extraction applies
+// instead of writing, only when the instance preserves the macOS metadata
+func (ufs *UFS) isMacMetadataEntry(name string) bool {
+	if !ufs.preservesMacMetadata() {
+		return false
+	}
+	_, ok := appleDoubleTarget(name)
+	return ok
+}
+
+// applyMacMetadataEntries sets the metadata of the AppleDouble entries on the extracted files.
+// extracted maps the archive names of the extracted entries to the names written under destPath,
+// entries describing files that weren't extracted are ignored. Entries that can't be applied are
+// skipped unless Options.OnWalkError aborts.
+func (ufs *UFS) applyMacMetadataEntries(entries []*zip.File, extracted map[string]string, destPath, operation string) error {
+	for _, file := range entries {
+		target, _ := appleDoubleTarget(file.Name)
+		name, ok := extracted[target]
+		if !ok {
+			continue
+		}
+		targetPath := filepath.Join(destPath, name)
+		if err := applyAppleDouble(file, targetPath); err != nil {
+			if err := ufs.decideWalkError(targetPath, fmt.Errorf("%s: %w", file.Name, err), WalkSkip, operation); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// zipMetadataEntry is an AppleDouble entry written after the files of an archive
+type zipMetadataEntry struct {
+	name string
+	data []byte
+}
+
+// appleDoubleName returns the name of the AppleDouble entry of an archive entry, "dir/file" gives "__MACOSX/dir/._file"
+func appleDoubleName(name string) string {
+	dir, base := path.Split(strings.TrimPrefix(name, "/"))
+	return "__MACOSX/" + dir + "._" + base
+}
+
+// appleDoubleTarget returns the name of the archive entry an AppleDouble entry describes,
+// ok = false when the entry isn't an AppleDouble entry
+func appleDoubleTarget(name string) (string, bool) {
+	name = strings.TrimPrefix(name, "./")
+	rest, found := strings.CutPrefix(name, "__MACOSX/")
+	dir, base := path.Split(rest)
+	if !found || !strings.HasPrefix(base, "._") || base == "._" {
+		return "", false
+	}
+	return dir + strings.TrimPrefix(base, "._"), true
+}
+
+// applyAppleDouble sets the metadata stored in an AppleDouble archive entry on an extracted file
+func applyAppleDouble(file *zip.File, target string) error {
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	meta, err := decodeAppleDouble(data)
+	if err != nil || meta.empty() {
+		return err
+	}
+	return writeMacMetadata(target, meta)
+}
+
+// AppleDouble format (RFC 1740): a header, a table of entries, then their data
+const (
+	appleDoubleMagic        = 0x00051607
+	appleDoubleVersion      = 0x00020000
+	appleDoubleHeaderSize   = 26 // magic, version, filler and entry count
+	appleDoubleEntrySize    = 12 // id, offset and length
+	appleDoubleResourceFork = 2
+	appleDoubleFinderInfo   = 9
+)
+
+// appleDoubleFiller is the filler of the header written by macOS
+const appleDoubleFiller = "Mac OS X        "
+
+// errInvalidAppleDouble is returned for AppleDouble data that can't be decoded
+var errInvalidAppleDouble = errors.New("invalid AppleDouble data")
+
+// encodeAppleDouble encodes metadata in the AppleDouble format, the Finder info first like macOS does
+func encodeAppleDouble(meta *MacMetadata) []byte {
+	type entry struct {
+		id   uint32
+		data []byte
+	}
+	var entries []entry
+	if meta.FinderInfo != nil {
+		entries = append(entries, entry{appleDoubleFinderInfo, meta.FinderInfo})
+	}
+	if meta.ResourceFork != nil {
+		entries = append(entries, entry{appleDoubleResourceFork, meta.ResourceFork})
+	}
+
+	data := binary.BigEndian.AppendUint32(nil, appleDoubleMagic)
+	data = binary.BigEndian.AppendUint32(data, appleDoubleVersion)
+	data = append(data, appleDoubleFiller...)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(entries)))
+	offset := appleDoubleHeaderSize + appleDoubleEntrySize*len(entries)
+	for _, e := range entries {
+		data = binary.BigEndian.AppendUint32(data, e.id)
+		data = binary.BigEndian.AppendUint32(data, uint32(offset))
+		data = binary.BigEndian.AppendUint32(data, uint32(len(e.data)))
+		offset += len(e.data)
+	}
+	for _, e := range entries {
+		data = append(data, e.data...)
+	}
+	return data
+}
+
+// decodeAppleDouble decodes the Finder info and resource fork of AppleDouble data. macOS stores the
+// extended attributes after the 32 bytes of the Finder info, they are ignored.
+func decodeAppleDouble(data []byte) (*MacMetadata, error) {
+	if len(data) < appleDoubleHeaderSize || binary.BigEndian.Uint32(data) != appleDoubleMagic {
+		return nil, errInvalidAppleDouble
+	}
+	count := int(binary.BigEndian.Uint16(data[24:]))
+	if len(data) < appleDoubleHeaderSize+appleDoubleEntrySize*count {
+		return nil, errInvalidAppleDouble
+	}
+
+	meta := &MacMetadata{}
+	for i := 0; i < count; i++ {
+		table := data[appleDoubleHeaderSize+appleDoubleEntrySize*i:]
+		id := binary.BigEndian.Uint32(table)
+		offset, length := uint64(binary.BigEndian.Uint32(table[4:])), uint64(binary.BigEndian.Uint32(table[8:]))
+		if offset+length > uint64(len(data)) {
+			return nil, errInvalidAppleDouble
+		}
+		content := data[offset : offset+length]
+
+		switch id {
+		case appleDoubleFinderInfo:
+			if len(content) < finderInfoSize {
+				return nil, errInvalidAppleDouble
+			}
+			meta.FinderInfo = append([]byte{}, content[:finderInfoSize]...)
+		case appleDoubleResourceFork:
+			if len(content) > 0 {
+				meta.ResourceFork = append([]byte{}, content...)
+			}
+		}
+	}
+	return meta, nil
+}
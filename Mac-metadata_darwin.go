@@ -0,0 +1,69 @@
+//go:build darwin
+
+package ufs
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// macMetadataSupported reports whether the files have a resource fork and Finder info
+const macMetadataSupported = true
+
+// Extended attributes holding the metadata
+const (
+	xattrFinderInfo   = "com.apple.FinderInfo"
+	xattrResourceFork = "com.apple.ResourceFork"
+)
+
+// readMacMetadata reads the resource fork and Finder info of a file from its extended attributes
+func readMacMetadata(path string) (*MacMetadata, error) {
+	finderInfo, err := getXattr(path, xattrFinderInfo)
+	if err != nil {
+		return nil, err
+	}
+	resourceFork, err := getXattr(path, xattrResourceFork)
+	if err != nil {
+		return nil, err
+	}
+	return &MacMetadata{FinderInfo: finderInfo, ResourceFork: resourceFork}, nil
+}
+
+// writeMacMetadata sets the resource fork and Finder info of a file, nil fields are left unchanged
+func writeMacMetadata(path string, meta *MacMetadata) error {
+	if meta.FinderInfo != nil {
+		if err := unix.Setxattr(path, xattrFinderInfo, meta.FinderInfo, 0); err != nil {
+			return &os.PathError{Op: "setxattr", Path: path, Err: err}
+		}
+	}
+	if meta.ResourceFork != nil {
+		if err := unix.Setxattr(path, xattrResourceFork, meta.ResourceFork, 0); err != nil {
+			return &os.PathError{Op: "setxattr", Path: path, Err: err}
+		}
+	}
+	return nil
+}
+
+// getXattr returns the value of an extended attribute, nil when the file doesn't have it
+func getXattr(path, name string) ([]byte, error) {
+	for {
+		size, err := unix.Getxattr(path, name, nil)
+		if err == nil && size > 0 {
+			value := make([]byte, size)
+			size, err = unix.Getxattr(path, name, value)
+			if errors.Is(err, unix.ERANGE) {
+				// Grown since its size was read
+				continue
+			}
+			if err == nil {
+				return value[:size], nil
+			}
+		}
+		if err == nil || errors.Is(err, unix.ENOATTR) {
+			return nil, nil
+		}
+		return nil, &os.PathError{Op: "getxattr", Path: path, Err: err}
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !darwin
+
+package ufs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// macMetadataSupported reports whether the files have a resource fork and Finder info
+const macMetadataSupported = false
+
+// readMacMetadata fails, only macOS files have a resource fork and Finder info
+func readMacMetadata(path string) (*MacMetadata, error) {
+	return nil, fmt.Errorf("macOS metadata of %s: %w", path, errors.ErrUnsupported)
+}
+
+// writeMacMetadata fails, only macOS files have a resource fork and Finder info
+func writeMacMetadata(path string, meta *MacMetadata) error {
+	return fmt.Errorf("macOS metadata of %s: %w", path, errors.ErrUnsupported)
+}
@@ -0,0 +1,243 @@
+package ufs
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+Merge-lines.go contains functions to merge line-based configuration files (.env, .ini, .gitignore, ...)
+instead of overwriting them.
+
+Three strategies are available:
+- MergeUnion: the lines of base, then the lines of incoming that base doesn't have.
+- MergeUnique: like MergeUnion, and every non-blank line is kept only once.
+- MergeOrdered: key aware, for KEY=value files. The order of base is kept, new keys of incoming are
+  inserted after the key preceding them in incoming, in the same [section] for INI files, and keys
+  with different values are written with conflict markers:
+
+	<<<<<<< base
+	PORT=8080
+	=======
+	PORT=9090
+	>>>>>>> incoming
+
+Functions:
+- MergeFileLines: Merges two line-based files into a destination file.
+*/
+
+// MergeStrategy decides how MergeFileLines combines the lines of two files.
+type MergeStrategy int
+
+const (
+	// MergeUnion keeps the lines of base and appends the lines of incoming missing from base
+	MergeUnion MergeStrategy = iota
+	// MergeUnique is MergeUnion without duplicated lines, blank lines excepted
+	MergeUnique
+	// MergeOrdered merges KEY=value lines by key, marking keys with different values as conflicts
+	MergeOrdered
+)
+
+// Conflict markers written by MergeOrdered
+const (
+	mergeMarkerBase     = "<<<<<<< base"
+	mergeMarkerSplit    = "======="
+	mergeMarkerIncoming = ">>>>>>> incoming"
+)
+
+// mergeLine is a line of a file merged by MergeOrdered
+type mergeLine struct {
+	text    string
+	section string // INI section the line belongs to, "" before the first section
+	key     string // Key of KEY=value lines, "" for other lines
+	value   string
+}
+
+// MergeFileLines merges the lines of incoming into base and writes the result to dst.
+// dst can be base itself to merge in place.
+// This function will create any parent directories of dst if they don't exist.
+//
+// Parameters:
+//   - base: The absolute or relative path to the current file, whose order is kept
+//   - incoming: The absolute or relative path to the file with the lines to merge in
+//   - dst: The absolute or relative path to the file to write
+//   - strategy: MergeUnion, MergeUnique or MergeOrdered
+//
+// Returns:
+//   - int: The number of conflicts written with conflict markers (only with MergeOrdered)
+//   - error: An error if a file couldn't be read or written, nil otherwise
+//
+// Example:
+//
+//	// Add the new settings of the template to the user's .env, without touching their values
+//	conflicts, err := ufs.MergeFileLines(".env", ".env.example", ".env", ufs.MergeOrdered)
+//	if err != nil {
+//	    fmt.Printf("Error merging .env: %v\n", err)
+//	    return
+//	}
+//	if conflicts > 0 {
+//	    fmt.Printf("%d settings differ, see the conflict markers in .env\n", conflicts)
+//	}
+func (ufs *UFS) MergeFileLines(base, incoming, dst string, strategy MergeStrategy) (_ int, err error) {
+	defer ufs.recoverPanic("MergeFileLines", &err)
+
+	baseLines, err := ufs.ReadFileWithLines(base)
+	if err != nil {
+		return 0, ufs.wrapError(err, "MergeFileLines")
+	}
+	incomingLines, err := ufs.ReadFileWithLines(incoming)
+	if err != nil {
+		return 0, ufs.wrapError(err, "MergeFileLines")
+	}
+
+	var merged []string
+	conflicts := 0
+
+	switch strategy {
+	case MergeUnion:
+		merged = mergeUnion(baseLines, incomingLines, false)
+	case MergeUnique:
+		merged = mergeUnion(baseLines, incomingLines, true)
+	case MergeOrdered:
+		merged, conflicts = mergeOrdered(parseMergeLines(baseLines), parseMergeLines(incomingLines))
+	default:
+		return 0, fmt.Errorf("MergeFileLines: unsupported merge strategy %d", strategy)
+	}
+
+	content := strings.Join(merged, "\n")
+	if len(merged) > 0 {
+		content += "\n"
+	}
+	if err := ufs.WriteStringToFile(dst, content); err != nil {
+		return 0, ufs.wrapError(err, "MergeFileLines")
+	}
+	return conflicts, nil
+}
+
+// mergeUnion appends the lines of incoming missing from base, removing all duplicates when unique is set
+func mergeUnion(base, incoming []string, unique bool) []string {
+	seen := make(map[string]bool, len(base))
+	merged := make([]string, 0, len(base)+len(incoming))
+
+	for _, line := range base {
+		if unique && seen[line] && strings.TrimSpace(line) != "" {
+			continue
+		}
+		seen[line] = true
+		merged = append(merged, line)
+	}
+	for _, line := range incoming {
+		if seen[line] || strings.TrimSpace(line) == "" {
+			continue
+		}
+		seen[line] = true
+		merged = append(merged, line)
+	}
+	return merged
+}
+
+// parseMergeLines finds the section, key and value of every line
+func parseMergeLines(lines []string) []mergeLine {
+	parsed := make([]mergeLine, len(lines))
+	section := ""
+
+	for i, text := range lines {
+		parsed[i] = mergeLine{text: text, section: section}
+		trimmed := strings.TrimSpace(text)
+
+		switch {
+		case strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"):
+			section = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			parsed[i].section = section
+		case trimmed == "", strings.HasPrefix(trimmed, "#"), strings.HasPrefix(trimmed, ";"):
+			// Comment or blank line
+		default:
+			key, value, ok := strings.Cut(trimmed, "=")
+			if !ok {
+				continue
+			}
+			key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+			parsed[i].key = key
+			parsed[i].value = strings.TrimSpace(value)
+		}
+	}
+	return parsed
+}
+
+// mergeOrdered merges KEY=value lines by key and returns the merged lines and the number of conflicts
+func mergeOrdered(base, incoming []mergeLine) ([]string, int) {
+	type keyID struct{ section, key string }
+
+	baseKeys := map[keyID]int{}  // key -> index in base
+	sections := map[string]int{} // section -> index of its header in base
+	lastGlobal := -1             // index of the last non-blank line before the first section
+	for i, line := range base {
+		if line.key != "" {
+			baseKeys[keyID{line.section, line.key}] = i
+		}
+		if _, ok := sections[line.section]; !ok && line.section != "" {
+			sections[line.section] = i
+		}
+		if line.section == "" && strings.TrimSpace(line.text) != "" {
+			lastGlobal = i
+		}
+	}
+
+	// Find where every new key of incoming goes: after the previous key of incoming known by base
+	insertAfter := map[int][]string{}
+	var appended []string
+	newSections := map[string]bool{}
+	conflicting := map[int]string{} // index in base -> incoming line
+	anchor, anchorSection := 0, ""
+	hasAnchor := false
+
+	for _, line := range incoming {
+		if line.key == "" {
+			continue
+		}
+		if line.section != anchorSection {
+			hasAnchor, anchorSection = false, line.section
+		}
+
+		id := keyID{line.section, line.key}
+		if index, ok := baseKeys[id]; ok {
+			if base[index].value != line.value {
+				conflicting[index] = line.text
+			}
+			anchor, hasAnchor = index, true
+			continue
+		}
+
+		switch header, ok := sections[line.section]; {
+		case hasAnchor:
+			insertAfter[anchor] = append(insertAfter[anchor], line.text)
+		case line.section == "":
+			insertAfter[lastGlobal] = append(insertAfter[lastGlobal], line.text)
+		case ok:
+			insertAfter[header] = append(insertAfter[header], line.text)
+		default:
+			if !newSections[line.section] {
+				newSections[line.section] = true
+				appended = append(appended, "", "["+line.section+"]")
+			}
+			appended = append(appended, line.text)
+		}
+	}
+
+	merged := make([]string, 0, len(base)+len(incoming))
+	merged = append(merged, insertAfter[-1]...)
+	for i, line := range base {
+		if incomingText, ok := conflicting[i]; ok {
+			merged = append(merged, mergeMarkerBase, line.text, mergeMarkerSplit, incomingText, mergeMarkerIncoming)
+		} else {
+			merged = append(merged, line.text)
+		}
+		merged = append(merged, insertAfter[i]...)
+	}
+	if len(merged) == 0 && len(appended) > 0 {
+		appended = appended[1:] // No blank line before the first section of an empty file
+	}
+	merged = append(merged, appended...)
+
+	return merged, len(conflicting)
+}
@@ -3,6 +3,7 @@ package ufs
 import (
 	"os"
 	"path/filepath"
+	"sync/atomic"
 )
 
 // GetFileSize returns the size of the given file in bytes.
@@ -20,13 +21,15 @@ import (
 //	size := ufs.GetFileSize("/path/to/file.txt")
 //	fmt.Printf("File size: %d bytes\n", size)
 func (ufs *UFS) GetFileSize(path string) int64 {
-	info, err := os.Stat(path)
+	defer ufs.recoverPanicReport("GetFileSize")
+
+	info, err := ufs.backend().Stat(path)
 	if err != nil {
 		ufs.handleError(err, "GetFileSize")
 		return 0
 	}
 	if info.IsDir() {
-		ufs.handleMistakeWarning("GetFileSize called on a directory, returning 0")
+		ufs.reportMisuse("GetFileSize", "Path is a directory, returning 0", path)
 		return 0
 	}
 	return info.Size()
@@ -35,6 +38,7 @@ func (ufs *UFS) GetFileSize(path string) int64 {
 // GetFileMetadata retrieves basic metadata for a file at the specified path.
 // This function collects essential information about a file including name, size,
 // permissions, modification time, and whether it's a directory.
+// GetExtendedMetadata also returns its owner, inode, number of links and creation time.
 //
 // Parameters:
 //   - path: The absolute or relative path to the file
@@ -54,7 +58,9 @@ func (ufs *UFS) GetFileSize(path string) int64 {
 //	fmt.Printf("File name: %s\n", metadata["Name"])
 //	fmt.Printf("Last modified: %s\n", metadata["ModTime"])
 func (ufs *UFS) GetFileMetadata(path string) map[string]interface{} {
-	info, err := os.Stat(path)
+	defer ufs.recoverPanicReport("GetFileMetadata")
+
+	info, err := ufs.backend().Stat(path)
 	if err != nil {
 		ufs.handleError(err, "GetFileMetadata")
 		return nil
@@ -72,6 +78,8 @@ func (ufs *UFS) GetFileMetadata(path string) map[string]interface{} {
 
 // GetFileList returns a list of file names under the given path (non-recursive).
 // This function lists only files (not directories) in the specified directory.
+// Hidden files are left out when Options.ExcludeHidden is set.
+// The names are sorted in byte order, Options.ListingOrder changes it (see Listing-order.go).
 //
 // Parameters:
 //   - path: The absolute or relative path to the directory to list files from
@@ -87,14 +95,16 @@ func (ufs *UFS) GetFileMetadata(path string) map[string]interface{} {
 //	    fmt.Printf("Found file: %s\n", file)
 //	}
 func (ufs *UFS) GetFileList(path string) []string {
+	defer ufs.recoverPanicReport("GetFileList")
+
 	var files []string
-	entries, err := os.ReadDir(path)
+	entries, err := ufs.readDirOrdered(path)
 	if err != nil {
 		ufs.handleError(err, "GetFileList")
 		return []string{}
 	}
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		if !entry.IsDir() && !ufs.skipHidden(entry) {
 			files = append(files, entry.Name())
 		}
 	}
@@ -103,6 +113,8 @@ func (ufs *UFS) GetFileList(path string) []string {
 
 // GetFolderList returns a list of folder names under the given path.
 // This function lists only directories (not files) in the specified directory.
+// Hidden directories are left out when Options.ExcludeHidden is set.
+// The names are sorted in byte order, Options.ListingOrder changes it (see Listing-order.go).
 //
 // Parameters:
 //   - path: The absolute or relative path to the directory to list folders from
@@ -118,14 +130,16 @@ func (ufs *UFS) GetFileList(path string) []string {
 //	    fmt.Printf("Found subdirectory: %s\n", folder)
 //	}
 func (ufs *UFS) GetFolderList(path string) []string {
+	defer ufs.recoverPanicReport("GetFolderList")
+
 	var folders []string
-	entries, err := os.ReadDir(path)
+	entries, err := ufs.readDirOrdered(path)
 	if err != nil {
 		ufs.handleError(err, "GetFolderList")
 		return []string{}
 	}
 	for _, entry := range entries {
-		if entry.IsDir() {
+		if entry.IsDir() && !ufs.skipHidden(entry) {
 			folders = append(folders, entry.Name())
 		}
 	}
@@ -134,6 +148,7 @@ func (ufs *UFS) GetFolderList(path string) []string {
 
 // GetFolderFileCount returns the number of files (not directories) in the specified directory.
 // This function counts only files at the top level and doesn't recurse into subdirectories.
+// Hidden files are not counted when Options.ExcludeHidden is set.
 //
 // Parameters:
 //   - path: The absolute or relative path to the directory to count files in
@@ -147,14 +162,16 @@ func (ufs *UFS) GetFolderList(path string) []string {
 //	count := ufs.GetFolderFileCount("/path/to/directory")
 //	fmt.Printf("Directory contains %d files\n", count)
 func (ufs *UFS) GetFolderFileCount(path string) int {
-	entries, err := os.ReadDir(path)
+	defer ufs.recoverPanicReport("GetFolderFileCount")
+
+	entries, err := ufs.backend().ReadDir(path)
 	if err != nil {
 		ufs.handleError(err, "GetFolderFileCount")
 		return 0
 	}
 	count := 0
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		if !entry.IsDir() && !ufs.skipHidden(entry) {
 			count++
 		}
 	}
@@ -164,6 +181,7 @@ func (ufs *UFS) GetFolderFileCount(path string) int {
 // GetFolderChildCount returns the total number of entries (both files and directories)
 // in the specified directory.
 // This function counts all items at the top level without recursing into subdirectories.
+// Hidden entries are not counted when Options.ExcludeHidden is set.
 //
 // Parameters:
 //   - path: The absolute or relative path to the directory to count children in
@@ -177,14 +195,18 @@ func (ufs *UFS) GetFolderFileCount(path string) int {
 //	count := ufs.GetFolderChildCount("/path/to/directory")
 //	fmt.Printf("Directory contains %d total items\n", count)
 func (ufs *UFS) GetFolderChildCount(path string) int {
-	entries, err := os.ReadDir(path)
+	defer ufs.recoverPanicReport("GetFolderChildCount")
+
+	entries, err := ufs.backend().ReadDir(path)
 	if err != nil {
 		ufs.handleError(err, "GetFolderChildCount")
 		return 0
 	}
 	count := 0
-	for range entries {
-		count++
+	for _, entry := range entries {
+		if !ufs.skipHidden(entry) {
+			count++
+		}
 	}
 	return count
 }
@@ -192,6 +214,7 @@ func (ufs *UFS) GetFolderChildCount(path string) int {
 // GetChildCount returns separate counts for the number of files and directories
 // in the specified directory.
 // This function counts items at the top level without recursing into subdirectories.
+// Hidden entries are not counted when Options.ExcludeHidden is set.
 //
 // Parameters:
 //   - path: The absolute or relative path to the directory to count children in
@@ -206,7 +229,9 @@ func (ufs *UFS) GetFolderChildCount(path string) int {
 //	folderCount, fileCount := ufs.GetChildCount("/path/to/directory")
 //	fmt.Printf("Directory contains %d folders and %d files\n", folderCount, fileCount)
 func (ufs *UFS) GetChildCount(path string) (int, int) {
-	entries, err := os.ReadDir(path)
+	defer ufs.recoverPanicReport("GetChildCount")
+
+	entries, err := ufs.backend().ReadDir(path)
 	if err != nil {
 		ufs.handleError(err, "GetChildCount")
 		return 0, 0
@@ -214,6 +239,9 @@ func (ufs *UFS) GetChildCount(path string) (int, int) {
 	folderCount := 0
 	fileCount := 0
 	for _, entry := range entries {
+		if ufs.skipHidden(entry) {
+			continue
+		}
 		if entry.IsDir() {
 			folderCount++
 		} else {
@@ -246,7 +274,9 @@ func (ufs *UFS) GetChildCount(path string) (int, int) {
 //	fmt.Printf("Folder name: %s\n", metadata["Name"])
 //	fmt.Printf("Last modified: %s\n", metadata["ModTime"])
 func (ufs *UFS) GetFolderMetadata(path string) map[string]interface{} {
-	info, err := os.Stat(path)
+	defer ufs.recoverPanicReport("GetFolderMetadata")
+
+	info, err := ufs.backend().Stat(path)
 	if err != nil {
 		ufs.handleError(err, "GetFolderMetadata")
 		return nil
@@ -264,33 +294,33 @@ func (ufs *UFS) GetFolderMetadata(path string) map[string]interface{} {
 }
 
 // GetFolderSize recursively calculates the total size of a folder and all its contents.
-// This function walks through the directory tree and sums the sizes of all files.
+// This function walks through the directory tree and sums the sizes of all files, reading
+// several directories at the same time (see Walk-parallel.go).
+// Paths that can't be read are skipped by default, Options.OnWalkError can abort instead.
+// Hidden files and directories are not counted when Options.ExcludeHidden is set.
 //
 // Parameters:
 //   - path: The absolute or relative path to the directory to calculate size for
 //
 // Returns:
 //   - int64: The total size of all files in the directory tree in bytes
-//   - Returns 0 if the directory doesn't exist or if an error occurs
+//   - Returns 0 if the directory doesn't exist or if the walk was aborted
 //
 // Example:
 //
 //	size := ufs.GetFolderSize("/path/to/directory")
 //	fmt.Printf("Total folder size: %d bytes\n", size)
 func (ufs *UFS) GetFolderSize(path string) int64 {
-	var size int64
-	err := filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
-		if err != nil {
-			ufs.handleError(err, "GetFolderSize")
-			return nil
-		}
+	defer ufs.recoverPanicReport("GetFolderSize")
+
+	var size atomic.Int64
+	err := ufs.walkParallel("GetFolderSize", path, 0, func(p string, d os.DirEntry) error {
 		if !d.IsDir() {
 			info, err := d.Info()
 			if err != nil {
-				ufs.handleError(err, "GetFolderSize")
-				return nil
+				return ufs.decideWalkError(p, err, WalkSkip, "GetFolderSize")
 			}
-			size += info.Size()
+			size.Add(info.Size())
 		}
 		return nil
 	})
@@ -298,5 +328,55 @@ func (ufs *UFS) GetFolderSize(path string) int64 {
 		ufs.handleError(err, "GetFolderSize")
 		return 0
 	}
-	return size
+	return size.Load()
+}
+
+// ListFilesRecursive returns the paths of all the files under a directory, in lexical order,
+// descending into subdirectories. Directories themselves are not listed.
+// Hidden files and directories are left out when Options.ExcludeHidden is set.
+// Paths that can't be read are skipped by default, Options.OnWalkError can abort instead.
+//
+// Parameters:
+//   - path: The absolute or relative path to the directory to list files from
+//
+// Returns:
+//   - []string: The paths of the files, starting with path
+//   - error: An error if the path is not a directory or the walk was aborted, nil otherwise
+//
+// Example:
+//
+//	files, err := ufs.ListFilesRecursive("/path/to/project")
+//	if err != nil {
+//	    fmt.Printf("Error listing files: %v\n", err)
+//	    return
+//	}
+//	for _, file := range files {
+//	    fmt.Println(file)
+//	}
+func (ufs *UFS) ListFilesRecursive(path string) (files []string, err error) {
+	defer ufs.recoverPanic("ListFilesRecursive", &err)
+
+	if !ufs.IsDirectory(path) {
+		return nil, ufs.misuseError("ListFilesRecursive", "path is not a directory", path)
+	}
+
+	err = filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(p, err, WalkSkip, "ListFilesRecursive")
+		}
+		if p != path && ufs.skipHidden(d) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, ufs.wrapError(err, "ListFilesRecursive")
+	}
+	return files, nil
 }
@@ -1,7 +1,6 @@
 package ufs
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
 )
@@ -38,7 +37,10 @@ More advanced functions:
 */
 
 // MoveFile moves or renames a file from one path to another.
-// If the destination already exists, it will be overwritten.
+// If the destination already exists, Options.Overwrite decides what happens: by default it is
+// overwritten, see Collision-policy.go for the other policies.
+// A move to another file system copies the file, keeping its permissions and modification time,
+// and removes the source once the copy is on disk (see Cross-device-move.go).
 // This function will create any parent directories for the destination if they don't exist.
 //
 // Parameters:
@@ -54,39 +56,102 @@ More advanced functions:
 //	if !success {
 //	    fmt.Println("Failed to move file")
 //	}
-func (ufs *UFS) MoveFile(srcPath, destPath string) bool {
+func (ufs *UFS) MoveFile(srcPath, destPath string) (ok bool) {
+	defer ufs.recoverPanicBool("MoveFile", &ok)
+
+	return ufs.reportFailure(ufs.tryMoveFile(srcPath, destPath), "MoveFile")
+}
+
+// tryMoveFile is MoveFile returning why the file couldn't be moved, a *MisuseError if the source isn't a file
+func (ufs *UFS) tryMoveFile(srcPath, destPath string) error {
+	// Verify source is a file
+	if !ufs.IsFile(srcPath) {
+		return &MisuseError{Op: "MoveFile", Reason: "Source is not a file", Path: srcPath}
+	}
+
+	_, _, err := ufs.placeFile(destPath, ufs.opts.Overwrite, func(dest string, noReplace bool) error {
+		return ufs.moveFileTo(srcPath, dest, noReplace)
+	})
+	return err
+}
+
+// moveFile is MoveFile overwriting the destination
+func (ufs *UFS) moveFile(srcPath, destPath string) bool {
 	// Verify source is a file
 	if !ufs.IsFile(srcPath) {
-		ufs.handleMistakeWarning(fmt.Sprintf("MoveFile: Source is not a file: %s", srcPath))
+		ufs.reportMisuse("MoveFile", "Source is not a file", srcPath)
 		return false
 	}
 
-	// Ensure destination directory exists
-	destDir := filepath.Dir(destPath)
-	if !ufs.IsDirectory(destDir) {
-		if !ufs.CreateDirectory(destDir) {
-			return false
-		}
+	if err := ufs.moveFileTo(srcPath, destPath, false); err != nil {
+		ufs.handleError(err, "MoveFile")
+		return false
 	}
+	return true
+}
 
-	// If destination exists and is a file, remove it
-	if ufs.IsFile(destPath) {
-		if !ufs.RemoveFile(destPath) {
-			return false
+// moveFileTo moves a file, replacing the destination unless noReplace is set, in which case it fails
+// with an error wrapping os.ErrExist if the destination exists
+func (ufs *UFS) moveFileTo(srcPath, destPath string, noReplace bool) error {
+	if info, err := ufs.backend().Stat(srcPath); err == nil {
+		if err := ufs.checkQuota(destPath, info.Size()); err != nil {
+			return err
 		}
 	}
 
-	// Move the file
-	err := os.Rename(srcPath, destPath)
-	if err != nil {
-		// Try copy and delete if rename fails (e.g., across different filesystems)
-		if !ufs.copyThenDelete(srcPath, destPath) {
-			ufs.handleError(err, "MoveFile")
-			return false
-		}
+	if ufs.dryRun("MoveFile", DryRunMove, destPath, srcPath) {
+		return nil
 	}
 
-	return true
+	// Ensure destination directory exists
+	if err := mkdirAllBackend(ufs.backend(), filepath.Dir(destPath), 0755); err != nil {
+		return err
+	}
+
+	var err error
+	switch {
+	case noReplace && ufs.onOS():
+		err = renameNoReplace(srcPath, destPath)
+		// Only a move to another file system is done by copying, see Cross-device-move.go
+		if ufs.IsCrossDevice(err) {
+			err = ufs.moveAcrossDevices(srcPath, destPath, true)
+		}
+
+	case noReplace:
+		// Backends have no rename refusing to replace files: the file is copied to a new file
+		if err = copyBackendFile(ufs.backend(), srcPath, destPath, os.O_EXCL); err == nil {
+			err = ufs.backend().Remove(srcPath)
+		}
+
+	default:
+		// If destination exists and is a file, remove it
+		if ufs.IsFile(destPath) {
+			if err := ufs.backend().Remove(destPath); err != nil {
+				return err
+			}
+		}
+
+		// Move the file
+		err = ufs.backend().Rename(srcPath, destPath)
+		switch {
+		case err == nil:
+		case ufs.onOS():
+			// Only a move to another file system is done by copying, see Cross-device-move.go
+			if ufs.IsCrossDevice(err) {
+				err = ufs.moveAcrossDevices(srcPath, destPath, false)
+			}
+		default:
+			// Try copy and delete if the rename of the backend fails
+			if ufs.copyThenDelete(srcPath, destPath) {
+				err = nil
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	ufs.recordQuota(srcPath, destPath)
+	return nil
 }
 
 // DeleteFile deletes a file at the specified path.
@@ -104,7 +169,9 @@ func (ufs *UFS) MoveFile(srcPath, destPath string) bool {
 //	if !success {
 //	    fmt.Println("Failed to delete file")
 //	}
-func (ufs *UFS) DeleteFile(path string) bool {
+func (ufs *UFS) DeleteFile(path string) (ok bool) {
+	defer ufs.recoverPanicBool("DeleteFile", &ok)
+
 	return ufs.RemoveFile(path)
 }
 
@@ -123,12 +190,15 @@ func (ufs *UFS) DeleteFile(path string) bool {
 //	if !success {
 //	    fmt.Println("Failed to delete directory")
 //	}
-func (ufs *UFS) DeleteDirectory(path string) bool {
+func (ufs *UFS) DeleteDirectory(path string) (ok bool) {
+	defer ufs.recoverPanicBool("DeleteDirectory", &ok)
+
 	return ufs.RemoveDirectoryRecursive(path)
 }
 
 // MoveDirectory moves or renames a directory from one path to another.
-// If the destination already exists as a directory, it will attempt to merge the contents.
+// If the destination already exists as a directory, it will attempt to merge the contents,
+// replacing the files present in both (see MoveDirectoryWithMerge for other policies).
 // This function will create any parent directories for the destination if they don't exist.
 //
 // Parameters:
@@ -144,13 +214,24 @@ func (ufs *UFS) DeleteDirectory(path string) bool {
 //	if !success {
 //	    fmt.Println("Failed to move directory")
 //	}
-func (ufs *UFS) MoveDirectory(srcPath, destPath string) bool {
+func (ufs *UFS) MoveDirectory(srcPath, destPath string) (ok bool) {
+	defer ufs.recoverPanicBool("MoveDirectory", &ok)
+
+	if !ufs.requireOSBool("MoveDirectory") {
+		return false
+	}
+
 	// Verify source is a directory
 	if !ufs.IsDirectory(srcPath) {
-		ufs.handleMistakeWarning(fmt.Sprintf("MoveDirectory: Source is not a directory: %s", srcPath))
+		ufs.reportMisuse("MoveDirectory", "Source is not a directory", srcPath)
 		return false
 	}
 
+	// A merge into an existing directory is recorded as a single move too
+	if ufs.dryRun("MoveDirectory", DryRunMove, destPath, srcPath) {
+		return true
+	}
+
 	// Ensure destination parent directory exists
 	destParent := filepath.Dir(destPath)
 	if !ufs.IsDirectory(destParent) {
@@ -175,7 +256,7 @@ func (ufs *UFS) MoveDirectory(srcPath, destPath string) bool {
 
 	// If destination exists and is a file, fail
 	if ufs.IsFile(destPath) {
-		ufs.handleMistakeWarning(fmt.Sprintf("MoveDirectory: Destination exists and is a file: %s", destPath))
+		ufs.reportMisuse("MoveDirectory", "Destination exists and is a file", destPath)
 		return false
 	}
 
@@ -203,7 +284,9 @@ func (ufs *UFS) MoveDirectory(srcPath, destPath string) bool {
 //	if !success {
 //	    fmt.Println("Failed to move file (if it existed)")
 //	}
-func (ufs *UFS) MoveFileIfExists(srcPath, destPath string) bool {
+func (ufs *UFS) MoveFileIfExists(srcPath, destPath string) (ok bool) {
+	defer ufs.recoverPanicBool("MoveFileIfExists", &ok)
+
 	if !ufs.IsFile(srcPath) {
 		return true // Success: nothing to move
 	}
@@ -226,7 +309,13 @@ func (ufs *UFS) MoveFileIfExists(srcPath, destPath string) bool {
 //	if !success {
 //	    fmt.Println("Failed to move directory (if it existed)")
 //	}
-func (ufs *UFS) MoveDirectoryIfExists(srcPath, destPath string) bool {
+func (ufs *UFS) MoveDirectoryIfExists(srcPath, destPath string) (ok bool) {
+	defer ufs.recoverPanicBool("MoveDirectoryIfExists", &ok)
+
+	if !ufs.requireOSBool("MoveDirectoryIfExists") {
+		return false
+	}
+
 	if !ufs.IsDirectory(srcPath) {
 		return true // Success: nothing to move
 	}
@@ -248,7 +337,9 @@ func (ufs *UFS) MoveDirectoryIfExists(srcPath, destPath string) bool {
 //	if !success {
 //	    fmt.Println("Failed to delete file (if it existed)")
 //	}
-func (ufs *UFS) DeleteFileIfExists(path string) bool {
+func (ufs *UFS) DeleteFileIfExists(path string) (ok bool) {
+	defer ufs.recoverPanicBool("DeleteFileIfExists", &ok)
+
 	if !ufs.IsFile(path) {
 		return true // Success: nothing to delete
 	}
@@ -270,7 +361,9 @@ func (ufs *UFS) DeleteFileIfExists(path string) bool {
 //	if !success {
 //	    fmt.Println("Failed to delete directory (if it existed)")
 //	}
-func (ufs *UFS) DeleteDirectoryIfExists(path string) bool {
+func (ufs *UFS) DeleteDirectoryIfExists(path string) (ok bool) {
+	defer ufs.recoverPanicBool("DeleteDirectoryIfExists", &ok)
+
 	if !ufs.IsDirectory(path) {
 		return true // Success: nothing to delete
 	}
@@ -293,16 +386,22 @@ func (ufs *UFS) DeleteDirectoryIfExists(path string) bool {
 //	if !success {
 //	    fmt.Println("Failed to move directory (it might not be empty)")
 //	}
-func (ufs *UFS) MoveDirectoryIfEmpty(srcPath, destPath string) bool {
+func (ufs *UFS) MoveDirectoryIfEmpty(srcPath, destPath string) (ok bool) {
+	defer ufs.recoverPanicBool("MoveDirectoryIfEmpty", &ok)
+
+	if !ufs.requireOSBool("MoveDirectoryIfEmpty") {
+		return false
+	}
+
 	// Verify source is a directory
 	if !ufs.IsDirectory(srcPath) {
-		ufs.handleMistakeWarning(fmt.Sprintf("MoveDirectoryIfEmpty: Source is not a directory: %s", srcPath))
+		ufs.reportMisuse("MoveDirectoryIfEmpty", "Source is not a directory", srcPath)
 		return false
 	}
 
 	// Check if directory is empty
 	if !ufs.IsDirectoryEmpty(srcPath) {
-		ufs.handleMistakeWarning(fmt.Sprintf("MoveDirectoryIfEmpty: Source directory is not empty: %s", srcPath))
+		ufs.reportMisuse("MoveDirectoryIfEmpty", "Source directory is not empty", srcPath)
 		return false
 	}
 
@@ -325,16 +424,18 @@ func (ufs *UFS) MoveDirectoryIfEmpty(srcPath, destPath string) bool {
 //	if !success {
 //	    fmt.Println("Failed to move file (it might not be empty)")
 //	}
-func (ufs *UFS) MoveFileIfEmpty(srcPath, destPath string) bool {
+func (ufs *UFS) MoveFileIfEmpty(srcPath, destPath string) (ok bool) {
+	defer ufs.recoverPanicBool("MoveFileIfEmpty", &ok)
+
 	// Verify source is a file
 	if !ufs.IsFile(srcPath) {
-		ufs.handleMistakeWarning(fmt.Sprintf("MoveFileIfEmpty: Source is not a file: %s", srcPath))
+		ufs.reportMisuse("MoveFileIfEmpty", "Source is not a file", srcPath)
 		return false
 	}
 
 	// Check if file is empty
 	if !ufs.IsFileEmpty(srcPath) {
-		ufs.handleMistakeWarning(fmt.Sprintf("MoveFileIfEmpty: Source file is not empty: %s", srcPath))
+		ufs.reportMisuse("MoveFileIfEmpty", "Source file is not empty", srcPath)
 		return false
 	}
 
@@ -356,16 +457,18 @@ func (ufs *UFS) MoveFileIfEmpty(srcPath, destPath string) bool {
 //	if !success {
 //	    fmt.Println("Failed to delete file (it might not be empty)")
 //	}
-func (ufs *UFS) DeleteFileIfEmpty(path string) bool {
+func (ufs *UFS) DeleteFileIfEmpty(path string) (ok bool) {
+	defer ufs.recoverPanicBool("DeleteFileIfEmpty", &ok)
+
 	// Verify path is a file
 	if !ufs.IsFile(path) {
-		ufs.handleMistakeWarning(fmt.Sprintf("DeleteFileIfEmpty: Path is not a file: %s", path))
+		ufs.reportMisuse("DeleteFileIfEmpty", "Path is not a file", path)
 		return false
 	}
 
 	// Check if file is empty
 	if !ufs.IsFileEmpty(path) {
-		ufs.handleMistakeWarning(fmt.Sprintf("DeleteFileIfEmpty: File is not empty: %s", path))
+		ufs.reportMisuse("DeleteFileIfEmpty", "File is not empty", path)
 		return false
 	}
 
@@ -387,16 +490,18 @@ func (ufs *UFS) DeleteFileIfEmpty(path string) bool {
 //	if !success {
 //	    fmt.Println("Failed to delete directory (it might not be empty)")
 //	}
-func (ufs *UFS) DeleteDirectoryIfEmpty(path string) bool {
+func (ufs *UFS) DeleteDirectoryIfEmpty(path string) (ok bool) {
+	defer ufs.recoverPanicBool("DeleteDirectoryIfEmpty", &ok)
+
 	// Verify path is a directory
 	if !ufs.IsDirectory(path) {
-		ufs.handleMistakeWarning(fmt.Sprintf("DeleteDirectoryIfEmpty: Path is not a directory: %s", path))
+		ufs.reportMisuse("DeleteDirectoryIfEmpty", "Path is not a directory", path)
 		return false
 	}
 
 	// Check if directory is empty
 	if !ufs.IsDirectoryEmpty(path) {
-		ufs.handleMistakeWarning(fmt.Sprintf("DeleteDirectoryIfEmpty: Directory is not empty: %s", path))
+		ufs.reportMisuse("DeleteDirectoryIfEmpty", "Directory is not empty", path)
 		return false
 	}
 
@@ -420,16 +525,18 @@ func (ufs *UFS) DeleteDirectoryIfEmpty(path string) bool {
 //	if !success {
 //	    fmt.Println("Failed to rename file")
 //	}
-func (ufs *UFS) RenameFile(path string, newName string) bool {
+func (ufs *UFS) RenameFile(path string, newName string) (ok bool) {
+	defer ufs.recoverPanicBool("RenameFile", &ok)
+
 	// Verify source is a file
 	if !ufs.IsFile(path) {
-		ufs.handleMistakeWarning(fmt.Sprintf("RenameFile: Source is not a file: %s", path))
+		ufs.reportMisuse("RenameFile", "Source is not a file", path)
 		return false
 	}
 
 	// Ensure newName is just a filename, not a path
 	if filepath.Base(newName) != newName {
-		ufs.handleMistakeWarning(fmt.Sprintf("RenameFile: New name should not be a path: %s", newName))
+		ufs.reportMisuse("RenameFile", "New name should not be a path", newName)
 		return false
 	}
 
@@ -456,16 +563,22 @@ func (ufs *UFS) RenameFile(path string, newName string) bool {
 //	if !success {
 //	    fmt.Println("Failed to rename directory")
 //	}
-func (ufs *UFS) RenameDirectory(path string, newName string) bool {
+func (ufs *UFS) RenameDirectory(path string, newName string) (ok bool) {
+	defer ufs.recoverPanicBool("RenameDirectory", &ok)
+
+	if !ufs.requireOSBool("RenameDirectory") {
+		return false
+	}
+
 	// Verify source is a directory
 	if !ufs.IsDirectory(path) {
-		ufs.handleMistakeWarning(fmt.Sprintf("RenameDirectory: Source is not a directory: %s", path))
+		ufs.reportMisuse("RenameDirectory", "Source is not a directory", path)
 		return false
 	}
 
 	// Ensure newName is just a directory name, not a path
 	if filepath.Base(newName) != newName {
-		ufs.handleMistakeWarning(fmt.Sprintf("RenameDirectory: New name should not be a path: %s", newName))
+		ufs.reportMisuse("RenameDirectory", "New name should not be a path", newName)
 		return false
 	}
 
@@ -496,6 +609,12 @@ func (ufs *UFS) RenameDirectory(path string, newName string) bool {
 //	    fmt.Printf("Destination was backed up to: %s\n", backupPath)
 //	}
 func (ufs *UFS) MoveWithBackup(srcPath, destPath string) (bool, string) {
+	defer ufs.recoverPanicReport("MoveWithBackup")
+
+	if !ufs.requireOSBool("MoveWithBackup") {
+		return false, ""
+	}
+
 	backupPath := ""
 
 	// If destination exists, create a backup
@@ -517,7 +636,7 @@ func (ufs *UFS) MoveWithBackup(srcPath, destPath string) (bool, string) {
 
 		// Create backup
 		if ufs.IsFile(destPath) {
-			if !ufs.MoveFile(destPath, backupPath) {
+			if !ufs.moveFile(destPath, backupPath) {
 				return false, ""
 			}
 		} else if ufs.IsDirectory(destPath) {
@@ -534,14 +653,14 @@ func (ufs *UFS) MoveWithBackup(srcPath, destPath string) (bool, string) {
 	} else if ufs.IsDirectory(srcPath) {
 		success = ufs.MoveDirectory(srcPath, destPath)
 	} else {
-		ufs.handleMistakeWarning(fmt.Sprintf("MoveWithBackup: Source is neither a file nor a directory: %s", srcPath))
+		ufs.reportMisuse("MoveWithBackup", "Source is neither a file nor a directory", srcPath)
 		return false, backupPath
 	}
 
 	if !success && backupPath != "" {
 		// Restore from backup if move failed
 		if ufs.IsFile(backupPath) {
-			ufs.MoveFile(backupPath, destPath)
+			ufs.moveFile(backupPath, destPath)
 		} else if ufs.IsDirectory(backupPath) {
 			ufs.MoveDirectory(backupPath, destPath)
 		}
@@ -570,9 +689,15 @@ func (ufs *UFS) MoveWithBackup(srcPath, destPath string) (bool, string) {
 //	    fmt.Printf("File was backed up to: %s before deletion\n", backupPath)
 //	}
 func (ufs *UFS) DeleteWithBackup(path string) (bool, string) {
+	defer ufs.recoverPanicReport("DeleteWithBackup")
+
+	if !ufs.requireOSBool("DeleteWithBackup") {
+		return false, ""
+	}
+
 	// Verify path exists
 	if !ufs.PathExists(path) {
-		ufs.handleMistakeWarning(fmt.Sprintf("DeleteWithBackup: Path does not exist: %s", path))
+		ufs.reportMisuse("DeleteWithBackup", "Path does not exist", path)
 		return false, ""
 	}
 
@@ -592,29 +717,36 @@ func (ufs *UFS) DeleteWithBackup(path string) (bool, string) {
 	}
 
 	// Create backup
+	if ufs.dryRun("DeleteWithBackup", DryRunCopy, backupPath, path) {
+		if ufs.IsDirectory(path) {
+			return ufs.DeleteDirectory(path), backupPath
+		}
+		return ufs.DeleteFile(path), backupPath
+	}
 	if ufs.IsFile(path) {
-		if err := ufs.CopyFile(path, backupPath); err != nil {
+		if err := ufs.copyFile(path, backupPath); err != nil {
 			return false, ""
 		}
 		return ufs.DeleteFile(path), backupPath
 	} else if ufs.IsDirectory(path) {
-		// For directories, we need to copy the entire structure
-		success := ufs.copyDirectoryRecursive(path, backupPath)
+		// For directories, we need to copy the entire structure, hidden entries included as the
+		// whole tree is deleted afterwards
+		success := ufs.copyDirectoryRecursive(path, backupPath, true)
 		if !success {
 			return false, ""
 		}
 		return ufs.DeleteDirectory(path), backupPath
 	}
 
-	ufs.handleMistakeWarning(fmt.Sprintf("DeleteWithBackup: Path is neither a file nor a directory: %s", path))
+	ufs.reportMisuse("DeleteWithBackup", "Path is neither a file nor a directory", path)
 	return false, ""
 }
 
 // copyThenDelete is a helper function that copies a file and then deletes the source
-// Used when os.Rename fails (e.g., across filesystems)
+// Used when the rename of a backend other than the OS fails
 func (ufs *UFS) copyThenDelete(srcPath, destPath string) bool {
 	// Copy the file
-	if err := ufs.CopyFile(srcPath, destPath); err != nil {
+	if err := ufs.copyFile(srcPath, destPath); err != nil {
 		return false
 	}
 
@@ -650,9 +782,9 @@ func (ufs *UFS) mergeDirectories(srcPath, destPath string) bool {
 			if !ufs.MoveDirectoryIfExists(srcItemPath, destItemPath) {
 				success = false
 			}
-		} else {
-			// If it's a file, move it
-			if !ufs.MoveFileIfExists(srcItemPath, destItemPath) {
+		} else if ufs.IsFile(srcItemPath) {
+			// If it's a file, move it over the existing one
+			if !ufs.moveFile(srcItemPath, destItemPath) {
 				success = false
 			}
 		}
@@ -670,8 +802,9 @@ func (ufs *UFS) mergeDirectories(srcPath, destPath string) bool {
 	return success
 }
 
-// copyDirectoryRecursive is a helper function that copies a directory and all its contents
-func (ufs *UFS) copyDirectoryRecursive(srcPath, destPath string) bool {
+// copyDirectoryRecursive is a helper function that copies a directory and all its contents.
+// Hidden entries are left out when Options.ExcludeHidden is set, unless includeHidden is true.
+func (ufs *UFS) copyDirectoryRecursive(srcPath, destPath string, includeHidden bool) bool {
 	// Create the destination directory
 	if !ufs.CreateDirectory(destPath) {
 		return false
@@ -686,19 +819,22 @@ func (ufs *UFS) copyDirectoryRecursive(srcPath, destPath string) bool {
 
 	success := true
 
-	// Copy each entry to the destination
+	// Copy each entry to the destination, leaving hidden entries out when Options.ExcludeHidden is set
 	for _, entry := range entries {
+		if !includeHidden && ufs.skipHidden(entry) {
+			continue
+		}
 		srcItemPath := filepath.Join(srcPath, entry.Name())
 		destItemPath := filepath.Join(destPath, entry.Name())
 
 		if entry.IsDir() {
 			// If it's a directory, recursively copy it
-			if !ufs.copyDirectoryRecursive(srcItemPath, destItemPath) {
+			if !ufs.copyDirectoryRecursive(srcItemPath, destItemPath, includeHidden) {
 				success = false
 			}
 		} else {
 			// If it's a file, copy it
-			if err := ufs.CopyFile(srcItemPath, destItemPath); err != nil {
+			if err := ufs.copyFile(srcItemPath, destItemPath); err != nil {
 				success = false
 			}
 		}
@@ -0,0 +1,444 @@
+package ufs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Name-index.go contains a persistent index of the names of a tree, to find files by name without walking it.
+
+BuildNameIndex lists every directory of a tree once and saves the names to an index file. The next
+builds (and NameIndex.Update) only list again the directories whose modification time changed: a
+directory's modification time changes when entries are created, removed or renamed in it, so an update
+of a tree of millions of files costs one stat per directory. A program watching the tree (inotify,
+FSEvents...) can instead call NameIndex.Refresh with the paths it is notified of, which lists only
+their directories.
+
+QueryIndex matches the names in memory: a name without wildcards is found in constant time, a pattern
+like "*.pdf" scans the distinct names once, without touching the disk.
+
+Hidden entries are skipped with Options.ExcludeHidden, symbolic links are indexed but not followed.
+On file systems with a coarse timestamp resolution (FAT: 2 seconds), a directory changed twice in the
+same tick before an update is only seen by Refresh.
+
+Functions:
+- BuildNameIndex: Loads or creates the index of a tree, updates it and saves it.
+- NameIndex.QueryIndex: Returns the paths whose name matches a pattern.
+- NameIndex.Update: Lists again the directories changed since the last update.
+- NameIndex.Refresh: Updates the index after a change of a path.
+- NameIndex.Save: Writes the index file if the index changed.
+- NameIndex.Len: Returns the number of entries indexed.
+*/
+
+// nameIndexVersion is the version of the index file format
+const nameIndexVersion = 1
+
+// nameIndexDir is what is remembered about a directory of the tree
+type nameIndexDir struct {
+	ModTime time.Time `json:"modTime"`
+	Entries []string  `json:"entries"`           // Names of the entries of the directory
+	Subdirs []string  `json:"subdirs,omitempty"` // Names of the entries that are directories
+}
+
+// NameIndex is the index of the names of a tree, created by BuildNameIndex.
+// Its methods are safe for concurrent use; queries run while an update lists the directories.
+type NameIndex struct {
+	ufs  *UFS
+	path string // Index file, "" keeps the index in memory only
+
+	scanMu  sync.Mutex // Serializes the updates, so an older listing doesn't overwrite a newer one
+	mu      sync.RWMutex
+	Version int                      `json:"version"`
+	Root    string                   `json:"root"`
+	Dirs    map[string]*nameIndexDir `json:"dirs"` // By path relative to the root, with slashes, "." for the root
+	names   map[string][]string      // Relative paths of the entries, by lowercase name
+	count   int
+	dirty   bool
+}
+
+// BuildNameIndex loads the index of root saved at indexPath, creating it if it doesn't exist, updates it
+// with the changes of the tree and saves it.
+//
+// Parameters:
+//   - root: The absolute or relative path to the directory to index
+//   - indexPath: The index file, "" keeps the index in memory only
+//
+// Returns:
+//   - *NameIndex: The index, up to date with the tree
+//   - error: An error if the root is not a directory, or the index file can't be read or written
+//
+// Example:
+//
+//	index, err := ufs.BuildNameIndex("/srv/media", "/var/cache/media.index")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	paths, _ := index.QueryIndex("*.mkv")
+//	fmt.Printf("%d videos\n", len(paths))
+func (ufs *UFS) BuildNameIndex(root, indexPath string) (_ *NameIndex, err error) {
+	defer ufs.recoverPanic("BuildNameIndex", &err)
+
+	if err := ufs.requireOS("BuildNameIndex"); err != nil {
+		return nil, err
+	}
+
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return nil, ufs.wrapError(err, "BuildNameIndex")
+	}
+	info, err := os.Stat(root)
+	if err != nil || !info.IsDir() {
+		return nil, ufs.misuseError("BuildNameIndex", "source path is not a directory", root)
+	}
+
+	index := &NameIndex{ufs: ufs, path: indexPath, Version: nameIndexVersion, Root: root, Dirs: map[string]*nameIndexDir{}}
+	if indexPath != "" {
+		if err := index.load(); err != nil {
+			return nil, ufs.wrapError(err, "BuildNameIndex")
+		}
+	}
+	index.rebuildNames()
+
+	if _, err := index.Update(); err != nil {
+		return nil, err
+	}
+	if err := index.Save(); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// load reads the index file, a missing file or one of another tree or format gives an empty index
+func (index *NameIndex) load() error {
+	data, err := os.ReadFile(index.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	saved := &NameIndex{}
+	if err := json.Unmarshal(data, saved); err != nil {
+		return fmt.Errorf("invalid name index file %s: %w", index.path, err)
+	}
+	if saved.Version != nameIndexVersion || saved.Root != index.Root || saved.Dirs == nil {
+		index.dirty = true
+		return nil
+	}
+	index.Dirs = saved.Dirs
+	return nil
+}
+
+// rebuildNames computes the names lookup table from the directories
+func (index *NameIndex) rebuildNames() {
+	index.names = map[string][]string{}
+	index.count = 0
+	for rel, dir := range index.Dirs {
+		index.addNames(rel, dir)
+	}
+}
+
+func (index *NameIndex) addNames(rel string, dir *nameIndexDir) {
+	for _, name := range dir.Entries {
+		key := strings.ToLower(name)
+		index.names[key] = append(index.names[key], path.Join(rel, name))
+	}
+	index.count += len(dir.Entries)
+}
+
+func (index *NameIndex) removeNames(rel string, dir *nameIndexDir) {
+	for _, name := range dir.Entries {
+		key := strings.ToLower(name)
+		entryPath := path.Join(rel, name)
+		paths := index.names[key]
+		for i, p := range paths {
+			if p == entryPath {
+				paths[i] = paths[len(paths)-1]
+				paths = paths[:len(paths)-1]
+				break
+			}
+		}
+		if len(paths) == 0 {
+			delete(index.names, key)
+		} else {
+			index.names[key] = paths
+		}
+	}
+	index.count -= len(dir.Entries)
+}
+
+// QueryIndex returns the paths of the entries, files and directories, whose name matches pattern.
+// The pattern uses the syntax of filepath.Match and is matched without regard to case.
+//
+// Parameters:
+//   - pattern: The name or pattern to find, e.g. "invoice-2024.pdf" or "*.pdf"
+//
+// Returns:
+//   - []string: The absolute paths of the matching entries, sorted
+//   - error: filepath.ErrBadPattern if the pattern is malformed
+//
+// Example:
+//
+//	paths, err := index.QueryIndex("readme*")
+//	for _, p := range paths {
+//	    fmt.Println(p)
+//	}
+func (index *NameIndex) QueryIndex(pattern string) ([]string, error) {
+	pattern = strings.ToLower(pattern)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("QueryIndex: %w", err)
+	}
+
+	index.mu.RLock()
+	var rels []string
+	if !strings.ContainsAny(pattern, `*?[\`) {
+		rels = append(rels, index.names[pattern]...)
+	} else {
+		for name, paths := range index.names {
+			if matched, _ := path.Match(pattern, name); matched {
+				rels = append(rels, paths...)
+			}
+		}
+	}
+	index.mu.RUnlock()
+
+	result := make([]string, len(rels))
+	for i, rel := range rels {
+		result[i] = filepath.Join(index.Root, filepath.FromSlash(rel))
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// Update lists again the directories of the tree whose modification time changed since the last update,
+// and forgets the removed ones. The index file is not written, see Save.
+//
+// Returns:
+//   - int: The number of directories listed
+//   - error: An error if the root can't be read, or a directory can't be read and Options.OnWalkError aborts
+//
+// Example:
+//
+//	for range time.Tick(time.Minute) {
+//	    if _, err := index.Update(); err == nil {
+//	        index.Save()
+//	    }
+//	}
+func (index *NameIndex) Update() (_ int, err error) {
+	defer index.ufs.recoverPanic("NameIndex.Update", &err)
+
+	listed, err := index.scan(".", false)
+	if err != nil {
+		return listed, index.ufs.wrapError(err, "NameIndex.Update")
+	}
+	return listed, nil
+}
+
+// Refresh updates the index after path was created, removed or renamed, e.g. on a notification of a
+// file watcher. The directory containing path is listed again, with the directories below it that changed.
+//
+// Parameters:
+//   - changed: The absolute or relative path that changed, inside the root of the index
+//
+// Returns:
+//   - error: An error if path is outside the root, or a directory can't be read
+//
+// Example:
+//
+//	for event := range watcher.Events {
+//	    index.Refresh(event.Name)
+//	}
+func (index *NameIndex) Refresh(changed string) (err error) {
+	defer index.ufs.recoverPanic("NameIndex.Refresh", &err)
+
+	absPath, err := filepath.Abs(changed)
+	if err != nil {
+		return index.ufs.wrapError(err, "NameIndex.Refresh")
+	}
+	rel, err := filepath.Rel(index.Root, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("NameIndex.Refresh: path is outside the root of the index: %s", changed)
+	}
+
+	// The parent lists the change; if it is new or gone too, the closest indexed ancestor still there does
+	rel = filepath.ToSlash(rel)
+	if rel != "." {
+		rel = path.Dir(rel)
+	}
+	for rel != "." {
+		index.mu.RLock()
+		indexed := index.Dirs[rel] != nil
+		index.mu.RUnlock()
+		if info, err := os.Stat(filepath.Join(index.Root, filepath.FromSlash(rel))); indexed && err == nil && info.IsDir() {
+			break
+		}
+		rel = path.Dir(rel)
+	}
+
+	if _, err := index.scan(rel, true); err != nil {
+		return index.ufs.wrapError(err, "NameIndex.Refresh")
+	}
+	return nil
+}
+
+// scan walks the directories below start, listing the ones changed (and start with force),
+// then applies the changes to the index
+func (index *NameIndex) scan(start string, force bool) (int, error) {
+	index.scanMu.Lock()
+	defer index.scanMu.Unlock()
+
+	changes := map[string]*nameIndexDir{}
+	seen := map[string]bool{}
+
+	stack := []string{start}
+	for len(stack) > 0 {
+		rel := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		dirPath := filepath.Join(index.Root, filepath.FromSlash(rel))
+
+		info, err := os.Stat(dirPath)
+		if err != nil || !info.IsDir() {
+			if rel == "." {
+				return 0, index.ufs.misuseError("NameIndex", "source path is not a directory", index.Root)
+			}
+			if err == nil || errors.Is(err, fs.ErrNotExist) {
+				continue // Removed meanwhile, forgotten below
+			}
+			if err := index.ufs.decideWalkError(dirPath, err, WalkSkip, "NameIndex"); err != nil {
+				return 0, err
+			}
+			seen[rel] = true // Kept as it was
+			continue
+		}
+		seen[rel] = true
+
+		index.mu.RLock()
+		old := index.Dirs[rel]
+		index.mu.RUnlock()
+		if old != nil && old.ModTime.Equal(info.ModTime()) && !(force && rel == start) {
+			for _, name := range old.Subdirs {
+				stack = append(stack, path.Join(rel, name))
+			}
+			continue
+		}
+
+		dir, err := index.listDir(dirPath, info)
+		if err != nil {
+			if err := index.ufs.decideWalkError(dirPath, err, WalkSkip, "NameIndex"); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		changes[rel] = dir
+		for _, name := range dir.Subdirs {
+			stack = append(stack, path.Join(rel, name))
+		}
+	}
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	for rel := range index.Dirs {
+		inScan := start == "." || rel == start || strings.HasPrefix(rel, start+"/")
+		if inScan && !seen[rel] {
+			changes[rel] = nil
+		}
+	}
+	for rel, dir := range changes {
+		if old := index.Dirs[rel]; old != nil {
+			index.removeNames(rel, old)
+		}
+		if dir == nil {
+			delete(index.Dirs, rel)
+			continue
+		}
+		index.Dirs[rel] = dir
+		index.addNames(rel, dir)
+	}
+	if len(changes) > 0 {
+		index.dirty = true
+	}
+
+	listed := 0
+	for _, dir := range changes {
+		if dir != nil {
+			listed++
+		}
+	}
+	return listed, nil
+}
+
+// listDir reads the entries of a directory of the tree
+func (index *NameIndex) listDir(dirPath string, info os.FileInfo) (*nameIndexDir, error) {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	dir := &nameIndexDir{ModTime: info.ModTime(), Entries: make([]string, 0, len(entries))}
+	for _, entry := range entries {
+		if index.ufs.skipHidden(entry) {
+			continue
+		}
+		dir.Entries = append(dir.Entries, entry.Name())
+		if entry.IsDir() {
+			dir.Subdirs = append(dir.Subdirs, entry.Name())
+		}
+	}
+	return dir, nil
+}
+
+// Save writes the index file if the index changed since it was loaded or saved.
+//
+// Returns:
+//   - error: An error if the index file can't be written
+//
+// Example:
+//
+//	defer index.Save()
+func (index *NameIndex) Save() (err error) {
+	defer index.ufs.recoverPanic("NameIndex.Save", &err)
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	if index.path == "" || !index.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return index.ufs.wrapError(err, "NameIndex.Save")
+	}
+	if err := os.MkdirAll(filepath.Dir(index.path), 0755); err != nil {
+		return index.ufs.wrapError(err, "NameIndex.Save")
+	}
+	// Atomically, so a crash leaves the previous index rather than a truncated one
+	if err := writeFileAtomic(index.path, data, 0644); err != nil {
+		return index.ufs.wrapError(err, "NameIndex.Save")
+	}
+	index.dirty = false
+	return nil
+}
+
+// Len returns the number of entries, files and directories, in the index.
+//
+// Returns:
+//   - int: The number of entries indexed, the root excluded
+//
+// Example:
+//
+//	fmt.Printf("%d entries indexed\n", index.Len())
+func (index *NameIndex) Len() int {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	return index.count
+}
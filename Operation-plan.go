@@ -0,0 +1,344 @@
+package ufs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Operation-plan.go contains PlanOperations, which checks what a list of copies, moves and deletes would
+do to the file system without doing it, e.g. so a CI job can review a deployment script before it runs.
+
+Sources can be glob patterns ("dist/*.js", "docs/**", "*.{png,svg}", see Glob.go): every match becomes
+a step, and the destination is then a directory receiving the matches under their own names. Each step
+of the plan records its size and the problems found:
+- the source doesn't exist, can't be read, or was already moved or deleted by an earlier step, itself or
+  with a parent directory; a source created by an earlier step is checked against what that step copies
+- the destination exists and Options.Overwrite is FailOnExisting, or several steps write it
+- the destination (or for moves and deletes, the directory of the source) isn't writable
+- the file system of the destination doesn't have enough available space for the copies
+
+	plan, err := ufs.PlanOperations([]ufs.PlannedOperation{
+	    {Kind: ufs.OpCopy, Source: "build/*.js", Destination: "/srv/www/js"},
+	    {Kind: ufs.OpDelete, Source: "/srv/www/js/legacy.js"},
+	})
+	data, _ := plan.JSON()
+	os.WriteFile("plan.json", data, 0644)
+	if !plan.OK {
+	    os.Exit(1)
+	}
+
+Write permissions are only checked on Unix systems. Moves are expected to stay on the same file
+system, their data isn't counted in the space needed.
+
+Functions:
+- PlanOperations: Resolves and checks operations without executing them
+*/
+
+// PlannedOperation is an operation to check with PlanOperations.
+type PlannedOperation struct {
+	Kind        OperationKind `json:"kind"`                  // OpCopy, OpMove or OpDelete
+	Source      string        `json:"source"`                // Path or glob pattern
+	Destination string        `json:"destination,omitempty"` // Target path, or directory of the matches of a pattern
+}
+
+// Plan is the result of PlanOperations, ready to be written as JSON with Plan.JSON.
+type Plan struct {
+	OK          bool       `json:"ok"`          // No step has a problem
+	Steps       []PlanStep `json:"steps"`       // One step per matched source, in the order of the operations
+	BytesCopied int64      `json:"bytesCopied"` // Data written by the copies
+	BytesFreed  int64      `json:"bytesFreed"`  // Data removed by the deletes
+}
+
+// PlanStep is a single file or directory of a Plan.
+type PlanStep struct {
+	Kind        OperationKind `json:"kind"`
+	Source      string        `json:"source"`
+	Destination string        `json:"destination,omitempty"`
+	IsDir       bool          `json:"isDir,omitempty"`
+	Bytes       int64         `json:"bytes"`                // Size of the file or of the directory tree
+	Overwrites  bool          `json:"overwrites,omitempty"` // The destination exists and would be replaced
+	Problems    []string      `json:"problems,omitempty"`   // Why the step would fail, empty when it is fine
+}
+
+// JSON encodes the plan as indented JSON.
+func (plan *Plan) JSON() ([]byte, error) {
+	return json.MarshalIndent(plan, "", "  ")
+}
+
+// PlanOperations resolves the glob patterns of the operations and checks every resulting step,
+// without changing anything, see Operation-plan.go.
+//
+// Parameters:
+//   - ops: The operations, in the order they would run
+//
+// Returns:
+//   - *Plan: The steps with their sizes and problems, Plan.OK is false when any step has a problem
+//   - error: An error if an operation is invalid (unknown kind, missing destination, bad pattern)
+//
+// Example:
+//
+//	plan, err := ufs.PlanOperations(ops)
+//	if err != nil {
+//	    fmt.Printf("Invalid operations: %v\n", err)
+//	    return
+//	}
+//	for _, step := range plan.Steps {
+//	    for _, problem := range step.Problems {
+//	        fmt.Printf("%s %s: %s\n", step.Kind, step.Source, problem)
+//	    }
+//	}
+func (ufs *UFS) PlanOperations(ops []PlannedOperation) (_ *Plan, err error) {
+	defer ufs.recoverPanic("PlanOperations", &err)
+
+	plan := &Plan{Steps: []PlanStep{}}
+	planner := &operationPlanner{ufs: ufs, changes: map[string]planChange{}, needed: map[string]int64{}}
+
+	for _, op := range ops {
+		switch op.Kind {
+		case OpCopy, OpMove:
+			if op.Destination == "" {
+				return nil, fmt.Errorf("PlanOperations: %s of %s needs a destination", op.Kind, op.Source)
+			}
+		case OpDelete:
+		default:
+			return nil, fmt.Errorf("PlanOperations: unknown operation kind %q", op.Kind)
+		}
+
+		steps, err := planner.resolve(op)
+		if err != nil {
+			return nil, ufs.wrapError(err, "PlanOperations")
+		}
+		for _, step := range steps {
+			planner.check(&step)
+			plan.Steps = append(plan.Steps, step)
+		}
+	}
+
+	planner.checkSpace(plan.Steps)
+
+	plan.OK = true
+	for _, step := range plan.Steps {
+		if len(step.Problems) > 0 {
+			plan.OK = false
+		}
+		switch step.Kind {
+		case OpCopy:
+			plan.BytesCopied += step.Bytes
+		case OpDelete:
+			plan.BytesFreed += step.Bytes
+		}
+	}
+	return plan, nil
+}
+
+// operationPlanner tracks the effect of the steps already planned
+type operationPlanner struct {
+	ufs     *UFS
+	changes map[string]planChange // Latest change of the paths by earlier steps, see lookup
+	needed  map[string]int64      // Bytes copied by directory receiving them, see checkSpace
+}
+
+// planChange is the change of a path by an earlier step: moved away or deleted, or written with the
+// content of origin
+type planChange struct {
+	removed bool
+	origin  string // Path on disk holding the content written, for written paths
+}
+
+// record records the change of a path by a step. It replaces the changes of the paths inside it:
+// a directory written or removed after them decides what they are.
+func (planner *operationPlanner) record(path string, change planChange) {
+	prefix := path + string(filepath.Separator)
+	for changed := range planner.changes {
+		if strings.HasPrefix(changed, prefix) {
+			delete(planner.changes, changed)
+		}
+	}
+	planner.changes[path] = change
+}
+
+// lookup returns where the content of path is on disk after the earlier steps: the path itself when no
+// earlier step changed it or its parents, the matching path of the origin when it is inside a path written
+// by an earlier step. removed is the path or parent moved away or deleted by an earlier step, if any.
+func (planner *operationPlanner) lookup(path string) (onDisk string, removed string) {
+	for changed := path; ; changed = filepath.Dir(changed) {
+		if change, ok := planner.changes[changed]; ok {
+			if change.removed {
+				return "", changed
+			}
+			if changed == path {
+				return change.origin, ""
+			}
+			// A directory copied or moved into an existing one is merged with it
+			rel, err := filepath.Rel(changed, path)
+			if err != nil {
+				return path, ""
+			}
+			if origin := filepath.Join(change.origin, rel); pathExistsNoFollow(origin) {
+				return origin, ""
+			}
+			return path, ""
+		}
+		if filepath.Dir(changed) == changed {
+			return path, ""
+		}
+	}
+}
+
+// resolve expands the glob pattern of an operation into steps with absolute paths
+func (planner *operationPlanner) resolve(op PlannedOperation) ([]PlanStep, error) {
+	source, err := filepath.Abs(op.Source)
+	if err != nil {
+		return nil, err
+	}
+	destination := ""
+	if op.Destination != "" {
+		if destination, err = filepath.Abs(op.Destination); err != nil {
+			return nil, err
+		}
+	}
+
+	if !strings.ContainsAny(op.Source, "*?[{") {
+		return []PlanStep{{Kind: op.Kind, Source: source, Destination: destination}}, nil
+	}
+
+	matches, err := planner.ufs.Glob(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return []PlanStep{{Kind: op.Kind, Source: source, Destination: destination, Problems: []string{"pattern matches nothing"}}}, nil
+	}
+
+	steps := make([]PlanStep, len(matches))
+	for i, match := range matches {
+		steps[i] = PlanStep{Kind: op.Kind, Source: match}
+		if destination != "" {
+			steps[i].Destination = filepath.Join(destination, filepath.Base(match))
+		}
+	}
+	return steps, nil
+}
+
+// check fills the size and problems of a step, then records its effect for the next steps
+func (planner *operationPlanner) check(step *PlanStep) {
+	if len(step.Problems) > 0 {
+		return
+	}
+	ufs := planner.ufs
+	problem := func(format string, args ...any) {
+		step.Problems = append(step.Problems, fmt.Sprintf(format, args...))
+	}
+
+	// The source, or what an earlier step writes there
+	source, removed := planner.lookup(step.Source)
+	switch {
+	case removed == step.Source:
+		problem("source is moved or deleted by an earlier step")
+		return
+	case removed != "":
+		problem("source is inside %s, moved or deleted by an earlier step", removed)
+		return
+	}
+	info, err := os.Lstat(source)
+	if err != nil {
+		problem("source: %v", err)
+		return
+	}
+	step.IsDir = info.IsDir()
+	step.Bytes = info.Size()
+	if step.IsDir {
+		step.Bytes = ufs.GetFolderSize(source)
+	}
+	if step.Kind != OpDelete && !planReadable(source, step.IsDir) {
+		problem("source is not readable")
+	}
+	if step.Kind != OpCopy {
+		if err := checkDirectoryWritable(existingAncestor(filepath.Dir(step.Source))); err != nil {
+			problem("source directory: %v", err)
+		}
+		planner.record(step.Source, planChange{removed: true})
+	}
+
+	// The destination
+	if step.Kind != OpDelete {
+		if change, ok := planner.changes[step.Destination]; ok && !change.removed {
+			problem("destination is written by an earlier step")
+		}
+		current, removed := planner.lookup(step.Destination)
+		planner.record(step.Destination, planChange{origin: source})
+
+		if _, err := os.Lstat(current); err == nil && removed == "" {
+			step.Overwrites = true
+			if ufs.opts.Overwrite == FailOnExisting && !step.IsDir {
+				problem("destination exists")
+			}
+		}
+		existing := existingAncestor(filepath.Dir(step.Destination))
+		if err := checkDirectoryWritable(existing); err != nil {
+			problem("destination directory: %v", err)
+		}
+		if step.Kind == OpCopy {
+			planner.needed[existing] += step.Bytes
+		}
+	}
+}
+
+// checkSpace adds a problem to the copies into directories whose file system doesn't have the
+// space available for all the copies made into them
+func (planner *operationPlanner) checkSpace(steps []PlanStep) {
+	full := map[string]string{}
+	for dir, needed := range planner.needed {
+		usage, err := planner.ufs.GetDiskUsage(dir)
+		if err == nil && needed > 0 && uint64(needed) > usage.Available {
+			full[dir] = fmt.Sprintf("not enough space: %d bytes needed, %d available", needed, usage.Available)
+		}
+	}
+	for i := range steps {
+		step := &steps[i]
+		if step.Kind != OpCopy || step.Destination == "" {
+			continue
+		}
+		if problem, ok := full[existingAncestor(filepath.Dir(step.Destination))]; ok {
+			step.Problems = append(step.Problems, problem)
+		}
+	}
+}
+
+// pathExistsNoFollow reports whether something exists at path, without following a final symbolic link
+func pathExistsNoFollow(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// existingAncestor returns path or its closest existing parent directory
+func existingAncestor(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// planReadable reports whether a file can be opened, or the entries of a directory listed
+func planReadable(path string, isDir bool) bool {
+	if isDir {
+		_, err := os.ReadDir(path)
+		return err == nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	file.Close()
+	return true
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd && !dragonfly
+
+package ufs
+
+// checkDirectoryWritable returns nil, write permissions are not checked on this platform
+func checkDirectoryWritable(dir string) error {
+	return nil
+}
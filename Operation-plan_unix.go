@@ -0,0 +1,17 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package ufs
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// checkDirectoryWritable reports whether the process may create and remove entries in dir
+func checkDirectoryWritable(dir string) error {
+	if err := unix.Access(dir, unix.W_OK|unix.X_OK); err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,391 @@
+package ufs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Operation-queue.go contains a queue of file operations persisted to disk, for long migration jobs.
+
+The copies, moves and deletes to do are enqueued first, then run. The queue is saved to a JSON file
+after every change, so when the process is stopped or crashes, opening the same queue file again
+and calling Run resumes with the operations that didn't complete. Failed operations are retried,
+and set aside once they failed too many times, without blocking the rest of the queue.
+
+Operations are idempotent, so an operation interrupted after it did its work but before the queue
+was saved can safely run again: a move whose source is gone and whose destination exists is done,
+deleting a missing path is done, and a copy is simply made again.
+
+	queue, err := ufs.OpenOperationQueue("/var/lib/migrate/queue.json")
+	if err != nil {
+	    return err
+	}
+	if len(queue.Pending()) == 0 {
+	    for _, share := range shares {
+	        queue.Enqueue(ufs.OpCopy, share, filepath.Join("/mnt/new", filepath.Base(share)))
+	    }
+	}
+	done, err := queue.Run(ctx, &ufs.QueueRunOptions{MaxAttempts: 5, RetryDelay: time.Minute})
+
+Functions:
+- OpenOperationQueue: Opens (or creates) a queue persisted to a file.
+*/
+
+// OperationKind is the kind of a queued operation.
+type OperationKind string
+
+const (
+	// OpCopy copies a file or a directory tree to the destination
+	OpCopy OperationKind = "copy"
+	// OpMove moves a file or a directory tree to the destination
+	OpMove OperationKind = "move"
+	// OpDelete deletes a file or a directory tree
+	OpDelete OperationKind = "delete"
+)
+
+// QueuedOperation is an operation of an OperationQueue.
+type QueuedOperation struct {
+	ID          int64         `json:"id"`
+	Kind        OperationKind `json:"kind"`
+	Source      string        `json:"source"`
+	Destination string        `json:"destination,omitempty"` // Empty for OpDelete
+	Attempts    int           `json:"attempts,omitempty"`    // Failed attempts so far
+	LastError   string        `json:"lastError,omitempty"`   // Error of the last failed attempt
+}
+
+// QueueRunOptions controls OperationQueue.Run. The zero value tries every operation 3 times, without delay.
+type QueueRunOptions struct {
+	// MaxAttempts is the number of times an operation is tried before it is set aside as failed, 0 uses 3
+	MaxAttempts int
+
+	// RetryDelay is the time waited before trying a failed operation again
+	RetryDelay time.Duration
+
+	// OnDone is called after every operation that completed, nil disables it
+	OnDone func(op QueuedOperation)
+}
+
+// operationQueueState is the content of a queue file
+type operationQueueState struct {
+	NextID  int64             `json:"nextId"`
+	Pending []QueuedOperation `json:"pending"`
+	Failed  []QueuedOperation `json:"failed"`
+}
+
+// OperationQueue is a queue of file operations persisted to a file, see OpenOperationQueue.
+// Its methods are safe for concurrent use, but only one Run may run at a time, and a queue file must only
+// be opened by one process at a time (see LockDirectory).
+type OperationQueue struct {
+	ufs     *UFS
+	path    string
+	mu      sync.Mutex
+	state   operationQueueState
+	running bool // A Run is running the operations
+}
+
+// OpenOperationQueue opens a queue persisted to a file, loading the operations left by a previous run.
+// The file is created on the first change if it doesn't exist.
+//
+// Parameters:
+//   - path: The absolute or relative path to the queue file
+//
+// Returns:
+//   - *OperationQueue: The queue
+//   - error: An error if the queue file exists but couldn't be read, nil otherwise
+//
+// Example:
+//
+//	queue, err := ufs.OpenOperationQueue("migration.queue.json")
+//	if err != nil {
+//	    fmt.Printf("Error opening queue: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("%d operations left from the last run\n", len(queue.Pending()))
+func (ufs *UFS) OpenOperationQueue(path string) (_ *OperationQueue, err error) {
+	defer ufs.recoverPanic("OpenOperationQueue", &err)
+
+	queue := &OperationQueue{ufs: ufs, path: path}
+	if ufs.PathExists(path) {
+		if err := ufs.ReadJSONFile(path, &queue.state); err != nil {
+			return nil, err
+		}
+	}
+	return queue, nil
+}
+
+// Enqueue adds an operation at the end of the queue and saves the queue.
+//
+// Parameters:
+//   - kind: OpCopy, OpMove or OpDelete
+//   - src: The path of the file or directory to copy, move or delete
+//   - dst: The destination path of copies and moves, ignored by OpDelete
+//
+// Returns:
+//   - int64: The ID of the operation
+//   - error: An error if the operation is invalid or the queue couldn't be saved, nil otherwise
+func (queue *OperationQueue) Enqueue(kind OperationKind, src, dst string) (_ int64, err error) {
+	defer queue.ufs.recoverPanic("OperationQueue.Enqueue", &err)
+
+	switch kind {
+	case OpCopy, OpMove:
+		if dst == "" {
+			return 0, fmt.Errorf("Enqueue: %s operation needs a destination", kind)
+		}
+	case OpDelete:
+		dst = ""
+	default:
+		return 0, fmt.Errorf("Enqueue: unknown operation kind %q", kind)
+	}
+	if src == "" {
+		return 0, fmt.Errorf("Enqueue: %s operation needs a source", kind)
+	}
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	queue.state.NextID++
+	op := QueuedOperation{ID: queue.state.NextID, Kind: kind, Source: src, Destination: dst}
+	queue.state.Pending = append(queue.state.Pending, op)
+	if err := queue.save(); err != nil {
+		queue.state.Pending = queue.state.Pending[:len(queue.state.Pending)-1]
+		return 0, err
+	}
+	return op.ID, nil
+}
+
+// Pending returns the operations left to run, in order
+func (queue *OperationQueue) Pending() []QueuedOperation {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	return append([]QueuedOperation(nil), queue.state.Pending...)
+}
+
+// Failed returns the operations set aside after failing QueueRunOptions.MaxAttempts times
+func (queue *OperationQueue) Failed() []QueuedOperation {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	return append([]QueuedOperation(nil), queue.state.Failed...)
+}
+
+// RetryFailed moves the failed operations back to the end of the queue, with their attempts reset,
+// and saves the queue.
+//
+// Returns:
+//   - int: The number of operations queued again
+//   - error: An error if the queue couldn't be saved, nil otherwise
+func (queue *OperationQueue) RetryFailed() (_ int, err error) {
+	defer queue.ufs.recoverPanic("OperationQueue.RetryFailed", &err)
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	failed := queue.state.Failed
+	for _, op := range failed {
+		op.Attempts = 0
+		queue.state.Pending = append(queue.state.Pending, op)
+	}
+	queue.state.Failed = nil
+	return len(failed), queue.save()
+}
+
+// Run runs the pending operations in order, saving the queue after each one. An operation that fails
+// is retried after opts.RetryDelay, and set aside in Failed once it failed opts.MaxAttempts times,
+// in this run or previous ones. Run stops when the queue is empty or the context is cancelled;
+// the operations left are kept for the next run. A queue is run by one Run at a time: calling Run
+// while another is running returns an error. In dry-run mode, the pending operations are recorded
+// without being run, and the queue is left unchanged.
+//
+// Parameters:
+//   - ctx: The context controlling cancellation, checked between operations and while waiting to retry
+//   - opts: The retry settings, nil uses the defaults
+//
+// Returns:
+//   - int: The number of operations completed by this run
+//   - error: The error of the context if it was cancelled, an error if the queue is already running
+//     or couldn't be saved, nil otherwise (even when operations failed, see Failed)
+func (queue *OperationQueue) Run(ctx context.Context, opts *QueueRunOptions) (done int, err error) {
+	defer queue.ufs.recoverPanic("OperationQueue.Run", &err)
+
+	// Cancelled by the shutdown manager of the instance, if any
+	ctx, stop, err := queue.ufs.trackOperation(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer stop()
+
+	if opts == nil {
+		opts = &QueueRunOptions{}
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 3
+	}
+
+	// The operation being run stays first in Pending, to be run again after a crash: a second runner
+	// would run it at the same time
+	queue.mu.Lock()
+	if queue.running {
+		queue.mu.Unlock()
+		return 0, fmt.Errorf("OperationQueue.Run: the queue is already running")
+	}
+	queue.running = true
+	pending := append([]QueuedOperation{}, queue.state.Pending...)
+	queue.mu.Unlock()
+	defer func() {
+		queue.mu.Lock()
+		queue.running = false
+		queue.mu.Unlock()
+	}()
+
+	// In dry-run mode the pending operations are recorded in order, the queue and its file are left unchanged
+	if queue.ufs.opts.DryRun {
+		for _, op := range pending {
+			if err := ctx.Err(); err != nil {
+				return done, err
+			}
+			if err := queue.ufs.runQueuedOperation(op); err != nil {
+				return done, err
+			}
+			done++
+		}
+		return done, nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return done, err
+		}
+
+		queue.mu.Lock()
+		if len(queue.state.Pending) == 0 {
+			queue.mu.Unlock()
+			return done, nil
+		}
+		op := queue.state.Pending[0]
+		queue.mu.Unlock()
+
+		opErr := queue.ufs.runQueuedOperation(op)
+
+		queue.mu.Lock()
+		queue.removePending(op.ID)
+		if opErr == nil {
+			done++
+		} else {
+			op.Attempts++
+			op.LastError = opErr.Error()
+			if op.Attempts >= maxAttempts {
+				queue.state.Failed = append(queue.state.Failed, op)
+			} else {
+				queue.state.Pending = append([]QueuedOperation{op}, queue.state.Pending...)
+			}
+		}
+		saveErr := queue.save()
+		queue.mu.Unlock()
+
+		if saveErr != nil {
+			return done, saveErr
+		}
+		if opErr == nil {
+			if opts.OnDone != nil {
+				opts.OnDone(op)
+			}
+			continue
+		}
+
+		if op.Attempts < maxAttempts && opts.RetryDelay > 0 {
+			select {
+			case <-ctx.Done():
+				return done, ctx.Err()
+			case <-time.After(opts.RetryDelay):
+			}
+		}
+	}
+}
+
+// removePending removes an operation from Pending, the caller must hold the lock
+func (queue *OperationQueue) removePending(id int64) {
+	for i, op := range queue.state.Pending {
+		if op.ID == id {
+			queue.state.Pending = append(queue.state.Pending[:i:i], queue.state.Pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// save writes the queue file, the caller must hold the lock
+func (queue *OperationQueue) save() error {
+	return queue.ufs.WriteJSONFile(queue.path, queue.state, "  ")
+}
+
+// runQueuedOperation runs an operation, succeeding if its work was already done
+func (ufs *UFS) runQueuedOperation(op QueuedOperation) error {
+	switch op.Kind {
+	case OpDelete:
+		if ufs.dryRun("OperationQueue.Run", DryRunRemoveAll, op.Source, "") {
+			return nil
+		}
+		return os.RemoveAll(op.Source)
+
+	case OpCopy:
+		if ufs.dryRun("OperationQueue.Run", DryRunCopy, op.Destination, op.Source) {
+			return nil
+		}
+		info, err := os.Stat(op.Source)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return ufs.CopyDirectoryParallel(op.Source, op.Destination, nil)
+		}
+		return ufs.CopyFileWithPermissions(op.Source, op.Destination)
+
+	case OpMove:
+		if ufs.dryRun("OperationQueue.Run", DryRunMove, op.Destination, op.Source) {
+			return nil
+		}
+		info, err := os.Lstat(op.Source)
+		if os.IsNotExist(err) && ufs.PathExists(op.Destination) {
+			return nil // Moved before the queue was saved
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(op.Destination), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(op.Source, op.Destination); err == nil {
+			return nil
+		}
+		// Across file systems: copy, then delete the source. Like MoveDirectoryWithProgress, the copy
+		// includes hidden entries whatever the options, and the source is kept if anything was left out.
+		if info.IsDir() {
+			copier := &directoryCopier{
+				ufs:           ufs,
+				opts:          &CopyDirectoryOptions{StopOnError: true},
+				src:           op.Source,
+				dst:           op.Destination,
+				report:        &CopyReport{},
+				includeHidden: true,
+			}
+			if err := copier.copyDirectory(op.Source, op.Destination, info, nil); err != nil {
+				return err
+			}
+			if len(copier.report.Skipped) > 0 {
+				return fmt.Errorf("special files can't be moved, %s was kept: %s", op.Source, strings.Join(copier.report.Skipped, ", "))
+			}
+		} else if err := ufs.CopyFileWithPermissions(op.Source, op.Destination); err != nil {
+			return err
+		}
+		return os.RemoveAll(op.Source)
+
+	default:
+		return fmt.Errorf("unknown operation kind %q", op.Kind)
+	}
+}
@@ -22,12 +22,12 @@ package ufs
 
 import (
 	"errors"
+	"io/fs"
 	"os"
 	"os/user"
 	"path/filepath"
 	"runtime"
 	"strings"
-	"syscall"
 )
 
 // PathExists checks if a file or directory exists at the specified path.
@@ -43,8 +43,10 @@ import (
 //	if ufs.PathExists("/path/to/check") {
 //	    fmt.Println("Path exists!")
 //	}
-func (ufs *UFS) PathExists(path string) bool {
-	_, err := os.Stat(path)
+func (ufs *UFS) PathExists(path string) (ok bool) {
+	defer ufs.recoverPanicBool("PathExists", &ok)
+
+	_, err := ufs.backend().Stat(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return false
@@ -68,8 +70,10 @@ func (ufs *UFS) PathExists(path string) bool {
 //	if ufs.IsFile("/path/to/check") {
 //	    fmt.Println("This is a file!")
 //	}
-func (ufs *UFS) IsFile(path string) bool {
-	info, err := os.Stat(path)
+func (ufs *UFS) IsFile(path string) (ok bool) {
+	defer ufs.recoverPanicBool("IsFile", &ok)
+
+	info, err := ufs.backend().Stat(path)
 	if err != nil {
 		ufs.handleError(err, "IsFile")
 		return false
@@ -90,8 +94,10 @@ func (ufs *UFS) IsFile(path string) bool {
 //	if ufs.IsDirectory("/path/to/check") {
 //	    fmt.Println("This is a directory!")
 //	}
-func (ufs *UFS) IsDirectory(path string) bool {
-	info, err := os.Stat(path)
+func (ufs *UFS) IsDirectory(path string) (ok bool) {
+	defer ufs.recoverPanicBool("IsDirectory", &ok)
+
+	info, err := ufs.backend().Stat(path)
 	if err != nil {
 		ufs.handleError(err, "IsDirectory")
 		return false
@@ -112,12 +118,14 @@ func (ufs *UFS) IsDirectory(path string) bool {
 //	if ufs.IsDirectoryEmpty("/path/to/directory") {
 //	    fmt.Println("The directory is empty!")
 //	}
-func (ufs *UFS) IsDirectoryEmpty(path string) bool {
+func (ufs *UFS) IsDirectoryEmpty(path string) (ok bool) {
+	defer ufs.recoverPanicBool("IsDirectoryEmpty", &ok)
+
 	if !ufs.IsDirectory(path) {
 		return false
 	}
 
-	entries, err := os.ReadDir(path)
+	entries, err := ufs.backend().ReadDir(path)
 	if err != nil {
 		ufs.handleError(err, "IsDirectoryEmpty")
 		return false
@@ -139,12 +147,14 @@ func (ufs *UFS) IsDirectoryEmpty(path string) bool {
 //	if ufs.IsFileEmpty("/path/to/file.txt") {
 //	    fmt.Println("The file is empty!")
 //	}
-func (ufs *UFS) IsFileEmpty(path string) bool {
+func (ufs *UFS) IsFileEmpty(path string) (ok bool) {
+	defer ufs.recoverPanicBool("IsFileEmpty", &ok)
+
 	if !ufs.IsFile(path) {
 		return false
 	}
 
-	info, err := os.Stat(path)
+	info, err := ufs.backend().Stat(path)
 	if err != nil {
 		ufs.handleError(err, "IsFileEmpty")
 		return false
@@ -166,7 +176,9 @@ func (ufs *UFS) IsFileEmpty(path string) bool {
 //	if ufs.IsInSystemPath("/usr/bin/python") {
 //	    fmt.Println("This is a system path!")
 //	}
-func (ufs *UFS) IsInSystemPath(path string) bool {
+func (ufs *UFS) IsInSystemPath(path string) (ok bool) {
+	defer ufs.recoverPanicBool("IsInSystemPath", &ok)
+
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		ufs.handleError(err, "IsInSystemPath")
@@ -217,7 +229,9 @@ func (ufs *UFS) IsInSystemPath(path string) bool {
 //	if ufs.IsInUserPath("~/Documents/file.txt") {
 //	    fmt.Println("This is in the user's home directory!")
 //	}
-func (ufs *UFS) IsInUserPath(path string) bool {
+func (ufs *UFS) IsInUserPath(path string) (ok bool) {
+	defer ufs.recoverPanicBool("IsInUserPath", &ok)
+
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		ufs.handleError(err, "IsInUserPath")
@@ -246,7 +260,9 @@ func (ufs *UFS) IsInUserPath(path string) bool {
 //	if ufs.IsInCurrentPath("./data/file.txt") {
 //	    fmt.Println("This is in the current working directory!")
 //	}
-func (ufs *UFS) IsInCurrentPath(path string) bool {
+func (ufs *UFS) IsInCurrentPath(path string) (ok bool) {
+	defer ufs.recoverPanicBool("IsInCurrentPath", &ok)
+
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		ufs.handleError(err, "IsInCurrentPath")
@@ -275,34 +291,19 @@ func (ufs *UFS) IsInCurrentPath(path string) bool {
 //	if ufs.IsFileHidden("/path/to/.hidden_file") {
 //	    fmt.Println("This is a hidden file!")
 //	}
-func (ufs *UFS) IsFileHidden(path string) bool {
+func (ufs *UFS) IsFileHidden(path string) (ok bool) {
+	defer ufs.recoverPanicBool("IsFileHidden", &ok)
+
 	if !ufs.IsFile(path) {
 		return false
 	}
 
-	// Get the base name of the file
-	baseName := filepath.Base(path)
-
-	// On Unix-like systems, files starting with a dot are hidden
-	if runtime.GOOS != "windows" {
-		return strings.HasPrefix(baseName, ".")
-	}
-
-	// On Windows, use file attributes
-	fileInfo, err := os.Stat(path)
+	fileInfo, err := ufs.backend().Stat(path)
 	if err != nil {
 		ufs.handleError(err, "IsFileHidden")
 		return false
 	}
-
-	// Check if the file has the hidden attribute (Windows only)
-	// The hidden attribute is represented by the constant 0x2 (FILE_ATTRIBUTE_HIDDEN)
-	if runtime.GOOS == "windows" {
-		attributes := fileInfo.Sys().(*syscall.Win32FileAttributeData).FileAttributes
-		return attributes&0x2 != 0
-	}
-
-	return false
+	return isHiddenEntry(fs.FileInfoToDirEntry(fileInfo))
 }
 
 // IsFileExecutable checks if a file is executable by the current user.
@@ -318,7 +319,9 @@ func (ufs *UFS) IsFileHidden(path string) bool {
 //	if ufs.IsFileExecutable("/path/to/script.sh") {
 //	    fmt.Println("This file is executable!")
 //	}
-func (ufs *UFS) IsFileExecutable(path string) bool {
+func (ufs *UFS) IsFileExecutable(path string) (ok bool) {
+	defer ufs.recoverPanicBool("IsFileExecutable", &ok)
+
 	if !ufs.IsFile(path) {
 		return false
 	}
@@ -336,7 +339,7 @@ func (ufs *UFS) IsFileExecutable(path string) bool {
 	}
 
 	// On Unix-like systems, check execution permission
-	info, err := os.Stat(path)
+	info, err := ufs.backend().Stat(path)
 	if err != nil {
 		ufs.handleError(err, "IsFileExecutable")
 		return false
@@ -358,13 +361,15 @@ func (ufs *UFS) IsFileExecutable(path string) bool {
 //	if ufs.IsFileReadable("/path/to/file.txt") {
 //	    fmt.Println("This file is readable!")
 //	}
-func (ufs *UFS) IsFileReadable(path string) bool {
+func (ufs *UFS) IsFileReadable(path string) (ok bool) {
+	defer ufs.recoverPanicBool("IsFileReadable", &ok)
+
 	if !ufs.IsFile(path) {
 		return false
 	}
 
 	// Try to open the file for reading
-	file, err := os.Open(path)
+	file, err := ufs.backend().OpenFile(path, os.O_RDONLY, 0)
 	if err != nil {
 		ufs.handleError(err, "IsFileReadable")
 		return false
@@ -387,13 +392,15 @@ func (ufs *UFS) IsFileReadable(path string) bool {
 //	if ufs.IsFileWritable("/path/to/file.txt") {
 //	    fmt.Println("This file is writable!")
 //	}
-func (ufs *UFS) IsFileWritable(path string) bool {
+func (ufs *UFS) IsFileWritable(path string) (ok bool) {
+	defer ufs.recoverPanicBool("IsFileWritable", &ok)
+
 	if !ufs.IsFile(path) {
 		return false
 	}
 
 	// Try to open the file for writing (append mode to avoid destroying content)
-	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0666)
+	file, err := ufs.backend().OpenFile(path, os.O_WRONLY|os.O_APPEND, 0666)
 	if err != nil {
 		if errors.Is(err, os.ErrPermission) {
 			return false
@@ -419,34 +426,34 @@ func (ufs *UFS) IsFileWritable(path string) bool {
 //	if ufs.IsDirectoryHidden("/path/to/.hidden_dir") {
 //	    fmt.Println("This is a hidden directory!")
 //	}
-func (ufs *UFS) IsDirectoryHidden(path string) bool {
+func (ufs *UFS) IsDirectoryHidden(path string) (ok bool) {
+	defer ufs.recoverPanicBool("IsDirectoryHidden", &ok)
+
 	if !ufs.IsDirectory(path) {
 		return false
 	}
 
-	// Get the base name of the directory
-	baseName := filepath.Base(path)
-
-	// On Unix-like systems, directories starting with a dot are hidden
-	if runtime.GOOS != "windows" {
-		return strings.HasPrefix(baseName, ".")
-	}
-
-	// On Windows, use file attributes
-	fileInfo, err := os.Stat(path)
+	fileInfo, err := ufs.backend().Stat(path)
 	if err != nil {
 		ufs.handleError(err, "IsDirectoryHidden")
 		return false
 	}
+	return isHiddenEntry(fs.FileInfoToDirEntry(fileInfo))
+}
 
-	// Check if the directory has the hidden attribute (Windows only)
-	// The hidden attribute is represented by the constant 0x2 (FILE_ATTRIBUTE_HIDDEN)
-	if runtime.GOOS == "windows" {
-		attributes := fileInfo.Sys().(*syscall.Win32FileAttributeData).FileAttributes
-		return attributes&0x2 != 0
+// isHiddenEntry reports whether a file or directory is hidden according to the OS conventions:
+// a name starting with a dot on Unix-like systems, the hidden attribute on Windows
+func isHiddenEntry(entry fs.DirEntry) bool {
+	if runtime.GOOS != "windows" {
+		return strings.HasPrefix(entry.Name(), ".")
 	}
+	info, err := entry.Info()
+	return err == nil && hasHiddenAttribute(info)
+}
 
-	return false
+// skipHidden reports whether an entry is left out because it is hidden and Options.ExcludeHidden is set
+func (ufs *UFS) skipHidden(entry fs.DirEntry) bool {
+	return ufs.opts.ExcludeHidden && isHiddenEntry(entry)
 }
 
 // IsDirectoryReadable checks if a directory is readable by the current user.
@@ -462,13 +469,15 @@ func (ufs *UFS) IsDirectoryHidden(path string) bool {
 //	if ufs.IsDirectoryReadable("/path/to/directory") {
 //	    fmt.Println("This directory is readable!")
 //	}
-func (ufs *UFS) IsDirectoryReadable(path string) bool {
+func (ufs *UFS) IsDirectoryReadable(path string) (ok bool) {
+	defer ufs.recoverPanicBool("IsDirectoryReadable", &ok)
+
 	if !ufs.IsDirectory(path) {
 		return false
 	}
 
 	// Try to read the directory entries
-	_, err := os.ReadDir(path)
+	_, err := ufs.backend().ReadDir(path)
 	if err != nil {
 		ufs.handleError(err, "IsDirectoryReadable")
 		return false
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package ufs
+
+import "os"
+
+// hasHiddenAttribute always reports false as only Windows has a hidden file attribute.
+// On other systems hidden files are detected by their leading dot.
+func hasHiddenAttribute(info os.FileInfo) bool {
+	return false
+}
@@ -0,0 +1,17 @@
+package ufs
+
+import (
+	"os"
+	"syscall"
+)
+
+// hasHiddenAttribute reports whether the FILE_ATTRIBUTE_HIDDEN (0x2) flag is set.
+// FileInfo values not backed by Win32 attribute data (e.g. from unusual filesystems
+// or virtual FileInfo implementations) are treated as not hidden instead of panicking.
+func hasHiddenAttribute(info os.FileInfo) bool {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok || data == nil {
+		return false
+	}
+	return data.FileAttributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0
+}
@@ -0,0 +1,167 @@
+package ufs
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Path-template.go contains functions to build dated output paths from templates,
+e.g. "backups/{yyyy}/{MM}/{dd}/app-{hostname}.zip" -> "backups/2025/03/14/app-web1.zip".
+
+The placeholders are:
+- {yyyy}, {yy}: The year, on 4 or 2 digits
+- {MM}, {dd}: The month and the day of the month, on 2 digits
+- {HH}, {mm}, {ss}: The hour (24-hour clock), minutes and seconds, on 2 digits
+- {date}: The date as yyyy-MM-dd, {time}: the time as HHmmss
+- {unix}: The Unix time in seconds
+- {hostname}: The host name of the machine, with path separators replaced by "_"
+- {pid}: The process ID of the program
+
+Dates and times are in the local time zone; use ExpandPathTemplateAt with t.UTC() for UTC paths.
+
+With Options.ExpandOutputPaths set, the output paths of the compression and backup functions
+(CompressDirectory, CompressDirectoryContext, CompressDirectoryWithOptions, CompressFile,
+CompressFileWithOptions, CompressDirectoryCheckpointed, CompressDirectorySplit,
+CompressDirectoryIncremental and CompressWithSystemCommand) are expanded too, so dated archives can be
+written with e.g. ufs.CompressDirectory("/data", "/backups/data-{date}.zip"). In these paths, braces that
+are not one of the placeholders above are kept as they are. By default the output paths are used as they
+are, braces included.
+
+Functions:
+- ExpandPathTemplate: Replaces the placeholders of a path with the current date, time and host name.
+- ExpandPathTemplateAt: Replaces the placeholders of a path with the given date and time.
+*/
+
+// pathPlaceholder matches the placeholders of a path template
+var pathPlaceholder = regexp.MustCompile(`\{(\w+)\}`)
+
+// ExpandPathTemplate replaces the placeholders of a path template (see the top of Path-template.go)
+// with the current date and time, the host name or the process ID.
+//
+// Parameters:
+//   - template: The path with placeholders, e.g. "backups/{yyyy}/{MM}/{dd}/app-{hostname}.zip"
+//
+// Returns:
+//   - string: The path with the placeholders replaced
+//   - error: An error if the template has an unknown placeholder or the host name couldn't be read, nil otherwise
+//
+// Example:
+//
+//	path, err := ufs.ExpandPathTemplate("logs/{yyyy}-{MM}/app-{hostname}-{date}.log")
+//	if err != nil {
+//	    fmt.Printf("Invalid template: %v\n", err)
+//	    return
+//	}
+//	ufs.AppendStringToFile(path, "Started\n") // logs/2025-03/app-web1-2025-03-14.log
+func (ufs *UFS) ExpandPathTemplate(template string) (_ string, err error) {
+	defer ufs.recoverPanic("ExpandPathTemplate", &err)
+
+	path, err := expandPathTemplate(template, time.Now(), true)
+	if err != nil {
+		return "", ufs.wrapError(err, "ExpandPathTemplate")
+	}
+	return path, nil
+}
+
+// ExpandPathTemplateAt replaces the placeholders of a path template like ExpandPathTemplate,
+// using the given date and time instead of the current one, e.g. to name a backup after the
+// time it was started, or for UTC paths.
+//
+// Parameters:
+//   - template: The path with placeholders, e.g. "reports/{yyyy}/{MM}/report-{dd}.csv"
+//   - t: The date and time to use, in its own time zone
+//
+// Returns:
+//   - string: The path with the placeholders replaced
+//   - error: An error if the template has an unknown placeholder or the host name couldn't be read, nil otherwise
+//
+// Example:
+//
+//	yesterday := time.Now().AddDate(0, 0, -1)
+//	path, err := ufs.ExpandPathTemplateAt("reports/{yyyy}/{MM}/report-{dd}.csv", yesterday)
+func (ufs *UFS) ExpandPathTemplateAt(template string, t time.Time) (_ string, err error) {
+	defer ufs.recoverPanic("ExpandPathTemplateAt", &err)
+
+	path, err := expandPathTemplate(template, t, true)
+	if err != nil {
+		return "", ufs.wrapError(err, "ExpandPathTemplateAt")
+	}
+	return path, nil
+}
+
+// expandOutputPath expands the placeholders of the output path of a compression or backup function
+// when Options.ExpandOutputPaths is set. Unknown placeholders are kept, as they may be part of actual file names.
+func (ufs *UFS) expandOutputPath(path string) (string, error) {
+	if !ufs.opts.ExpandOutputPaths || !strings.Contains(path, "{") {
+		return path, nil
+	}
+	return expandPathTemplate(path, time.Now(), false)
+}
+
+// expandPathTemplate replaces the placeholders of template with their value at t.
+// Unknown placeholders are an error when strict, and kept otherwise.
+func expandPathTemplate(template string, t time.Time, strict bool) (string, error) {
+	var err error
+	path := pathPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		if err != nil {
+			return placeholder
+		}
+		value, ok, valueErr := pathPlaceholderValue(placeholder[1:len(placeholder)-1], t)
+		switch {
+		case valueErr != nil:
+			err = valueErr
+		case !ok && strict:
+			err = fmt.Errorf("unknown placeholder %s in path template %q", placeholder, template)
+		case !ok:
+			return placeholder
+		}
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// pathPlaceholderValue returns the value of the placeholder named name at t, false if it is not a placeholder
+func pathPlaceholderValue(name string, t time.Time) (string, bool, error) {
+	var value string
+	switch name {
+	case "yyyy":
+		value = t.Format("2006")
+	case "yy":
+		value = t.Format("06")
+	case "MM":
+		value = t.Format("01")
+	case "dd":
+		value = t.Format("02")
+	case "HH":
+		value = t.Format("15")
+	case "mm":
+		value = t.Format("04")
+	case "ss":
+		value = t.Format("05")
+	case "date":
+		value = t.Format(time.DateOnly)
+	case "time":
+		value = t.Format("150405")
+	case "unix":
+		value = strconv.FormatInt(t.Unix(), 10)
+	case "pid":
+		value = strconv.Itoa(os.Getpid())
+	case "hostname":
+		hostname, err := os.Hostname()
+		if err != nil {
+			return "", true, err
+		}
+		value = strings.NewReplacer("/", "_", `\`, "_").Replace(hostname)
+	default:
+		return "", false, nil
+	}
+	return value, true, nil
+}
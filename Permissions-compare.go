@@ -0,0 +1,153 @@
+package ufs
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+/*
+Permissions-compare.go contains functions to compare the permissions and owners of two directory trees.
+
+Copies between file systems (NTFS or FAT drives, network shares, cloud storage, archives) often keep the
+content of files but silently lose their modes and owners: scripts are no longer executable, private keys
+become world readable, everything belongs to root. ComparePermissions finds these differences, which
+content comparisons such as DiffDirectories don't report.
+
+Owners are compared on Unix systems only. On Windows, modes only reflect the read-only attribute.
+
+Functions:
+- ComparePermissions: Reports the mode and owner differences between the entries of two trees.
+*/
+
+// permissionBits are the mode bits compared by ComparePermissions
+const permissionBits = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// PermissionDifference is an entry whose mode or owner differs between two trees.
+type PermissionDifference struct {
+	Path   string      // Path relative to the roots
+	ModeA  fs.FileMode // Permission bits in the first tree
+	ModeB  fs.FileMode // Permission bits in the second tree
+	OwnerA string      // "uid:gid" in the first tree, empty where owners are not available
+	OwnerB string      // "uid:gid" in the second tree, empty where owners are not available
+}
+
+// ModeChanged reports whether the permission bits differ
+func (d PermissionDifference) ModeChanged() bool {
+	return d.ModeA != d.ModeB
+}
+
+// OwnerChanged reports whether the owner or the group differs
+func (d PermissionDifference) OwnerChanged() bool {
+	return d.OwnerA != d.OwnerB
+}
+
+// String describes the difference, e.g. "bin/deploy.sh: mode -rwxr-xr-x -> -rw-r--r--"
+func (d PermissionDifference) String() string {
+	description := d.Path + ":"
+	if d.ModeChanged() {
+		description += fmt.Sprintf(" mode %s -> %s", d.ModeA, d.ModeB)
+	}
+	if d.OwnerChanged() {
+		description += fmt.Sprintf(" owner %s -> %s", d.OwnerA, d.OwnerB)
+	}
+	return description
+}
+
+// ComparePermissions compares the permission bits (including setuid, setgid and sticky) and the owners
+// of the files and directories present in both trees, whatever their content.
+// Entries present in only one tree are ignored, and symbolic links are compared by owner only,
+// their mode being meaningless.
+//
+// Parameters:
+//   - a: The absolute or relative path to the first directory, e.g. the source of a migration
+//   - b: The absolute or relative path to the second directory, e.g. the migrated copy
+//
+// Returns:
+//   - []PermissionDifference: The entries whose mode or owner differ, in walk order, empty if none
+//   - error: An error if either tree couldn't be read, nil otherwise
+//
+// Example:
+//
+//	diffs, err := ufs.ComparePermissions("/srv/app", "/mnt/new-disk/app")
+//	if err != nil {
+//	    fmt.Printf("Error comparing permissions: %v\n", err)
+//	    return
+//	}
+//	for _, diff := range diffs {
+//	    fmt.Println(diff)
+//	}
+func (ufs *UFS) ComparePermissions(a, b string) (_ []PermissionDifference, err error) {
+	defer ufs.recoverPanic("ComparePermissions", &err)
+
+	if err := ufs.requireOS("ComparePermissions"); err != nil {
+		return nil, err
+	}
+
+	if !ufs.IsDirectory(a) {
+		return nil, ufs.misuseError("ComparePermissions", "path is not a directory", a)
+	}
+	if !ufs.IsDirectory(b) {
+		return nil, ufs.misuseError("ComparePermissions", "path is not a directory", b)
+	}
+
+	diffs := []PermissionDifference{}
+	err = filepath.WalkDir(a, func(pathA string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(pathA, err, WalkAbort, "ComparePermissions")
+		}
+
+		rel, err := filepath.Rel(a, pathA)
+		if err != nil {
+			return err
+		}
+		infoB, err := os.Lstat(filepath.Join(b, rel))
+		if os.IsNotExist(err) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if err != nil {
+			return ufs.decideWalkError(filepath.Join(b, rel), err, WalkAbort, "ComparePermissions")
+		}
+		infoA, err := d.Info()
+		if err != nil {
+			return ufs.decideWalkError(pathA, err, WalkAbort, "ComparePermissions")
+		}
+
+		diff := PermissionDifference{
+			Path:   rel,
+			ModeA:  infoA.Mode() & permissionBits,
+			ModeB:  infoB.Mode() & permissionBits,
+			OwnerA: fileOwner(infoA),
+			OwnerB: fileOwner(infoB),
+		}
+		if infoA.Mode()&fs.ModeSymlink != 0 || infoB.Mode()&fs.ModeSymlink != 0 {
+			diff.ModeA, diff.ModeB = 0, 0
+		}
+		if diff.ModeChanged() || diff.OwnerChanged() {
+			diffs = append(diffs, diff)
+		}
+
+		// Don't descend into a directory replaced by a file
+		if d.IsDir() && !infoB.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, ufs.wrapError(err, "ComparePermissions")
+	}
+	return diffs, nil
+}
+
+// fileOwner returns the owner of a file as "uid:gid", empty where owners are not available
+func fileOwner(info os.FileInfo) string {
+	owner, ok := statOwner(info)
+	if !ok {
+		return ""
+	}
+	return owner.UID + ":" + owner.GID
+}
@@ -0,0 +1,228 @@
+package ufs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+)
+
+/*
+Releases.go contains functions for the release directory deployment layout:
+
+	/srv/app/releases/2024-05-01-1200/
+	/srv/app/releases/2024-05-02-0930/
+	/srv/app/current -> releases/2024-05-02-0930
+
+Every deploy goes to a new release directory, then the "current" symbolic link is switched to it.
+UpdateCurrentSymlink switches the link atomically: a temporary link is created next to it and renamed
+over it, so the link always points to a complete release, never to nothing. Rolling back is switching
+the link to the previous release, and PruneOldReleases removes the oldest releases, never the current one.
+
+Releases are ordered by their names, which must therefore sort in deploy order: timestamps such as
+"2024-05-02-0930" or versions such as "v1.10.0", compared in natural order (see Listing-order.go) so
+"v1.10.0" comes after "v1.9.0". The modification times of the directories are not used, as they
+change whenever an entry of a release is added, removed or renamed, and copies or restores reset them.
+
+On Windows, creating symbolic links may require administrator rights or the developer mode,
+and replacing the link is not atomic.
+
+Functions:
+- UpdateCurrentSymlink: Atomically points a symbolic link to a new target.
+- ListReleases: Lists the release directories, oldest first.
+- PruneOldReleases: Deletes the oldest releases, keeping the most recent ones and the current one.
+*/
+
+// Release is a release directory of a deployment.
+type Release struct {
+	Name    string    // Directory name, a timestamp or a version giving the order of the releases
+	Path    string    // Path of the directory
+	ModTime time.Time // Modification time of the directory, for information only
+}
+
+// UpdateCurrentSymlink points the symbolic link linkPath to newTarget, creating it if it doesn't exist.
+// The link is replaced atomically: a temporary link is renamed over it, so programs following it
+// see either the old or the new target, never a missing link.
+// A relative newTarget is relative to the directory of the link, like any symbolic link target.
+//
+// Parameters:
+//   - linkPath: The absolute or relative path to the symbolic link, e.g. "/srv/app/current"
+//   - newTarget: The target of the link, e.g. "releases/2024-05-02-0930"
+//
+// Returns:
+//   - error: An error if linkPath exists and is not a symbolic link, or the link couldn't be replaced, nil otherwise
+//
+// Example:
+//
+//	release := filepath.Join("/srv/app/releases", time.Now().Format("2006-01-02-1504"))
+//	// ... deploy to release ...
+//	err := ufs.UpdateCurrentSymlink("/srv/app/current", filepath.Join("releases", filepath.Base(release)))
+//	if err != nil {
+//	    fmt.Printf("Error switching release: %v\n", err)
+//	}
+func (ufs *UFS) UpdateCurrentSymlink(linkPath, newTarget string) (err error) {
+	defer ufs.recoverPanic("UpdateCurrentSymlink", &err)
+
+	if err := ufs.requireOS("UpdateCurrentSymlink"); err != nil {
+		return err
+	}
+
+	if newTarget == "" {
+		return fmt.Errorf("UpdateCurrentSymlink: target can't be empty")
+	}
+	if err := ufs.requireCapability(CapabilitySymlinks, "UpdateCurrentSymlink", linkPath); err != nil {
+		return err
+	}
+
+	// Never replace a real file or directory
+	if info, err := os.Lstat(linkPath); err == nil && info.Mode()&os.ModeSymlink == 0 {
+		return fmt.Errorf("path is not a symbolic link: %s", linkPath)
+	} else if err != nil && !os.IsNotExist(err) {
+		return ufs.wrapError(err, "UpdateCurrentSymlink")
+	}
+
+	temp := filepath.Join(filepath.Dir(linkPath),
+		"."+filepath.Base(linkPath)+".tmp-"+strconv.FormatInt(time.Now().UnixNano(), 36))
+	if err := os.Symlink(newTarget, temp); err != nil {
+		return ufs.wrapError(err, "UpdateCurrentSymlink")
+	}
+
+	err = os.Rename(temp, linkPath)
+	if err != nil && runtime.GOOS == "windows" {
+		// Windows can't rename over a directory symbolic link
+		if removeErr := os.Remove(linkPath); removeErr == nil || os.IsNotExist(removeErr) {
+			err = os.Rename(temp, linkPath)
+		}
+	}
+	if err != nil {
+		os.Remove(temp)
+		return ufs.wrapError(err, "UpdateCurrentSymlink")
+	}
+	return nil
+}
+
+// ListReleases lists the release directories of a releases folder, oldest first.
+// Releases are ordered by name in natural order, see Releases.go.
+//
+// Parameters:
+//   - releasesDir: The absolute or relative path to the folder holding the release directories
+//
+// Returns:
+//   - []Release: The release directories, oldest first
+//   - error: An error if the folder couldn't be read, nil otherwise
+//
+// Example:
+//
+//	releases, err := ufs.ListReleases("/srv/app/releases")
+//	if err != nil {
+//	    fmt.Printf("Error listing releases: %v\n", err)
+//	    return
+//	}
+//	if len(releases) >= 2 {
+//	    previous := releases[len(releases)-2]
+//	    fmt.Println("Rolling back to", previous.Name)
+//	    ufs.UpdateCurrentSymlink("/srv/app/current", previous.Path)
+//	}
+func (ufs *UFS) ListReleases(releasesDir string) (_ []Release, err error) {
+	defer ufs.recoverPanic("ListReleases", &err)
+
+	if err := ufs.requireOS("ListReleases"); err != nil {
+		return nil, err
+	}
+
+	if !ufs.IsDirectory(releasesDir) {
+		return nil, ufs.misuseError("ListReleases", "path is not a directory", releasesDir)
+	}
+
+	entries, err := os.ReadDir(releasesDir)
+	if err != nil {
+		return nil, ufs.wrapError(err, "ListReleases")
+	}
+
+	releases := []Release{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed in the meantime
+		}
+		releases = append(releases, Release{
+			Name:    entry.Name(),
+			Path:    filepath.Join(releasesDir, entry.Name()),
+			ModTime: info.ModTime(),
+		})
+	}
+
+	sort.Slice(releases, func(i, j int) bool {
+		return naturalLess(releases[i].Name, releases[j].Name)
+	})
+	return releases, nil
+}
+
+// PruneOldReleases deletes the oldest release directories in the order of ListReleases, keeping the
+// keep most recent ones.
+// The release the current link points to is never deleted, even when it is older.
+//
+// Parameters:
+//   - releasesDir: The absolute or relative path to the folder holding the release directories
+//   - currentLink: The absolute or relative path to the current symbolic link, "" if there is none
+//   - keep: The number of most recent releases to keep, at least 1
+//
+// Returns:
+//   - []Release: The releases deleted, or that would be in dry-run mode
+//   - error: An error if a release couldn't be deleted, nil otherwise
+//
+// Example:
+//
+//	pruned, err := ufs.PruneOldReleases("/srv/app/releases", "/srv/app/current", 5)
+//	if err != nil {
+//	    fmt.Printf("Error pruning releases: %v\n", err)
+//	}
+//	for _, release := range pruned {
+//	    fmt.Println("Deleted", release.Name)
+//	}
+func (ufs *UFS) PruneOldReleases(releasesDir, currentLink string, keep int) (_ []Release, err error) {
+	defer ufs.recoverPanic("PruneOldReleases", &err)
+
+	if err := ufs.requireOS("PruneOldReleases"); err != nil {
+		return nil, err
+	}
+
+	if keep < 1 {
+		return nil, fmt.Errorf("PruneOldReleases: invalid number of releases to keep %d, expected at least 1", keep)
+	}
+
+	releases, err := ufs.ListReleases(releasesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var current os.FileInfo
+	if currentLink != "" {
+		current, err = os.Stat(currentLink)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, ufs.wrapError(err, "PruneOldReleases")
+		}
+	}
+
+	pruned := []Release{}
+	for _, release := range releases[:max(len(releases)-keep, 0)] {
+		if current != nil {
+			if info, err := os.Stat(release.Path); err == nil && os.SameFile(info, current) {
+				continue
+			}
+		}
+		if !ufs.dryRun("PruneOldReleases", DryRunRemoveAll, release.Path, "") {
+			if err := os.RemoveAll(release.Path); err != nil {
+				return pruned, ufs.wrapError(err, "PruneOldReleases")
+			}
+		}
+		pruned = append(pruned, release)
+	}
+	return pruned, nil
+}
@@ -29,19 +29,28 @@ This package is part of the ufs library, which provides a unified file system in
 //	if !ok {
 //	    fmt.Println("Error removing file")
 //	}
-func (ufs *UFS) RemoveFile(path string) bool {
+func (ufs *UFS) RemoveFile(path string) (ok bool) {
+	defer ufs.recoverPanicBool("RemoveFile", &ok)
+
+	return ufs.reportFailure(ufs.tryRemoveFile(path), "RemoveFile")
+}
+
+// tryRemoveFile is RemoveFile returning why the file couldn't be removed, a *MisuseError if it isn't a file
+func (ufs *UFS) tryRemoveFile(path string) error {
 	// Verify the path is a file
 	if !ufs.IsFile(path) {
-		ufs.handleMistakeWarning(fmt.Sprintf("RemoveFile: Path is not a file: %s", path))
-		return false
+		return &MisuseError{Op: "RemoveFile", Reason: "Path is not a file", Path: path}
 	}
 
-	err := os.Remove(path)
-	if err != nil {
-		ufs.handleError(err, "RemoveFile")
-		return false
+	if ufs.dryRun("RemoveFile", DryRunRemove, path, "") {
+		return nil
 	}
-	return true
+
+	if err := ufs.backend().Remove(path); err != nil {
+		return err
+	}
+	ufs.recordQuota(path)
+	return nil
 }
 
 // RemoveDirectory removes an empty directory at the specified path.
@@ -60,20 +69,26 @@ func (ufs *UFS) RemoveFile(path string) bool {
 //	if !ok {
 //	    fmt.Println("Error removing directory")
 //	}
-func (ufs *UFS) RemoveDirectory(path string) bool {
+func (ufs *UFS) RemoveDirectory(path string) (ok bool) {
+	defer ufs.recoverPanicBool("RemoveDirectory", &ok)
+
 	// Verify the path is a directory
 	if !ufs.IsDirectory(path) {
-		ufs.handleMistakeWarning(fmt.Sprintf("RemoveDirectory: Path is not a directory: %s", path))
+		ufs.reportMisuse("RemoveDirectory", "Path is not a directory", path)
 		return false
 	}
 
 	// Verify the directory is empty
 	if !ufs.IsDirectoryEmpty(path) {
-		ufs.handleMistakeWarning(fmt.Sprintf("RemoveDirectory: Directory is not empty: %s", path))
+		ufs.reportMisuse("RemoveDirectory", "Directory is not empty", path)
 		return false
 	}
 
-	err := os.Remove(path)
+	if ufs.dryRun("RemoveDirectory", DryRunRemove, path, "") {
+		return true
+	}
+
+	err := ufs.backend().Remove(path)
 	if err != nil {
 		ufs.handleError(err, "RemoveDirectory")
 		return false
@@ -97,14 +112,20 @@ func (ufs *UFS) RemoveDirectory(path string) bool {
 //	if !ok {
 //	    fmt.Println("Error removing directory recursively")
 //	}
-func (ufs *UFS) RemoveDirectoryRecursive(path string) bool {
+func (ufs *UFS) RemoveDirectoryRecursive(path string) (ok bool) {
+	defer ufs.recoverPanicBool("RemoveDirectoryRecursive", &ok)
+
 	// Verify the path is a directory
 	if !ufs.IsDirectory(path) {
-		ufs.handleMistakeWarning(fmt.Sprintf("RemoveDirectoryRecursive: Path is not a directory: %s", path))
+		ufs.reportMisuse("RemoveDirectoryRecursive", "Path is not a directory", path)
 		return false
 	}
 
-	err := os.RemoveAll(path)
+	if ufs.dryRun("RemoveDirectoryRecursive", DryRunRemoveAll, path, "") {
+		return true
+	}
+
+	err := removeAllBackend(ufs.backend(), path)
 	if err != nil {
 		ufs.handleError(err, "RemoveDirectoryRecursive")
 		return false
@@ -127,20 +148,26 @@ func (ufs *UFS) RemoveDirectoryRecursive(path string) bool {
 //	if !ok {
 //	    fmt.Println("Error removing symlink")
 //	}
-func (ufs *UFS) RemoveSymlink(path string) bool {
+func (ufs *UFS) RemoveSymlink(path string) (ok bool) {
+	defer ufs.recoverPanicBool("RemoveSymlink", &ok)
+
 	// Check if path is a symlink
-	info, err := os.Lstat(path)
+	info, err := ufs.lstat(path)
 	if err != nil {
 		ufs.handleError(err, "RemoveSymlink")
 		return false
 	}
 
 	if info.Mode()&os.ModeSymlink == 0 {
-		ufs.handleMistakeWarning(fmt.Sprintf("RemoveSymlink: Path is not a symlink: %s", path))
+		ufs.reportMisuse("RemoveSymlink", "Path is not a symlink", path)
 		return false
 	}
 
-	err = os.Remove(path)
+	if ufs.dryRun("RemoveSymlink", DryRunRemove, path, "") {
+		return true
+	}
+
+	err = ufs.backend().Remove(path)
 	if err != nil {
 		ufs.handleError(err, "RemoveSymlink")
 		return false
@@ -167,31 +194,38 @@ func (ufs *UFS) RemoveSymlink(path string) bool {
 //	    fmt.Printf("File backed up to: %s\n", backupPath)
 //	}
 func (ufs *UFS) RemoveFileWithBackup(path string) (bool, string) {
+	defer ufs.recoverPanicReport("RemoveFileWithBackup")
+
 	// Verify the path is a file
 	if !ufs.IsFile(path) {
-		ufs.handleMistakeWarning(fmt.Sprintf("RemoveFileWithBackup: Path is not a file: %s", path))
+		ufs.reportMisuse("RemoveFileWithBackup", "Path is not a file", path)
 		return false, ""
 	}
 
 	// Create backup path
 	backupPath := path + ".bak"
 
+	if ufs.dryRun("RemoveFileWithBackup", DryRunCopy, backupPath, path) {
+		ufs.dryRun("RemoveFileWithBackup", DryRunRemove, path, "")
+		return true, backupPath
+	}
+
 	// Read the original file
-	content, err := os.ReadFile(path)
+	content, err := readBackendFile(ufs.backend(), path)
 	if err != nil {
 		ufs.handleError(err, "RemoveFileWithBackup")
 		return false, ""
 	}
 
 	// Write to backup file
-	err = os.WriteFile(backupPath, content, 0644)
+	err = writeBackendFile(ufs.backend(), backupPath, content, 0644)
 	if err != nil {
 		ufs.handleError(err, "RemoveFileWithBackup")
 		return false, ""
 	}
 
 	// Remove the original file
-	err = os.Remove(path)
+	err = ufs.backend().Remove(path)
 	if err != nil {
 		ufs.handleError(err, "RemoveFileWithBackup")
 		return false, backupPath
@@ -219,13 +253,15 @@ func (ufs *UFS) RemoveFileWithBackup(path string) (bool, string) {
 //	    fmt.Printf("Removed %d empty files\n", count)
 //	}
 func (ufs *UFS) RemoveEmptyFiles(dirPath string) (bool, int) {
+	defer ufs.recoverPanicReport("RemoveEmptyFiles")
+
 	// Verify the path is a directory
 	if !ufs.IsDirectory(dirPath) {
-		ufs.handleMistakeWarning(fmt.Sprintf("RemoveEmptyFiles: Path is not a directory: %s", dirPath))
+		ufs.reportMisuse("RemoveEmptyFiles", "Path is not a directory", dirPath)
 		return false, 0
 	}
 
-	entries, err := os.ReadDir(dirPath)
+	entries, err := ufs.backend().ReadDir(dirPath)
 	if err != nil {
 		ufs.handleError(err, "RemoveEmptyFiles")
 		return false, 0
@@ -279,13 +315,15 @@ func (ufs *UFS) RemoveEmptyFiles(dirPath string) (bool, int) {
 //	    fmt.Printf("Removed %d empty directories\n", count)
 //	}
 func (ufs *UFS) RemoveEmptyDirectories(dirPath string) (bool, int) {
+	defer ufs.recoverPanicReport("RemoveEmptyDirectories")
+
 	// Verify the path is a directory
 	if !ufs.IsDirectory(dirPath) {
-		ufs.handleMistakeWarning(fmt.Sprintf("RemoveEmptyDirectories: Path is not a directory: %s", dirPath))
+		ufs.reportMisuse("RemoveEmptyDirectories", "Path is not a directory", dirPath)
 		return false, 0
 	}
 
-	entries, err := os.ReadDir(dirPath)
+	entries, err := ufs.backend().ReadDir(dirPath)
 	if err != nil {
 		ufs.handleError(err, "RemoveEmptyDirectories")
 		return false, 0
@@ -329,14 +367,16 @@ func (ufs *UFS) RemoveEmptyDirectories(dirPath string) (bool, int) {
 //	if !ok {
 //	    fmt.Println("Error removing directory contents")
 //	}
-func (ufs *UFS) RemoveDirectoryContents(dirPath string) bool {
+func (ufs *UFS) RemoveDirectoryContents(dirPath string) (ok bool) {
+	defer ufs.recoverPanicBool("RemoveDirectoryContents", &ok)
+
 	// Verify the path is a directory
 	if !ufs.IsDirectory(dirPath) {
-		ufs.handleMistakeWarning(fmt.Sprintf("RemoveDirectoryContents: Path is not a directory: %s", dirPath))
+		ufs.reportMisuse("RemoveDirectoryContents", "Path is not a directory", dirPath)
 		return false
 	}
 
-	entries, err := os.ReadDir(dirPath)
+	entries, err := ufs.backend().ReadDir(dirPath)
 	if err != nil {
 		ufs.handleError(err, "RemoveDirectoryContents")
 		return false
@@ -384,10 +424,12 @@ func (ufs *UFS) RemoveDirectoryContents(dirPath string) bool {
 //	if !ok {
 //	    fmt.Println("Error removing directory tree")
 //	}
-func (ufs *UFS) RemoveDirectoryTree(basePath string, structure map[string]interface{}) bool {
+func (ufs *UFS) RemoveDirectoryTree(basePath string, structure map[string]interface{}) (ok bool) {
+	defer ufs.recoverPanicBool("RemoveDirectoryTree", &ok)
+
 	// Verify the path is a directory
 	if !ufs.IsDirectory(basePath) {
-		ufs.handleMistakeWarning(fmt.Sprintf("RemoveDirectoryTree: Base path is not a directory: %s", basePath))
+		ufs.reportMisuse("RemoveDirectoryTree", "Base path is not a directory", basePath)
 		return false
 	}
 
@@ -441,13 +483,15 @@ func (ufs *UFS) RemoveDirectoryTree(basePath string, structure map[string]interf
 //	    fmt.Printf("Removed %d symbolic links\n", count)
 //	}
 func (ufs *UFS) RemoveAllLinks(dirPath string) (bool, int) {
+	defer ufs.recoverPanicReport("RemoveAllLinks")
+
 	// Verify the path is a directory
 	if !ufs.IsDirectory(dirPath) {
-		ufs.handleMistakeWarning(fmt.Sprintf("RemoveAllLinks: Path is not a directory: %s", dirPath))
+		ufs.reportMisuse("RemoveAllLinks", "Path is not a directory", dirPath)
 		return false, 0
 	}
 
-	entries, err := os.ReadDir(dirPath)
+	entries, err := ufs.backend().ReadDir(dirPath)
 	if err != nil {
 		ufs.handleError(err, "RemoveAllLinks")
 		return false, 0
@@ -460,7 +504,7 @@ func (ufs *UFS) RemoveAllLinks(dirPath string) (bool, int) {
 		entryPath := filepath.Join(dirPath, entry.Name())
 
 		// Check if it's a symlink
-		info, err := os.Lstat(entryPath)
+		info, err := ufs.lstat(entryPath)
 		if err != nil {
 			ufs.handleError(err, "RemoveAllLinks")
 			success = false
@@ -480,11 +524,12 @@ func (ufs *UFS) RemoveAllLinks(dirPath string) (bool, int) {
 }
 
 // RemoveByPattern removes all files matching a specified pattern in the given directory.
-// This function uses filepath.Match for pattern matching.
+// The pattern is matched like GlobIn: "*.tmp" only matches the files directly in the directory,
+// "**/*.tmp" the files at any depth, and braces are expanded ("*.{tmp,bak}"). Directories are not removed.
 //
 // Parameters:
 //   - dirPath: The absolute or relative path to the directory to clean
-//   - pattern: The pattern to match files against (e.g., "*.tmp", "backup-*")
+//   - pattern: The pattern to match files against (e.g., "*.tmp", "backup-*", "**/*.{log,tmp}")
 //
 // Returns:
 //   - bool: true if all matching files were removed successfully, false if any removal failed
@@ -499,13 +544,15 @@ func (ufs *UFS) RemoveAllLinks(dirPath string) (bool, int) {
 //	    fmt.Printf("Removed %d temporary files\n", count)
 //	}
 func (ufs *UFS) RemoveByPattern(dirPath, pattern string) (bool, int) {
+	defer ufs.recoverPanicReport("RemoveByPattern")
+
 	// Verify the path is a directory
 	if !ufs.IsDirectory(dirPath) {
-		ufs.handleMistakeWarning(fmt.Sprintf("RemoveByPattern: Path is not a directory: %s", dirPath))
+		ufs.reportMisuse("RemoveByPattern", "Path is not a directory", dirPath)
 		return false, 0
 	}
 
-	entries, err := os.ReadDir(dirPath)
+	matches, err := ufs.globIn("RemoveByPattern", dirPath, pattern, nil)
 	if err != nil {
 		ufs.handleError(err, "RemoveByPattern")
 		return false, 0
@@ -514,25 +561,16 @@ func (ufs *UFS) RemoveByPattern(dirPath, pattern string) (bool, int) {
 	success := true
 	count := 0
 
-	for _, entry := range entries {
-		if entry.IsDir() {
+	for _, filePath := range matches {
+		info, err := ufs.lstat(filePath)
+		if err != nil || info.IsDir() {
 			continue
 		}
 
-		match, err := filepath.Match(pattern, entry.Name())
-		if err != nil {
-			ufs.handleError(err, "RemoveByPattern")
+		if ufs.RemoveFile(filePath) {
+			count++
+		} else {
 			success = false
-			continue
-		}
-
-		if match {
-			filePath := filepath.Join(dirPath, entry.Name())
-			if ufs.RemoveFile(filePath) {
-				count++
-			} else {
-				success = false
-			}
 		}
 	}
 
@@ -557,34 +595,39 @@ func (ufs *UFS) RemoveByPattern(dirPath, pattern string) (bool, int) {
 //	if !ok {
 //	    fmt.Println("Error: File did not match expected criteria or couldn't be removed")
 //	}
-func (ufs *UFS) SafeRemoveFile(path string, expectedSize int64, expectedModTime *os.FileInfo) bool {
+func (ufs *UFS) SafeRemoveFile(path string, expectedSize int64, expectedModTime *os.FileInfo) (ok bool) {
+	defer ufs.recoverPanicBool("SafeRemoveFile", &ok)
+
 	// Verify the path is a file
-	info, err := os.Stat(path)
+	info, err := ufs.backend().Stat(path)
 	if err != nil {
 		ufs.handleError(err, "SafeRemoveFile")
 		return false
 	}
 
 	if info.IsDir() {
-		ufs.handleMistakeWarning(fmt.Sprintf("SafeRemoveFile: Path is not a file: %s", path))
+		ufs.reportMisuse("SafeRemoveFile", "Path is not a file", path)
 		return false
 	}
 
 	// Check file size if specified
 	if expectedSize >= 0 && info.Size() != expectedSize {
-		ufs.handleMistakeWarning(fmt.Sprintf("SafeRemoveFile: File size mismatch: expected %d, got %d",
-			expectedSize, info.Size()))
+		ufs.reportMisuse("SafeRemoveFile", fmt.Sprintf("File size mismatch: expected %d, got %d",
+			expectedSize, info.Size()), path)
 		return false
 	}
 
 	// Check modification time if specified
 	if expectedModTime != nil && (*expectedModTime).ModTime() != info.ModTime() {
-		ufs.handleMistakeWarning(fmt.Sprintf("SafeRemoveFile: File modification time mismatch"))
+		ufs.reportMisuse("SafeRemoveFile", "File modification time mismatch", path)
 		return false
 	}
 
 	// All checks passed, remove the file
-	err = os.Remove(path)
+	if ufs.dryRun("SafeRemoveFile", DryRunRemove, path, "") {
+		return true
+	}
+	err = ufs.backend().Remove(path)
 	if err != nil {
 		ufs.handleError(err, "SafeRemoveFile")
 		return false
@@ -0,0 +1,347 @@
+package ufs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+/*
+Replace.go contains sed-like functions to find and replace text in a file or a whole tree.
+
+Patterns are regular expressions (RE2 syntax) whose replacement can refer to groups ($1, ${name}),
+or plain text with ReplaceOptions.Literal. Patterns may span several lines.
+
+With ReplaceOptions.DryRun nothing is written: the results hold the diff of every file that would
+change, in unified diff format without context lines:
+
+	--- config/app.yaml
+	+++ config/app.yaml
+	@@ -3 +3 @@
+	-host: staging.example.com
+	+host: prod.example.com
+
+Functions:
+- ReplaceInFile: Replaces the matches of a pattern in a file.
+- ReplaceInDirectory: Replaces the matches of a pattern in the files of a directory tree.
+*/
+
+// defaultBackupSuffix is the suffix of the backup files when ReplaceOptions.BackupSuffix is empty
+const defaultBackupSuffix = ".bak"
+
+// ReplaceOptions controls ReplaceInFile and ReplaceInDirectory.
+// The zero value replaces a regular expression in place, without backup.
+type ReplaceOptions struct {
+	// Literal replaces the pattern as plain text, and inserts the replacement as is (no $1 expansion)
+	Literal bool
+
+	// IgnoreCase matches the pattern case-insensitively
+	IgnoreCase bool
+
+	// DryRun writes nothing and returns the diffs of the files that would change
+	DryRun bool
+
+	// Backup copies every modified file to its path + BackupSuffix before modifying it
+	Backup bool
+
+	// BackupSuffix is appended to the path of the backup files, "" uses ".bak"
+	BackupSuffix string
+
+	// Include restricts ReplaceInDirectory to files matching at least one of these globs,
+	// matched like SearchOptions.Include. Empty includes every file.
+	Include []string
+
+	// Exclude skips the files and directories matching any of these globs, matched like SearchOptions.Exclude
+	Exclude []string
+}
+
+// ReplaceResult describes the replacements made (or that would be made) in a file.
+type ReplaceResult struct {
+	Path         string // Path of the file
+	Replacements int    // Number of matches replaced
+	Diff         string // Diff of the changes, only set with ReplaceOptions.DryRun
+}
+
+// ReplaceInFile replaces every match of a pattern in a file. The file keeps its permissions and
+// is replaced atomically. A file without matches is not touched.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file
+//   - pattern: The regular expression (RE2 syntax), or the text when opts.Literal is set
+//   - replacement: The replacement text, $1 or ${name} refer to the groups of the pattern unless opts.Literal is set
+//   - opts: The replace settings, nil uses the defaults
+//
+// Returns:
+//   - *ReplaceResult: The number of replacements, and the diff with opts.DryRun
+//   - error: An error if the pattern is invalid or the file couldn't be read or written, nil otherwise
+//
+// Example:
+//
+//	result, err := ufs.ReplaceInFile("go.mod", `^go 1\.\d+$`, "go 1.24", nil)
+//	if err != nil {
+//	    fmt.Printf("Error replacing: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("%d replacements\n", result.Replacements)
+func (ufs *UFS) ReplaceInFile(path, pattern, replacement string, opts *ReplaceOptions) (_ *ReplaceResult, err error) {
+	defer ufs.recoverPanic("ReplaceInFile", &err)
+
+	if err := ufs.requireOS("ReplaceInFile"); err != nil {
+		return nil, err
+	}
+
+	if opts == nil {
+		opts = &ReplaceOptions{}
+	}
+	re, err := opts.compile(pattern)
+	if err != nil {
+		return nil, ufs.wrapError(err, "ReplaceInFile")
+	}
+	if !ufs.IsFile(path) {
+		return nil, ufs.misuseError("ReplaceInFile", "path is not a file", path)
+	}
+
+	result, err := ufs.replaceInFile(path, re, replacement, opts, false)
+	if err != nil {
+		return nil, ufs.wrapError(err, "ReplaceInFile")
+	}
+	return result, nil
+}
+
+// ReplaceInDirectory replaces every match of a pattern in the files of a directory tree.
+// Binary files (with a NUL byte in their first 8 KiB) and symbolic links are skipped.
+// Files that can't be read are skipped and reported through Options.OnWalkError.
+//
+// Parameters:
+//   - dir: The absolute or relative path to the directory
+//   - pattern: The regular expression (RE2 syntax), or the text when opts.Literal is set
+//   - replacement: The replacement text, $1 or ${name} refer to the groups of the pattern unless opts.Literal is set
+//   - opts: The replace settings, nil uses the defaults
+//
+// Returns:
+//   - []ReplaceResult: The files with at least one replacement, in walk order
+//   - error: An error if the pattern is invalid or a file couldn't be written, nil otherwise
+//
+// Example:
+//
+//	// Preview a rename across the code base
+//	results, err := ufs.ReplaceInDirectory("./src", `\bOldName\b`, "NewName", &ufs.ReplaceOptions{
+//	    DryRun:  true,
+//	    Include: []string{"*.go"},
+//	    Exclude: []string{"vendor"},
+//	})
+//	if err != nil {
+//	    fmt.Printf("Error replacing: %v\n", err)
+//	    return
+//	}
+//	for _, result := range results {
+//	    fmt.Print(result.Diff)
+//	}
+func (ufs *UFS) ReplaceInDirectory(dir, pattern, replacement string, opts *ReplaceOptions) (_ []ReplaceResult, err error) {
+	defer ufs.recoverPanic("ReplaceInDirectory", &err)
+
+	if err := ufs.requireOS("ReplaceInDirectory"); err != nil {
+		return nil, err
+	}
+
+	if opts == nil {
+		opts = &ReplaceOptions{}
+	}
+	re, err := opts.compile(pattern)
+	if err != nil {
+		return nil, ufs.wrapError(err, "ReplaceInDirectory")
+	}
+	if !ufs.IsDirectory(dir) {
+		return nil, ufs.misuseError("ReplaceInDirectory", "path is not a directory", dir)
+	}
+
+	results := []ReplaceResult{}
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkSkip, "ReplaceInDirectory")
+		}
+		if path == dir {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if matchSearchGlobs(opts.Exclude, rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || (len(opts.Include) > 0 && !matchSearchGlobs(opts.Include, rel)) {
+			return nil
+		}
+		// Don't edit the backups of a previous run
+		if opts.Backup && strings.HasSuffix(path, opts.backupSuffix()) {
+			return nil
+		}
+
+		result, err := ufs.replaceInFile(path, re, replacement, opts, true)
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkSkip, "ReplaceInDirectory")
+		}
+		if result.Replacements > 0 {
+			results = append(results, *result)
+		}
+		return nil
+	})
+	if err != nil {
+		return results, ufs.wrapError(err, "ReplaceInDirectory")
+	}
+	return results, nil
+}
+
+// replaceInFile replaces the matches of re in a file, skipping binary files when skipBinary is set
+func (ufs *UFS) replaceInFile(path string, re *regexp.Regexp, replacement string, opts *ReplaceOptions, skipBinary bool) (*ReplaceResult, error) {
+	result := &ReplaceResult{Path: path}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if skipBinary && bytes.IndexByte(data[:min(len(data), binarySniffSize)], 0) >= 0 {
+		return result, nil
+	}
+
+	content := string(data)
+	matches := re.FindAllStringSubmatchIndex(content, -1)
+	if len(matches) == 0 {
+		return result, nil
+	}
+	result.Replacements = len(matches)
+
+	var replaced strings.Builder
+	var diff replaceDiff
+	last := 0
+	for _, match := range matches {
+		var text []byte
+		if opts.Literal {
+			text = []byte(replacement)
+		} else {
+			text = re.ExpandString(nil, replacement, content, match)
+		}
+		if opts.DryRun {
+			diff.add(content, match[0], match[1], replaced.Len()+match[0]-last, string(text))
+		}
+		replaced.WriteString(content[last:match[0]])
+		replaced.Write(text)
+		last = match[1]
+	}
+	replaced.WriteString(content[last:])
+
+	if opts.DryRun {
+		result.Diff = diff.format(path, content, replaced.String())
+		return result, nil
+	}
+
+	if opts.Backup {
+		if err := os.WriteFile(path+opts.backupSuffix(), data, info.Mode().Perm()); err != nil {
+			return nil, err
+		}
+	}
+	done := ufs.trackCritical()
+	defer done()
+	if err := writeFileAtomic(path, []byte(replaced.String()), ufs.fileMode(info.Mode().Perm())); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// replaceDiff collects the changed regions of a file, as byte ranges of the old and new content
+type replaceDiff struct {
+	hunks []replaceHunk
+}
+
+// replaceHunk is a changed region, extended to whole lines
+type replaceHunk struct {
+	oldStart, oldEnd int // Byte range in the old content
+	newStart, newEnd int // Byte range in the new content
+}
+
+// add records a match replaced by text, given its offsets in the old content and in the new content
+func (d *replaceDiff) add(content string, start, end, newStart int, text string) {
+	// Extend the match to whole lines
+	lineStart := strings.LastIndexByte(content[:start], '\n') + 1
+	lineEnd := len(content)
+	if i := strings.IndexByte(content[end:], '\n'); i >= 0 {
+		lineEnd = end + i
+	}
+	hunk := replaceHunk{
+		oldStart: lineStart,
+		oldEnd:   lineEnd,
+		newStart: newStart - (start - lineStart),
+		newEnd:   newStart + len(text) + (lineEnd - end),
+	}
+
+	// Matches on the same lines make a single hunk
+	if n := len(d.hunks); n > 0 && d.hunks[n-1].oldEnd >= hunk.oldStart {
+		d.hunks[n-1].oldEnd, d.hunks[n-1].newEnd = hunk.oldEnd, hunk.newEnd
+		return
+	}
+	d.hunks = append(d.hunks, hunk)
+}
+
+// format returns the diff in unified format without context lines
+func (d *replaceDiff) format(path, oldContent, newContent string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", path, path)
+
+	for _, hunk := range d.hunks {
+		oldLines := strings.Split(oldContent[hunk.oldStart:hunk.oldEnd], "\n")
+		newLines := strings.Split(newContent[hunk.newStart:hunk.newEnd], "\n")
+		oldLine := strings.Count(oldContent[:hunk.oldStart], "\n") + 1
+		newLine := strings.Count(newContent[:hunk.newStart], "\n") + 1
+
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", diffRange(oldLine, len(oldLines)), diffRange(newLine, len(newLines)))
+		for _, line := range oldLines {
+			b.WriteString("-" + line + "\n")
+		}
+		for _, line := range newLines {
+			b.WriteString("+" + line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// diffRange formats the line range of a hunk header
+func diffRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// compile compiles the pattern of a replacement
+func (opts *ReplaceOptions) compile(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern can't be empty")
+	}
+	if opts.Literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if opts.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// backupSuffix returns the suffix of the backup files
+func (opts *ReplaceOptions) backupSuffix() string {
+	if opts.BackupSuffix == "" {
+		return defaultBackupSuffix
+	}
+	return opts.BackupSuffix
+}
@@ -0,0 +1,310 @@
+package ufs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Route-files.go contains functions to sort incoming files into destination folders by content type,
+the core of "sort my Downloads folder" tools.
+
+Every file of the source directory is matched against a list of rules, by extension, MIME type and
+size, and moved to the destination of the first matching rule. Files matching no rule stay where they are.
+
+	report, err := ufs.RouteFiles("~/Downloads", []ufs.RouteRule{
+	    {Name: "images", MIMETypes: []string{"image/*"}, Destination: "~/Pictures/Inbox"},
+	    {Name: "documents", Extensions: []string{".pdf", ".docx"}, Destination: "~/Documents/Inbox"},
+	    {Name: "large", MinSize: 1 << 30, Destination: "/mnt/storage/large"},
+	}, &ufs.RouteOptions{Overwrite: ufs.RenameWithSuffix})
+
+The MIME type of a file is sniffed from its first 512 bytes (see DetectMIMEType),
+and a rule also matches the type registered for the extension of the file.
+
+Functions:
+- RouteFiles: Moves the files of a directory to the destination of the first rule they match.
+*/
+
+// RouteRule maps a class of files to a destination directory.
+// A file matches a rule when it matches all the criteria the rule sets.
+type RouteRule struct {
+	// Name identifies the rule in the report, optional
+	Name string
+
+	// Extensions restricts the rule to files with one of these extensions, e.g. ".pdf", compared case-insensitively.
+	// Empty matches any extension
+	Extensions []string
+
+	// MIMETypes restricts the rule to files with one of these MIME types, e.g. "application/pdf",
+	// or "image/*" for a whole family. Empty matches any type
+	MIMETypes []string
+
+	// MinSize restricts the rule to files of at least this many bytes, 0 disables the limit
+	MinSize int64
+
+	// MaxSize restricts the rule to files of at most this many bytes, 0 disables the limit
+	MaxSize int64
+
+	// Destination is the directory the matching files are moved to, created if needed
+	Destination string
+}
+
+// RouteOptions controls RouteFiles. The zero value routes the files at the top of the directory,
+// replacing existing files in the destinations.
+type RouteOptions struct {
+	// Recursive also routes the files of the subdirectories. The destinations are not descended into
+	Recursive bool
+
+	// Overwrite decides what happens when a file already exists in the destination.
+	// FailOnExisting fails before any file is moved
+	Overwrite OverwritePolicy
+}
+
+// RoutedFile is a file moved by RouteFiles.
+type RoutedFile struct {
+	Source      string // Path the file was moved from
+	Destination string // Path the file was moved to
+	Rule        string // Name of the matching rule
+}
+
+// RouteReport describes what RouteFiles did.
+type RouteReport struct {
+	Moved           []RoutedFile // Files moved, in walk order
+	Renamed         []RoutedFile // Files moved under a new name by RenameWithSuffix, also listed in Moved
+	SkippedExisting []string     // Files left in place by SkipExisting because the destination exists
+	Unmatched       []string     // Files matching no rule, left in place
+}
+
+// RouteFiles moves the files of a directory to the destination of the first rule they match.
+// Files are moved with a rename when possible, or copied then deleted across file systems.
+// Symbolic links and files matching no rule are left in place.
+//
+// Parameters:
+//   - srcDir: The absolute or relative path to the directory to sort
+//   - rules: The rules, tried in order
+//   - opts: The routing settings, nil uses the defaults
+//
+// Returns:
+//   - *RouteReport: The files moved and left in place, also returned with an error for the files handled so far
+//   - error: An error if a rule is invalid or a file couldn't be read or moved, nil otherwise
+//
+// Example:
+//
+//	report, err := ufs.RouteFiles("/home/me/Downloads", []ufs.RouteRule{
+//	    {Name: "images", MIMETypes: []string{"image/*"}, Destination: "/home/me/Pictures"},
+//	    {Name: "archives", Extensions: []string{".zip", ".tar.gz"}, Destination: "/home/me/Archives"},
+//	}, &ufs.RouteOptions{Overwrite: ufs.RenameWithSuffix})
+//	if err != nil {
+//	    fmt.Printf("Error sorting downloads: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("%d files sorted, %d left\n", len(report.Moved), len(report.Unmatched))
+func (ufs *UFS) RouteFiles(srcDir string, rules []RouteRule, opts *RouteOptions) (_ *RouteReport, err error) {
+	defer ufs.recoverPanic("RouteFiles", &err)
+
+	if err := ufs.requireOS("RouteFiles"); err != nil {
+		return nil, err
+	}
+	defer ufs.applyIOPriority()()
+
+	if opts == nil {
+		opts = &RouteOptions{}
+	}
+	if opts.Overwrite < OverwriteExisting || opts.Overwrite > FailOnExisting {
+		return nil, fmt.Errorf("RouteFiles: invalid OverwritePolicy %d", opts.Overwrite)
+	}
+	if !ufs.IsDirectory(srcDir) {
+		return nil, ufs.misuseError("RouteFiles", "path is not a directory", srcDir)
+	}
+
+	destinations := map[string]bool{}
+	for i, rule := range rules {
+		if rule.Destination == "" {
+			return nil, fmt.Errorf("RouteFiles: rule %d has no destination", i)
+		}
+		for _, pattern := range rule.MIMETypes {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("RouteFiles: invalid MIME type pattern %q: %w", pattern, err)
+			}
+		}
+		if abs, err := filepath.Abs(rule.Destination); err == nil {
+			destinations[abs] = true
+		}
+	}
+
+	// Match every file first, so FailOnExisting fails before anything is moved
+	report := &RouteReport{}
+	var planned []RoutedFile
+	err = filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkSkip, "RouteFiles")
+		}
+		if info.IsDir() {
+			if path == srcDir {
+				return nil
+			}
+			abs, _ := filepath.Abs(path)
+			if !opts.Recursive || destinations[abs] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rule, err := matchRouteRule(rules, path, info)
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkSkip, "RouteFiles")
+		}
+		if rule == nil {
+			report.Unmatched = append(report.Unmatched, path)
+			return nil
+		}
+		planned = append(planned, RoutedFile{
+			Source:      path,
+			Destination: filepath.Join(rule.Destination, info.Name()),
+			Rule:        rule.Name,
+		})
+		return nil
+	})
+	if err != nil {
+		return report, ufs.wrapError(err, "RouteFiles")
+	}
+
+	if opts.Overwrite == FailOnExisting {
+		targets := map[string]bool{}
+		for _, file := range planned {
+			if _, err := os.Lstat(file.Destination); err == nil || targets[file.Destination] {
+				return report, fmt.Errorf("RouteFiles: %w: %s", os.ErrExist, file.Destination)
+			}
+			targets[file.Destination] = true
+		}
+	}
+
+	for _, file := range planned {
+		if _, err := os.Lstat(file.Destination); err == nil {
+			switch opts.Overwrite {
+			case SkipExisting:
+				report.SkippedExisting = append(report.SkippedExisting, file.Source)
+				continue
+			case RenameWithSuffix:
+				file.Destination = uniquePath(file.Destination)
+				report.Renamed = append(report.Renamed, file)
+			}
+		}
+
+		if ufs.dryRun("RouteFiles", DryRunMove, file.Destination, file.Source) {
+			report.Moved = append(report.Moved, file)
+			continue
+		}
+		if err := ufs.routeFile(file.Source, file.Destination); err != nil {
+			return report, ufs.wrapError(err, "RouteFiles")
+		}
+		report.Moved = append(report.Moved, file)
+	}
+	return report, nil
+}
+
+// routeFile moves a file, creating the destination directory,
+// and copies then deletes it when it can't be renamed (across file systems)
+func (ufs *UFS) routeFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	if err := ufs.copyFile(src, dst); err != nil {
+		return err
+	}
+	if err := os.Remove(src); err != nil {
+		// Don't leave two copies behind
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+// matchRouteRule returns the first rule matching a file, nil if none does.
+// The content type is only sniffed when a rule needs it.
+func matchRouteRule(rules []RouteRule, path string, info os.FileInfo) (*RouteRule, error) {
+	var contentTypes []string
+	sniffed := false
+
+	for i := range rules {
+		rule := &rules[i]
+		if rule.MinSize > 0 && info.Size() < rule.MinSize {
+			continue
+		}
+		if rule.MaxSize > 0 && info.Size() > rule.MaxSize {
+			continue
+		}
+		if len(rule.Extensions) > 0 && !hasExtension(info.Name(), rule.Extensions) {
+			continue
+		}
+
+		if len(rule.MIMETypes) > 0 {
+			if !sniffed {
+				var err error
+				if contentTypes, err = fileContentTypes(path); err != nil {
+					return nil, err
+				}
+				sniffed = true
+			}
+			if !matchContentTypes(rule.MIMETypes, contentTypes) {
+				continue
+			}
+		}
+		return rule, nil
+	}
+	return nil, nil
+}
+
+// hasExtension reports whether a file name ends with one of the extensions, case-insensitively.
+// Multi-part extensions like ".tar.gz" are supported.
+func hasExtension(name string, extensions []string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range extensions {
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if strings.HasSuffix(name, ext) && len(name) > len(ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileContentTypes returns the MIME types of a file: the type sniffed from its content,
+// then the type registered for its extension if there is one
+func fileContentTypes(path string) ([]string, error) {
+	head, err := readFileHead(path, sniffSize)
+	if err != nil {
+		return nil, err
+	}
+
+	types := []string{sniffMIMEType(head)}
+	if byExtension := mimeTypeByExtension(path); byExtension != "" {
+		types = append(types, byExtension)
+	}
+	return types, nil
+}
+
+// matchContentTypes reports whether one of the types matches one of the patterns, like "image/*"
+func matchContentTypes(patterns, types []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		for _, contentType := range types {
+			if matched, _ := path.Match(pattern, contentType); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
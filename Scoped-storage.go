@@ -0,0 +1,229 @@
+package ufs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+/*
+Scoped-storage.go contains the restricted mode used in mobile sandboxes, for apps embedding ufs with gomobile.
+
+Android (scoped storage) and iOS (app containers) reject operations that work on desktops:
+changing permissions, symbolic and hard links, writing to system directories and running programs.
+In scoped storage mode, ufs avoids them:
+- Permissions are not changed: CopyDirectory, MoveDirectoryWithProgress, WriteFileAtomic, ReplaceInFile,
+  the line editing functions and Options.CreatePolicies keep the default mode of the files they write.
+- CreateSymlink, CreateHardLink, SymlinkDirectoryTree, UpdateCurrentSymlink, the functions running
+  system commands (CompressWithSystemCommand, ExtractWithSystemCommand, ExtractWithSystemCommandOptions,
+  Extract7z, ExtractRar) and CopyDirectory for symbolic links fail with a *CapabilityError, instead of
+  an obscure EPERM.
+- The core file functions (see Backend.go) refuse to write to system directories, with a *CapabilityError:
+  /system, /vendor... on Android, /System, /Library... on iOS, and the ones of IsInSystemPath elsewhere
+  (iOS apps live in /var/mobile, which IsInSystemPath would refuse).
+
+The mode is on by default on Android and iOS, and can be forced with Options.ScopedStorage, e.g. to
+test an app on a desktop under the same restrictions.
+
+Functions:
+- IsMobilePlatform: Reports whether the program runs on Android or iOS.
+- IsScopedStorage: Reports whether the scoped storage mode applies to the instance.
+- HasCapability: Reports whether an operation is available to the instance.
+*/
+
+// ScopedStorageMode selects the scoped storage mode of an instance, see Options.ScopedStorage.
+type ScopedStorageMode int
+
+const (
+	// ScopedStorageAuto enables the mode on Android and iOS only (default)
+	ScopedStorageAuto ScopedStorageMode = iota
+	// ScopedStorageOn enables the mode on every platform
+	ScopedStorageOn
+	// ScopedStorageOff disables the mode, e.g. for rooted devices or apps with full file access
+	ScopedStorageOff
+)
+
+// Capability is an operation mobile sandboxes may forbid, see HasCapability.
+type Capability int
+
+const (
+	// CapabilityChmod is changing the permissions of files and directories
+	CapabilityChmod Capability = iota
+	// CapabilitySymlinks is creating symbolic links
+	CapabilitySymlinks
+	// CapabilityHardLinks is creating hard links
+	CapabilityHardLinks
+	// CapabilitySystemPaths is writing to the system directories
+	CapabilitySystemPaths
+	// CapabilityProcesses is running system commands (tar, 7z...)
+	CapabilityProcesses
+)
+
+func (c Capability) String() string {
+	switch c {
+	case CapabilityChmod:
+		return "changing permissions"
+	case CapabilitySymlinks:
+		return "symbolic links"
+	case CapabilityHardLinks:
+		return "hard links"
+	case CapabilitySystemPaths:
+		return "writing to system directories"
+	case CapabilityProcesses:
+		return "running system commands"
+	}
+	return "unknown capability"
+}
+
+// mobileSystemPaths are the system directories of the mobile platforms, used instead of the ones of IsInSystemPath
+var mobileSystemPaths = map[string][]string{
+	"android": {"/system", "/vendor", "/product", "/apex", "/odm", "/oem", "/proc", "/sys", "/dev"},
+	"ios":     {"/System", "/Library", "/Applications", "/usr", "/private/etc", "/dev"},
+}
+
+// IsMobilePlatform reports whether the program runs on Android or iOS, where the scoped storage
+// mode is on by default.
+//
+// Returns:
+//   - bool: True on Android and iOS, false otherwise
+//
+// Example:
+//
+//	if ufs.IsMobilePlatform() {
+//	    dataDir = appFilesDir // Given by the app, e.g. Context.getFilesDir()
+//	}
+func (ufs *UFS) IsMobilePlatform() bool {
+	return runtime.GOOS == "android" || runtime.GOOS == "ios"
+}
+
+// IsScopedStorage reports whether the scoped storage mode applies to the instance,
+// depending on Options.ScopedStorage and the platform.
+//
+// Returns:
+//   - bool: True if the operations invalid in mobile sandboxes are avoided
+//
+// Example:
+//
+//	if !ufs.IsScopedStorage() {
+//	    ufs.CreateSymlink(release, current)
+//	}
+func (ufs *UFS) IsScopedStorage() bool {
+	switch ufs.opts.ScopedStorage {
+	case ScopedStorageOn:
+		return true
+	case ScopedStorageOff:
+		return false
+	}
+	return ufs.IsMobilePlatform()
+}
+
+// HasCapability reports whether an operation is available to the instance: every capability is
+// available, except in scoped storage mode where none is.
+//
+// Parameters:
+//   - capability: The operation, e.g. CapabilitySymlinks
+//
+// Returns:
+//   - bool: True if the instance may use the operation
+//
+// Example:
+//
+//	if ufs.HasCapability(ufs.CapabilityProcesses) {
+//	    err = ufs.CompressWithSystemCommand(dir, archive, "gz")
+//	} else {
+//	    err = ufs.CompressDirectory(dir, archive+".zip")
+//	}
+func (ufs *UFS) HasCapability(capability Capability) bool {
+	return !ufs.IsScopedStorage()
+}
+
+// requireCapability returns a *CapabilityError if the instance may not use capability,
+// for the operation on path
+func (ufs *UFS) requireCapability(capability Capability, operation, path string) error {
+	if ufs.HasCapability(capability) {
+		return nil
+	}
+	return &CapabilityError{Operation: operation, Path: path, Capability: capability, Platform: runtime.GOOS}
+}
+
+// isScopedSystemPath reports whether path is a system directory, or inside one, for the scoped storage mode
+func (ufs *UFS) isScopedSystemPath(path string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	sysPaths, mobile := mobileSystemPaths[runtime.GOOS]
+	if !mobile {
+		return ufs.IsInSystemPath(absPath)
+	}
+	for _, sysPath := range sysPaths {
+		if absPath == sysPath || strings.HasPrefix(absPath, sysPath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// scopedBackend is the operating system backend in scoped storage mode: writes to system directories
+// are refused and permissions are not changed
+type scopedBackend struct {
+	osBackend
+	ufs *UFS
+}
+
+// refuse returns a *CapabilityError if path is a system directory
+func (b scopedBackend) refuse(operation, path string) error {
+	if !b.ufs.isScopedSystemPath(path) {
+		return nil
+	}
+	return &CapabilityError{Operation: operation, Path: path, Capability: CapabilitySystemPaths, Platform: runtime.GOOS}
+}
+
+func (b scopedBackend) OpenFile(name string, flag int, perm fs.FileMode) (BackendFile, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		if err := b.refuse("open", name); err != nil {
+			return nil, err
+		}
+	}
+	return b.osBackend.OpenFile(name, flag, perm)
+}
+
+func (b scopedBackend) Mkdir(name string, perm fs.FileMode) error {
+	if err := b.refuse("mkdir", name); err != nil {
+		return err
+	}
+	return b.osBackend.Mkdir(name, perm)
+}
+
+func (b scopedBackend) Remove(name string) error {
+	if err := b.refuse("remove", name); err != nil {
+		return err
+	}
+	return b.osBackend.Remove(name)
+}
+
+func (b scopedBackend) Rename(oldpath, newpath string) error {
+	if err := b.refuse("rename", oldpath); err != nil {
+		return err
+	}
+	if err := b.refuse("rename", newpath); err != nil {
+		return err
+	}
+	return b.osBackend.Rename(oldpath, newpath)
+}
+
+// Chmod does nothing, the sandbox keeps its own permissions
+func (b scopedBackend) Chmod(name string, mode fs.FileMode) error {
+	return nil
+}
+
+// fileMode returns the mode to give to a file written with perm: 0 in scoped storage mode, where
+// the default mode is kept
+func (ufs *UFS) fileMode(perm fs.FileMode) fs.FileMode {
+	if ufs.IsScopedStorage() {
+		return 0
+	}
+	return perm
+}
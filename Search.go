@@ -0,0 +1,306 @@
+package ufs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+/*
+Search.go contains grep-like functions to find the lines of files matching a pattern.
+
+Patterns are regular expressions (RE2 syntax, see the regexp package) unless SearchOptions.Literal is set.
+SearchInDirectory searches the files of a tree with several workers, filters them with include and
+exclude globs, and skips binary files (files with a NUL byte in their first 8 KiB).
+
+Functions:
+- SearchInFile: Finds the lines of a file matching a regular expression.
+- SearchInDirectory: Finds the lines matching a pattern in the files of a directory tree.
+*/
+
+// binarySniffSize is the number of bytes read to decide whether a file is binary
+const binarySniffSize = 8 * 1024
+
+// SearchMatch is a line matching the pattern of a search.
+type SearchMatch struct {
+	Path string // Path of the file, joined to the searched directory
+	Line int    // Line number, starting at 1
+	Text string // The line, without line break
+}
+
+// SearchOptions controls SearchInDirectory. The zero value searches every text file
+// for a regular expression with runtime.NumCPU() workers.
+type SearchOptions struct {
+	// Literal searches the pattern as plain text instead of a regular expression
+	Literal bool
+
+	// IgnoreCase matches the pattern case-insensitively
+	IgnoreCase bool
+
+	// Include restricts the search to files matching at least one of these globs (see Glob.go),
+	// globs without a slash are matched against the file name, the others against the slash separated
+	// path relative to the directory, e.g. "*.go", "cmd/*/main.go" or "internal/**". Empty searches every file.
+	Include []string
+
+	// Exclude skips the files and directories matching any of these globs, matched like Include,
+	// e.g. ".git", "node_modules" or "*.min.js"
+	Exclude []string
+
+	// Workers is the number of files searched at the same time, 0 uses runtime.NumCPU()
+	Workers int
+
+	// MaxMatches stops the search after this many matches, 0 doesn't limit the matches
+	MaxMatches int
+}
+
+// SearchInFile finds the lines of a file matching a regular expression, like grep.
+// The file is read line by line, lines longer than Options.MaxLineSize fail with bufio.ErrTooLong.
+// Use regexp.QuoteMeta to search plain text.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file to search
+//   - pattern: The regular expression (RE2 syntax) matched against every line
+//
+// Returns:
+//   - []SearchMatch: The matching lines in file order, empty if none matches
+//   - error: An error if the pattern is invalid or the file couldn't be read, nil otherwise
+//
+// Example:
+//
+//	matches, err := ufs.SearchInFile("/var/log/app.log", `ERROR|FATAL`)
+//	if err != nil {
+//	    fmt.Printf("Error searching file: %v\n", err)
+//	    return
+//	}
+//	for _, match := range matches {
+//	    fmt.Printf("%d: %s\n", match.Line, match.Text)
+//	}
+func (ufs *UFS) SearchInFile(path string, pattern string) (_ []SearchMatch, err error) {
+	defer ufs.recoverPanic("SearchInFile", &err)
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, ufs.wrapError(err, "SearchInFile")
+	}
+	if !ufs.IsFile(path) {
+		return nil, ufs.misuseError("SearchInFile", "path is not a file", path)
+	}
+
+	matches, err := ufs.searchFile(path, re, 0)
+	if err != nil {
+		return nil, ufs.wrapError(err, "SearchInFile")
+	}
+	return matches, nil
+}
+
+// SearchInDirectory finds the lines matching a pattern in the files of a directory tree,
+// searching several files at the same time. Binary files and symbolic links are skipped.
+// Files that can't be read are skipped and reported through Options.OnWalkError.
+//
+// Parameters:
+//   - dir: The absolute or relative path to the directory to search
+//   - pattern: The regular expression (RE2 syntax), or the text when opts.Literal is set
+//   - opts: The search settings, nil uses the defaults
+//
+// Returns:
+//   - []SearchMatch: The matching lines, sorted by path and line number
+//   - error: An error if the pattern is invalid or the tree couldn't be searched, nil otherwise
+//
+// Example:
+//
+//	matches, err := ufs.SearchInDirectory("./src", "TODO", &ufs.SearchOptions{
+//	    Literal: true,
+//	    Include: []string{"*.go"},
+//	    Exclude: []string{"vendor", "*_test.go"},
+//	})
+//	if err != nil {
+//	    fmt.Printf("Error searching: %v\n", err)
+//	    return
+//	}
+//	for _, match := range matches {
+//	    fmt.Printf("%s:%d: %s\n", match.Path, match.Line, match.Text)
+//	}
+func (ufs *UFS) SearchInDirectory(dir string, pattern string, opts *SearchOptions) (_ []SearchMatch, err error) {
+	defer ufs.recoverPanic("SearchInDirectory", &err)
+
+	if opts == nil {
+		opts = &SearchOptions{}
+	}
+	re, err := opts.compile(pattern)
+	if err != nil {
+		return nil, ufs.wrapError(err, "SearchInDirectory")
+	}
+	if !ufs.IsDirectory(dir) {
+		return nil, ufs.misuseError("SearchInDirectory", "path is not a directory", dir)
+	}
+	workers := opts.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	var files []string
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkSkip, "SearchInDirectory")
+		}
+		if path == dir {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if opts.excluded(rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() && opts.included(rel) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, ufs.wrapError(err, "SearchInDirectory")
+	}
+
+	var (
+		mu       sync.Mutex
+		matches  []SearchMatch
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	jobs := make(chan string)
+
+	// done reports whether the search must stop, mu must be held
+	done := func() bool {
+		return firstErr != nil || (opts.MaxMatches > 0 && len(matches) >= opts.MaxMatches)
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer ufs.applyIOPriority()()
+
+			for path := range jobs {
+				var found []SearchMatch
+				err := ufs.recoverWorker("SearchInDirectory", func() (err error) {
+					found, err = ufs.searchFile(path, re, binarySniffSize)
+					return err
+				})
+				if err != nil {
+					err = ufs.decideWalkError(path, err, WalkSkip, "SearchInDirectory")
+				}
+
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				matches = append(matches, found...)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, path := range files {
+		mu.Lock()
+		stop := done()
+		mu.Unlock()
+		if stop {
+			break
+		}
+		jobs <- path
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, ufs.wrapError(firstErr, "SearchInDirectory")
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Path != matches[j].Path {
+			return matches[i].Path < matches[j].Path
+		}
+		return matches[i].Line < matches[j].Line
+	})
+	if opts.MaxMatches > 0 && len(matches) > opts.MaxMatches {
+		matches = matches[:opts.MaxMatches]
+	}
+	return matches, nil
+}
+
+// searchFile returns the lines of a file matching re. When sniff is greater than 0,
+// files with a NUL byte in their first sniff bytes are considered binary and not searched.
+func (ufs *UFS) searchFile(path string, re *regexp.Regexp, sniff int) ([]SearchMatch, error) {
+	file, err := ufs.openSequential(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if sniff > 0 {
+		head := make([]byte, sniff)
+		n, err := io.ReadFull(file, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		if bytes.IndexByte(head[:n], 0) >= 0 {
+			return nil, nil
+		}
+		reader = io.MultiReader(bytes.NewReader(head[:n]), file)
+	}
+
+	matches := []SearchMatch{}
+	scanner := ufs.newLineScanner(reader)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		if re.Match(scanner.Bytes()) {
+			matches = append(matches, SearchMatch{Path: path, Line: lineNo, Text: scanner.Text()})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return matches, nil
+}
+
+// compile compiles the pattern of a search
+func (opts *SearchOptions) compile(pattern string) (*regexp.Regexp, error) {
+	if opts.Literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if opts.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// included reports whether a file, given by its path relative to the searched directory, must be searched
+func (opts *SearchOptions) included(rel string) bool {
+	return len(opts.Include) == 0 || matchSearchGlobs(opts.Include, rel)
+}
+
+// excluded reports whether a file or directory, given by its path relative to the searched directory, is skipped
+func (opts *SearchOptions) excluded(rel string) bool {
+	return matchSearchGlobs(opts.Exclude, rel)
+}
+
+// matchSearchGlobs reports whether the name or the slash separated relative path matches one of the globs
+func matchSearchGlobs(globs []string, rel string) bool {
+	for _, glob := range globs {
+		if compiled, err := compileGlob(glob); err == nil && compiled.matchName(filepath.ToSlash(rel)) {
+			return true
+		}
+	}
+	return false
+}
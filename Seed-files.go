@@ -0,0 +1,212 @@
+package ufs
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*
+Seed-files.go contains first-run seeding of default files, a common application bootstrap pattern.
+
+An application ships a tree of default files (configs, templates, ...) and copies it to the
+user's directory on start. SeedIfMissing only writes the files the user doesn't own:
+a seed manifest (.ufs-seed.json in the target directory) remembers the hash of every file it seeded,
+so on later runs it can tell apart:
+- files never seeded and missing: seeded.
+- files seeded and left untouched: updated when the default changed.
+- files created or modified by the user: kept as they are.
+- files seeded and then deleted by the user: not seeded again.
+
+Functions:
+- SeedIfMissing: Copies default files to a directory without overwriting user changes.
+*/
+
+// seedManifestName is the file name of the seed manifest inside the target directory
+const seedManifestName = ".ufs-seed.json"
+
+// seedManifestVersion is the version of the seed manifest format
+const seedManifestVersion = 1
+
+// SeedManifest records the files seeded by SeedIfMissing.
+type SeedManifest struct {
+	Version int               `json:"version"`
+	Updated time.Time         `json:"updated"`
+	Files   map[string]string `json:"files"` // Slash separated path relative to the target -> SHA-256 of the seeded content
+}
+
+// SeedReport describes what SeedIfMissing did. Paths are relative to the target directory.
+type SeedReport struct {
+	Seeded  []string // Files copied for the first time
+	Updated []string // Seeded files left untouched by the user, replaced by a newer default
+	Kept    []string // Files created, modified or deleted by the user, left as they are
+}
+
+// SeedIfMissing copies the files of seedDir to targetDir, except those the user created, modified or deleted.
+// A seed manifest is kept in targetDir to remember what was seeded.
+//
+// Parameters:
+//   - seedDir: The absolute or relative path to the directory holding the default files
+//   - targetDir: The absolute or relative path to the directory to seed, created if needed
+//
+// Returns:
+//   - *SeedReport: The seeded, updated and kept files
+//   - error: An error if a file couldn't be seeded or the manifest couldn't be written, nil otherwise
+//
+// Example:
+//
+//	report, err := ufs.SeedIfMissing("/usr/share/myapp/defaults", filepath.Join(home, ".config", "myapp"))
+//	if err != nil {
+//	    fmt.Printf("Error seeding configuration: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("%d files seeded, %d updated\n", len(report.Seeded), len(report.Updated))
+func (ufs *UFS) SeedIfMissing(seedDir, targetDir string) (_ *SeedReport, err error) {
+	defer ufs.recoverPanic("SeedIfMissing", &err)
+
+	if err := ufs.requireOS("SeedIfMissing"); err != nil {
+		return nil, err
+	}
+
+	// Verify source is a directory
+	if !ufs.IsDirectory(seedDir) {
+		return nil, ufs.misuseError("SeedIfMissing", "seed path is not a directory", seedDir)
+	}
+
+	seedDir, err = filepath.Abs(seedDir)
+	if err != nil {
+		return nil, ufs.wrapError(err, "SeedIfMissing")
+	}
+	targetDir, err = filepath.Abs(targetDir)
+	if err != nil {
+		return nil, ufs.wrapError(err, "SeedIfMissing")
+	}
+
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return nil, ufs.wrapError(err, "SeedIfMissing")
+	}
+
+	manifestPath := filepath.Join(targetDir, seedManifestName)
+	manifest, err := readSeedManifest(manifestPath)
+	if err != nil {
+		return nil, ufs.wrapError(err, "SeedIfMissing")
+	}
+
+	report := &SeedReport{}
+
+	// The manifest is saved even when a file fails, so the files already seeded are remembered
+	defer func() {
+		manifest.Updated = time.Now().UTC()
+		data, marshalErr := json.MarshalIndent(manifest, "", "  ")
+		if marshalErr == nil {
+			marshalErr = writeFileReplacing(manifestPath, data)
+		}
+		if marshalErr != nil && err == nil {
+			err = ufs.wrapError(marshalErr, "SeedIfMissing")
+		}
+	}()
+
+	err = filepath.Walk(seedDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkAbort, "SeedIfMissing")
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(seedDir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if key == seedManifestName {
+			return nil
+		}
+		target := filepath.Join(targetDir, rel)
+
+		seedHash, err := ufs.seedHash(path, info.Size())
+		if err != nil {
+			return err
+		}
+
+		seededHash, wasSeeded := manifest.Files[key]
+		targetInfo, statErr := os.Stat(target)
+
+		switch {
+		case os.IsNotExist(statErr) && !wasSeeded:
+			if err := ufs.CopyFileWithPermissions(path, target); err != nil {
+				return err
+			}
+			report.Seeded = append(report.Seeded, rel)
+
+		case statErr != nil && !os.IsNotExist(statErr):
+			return statErr
+
+		case os.IsNotExist(statErr), !wasSeeded, targetInfo.IsDir():
+			// Deleted after seeding, or created by the user
+			report.Kept = append(report.Kept, rel)
+			return nil
+
+		default:
+			targetHash, err := ufs.seedHash(target, targetInfo.Size())
+			if err != nil {
+				return err
+			}
+			if targetHash != seededHash {
+				report.Kept = append(report.Kept, rel)
+				return nil
+			}
+			if targetHash == seedHash {
+				return nil // Up to date
+			}
+			if err := ufs.CopyFileWithPermissions(path, target); err != nil {
+				return err
+			}
+			report.Updated = append(report.Updated, rel)
+		}
+
+		manifest.Files[key] = seedHash
+		return nil
+	})
+	if err != nil {
+		return report, ufs.wrapError(err, "SeedIfMissing")
+	}
+
+	return report, nil
+}
+
+// seedHash returns the hex encoded SHA-256 of a file
+func (ufs *UFS) seedHash(path string, size int64) (string, error) {
+	sum, err := ufs.fileDigest(path, size, DetectFullHash)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// readSeedManifest reads a seed manifest, an empty one when the file doesn't exist yet
+func readSeedManifest(path string) (*SeedManifest, error) {
+	manifest := &SeedManifest{Version: seedManifestVersion, Files: map[string]string{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("invalid seed manifest %s: %w", path, err)
+	}
+	if manifest.Version != seedManifestVersion {
+		return nil, fmt.Errorf("unsupported seed manifest version %d", manifest.Version)
+	}
+	if manifest.Files == nil {
+		manifest.Files = map[string]string{}
+	}
+	return manifest, nil
+}
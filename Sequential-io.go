@@ -0,0 +1,146 @@
+package ufs
+
+import (
+	"io"
+	"os"
+	"unsafe"
+)
+
+/*
+Sequential-io.go contains the read path used by large sequential reads (file copies and hashing).
+
+Two settings of Options tune it:
+- ReadAheadBytes: asks the OS to prefetch that many bytes ahead of the reader
+  (posix_fadvise on Linux, sequential scan hint on Windows).
+- DirectIO: bypasses the page cache (O_DIRECT on Linux, FILE_FLAG_NO_BUFFERING on Windows),
+  so backups of large trees don't evict the cache of interactive programs.
+  When the file system doesn't support it, the file is read normally. Reads into buffers that are
+  not aligned on blocks, like those of bufio, go through an internal aligned buffer.
+
+When neither is set files are opened with os.Open, exactly like before.
+The platform specific parts live in Sequential-io_linux.go, Sequential-io_windows.go
+and Sequential-io_others.go.
+*/
+
+// directIOAlignment is the buffer alignment required by O_DIRECT and FILE_FLAG_NO_BUFFERING
+const directIOAlignment = 4096
+
+// sequentialBufferSize is the size of the buffer used to copy tuned readers
+const sequentialBufferSize = 1 << 20
+
+// directReadSize is the size of the aligned buffer direct reads go through when the caller's
+// buffer can't be used, a multiple of directIOAlignment
+const directReadSize = 1 << 16
+
+// openSequential opens a file that will be read from start to end.
+// Without ReadAheadBytes and DirectIO this is os.Open, with a custom Backend its OpenFile.
+func (ufs *UFS) openSequential(path string) (io.ReadCloser, error) {
+	if !ufs.onOS() {
+		return ufs.backend().OpenFile(path, os.O_RDONLY, 0)
+	}
+	if ufs.opts.ReadAheadBytes <= 0 && !ufs.opts.DirectIO {
+		return os.Open(path)
+	}
+
+	file, direct, err := openFileSequential(path, ufs.opts.DirectIO)
+	if err != nil {
+		return nil, err
+	}
+	adviseSequential(file)
+
+	return &sequentialReader{file: file, direct: direct, readAhead: ufs.opts.ReadAheadBytes}, nil
+}
+
+// sequentialReader reads a file from start to end, requesting read-ahead as it goes
+type sequentialReader struct {
+	file      *os.File
+	direct    bool  // the file was opened for direct I/O, buffers must be aligned
+	readAhead int64 // number of bytes to prefetch ahead of the reader
+	offset    int64 // bytes read so far
+	advised   int64 // offset up to which read-ahead was requested
+
+	buffer  []byte // aligned buffer of direct reads into unaligned caller buffers
+	pending []byte // part of buffer not returned to the caller yet
+}
+
+func (r *sequentialReader) Read(p []byte) (int, error) {
+	// Request the next window when the reader gets past half of the current one
+	if r.readAhead > 0 && r.offset+r.readAhead/2 >= r.advised {
+		start := r.advised
+		if start < r.offset {
+			start = r.offset
+		}
+		adviseWillNeed(r.file, start, r.readAhead)
+		r.advised = start + r.readAhead
+	}
+
+	if len(r.pending) == 0 && (!r.direct || isAligned(p)) {
+		n, err := r.file.Read(p)
+		r.offset += int64(n)
+		return n, err
+	}
+
+	// Direct I/O fails with EINVAL on buffers not aligned on blocks: read through an aligned buffer
+	if len(r.pending) == 0 {
+		if r.buffer == nil {
+			r.buffer = alignedBuffer(directReadSize)
+		}
+		n, err := r.file.Read(r.buffer)
+		r.offset += int64(n)
+		r.pending = r.buffer[:n]
+		if n == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// WriteTo copies the file with an aligned buffer, which direct I/O requires.
+// io.Copy uses it instead of allocating its own buffer.
+func (r *sequentialReader) WriteTo(w io.Writer) (int64, error) {
+	buffer := alignedBuffer(sequentialBufferSize)
+
+	var written int64
+	for {
+		n, err := r.Read(buffer)
+		if n > 0 {
+			wn, werr := w.Write(buffer[:n])
+			written += int64(wn)
+			if werr != nil {
+				return written, werr
+			}
+			if wn != n {
+				return written, io.ErrShortWrite
+			}
+		}
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+func (r *sequentialReader) Close() error {
+	return r.file.Close()
+}
+
+// isAligned reports whether a buffer can be used for direct I/O: it starts at a directIOAlignment
+// boundary and its length is a multiple of it
+func isAligned(p []byte) bool {
+	return len(p) > 0 && len(p)%directIOAlignment == 0 &&
+		uintptr(unsafe.Pointer(&p[0]))%directIOAlignment == 0
+}
+
+// alignedBuffer returns a buffer of size bytes starting at a directIOAlignment boundary
+func alignedBuffer(size int) []byte {
+	buffer := make([]byte, size+directIOAlignment)
+	offset := 0
+	if remainder := int(uintptr(unsafe.Pointer(&buffer[0])) % directIOAlignment); remainder != 0 {
+		offset = directIOAlignment - remainder
+	}
+	return buffer[offset : offset+size]
+}
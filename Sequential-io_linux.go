@@ -0,0 +1,42 @@
+//go:build linux
+
+package ufs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// directIOSupported reports whether openFileSequential can bypass the page cache on this platform
+const directIOSupported = true
+
+// openFileSequential opens a file for reading, with O_DIRECT when direct is true.
+// File systems without O_DIRECT support (tmpfs, some network file systems) fall back to a normal open.
+func openFileSequential(path string, direct bool) (*os.File, bool, error) {
+	if direct {
+		file, err := os.OpenFile(path, os.O_RDONLY|unix.O_DIRECT, 0)
+		if err == nil {
+			return file, true, nil
+		}
+		if !os.IsNotExist(err) && !os.IsPermission(err) {
+			// EINVAL: direct I/O is not supported here, read through the cache
+			file, err = os.Open(path)
+			return file, false, err
+		}
+		return nil, false, err
+	}
+
+	file, err := os.Open(path)
+	return file, false, err
+}
+
+// adviseSequential tells the kernel the whole file will be read sequentially
+func adviseSequential(file *os.File) {
+	unix.Fadvise(int(file.Fd()), 0, 0, unix.FADV_SEQUENTIAL)
+}
+
+// adviseWillNeed asks the kernel to start reading the given range in the background
+func adviseWillNeed(file *os.File, offset, length int64) {
+	unix.Fadvise(int(file.Fd()), offset, length, unix.FADV_WILLNEED)
+}
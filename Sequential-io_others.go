@@ -0,0 +1,20 @@
+//go:build !linux && !windows
+
+package ufs
+
+import "os"
+
+// directIOSupported reports whether openFileSequential can bypass the page cache on this platform
+const directIOSupported = false
+
+// openFileSequential opens a file for reading, direct I/O is not supported on this platform
+func openFileSequential(path string, direct bool) (*os.File, bool, error) {
+	file, err := os.Open(path)
+	return file, false, err
+}
+
+// adviseSequential does nothing on this platform
+func adviseSequential(file *os.File) {}
+
+// adviseWillNeed does nothing on this platform
+func adviseWillNeed(file *os.File, offset, length int64) {}
@@ -0,0 +1,269 @@
+package ufs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+/*
+Shutdown-manager.go contains ShutdownManager, which stops the operations of a UFS instance cleanly
+when a service embedding ufs is asked to stop.
+
+Once a manager is attached to an instance (NewShutdownManager), its long operations are tracked:
+- Cancellable operations (CompressDirectory and the other ZIP compression functions, ExtractArchive and
+  the other ZIP extraction functions, OperationQueue.Run, and the functions run with Track) are cancelled
+  on shutdown, as if their context was cancelled: partial archives and extracted files are removed.
+- Atomic writes (WriteFileAtomic and the functions built on it, like WriteJSONFile, and ReplaceInFile)
+  are not cancelled: the shutdown waits for them to rename their temporary file over the destination.
+Then the flush functions registered with OnShutdown run, e.g. to save journals or close databases.
+
+After the shutdown started, new cancellable operations fail with ErrShuttingDown, while atomic writes
+are still accepted, so flush functions can save their state.
+
+	manager := ufs.NewShutdownManager()
+	manager.OnShutdown(func() error { return journal.Sync() })
+	stopped := manager.HandleSignals(30 * time.Second) // Ctrl+C or SIGTERM
+	go serve()
+	if err := <-stopped; err != nil {
+	    log.Printf("unclean shutdown: %v", err)
+	}
+
+Functions:
+- NewShutdownManager: Attaches a shutdown manager to the instance.
+- ShutdownManager.Context: Returns a context cancelled on shutdown.
+- ShutdownManager.Track: Runs an operation cancelled on shutdown.
+- ShutdownManager.OnShutdown: Registers a function run at the end of the shutdown.
+- ShutdownManager.Shutdown: Cancels the operations, waits for them and runs the flush functions.
+- ShutdownManager.HandleSignals: Shuts down on Ctrl+C or SIGTERM.
+*/
+
+// ShutdownManager tracks the in-flight operations of a UFS instance to stop them cleanly, see NewShutdownManager.
+// Its methods are safe for concurrent use.
+type ShutdownManager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu         sync.Mutex
+	stopping   bool
+	operations int           // Cancellable operations running
+	critical   int           // Atomic writes running
+	changed    chan struct{} // Closed when operations or critical decreases, while someone waits
+	flushers   []func() error
+	done       chan struct{} // Closed when the shutdown completed
+	err        error         // Result of the shutdown
+}
+
+// NewShutdownManager attaches a shutdown manager to the instance, so its long operations can be stopped
+// cleanly. An instance has a single manager: later calls return the same one.
+//
+// Returns:
+//   - *ShutdownManager: The manager of the instance
+//
+// Example:
+//
+//	manager := ufs.NewShutdownManager()
+//	go func() {
+//	    <-ctx.Done()
+//	    timeout, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	    defer cancel()
+//	    manager.Shutdown(timeout)
+//	}()
+func (ufs *UFS) NewShutdownManager() *ShutdownManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	manager := &ShutdownManager{ctx: ctx, cancel: cancel, done: make(chan struct{})}
+	if !ufs.shutdown.CompareAndSwap(nil, manager) {
+		cancel()
+		return ufs.shutdown.Load()
+	}
+	return manager
+}
+
+// Context returns a context cancelled when the shutdown starts, for the operations of the program
+// that are not run with Track.
+func (m *ShutdownManager) Context() context.Context {
+	return m.ctx
+}
+
+// Track runs an operation, giving it a context cancelled when the shutdown starts, and makes the
+// shutdown wait for it to return.
+//
+// Parameters:
+//   - op: The operation, which should stop soon after its context is cancelled
+//
+// Returns:
+//   - error: ErrShuttingDown if the shutdown already started, else the error returned by op
+//
+// Example:
+//
+//	err := manager.Track(func(ctx context.Context) error {
+//	    return ufs.CompressDirectoryContext(ctx, "/data", "/backups/data.zip")
+//	})
+func (m *ShutdownManager) Track(op func(ctx context.Context) error) error {
+	ctx, done, err := m.begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer done()
+	return op(ctx)
+}
+
+// OnShutdown registers a function run at the end of the shutdown, once the operations stopped.
+// Functions run in the reverse order of their registration, like deferred calls.
+//
+// Parameters:
+//   - flush: The function, e.g. saving a journal
+func (m *ShutdownManager) OnShutdown(flush func() error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flushers = append(m.flushers, flush)
+}
+
+// Shutdown cancels the cancellable operations, waits for them and for the atomic writes in progress,
+// then runs the functions registered with OnShutdown. Calling it again waits for the first shutdown.
+//
+// Parameters:
+//   - ctx: The context bounding the wait, e.g. with a timeout; the flush functions run even when it expires
+//
+// Returns:
+//   - error: The error of ctx if operations were still running when it expired, joined with the errors
+//     of the flush functions, nil otherwise
+func (m *ShutdownManager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	if m.stopping {
+		m.mu.Unlock()
+		select {
+		case <-m.done:
+			return m.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	m.stopping = true
+	m.mu.Unlock()
+
+	m.cancel()
+	errs := []error{m.wait(ctx)}
+
+	m.mu.Lock()
+	flushers := m.flushers
+	m.mu.Unlock()
+	for i := len(flushers) - 1; i >= 0; i-- {
+		errs = append(errs, flushers[i]())
+	}
+
+	m.err = errors.Join(errs...)
+	close(m.done)
+	return m.err
+}
+
+// HandleSignals shuts down when the program receives Ctrl+C (os.Interrupt) or SIGTERM, or the given signals.
+// Once the signal is received, a second one is not intercepted, so it stops the program right away.
+//
+// Parameters:
+//   - timeout: The longest time waited for the operations to stop, 0 waits as long as needed
+//   - signals: The signals to handle, none uses os.Interrupt and syscall.SIGTERM
+//
+// Returns:
+//   - <-chan error: Receives the result of Shutdown once the shutdown completed
+func (m *ShutdownManager) HandleSignals(timeout time.Duration, signals ...os.Signal) <-chan error {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+
+	result := make(chan error, 1)
+	go func() {
+		<-received
+		signal.Stop(received)
+
+		ctx := context.Background()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		result <- m.Shutdown(ctx)
+	}()
+	return result
+}
+
+// begin registers a cancellable operation, returning its context, cancelled on shutdown, and the function
+// to call when it returns
+func (m *ShutdownManager) begin(ctx context.Context) (context.Context, func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopping {
+		return nil, nil, ErrShuttingDown
+	}
+	m.operations++
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(m.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		m.release(&m.operations)
+	}, nil
+}
+
+// release decrements a counter of running operations and wakes up the shutdown waiting for them
+func (m *ShutdownManager) release(counter *int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	*counter--
+	if m.changed != nil {
+		close(m.changed)
+		m.changed = nil
+	}
+}
+
+// wait waits for the operations and the atomic writes to complete, or ctx to expire
+func (m *ShutdownManager) wait(ctx context.Context) error {
+	for {
+		m.mu.Lock()
+		if m.operations == 0 && m.critical == 0 {
+			m.mu.Unlock()
+			return nil
+		}
+		if m.changed == nil {
+			m.changed = make(chan struct{})
+		}
+		changed := m.changed
+		m.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// trackOperation registers a cancellable operation with the shutdown manager of the instance, if any.
+// It returns the context to use, cancelled on shutdown, and the function to call when the operation returns.
+func (ufs *UFS) trackOperation(ctx context.Context) (context.Context, func(), error) {
+	manager := ufs.shutdown.Load()
+	if manager == nil {
+		return ctx, func() {}, nil
+	}
+	return manager.begin(ctx)
+}
+
+// trackCritical registers an atomic write with the shutdown manager of the instance, if any, so the
+// shutdown waits for it. It returns the function to call when the write completed.
+func (ufs *UFS) trackCritical() func() {
+	manager := ufs.shutdown.Load()
+	if manager == nil {
+		return func() {}
+	}
+	manager.mu.Lock()
+	manager.critical++
+	manager.mu.Unlock()
+	return func() { manager.release(&manager.critical) }
+}
@@ -0,0 +1,164 @@
+package ufs
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+/*
+Signing.go contains functions to sign files and verify their signatures, e.g. the archives
+produced by CompressDirectory before publishing them.
+
+The signature covers the SHA-256 digest of the file, so files of any size are signed with
+constant memory. It is stored base64 encoded in a sidecar file next to the file: release.zip
+gets release.zip.sig. The signing algorithm is pluggable through the FileSigner and FileVerifier
+interfaces, to use keys held by an HSM or a cloud KMS; Ed25519Signer and Ed25519Verifier are
+the reference implementation.
+
+Functions:
+- SignFile: Signs a file and writes its signature sidecar.
+- VerifyFileSignature: Verifies a file against its signature sidecar.
+*/
+
+// signatureExtension is the extension of the signature sidecar files
+const signatureExtension = ".sig"
+
+// FileSigner signs the SHA-256 digest of a file.
+type FileSigner interface {
+	Sign(digest []byte) (signature []byte, err error)
+}
+
+// FileVerifier verifies a signature of the SHA-256 digest of a file.
+// Verify returns a non-nil error when the signature is invalid.
+type FileVerifier interface {
+	Verify(digest, signature []byte) error
+}
+
+// Ed25519Signer is a FileSigner using an Ed25519 private key.
+type Ed25519Signer struct {
+	PrivateKey ed25519.PrivateKey
+}
+
+// Sign signs the digest with the private key
+func (s Ed25519Signer) Sign(digest []byte) ([]byte, error) {
+	if len(s.PrivateKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid Ed25519 private key size %d", len(s.PrivateKey))
+	}
+	return ed25519.Sign(s.PrivateKey, digest), nil
+}
+
+// Ed25519Verifier is a FileVerifier using an Ed25519 public key.
+type Ed25519Verifier struct {
+	PublicKey ed25519.PublicKey
+}
+
+// Verify verifies the signature of the digest with the public key
+func (v Ed25519Verifier) Verify(digest, signature []byte) error {
+	if len(v.PublicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid Ed25519 public key size %d", len(v.PublicKey))
+	}
+	if !ed25519.Verify(v.PublicKey, digest, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// SignFile signs the SHA-256 digest of a file and writes the signature, base64 encoded,
+// to a sidecar file next to it (path + ".sig"), replacing any previous signature.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file to sign
+//   - signer: The signer, e.g. Ed25519Signer{PrivateKey: key}
+//
+// Returns:
+//   - string: The path of the signature file
+//   - error: An error if the file couldn't be read, signed or the signature couldn't be written, nil otherwise
+//
+// Example:
+//
+//	_, privateKey, _ := ed25519.GenerateKey(nil) // Load your release key instead
+//	if err := ufs.CompressDirectory("./build", "release.zip"); err != nil {
+//	    return
+//	}
+//	sigPath, err := ufs.SignFile("release.zip", ufs.Ed25519Signer{PrivateKey: privateKey})
+//	if err != nil {
+//	    fmt.Printf("Error signing release: %v\n", err)
+//	    return
+//	}
+//	fmt.Println("Signature written to", sigPath)
+func (ufs *UFS) SignFile(path string, signer FileSigner) (_ string, err error) {
+	defer ufs.recoverPanic("SignFile", &err)
+
+	if signer == nil {
+		return "", fmt.Errorf("SignFile: signer can't be nil")
+	}
+
+	if !ufs.IsFile(path) {
+		return "", ufs.misuseError("SignFile", "path is not a file", path)
+	}
+	digest, err := ufs.fileHash(path, HashSHA256)
+	if err != nil {
+		return "", ufs.wrapError(err, "SignFile")
+	}
+	signature, err := signer.Sign(digest)
+	if err != nil {
+		return "", ufs.wrapError(err, "SignFile")
+	}
+
+	sigPath := path + signatureExtension
+	if err := ufs.WriteFileAtomic(sigPath, []byte(base64.StdEncoding.EncodeToString(signature)+"\n")); err != nil {
+		return "", err
+	}
+	return sigPath, nil
+}
+
+// VerifyFileSignature verifies a file against the signature in its sidecar file (path + ".sig").
+//
+// Parameters:
+//   - path: The absolute or relative path to the signed file
+//   - verifier: The verifier, e.g. Ed25519Verifier{PublicKey: key}
+//
+// Returns:
+//   - error: An error matching ErrInvalidSignature if the signature doesn't match the file,
+//     an error if the file or its signature couldn't be read, nil if the signature is valid
+//
+// Example:
+//
+//	err := ufs.VerifyFileSignature("release.zip", ufs.Ed25519Verifier{PublicKey: releaseKey})
+//	if errors.Is(err, ufs.ErrInvalidSignature) {
+//	    fmt.Println("release.zip was tampered with")
+//	    return
+//	} else if err != nil {
+//	    fmt.Printf("Error verifying release: %v\n", err)
+//	    return
+//	}
+func (ufs *UFS) VerifyFileSignature(path string, verifier FileVerifier) (err error) {
+	defer ufs.recoverPanic("VerifyFileSignature", &err)
+
+	if verifier == nil {
+		return fmt.Errorf("VerifyFileSignature: verifier can't be nil")
+	}
+
+	encoded, err := readBackendFile(ufs.backend(), path+signatureExtension)
+	if err != nil {
+		return ufs.wrapError(err, "VerifyFileSignature")
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return fmt.Errorf("VerifyFileSignature: %w: malformed signature file %s", ErrInvalidSignature, path+signatureExtension)
+	}
+
+	if !ufs.IsFile(path) {
+		return ufs.misuseError("VerifyFileSignature", "path is not a file", path)
+	}
+	digest, err := ufs.fileHash(path, HashSHA256)
+	if err != nil {
+		return ufs.wrapError(err, "VerifyFileSignature")
+	}
+	if err := verifier.Verify(digest, signature); err != nil {
+		return fmt.Errorf("VerifyFileSignature: %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package ufs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+/*
+Split-manifest.go contains the manifest written by SplitFile, SplitFileWithOptions and SplitFileByLines,
+and AssembleFromManifest which rebuilds the file from it, for reliable transfers of large files in parts.
+
+Next to the parts, the split functions write <source file name>.manifest.json (unless
+SplitOptions.SkipManifest is set), listing the parts in order with their size and SHA-256.
+It is the SplitManifest format of CompressDirectorySplit, so the parts of a split archive
+can be assembled too:
+
+	{
+	  "version": 1,
+	  "archive": "backup.tar",
+	  "totalSize": 262144000,
+	  "chunkSize": 104857600,
+	  "sha256": "9f86d0...",
+	  "parts": [
+	    {"index": 1, "name": "backup_1.tar", "size": 104857600, "sha256": "e3b0c4..."},
+	    ...
+	  ]
+	}
+
+AssembleFromManifest checks every part against the manifest while writing it, so a part truncated or
+altered during the transfer is reported instead of silently producing a corrupted file. When the
+destination already holds the beginning of the file (an earlier assembly was interrupted, or failed
+on a bad part that was sent again), the parts already written are checked and kept, and the assembly
+resumes after them.
+
+Functions:
+- AssembleFromManifest: Rebuilds a split file from its manifest, verifying and resuming.
+*/
+
+// AssembleFromManifest rebuilds a split file from its manifest, reading the parts from the directory
+// of the manifest. Every part is checked against its size and SHA-256 while it is written.
+// If dst already exists, the parts it already holds are verified and kept, and the assembly resumes
+// with the first missing or different part; anything after it is discarded.
+// This function will create any parent directories if they don't exist.
+//
+// Parameters:
+//   - manifestPath: The path to the manifest written by the split functions
+//   - dst: The path of the file to rebuild
+//
+// Returns:
+//   - error: A *ChunkCorruptError matching ErrChunkCorrupt if a part doesn't match the manifest
+//     (dst then holds the parts before it, so the assembly can resume once the part is replaced),
+//     or an error if the manifest is invalid or a file couldn't be read or written, nil otherwise
+//
+// Example:
+//
+//	err := ufs.AssembleFromManifest("/srv/incoming/backup.tar.manifest.json", "/srv/restore/backup.tar")
+//	var corrupt *ufs.ChunkCorruptError
+//	if errors.As(err, &corrupt) {
+//	    fmt.Printf("Part %s is damaged, download it again\n", corrupt.Path)
+//	}
+func (ufs *UFS) AssembleFromManifest(manifestPath, dst string) (err error) {
+	defer ufs.recoverPanic("AssembleFromManifest", &err)
+
+	if err := ufs.requireOS("AssembleFromManifest"); err != nil {
+		return err
+	}
+	defer ufs.applyIOPriority()()
+
+	manifest, err := readSplitManifest(manifestPath)
+	if err != nil {
+		return ufs.wrapError(err, "AssembleFromManifest")
+	}
+	parts, err := manifest.orderedParts()
+	if err != nil {
+		return ufs.wrapError(err, "AssembleFromManifest")
+	}
+	dir := filepath.Dir(manifestPath)
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return ufs.wrapError(err, "AssembleFromManifest")
+	}
+	out, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return ufs.wrapError(err, "AssembleFromManifest")
+	}
+	defer out.Close()
+
+	info, err := out.Stat()
+	if err != nil {
+		return ufs.wrapError(err, "AssembleFromManifest")
+	}
+
+	// Keep the parts already written, as long as they match
+	offset, next := int64(0), 0
+	for ; next < len(parts); next++ {
+		part := parts[next]
+		if offset+part.Size > info.Size() {
+			break
+		}
+		sum := sha256.New()
+		if _, err := io.Copy(sum, io.NewSectionReader(out, offset, part.Size)); err != nil {
+			return ufs.wrapError(err, "AssembleFromManifest")
+		}
+		if hex.EncodeToString(sum.Sum(nil)) != part.SHA256 {
+			break
+		}
+		offset += part.Size
+	}
+	if err := out.Truncate(offset); err != nil {
+		return ufs.wrapError(err, "AssembleFromManifest")
+	}
+	if _, err := out.Seek(offset, io.SeekStart); err != nil {
+		return ufs.wrapError(err, "AssembleFromManifest")
+	}
+
+	for ; next < len(parts); next++ {
+		if err := ufs.appendManifestPart(out, filepath.Join(dir, parts[next].Name), parts[next], offset); err != nil {
+			return err
+		}
+		offset += parts[next].Size
+	}
+
+	if err := out.Sync(); err != nil {
+		return ufs.wrapError(err, "AssembleFromManifest")
+	}
+	return ufs.wrapError(out.Close(), "AssembleFromManifest")
+}
+
+// appendManifestPart writes a part at offset, the end of out, checking it against the manifest.
+// A part that doesn't match is removed from out.
+func (ufs *UFS) appendManifestPart(out *os.File, path string, part SplitPart, offset int64) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return ufs.wrapError(err, "AssembleFromManifest")
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return ufs.wrapError(err, "AssembleFromManifest")
+	}
+	corrupt := &ChunkCorruptError{Path: path, Index: part.Index, ExpectedSize: part.Size, ActualSize: info.Size(), Expected: part.SHA256}
+	if info.Size() != part.Size {
+		return corrupt
+	}
+
+	sum := sha256.New()
+	written, err := io.Copy(io.MultiWriter(out, sum), file)
+	if err == nil {
+		corrupt.ActualSize = written
+		corrupt.Actual = hex.EncodeToString(sum.Sum(nil))
+		if written != part.Size || corrupt.Actual != part.SHA256 {
+			err = corrupt
+		}
+	}
+	if err != nil {
+		// Leave dst ending with the last good part, so the assembly can resume
+		out.Truncate(offset)
+		out.Seek(offset, io.SeekStart)
+		if err == corrupt {
+			return err
+		}
+		return ufs.wrapError(err, "AssembleFromManifest")
+	}
+	return nil
+}
+
+// splitManifestWriter records the parts of a file while it is split, nil when no manifest is written
+type splitManifestWriter struct {
+	manifest SplitManifest
+	total    hash.Hash // SHA-256 of the whole file
+	part     hash.Hash // SHA-256 of the current part
+	partSize int64
+	path     string // Path of the manifest file
+}
+
+// newSplitManifestWriter starts the manifest of src, written in the directory of the parts.
+// chunkSize is the size of the parts, 0 when they are cut by lines.
+func newSplitManifestWriter(src, partDir string, chunkSize int64, opts *SplitOptions) *splitManifestWriter {
+	if opts != nil && opts.SkipManifest {
+		return nil
+	}
+	return &splitManifestWriter{
+		manifest: SplitManifest{Version: splitManifestVersion, Archive: filepath.Base(src), ChunkSize: chunkSize},
+		total:    sha256.New(),
+		part:     sha256.New(),
+		path:     filepath.Join(partDir, filepath.Base(src)+splitManifestSuffix),
+	}
+}
+
+// startPart returns the writer to use for a new part written to file
+func (w *splitManifestWriter) startPart(file io.Writer) io.Writer {
+	if w == nil {
+		return file
+	}
+	w.part.Reset()
+	w.partSize = 0
+	return io.MultiWriter(file, w)
+}
+
+// Write hashes the data written to the current part
+func (w *splitManifestWriter) Write(p []byte) (int, error) {
+	w.total.Write(p)
+	w.part.Write(p)
+	w.partSize += int64(len(p))
+	return len(p), nil
+}
+
+// endPart records the current part, once its content is written
+func (w *splitManifestWriter) endPart(path string) {
+	if w == nil {
+		return
+	}
+	w.manifest.Parts = append(w.manifest.Parts, SplitPart{
+		Index:  len(w.manifest.Parts) + 1,
+		Name:   filepath.Base(path),
+		Size:   w.partSize,
+		SHA256: hex.EncodeToString(w.part.Sum(nil)),
+	})
+	w.manifest.TotalSize += w.partSize
+}
+
+// save writes the manifest file, once all the parts are written
+func (w *splitManifestWriter) save(ufs *UFS) error {
+	if w == nil {
+		return nil
+	}
+	w.manifest.SHA256 = hex.EncodeToString(w.total.Sum(nil))
+	return ufs.WriteJSONFile(w.path, w.manifest, "  ")
+}
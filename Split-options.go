@@ -0,0 +1,262 @@
+package ufs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+/*
+Split-options.go contains the settings used to control how SplitFile names and places the parts of a file,
+and the splitting of text files by line count.
+
+By default the parts are written next to the source, named with a _1, _2, ... suffix
+("data_1.csv", "data_2.csv"), with a manifest to rebuild the file (see Split-manifest.go).
+SplitOptions.NameTemplate and SplitOptions.OutputDir change the names and the directory:
+
+	parts, err := ufs.SplitFileByLines("export.csv", 100000, &ufs.SplitOptions{
+	    NameTemplate: "{name}.part{num:03d}{ext}", // export.part001.csv, export.part002.csv, ...
+	    OutputDir:    "/srv/upload/batches",
+	})
+
+The template placeholders are:
+- {name}: The source file name without its extension
+- {ext}: The extension of the source file, with its dot (empty if it has none)
+- {num}: The part number, starting at 1. {num:03d} pads it with zeros to 3 digits, like printf
+
+Functions:
+- SplitFileWithOptions: SplitFile with a naming template and output directory.
+- SplitFileByLines: Splits a text file into parts of a number of lines.
+*/
+
+// defaultSplitTemplate names the parts like SplitFile always did
+const defaultSplitTemplate = "{name}_{num}{ext}"
+
+// splitPlaceholder matches the placeholders of a naming template, with their optional format
+var splitPlaceholder = regexp.MustCompile(`\{(\w+)(?::([^}]*))?\}`)
+
+// splitNumberFormat matches the formats accepted by {num}, like "03d"
+var splitNumberFormat = regexp.MustCompile(`^0?\d*d$`)
+
+// SplitOptions controls how the parts of a split file are named and where they are written.
+// The zero value writes the parts next to the source, named with a _1, _2, ... suffix.
+type SplitOptions struct {
+	// NameTemplate is the file name of the parts, with the {name}, {ext} and {num} placeholders
+	// (see the top of Split-options.go). It must contain {num}. Empty uses "{name}_{num}{ext}"
+	NameTemplate string
+
+	// OutputDir is the directory the parts are written to, created if needed.
+	// Empty uses the directory of the source file
+	OutputDir string
+
+	// SkipManifest doesn't write the manifest of the parts (see Split-manifest.go)
+	SkipManifest bool
+}
+
+// SplitFileWithOptions splits a file into multiple files of chunkSize bytes like SplitFile,
+// naming the parts after opts.NameTemplate in opts.OutputDir.
+//
+// Parameters:
+//   - src: The path to the source file to split
+//   - chunkSize: The maximum size in bytes of each split file
+//   - opts: The naming settings, nil uses the defaults of SplitFile
+//
+// Returns:
+//   - []string: A slice of paths to the created split files
+//   - error: An error if the template is invalid or the file couldn't be split
+//
+// Example:
+//
+//	parts, err := ufs.SplitFileWithOptions("backup.tar", 100*1024*1024, &ufs.SplitOptions{
+//	    NameTemplate: "{name}{ext}.{num:03d}", // backup.tar.001, backup.tar.002, ...
+//	    OutputDir:    "/mnt/usb",
+//	})
+//	if err != nil {
+//	    fmt.Printf("Error splitting file: %v\n", err)
+//	    return
+//	}
+//	fmt.Printf("File split into %d parts\n", len(parts))
+func (ufs *UFS) SplitFileWithOptions(src string, chunkSize int64, opts *SplitOptions) (_ []string, err error) {
+	defer ufs.recoverPanic("SplitFileWithOptions", &err)
+
+	if err := ufs.requireOS("SplitFileWithOptions"); err != nil {
+		return nil, err
+	}
+	return ufs.splitFileBySize(src, chunkSize, opts, "SplitFileWithOptions")
+}
+
+// SplitFileByLines splits a text file into multiple files of linesPerChunk lines each, the last one
+// holding the remaining lines. Lines are copied as is, with their line endings, so assembling the parts
+// (see AssembleFiles) gives back the original file. Lines of any length are supported.
+//
+// Parameters:
+//   - src: The path to the source file to split
+//   - linesPerChunk: The maximum number of lines of each split file
+//   - opts: The naming settings, nil writes the parts next to the source with a _1, _2, ... suffix
+//
+// Returns:
+//   - []string: A slice of paths to the created split files
+//   - error: An error if the file is empty, the template is invalid or the file couldn't be split
+//
+// Example:
+//
+//	parts, err := ufs.SplitFileByLines("access.log", 50000, &ufs.SplitOptions{
+//	    NameTemplate: "{name}.part{num:03d}{ext}",
+//	    OutputDir:    "chunks",
+//	})
+//	if err != nil {
+//	    fmt.Printf("Error splitting log: %v\n", err)
+//	    return
+//	}
+//	for _, part := range parts {
+//	    fmt.Println(part) // chunks/access.part001.log, ...
+//	}
+func (ufs *UFS) SplitFileByLines(src string, linesPerChunk int, opts *SplitOptions) (splitFiles []string, err error) {
+	defer ufs.recoverPanic("SplitFileByLines", &err)
+
+	if err := ufs.requireOS("SplitFileByLines"); err != nil {
+		return nil, err
+	}
+
+	if !ufs.IsFile(src) {
+		return nil, ufs.misuseError("SplitFileByLines", "source is not a file", src)
+	}
+	if linesPerChunk <= 0 {
+		return nil, fmt.Errorf("SplitFileByLines: lines per chunk must be positive, got %d", linesPerChunk)
+	}
+	namer, err := opts.partNamer(src)
+	if err != nil {
+		return nil, fmt.Errorf("SplitFileByLines: %w", err)
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return nil, ufs.wrapError(err, "SplitFileByLines")
+	}
+	defer srcFile.Close()
+
+	reader := bufio.NewReaderSize(srcFile, streamBufferSize)
+	if _, err := reader.Peek(1); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("file is empty, nothing to split: %s", src)
+		}
+		return nil, ufs.wrapError(err, "SplitFileByLines")
+	}
+
+	var manifest *splitManifestWriter
+	var part *bufio.Writer
+	var partFile *os.File
+	closePart := func() error {
+		if partFile == nil {
+			return nil
+		}
+		err := part.Flush()
+		if closeErr := partFile.Close(); err == nil {
+			err = closeErr
+		}
+		partFile = nil
+		if err == nil {
+			manifest.endPart(splitFiles[len(splitFiles)-1])
+		}
+		return err
+	}
+	defer closePart()
+
+	lines := 0
+	for {
+		// ReadSlice returns long lines in several pieces, only the last one ends the line
+		piece, readErr := reader.ReadSlice('\n')
+		if len(piece) > 0 {
+			if partFile == nil {
+				name := namer(len(splitFiles) + 1)
+				if len(splitFiles) == 0 {
+					if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+						return nil, ufs.wrapError(err, "SplitFileByLines")
+					}
+					manifest = newSplitManifestWriter(src, filepath.Dir(name), 0, opts)
+				}
+				if partFile, err = os.Create(name); err != nil {
+					return splitFiles, ufs.wrapError(err, "SplitFileByLines")
+				}
+				splitFiles = append(splitFiles, name)
+				part = bufio.NewWriterSize(manifest.startPart(partFile), streamBufferSize)
+			}
+			if _, err := part.Write(piece); err != nil {
+				return splitFiles, ufs.wrapError(err, "SplitFileByLines")
+			}
+		}
+
+		if readErr == bufio.ErrBufferFull {
+			continue
+		}
+		if readErr != nil && readErr != io.EOF {
+			return splitFiles, ufs.wrapError(readErr, "SplitFileByLines")
+		}
+
+		if len(piece) > 0 {
+			lines++
+		}
+		if lines == linesPerChunk || readErr != nil {
+			if err := closePart(); err != nil {
+				return splitFiles, ufs.wrapError(err, "SplitFileByLines")
+			}
+			lines = 0
+		}
+		if readErr != nil {
+			return splitFiles, manifest.save(ufs)
+		}
+	}
+}
+
+// partNamer returns the function giving the path of part num of src, after validating the template
+func (opts *SplitOptions) partNamer(src string) (func(num int) string, error) {
+	template, dir := defaultSplitTemplate, filepath.Dir(src)
+	if opts != nil && opts.NameTemplate != "" {
+		template = opts.NameTemplate
+	}
+	if opts != nil && opts.OutputDir != "" {
+		dir = opts.OutputDir
+	}
+
+	ext := filepath.Ext(src)
+	name := strings.TrimSuffix(filepath.Base(src), ext)
+
+	// Check the placeholders once, so naming the parts can't fail
+	hasNumber := false
+	for _, match := range splitPlaceholder.FindAllStringSubmatch(template, -1) {
+		switch key, format := match[1], match[2]; {
+		case key == "num" && (format == "" || splitNumberFormat.MatchString(format)):
+			hasNumber = true
+		case (key == "name" || key == "ext") && format == "":
+		default:
+			return nil, fmt.Errorf("invalid placeholder %s in name template %q", match[0], template)
+		}
+	}
+	if !hasNumber {
+		return nil, fmt.Errorf("name template %q has no {num} placeholder", template)
+	}
+	if strings.ContainsAny(template, `/\`) {
+		return nil, fmt.Errorf("name template %q must be a file name, use OutputDir for the directory", template)
+	}
+
+	return func(num int) string {
+		partName := splitPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+			match := splitPlaceholder.FindStringSubmatch(placeholder)
+			switch match[1] {
+			case "name":
+				return name
+			case "ext":
+				return ext
+			}
+			if match[2] == "" {
+				return fmt.Sprint(num)
+			}
+			return fmt.Sprintf("%"+match[2], num)
+		})
+		return filepath.Join(dir, partName)
+	}, nil
+}
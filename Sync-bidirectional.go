@@ -0,0 +1,600 @@
+package ufs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+Sync-bidirectional.go contains the two-way synchronization of directory trees, e.g. a laptop and a
+USB drive both edited between runs.
+
+BidirectionalSync remembers in a state file the entries both trees had after the previous run, with
+their size, modification time and type on each side. That tells apart an entry created on one side
+(copied to the other) from an entry deleted on one side (deleted from the other), and a change on one
+side (copied over the other) from a change on both sides: a conflict, resolved with the
+BidirectionalSyncOptions.Conflict strategy:
+- ConflictNewerWins: the most recently modified version is kept (default)
+- ConflictLargerWins: the largest version is kept
+- ConflictKeepBoth: both versions are kept, the one of b under a new name, e.g. "notes (conflict).txt"
+- ConflictAsk: BidirectionalSyncOptions.OnConflict decides, per conflict
+
+An entry changed on one side and deleted on the other is restored: the change wins over the deletion.
+Without a state file, every run is like the first one: entries missing from one side are copied to
+it, nothing is deleted, and files differing on both sides are conflicts.
+
+Functions:
+- BidirectionalSync: Synchronizes two directory trees both ways.
+*/
+
+// ConflictStrategy selects how BidirectionalSync resolves a file changed on both sides.
+type ConflictStrategy int
+
+const (
+	// ConflictNewerWins keeps the version with the latest modification time, a on equal times (default)
+	ConflictNewerWins ConflictStrategy = iota
+	// ConflictLargerWins keeps the largest version, a on equal sizes
+	ConflictLargerWins
+	// ConflictKeepBoth keeps the version of a under the name and the one of b under a conflict name, on both sides
+	ConflictKeepBoth
+	// ConflictAsk calls BidirectionalSyncOptions.OnConflict for every conflict
+	ConflictAsk
+)
+
+// ConflictResolution is the decision taken for a conflict, see BidirectionalSyncOptions.OnConflict.
+type ConflictResolution int
+
+const (
+	// ResolveKeepA copies the version of a over the one of b
+	ResolveKeepA ConflictResolution = iota
+	// ResolveKeepB copies the version of b over the one of a
+	ResolveKeepB
+	// ResolveKeepBoth keeps both versions, like ConflictKeepBoth
+	ResolveKeepBoth
+	// ResolveSkip leaves both versions as they are, the conflict comes back on the next run
+	ResolveSkip
+)
+
+// SyncConflict is an entry changed on both sides, given to BidirectionalSyncOptions.OnConflict.
+type SyncConflict struct {
+	Path  string      // Path of the entry, relative to the roots
+	PathA string      // Path of the entry in a
+	PathB string      // Path of the entry in b
+	A     os.FileInfo // The version of a
+	B     os.FileInfo // The version of b
+}
+
+// BidirectionalSyncOptions controls how BidirectionalSync synchronizes two trees. The zero value
+// resolves conflicts with ConflictNewerWins and keeps no state, so nothing is deleted.
+type BidirectionalSyncOptions struct {
+	Detection ChangeDetection // Strategy used to decide if two files differing on both sides are equal anyway
+
+	// StateFile is the path of the state file remembering the entries synchronized, required to
+	// propagate deletions. It must be kept between the runs, and outside of both trees.
+	StateFile string
+
+	Conflict ConflictStrategy // How files changed on both sides are resolved
+
+	// OnConflict decides the resolution of every conflict with ConflictAsk
+	OnConflict func(conflict SyncConflict) ConflictResolution
+
+	// ConflictSuffix is added before the extension of the versions of b kept by ConflictKeepBoth,
+	// " (conflict)" by default
+	ConflictSuffix string
+}
+
+// BidirectionalSyncReport is the result of BidirectionalSync. Paths are relative to the roots.
+type BidirectionalSyncReport struct {
+	CopiedToA    []string      // Entries copied from b to a, because they were new or changed in b
+	CopiedToB    []string      // Entries copied from a to b, because they were new or changed in a
+	DeletedFromA []string      // Entries deleted from a, because they were deleted from b
+	DeletedFromB []string      // Entries deleted from b, because they were deleted from a
+	Conflicts    []string      // Entries changed on both sides, resolved or not
+	Unresolved   []string      // Conflicts left as they were by ResolveSkip
+	Errors       []SyncFailure // Entries that couldn't be synchronized
+}
+
+// OK reports whether every entry was synchronized, without unresolved conflicts
+func (report *BidirectionalSyncReport) OK() bool {
+	return len(report.Errors) == 0 && len(report.Unresolved) == 0
+}
+
+// BidirectionalSync synchronizes the trees a and b both ways: entries created or changed on one side
+// are copied to the other, entries deleted on one side are deleted from the other (with a state file),
+// and files changed on both sides are resolved with opts.Conflict.
+// Files are copied with their permissions and modification time, symbolic links are recreated with
+// the same target. Entries that fail are listed in the report and the synchronization goes on.
+// Hidden entries are left alone on both sides when Options.ExcludeHidden is set.
+//
+// Parameters:
+//   - a: The absolute or relative path to the first directory
+//   - b: The absolute or relative path to the second directory, created if needed on the first run
+//   - opts: Synchronization options, nil resolves conflicts with ConflictNewerWins and deletes nothing
+//
+// Returns:
+//   - *BidirectionalSyncReport: The entries copied and deleted each way, and the conflicts
+//   - error: An error if the roots are invalid or nested, a root is missing after a previous run
+//     (an unmounted drive must not delete everything), or a tree or the state file couldn't be read
+//
+// Example:
+//
+//	report, err := ufs.BidirectionalSync("/home/ana/notes", "/media/usb/notes", &ufs.BidirectionalSyncOptions{
+//	    StateFile: "/home/ana/.config/notes-sync.json",
+//	    Conflict:  ufs.ConflictKeepBoth,
+//	})
+//	if err != nil {
+//	    fmt.Printf("Error synchronizing: %v\n", err)
+//	    return
+//	}
+//	for _, rel := range report.Conflicts {
+//	    fmt.Printf("Edited on both sides: %s\n", rel)
+//	}
+func (ufs *UFS) BidirectionalSync(a, b string, opts *BidirectionalSyncOptions) (_ *BidirectionalSyncReport, err error) {
+	defer ufs.recoverPanic("BidirectionalSync", &err)
+
+	if err := ufs.requireOS("BidirectionalSync"); err != nil {
+		return nil, err
+	}
+	defer ufs.applyIOPriority()()
+
+	if opts == nil {
+		opts = &BidirectionalSyncOptions{}
+	}
+	if opts.Conflict == ConflictAsk && opts.OnConflict == nil {
+		return nil, fmt.Errorf("BidirectionalSync: ConflictAsk requires OnConflict")
+	}
+
+	a, err = filepath.Abs(a)
+	if err != nil {
+		return nil, ufs.wrapError(err, "BidirectionalSync")
+	}
+	b, err = filepath.Abs(b)
+	if err != nil {
+		return nil, ufs.wrapError(err, "BidirectionalSync")
+	}
+	if a == b || strings.HasPrefix(a, b+string(filepath.Separator)) || strings.HasPrefix(b, a+string(filepath.Separator)) {
+		return nil, fmt.Errorf("BidirectionalSync: can't synchronize nested directories %s and %s", a, b)
+	}
+
+	state, err := loadBidirectionalState(opts.StateFile, a, b)
+	if err != nil {
+		return nil, ufs.wrapError(err, "BidirectionalSync")
+	}
+	for _, root := range []string{a, b} {
+		switch {
+		case ufs.IsDirectory(root):
+		case ufs.PathExists(root):
+			return nil, ufs.misuseError("BidirectionalSync", "source path is not a directory", root)
+		case len(state.Entries) > 0:
+			return nil, fmt.Errorf("BidirectionalSync: directory is missing since the previous run: %s", root)
+		}
+	}
+	if !ufs.IsDirectory(a) && !ufs.IsDirectory(b) {
+		return nil, ufs.misuseError("BidirectionalSync", "source path is not a directory", a)
+	}
+	entries := [2]map[string]os.FileInfo{}
+	for i, root := range []string{a, b} {
+		entries[i] = map[string]os.FileInfo{}
+		if !ufs.PathExists(root) && ufs.dryRun("BidirectionalSync", DryRunMkdir, root, "") {
+			continue
+		}
+		if err := os.MkdirAll(root, 0755); err != nil {
+			return nil, ufs.wrapError(err, "BidirectionalSync")
+		}
+		if entries[i], err = ufs.collectEntries(root, "BidirectionalSync"); err != nil {
+			return nil, ufs.wrapError(err, "BidirectionalSync")
+		}
+	}
+	entriesA, entriesB := entries[0], entries[1]
+
+	s := &bidirectionalSync{ufs: ufs, opts: opts, a: a, b: b, state: state, report: &BidirectionalSyncReport{}}
+
+	// Sorted, so directories are created before their entries
+	union := map[string]bool{}
+	for _, entries := range []map[string]os.FileInfo{entriesA, entriesB} {
+		for rel := range entries {
+			union[rel] = true
+		}
+	}
+	for rel := range state.Entries {
+		union[rel] = true
+	}
+	rels := make([]string, 0, len(union))
+	for rel := range union {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+
+	var deletions []string
+	for _, rel := range rels {
+		infoA, inA := entriesA[rel]
+		infoB, inB := entriesB[rel]
+		known, inState := state.Entries[rel]
+		changedA := inA && (!inState || !known.A.matches(infoA))
+		changedB := inB && (!inState || !known.B.matches(infoB))
+
+		switch {
+		case inA && inB:
+			if infoA.Mode().Type() != infoB.Mode().Type() {
+				s.fail(rel, fmt.Errorf("a has a %s, b a %s", entryKind(infoA), entryKind(infoB)))
+				continue
+			}
+			if infoA.IsDir() || (!changedA && !changedB) {
+				s.record(rel)
+				continue
+			}
+			if changedA && changedB {
+				s.conflict(rel, infoA, infoB)
+				continue
+			}
+			s.copy(rel, changedA, infoA, infoB)
+
+		case inA:
+			// Deleted from b, unless it changed in a meanwhile
+			if inState && !changedA {
+				deletions = append(deletions, rel)
+				continue
+			}
+			s.copy(rel, true, infoA, infoB)
+
+		case inB:
+			if inState && !changedB {
+				deletions = append(deletions, rel)
+				continue
+			}
+			s.copy(rel, false, infoA, infoB)
+
+		default:
+			// Deleted from both sides
+			s.forget(rel)
+		}
+	}
+
+	// Reversed, so the entries of a directory are removed before it
+	for i := len(deletions) - 1; i >= 0; i-- {
+		_, inA := entriesA[deletions[i]]
+		s.remove(deletions[i], inA)
+	}
+
+	report := s.report
+	for _, list := range []*[]string{&report.CopiedToA, &report.CopiedToB, &report.DeletedFromA, &report.DeletedFromB} {
+		sort.Strings(*list)
+	}
+
+	if ufs.IsDryRun() {
+		return report, nil
+	}
+	if err := state.save(); err != nil {
+		return report, ufs.wrapError(err, "BidirectionalSync")
+	}
+	return report, nil
+}
+
+// bidirectionalSync holds a run of BidirectionalSync
+type bidirectionalSync struct {
+	ufs    *UFS
+	opts   *BidirectionalSyncOptions
+	a, b   string
+	state  *bidirectionalState
+	report *BidirectionalSyncReport
+}
+
+func (s *bidirectionalSync) fail(rel string, err error) {
+	s.report.Errors = append(s.report.Errors, SyncFailure{Path: rel, Err: err})
+}
+
+// paths returns the path of rel in a and in b
+func (s *bidirectionalSync) paths(rel string) (string, string) {
+	return filepath.Join(s.a, rel), filepath.Join(s.b, rel)
+}
+
+// copy copies rel from a to b (fromA) or from b to a, over the version of the destination if any
+func (s *bidirectionalSync) copy(rel string, fromA bool, infoA, infoB os.FileInfo) {
+	pathA, pathB := s.paths(rel)
+	src, dst, info, copied := pathA, pathB, infoA, &s.report.CopiedToB
+	if !fromA {
+		src, dst, info, copied = pathB, pathA, infoB, &s.report.CopiedToA
+	}
+
+	if err := s.copyEntry(src, dst, info); err != nil {
+		s.fail(rel, err)
+		return
+	}
+	*copied = append(*copied, rel)
+	s.record(rel)
+}
+
+// copyEntry copies a directory, symbolic link or file for BidirectionalSync
+func (s *bidirectionalSync) copyEntry(src, dst string, info os.FileInfo) error {
+	switch {
+	case info.IsDir():
+		if s.ufs.dryRun("BidirectionalSync", DryRunMkdir, dst, "") {
+			return nil
+		}
+		return os.MkdirAll(dst, info.Mode().Perm()|0700)
+
+	case info.Mode()&os.ModeSymlink != 0:
+		link, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		if err := s.ufs.requireCapability(CapabilitySymlinks, "BidirectionalSync", src); err != nil {
+			return err
+		}
+		if s.ufs.dryRun("BidirectionalSync", DryRunSymlink, dst, link) {
+			return nil
+		}
+		if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		return os.Symlink(link, dst)
+
+	case info.Mode().IsRegular():
+		if s.ufs.dryRun("BidirectionalSync", DryRunCopy, dst, src) {
+			return nil
+		}
+		return s.ufs.syncFile(src, dst, info)
+	}
+	return fmt.Errorf("special files can't be synchronized")
+}
+
+// conflict resolves an entry changed on both sides
+func (s *bidirectionalSync) conflict(rel string, infoA, infoB os.FileInfo) {
+	pathA, pathB := s.paths(rel)
+
+	// Changed the same way, e.g. the same file saved on both sides
+	equal, err := s.equal(pathA, infoA, pathB, infoB)
+	if err != nil {
+		s.fail(rel, err)
+		return
+	}
+	if equal {
+		s.record(rel)
+		return
+	}
+
+	s.report.Conflicts = append(s.report.Conflicts, rel)
+	resolution := ResolveKeepA
+	switch s.opts.Conflict {
+	case ConflictLargerWins:
+		if infoB.Size() > infoA.Size() {
+			resolution = ResolveKeepB
+		}
+	case ConflictKeepBoth:
+		resolution = ResolveKeepBoth
+	case ConflictAsk:
+		resolution = s.opts.OnConflict(SyncConflict{Path: rel, PathA: pathA, PathB: pathB, A: infoA, B: infoB})
+	default:
+		if infoB.ModTime().After(infoA.ModTime()) {
+			resolution = ResolveKeepB
+		}
+	}
+
+	switch resolution {
+	case ResolveKeepA:
+		s.copy(rel, true, infoA, infoB)
+	case ResolveKeepB:
+		s.copy(rel, false, infoA, infoB)
+	case ResolveKeepBoth:
+		s.keepBoth(rel, infoA, infoB)
+	default:
+		s.report.Unresolved = append(s.report.Unresolved, rel)
+	}
+}
+
+// equal reports whether the versions of an entry changed on both sides are the same
+func (s *bidirectionalSync) equal(pathA string, infoA os.FileInfo, pathB string, infoB os.FileInfo) (bool, error) {
+	if infoA.Mode()&os.ModeSymlink != 0 {
+		linkA, err := os.Readlink(pathA)
+		if err != nil {
+			return false, err
+		}
+		linkB, err := os.Readlink(pathB)
+		return linkA == linkB, err
+	}
+	if !infoA.Mode().IsRegular() {
+		return false, nil
+	}
+	changed, err := s.ufs.fileInfoChanged(nil, pathA, infoA, pathB, infoB, s.opts.Detection)
+	return !changed, err
+}
+
+// keepBoth renames the version of b to a conflict name free on both sides and copies it to a,
+// then copies the version of a to b
+func (s *bidirectionalSync) keepBoth(rel string, infoA, infoB os.FileInfo) {
+	suffix := s.opts.ConflictSuffix
+	if suffix == "" {
+		suffix = " (conflict)"
+	}
+	dir, base := filepath.Split(rel)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	if stem == "" {
+		// Dot files like ".env" have no stem, keep the whole name
+		stem, ext = base, ""
+	}
+
+	conflictRel := ""
+	for i := 1; conflictRel == ""; i++ {
+		candidate := filepath.Join(dir, stem+suffix+ext)
+		if i > 1 {
+			candidate = filepath.Join(dir, fmt.Sprintf("%s%s %d%s", stem, suffix, i, ext))
+		}
+		candidateA, candidateB := s.paths(candidate)
+		_, errA := os.Lstat(candidateA)
+		_, errB := os.Lstat(candidateB)
+		if os.IsNotExist(errA) && os.IsNotExist(errB) {
+			conflictRel = candidate
+		}
+	}
+
+	_, pathB := s.paths(rel)
+	_, conflictB := s.paths(conflictRel)
+	if !s.ufs.dryRun("BidirectionalSync", DryRunMove, conflictB, pathB) {
+		if err := os.Rename(pathB, conflictB); err != nil {
+			s.fail(rel, err)
+			return
+		}
+	}
+	s.copy(conflictRel, false, nil, infoB)
+	s.copy(rel, true, infoA, nil)
+}
+
+// remove deletes rel from a (onA) or b, because it was deleted from the other side.
+// A directory holding entries copied during this run is kept and recreated on the other side instead.
+func (s *bidirectionalSync) remove(rel string, onA bool) {
+	pathA, pathB := s.paths(rel)
+	target, other, deleted := pathB, pathA, &s.report.DeletedFromB
+	if onA {
+		target, other, deleted = pathA, pathB, &s.report.DeletedFromA
+	}
+
+	if info, err := os.Lstat(other); err == nil && info.IsDir() {
+		// Recreated by the copy of new entries
+		s.record(rel)
+		return
+	}
+
+	if s.ufs.dryRun("BidirectionalSync", DryRunRemove, target, "") {
+		*deleted = append(*deleted, rel)
+		return
+	}
+
+	err := os.Remove(target)
+	if s.ufs.IsDirectoryNotEmpty(err) {
+		// Holds entries left out of the synchronization, e.g. hidden ones
+		info, statErr := os.Lstat(target)
+		if statErr == nil {
+			err = os.MkdirAll(other, info.Mode().Perm()|0700)
+		}
+		if err != nil {
+			s.fail(rel, err)
+			return
+		}
+		s.record(rel)
+		return
+	}
+	if err != nil && !os.IsNotExist(err) {
+		s.fail(rel, err)
+		return
+	}
+	s.ufs.recordQuota(target)
+	*deleted = append(*deleted, rel)
+	s.forget(rel)
+}
+
+// record remembers rel as synchronized, with the current state of both sides
+func (s *bidirectionalSync) record(rel string) {
+	pathA, pathB := s.paths(rel)
+	infoA, errA := os.Lstat(pathA)
+	infoB, errB := os.Lstat(pathB)
+	if errA != nil || errB != nil {
+		return
+	}
+	s.state.Entries[rel] = bidirectionalEntry{A: newBidirectionalSide(infoA), B: newBidirectionalSide(infoB)}
+	s.state.dirty = true
+}
+
+// forget removes rel from the state, it is on neither side
+func (s *bidirectionalSync) forget(rel string) {
+	if _, ok := s.state.Entries[rel]; ok {
+		delete(s.state.Entries, rel)
+		s.state.dirty = true
+	}
+}
+
+// bidirectionalStateVersion is the version of the state file format
+const bidirectionalStateVersion = 1
+
+// bidirectionalSide is what is remembered about an entry on one side
+type bidirectionalSide struct {
+	Size    int64       `json:"size"`
+	ModTime time.Time   `json:"modTime"`
+	Type    os.FileMode `json:"type"`
+}
+
+func newBidirectionalSide(info os.FileInfo) bidirectionalSide {
+	return bidirectionalSide{Size: info.Size(), ModTime: info.ModTime(), Type: info.Mode().Type()}
+}
+
+// matches reports whether info is the entry remembered, directories only compare by type
+// as their size and modification time change with their entries
+func (side bidirectionalSide) matches(info os.FileInfo) bool {
+	if side.Type != info.Mode().Type() {
+		return false
+	}
+	return info.IsDir() || (side.Size == info.Size() && side.ModTime.Equal(info.ModTime()))
+}
+
+// bidirectionalEntry is an entry present on both sides after the previous run
+type bidirectionalEntry struct {
+	A bidirectionalSide `json:"a"`
+	B bidirectionalSide `json:"b"`
+}
+
+// bidirectionalState remembers the entries synchronized by the previous runs, keyed by relative path.
+// Without a path it starts empty and isn't saved.
+type bidirectionalState struct {
+	path    string
+	Version int                           `json:"version"`
+	A       string                        `json:"a"`
+	B       string                        `json:"b"`
+	Entries map[string]bidirectionalEntry `json:"entries"`
+	dirty   bool
+}
+
+// loadBidirectionalState reads the state file at path, a missing file or one of other roots gives an empty state
+func loadBidirectionalState(path, a, b string) (*bidirectionalState, error) {
+	state := &bidirectionalState{path: path, Version: bidirectionalStateVersion, A: a, B: b, Entries: map[string]bidirectionalEntry{}}
+	if path == "" {
+		return state, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	saved := &bidirectionalState{}
+	if err := json.Unmarshal(data, saved); err != nil {
+		return nil, fmt.Errorf("invalid sync state file %s: %w", path, err)
+	}
+	// A state of other roots or of another format version can't be trusted, start over
+	if saved.Version != bidirectionalStateVersion || saved.A != a || saved.B != b || saved.Entries == nil {
+		state.dirty = true
+		return state, nil
+	}
+	state.Entries = saved.Entries
+	return state, nil
+}
+
+// save writes the state file if it changed
+func (state *bidirectionalState) save() error {
+	if state.path == "" || !state.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(state.path), 0755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(state.path, data, 0644); err != nil {
+		return err
+	}
+	state.dirty = false
+	return nil
+}
@@ -0,0 +1,341 @@
+package ufs
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+/*
+Toml-files.go contains functions to read and write TOML files into Go values, like Json-files.go does
+for JSON. The files are parsed by github.com/BurntSushi/toml, which implements TOML 1.0:
+
+	title = "Example"
+
+	[server]
+	port = 8080
+	hosts = ["a.example.com", "b.example.com"]
+
+	[[users]]
+	name = "Ada"
+	admin = true
+
+Malformed documents are refused with the error of the parser. Dates and times are read as strings
+in RFC 3339 format, which time.Time fields decode. Infinities and NaN, which JSON can't hold, are refused.
+The files are written in the order of the fields, the values of a table first, then its tables and
+arrays of tables.
+
+The documents are converted through encoding/json: Go values are mapped with their json struct tags,
+like with ReadJSONFile and WriteJSONFile. TOML has no null, so null values (nil pointers, maps and
+slices) are left out when writing.
+
+Functions:
+- ReadTOMLFile: Reads a TOML file into a Go value.
+- WriteTOMLFile: Atomically writes a Go value to a TOML file.
+*/
+
+// ReadTOMLFile reads a TOML file and decodes it into v, mapping the fields of structs with their
+// json tags, see Toml-files.go.
+//
+// Parameters:
+//   - path: The absolute or relative path to the TOML file
+//   - v: A pointer to the value to fill, e.g. a pointer to a struct or a map
+//
+// Returns:
+//   - error: An error if the file couldn't be read, isn't valid TOML or doesn't fit v, nil otherwise
+//
+// Example:
+//
+//	type Config struct {
+//	    Title  string `json:"title"`
+//	    Server struct {
+//	        Port int `json:"port"`
+//	    } `json:"server"`
+//	}
+//	var config Config
+//	if err := ufs.ReadTOMLFile("config.toml", &config); err != nil {
+//	    fmt.Printf("Error reading config: %v\n", err)
+//	    return
+//	}
+func (ufs *UFS) ReadTOMLFile(path string, v any) (err error) {
+	defer ufs.recoverPanic("ReadTOMLFile", &err)
+
+	data, err := ufs.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	document, err := parseTOML(string(data))
+	if err != nil {
+		return fmt.Errorf("ReadTOMLFile: invalid TOML in %s: %w", path, err)
+	}
+	if err := decodeThroughJSON(document, v); err != nil {
+		return fmt.Errorf("ReadTOMLFile: can't decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteTOMLFile encodes v as TOML and writes it atomically to a file, creating it if it doesn't exist
+// or replacing it if it does. v must encode to a JSON object, e.g. a struct or a map: the fields
+// holding values are written first, then the tables and arrays of tables, in the order of the fields.
+// This function will create any parent directories if they don't exist.
+//
+// Parameters:
+//   - path: The absolute or relative path to the TOML file
+//   - v: The value to encode, like json.Marshal
+//
+// Returns:
+//   - error: An error if v can't be encoded as TOML or the file couldn't be written, nil otherwise
+//
+// Example:
+//
+//	config.Server.Port = 9090
+//	if err := ufs.WriteTOMLFile("config.toml", config); err != nil {
+//	    fmt.Printf("Error saving config: %v\n", err)
+//	}
+func (ufs *UFS) WriteTOMLFile(path string, v any) (err error) {
+	defer ufs.recoverPanic("WriteTOMLFile", &err)
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ufs.wrapError(err, "WriteTOMLFile")
+	}
+	document, err := decodeOrderedJSON(data)
+	if err != nil {
+		return ufs.wrapError(err, "WriteTOMLFile")
+	}
+	table, ok := document.([]jsonField)
+	if !ok {
+		return fmt.Errorf("WriteTOMLFile: a TOML document is a table, %T doesn't encode to one", v)
+	}
+
+	var out strings.Builder
+	if err := writeTOMLTable(&out, nil, table); err != nil {
+		return ufs.wrapError(err, "WriteTOMLFile")
+	}
+	return ufs.WriteFileAtomic(path, []byte(out.String()))
+}
+
+// parseTOML parses a TOML document into maps, slices, strings, numbers and bools
+func parseTOML(data string) (any, error) {
+	document := map[string]any{}
+	if _, err := toml.Decode(data, &document); err != nil {
+		return nil, err
+	}
+	return plainTOMLValue(document)
+}
+
+// plainTOMLValue converts a value decoded by the toml package to a value encoding/json can marshal:
+// dates and times become RFC 3339 strings, infinite and NaN floats are refused
+func plainTOMLValue(value any) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			plain, err := plainTOMLValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			v[key] = plain
+		}
+		return v, nil
+	case []map[string]any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			plain, err := plainTOMLValue(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = plain
+		}
+		return items, nil
+	case []any:
+		for i, item := range v {
+			plain, err := plainTOMLValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = plain
+		}
+		return v, nil
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil, fmt.Errorf("%v can't be represented", v)
+		}
+		return v, nil
+	case time.Time:
+		// Local dates and times have no offset, the toml package marks them with the name of their location
+		switch v.Location().String() {
+		case "datetime-local":
+			return v.Format("2006-01-02T15:04:05.999999999"), nil
+		case "date-local":
+			return v.Format("2006-01-02"), nil
+		case "time-local":
+			return v.Format("15:04:05.999999999"), nil
+		}
+		return v.Format(time.RFC3339Nano), nil
+	default:
+		return v, nil
+	}
+}
+
+// isBareTOMLKeyChar reports whether c can be part of a bare key
+func isBareTOMLKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// writeTOMLTable writes a table of a document decoded by decodeOrderedJSON, path being its name:
+// its values first, then its tables and arrays of tables. Null values are left out.
+func writeTOMLTable(out *strings.Builder, path []string, table []jsonField) error {
+	for _, field := range table {
+		if field.value == nil || isTOMLTable(field.value) || isTOMLTableArray(field.value) {
+			continue
+		}
+		value, err := formatTOMLValue(field.value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", strings.Join(append(path, field.key), "."), err)
+		}
+		out.WriteString(formatTOMLKey(field.key) + " = " + value + "\n")
+	}
+
+	for _, field := range table {
+		name := append(append([]string(nil), path...), field.key)
+		header := make([]string, len(name))
+		for i, key := range name {
+			header[i] = formatTOMLKey(key)
+		}
+
+		switch {
+		case isTOMLTable(field.value):
+			if out.Len() > 0 {
+				out.WriteByte('\n')
+			}
+			out.WriteString("[" + strings.Join(header, ".") + "]\n")
+			if err := writeTOMLTable(out, name, field.value.([]jsonField)); err != nil {
+				return err
+			}
+		case isTOMLTableArray(field.value):
+			for _, item := range field.value.([]any) {
+				if out.Len() > 0 {
+					out.WriteByte('\n')
+				}
+				out.WriteString("[[" + strings.Join(header, ".") + "]]\n")
+				if err := writeTOMLTable(out, name, item.([]jsonField)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isTOMLTable reports whether a value is written as a table
+func isTOMLTable(value any) bool {
+	_, ok := value.([]jsonField)
+	return ok
+}
+
+// isTOMLTableArray reports whether a value is written as an array of tables: a non-empty array of objects
+func isTOMLTableArray(value any) bool {
+	items, ok := value.([]any)
+	if !ok || len(items) == 0 {
+		return false
+	}
+	for _, item := range items {
+		if !isTOMLTable(item) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatTOMLValue formats a value written on the line of its key, tables becoming inline tables
+func formatTOMLValue(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", fmt.Errorf("TOML has no null value")
+	case bool:
+		return strconv.FormatBool(v), nil
+	case json.Number:
+		return v.String(), nil
+	case string:
+		return formatTOMLString(v), nil
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			formatted, err := formatTOMLValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = formatted
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case []jsonField:
+		var fields []string
+		for _, field := range v {
+			if field.value == nil {
+				continue
+			}
+			formatted, err := formatTOMLValue(field.value)
+			if err != nil {
+				return "", err
+			}
+			fields = append(fields, formatTOMLKey(field.key)+" = "+formatted)
+		}
+		if len(fields) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(fields, ", ") + " }", nil
+	default:
+		return "", fmt.Errorf("unexpected value %v", v)
+	}
+}
+
+// formatTOMLKey writes a key bare when it can be, quoted otherwise
+func formatTOMLKey(key string) string {
+	if key == "" {
+		return `""`
+	}
+	for i := 0; i < len(key); i++ {
+		if !isBareTOMLKeyChar(key[i]) {
+			return formatTOMLString(key)
+		}
+	}
+	return key
+}
+
+// formatTOMLString writes a basic string, escaping the quotes, backslashes and control characters
+func formatTOMLString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < ' ' || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,52 @@
+package ufs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadTOMLFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    any
+		wantErr bool
+	}{
+		{name: "tables", data: "title = \"x\"\n[server]\nport = 0xff\n", want: map[string]any{"title": "x", "server": map[string]any{"port": 255.0}}},
+		{name: "arrays of tables", data: "[[users]]\nname = \"Ada\"\n[[users]]\nname = \"Bob\"\n", want: map[string]any{"users": []any{map[string]any{"name": "Ada"}, map[string]any{"name": "Bob"}}}},
+		{name: "dates and times", data: "d = 1979-05-27\nt = 07:32:00\ndt = 1979-05-27T07:32:00\no = 1979-05-27T07:32:00-08:00\n", want: map[string]any{"d": "1979-05-27", "t": "07:32:00", "dt": "1979-05-27T07:32:00", "o": "1979-05-27T07:32:00-08:00"}},
+		{name: "empty file", data: "", want: map[string]any{}},
+		{name: "chained assignment", data: "a = b = c\n", wantErr: true},
+		{name: "bare string", data: "a = b\n", wantErr: true},
+		{name: "unclosed array", data: "a = [1, 2\n", wantErr: true},
+		{name: "duplicate key", data: "a = 1\na = 2\n", wantErr: true},
+		{name: "table defined twice", data: "[a]\n[a]\n", wantErr: true},
+		{name: "infinity", data: "a = inf\n", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(path, []byte(test.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			var got any
+			err := NewUfs(NewOptions()).ReadTOMLFile(path, &got)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("ReadTOMLFile returned %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ReadTOMLFile returned %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,358 @@
+package ufs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+/*
+Transaction.go contains transactions: steps queued, then executed all or nothing, e.g. to deploy
+a release or scaffold a project without leaving a half-done tree behind when a step fails.
+
+	tx := ufs.NewTransaction()
+	tx.CreateDirectory("/srv/app/releases/1.4.2")
+	tx.CopyFile("build/app", "/srv/app/releases/1.4.2/app")
+	tx.MoveFile("/srv/app/current.conf", "/srv/app/previous.conf")
+	tx.CopyFile("build/app.conf", "/srv/app/current.conf")
+	tx.DeleteFile("/srv/app/maintenance.flag")
+	if err := tx.Commit(); err != nil {
+	    fmt.Println("Deployment failed, nothing was changed:", err)
+	}
+
+Commit executes the steps in order. When a step fails, the steps already executed are undone in
+reverse order and Commit returns a *TransactionError. To be undone:
+- Files overwritten or deleted are first moved aside, next to them, under a hidden backup name
+  (".<name>.ufs-tx-<id>-<n>", unique to each step, so a path written twice keeps both backups), and
+  moved back on rollback. The backups are removed once Commit succeeded.
+- Directories created, for the steps or as parents of their destinations, are removed.
+- Files moved are moved back, copies are removed.
+
+The rollback is done in memory: a process killed during Commit leaves the executed steps and the
+backups in place. In dry-run mode (see Dry-run.go), Commit records the steps instead of executing them.
+
+Functions:
+- NewTransaction: Creates an empty transaction
+- Transaction.CreateDirectory, CopyFile, MoveFile, DeleteFile: Queue a step
+- Transaction.Commit: Executes the steps, all or nothing
+*/
+
+// Transaction is a list of steps executed all or nothing by Commit, see NewTransaction.
+// A Transaction is not safe for concurrent use and can be committed once.
+type Transaction struct {
+	ufs       *UFS
+	steps     []transactionStep
+	committed bool
+}
+
+// transactionStep is a step queued in a transaction
+type transactionStep struct {
+	operation string // Name of the queuing method, e.g. "CopyFile"
+	path      string // Path created, written or deleted
+	source    string // Source of copies and moves
+}
+
+// NewTransaction creates an empty transaction, steps are queued with its methods and executed by Commit.
+//
+// Returns:
+//   - *Transaction: The transaction
+//
+// Example:
+//
+//	tx := ufs.NewTransaction()
+//	tx.CreateDirectory("myapp/cmd").CopyFile("templates/main.go", "myapp/cmd/main.go")
+//	if err := tx.Commit(); err != nil {
+//	    fmt.Printf("Scaffolding failed: %v\n", err)
+//	}
+func (ufs *UFS) NewTransaction() *Transaction {
+	return &Transaction{ufs: ufs}
+}
+
+// CreateDirectory queues the creation of a directory and its missing parents.
+// The step does nothing if the directory exists.
+//
+// Parameters:
+//   - path: The absolute or relative path to the directory to create
+//
+// Returns:
+//   - *Transaction: The transaction, to chain the steps
+//
+// Example:
+//
+//	tx.CreateDirectory("/srv/app/releases/1.4.2")
+func (tx *Transaction) CreateDirectory(path string) *Transaction {
+	tx.steps = append(tx.steps, transactionStep{operation: "CreateDirectory", path: path})
+	return tx
+}
+
+// CopyFile queues the copy of a file, with its permissions. The destination is overwritten
+// if it exists, and its missing parent directories are created.
+//
+// Parameters:
+//   - src: The absolute or relative path to the file to copy
+//   - dst: The absolute or relative path to the copy
+//
+// Returns:
+//   - *Transaction: The transaction, to chain the steps
+//
+// Example:
+//
+//	tx.CopyFile("build/app", "/srv/app/releases/1.4.2/app")
+func (tx *Transaction) CopyFile(src, dst string) *Transaction {
+	tx.steps = append(tx.steps, transactionStep{operation: "CopyFile", path: dst, source: src})
+	return tx
+}
+
+// MoveFile queues the move of a file. The destination is overwritten if it exists,
+// and its missing parent directories are created.
+//
+// Parameters:
+//   - src: The absolute or relative path to the file to move
+//   - dst: The absolute or relative path where the file is moved to
+//
+// Returns:
+//   - *Transaction: The transaction, to chain the steps
+//
+// Example:
+//
+//	tx.MoveFile("/srv/app/current.conf", "/srv/app/previous.conf")
+func (tx *Transaction) MoveFile(src, dst string) *Transaction {
+	tx.steps = append(tx.steps, transactionStep{operation: "MoveFile", path: dst, source: src})
+	return tx
+}
+
+// DeleteFile queues the deletion of a file.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file to delete
+//
+// Returns:
+//   - *Transaction: The transaction, to chain the steps
+//
+// Example:
+//
+//	tx.DeleteFile("/srv/app/maintenance.flag")
+func (tx *Transaction) DeleteFile(path string) *Transaction {
+	tx.steps = append(tx.steps, transactionStep{operation: "DeleteFile", path: path})
+	return tx
+}
+
+// Commit executes the steps in order. If a step fails, the executed steps are rolled back and
+// the returned *TransactionError tells which step failed and whether the rollback completed.
+//
+// Returns:
+//   - error: A *TransactionError if a step failed, nil if all the steps were executed
+//
+// Example:
+//
+//	err := tx.Commit()
+//	var txErr *ufs.TransactionError
+//	if errors.As(err, &txErr) && len(txErr.RollbackErrors) > 0 {
+//	    fmt.Println("Rollback incomplete, check:", txErr.RollbackErrors)
+//	}
+func (tx *Transaction) Commit() (err error) {
+	defer tx.ufs.recoverPanic("Commit", &err)
+
+	if tx.committed {
+		return fmt.Errorf("Commit: transaction already committed")
+	}
+	tx.committed = true
+
+	if tx.ufs.IsDryRun() {
+		for _, step := range tx.steps {
+			tx.ufs.dryRun("Transaction", step.dryRunAction(), step.path, step.source)
+		}
+		return nil
+	}
+
+	id, err := newBackupID()
+	if err != nil {
+		return tx.ufs.wrapError(err, "Commit")
+	}
+	run := &transactionRun{ufs: tx.ufs, id: id}
+
+	for i, step := range tx.steps {
+		if err := run.execute(step); err != nil {
+			return &TransactionError{Step: i, Operation: step.operation, Path: step.path, Err: err, RollbackErrors: run.rollback()}
+		}
+	}
+
+	// The backups aren't needed anymore, a leftover is harmless
+	for _, backup := range run.backups {
+		os.Remove(backup)
+	}
+	return nil
+}
+
+// dryRunAction returns the change of the step recorded in dry-run mode
+func (step transactionStep) dryRunAction() string {
+	switch step.operation {
+	case "CreateDirectory":
+		return DryRunMkdir
+	case "CopyFile":
+		return DryRunCopy
+	case "MoveFile":
+		return DryRunMove
+	}
+	return DryRunRemove
+}
+
+// transactionRun holds the execution of a transaction by Commit
+type transactionRun struct {
+	ufs     *UFS
+	id      string         // Part of the backup names, unique to the run
+	count   int            // Backups made so far, numbering the backup names within the run
+	undo    []func() error // Undoes the changes made so far, in order
+	backups []string       // Backups to remove on success
+}
+
+// execute runs a step. The undo of each change is registered as soon as it is made,
+// so a step failing halfway is rolled back too.
+func (run *transactionRun) execute(step transactionStep) error {
+	switch step.operation {
+	case "CreateDirectory":
+		return run.mkdirAll(step.path)
+
+	case "CopyFile":
+		if !run.ufs.IsFile(step.source) {
+			return run.ufs.misuseError("Commit", "source is not a file", step.source)
+		}
+		if err := run.prepareDestination(step.path); err != nil {
+			return err
+		}
+		run.undo = append(run.undo, func() error {
+			return removeIfExists(step.path)
+		})
+		return run.ufs.CopyFileWithPermissions(step.source, step.path)
+
+	case "MoveFile":
+		if !run.ufs.IsFile(step.source) {
+			return run.ufs.misuseError("Commit", "source is not a file", step.source)
+		}
+		if err := run.prepareDestination(step.path); err != nil {
+			return err
+		}
+		run.undo = append(run.undo, func() error {
+			// The source is still there if the copy fallback failed, the destination is a partial copy
+			if _, err := os.Lstat(step.source); err == nil {
+				return removeIfExists(step.path)
+			}
+			return run.move(step.path, step.source)
+		})
+		return run.move(step.source, step.path)
+
+	case "DeleteFile":
+		if !run.ufs.IsFile(step.path) {
+			return run.ufs.misuseError("Commit", "path is not a file", step.path)
+		}
+		_, err := run.moveAside(step.path)
+		return err
+	}
+	return fmt.Errorf("Commit: unknown step %s", step.operation)
+}
+
+// prepareDestination creates the missing parents of a file about to be written and moves
+// the file aside if it exists. The caller registers the undo of the write, run before the restoration.
+func (run *transactionRun) prepareDestination(path string) error {
+	if info, err := os.Lstat(path); err == nil && info.IsDir() {
+		return run.ufs.misuseError("Commit", "destination is a directory", path)
+	}
+	if err := run.mkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+	_, err := run.moveAside(path)
+	return err
+}
+
+// moveAside renames an existing file to a backup name next to it and registers its restoration.
+// It returns the backup path, "" if the file doesn't exist.
+func (run *transactionRun) moveAside(path string) (string, error) {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return "", nil
+	}
+	run.count++
+	backup := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.ufs-tx-%s-%d", filepath.Base(path), run.id, run.count))
+	if err := os.Rename(path, backup); err != nil {
+		return "", err
+	}
+	run.backups = append(run.backups, backup)
+	run.undo = append(run.undo, func() error {
+		// Never overwrite what couldn't be undone, e.g. a file that couldn't be moved back
+		if _, err := os.Lstat(path); err == nil {
+			return fmt.Errorf("can't restore %s from %s, the path is in use", path, backup)
+		}
+		return os.Rename(backup, path)
+	})
+	return backup, nil
+}
+
+// mkdirAll creates a directory and its missing parents, and registers their removal
+func (run *transactionRun) mkdirAll(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	// Missing directories, from the deepest
+	var missing []string
+	for current := dir; ; current = filepath.Dir(current) {
+		info, err := os.Stat(current)
+		if err == nil {
+			if !info.IsDir() {
+				return run.ufs.misuseError("Commit", "path is not a directory", current)
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		missing = append(missing, current)
+		if filepath.Dir(current) == current {
+			break
+		}
+	}
+
+	// Created from the shallowest, removed from the deepest
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := os.Mkdir(missing[i], 0755); err != nil {
+			return err
+		}
+		created := missing[i]
+		run.undo = append(run.undo, func() error {
+			return os.Remove(created)
+		})
+	}
+	return nil
+}
+
+// move renames a file, or copies it with its permissions and removes it across file systems
+func (run *transactionRun) move(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := run.ufs.CopyFileWithPermissions(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// rollback undoes the changes made, from the last one, and returns the errors met.
+// It goes on after an error, to restore as much as possible.
+func (run *transactionRun) rollback() []error {
+	var errs []error
+	for i := len(run.undo) - 1; i >= 0; i-- {
+		if err := run.undo[i](); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	run.undo = nil
+	return errs
+}
+
+// removeIfExists removes a file, a missing file is not an error
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package ufs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTransactionFiles creates the files of a test, by path relative to dir
+func writeTransactionFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// assertTransactionTree checks that dir holds exactly the given files, backups included
+func assertTransactionTree(t *testing.T, dir string, want map[string]string) {
+	t.Helper()
+	got := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		got[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("tree is %v, want %v", got, want)
+	}
+	for name, content := range want {
+		if got[name] != content {
+			t.Fatalf("tree is %v, want %v", got, want)
+		}
+	}
+}
+
+func TestTransactionCommit(t *testing.T) {
+	dir := t.TempDir()
+	writeTransactionFiles(t, dir, map[string]string{"a": "a", "b": "b", "x": "x", "old": "old"})
+
+	ufs := NewUfs(NewOptions())
+	err := ufs.NewTransaction().
+		CreateDirectory(filepath.Join(dir, "new/sub")).
+		CopyFile(filepath.Join(dir, "a"), filepath.Join(dir, "x")).
+		CopyFile(filepath.Join(dir, "b"), filepath.Join(dir, "x")).
+		MoveFile(filepath.Join(dir, "old"), filepath.Join(dir, "new/old")).
+		Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The backups are removed once committed
+	assertTransactionTree(t, dir, map[string]string{"a": "a", "b": "b", "x": "b", "new/old": "old"})
+}
+
+func TestTransactionRollback(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		steps func(tx *Transaction, dir string) *Transaction
+		step  int
+	}{
+		{
+			name:  "path written twice",
+			files: map[string]string{"a": "a", "b": "b", "x": "x"},
+			steps: func(tx *Transaction, dir string) *Transaction {
+				return tx.CopyFile(filepath.Join(dir, "a"), filepath.Join(dir, "x")).
+					CopyFile(filepath.Join(dir, "b"), filepath.Join(dir, "x")).
+					DeleteFile(filepath.Join(dir, "missing"))
+			},
+			step: 2,
+		},
+		{
+			name:  "path moved over then deleted",
+			files: map[string]string{"a": "a", "x": "x"},
+			steps: func(tx *Transaction, dir string) *Transaction {
+				return tx.MoveFile(filepath.Join(dir, "a"), filepath.Join(dir, "x")).
+					DeleteFile(filepath.Join(dir, "x")).
+					CopyFile(filepath.Join(dir, "missing"), filepath.Join(dir, "y"))
+			},
+			step: 2,
+		},
+		{
+			name:  "directories and copies",
+			files: map[string]string{"a": "a"},
+			steps: func(tx *Transaction, dir string) *Transaction {
+				return tx.CreateDirectory(filepath.Join(dir, "new/sub")).
+					CopyFile(filepath.Join(dir, "a"), filepath.Join(dir, "other/deep/a")).
+					MoveFile(filepath.Join(dir, "a"), filepath.Join(dir, "new/a")).
+					DeleteFile(filepath.Join(dir, "new"))
+			},
+			step: 3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTransactionFiles(t, dir, test.files)
+
+			ufs := NewUfs(NewOptions())
+			err := test.steps(ufs.NewTransaction(), dir).Commit()
+
+			var txErr *TransactionError
+			if !errors.As(err, &txErr) {
+				t.Fatalf("Commit returned %v, want a *TransactionError", err)
+			}
+			if txErr.Step != test.step {
+				t.Errorf("failed step is %d, want %d", txErr.Step, test.step)
+			}
+			if len(txErr.RollbackErrors) > 0 {
+				t.Fatalf("rollback incomplete: %v", txErr.RollbackErrors)
+			}
+
+			// Nothing changed, no backup left and no directory created
+			assertTransactionTree(t, dir, test.files)
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(test.files) {
+				t.Errorf("%d entries left in the directory, want %d", len(entries), len(test.files))
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package ufs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*
+Transfer-progress.go contains copy and move functions reporting their progress, with an optional
+bandwidth limit, for GUI and CLI tools showing transfers, or background jobs that shouldn't saturate
+a disk or a network share.
+
+Progress is reported after every block of data written (64 KiB), with the bytes transferred so far,
+the total to transfer and the file being copied. Files cloned by the file system (see File-clone.go)
+and directories moved with a single rename are reported at once, and don't count against the limit.
+
+Functions:
+- CopyFileWithProgress: Copies a file, reporting progress and limiting the transfer rate.
+- MoveDirectoryWithProgress: Moves a directory, reporting progress and limiting the transfer rate.
+*/
+
+// ProgressFunc receives the progress of a transfer: the bytes transferred so far, the total bytes to
+// transfer, and the path of the file being transferred.
+type ProgressFunc func(bytesDone, bytesTotal int64, currentPath string)
+
+// TransferOptions controls the rate of CopyFileWithProgress and MoveDirectoryWithProgress.
+// The zero value doesn't limit the transfer.
+type TransferOptions struct {
+	// BytesPerSecond is the maximum average transfer rate, 0 doesn't limit it
+	BytesPerSecond int64
+}
+
+// CopyFileWithProgress copies a file like CopyFileWithPermissions, calling progress as the data is written
+// and pacing the copy so it doesn't exceed opts.BytesPerSecond.
+// If the destination file already exists, it will be overwritten.
+// This function will create any parent directories for the destination if they don't exist.
+//
+// Parameters:
+//   - src: The absolute or relative path to the source file
+//   - dst: The absolute or relative path to the destination file
+//   - progress: Called after every block written, nil disables progress reports
+//   - opts: The rate limit, nil doesn't limit the copy
+//
+// Returns:
+//   - error: An error if the file couldn't be copied, nil otherwise
+//
+// Example:
+//
+//	err := ufs.CopyFileWithProgress("/media/usb/video.mkv", "/data/video.mkv", func(done, total int64, path string) {
+//	    fmt.Printf("\r%s: %d%%", filepath.Base(path), done*100/max(total, 1))
+//	}, &ufs.TransferOptions{BytesPerSecond: 20 << 20}) // 20 MiB/s
+//	if err != nil {
+//	    fmt.Printf("\nError copying file: %v\n", err)
+//	}
+func (ufs *UFS) CopyFileWithProgress(src, dst string, progress ProgressFunc, opts *TransferOptions) (err error) {
+	defer ufs.recoverPanic("CopyFileWithProgress", &err)
+
+	if err := ufs.requireOS("CopyFileWithProgress"); err != nil {
+		return err
+	}
+	defer ufs.applyIOPriority()()
+
+	info, err := os.Stat(src)
+	if err != nil || !info.Mode().IsRegular() {
+		return ufs.misuseError("CopyFileWithProgress", "source is not a file", src)
+	}
+
+	transfer := newTransfer(progress, opts, info.Size())
+	if err := ufs.copyFileTransfer(src, dst, info, transfer); err != nil {
+		return ufs.wrapError(err, "CopyFileWithProgress")
+	}
+	return nil
+}
+
+// MoveDirectoryWithProgress moves a directory and all its contents, calling progress as the data is
+// written and pacing the copy so it doesn't exceed opts.BytesPerSecond.
+// The directory is renamed when possible. Otherwise (other file system, or dst already exists and
+// the trees are merged) it is copied like CopyDirectory, keeping modes, times and symbolic links,
+// hidden entries included, then the source is deleted. The source is kept if anything couldn't be copied.
+// This function will create any parent directories for the destination if they don't exist.
+//
+// Parameters:
+//   - src: The absolute or relative path to the source directory
+//   - dst: The absolute or relative path where the directory should be moved to
+//   - progress: Called after every block written, nil disables progress reports
+//   - opts: The rate limit, nil doesn't limit the move
+//
+// Returns:
+//   - error: An error if the directory couldn't be moved, nil otherwise
+//
+// Example:
+//
+//	err := ufs.MoveDirectoryWithProgress("/data/projects", "/mnt/nas/projects", func(done, total int64, path string) {
+//	    bar.Set(done, total)
+//	    label.SetText(path)
+//	}, &ufs.TransferOptions{BytesPerSecond: 50 << 20})
+func (ufs *UFS) MoveDirectoryWithProgress(src, dst string, progress ProgressFunc, opts *TransferOptions) (err error) {
+	defer ufs.recoverPanic("MoveDirectoryWithProgress", &err)
+
+	if err := ufs.requireOS("MoveDirectoryWithProgress"); err != nil {
+		return err
+	}
+	defer ufs.applyIOPriority()()
+
+	src, err = filepath.Abs(src)
+	if err != nil {
+		return ufs.wrapError(err, "MoveDirectoryWithProgress")
+	}
+	dst, err = filepath.Abs(dst)
+	if err != nil {
+		return ufs.wrapError(err, "MoveDirectoryWithProgress")
+	}
+	info, err := os.Stat(src)
+	if err != nil || !info.IsDir() {
+		return ufs.misuseError("MoveDirectoryWithProgress", "source path is not a directory", src)
+	}
+	if dst == src || strings.HasPrefix(dst, src+string(filepath.Separator)) {
+		return fmt.Errorf("MoveDirectoryWithProgress: can't move %s inside itself", src)
+	}
+	if ufs.IsFile(dst) {
+		return fmt.Errorf("MoveDirectoryWithProgress: destination exists and is a file: %s", dst)
+	}
+	if ufs.dryRun("MoveDirectoryWithProgress", DryRunMove, dst, src) {
+		return nil
+	}
+
+	// The total is needed for the first report, even when the move is a rename
+	var total int64
+	err = filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return ufs.wrapError(err, "MoveDirectoryWithProgress")
+	}
+	transfer := newTransfer(progress, opts, total)
+
+	if !ufs.PathExists(dst) {
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return ufs.wrapError(err, "MoveDirectoryWithProgress")
+		}
+		if os.Rename(src, dst) == nil {
+			transfer.skip(total, dst)
+			return nil
+		}
+	}
+
+	copier := &directoryCopier{
+		ufs:           ufs,
+		opts:          &CopyDirectoryOptions{StopOnError: true},
+		src:           src,
+		dst:           dst,
+		report:        &CopyReport{},
+		includeHidden: true,
+		transfer:      transfer,
+	}
+	if err := copier.copyDirectory(src, dst, info, nil); err != nil {
+		return ufs.wrapError(err, "MoveDirectoryWithProgress")
+	}
+	if len(copier.report.Skipped) > 0 {
+		return fmt.Errorf("MoveDirectoryWithProgress: special files can't be moved, %s was kept: %s",
+			src, strings.Join(copier.report.Skipped, ", "))
+	}
+
+	if err := os.RemoveAll(src); err != nil {
+		return ufs.wrapError(err, "MoveDirectoryWithProgress")
+	}
+	return nil
+}
+
+// transfer counts the bytes of a copy or move, reports them and paces the copy
+type transfer struct {
+	progress ProgressFunc
+	rate     int64 // Bytes per second, 0 doesn't limit
+	start    time.Time
+	done     int64 // Bytes reported
+	paced    int64 // Bytes counted against the rate, cloned and renamed files are not
+	total    int64
+}
+
+func newTransfer(progress ProgressFunc, opts *TransferOptions, total int64) *transfer {
+	t := &transfer{progress: progress, start: time.Now(), total: total}
+	if opts != nil && opts.BytesPerSecond > 0 {
+		t.rate = opts.BytesPerSecond
+	}
+	return t
+}
+
+// copy copies src to dst, reporting and pacing every block
+func (t *transfer) copy(dst io.Writer, src io.Reader, path string) error {
+	buf := make([]byte, streamBufferSize)
+	// Small blocks under low rates, so the pace is regular
+	if t.rate > 0 && t.rate < int64(len(buf)) {
+		buf = buf[:max(t.rate/4, 1)]
+	}
+
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return err
+			}
+			t.advance(int64(n), path)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// advance counts n bytes written to path, waiting as long as needed to stay under the rate
+func (t *transfer) advance(n int64, path string) {
+	t.done += n
+	t.paced += n
+	if t.progress != nil {
+		t.progress(t.done, t.total, path)
+	}
+	if t.rate > 0 {
+		expected := time.Duration(float64(t.paced) / float64(t.rate) * float64(time.Second))
+		if wait := expected - time.Since(t.start); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+}
+
+// skip counts n bytes transferred without copying them (clone, rename), which are not paced
+func (t *transfer) skip(n int64, path string) {
+	t.done += n
+	if t.progress != nil {
+		t.progress(t.done, t.total, path)
+	}
+}
+
+// copyFileTransfer copies the file src, described by info, to dst with its permissions,
+// counting its bytes in t. The file is cloned when possible, unless Options.DisableReflink is set.
+func (ufs *UFS) copyFileTransfer(src, dst string, info os.FileInfo, t *transfer) error {
+	if err := ufs.checkQuota(dst, info.Size()); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	srcFile, err := ufs.openSequential(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+	defer ufs.recordQuota(dst)
+
+	if file := openedFile(srcFile); !ufs.opts.DisableReflink && file != nil && cloneFile(file, dstFile) == nil {
+		t.skip(info.Size(), src)
+		return nil
+	}
+	return t.copy(dstFile, srcFile, src)
+}
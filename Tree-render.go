@@ -0,0 +1,361 @@
+package ufs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Tree-render.go contains functions drawing a directory tree like the `tree` command, for command line
+tools, logs and the golden files of tests:
+
+	out, _ := ufs.RenderTree("project", &ufs.TreeOptions{MaxDepth: 2, ShowSize: true})
+	fmt.Print(out)
+
+	project
+	├── cmd
+	│   └── main.go [1.2 KiB]
+	├── go.mod [87 B]
+	└── internal
+	    ├── api
+	    └── store
+
+	4 directories, 2 files
+
+The entries of a directory are in the order of Options.ListingOrder, by name by default, so the output
+only depends on the tree. Hidden entries are left out unless TreeOptions.Hidden is set, symbolic links
+are shown with their target and not followed, and the contents of directories that can't be read are
+left out (see Options.OnWalkError).
+
+RenderTreeFS draws a tree of an fs.FS instead, e.g. the content of an archive opened with OpenArchiveFS
+or an embed.FS:
+
+	archive, _ := ufs.OpenArchiveFS("release.zip")
+	defer archive.Close()
+	out, _ := ufs.RenderTreeFS(archive, ".", nil)
+
+Functions:
+- RenderTree: Returns the drawing of a directory tree as a string
+- RenderTreeFS: Returns the drawing of a directory tree of an fs.FS as a string
+- PrintTree: Writes the drawing of a directory tree to TreeOptions.Output, the standard output by default
+*/
+
+// TreeOptions are the settings of RenderTree and PrintTree.
+type TreeOptions struct {
+	// MaxDepth is the deepest level drawn, 1 for the entries directly in the root, 0 is unlimited.
+	// Negative depths are rejected.
+	MaxDepth int
+
+	// ASCII draws the branches with "|--" and "`--" instead of box drawing characters
+	ASCII bool
+
+	// ShowSize adds the size of the files after their name, e.g. "[1.5 MiB]"
+	ShowSize bool
+
+	// DirsOnly draws directories only
+	DirsOnly bool
+
+	// DirsFirst draws the directories before the files of each directory
+	DirsFirst bool
+
+	// Hidden draws hidden entries, which are left out with their contents by default
+	Hidden bool
+
+	// Exclude leaves out the entries matching any of these patterns, and the contents of the directories
+	// matching them, e.g. ".git", "node_modules", see Glob.go
+	Exclude []string
+
+	// NoReport leaves out the "N directories, M files" line at the end
+	NoReport bool
+
+	// Output is where PrintTree writes, os.Stdout by default. RenderTree ignores it.
+	Output io.Writer
+}
+
+// treeBranches are the prefixes of the lines of a tree
+type treeBranches struct {
+	middle, last, vertical, blank string
+}
+
+var (
+	unicodeBranches = treeBranches{middle: "├── ", last: "└── ", vertical: "│   ", blank: "    "}
+	asciiBranches   = treeBranches{middle: "|-- ", last: "`-- ", vertical: "|   ", blank: "    "}
+)
+
+// RenderTree returns the drawing of a directory tree, see Tree-render.go.
+//
+// Parameters:
+//   - root: The absolute or relative path to the directory to draw, shown as given on the first line
+//   - opts: The settings of the drawing, nil draws the whole tree with box drawing characters
+//
+// Returns:
+//   - string: The drawing, every line ending with a newline
+//   - error: An error if root can't be read, a pattern is malformed or Options.OnWalkError aborts
+//
+// Example:
+//
+//	tree, err := ufs.RenderTree("testdata/expected", &ufs.TreeOptions{ASCII: true, ShowSize: true})
+//	if err != nil {
+//	    t.Fatal(err)
+//	}
+//	if tree != golden {
+//	    t.Errorf("unexpected tree:\n%s", tree)
+//	}
+func (ufs *UFS) RenderTree(root string, opts *TreeOptions) (_ string, err error) {
+	defer ufs.recoverPanic("RenderTree", &err)
+
+	var out strings.Builder
+	if err := ufs.renderTree("RenderTree", &out, root, opts, ufs.backendTreeSource()); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// RenderTreeFS returns the drawing of a directory tree of an fs.FS, see Tree-render.go. Symbolic
+// links are shown with their target when fsys has a ReadLink method.
+//
+// Parameters:
+//   - fsys: The file system holding the tree, e.g. an *ArchiveFS or an embed.FS
+//   - root: The slash separated path to the directory to draw in fsys, "." for its root, shown as given
+//     on the first line
+//   - opts: The settings of the drawing, nil draws the whole tree with box drawing characters
+//
+// Returns:
+//   - string: The drawing, every line ending with a newline
+//   - error: An error if root can't be read, a pattern is malformed or Options.OnWalkError aborts
+//
+// Example:
+//
+//	archive, err := ufs.OpenArchiveFS("release.tar.gz")
+//	if err != nil {
+//	    fmt.Printf("Error opening archive: %v\n", err)
+//	    return
+//	}
+//	defer archive.Close()
+//	tree, err := ufs.RenderTreeFS(archive, ".", &ufs.TreeOptions{ShowSize: true})
+//	if err == nil {
+//	    fmt.Print(tree)
+//	}
+func (ufs *UFS) RenderTreeFS(fsys fs.FS, root string, opts *TreeOptions) (_ string, err error) {
+	defer ufs.recoverPanic("RenderTreeFS", &err)
+
+	var out strings.Builder
+	if err := ufs.renderTree("RenderTreeFS", &out, root, opts, ufs.fsTreeSource(fsys)); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// PrintTree writes the drawing of a directory tree to TreeOptions.Output, the standard output by
+// default, see Tree-render.go.
+//
+// Parameters:
+//   - root: The absolute or relative path to the directory to draw, shown as given on the first line
+//   - opts: The settings of the drawing, nil draws the whole tree to the standard output
+//
+// Returns:
+//   - error: An error if root can't be read, a pattern is malformed, Options.OnWalkError aborts or the
+//     output can't be written
+//
+// Example:
+//
+//	err := ufs.PrintTree(".", &ufs.TreeOptions{MaxDepth: 3, DirsFirst: true, Exclude: []string{".git", "node_modules"}})
+func (ufs *UFS) PrintTree(root string, opts *TreeOptions) (err error) {
+	defer ufs.recoverPanic("PrintTree", &err)
+
+	output := io.Writer(os.Stdout)
+	if opts != nil && opts.Output != nil {
+		output = opts.Output
+	}
+	return ufs.renderTree("PrintTree", output, root, opts, ufs.backendTreeSource())
+}
+
+// treeSource is where a tree is read from, the backend or an fs.FS
+type treeSource struct {
+	stat     func(name string) (fs.FileInfo, error)
+	readDir  func(name string) ([]fs.DirEntry, error) // In the order of Options.ListingOrder
+	readLink func(name string) (string, error)
+	join     func(dir, name string) string
+}
+
+// readLinkFS is an fs.FS giving the targets of its symbolic links
+type readLinkFS interface {
+	ReadLink(name string) (string, error)
+}
+
+// backendTreeSource reads a tree from the backend
+func (ufs *UFS) backendTreeSource() treeSource {
+	return treeSource{
+		stat:     ufs.backend().Stat,
+		readDir:  ufs.readDirOrdered,
+		readLink: os.Readlink,
+		join:     func(dir, name string) string { return filepath.Join(dir, name) },
+	}
+}
+
+// fsTreeSource reads a tree from fsys
+func (ufs *UFS) fsTreeSource(fsys fs.FS) treeSource {
+	return treeSource{
+		stat: func(name string) (fs.FileInfo, error) { return fs.Stat(fsys, name) },
+		readDir: func(name string) ([]fs.DirEntry, error) {
+			entries, err := fs.ReadDir(fsys, name)
+			if err != nil {
+				return nil, err
+			}
+			ufs.orderEntries(entries)
+			return entries, nil
+		},
+		readLink: func(name string) (string, error) {
+			if links, ok := fsys.(readLinkFS); ok {
+				return links.ReadLink(name)
+			}
+			return "", errors.ErrUnsupported
+		},
+		join: func(dir, name string) string { return path.Join(dir, name) },
+	}
+}
+
+// renderTree draws the tree of root read from source to out, reporting the errors as those of operation
+func (ufs *UFS) renderTree(operation string, out io.Writer, root string, opts *TreeOptions, source treeSource) error {
+	if opts == nil {
+		opts = &TreeOptions{}
+	}
+	if opts.MaxDepth < 0 {
+		return fmt.Errorf("%s: negative MaxDepth %d", operation, opts.MaxDepth)
+	}
+	exclude, err := compileGlobs(opts.Exclude)
+	if err != nil {
+		return ufs.wrapError(err, operation)
+	}
+	info, err := source.stat(root)
+	if err != nil {
+		return ufs.wrapError(err, operation)
+	}
+	if !info.IsDir() {
+		return ufs.misuseError(operation, "root is not a directory", root)
+	}
+
+	t := &treeRenderer{ufs: ufs, operation: operation, opts: opts, source: source, exclude: exclude, branches: unicodeBranches}
+	if opts.ASCII {
+		t.branches = asciiBranches
+	}
+	t.lines.WriteString(root)
+	t.lines.WriteByte('\n')
+	if err := t.drawDir(root, "", "", 1); err != nil {
+		return ufs.wrapError(err, operation)
+	}
+	if !opts.NoReport {
+		fmt.Fprintf(&t.lines, "\n%d %s", t.dirs, plural(t.dirs, "directory", "directories"))
+		if !opts.DirsOnly {
+			fmt.Fprintf(&t.lines, ", %d %s", t.files, plural(t.files, "file", "files"))
+		}
+		t.lines.WriteByte('\n')
+	}
+
+	_, err = io.WriteString(out, t.lines.String())
+	return ufs.wrapError(err, operation)
+}
+
+// treeRenderer holds the state of a RenderTree
+type treeRenderer struct {
+	ufs       *UFS
+	operation string
+	opts      *TreeOptions
+	source    treeSource
+	exclude   *globPattern
+	branches  treeBranches
+
+	lines       strings.Builder
+	dirs, files int
+}
+
+// drawDir draws the entries of dir, rel being its slash separated path relative to the root and
+// prefix the branches of its ancestors
+func (t *treeRenderer) drawDir(dir, rel, prefix string, depth int) error {
+	entries, err := t.source.readDir(dir)
+	if err != nil {
+		return t.ufs.decideWalkError(dir, err, WalkSkip, t.operation)
+	}
+
+	shown := entries[:0]
+	for _, entry := range entries {
+		if (!t.opts.Hidden && isHiddenEntry(entry)) || t.ufs.skipHidden(entry) {
+			continue
+		}
+		if t.opts.DirsOnly && !entry.IsDir() {
+			continue
+		}
+		if t.exclude.matchName(joinSlash(rel, entry.Name())) {
+			continue
+		}
+		shown = append(shown, entry)
+	}
+	if t.opts.DirsFirst {
+		dirs := make([]fs.DirEntry, 0, len(shown))
+		var files []fs.DirEntry
+		for _, entry := range shown {
+			if entry.IsDir() {
+				dirs = append(dirs, entry)
+			} else {
+				files = append(files, entry)
+			}
+		}
+		shown = append(dirs, files...)
+	}
+
+	for i, entry := range shown {
+		branch, indent := t.branches.middle, t.branches.vertical
+		if i == len(shown)-1 {
+			branch, indent = t.branches.last, t.branches.blank
+		}
+		path := t.source.join(dir, entry.Name())
+
+		t.lines.WriteString(prefix + branch + entry.Name())
+		switch {
+		case entry.Type()&fs.ModeSymlink != 0:
+			if target, err := t.source.readLink(path); err == nil {
+				t.lines.WriteString(" -> " + target)
+			}
+			t.files++
+		case entry.IsDir():
+			t.dirs++
+		default:
+			if t.opts.ShowSize {
+				if info, err := entry.Info(); err == nil {
+					t.lines.WriteString(" [" + formatSize(info.Size()) + "]")
+				}
+			}
+			t.files++
+		}
+		t.lines.WriteByte('\n')
+
+		if entry.IsDir() && (t.opts.MaxDepth == 0 || depth < t.opts.MaxDepth) {
+			if err := t.drawDir(path, joinSlash(rel, entry.Name()), prefix+indent, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// joinSlash joins a slash separated relative path and a name
+func joinSlash(rel, name string) string {
+	if rel == "" {
+		return name
+	}
+	return rel + "/" + name
+}
+
+// plural returns singular when n is 1, plural otherwise
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
@@ -0,0 +1,320 @@
+package ufs
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+/*
+Vendor-prune.go contains the pruning of vendored dependency trees (vendor/, third_party/, node_modules...):
+their tests, documentation and files for other platforms are removed to shrink a repository or an image.
+
+	report, err := ufs.PruneVendorTree("vendor", &ufs.VendorPruneRules{
+	    Tests:    true,
+	    Docs:     true,
+	    TargetOS: []string{"linux"},
+	    Keep:     []string{"github.com/acme/gen/testdata/**"},
+	})
+	fmt.Printf("%d entries removed, %d bytes reclaimed\n", len(report.Removed), report.ReclaimedSize)
+
+License files (LICENSE, COPYING, NOTICE, AUTHORS, PATENTS...) are never removed, even inside a removed
+directory: the directory is then emptied of everything else and kept. The Keep patterns protect other
+files and directories the same way. Patterns without a slash match names, others match slash separated
+paths relative to the root, where a "**" segment matches any number of directories.
+
+Platform files are recognized by name, like the go tool does: "poll_windows.go", "asm_arm64.s" and
+"zsys_darwin_amd64.go" are removed when their system or architecture isn't targeted. Build constraints
+inside the files are not evaluated. In dry-run mode (see Dry-run.go) the removals are recorded only.
+
+Functions:
+- PruneVendorTree: Removes the tests, documentation and other platform files of a vendored tree.
+*/
+
+// VendorPruneRules selects what PruneVendorTree removes.
+type VendorPruneRules struct {
+	// Tests removes test files (*_test.go, test_*.py, *.test.js, *.spec.ts...) and test directories
+	// (testdata, test, tests, __tests__)
+	Tests bool
+
+	// Docs removes documentation files (*.md, *.rst, CHANGELOG...) and directories (docs, examples, _examples)
+	Docs bool
+
+	// TargetOS lists the operating systems (GOOS values) to keep the files of, e.g. "linux".
+	// Files for the others are removed. Empty keeps the files of every system.
+	TargetOS []string
+
+	// TargetArch lists the architectures (GOARCH values) to keep the files of, e.g. "amd64".
+	// Files for the others are removed. Empty keeps the files of every architecture.
+	TargetArch []string
+
+	// Remove lists patterns of other files and directories to remove, e.g. "*.png" or "**/benchmarks"
+	Remove []string
+
+	// Keep lists patterns of files and directories never removed, checked before the other rules
+	Keep []string
+}
+
+// DefaultVendorPruneRules are the rules used when PruneVendorTree is called with nil rules
+var DefaultVendorPruneRules = VendorPruneRules{Tests: true, Docs: true}
+
+// VendorPruneReport describes what PruneVendorTree removed.
+type VendorPruneReport struct {
+	Root          string   // Absolute path of the pruned tree
+	Removed       []string // Files and directories removed, relative to Root, sorted
+	ReclaimedSize int64    // Total size of the files removed, in bytes
+}
+
+// Names of the test and documentation directories
+var (
+	vendorTestDirs = []string{"testdata", "test", "tests", "__tests__"}
+	vendorDocDirs  = []string{"docs", "examples", "_examples"}
+)
+
+// Patterns of the test and documentation files
+var (
+	vendorTestFiles = []string{"*_test.go", "test_*.py", "*_test.py", "*.test.js", "*.test.ts", "*.spec.js", "*.spec.ts"}
+	vendorDocFiles  = []string{"*.md", "*.markdown", "*.rst", "*.adoc", "CHANGELOG*", "CHANGES*", "HISTORY*"}
+)
+
+// vendorLicensePrefixes are the upper case prefixes of the license files never removed
+var vendorLicensePrefixes = []string{"LICENSE", "LICENCE", "COPYING", "COPYRIGHT", "NOTICE", "AUTHORS", "CONTRIBUTORS", "PATENTS", "UNLICENSE"}
+
+// PruneVendorTree removes the tests, documentation and files for other platforms of a vendored
+// dependency tree, as selected by the rules, and reports the space reclaimed. License files and the
+// paths matching the Keep patterns are never removed. Symbolic links are not followed.
+// Removing continues after an error, the first error is returned with the report.
+//
+// Parameters:
+//   - root: The absolute or relative path to the vendored tree, e.g. "vendor"
+//   - rules: What to remove, nil uses DefaultVendorPruneRules
+//
+// Returns:
+//   - *VendorPruneReport: The entries removed and the space reclaimed
+//   - error: An error if the tree couldn't be scanned or an entry removed, nil otherwise
+//
+// Example:
+//
+//	report, err := ufs.PruneVendorTree("third_party", &ufs.VendorPruneRules{
+//	    Tests:      true,
+//	    TargetOS:   []string{"linux", "darwin"},
+//	    TargetArch: []string{"amd64", "arm64"},
+//	    Remove:     []string{"*.png", "benchmarks"},
+//	})
+//	if err != nil {
+//	    fmt.Printf("Error pruning: %v\n", err)
+//	}
+//	fmt.Printf("Reclaimed %d bytes\n", report.ReclaimedSize)
+func (ufs *UFS) PruneVendorTree(root string, rules *VendorPruneRules) (_ *VendorPruneReport, err error) {
+	defer ufs.recoverPanic("PruneVendorTree", &err)
+
+	if err := ufs.requireOS("PruneVendorTree"); err != nil {
+		return nil, err
+	}
+
+	// Verify root is a directory
+	if !ufs.IsDirectory(root) {
+		return nil, ufs.misuseError("PruneVendorTree", "root path is not a directory", root)
+	}
+	if rules == nil {
+		rules = &DefaultVendorPruneRules
+	}
+
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return nil, ufs.wrapError(err, "PruneVendorTree")
+	}
+
+	var files []string          // Files to remove, relative
+	sizes := map[string]int64{} // Sizes of the files to remove
+	removedDirs := map[string]bool{}
+	var dirs []string           // Directories to remove, relative, in walk order
+	keptIn := map[string]bool{} // Removed directories holding a kept entry
+
+	// removedAncestor returns the closest directory of rel being removed, "" if none
+	removedAncestor := func(rel string) string {
+		for dir := filepath.Dir(rel); dir != "."; dir = filepath.Dir(dir) {
+			if removedDirs[dir] {
+				return dir
+			}
+		}
+		return ""
+	}
+	// keep marks the removed directories holding rel as kept
+	keep := func(rel string) {
+		for dir := removedAncestor(rel); dir != ""; dir = removedAncestor(dir) {
+			keptIn[dir] = true
+		}
+	}
+
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return ufs.decideWalkError(path, err, WalkAbort, "PruneVendorTree")
+		}
+		if path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".git" || matchVendorPatterns(rules.Keep, rel) {
+				keep(rel)
+				return filepath.SkipDir
+			}
+			if removedAncestor(rel) != "" || rules.removesDir(rel, info.Name()) {
+				removedDirs[rel] = true
+				dirs = append(dirs, rel)
+			}
+			return nil
+		}
+
+		if matchVendorPatterns(rules.Keep, rel) || isLicenseFile(info.Name()) {
+			keep(rel)
+			return nil
+		}
+		if removedAncestor(rel) != "" || rules.removesFile(rel, info.Name()) {
+			files = append(files, rel)
+			if info.Mode().IsRegular() {
+				sizes[rel] = info.Size()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, ufs.wrapError(err, "PruneVendorTree")
+	}
+
+	report := &VendorPruneReport{Root: root}
+	var firstErr error
+	var removed []string
+	for _, rel := range files {
+		path := filepath.Join(root, rel)
+		if !ufs.dryRun("PruneVendorTree", DryRunRemove, path, "") {
+			if err := os.Remove(path); err != nil {
+				keep(rel)
+				if firstErr == nil {
+					firstErr = ufs.wrapError(err, "PruneVendorTree")
+				}
+				continue
+			}
+			removed = append(removed, path)
+		}
+		report.Removed = append(report.Removed, rel)
+		report.ReclaimedSize += sizes[rel]
+	}
+	ufs.recordQuota(removed...)
+
+	// Deepest first, so the directories are empty
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rel := dirs[i]
+		if keptIn[rel] {
+			continue
+		}
+		path := filepath.Join(root, rel)
+		if !ufs.dryRun("PruneVendorTree", DryRunRemove, path, "") {
+			if err := os.Remove(path); err != nil {
+				// Something was added to it during the pruning
+				if !ufs.IsDirectoryNotEmpty(err) && firstErr == nil {
+					firstErr = ufs.wrapError(err, "PruneVendorTree")
+				}
+				continue
+			}
+		}
+		report.Removed = append(report.Removed, rel)
+	}
+
+	sort.Strings(report.Removed)
+	return report, firstErr
+}
+
+// removesDir reports whether the rules remove a directory with its contents
+func (rules *VendorPruneRules) removesDir(rel, name string) bool {
+	return (rules.Tests && slices.Contains(vendorTestDirs, name)) ||
+		(rules.Docs && slices.Contains(vendorDocDirs, name)) ||
+		matchVendorPatterns(rules.Remove, rel)
+}
+
+// removesFile reports whether the rules remove a file
+func (rules *VendorPruneRules) removesFile(rel, name string) bool {
+	return (rules.Tests && matchVendorPatterns(vendorTestFiles, name)) ||
+		(rules.Docs && matchVendorPatterns(vendorDocFiles, name)) ||
+		!rules.targetsFile(name) ||
+		matchVendorPatterns(rules.Remove, rel)
+}
+
+// targetsFile reports whether a source file is built for a targeted system and architecture,
+// according to its name, like the go tool does for "name_GOOS_GOARCH.go"
+func (rules *VendorPruneRules) targetsFile(name string) bool {
+	if len(rules.TargetOS) == 0 && len(rules.TargetArch) == 0 {
+		return true
+	}
+	switch filepath.Ext(name) {
+	case ".go", ".s", ".c", ".h", ".cc", ".cpp", ".syso":
+	default:
+		return true
+	}
+
+	// The first element is never a constraint: "linux.go" is built everywhere
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	parts := strings.Split(name, "_")[1:]
+	if n := len(parts); n > 0 && parts[n-1] == "test" {
+		parts = parts[:n-1]
+	}
+
+	goos, goarch := "", ""
+	switch n := len(parts); {
+	case n >= 2 && slices.Contains(knownGOOS, parts[n-2]) && slices.Contains(knownGOARCH, parts[n-1]):
+		goos, goarch = parts[n-2], parts[n-1]
+	case n >= 1 && slices.Contains(knownGOOS, parts[n-1]):
+		goos = parts[n-1]
+	case n >= 1 && slices.Contains(knownGOARCH, parts[n-1]):
+		goarch = parts[n-1]
+	}
+
+	if goos != "" && len(rules.TargetOS) > 0 && !slices.ContainsFunc(rules.TargetOS, func(target string) bool {
+		// Like the go tool: android builds the linux files, illumos the solaris ones and ios the darwin ones
+		return target == goos || (goos == "linux" && target == "android") ||
+			(goos == "solaris" && target == "illumos") || (goos == "darwin" && target == "ios")
+	}) {
+		return false
+	}
+	if goarch != "" && len(rules.TargetArch) > 0 && !slices.Contains(rules.TargetArch, goarch) {
+		return false
+	}
+	return true
+}
+
+// knownGOOS and knownGOARCH are the values the go tool recognizes in file names
+var (
+	knownGOOS = []string{"aix", "android", "darwin", "dragonfly", "freebsd", "hurd", "illumos", "ios", "js",
+		"linux", "nacl", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows", "zos"}
+	knownGOARCH = []string{"386", "amd64", "amd64p32", "arm", "armbe", "arm64", "arm64be", "loong64", "mips",
+		"mipsle", "mips64", "mips64le", "mips64p32", "mips64p32le", "ppc", "ppc64", "ppc64le", "riscv",
+		"riscv64", "s390", "s390x", "sparc", "sparc64", "wasm"}
+)
+
+// isLicenseFile reports whether a file holds license terms, e.g. "LICENSE", "COPYING.txt" or "NOTICE.md"
+func isLicenseFile(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, prefix := range vendorLicensePrefixes {
+		if strings.HasPrefix(upper, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchVendorPatterns reports whether a relative path matches one of the patterns, see matchArchivePattern
+func matchVendorPatterns(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matchArchivePattern(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
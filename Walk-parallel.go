@@ -0,0 +1,211 @@
+package ufs
+
+import (
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+Walk-parallel.go contains WalkParallel, a directory traversal reading several directories at the same
+time. On NVMe drives and network shares, where a single directory read leaves the device mostly idle,
+it walks large trees several times faster than filepath.WalkDir; GetFolderSize uses it.
+
+Every worker keeps the directories it discovers on a private stack and goes depth first through them,
+without locking. When a worker runs out of directories, the others hand it the directories they
+discover next, so the work spreads over the pool without a shared queue every directory goes through.
+
+	var files atomic.Int64
+	err := ufs.WalkParallel("/mnt/share", 16, func(path string, d fs.DirEntry) error {
+	    if !d.IsDir() {
+	        files.Add(1)
+	    }
+	    return nil
+	})
+
+fn is called from several goroutines at once and in no particular order, a directory is only
+guaranteed to be visited before its contents. Returning filepath.SkipDir for a directory skips its
+contents, filepath.SkipAll or any other error stops the walk. Symbolic links are not followed, hidden
+entries are skipped with Options.ExcludeHidden, and directories that can't be read are handled like
+in the other recursive functions (see Options.OnWalkError).
+
+Functions:
+- WalkParallel: Walks a directory tree with several workers
+*/
+
+// WalkParallel calls fn for root and every file and directory under it, reading workers directories
+// at the same time, see Walk-parallel.go.
+//
+// Parameters:
+//   - root: The absolute or relative path to the directory to walk
+//   - workers: The number of directories read at the same time, 0 uses runtime.NumCPU()
+//   - fn: The function called with the path of every entry, from several goroutines at once
+//
+// Returns:
+//   - error: The first error returned by fn, or the error of a path when Options.OnWalkError aborts the walk
+//
+// Example:
+//
+//	var mu sync.Mutex
+//	var logs []string
+//	err := ufs.WalkParallel("/var/log", 8, func(path string, d fs.DirEntry) error {
+//	    if strings.HasSuffix(path, ".log") {
+//	        mu.Lock()
+//	        logs = append(logs, path)
+//	        mu.Unlock()
+//	    }
+//	    return nil
+//	})
+func (ufs *UFS) WalkParallel(root string, workers int, fn func(path string, d fs.DirEntry) error) (err error) {
+	defer ufs.recoverPanic("WalkParallel", &err)
+	return ufs.walkParallel("WalkParallel", root, workers, fn)
+}
+
+// walkParallel is WalkParallel, reporting the errors as those of operation
+func (ufs *UFS) walkParallel(operation, root string, workers int, fn func(path string, d fs.DirEntry) error) error {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	info, err := ufs.backend().Stat(root)
+	if err != nil {
+		return ufs.wrapError(ufs.decideWalkError(root, err, WalkSkip, operation), operation)
+	}
+	if err := fn(root, fs.FileInfoToDirEntry(info)); err != nil || !info.IsDir() {
+		if err == filepath.SkipDir || err == filepath.SkipAll {
+			err = nil
+		}
+		return ufs.wrapError(err, operation)
+	}
+
+	w := &parallelWalker{
+		ufs:       ufs,
+		operation: operation,
+		fn:        fn,
+		handoff:   make(chan string),
+		done:      make(chan struct{}),
+	}
+	w.pending.Store(1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.work()
+		}()
+	}
+	w.handoff <- root
+	wg.Wait()
+
+	if w.err == filepath.SkipAll {
+		return nil
+	}
+	return ufs.wrapError(w.err, operation)
+}
+
+// parallelWalker holds the state shared by the workers of a WalkParallel
+type parallelWalker struct {
+	ufs       *UFS
+	operation string
+	fn        func(path string, d fs.DirEntry) error
+
+	handoff chan string   // Directories given to idle workers, unbuffered
+	done    chan struct{} // Closed when every directory was read
+	pending atomic.Int64  // Directories discovered and not read yet
+	idle    atomic.Int32  // Workers waiting on handoff
+
+	stopped atomic.Bool
+	errOnce sync.Once
+	err     error // Why the walk stopped
+}
+
+// work reads directories until the walk is over
+func (w *parallelWalker) work() {
+	var stack []string
+	for {
+		if len(stack) == 0 {
+			w.idle.Add(1)
+			select {
+			case dir := <-w.handoff:
+				w.idle.Add(-1)
+				stack = append(stack, dir)
+			case <-w.done:
+				return
+			}
+		}
+
+		dir := stack[len(stack)-1]
+		stack = w.readDir(dir, stack[:len(stack)-1])
+		if w.pending.Add(-1) == 0 {
+			close(w.done)
+		}
+	}
+}
+
+// readDir calls fn for the entries of dir and returns stack with the subdirectories to read, minus
+// those handed to idle workers. A panic stops the walk, the directories stacked until then being
+// dropped like the others.
+func (w *parallelWalker) readDir(dir string, stack []string) (next []string) {
+	defer func() {
+		if r := recover(); r != nil {
+			w.stop(&PanicError{Op: w.operation, Value: r, Stack: debug.Stack()})
+			next = stack
+		}
+	}()
+
+	if w.stopped.Load() {
+		return stack
+	}
+	entries, err := w.ufs.backend().ReadDir(dir)
+	if err != nil {
+		if err := w.ufs.decideWalkError(dir, err, WalkSkip, w.operation); err != nil {
+			w.stop(err)
+		}
+		return stack
+	}
+
+	for _, entry := range entries {
+		if w.stopped.Load() {
+			return stack
+		}
+		if w.ufs.skipHidden(entry) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if err := w.fn(path, entry); err != nil {
+			if err == filepath.SkipDir && entry.IsDir() {
+				continue
+			}
+			if err != filepath.SkipDir {
+				w.stop(err)
+			}
+			return stack
+		}
+		if !entry.IsDir() {
+			continue
+		}
+
+		w.pending.Add(1)
+		if w.idle.Load() > 0 {
+			select {
+			case w.handoff <- path:
+				continue
+			default:
+			}
+		}
+		stack = append(stack, path)
+	}
+	return stack
+}
+
+// stop makes the workers drop the remaining directories, err being the result of the walk
+func (w *parallelWalker) stop(err error) {
+	w.errOnce.Do(func() {
+		w.err = err
+		w.stopped.Store(true)
+	})
+}
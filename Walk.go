@@ -0,0 +1,220 @@
+package ufs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+/*
+Walk.go contains Walk, the directory traversal shared by the functions of the library going through
+a tree (Find, Glob, ...), for callers needing more control than filepath.WalkDir gives:
+- a maximum depth
+- following symbolic links to directories, with loops detected instead of walked forever
+- skipping hidden entries and entries matching patterns (see Glob.go), with the contents of skipped directories
+- an error policy: continue past unreadable paths, collect their errors, or stop at the first one
+
+	err := ufs.Walk("/srv", ufs.WalkOptions{
+	    MaxDepth:       3,
+	    FollowSymlinks: true,
+	    Skip:           []string{".git", "node_modules"},
+	    Errors:         ufs.WalkCollect,
+	}, func(path string, d fs.DirEntry) error {
+	    fmt.Println(path)
+	    return nil
+	})
+
+Entries are visited in lexical order, a directory before its contents, like filepath.WalkDir; fn may
+return filepath.SkipDir and filepath.SkipAll the same way.
+
+Functions:
+- Walk: Walks a directory tree with options
+*/
+
+// WalkErrorPolicy decides what Walk does when a path can't be read.
+type WalkErrorPolicy int
+
+const (
+	// WalkContinue skips the path, the error is passed to Options.OnWalkError or reported like the
+	// errors of the boolean functions
+	WalkContinue WalkErrorPolicy = iota
+	// WalkCollect skips the path and returns the errors of all the skipped paths at the end, joined
+	WalkCollect
+	// WalkStop stops the walk and returns the error
+	WalkStop
+)
+
+// WalkOptions are the settings of Walk.
+type WalkOptions struct {
+	// MaxDepth is the deepest level visited, 1 for the entries directly in the root, 0 is unlimited
+	MaxDepth int
+
+	// FollowSymlinks walks the directories symbolic links point to, links to a directory containing
+	// them fail with ErrSymlinkLoop. fn receives the links as the directories or files they point to.
+	FollowSymlinks bool
+
+	// SkipHidden skips hidden files and directories (see IsFileHidden), like Options.ExcludeHidden
+	// (which applies too)
+	SkipHidden bool
+
+	// Skip lists patterns of entries not visited, directories matching them are skipped with their
+	// contents. Patterns without "/" match the name of the entries, the others their slash separated
+	// path relative to the root, see Glob.go.
+	Skip []string
+
+	// Errors decides what happens when a directory can't be read, WalkContinue by default
+	Errors WalkErrorPolicy
+}
+
+// Walk calls fn for root and every file and directory under it, see Walk.go.
+//
+// Parameters:
+//   - root: The absolute or relative path to the directory to walk
+//   - opts: The settings of the walk
+//   - fn: The function called with the path of every entry, prefixed with root
+//
+// Returns:
+//   - error: The error returned by fn, the first error with WalkStop, the errors joined with WalkCollect,
+//     or an error if root can't be read or a pattern is malformed
+//
+// Example:
+//
+//	var total int64
+//	err := ufs.Walk("/home/me/photos", ufs.WalkOptions{SkipHidden: true, Errors: ufs.WalkCollect},
+//	    func(path string, d fs.DirEntry) error {
+//	        if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+//	            total += info.Size()
+//	        }
+//	        return nil
+//	    })
+//	if err != nil {
+//	    fmt.Printf("Some photos couldn't be read: %v\n", err)
+//	}
+func (ufs *UFS) Walk(root string, opts WalkOptions, fn func(path string, d fs.DirEntry) error) (err error) {
+	defer ufs.recoverPanic("Walk", &err)
+	return ufs.walk("Walk", root, opts, fn)
+}
+
+// walk is Walk, reporting the errors as those of operation
+func (ufs *UFS) walk(operation, root string, opts WalkOptions, fn func(path string, d fs.DirEntry) error) error {
+	skip, err := compileGlobs(opts.Skip)
+	if err != nil {
+		return ufs.wrapError(err, operation)
+	}
+	info, err := ufs.backend().Stat(root)
+	if err != nil {
+		return ufs.wrapError(err, operation)
+	}
+
+	w := &walker{ufs: ufs, operation: operation, opts: opts, skip: skip, fn: fn}
+	err = fn(root, fs.FileInfoToDirEntry(info))
+	if err == nil && info.IsDir() {
+		err = w.walkDir(root, "", 0, []os.FileInfo{info})
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		err = nil
+	}
+	if err == nil && len(w.errs) > 0 {
+		err = errors.Join(w.errs...)
+	}
+	return ufs.wrapError(err, operation)
+}
+
+// walker holds the state of a Walk
+type walker struct {
+	ufs       *UFS
+	operation string
+	opts      WalkOptions
+	skip      *globPattern
+	fn        func(path string, d fs.DirEntry) error
+	errs      []error // Errors collected with WalkCollect
+}
+
+// walkDir visits the entries of dir, at depth+1, then their contents. ancestors are the directories
+// containing them, used to detect symbolic link loops.
+func (w *walker) walkDir(dir, rel string, depth int, ancestors []os.FileInfo) error {
+	entries, err := w.ufs.backend().ReadDir(dir)
+	if err != nil {
+		return w.fail(dir, err)
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		entryRel := entry.Name()
+		if rel != "" {
+			entryRel = rel + "/" + entry.Name()
+		}
+		if (w.opts.SkipHidden && isHiddenEntry(entry)) || w.ufs.skipHidden(entry) || w.skip.matchName(entryRel) {
+			continue
+		}
+
+		var info os.FileInfo
+		if entry.Type()&fs.ModeSymlink != 0 && w.opts.FollowSymlinks {
+			// Broken links are visited as links
+			if target, err := w.ufs.backend().Stat(path); err == nil {
+				entry, info = fs.FileInfoToDirEntry(target), target
+			}
+		}
+
+		descend := entry.IsDir() && (w.opts.MaxDepth <= 0 || depth+1 < w.opts.MaxDepth)
+		if descend && w.opts.FollowSymlinks {
+			if info == nil {
+				if info, err = entry.Info(); err != nil {
+					if err := w.fail(path, err); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			if containsSameFile(ancestors, info) {
+				if err := w.fail(path, &fs.PathError{Op: "walk", Path: path, Err: ErrSymlinkLoop}); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if err := w.fn(path, entry); err != nil {
+			if err == filepath.SkipDir && entry.IsDir() {
+				continue
+			}
+			if err == filepath.SkipDir {
+				// Skips the remaining entries of the directory, like filepath.WalkDir
+				return nil
+			}
+			return err
+		}
+		if descend {
+			if err := w.walkDir(path, entryRel, depth+1, append(ancestors, info)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fail applies the error policy to an error met on path. It returns the error when the walk must stop.
+func (w *walker) fail(path string, err error) error {
+	switch w.opts.Errors {
+	case WalkStop:
+		return err
+	case WalkCollect:
+		w.errs = append(w.errs, err)
+		return nil
+	case WalkContinue:
+		return w.ufs.decideWalkError(path, err, WalkSkip, w.operation)
+	}
+	return fmt.Errorf("invalid WalkErrorPolicy %d", w.opts.Errors)
+}
+
+// containsSameFile reports whether info describes one of the files of infos
+func containsSameFile(infos []os.FileInfo, info os.FileInfo) bool {
+	for _, other := range infos {
+		if other != nil && os.SameFile(other, info) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package ufs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*
+Yaml-files.go contains functions to read and write YAML files into Go values, like Json-files.go does
+for JSON. The files are parsed and written by gopkg.in/yaml.v3:
+
+	# Comments are ignored
+	server:
+	  port: 8080
+	  hosts: [a.example.com, b.example.com]
+	users:
+	  - name: "Ada"
+	    admin: true
+	motd: |
+	  Welcome,
+	  enjoy your stay.
+
+Malformed documents are refused with the error of the parser. Anchors and aliases are resolved;
+files holding more than one document, mappings with complex keys and values JSON can't hold
+(infinities and NaN) are refused with an error instead of being misread.
+
+The documents are converted through encoding/json: Go values are mapped with their json struct tags,
+like with ReadJSONFile and WriteJSONFile, and a YAML file can be read into any value a JSON file can.
+
+Functions:
+- ReadYAMLFile: Reads a YAML file into a Go value.
+- WriteYAMLFile: Atomically writes a Go value to a YAML file.
+*/
+
+// ReadYAMLFile reads a YAML file and decodes it into v, mapping the fields of structs with their
+// json tags, see Yaml-files.go.
+//
+// Parameters:
+//   - path: The absolute or relative path to the YAML file
+//   - v: A pointer to the value to fill, e.g. a pointer to a struct or a map
+//
+// Returns:
+//   - error: An error if the file couldn't be read, isn't valid YAML or doesn't fit v, nil otherwise
+//
+// Example:
+//
+//	type Config struct {
+//	    Port  int      `json:"port"`
+//	    Hosts []string `json:"hosts"`
+//	}
+//	var config Config
+//	if err := ufs.ReadYAMLFile("config.yaml", &config); err != nil {
+//	    fmt.Printf("Error reading config: %v\n", err)
+//	    return
+//	}
+func (ufs *UFS) ReadYAMLFile(path string, v any) (err error) {
+	defer ufs.recoverPanic("ReadYAMLFile", &err)
+
+	data, err := ufs.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	document, err := parseYAML(data)
+	if err != nil {
+		return fmt.Errorf("ReadYAMLFile: invalid YAML in %s: %w", path, err)
+	}
+	if err := decodeThroughJSON(document, v); err != nil {
+		return fmt.Errorf("ReadYAMLFile: can't decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteYAMLFile encodes v as YAML and writes it atomically to a file, creating it if it doesn't exist
+// or replacing it if it does. Struct fields are written in order, with the names of their json tags.
+// This function will create any parent directories if they don't exist.
+//
+// Parameters:
+//   - path: The absolute or relative path to the YAML file
+//   - v: The value to encode, like json.Marshal
+//
+// Returns:
+//   - error: An error if v can't be encoded or the file couldn't be written, nil otherwise
+//
+// Example:
+//
+//	config.Port = 9090
+//	if err := ufs.WriteYAMLFile("config.yaml", config); err != nil {
+//	    fmt.Printf("Error saving config: %v\n", err)
+//	}
+func (ufs *UFS) WriteYAMLFile(path string, v any) (err error) {
+	defer ufs.recoverPanic("WriteYAMLFile", &err)
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ufs.wrapError(err, "WriteYAMLFile")
+	}
+	document, err := decodeOrderedJSON(data)
+	if err != nil {
+		return ufs.wrapError(err, "WriteYAMLFile")
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(yamlNode(document)); err != nil {
+		return ufs.wrapError(err, "WriteYAMLFile")
+	}
+	if err := encoder.Close(); err != nil {
+		return ufs.wrapError(err, "WriteYAMLFile")
+	}
+	return ufs.WriteFileAtomic(path, out.Bytes())
+}
+
+// parseYAML parses a YAML document into maps, slices, strings, numbers, bools and nil, refusing files
+// holding more than one document
+func parseYAML(data []byte) (any, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+
+	var document any
+	if err := decoder.Decode(&document); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	var next any
+	if err := decoder.Decode(&next); err == nil {
+		return nil, fmt.Errorf("the file holds more than one document")
+	} else if !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return plainYAMLValue(document)
+}
+
+// plainYAMLValue converts a value decoded by yaml.v3 to a value encoding/json can marshal:
+// the keys of the mappings become strings, infinite and NaN floats are refused
+func plainYAMLValue(value any) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			plain, err := plainYAMLValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = plain
+		}
+		return v, nil
+	case map[any]any:
+		mapping := make(map[string]any, len(v))
+		for key, item := range v {
+			var name string
+			if key == nil {
+				name = "null"
+			} else {
+				name = fmt.Sprint(key)
+			}
+			plain, err := plainYAMLValue(item)
+			if err != nil {
+				return nil, err
+			}
+			mapping[name] = plain
+		}
+		return mapping, nil
+	case []any:
+		for i, item := range v {
+			plain, err := plainYAMLValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = plain
+		}
+		return v, nil
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil, fmt.Errorf("%v can't be represented", v)
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+// yamlNode converts a document decoded by decodeOrderedJSON to a YAML node, keeping the order of the keys
+func yamlNode(value any) *yaml.Node {
+	switch v := value.(type) {
+	case []jsonField:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, field := range v {
+			key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: field.key}
+			node.Content = append(node.Content, key, yamlNode(field.value))
+		}
+		return node
+	case []any:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range v {
+			node.Content = append(node.Content, yamlNode(item))
+		}
+		return node
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(v.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v.String()}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}
+	case string:
+		// Quoted by the encoder when it would be read back as another type, multi-line strings are
+		// written as literal blocks when they can be
+		node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+		switch {
+		case strings.Contains(v, "\n"):
+			node.Style = yaml.LiteralStyle
+		case slices.Contains([]string{"y", "yes", "n", "no", "on", "off"}, strings.ToLower(v)):
+			node.Style = yaml.DoubleQuotedStyle // Booleans for YAML 1.1 parsers
+		}
+		return node
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	}
+}
@@ -0,0 +1,82 @@
+package ufs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadYAMLFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    any
+		wantErr bool
+	}{
+		{name: "mapping", data: "port: 8080\nhosts: [a, b]\n", want: map[string]any{"port": 8080.0, "hosts": []any{"a", "b"}}},
+		{name: "aliases", data: "base: &port 80\nport: *port\n", want: map[string]any{"base": 80.0, "port": 80.0}},
+		{name: "block scalar", data: "motd: |\n  Hello,\n  world\n", want: map[string]any{"motd": "Hello,\nworld\n"}},
+		{name: "empty file", data: "", want: nil},
+		{name: "nested mapping value", data: "a: b: c\n", wantErr: true},
+		{name: "unclosed flow sequence", data: "a: [1, 2\n", wantErr: true},
+		{name: "bad indentation", data: "a:\n  - 1\n - 2\n", wantErr: true},
+		{name: "duplicate key", data: "a: 1\na: 2\n", wantErr: true},
+		{name: "complex key", data: "? [a]\n: 1\n", wantErr: true},
+		{name: "several documents", data: "a: 1\n---\nb: 2\n", wantErr: true},
+		{name: "infinity", data: "a: .inf\n", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(test.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			var got any
+			err := NewUfs(NewOptions()).ReadYAMLFile(path, &got)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("ReadYAMLFile returned %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ReadYAMLFile returned %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteYAMLFile(t *testing.T) {
+	type config struct {
+		Name  string   `json:"name"`
+		Port  int      `json:"port"`
+		Hosts []string `json:"hosts"`
+		Motd  string   `json:"motd"`
+	}
+	// Strings read back as other types must stay strings
+	value := config{Name: "yes", Port: 80, Hosts: []string{"a: b", "1.0", "", " x"}, Motd: "Hello,\nworld\n"}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	ufs := NewUfs(NewOptions())
+	if err := ufs.WriteYAMLFile(path, value); err != nil {
+		t.Fatal(err)
+	}
+	want := "name: \"yes\"\nport: 80\nhosts:\n  - 'a: b'\n  - \"1.0\"\n  - \"\"\n  - ' x'\nmotd: |\n  Hello,\n  world\n"
+	if data, _ := os.ReadFile(path); string(data) != want {
+		t.Errorf("WriteYAMLFile wrote\n%s\nwant\n%s", data, want)
+	}
+
+	var got config
+	if err := ufs.ReadYAMLFile(path, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, value) {
+		t.Errorf("read back %+v, want %+v", got, value)
+	}
+}
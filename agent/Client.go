@@ -0,0 +1,239 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/utsav-56/ufs"
+)
+
+/*
+Client.go contains the client of the agents, a ufs.Backend: a UFS instance using it runs the core
+functions (see Backend.go in ufs) on the remote machine.
+
+	client := agent.NewClient("https://web1.internal:7443", os.Getenv("UFS_AGENT_TOKEN"))
+	web1 := ufs.NewUfs(&ufs.Options{Backend: client})
+	if web1.IsFile("/srv/app/maintenance.flag") {
+	    web1.RemoveFile("/srv/app/maintenance.flag")
+	}
+	web1.AppendToFile("/srv/app/deploy.log", []byte("deployed 1.4.2\n"))
+
+Files opened for reading are streamed from the agent. Files opened for writing are streamed to it as
+they are written, and the upload completes on Close, which returns its error. Files can't be opened for
+reading and writing at once: reading a file opened with os.O_RDWR fails.
+
+Functions:
+- NewClient: Creates a client of an agent.
+- Client.OpenFile, Stat, ReadDir, Mkdir, Remove, Rename, Chmod: The operations of ufs.Backend.
+*/
+
+// Client is a ufs.Backend running its operations on a remote agent.
+// Its methods are safe for concurrent use.
+type Client struct {
+	// HTTPClient sends the requests, http.DefaultClient when nil. Set it for custom TLS settings or timeouts.
+	HTTPClient *http.Client
+
+	baseURL string
+	token   string
+}
+
+var _ ufs.Backend = (*Client)(nil)
+
+// NewClient creates a client of the agent listening at baseURL.
+//
+// Parameters:
+//   - baseURL: The URL of the agent, e.g. "https://web1.internal:7443"
+//   - token: The token of the agent
+//
+// Returns:
+//   - *Client: The client, to set in ufs.Options.Backend
+//
+// Example:
+//
+//	client := agent.NewClient("https://db1.internal:7443", token)
+//	db1 := ufs.NewUfs(&ufs.Options{Backend: client})
+//	files := db1.GetFileList("/var/backups")
+func NewClient(baseURL, token string) *Client {
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), token: token}
+}
+
+// do sends a request and returns its response, or the error of the agent
+func (c *Client) do(method, endpoint string, query url.Values, body io.Reader, op, path, newPath string) (*http.Response, error) {
+	target := c.baseURL + "/v1/" + endpoint
+	if query != nil {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: path, Err: err}
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: path, Err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var f failure
+		if err := json.NewDecoder(resp.Body).Decode(&f); err != nil {
+			f = failure{Code: ufs.CodeUnknown, Message: fmt.Sprintf("agent answered %s", resp.Status)}
+		}
+		return nil, remoteError(op, path, newPath, f)
+	}
+	return resp, nil
+}
+
+// call runs a JSON operation, decoding its result into result when not nil
+func (c *Client) call(endpoint string, req request, result any) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(http.MethodPost, endpoint, nil, bytes.NewReader(body), endpoint, req.Path, req.NewPath)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return &fs.PathError{Op: endpoint, Path: req.Path, Err: err}
+	}
+	return nil
+}
+
+// OpenFile opens a remote file like os.OpenFile
+func (c *Client) OpenFile(name string, flag int, perm fs.FileMode) (ufs.BackendFile, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		resp, err := c.do(http.MethodGet, "file", url.Values{"path": {name}}, nil, "open", name, "")
+		if err != nil {
+			return nil, err
+		}
+		return &remoteFile{client: c, name: name, body: resp.Body}, nil
+	}
+
+	// The file is created, truncated or checked right away, so errors are reported by OpenFile
+	if err := c.call("open", request{Path: name, Flag: flag, Mode: perm}, nil); err != nil {
+		return nil, err
+	}
+	return &remoteFile{client: c, name: name, writable: true, appending: flag&os.O_APPEND != 0}, nil
+}
+
+// Stat describes a remote file or directory
+func (c *Client) Stat(name string) (fs.FileInfo, error) {
+	var info fileInfo
+	if err := c.call("stat", request{Path: name}, &info); err != nil {
+		return nil, err
+	}
+	return &remoteInfo{info: info}, nil
+}
+
+// ReadDir lists the entries of a remote directory, sorted by name
+func (c *Client) ReadDir(name string) ([]fs.DirEntry, error) {
+	var infos []fileInfo
+	if err := c.call("readdir", request{Path: name}, &infos); err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, len(infos))
+	for i := range infos {
+		entries[i] = fs.FileInfoToDirEntry(&remoteInfo{info: infos[i]})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// Mkdir creates a remote directory, its parent must exist
+func (c *Client) Mkdir(name string, perm fs.FileMode) error {
+	return c.call("mkdir", request{Path: name, Mode: perm}, nil)
+}
+
+// Remove removes a remote file or empty directory
+func (c *Client) Remove(name string) error {
+	return c.call("remove", request{Path: name}, nil)
+}
+
+// Rename moves a remote file or directory
+func (c *Client) Rename(oldpath, newpath string) error {
+	return c.call("rename", request{Path: oldpath, NewPath: newpath}, nil)
+}
+
+// Chmod changes the permission bits of a remote file or directory
+func (c *Client) Chmod(name string, mode fs.FileMode) error {
+	return c.call("chmod", request{Path: name, Mode: mode}, nil)
+}
+
+// remoteFile is a file of an agent opened by Client.OpenFile
+type remoteFile struct {
+	client *Client
+	name   string
+
+	body io.ReadCloser // Content being downloaded, for reading
+
+	writable  bool
+	appending bool
+	upload    *io.PipeWriter // Content being uploaded, started by the first Write
+	uploaded  chan error     // Result of the upload
+}
+
+func (f *remoteFile) Read(p []byte) (int, error) {
+	if f.body == nil {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrPermission}
+	}
+	return f.body.Read(p)
+}
+
+func (f *remoteFile) Write(p []byte) (int, error) {
+	if !f.writable {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrPermission}
+	}
+	if f.upload == nil {
+		reader, writer := io.Pipe()
+		f.upload, f.uploaded = writer, make(chan error, 1)
+		query := url.Values{"path": {f.name}}
+		if f.appending {
+			query.Set("append", "1")
+		}
+		go func() {
+			resp, err := f.client.do(http.MethodPut, "file", query, reader, "write", f.name, "")
+			if err == nil {
+				resp.Body.Close()
+			}
+			// Unblocks the writes when the agent stopped reading
+			reader.CloseWithError(err)
+			f.uploaded <- err
+		}()
+	}
+	return f.upload.Write(p)
+}
+
+// Close completes the upload of a file opened for writing and returns its result
+func (f *remoteFile) Close() error {
+	if f.body != nil {
+		return f.body.Close()
+	}
+	if f.upload == nil {
+		return nil
+	}
+	f.upload.Close()
+	err := <-f.uploaded
+	f.upload = nil
+	return err
+}
+
+func (f *remoteFile) Stat() (fs.FileInfo, error) {
+	return f.client.Stat(f.name)
+}
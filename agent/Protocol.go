@@ -0,0 +1,133 @@
+// Package agent runs ufs operations on remote machines: a Server exposes the file system of a machine
+// over HTTP, with a token and a path policy, and a Client implements ufs.Backend on top of it, so a
+// controller can use the core ufs functions on every machine it manages.
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/utsav-56/ufs"
+)
+
+/*
+Protocol.go contains the messages exchanged by the Server and the Client.
+
+Every request carries "Authorization: Bearer <token>". Operations are under /v1/:
+- POST stat, readdir, mkdir, remove, rename, chmod, open: a JSON request, a JSON response
+- GET file?path=...: the content of a file
+- PUT file?path=...&append=1: writes the body to a file prepared by open
+Failures are a JSON failure with the ufs.ErrorCode of the error, so the client rebuilds errors
+matching fs.ErrNotExist, fs.ErrExist, fs.ErrPermission and the codes of ufs.ErrorCodeOf.
+*/
+
+// request is the body of the JSON operations, each using the fields it needs
+type request struct {
+	Path    string      `json:"path"`
+	NewPath string      `json:"newPath,omitempty"` // rename
+	Mode    fs.FileMode `json:"mode,omitempty"`    // mkdir, chmod, open
+	Flag    int         `json:"flag,omitempty"`    // open
+}
+
+// fileInfo is the wire form of an fs.FileInfo
+type fileInfo struct {
+	Name    string      `json:"name"`
+	Size    int64       `json:"size"`
+	Mode    fs.FileMode `json:"mode"`
+	ModTime time.Time   `json:"modTime"`
+}
+
+func newFileInfo(info fs.FileInfo) fileInfo {
+	return fileInfo{Name: info.Name(), Size: info.Size(), Mode: info.Mode(), ModTime: info.ModTime()}
+}
+
+// remoteInfo is an fs.FileInfo received from the server
+type remoteInfo struct {
+	info fileInfo
+}
+
+func (r *remoteInfo) Name() string       { return r.info.Name }
+func (r *remoteInfo) Size() int64        { return r.info.Size }
+func (r *remoteInfo) Mode() fs.FileMode  { return r.info.Mode }
+func (r *remoteInfo) ModTime() time.Time { return r.info.ModTime }
+func (r *remoteInfo) IsDir() bool        { return r.info.Mode.IsDir() }
+func (r *remoteInfo) Sys() any           { return nil }
+
+// failure is the body of a failed request
+type failure struct {
+	Code    ufs.ErrorCode `json:"code"`
+	Message string        `json:"message"`
+}
+
+// writeFailure answers a request with the error, and the HTTP status matching its code
+func writeFailure(w http.ResponseWriter, err error) {
+	code := ufs.ErrorCodeOf(err)
+	status := http.StatusInternalServerError
+	switch code {
+	case ufs.CodeNotFound:
+		status = http.StatusNotFound
+	case ufs.CodeAlreadyExists, ufs.CodeDirectoryNotEmpty:
+		status = http.StatusConflict
+	case ufs.CodePermissionDenied, ufs.CodeReadOnly:
+		status = http.StatusForbidden
+	case ufs.CodeNotDirectory, ufs.CodeIsDirectory, ufs.CodeInvalidName, ufs.CodeNameTooLong:
+		status = http.StatusBadRequest
+	}
+	// The client adds the operation and the path of its side
+	message := err.Error()
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	switch {
+	case errors.As(err, &pathErr):
+		message = pathErr.Err.Error()
+	case errors.As(err, &linkErr):
+		message = linkErr.Err.Error()
+	}
+	writeJSON(w, status, failure{Code: code, Message: message})
+}
+
+// writeJSON answers a request with a JSON body
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// RemoteError is a failure reported by the agent, with the message of the server, e.g. a path
+// refused by its policy. ufs.ErrorCodeOf returns its Code, and errors.Is matches fs.ErrPermission for
+// CodePermissionDenied. Missing and existing files are reported with fs.ErrNotExist and fs.ErrExist
+// instead, so os.IsNotExist and os.IsExist work too.
+type RemoteError struct {
+	Code    ufs.ErrorCode // Portable reason of the failure
+	Message string        // Message of the server
+}
+
+func (e *RemoteError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns a *ufs.FileSystemError with the code, for ufs.ErrorCodeOf and errors.Is
+func (e *RemoteError) Unwrap() error {
+	return &ufs.FileSystemError{Code: e.Code, Err: errors.New(e.Message)}
+}
+
+// remoteError rebuilds the error of a failed request on the operation op of path (and newPath for renames)
+func remoteError(op, path, newPath string, f failure) error {
+	var err error
+	switch f.Code {
+	case ufs.CodeNotFound:
+		err = fs.ErrNotExist
+	case ufs.CodeAlreadyExists:
+		err = fs.ErrExist
+	default:
+		err = &RemoteError{Code: f.Code, Message: f.Message}
+	}
+	if op == "rename" {
+		return &os.LinkError{Op: op, Old: path, New: newPath, Err: err}
+	}
+	return &fs.PathError{Op: op, Path: path, Err: err}
+}
@@ -0,0 +1,317 @@
+package agent
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/utsav-56/ufs"
+)
+
+/*
+Server.go contains the agent run on the managed machines.
+
+The server only serves the directories of its policy (ServerOptions.Roots): paths are made absolute,
+symbolic links are resolved, and anything ending outside of the roots is refused with
+ufs.CodePermissionDenied, so a link can't be used to escape them. ReadOnly refuses every change.
+
+The token is compared in constant time. It travels in a header, so the server should be run behind
+TLS (http.ListenAndServeTLS) outside of trusted networks.
+
+	server, err := agent.NewServer(&agent.ServerOptions{Token: os.Getenv("UFS_AGENT_TOKEN"), Roots: []string{"/srv"}})
+	if err != nil {
+	    log.Fatal(err)
+	}
+	log.Fatal(http.ListenAndServeTLS(":7443", "agent.crt", "agent.key", server))
+
+Functions:
+- NewServer: Creates an agent serving the directories of a policy.
+- Server.ServeHTTP: Answers a request of a Client.
+*/
+
+// maxRequestSize bounds the JSON requests, file contents are not limited
+const maxRequestSize = 1 << 20
+
+// ServerOptions configures an agent server.
+type ServerOptions struct {
+	// Token is the secret the clients must send, required
+	Token string
+
+	// Roots are the directories served, required; paths outside of them are refused
+	Roots []string
+
+	// ReadOnly refuses every operation changing the file system
+	ReadOnly bool
+}
+
+// Server is an agent serving the file system of the machine to Clients, as an http.Handler.
+type Server struct {
+	token    []byte
+	roots    []string // Absolute, with symbolic links resolved
+	readOnly bool
+	mux      *http.ServeMux
+}
+
+// errOutsideRoots is the error of the paths refused by the policy
+var errOutsideRoots = &RemoteError{Code: ufs.CodePermissionDenied, Message: "path is outside the roots of the agent"}
+
+// errReadOnly is the error of the changes refused by a read-only agent
+var errReadOnly = &RemoteError{Code: ufs.CodeReadOnly, Message: "the agent is read-only"}
+
+// NewServer creates an agent serving the directories of opts.Roots to the clients sending opts.Token.
+//
+// Parameters:
+//   - opts: The token and the policy of the agent
+//
+// Returns:
+//   - *Server: The agent, to serve with http.ListenAndServeTLS or mount on a mux
+//   - error: An error if the token or the roots are missing, or a root is not a directory
+//
+// Example:
+//
+//	server, err := agent.NewServer(&agent.ServerOptions{
+//	    Token:    os.Getenv("UFS_AGENT_TOKEN"),
+//	    Roots:    []string{"/var/log", "/srv/app"},
+//	    ReadOnly: true,
+//	})
+func NewServer(opts *ServerOptions) (*Server, error) {
+	if opts == nil || opts.Token == "" {
+		return nil, fmt.Errorf("agent: a token is required")
+	}
+	if len(opts.Roots) == 0 {
+		return nil, fmt.Errorf("agent: at least one root directory is required")
+	}
+
+	s := &Server{token: []byte(opts.Token), readOnly: opts.ReadOnly, mux: http.NewServeMux()}
+	for _, root := range opts.Roots {
+		resolved, err := filepath.Abs(root)
+		if err == nil {
+			resolved, err = filepath.EvalSymlinks(resolved)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("agent: invalid root %s: %w", root, err)
+		}
+		if info, err := os.Stat(resolved); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("agent: root is not a directory: %s", root)
+		}
+		s.roots = append(s.roots, resolved)
+	}
+
+	s.mux.HandleFunc("POST /v1/stat", s.handle(s.stat))
+	s.mux.HandleFunc("POST /v1/readdir", s.handle(s.readDir))
+	s.mux.HandleFunc("POST /v1/mkdir", s.handle(s.mkdir))
+	s.mux.HandleFunc("POST /v1/remove", s.handle(s.remove))
+	s.mux.HandleFunc("POST /v1/rename", s.handle(s.rename))
+	s.mux.HandleFunc("POST /v1/chmod", s.handle(s.chmod))
+	s.mux.HandleFunc("POST /v1/open", s.handle(s.open))
+	s.mux.HandleFunc("GET /v1/file", s.download)
+	s.mux.HandleFunc("PUT /v1/file", s.upload)
+	return s, nil
+}
+
+// ServeHTTP answers a request of a Client, after checking its token
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), s.token) != 1 {
+		writeJSON(w, http.StatusUnauthorized, failure{Code: ufs.CodePermissionDenied, Message: "invalid agent token"})
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// handle decodes the JSON request of an operation, runs it and encodes its result
+func (s *Server) handle(op func(req *request) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, failure{Code: ufs.CodeUnknown, Message: "invalid request: " + err.Error()})
+			return
+		}
+		result, err := op(&req)
+		if err != nil {
+			writeFailure(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// resolve returns the real path of path, refusing the paths outside of the roots. With follow, a final
+// symbolic link is resolved too, for the operations acting on what it points to.
+func (s *Server) resolve(path string, follow bool) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	// The parent must exist for every operation, the entry itself may not
+	resolved := path
+	if parent, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		resolved = filepath.Join(parent, filepath.Base(path))
+	}
+	if follow {
+		if target, err := filepath.EvalSymlinks(resolved); err == nil {
+			resolved = target
+		} else if info, err := os.Lstat(resolved); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			// A dangling link, creating the file would create its target wherever it is
+			return "", &fs.PathError{Op: "resolve", Path: path, Err: errOutsideRoots}
+		}
+	}
+
+	for _, root := range s.roots {
+		if resolved == root || strings.HasPrefix(resolved, root+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", &fs.PathError{Op: "resolve", Path: path, Err: errOutsideRoots}
+}
+
+// change resolves the path of an operation changing the file system
+func (s *Server) change(path string, follow bool) (string, error) {
+	if s.readOnly {
+		return "", &fs.PathError{Op: "resolve", Path: path, Err: errReadOnly}
+	}
+	return s.resolve(path, follow)
+}
+
+func (s *Server) stat(req *request) (any, error) {
+	path, err := s.resolve(req.Path, true)
+	if err != nil {
+		return nil, err
+	}
+	info, err := ufs.OSBackend.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return newFileInfo(info), nil
+}
+
+func (s *Server) readDir(req *request) (any, error) {
+	path, err := s.resolve(req.Path, true)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ufs.OSBackend.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]fileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed meanwhile
+		}
+		infos = append(infos, newFileInfo(info))
+	}
+	return infos, nil
+}
+
+func (s *Server) mkdir(req *request) (any, error) {
+	path, err := s.change(req.Path, false)
+	if err != nil {
+		return nil, err
+	}
+	return struct{}{}, ufs.OSBackend.Mkdir(path, req.Mode)
+}
+
+func (s *Server) remove(req *request) (any, error) {
+	path, err := s.change(req.Path, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, root := range s.roots {
+		if path == root {
+			return nil, &fs.PathError{Op: "remove", Path: req.Path, Err: errOutsideRoots}
+		}
+	}
+	return struct{}{}, ufs.OSBackend.Remove(path)
+}
+
+func (s *Server) rename(req *request) (any, error) {
+	oldPath, err := s.change(req.Path, false)
+	if err != nil {
+		return nil, err
+	}
+	newPath, err := s.change(req.NewPath, false)
+	if err != nil {
+		return nil, err
+	}
+	return struct{}{}, ufs.OSBackend.Rename(oldPath, newPath)
+}
+
+func (s *Server) chmod(req *request) (any, error) {
+	path, err := s.change(req.Path, true)
+	if err != nil {
+		return nil, err
+	}
+	return struct{}{}, ufs.OSBackend.Chmod(path, req.Mode)
+}
+
+// open prepares a file for the uploads: it is created, truncated or checked according to the flag
+func (s *Server) open(req *request) (any, error) {
+	path, err := s.change(req.Path, true)
+	if err != nil {
+		return nil, err
+	}
+	file, err := ufs.OSBackend.OpenFile(path, req.Flag&^os.O_APPEND, req.Mode)
+	if err != nil {
+		return nil, err
+	}
+	return struct{}{}, file.Close()
+}
+
+// download sends the content of a file
+func (s *Server) download(w http.ResponseWriter, r *http.Request) {
+	path, err := s.resolve(r.URL.Query().Get("path"), true)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	file, err := ufs.OSBackend.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err == nil && info.IsDir() {
+		writeFailure(w, &fs.PathError{Op: "read", Path: path, Err: &RemoteError{Code: ufs.CodeIsDirectory, Message: "is a directory"}})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	io.Copy(w, file)
+}
+
+// upload writes the body of the request to a file prepared by open, from its start or at its end
+func (s *Server) upload(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	path, err := s.change(query.Get("path"), true)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	flag := os.O_WRONLY
+	if query.Get("append") == "1" {
+		flag |= os.O_APPEND
+	}
+	file, err := ufs.OSBackend.OpenFile(path, flag, 0)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	_, err = io.Copy(file, r.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, struct{}{})
+}
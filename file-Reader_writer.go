@@ -1,7 +1,8 @@
 package ufs
 
 import (
-	"bufio"
+	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"os"
@@ -20,6 +21,8 @@ This file provides basic file operations such as reading, writing, appending, co
 Provided functions include:
 - ReadFile: Reads the content of a file and returns it as a byte slice.
 - WriteFile: Writes data to a file, creating it if it doesn't exist or overwriting it if it does.
+- WriteFileIfChanged: Writes data to a file only when its content differs, keeping the modification time otherwise.
+- WriteFileAtomic: Writes data to a file through a temporary file, so readers never see a partial file.
 - AppendToFile: Appends data to a file, creating it if it doesn't exist.
 - CopyFile: Copies the content of one file to another.
 - MoveFile: Moves a file from one location to another.
@@ -39,6 +42,7 @@ Other utilities:
 Advanced utilities includes:
 - AssembleFiles : Combines multiple files into a single file in order of slice.,
 - SplitFile : Splits a file into multiple files based on a specified size limit.
+  See Split-options.go for splitting by lines and naming the parts.
 - CleanUpFiles : Cleans up files by removing empty files given in a slice.
 - ReadFileWithLines : Reads a file and returns its content as a slice of strings, each representing a line in the file.
 - AppendToLastLine : Appends a string to the last line of a file, creating the file if it doesn't exist. if file has 14 lines, it will append to 15th line. wont append to 14th line (same line).
@@ -63,12 +67,14 @@ Advanced utilities includes:
 //	    return
 //	}
 //	fmt.Printf("File content: %s\n", data)
-func (ufs *UFS) ReadFile(path string) ([]byte, error) {
+func (ufs *UFS) ReadFile(path string) (_ []byte, err error) {
+	defer ufs.recoverPanic("ReadFile", &err)
+
 	if !ufs.IsFile(path) {
-		return nil, fmt.Errorf("path is not a file: %s", path)
+		return nil, ufs.misuseError("ReadFile", "path is not a file", path)
 	}
 
-	data, err := os.ReadFile(path)
+	data, err := readBackendFile(ufs.backend(), path)
 	if err != nil {
 		return nil, ufs.wrapError(err, "ReadFile")
 	}
@@ -93,7 +99,9 @@ func (ufs *UFS) ReadFile(path string) ([]byte, error) {
 //	    return
 //	}
 //	fmt.Printf("File content: %s\n", content)
-func (ufs *UFS) ReadFileAsString(path string) (string, error) {
+func (ufs *UFS) ReadFileAsString(path string) (_ string, err error) {
+	defer ufs.recoverPanic("ReadFileAsString", &err)
+
 	data, err := ufs.ReadFile(path)
 	if err != nil {
 		return "", err
@@ -119,23 +127,134 @@ func (ufs *UFS) ReadFileAsString(path string) (string, error) {
 //	    return
 //	}
 //	fmt.Println("File written successfully")
-func (ufs *UFS) WriteFile(path string, data []byte) error {
+func (ufs *UFS) WriteFile(path string, data []byte) (err error) {
+	defer ufs.recoverPanic("WriteFile", &err)
+
 	// Ensure the directory exists
 	dir := filepath.Dir(path)
 	if !ufs.IsDirectory(dir) {
-		err := os.MkdirAll(dir, 0755)
+		err := mkdirAllBackend(ufs.backend(), dir, 0755)
 		if err != nil {
 			return ufs.wrapError(err, "WriteFile")
 		}
 	}
 
-	err := os.WriteFile(path, data, 0644)
+	if err := ufs.checkQuota(path, int64(len(data))); err != nil {
+		return ufs.wrapError(err, "WriteFile")
+	}
+	err = writeBackendFile(ufs.backend(), path, data, 0644)
+	ufs.recordQuota(path)
 	if err != nil {
 		return ufs.wrapError(err, "WriteFile")
 	}
 	return nil
 }
 
+// WriteFileIfChanged writes data to a file only when the file doesn't exist or its content differs.
+// An unchanged file is not touched, so its modification time is kept and build systems or file watchers
+// relying on it don't rebuild unnecessarily. The content is compared by size, then by SHA-256 hash.
+// This function will create any parent directories if they don't exist.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file to write
+//   - data: The data to write to the file as a byte slice
+//
+// Returns:
+//   - bool: true if the file was written, false if it already had this content
+//   - error: An error if the file couldn't be read or written
+//
+// Example:
+//
+//	written, err := ufs.WriteFileIfChanged("gen/version.go", source)
+//	if err != nil {
+//	    fmt.Printf("Error writing file: %v\n", err)
+//	    return
+//	}
+//	if !written {
+//	    fmt.Println("gen/version.go is up to date")
+//	}
+func (ufs *UFS) WriteFileIfChanged(path string, data []byte) (_ bool, err error) {
+	defer ufs.recoverPanic("WriteFileIfChanged", &err)
+
+	info, err := ufs.backend().Stat(path)
+	switch {
+	case os.IsNotExist(err):
+		// Written below
+	case err != nil:
+		return false, ufs.wrapError(err, "WriteFileIfChanged")
+	case info.IsDir():
+		return false, ufs.misuseError("WriteFileIfChanged", "path is not a file", path)
+	case info.Size() == int64(len(data)):
+		digest, err := ufs.fileDigest(path, info.Size(), DetectFullHash)
+		if err != nil {
+			return false, ufs.wrapError(err, "WriteFileIfChanged")
+		}
+		sum := sha256.Sum256(data)
+		if bytes.Equal(digest, sum[:]) {
+			return false, nil
+		}
+	}
+
+	if err := ufs.WriteFile(path, data); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WriteFileAtomic writes data to a file, creating it if it doesn't exist or replacing it if it does.
+// The data is written and synced to a temporary file in the same directory, which is then renamed over
+// the file: readers see the old or the new content, never a partial file, even if the program crashes.
+// An existing file keeps its permissions, a new file gets 0644.
+// This function will create any parent directories if they don't exist.
+//
+// Parameters:
+//   - path: The absolute or relative path to the file to write
+//   - data: The data to write to the file as a byte slice
+//
+// Returns:
+//   - error: An error if the file couldn't be written to
+//
+// Example:
+//
+//	err := ufs.WriteFileAtomic("/var/lib/myapp/state.db", state)
+//	if err != nil {
+//	    fmt.Printf("Error saving state: %v\n", err)
+//	    return
+//	}
+func (ufs *UFS) WriteFileAtomic(path string, data []byte) (err error) {
+	defer ufs.recoverPanic("WriteFileAtomic", &err)
+
+	perm := os.FileMode(0644)
+	if info, err := ufs.backend().Stat(path); err == nil {
+		if info.IsDir() {
+			return ufs.misuseError("WriteFileAtomic", "path is not a file", path)
+		}
+		perm = info.Mode().Perm()
+	}
+
+	// Ensure the directory exists
+	dir := filepath.Dir(path)
+	if !ufs.IsDirectory(dir) {
+		err := mkdirAllBackend(ufs.backend(), dir, 0755)
+		if err != nil {
+			return ufs.wrapError(err, "WriteFileAtomic")
+		}
+	}
+
+	if err := ufs.checkQuota(path, int64(len(data))); err != nil {
+		return ufs.wrapError(err, "WriteFileAtomic")
+	}
+	// Completed even when the program is shutting down, see Shutdown-manager.go
+	done := ufs.trackCritical()
+	err = ufs.replaceFile(path, data, ufs.fileMode(perm))
+	done()
+	ufs.recordQuota(path)
+	if err != nil {
+		return ufs.wrapError(err, "WriteFileAtomic")
+	}
+	return nil
+}
+
 // WriteStringToFile writes a string to a file, creating it if it doesn't exist or overwriting it if it does.
 // This function will create any parent directories if they don't exist.
 //
@@ -154,7 +273,9 @@ func (ufs *UFS) WriteFile(path string, data []byte) error {
 //	    return
 //	}
 //	fmt.Println("File written successfully")
-func (ufs *UFS) WriteStringToFile(path string, content string) error {
+func (ufs *UFS) WriteStringToFile(path string, content string) (err error) {
+	defer ufs.recoverPanic("WriteStringToFile", &err)
+
 	return ufs.WriteFile(path, []byte(content))
 }
 
@@ -176,24 +297,35 @@ func (ufs *UFS) WriteStringToFile(path string, content string) error {
 //	    return
 //	}
 //	fmt.Println("Data appended to file successfully")
-func (ufs *UFS) AppendToFile(path string, data []byte) error {
+func (ufs *UFS) AppendToFile(path string, data []byte) (err error) {
+	defer ufs.recoverPanic("AppendToFile", &err)
+
 	// Ensure the directory exists
 	dir := filepath.Dir(path)
 	if !ufs.IsDirectory(dir) {
-		err := os.MkdirAll(dir, 0755)
+		err := mkdirAllBackend(ufs.backend(), dir, 0755)
 		if err != nil {
 			return ufs.wrapError(err, "AppendToFile")
 		}
 	}
 
+	var size int64
+	if info, err := ufs.backend().Stat(path); err == nil {
+		size = info.Size()
+	}
+	if err := ufs.checkQuota(path, size+int64(len(data))); err != nil {
+		return ufs.wrapError(err, "AppendToFile")
+	}
+
 	// Open file in append mode
-	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	file, err := ufs.backend().OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return ufs.wrapError(err, "AppendToFile")
 	}
 	defer file.Close()
 
 	_, err = file.Write(data)
+	ufs.recordQuota(path)
 	if err != nil {
 		return ufs.wrapError(err, "AppendToFile")
 	}
@@ -218,12 +350,18 @@ func (ufs *UFS) AppendToFile(path string, data []byte) error {
 //	    return
 //	}
 //	fmt.Println("Text appended to file successfully")
-func (ufs *UFS) AppendStringToFile(path string, content string) error {
+func (ufs *UFS) AppendStringToFile(path string, content string) (err error) {
+	defer ufs.recoverPanic("AppendStringToFile", &err)
+
 	return ufs.AppendToFile(path, []byte(content))
 }
 
 // CopyFile copies the content of one file to another.
-// If the destination file already exists, it will be overwritten.
+// On file systems supporting it (Btrfs, XFS, APFS, ReFS) the file is cloned, which is near-instant,
+// unless Options.DisableReflink is set (see File-clone.go).
+// With Options.PreserveMacMetadata the resource fork and Finder info are copied too on macOS.
+// If the destination file already exists, Options.Overwrite decides what happens: by default it is
+// overwritten, see Collision-policy.go for the other policies.
 // This function will create any parent directories for the destination if they don't exist.
 //
 // Parameters:
@@ -241,45 +379,104 @@ func (ufs *UFS) AppendStringToFile(path string, content string) error {
 //	    return
 //	}
 //	fmt.Println("File copied successfully")
-func (ufs *UFS) CopyFile(src, dst string) error {
+func (ufs *UFS) CopyFile(src, dst string) (err error) {
+	defer ufs.recoverPanic("CopyFile", &err)
+
 	// Verify source is a file
 	if !ufs.IsFile(src) {
-		return fmt.Errorf("source is not a file: %s", src)
+		return ufs.misuseError("CopyFile", "source is not a file", src)
+	}
+
+	_, _, err = ufs.placeFile(dst, ufs.opts.Overwrite, func(dest string, noReplace bool) error {
+		return ufs.copyFileTo(src, dest, noReplace)
+	})
+	return err
+}
+
+// copyFile is CopyFile overwriting the destination
+func (ufs *UFS) copyFile(src, dst string) error {
+	return ufs.copyFileTo(src, dst, false)
+}
+
+// copyFileTo copies a file, overwriting the destination unless noReplace is set, in which case the
+// destination is created exclusively and an error wrapping os.ErrExist is returned if it exists
+func (ufs *UFS) copyFileTo(src, dst string, noReplace bool) (err error) {
+	defer ufs.recoverPanic("CopyFile", &err)
+
+	// Verify source is a file
+	if !ufs.IsFile(src) {
+		return ufs.misuseError("CopyFile", "source is not a file", src)
+	}
+
+	srcInfo, err := ufs.backend().Stat(src)
+	if err != nil {
+		return ufs.wrapError(err, "CopyFile")
+	}
+	if err := ufs.checkQuota(dst, srcInfo.Size()); err != nil {
+		return ufs.wrapError(err, "CopyFile")
 	}
 
 	// Ensure the destination directory exists
 	dstDir := filepath.Dir(dst)
 	if !ufs.IsDirectory(dstDir) {
-		err := os.MkdirAll(dstDir, 0755)
+		err := mkdirAllBackend(ufs.backend(), dstDir, 0755)
 		if err != nil {
 			return ufs.wrapError(err, "CopyFile")
 		}
 	}
 
+	flag := os.O_TRUNC
+	if noReplace {
+		flag = os.O_EXCL
+	}
+
+	if !ufs.onOS() {
+		if err := copyBackendFile(ufs.backend(), src, dst, flag); err != nil {
+			return ufs.wrapError(err, "CopyFile")
+		}
+		return nil
+	}
+
 	// Open source file
-	srcFile, err := os.Open(src)
+	srcFile, err := ufs.openSequential(src)
 	if err != nil {
 		return ufs.wrapError(err, "CopyFile")
 	}
 	defer srcFile.Close()
 
 	// Create destination file
-	dstFile, err := os.Create(dst)
+	dstFile, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|flag, 0666)
 	if err != nil {
 		return ufs.wrapError(err, "CopyFile")
 	}
 	defer dstFile.Close()
+	if noReplace {
+		// The file created for the copy is not left half written
+		defer func() {
+			if err != nil {
+				dstFile.Close()
+				os.Remove(dst)
+			}
+		}()
+	}
 
-	// Copy the contents
-	_, err = io.Copy(dstFile, srcFile)
+	// Copy the contents, by cloning them when the file system supports it
+	err = ufs.cloneOrCopy(srcFile, dstFile)
+	ufs.recordQuota(dst)
 	if err != nil {
 		return ufs.wrapError(err, "CopyFile")
 	}
 
+	// Keep the resource fork and Finder info when Options.PreserveMacMetadata is set
+	if err := ufs.copyMacMetadata(src, dst); err != nil {
+		return ufs.wrapError(err, "CopyFile")
+	}
+
 	return nil
 }
 
 // CopyFileWithPermissions copies a file to a new location, preserving its permissions.
+// Like CopyFile, the file is cloned when the file system supports it.
 // If the destination file already exists, it will be overwritten.
 // This function will create any parent directories for the destination if they don't exist.
 //
@@ -298,10 +495,16 @@ func (ufs *UFS) CopyFile(src, dst string) error {
 //	    return
 //	}
 //	fmt.Println("File copied with permissions successfully")
-func (ufs *UFS) CopyFileWithPermissions(src, dst string) error {
+func (ufs *UFS) CopyFileWithPermissions(src, dst string) (err error) {
+	defer ufs.recoverPanic("CopyFileWithPermissions", &err)
+
+	if err := ufs.requireOS("CopyFileWithPermissions"); err != nil {
+		return err
+	}
+
 	// Verify source is a file
 	if !ufs.IsFile(src) {
-		return fmt.Errorf("source is not a file: %s", src)
+		return ufs.misuseError("CopyFileWithPermissions", "source is not a file", src)
 	}
 
 	// Get source file info for permissions
@@ -309,6 +512,9 @@ func (ufs *UFS) CopyFileWithPermissions(src, dst string) error {
 	if err != nil {
 		return ufs.wrapError(err, "CopyFileWithPermissions")
 	}
+	if err := ufs.checkQuota(dst, srcInfo.Size()); err != nil {
+		return ufs.wrapError(err, "CopyFileWithPermissions")
+	}
 
 	// Ensure the destination directory exists
 	dstDir := filepath.Dir(dst)
@@ -320,7 +526,7 @@ func (ufs *UFS) CopyFileWithPermissions(src, dst string) error {
 	}
 
 	// Open source file
-	srcFile, err := os.Open(src)
+	srcFile, err := ufs.openSequential(src)
 	if err != nil {
 		return ufs.wrapError(err, "CopyFileWithPermissions")
 	}
@@ -333,16 +539,23 @@ func (ufs *UFS) CopyFileWithPermissions(src, dst string) error {
 	}
 	defer dstFile.Close()
 
-	// Copy the contents
-	_, err = io.Copy(dstFile, srcFile)
+	// Copy the contents, by cloning them when the file system supports it
+	err = ufs.cloneOrCopy(srcFile, dstFile)
+	ufs.recordQuota(dst)
 	if err != nil {
 		return ufs.wrapError(err, "CopyFileWithPermissions")
 	}
 
+	// Keep the resource fork and Finder info when Options.PreserveMacMetadata is set
+	if err := ufs.copyMacMetadata(src, dst); err != nil {
+		return ufs.wrapError(err, "CopyFileWithPermissions")
+	}
+
 	return nil
 }
 
 // MoveFileWithPermissions moves a file to a new location, preserving its permissions.
+// A move to another file system copies the file and removes the source once the copy is on disk.
 // If the destination file already exists, it will be overwritten.
 // This function will create any parent directories for the destination if they don't exist.
 //
@@ -361,10 +574,27 @@ func (ufs *UFS) CopyFileWithPermissions(src, dst string) error {
 //	    return
 //	}
 //	fmt.Println("File moved with permissions successfully")
-func (ufs *UFS) MoveFileWithPermissions(src, dst string) error {
+func (ufs *UFS) MoveFileWithPermissions(src, dst string) (err error) {
+	defer ufs.recoverPanic("MoveFileWithPermissions", &err)
+
+	if err := ufs.requireOS("MoveFileWithPermissions"); err != nil {
+		return err
+	}
+
 	// Verify source is a file
 	if !ufs.IsFile(src) {
-		return fmt.Errorf("source is not a file: %s", src)
+		return ufs.misuseError("MoveFileWithPermissions", "source is not a file", src)
+	}
+
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return ufs.wrapError(err, "MoveFileWithPermissions")
+	}
+	if err := ufs.checkQuota(dst, srcInfo.Size()); err != nil {
+		return ufs.wrapError(err, "MoveFileWithPermissions")
+	}
+	if ufs.dryRun("MoveFileWithPermissions", DryRunMove, dst, src) {
+		return nil
 	}
 
 	// Ensure the destination directory exists
@@ -377,19 +607,18 @@ func (ufs *UFS) MoveFileWithPermissions(src, dst string) error {
 	}
 
 	// Try to rename the file (only works on same file system)
-	err := os.Rename(src, dst)
+	err = os.Rename(src, dst)
 	if err == nil {
+		ufs.recordQuota(src, dst)
 		return nil
 	}
 
-	// If rename fails, try copy with permissions and delete
-	err = ufs.CopyFileWithPermissions(src, dst)
-	if err != nil {
-		return err
+	// Only a move to another file system is done by copying, see Cross-device-move.go
+	if !ufs.IsCrossDevice(err) {
+		return ufs.wrapError(err, "MoveFileWithPermissions")
 	}
-
-	// Delete the source file
-	err = os.Remove(src)
+	err = ufs.moveAcrossDevices(src, dst, false)
+	ufs.recordQuota(src, dst)
 	if err != nil {
 		return ufs.wrapError(err, "MoveFileWithPermissions")
 	}
@@ -416,7 +645,13 @@ func (ufs *UFS) MoveFileWithPermissions(src, dst string) error {
 //	    return
 //	}
 //	fmt.Println("Files combined successfully")
-func (ufs *UFS) AssembleFiles(srcFiles []string, dst string) error {
+func (ufs *UFS) AssembleFiles(srcFiles []string, dst string) (err error) {
+	defer ufs.recoverPanic("AssembleFiles", &err)
+
+	if err := ufs.requireOS("AssembleFiles"); err != nil {
+		return err
+	}
+
 	// Ensure all source files exist
 	for _, src := range srcFiles {
 		if !ufs.IsFile(src) {
@@ -458,7 +693,8 @@ func (ufs *UFS) AssembleFiles(srcFiles []string, dst string) error {
 }
 
 // SplitFile splits a file into multiple files based on a specified size limit.
-// This function will create the split files in the same directory as the original with suffixes _1, _2, etc.
+// This function will create the split files in the same directory as the original with suffixes _1, _2, etc.,
+// and a manifest of the parts to rebuild the file with AssembleFromManifest (see Split-manifest.go).
 //
 // Parameters:
 //   - src: The path to the source file to split
@@ -479,23 +715,40 @@ func (ufs *UFS) AssembleFiles(srcFiles []string, dst string) error {
 //	for i, file := range splitFiles {
 //	    fmt.Printf("Part %d: %s\n", i+1, file)
 //	}
-func (ufs *UFS) SplitFile(src string, chunkSize int64) ([]string, error) {
+func (ufs *UFS) SplitFile(src string, chunkSize int64) (_ []string, err error) {
+	defer ufs.recoverPanic("SplitFile", &err)
+
+	if err := ufs.requireOS("SplitFile"); err != nil {
+		return nil, err
+	}
+	return ufs.splitFileBySize(src, chunkSize, nil, "SplitFile")
+}
+
+// splitFileBySize splits a file into parts of chunkSize bytes, named after opts (see Split-options.go)
+func (ufs *UFS) splitFileBySize(src string, chunkSize int64, opts *SplitOptions, operation string) ([]string, error) {
 	// Verify source is a file
 	if !ufs.IsFile(src) {
-		return nil, fmt.Errorf("source is not a file: %s", src)
+		return nil, ufs.misuseError(operation, "source is not a file", src)
+	}
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("%s: chunk size must be positive, got %d", operation, chunkSize)
+	}
+	namer, err := opts.partNamer(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operation, err)
 	}
 
 	// Open source file
 	srcFile, err := os.Open(src)
 	if err != nil {
-		return nil, ufs.wrapError(err, "SplitFile")
+		return nil, ufs.wrapError(err, operation)
 	}
 	defer srcFile.Close()
 
 	// Get file info
 	srcInfo, err := srcFile.Stat()
 	if err != nil {
-		return nil, ufs.wrapError(err, "SplitFile")
+		return nil, ufs.wrapError(err, operation)
 	}
 
 	// Calculate number of parts
@@ -507,15 +760,14 @@ func (ufs *UFS) SplitFile(src string, chunkSize int64) ([]string, error) {
 	}
 
 	// Generate split file paths
-	baseDir := filepath.Dir(src)
-	baseExt := filepath.Ext(src)
-	baseName := filepath.Base(src)
-	baseName = strings.TrimSuffix(baseName, baseExt)
-
 	splitFiles := make([]string, numParts)
 	for i := range splitFiles {
-		splitFiles[i] = filepath.Join(baseDir, fmt.Sprintf("%s_%d%s", baseName, i+1, baseExt))
+		splitFiles[i] = namer(i + 1)
+	}
+	if err := os.MkdirAll(filepath.Dir(splitFiles[0]), 0755); err != nil {
+		return nil, ufs.wrapError(err, operation)
 	}
+	manifest := newSplitManifestWriter(src, filepath.Dir(splitFiles[0]), chunkSize, opts)
 
 	// Split the file
 	buffer := make([]byte, 4096) // 4KB read buffer
@@ -523,8 +775,9 @@ func (ufs *UFS) SplitFile(src string, chunkSize int64) ([]string, error) {
 		// Create part file
 		partFile, err := os.Create(splitFiles[i])
 		if err != nil {
-			return splitFiles[:i], ufs.wrapError(err, "SplitFile")
+			return splitFiles[:i], ufs.wrapError(err, operation)
 		}
+		partWriter := manifest.startPart(partFile)
 
 		// Write chunk
 		bytesWritten := int64(0)
@@ -537,17 +790,17 @@ func (ufs *UFS) SplitFile(src string, chunkSize int64) ([]string, error) {
 			n, err := srcFile.Read(buffer[:bytesToRead])
 			if err != nil && err != io.EOF {
 				partFile.Close()
-				return splitFiles[:i+1], ufs.wrapError(err, "SplitFile")
+				return splitFiles[:i+1], ufs.wrapError(err, operation)
 			}
 
 			if n == 0 {
 				break // End of file
 			}
 
-			_, err = partFile.Write(buffer[:n])
+			_, err = partWriter.Write(buffer[:n])
 			if err != nil {
 				partFile.Close()
-				return splitFiles[:i+1], ufs.wrapError(err, "SplitFile")
+				return splitFiles[:i+1], ufs.wrapError(err, operation)
 			}
 
 			bytesWritten += int64(n)
@@ -556,9 +809,15 @@ func (ufs *UFS) SplitFile(src string, chunkSize int64) ([]string, error) {
 			}
 		}
 
-		partFile.Close()
+		if err := partFile.Close(); err != nil {
+			return splitFiles[:i+1], ufs.wrapError(err, operation)
+		}
+		manifest.endPart(splitFiles[i])
 	}
 
+	if err := manifest.save(ufs); err != nil {
+		return splitFiles, err
+	}
 	return splitFiles, nil
 }
 
@@ -581,7 +840,9 @@ func (ufs *UFS) SplitFile(src string, chunkSize int64) ([]string, error) {
 //	    return
 //	}
 //	fmt.Printf("Removed %d empty files\n", len(removedFiles))
-func (ufs *UFS) CleanUpFiles(files []string) ([]string, error) {
+func (ufs *UFS) CleanUpFiles(files []string) (_ []string, err error) {
+	defer ufs.recoverPanic("CleanUpFiles", &err)
+
 	var removedFiles []string
 	var lastError error
 
@@ -592,7 +853,7 @@ func (ufs *UFS) CleanUpFiles(files []string) ([]string, error) {
 
 		// Check if file is empty
 		if ufs.IsFileEmpty(file) {
-			err := os.Remove(file)
+			err := ufs.backend().Remove(file)
 			if err != nil {
 				lastError = ufs.wrapError(err, "CleanUpFiles")
 				continue
@@ -627,21 +888,23 @@ func (ufs *UFS) CleanUpFiles(files []string) ([]string, error) {
 //	for i, line := range lines {
 //	    fmt.Printf("Line %d: %s\n", i+1, line)
 //	}
-func (ufs *UFS) ReadFileWithLines(path string) ([]string, error) {
+func (ufs *UFS) ReadFileWithLines(path string) (_ []string, err error) {
+	defer ufs.recoverPanic("ReadFileWithLines", &err)
+
 	// Verify source is a file
 	if !ufs.IsFile(path) {
-		return nil, fmt.Errorf("path is not a file: %s", path)
+		return nil, ufs.misuseError("ReadFileWithLines", "path is not a file", path)
 	}
 
 	// Open file
-	file, err := os.Open(path)
+	file, err := ufs.backend().OpenFile(path, os.O_RDONLY, 0)
 	if err != nil {
 		return nil, ufs.wrapError(err, "ReadFileWithLines")
 	}
 	defer file.Close()
 
 	// Read lines
-	scanner := bufio.NewScanner(file)
+	scanner := ufs.newLineScanner(file)
 	var lines []string
 	for scanner.Scan() {
 		lines = append(lines, scanner.Text())
@@ -673,11 +936,13 @@ func (ufs *UFS) ReadFileWithLines(path string) ([]string, error) {
 //	    return
 //	}
 //	fmt.Println("Content appended to last line successfully")
-func (ufs *UFS) AppendToLastLine(path string, content string) error {
+func (ufs *UFS) AppendToLastLine(path string, content string) (err error) {
+	defer ufs.recoverPanic("AppendToLastLine", &err)
+
 	// Ensure the directory exists
 	dir := filepath.Dir(path)
 	if !ufs.IsDirectory(dir) {
-		err := os.MkdirAll(dir, 0755)
+		err := mkdirAllBackend(ufs.backend(), dir, 0755)
 		if err != nil {
 			return ufs.wrapError(err, "AppendToLastLine")
 		}
@@ -711,11 +976,13 @@ func (ufs *UFS) AppendToLastLine(path string, content string) error {
 //	    return
 //	}
 //	fmt.Println("Content added as first line successfully")
-func (ufs *UFS) AppendToFirstLine(path string, content string) error {
+func (ufs *UFS) AppendToFirstLine(path string, content string) (err error) {
+	defer ufs.recoverPanic("AppendToFirstLine", &err)
+
 	// Ensure the directory exists
 	dir := filepath.Dir(path)
 	if !ufs.IsDirectory(dir) {
-		err := os.MkdirAll(dir, 0755)
+		err := mkdirAllBackend(ufs.backend(), dir, 0755)
 		if err != nil {
 			return ufs.wrapError(err, "AppendToFirstLine")
 		}
@@ -740,3 +1007,29 @@ func (ufs *UFS) AppendToFirstLine(path string, content string) error {
 
 	return ufs.WriteStringToFile(path, newContent)
 }
+
+// writeFileAtomic writes data to a synced temporary file in the directory of path, then renames it over path.
+// A perm of 0 keeps the default mode of the temporary file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(data)
+	if err == nil && perm != 0 {
+		err = temp.Chmod(perm)
+	}
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+	return err
+}
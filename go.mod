@@ -2,10 +2,15 @@ module github.com/utsav-56/ufs
 
 go 1.24.2
 
+require (
+	github.com/BurntSushi/toml v1.5.0
+	github.com/utsav-56/ulog v0.0.0-20250624154113-fa85904ae8c7
+	golang.org/x/sys v0.25.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
 require (
 	github.com/fatih/color v1.18.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/utsav-56/ulog v0.0.0-20250624154113-fa85904ae8c7 // indirect
-	golang.org/x/sys v0.25.0 // indirect
 )
@@ -11,6 +11,7 @@ var GetFolderMetadata = dufs.GetFolderMetadata
 var Get = dufs.GetFolderMetadata
 var GetFileMetadata = dufs.GetFileMetadata
 var GetChildCount = dufs.GetChildCount
+var ListFilesRecursive = dufs.ListFilesRecursive
 
 // Creations.go functions
 var CreateFile = dufs.CreateFile
@@ -23,6 +24,7 @@ var CreateSymlink = dufs.CreateSymlink
 var CreateHardLink = dufs.CreateHardLink
 var CreateDirectoryTree = dufs.CreateDirectoryTree
 var CreateDirectoryTreeWithPermissions = dufs.CreateDirectoryTreeWithPermissions
+var ReadDirectoryTree = dufs.ReadDirectoryTree
 var SymlinkDirectoryTree = dufs.SymlinkDirectoryTree
 var RenameFile = dufs.RenameFile
 var RenameDirectory = dufs.RenameDirectory
@@ -45,6 +47,8 @@ var SafeRemoveFile = dufs.SafeRemoveFile
 var ReadFile = dufs.ReadFile
 var ReadFileAsString = dufs.ReadFileAsString
 var WriteFile = dufs.WriteFile
+var WriteFileIfChanged = dufs.WriteFileIfChanged
+var WriteFileAtomic = dufs.WriteFileAtomic
 var WriteStringToFile = dufs.WriteStringToFile
 var AppendToFile = dufs.AppendToFile
 var AppendStringToFile = dufs.AppendStringToFile
@@ -55,11 +59,236 @@ var CopyFileWithPermissions = dufs.CopyFileWithPermissions
 var MoveFileWithPermissions = dufs.MoveFileWithPermissions
 var AssembleFiles = dufs.AssembleFiles
 var SplitFile = dufs.SplitFile
+var SplitFileWithOptions = dufs.SplitFileWithOptions
+var SplitFileByLines = dufs.SplitFileByLines
+var AssembleFromManifest = dufs.AssembleFromManifest
 var CleanUpFiles = dufs.CleanUpFiles
 var ReadFileWithLines = dufs.ReadFileWithLines
 var AppendToLastLine = dufs.AppendToLastLine
 var AppendToFirstLine = dufs.AppendToFirstLine
 
+// File-stream.go functions
+var WriteFileFromReader = dufs.WriteFileFromReader
+var ReadFileToWriter = dufs.ReadFileToWriter
+var OpenBufferedReader = dufs.OpenBufferedReader
+var OpenBufferedWriter = dufs.OpenBufferedWriter
+var IterateLines = dufs.IterateLines
+
+// File-lines.go functions
+var ReadFirstNLines = dufs.ReadFirstNLines
+var ReadLastNLines = dufs.ReadLastNLines
+var ReadLinesRange = dufs.ReadLinesRange
+var InsertLineAt = dufs.InsertLineAt
+var ReplaceLine = dufs.ReplaceLine
+var DeleteLine = dufs.DeleteLine
+var DeleteLinesMatching = dufs.DeleteLinesMatching
+
+// Generated-files.go functions
+var WriteGeneratedFile = dufs.WriteGeneratedFile
+
+// Json-files.go functions
+var ReadJSONFile = dufs.ReadJSONFile
+var WriteJSONFile = dufs.WriteJSONFile
+
+// Yaml-files.go functions
+var ReadYAMLFile = dufs.ReadYAMLFile
+var WriteYAMLFile = dufs.WriteYAMLFile
+
+// Toml-files.go functions
+var ReadTOMLFile = dufs.ReadTOMLFile
+var WriteTOMLFile = dufs.WriteTOMLFile
+
+// Csv-files.go functions
+var ReadCSVFile = dufs.ReadCSVFile
+var WriteCSVFile = dufs.WriteCSVFile
+var IterateCSVRows = dufs.IterateCSVRows
+
+// Config-files.go functions
+var ReadEnvFile = dufs.ReadEnvFile
+var WriteEnvFile = dufs.WriteEnvFile
+var ReadINI = dufs.ReadINI
+var WriteINI = dufs.WriteINI
+
+// Search.go functions
+var SearchInFile = dufs.SearchInFile
+var SearchInDirectory = dufs.SearchInDirectory
+
+// Releases.go functions
+var UpdateCurrentSymlink = dufs.UpdateCurrentSymlink
+var ListReleases = dufs.ListReleases
+var PruneOldReleases = dufs.PruneOldReleases
+
+// Directory-index.go functions
+var GenerateIndex = dufs.GenerateIndex
+
+// Replace.go functions
+var ReplaceInFile = dufs.ReplaceInFile
+var ReplaceInDirectory = dufs.ReplaceInDirectory
+
+// Collision-policy.go functions
+var MoveFileWithPolicy = dufs.MoveFileWithPolicy
+var MoveFileUnique = dufs.MoveFileUnique
+var CopyFileWithPolicy = dufs.CopyFileWithPolicy
+
+// Batch-operations.go functions
+var MoveFiles = dufs.MoveFiles
+var CopyFiles = dufs.CopyFiles
+var DeleteFiles = dufs.DeleteFiles
+
+// Operation-plan.go functions
+var PlanOperations = dufs.PlanOperations
+
+// Glob.go functions
+var Glob = dufs.Glob
+var GlobIn = dufs.GlobIn
+
+// Find.go functions
+var Find = dufs.Find
+var FindFunc = dufs.FindFunc
+
+// Walk.go functions
+var Walk = dufs.Walk
+
+// Walk-parallel.go functions
+var WalkParallel = dufs.WalkParallel
+
+// Directory-snapshot.go functions
+var SnapshotDirectory = dufs.SnapshotDirectory
+var SaveSnapshot = dufs.SaveSnapshot
+var LoadSnapshot = dufs.LoadSnapshot
+
+// Tree-render.go functions
+var RenderTree = dufs.RenderTree
+var RenderTreeFS = dufs.RenderTreeFS
+var PrintTree = dufs.PrintTree
+
+// Features.go functions
+var Features = dufs.Features
+
+// Extended-metadata.go functions
+var GetExtendedMetadata = dufs.GetExtendedMetadata
+var GetFileOwner = dufs.GetFileOwner
+var GetCreationTime = dufs.GetCreationTime
+
+// List-directory.go functions
+var ListDirectory = dufs.ListDirectory
+var ListDirectoryFS = dufs.ListDirectoryFS
+
+// Mac-metadata.go functions
+var ReadMacMetadata = dufs.ReadMacMetadata
+var WriteMacMetadata = dufs.WriteMacMetadata
+
+// Vendor-prune.go functions
+var PruneVendorTree = dufs.PruneVendorTree
+
+// Transaction.go functions
+var NewTransaction = dufs.NewTransaction
+
+// Dry-run.go functions
+var Preview = dufs.Preview
+var IsDryRun = dufs.IsDryRun
+var DryRunActions = dufs.DryRunActions
+var ResetDryRun = dufs.ResetDryRun
+
+// Name-index.go functions
+var BuildNameIndex = dufs.BuildNameIndex
+
+// Copy-verified.go functions
+var CopyFileVerified = dufs.CopyFileVerified
+var CopyDirectoryVerified = dufs.CopyDirectoryVerified
+
+// Scoped-storage.go functions
+var IsMobilePlatform = dufs.IsMobilePlatform
+var IsScopedStorage = dufs.IsScopedStorage
+var HasCapability = dufs.HasCapability
+
+// Backend.go functions
+var NewMemoryBackend = dufs.NewMemoryBackend
+
+// Shutdown-manager.go functions
+var NewShutdownManager = dufs.NewShutdownManager
+
+// Transfer-progress.go functions
+var CopyFileWithProgress = dufs.CopyFileWithProgress
+var MoveDirectoryWithProgress = dufs.MoveDirectoryWithProgress
+
+// Path-template.go functions
+var ExpandPathTemplate = dufs.ExpandPathTemplate
+var ExpandPathTemplateAt = dufs.ExpandPathTemplateAt
+
+// Copy-directory.go functions
+var CopyDirectory = dufs.CopyDirectory
+
+// Directory-quota.go functions
+var SetDirectoryQuota = dufs.SetDirectoryQuota
+var SetDirectoryQuotaWithOptions = dufs.SetDirectoryQuotaWithOptions
+var GetDirectoryQuota = dufs.GetDirectoryQuota
+var RefreshDirectoryQuota = dufs.RefreshDirectoryQuota
+var RemoveDirectoryQuota = dufs.RemoveDirectoryQuota
+var EvictOldestFiles = dufs.EvictOldestFiles
+
+// File-clone.go functions
+var CopyFileCloned = dufs.CopyFileCloned
+
+// Error-codes.go functions
+var ErrorCodeOf = dufs.ErrorCodeOf
+var NormalizeError = dufs.NormalizeError
+var IsPermissionDenied = dufs.IsPermissionDenied
+var IsNotFound = dufs.IsNotFound
+var IsAlreadyExists = dufs.IsAlreadyExists
+var IsDirectoryNotEmpty = dufs.IsDirectoryNotEmpty
+var IsDiskFull = dufs.IsDiskFull
+var IsCrossDevice = dufs.IsCrossDevice
+var IsFileInUse = dufs.IsFileInUse
+
+// File-primitives.go functions
+var TruncateFile = dufs.TruncateFile
+var TouchFile = dufs.TouchFile
+var SetFileTimes = dufs.SetFileTimes
+var PreallocateFile = dufs.PreallocateFile
+
+// Permissions-compare.go functions
+var ComparePermissions = dufs.ComparePermissions
+
+// Operation-queue.go functions
+var OpenOperationQueue = dufs.OpenOperationQueue
+
+// File-type.go functions
+var IsBinaryFile = dufs.IsBinaryFile
+var DetectMIMEType = dufs.DetectMIMEType
+var GetFileKind = dufs.GetFileKind
+
+// Directory-lock.go functions
+var LockDirectory = dufs.LockDirectory
+
+// Archive-fs.go functions
+var OpenArchiveFS = dufs.OpenArchiveFS
+var ReadArchiveTree = dufs.ReadArchiveTree
+
+// Ingest.go functions
+var IngestFile = dufs.IngestFile
+
+// Route-files.go functions
+var RouteFiles = dufs.RouteFiles
+
+// Checksums.go functions
+var HashFile = dufs.HashFile
+var HashDirectory = dufs.HashDirectory
+var HashDirectoryParallel = dufs.HashDirectoryParallel
+var VerifyChecksumManifest = dufs.VerifyChecksumManifest
+var WriteChecksumSidecars = dufs.WriteChecksumSidecars
+var VerifyChecksumSidecars = dufs.VerifyChecksumSidecars
+
+// Signing.go functions
+var SignFile = dufs.SignFile
+var VerifyFileSignature = dufs.VerifyFileSignature
+
+// Directory-merge.go functions
+var MoveDirectoryWithMerge = dufs.MoveDirectoryWithMerge
+
+// Merge-lines.go functions
+var MergeFileLines = dufs.MergeFileLines
+
 // Path-properties.go functions
 var PathExists = dufs.PathExists
 var IsFile = dufs.IsFile
@@ -79,6 +308,15 @@ var IsDirectoryReadable = dufs.IsDirectoryReadable
 // Compress-Extract.go functions
 var CompressDirectory = dufs.CompressDirectory
 var ExtractArchive = dufs.ExtractArchive
+var CompressDirectoryContext = dufs.CompressDirectoryContext
+var ExtractArchiveContext = dufs.ExtractArchiveContext
+var ExtractFiles = dufs.ExtractFiles
+var ExtractMatching = dufs.ExtractMatching
+
+// Composite-operations.go functions
+var CopyThenCompress = dufs.CopyThenCompress
+var DownloadAndExtract = dufs.DownloadAndExtract
+var CompressAndUpload = dufs.CompressAndUpload
 var CompressFile = dufs.CompressFile
 var CompressHere = dufs.CompressHere
 var ExtractHere = dufs.ExtractHere
@@ -90,4 +328,67 @@ var ExtractAndCompress = dufs.ExtractAndCompress
 var CompressWithSystemCommand = dufs.CompressWithSystemCommand
 var ExtractWithSystemCommand = dufs.ExtractWithSystemCommand
 
+// Compress-options.go functions
+var CompressDirectoryWithOptions = dufs.CompressDirectoryWithOptions
+var CompressFileWithOptions = dufs.CompressFileWithOptions
+
+// Extract-options.go functions
+var ExtractArchiveWithOptions = dufs.ExtractArchiveWithOptions
+var ExtractWithSystemCommandOptions = dufs.ExtractWithSystemCommandOptions
+
+// Compress-stream.go functions
+var CompressDirectoryTo = dufs.CompressDirectoryTo
+var ExtractArchiveFrom = dufs.ExtractArchiveFrom
+
+// Compress-split.go functions
+var CompressDirectorySplit = dufs.CompressDirectorySplit
+var ExtractSplitArchive = dufs.ExtractSplitArchive
+
+// Compress-checkpoint.go functions
+var CompressDirectoryCheckpointed = dufs.CompressDirectoryCheckpointed
+
+// Backup-incremental.go functions
+var CompressDirectoryIncremental = dufs.CompressDirectoryIncremental
+var RestoreIncremental = dufs.RestoreIncremental
+
+// Chunk-archive.go functions
+var ChunkArchive = dufs.ChunkArchive
+var RestoreChunkArchive = dufs.RestoreChunkArchive
+
+// Archive-inspect.go functions
+var ListArchiveContents = dufs.ListArchiveContents
+var CompareDirectoryWithArchive = dufs.CompareDirectoryWithArchive
+var VerifyArchive = dufs.VerifyArchive
+var TestArchive = dufs.TestArchive
+
+// Archive-tools.go functions
+var Extract7z = dufs.Extract7z
+var ExtractRar = dufs.ExtractRar
+var ExtractEncrypted = dufs.ExtractEncrypted
+
 var MoveDirectory = dufs.MoveDirectory
+
+// Compare-Sync.go functions
+var CompareDirectories = dufs.CompareDirectories
+var DiffDirectories = dufs.DiffDirectories
+var SyncDirectories = dufs.SyncDirectories
+var BidirectionalSync = dufs.BidirectionalSync
+var FileChanged = dufs.FileChanged
+var FilesEqual = dufs.FilesEqual
+
+// Copy-parallel.go functions
+var CopyDirectoryParallel = dufs.CopyDirectoryParallel
+
+// Seed-files.go functions
+var SeedIfMissing = dufs.SeedIfMissing
+
+// Disk-usage.go functions
+var GetDiskUsage = dufs.GetDiskUsage
+var WatchFreeSpace = dufs.WatchFreeSpace
+var WatchFreeSpaceWithOptions = dufs.WatchFreeSpaceWithOptions
+
+// Cache-dir.go functions
+var OpenCacheDir = dufs.OpenCacheDir
+
+// Dev-cleanup.go functions
+var CleanDevArtifacts = dufs.CleanDevArtifacts
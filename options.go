@@ -1,22 +1,149 @@
 package ufs
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 
 	"github.com/utsav-56/ulog"
 )
 
 // options.go
 
+// WalkDecision tells a recursive operation what to do after an error on a single path.
+type WalkDecision int
+
+const (
+	// WalkSkip skips the path that failed and continues with the rest of the tree
+	WalkSkip WalkDecision = iota
+	// WalkAbort stops the whole operation and reports the error
+	WalkAbort
+)
+
 type Options struct {
 	ShowError      bool
 	ReturnReadable bool
 	prettifyError  bool // If true, prettify the error messages
+
+	// OnWalkError is called by recursive operations (GetFolderSize, CompressDirectory,
+	// CompareDirectories, ReadDirectoryTree, ...) when a single path inside the tree can't be read,
+	// e.g. permission denied on one folder. When nil every operation keeps its default behaviour.
+	OnWalkError func(path string, err error) WalkDecision
+
+	// CompressionWorkers is the number of files CompressDirectory deflates concurrently.
+	// 0 or 1 keeps the sequential mode; runtime.NumCPU() is a good value for large trees.
+	CompressionWorkers int
+
+	// ReadAheadBytes asks the OS to prefetch this many bytes ahead of large sequential reads
+	// (file copies and hashing). 0 keeps the OS default read-ahead.
+	ReadAheadBytes int64
+
+	// DirectIO reads files for copies and hashing without going through the page cache,
+	// for backup workloads that shouldn't evict the cache of other programs.
+	DirectIO bool
+
+	// IOPriority lowers the disk priority of heavy operations (archiving, copies, comparisons),
+	// for background jobs that shouldn't slow down interactive programs. See Io-priority.go.
+	IOPriority IOPriority
+
+	// ExtractLimits bounds what a single extraction (ExtractArchive, ExtractArchiveWithOptions, ExtractFiles, ...)
+	// may write, for services extracting untrusted archives (decompression bombs).
+	// The zero value doesn't limit anything. ExtractOptions.Limits overrides it for a single call.
+	// Extractions done by the system tar (ExtractWithSystemCommand) are not limited.
+	ExtractLimits ExtractLimits
+
+	// CreatePolicies give default permissions and header content to the files created by
+	// CreateFile and CreateFileWithContent, the first policy matching the file name is used.
+	// See Create-policies.go.
+	CreatePolicies []CreatePolicy
+
+	// MaxLineSize is the longest line, in bytes, accepted by the line reading functions
+	// (IterateLines, ReadFileWithLines). 0 keeps the bufio.Scanner default of 64 KiB.
+	// Longer lines fail with bufio.ErrTooLong.
+	MaxLineSize int
+
+	// ExcludeHidden leaves hidden files and directories (see IsFileHidden) out of listings, copies,
+	// archives and statistics: GetFileList, GetFolderList, the Get*Count functions, GetFolderSize,
+	// ListFilesRecursive, Glob, GlobIn, RemoveByPattern, CopyDirectory, CopyDirectoryParallel and CompressDirectory.
+	// Hidden directories are skipped with their contents. The default includes hidden entries.
+	ExcludeHidden bool
+
+	// DisableReflink makes CopyFile and CopyFileWithPermissions always copy the content of files,
+	// instead of cloning them on file systems supporting it (Btrfs, XFS, APFS, ReFS), e.g. for backups
+	// that must not share disk blocks with the source. See File-clone.go.
+	DisableReflink bool
+
+	// Backend is the storage of the core file and directory functions, see Backend.go.
+	// nil uses the operating system, or under GOOS=js a file system held in memory.
+	Backend Backend
+
+	// ScopedStorage restricts the instance to the operations allowed in mobile sandboxes, see
+	// Scoped-storage.go. The default enables it on Android and iOS only.
+	ScopedStorage ScopedStorageMode
+
+	// Overwrite decides what MoveFile and CopyFile do when the destination file exists, see
+	// Collision-policy.go. The default, OverwriteExisting, replaces it.
+	Overwrite OverwritePolicy
+
+	// ExpandOutputPaths expands the date, time and host name placeholders of the output paths of the
+	// compression and backup functions, e.g. "/backups/data-{date}.zip", see Path-template.go.
+	// The default uses the paths as they are.
+	ExpandOutputPaths bool
+
+	// DryRun makes the move, delete, remove, sync and compress functions record the changes they
+	// would make instead of making them, see Dry-run.go and DryRunActions.
+	DryRun bool
+
+	// OnDryRun is called with each change recorded in dry-run mode, e.g. to print the preview as it goes.
+	OnDryRun func(DryRunAction)
+
+	// PreserveMacMetadata keeps the resource forks and Finder info of files on macOS: copies and moves
+	// copy them, archives store them as AppleDouble entries and extractions restore them.
+	// See Mac-metadata.go. Other systems ignore it.
+	PreserveMacMetadata bool
+
+	// WarningsSink receives the warnings of the boolean functions (source is not a file, directory not
+	// empty, ...), e.g. to show them in a UI. They are still printed when ShowError is set.
+	// In strict mode they are errors passed to ErrorSink instead, see StrictMode.
+	// It may be called from several goroutines at once.
+	WarningsSink func(message string)
+
+	// StrictMode reports the misuses of the boolean functions (source is not a file, directory not
+	// empty, new name is a path, ...) as *MisuseError errors instead of warnings: they are logged as
+	// errors and passed to ErrorSink rather than WarningsSink, for pipelines that must fail loudly.
+	// The functions returning errors return a *MisuseError for the same mistakes (a path of the wrong
+	// kind), matching ErrMisuse with errors.Is, instead of a plain error.
+	StrictMode bool
+
+	// ErrorSink receives the errors of the functions reporting them by returning false or a zero value
+	// instead of an error, e.g. to collect them. They are still printed when ShowError is set.
+	// It may be called from several goroutines at once.
+	ErrorSink func(err error)
+
+	// ListingOrder is the order of the names returned by GetFileList and GetFolderList, the byte order
+	// of the names by default. ListNatural sorts them like file managers, ListUnsorted skips sorting.
+	// See Listing-order.go.
+	ListingOrder ListingOrder
+
+	// ListingDescending reverses ListingOrder
+	ListingDescending bool
 }
 
 type UFS struct {
 	opts Options
+
+	// Directories with a quota, by absolute path, see Directory-quota.go
+	quotaMu sync.Mutex
+	quotas  map[string]*directoryQuota
+
+	// Manager stopping the operations on shutdown, see Shutdown-manager.go
+	shutdown atomic.Pointer[ShutdownManager]
+
+	// Changes recorded in dry-run mode, see Dry-run.go
+	dryRunMu      sync.Mutex
+	dryRunActions []DryRunAction
 }
 
 var dufs *UFS = &UFS{
@@ -51,6 +178,9 @@ func (ufs *UFS) SetOptions(opts *Options) {
 }
 
 func (ufs *UFS) handleError(err error, operation ...string) {
+	if ufs.opts.ErrorSink != nil {
+		ufs.opts.ErrorSink(err)
+	}
 	if ufs.opts.ShowError {
 
 		errMessage := err.Error()
@@ -71,7 +201,40 @@ func (ufs *UFS) handleError(err error, operation ...string) {
 	// Simply do nothing if ShowError is false
 }
 
+// reportMisuse reports a call on a path of the wrong kind or with an invalid argument, as a warning
+// or as a *MisuseError in strict mode
+func (ufs *UFS) reportMisuse(operation, reason, path string) {
+	ufs.reportFailure(&MisuseError{Op: operation, Reason: reason, Path: path}, operation)
+}
+
+// misuseError returns the error of a function returning errors called on a path of the wrong kind or
+// with an invalid argument: a *MisuseError in strict mode, a plain "message: path" error otherwise
+func (ufs *UFS) misuseError(operation, message, path string) error {
+	if ufs.opts.StrictMode {
+		return &MisuseError{Op: operation, Reason: message, Path: path}
+	}
+	return fmt.Errorf("%s: %s", message, path)
+}
+
+// reportFailure reports why a boolean function failed: a *MisuseError like reportMisuse, other errors
+// like handleError. It returns whether err is nil.
+func (ufs *UFS) reportFailure(err error, operation string) bool {
+	if err == nil {
+		return true
+	}
+	var misuse *MisuseError
+	if errors.As(err, &misuse) && !ufs.opts.StrictMode {
+		ufs.handleMistakeWarning(err.Error())
+		return false
+	}
+	ufs.handleError(err, operation)
+	return false
+}
+
 func (ufs *UFS) handleMistakeWarning(mesage string) {
+	if ufs.opts.WarningsSink != nil {
+		ufs.opts.WarningsSink(mesage)
+	}
 	if ufs.opts.ShowError {
 		if ufs.opts.prettifyError {
 			ulog.Warning(mesage)
@@ -81,6 +244,24 @@ func (ufs *UFS) handleMistakeWarning(mesage string) {
 	}
 }
 
+// decideWalkError asks OnWalkError what to do with an error met on path during a recursive operation.
+// It returns nil when the path should be skipped and the error when the operation must abort.
+// Without a callback the operation's default decision is used, skipped errors are still reported.
+func (ufs *UFS) decideWalkError(path string, err error, defaultDecision WalkDecision, operation string) error {
+	if ufs.opts.OnWalkError == nil {
+		if defaultDecision == WalkAbort {
+			return err
+		}
+		ufs.handleError(err, operation)
+		return nil
+	}
+
+	if ufs.opts.OnWalkError(path, err) == WalkAbort {
+		return err
+	}
+	return nil
+}
+
 // wrapError is a helper function to wrap errors with function names
 func (ufs *UFS) wrapError(err error, functionName string) error {
 	if err != nil {